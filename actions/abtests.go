@@ -0,0 +1,233 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cbroglie/mustache"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+func GetABTest(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		test, err := storage.GetABTest(id, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "A/B test not found.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, test)
+	}
+}
+
+func PutABTest(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		campaign, err := storage.GetCampaign(id, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found.",
+			})
+			return
+		}
+
+		if campaign.Status != entities.StatusDraft && campaign.Status != entities.StatusScheduled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "The A/B test can be changed only before the campaign is sent.",
+			})
+			return
+		}
+
+		body := &params.PutABTest{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		test := &entities.ABTest{
+			CampaignID:     campaign.ID,
+			UserID:         u.ID,
+			TestPercentage: body.TestPercentage,
+			WaitMinutes:    body.WaitMinutes,
+			WinnerMetric:   body.WinnerMetric,
+			Status:         entities.ABTestStatusDraft,
+		}
+
+		for _, v := range body.Variants {
+			variant := entities.CampaignVariant{Name: v.Name}
+
+			if v.SubjectPart != "" {
+				if _, err := mustache.ParseString(v.SubjectPart); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"message": "Invalid subject of variant " + v.Name + ".",
+					})
+					return
+				}
+				subject := v.SubjectPart
+				variant.SubjectPart = &subject
+			}
+
+			if v.TemplateName != "" {
+				template, err := storage.GetTemplateByName(v.TemplateName, u.ID)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"message": "Template " + v.TemplateName + " of variant " + v.Name + " not found.",
+					})
+					return
+				}
+				variant.TemplateID = &template.ID
+			}
+
+			test.Variants = append(test.Variants, variant)
+		}
+
+		err = storage.SaveABTest(test)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("Unable to save A/B test.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to save the A/B test, please try again.",
+			})
+			return
+		}
+
+		test, err = storage.GetABTest(id, u.ID)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("Unable to fetch A/B test.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to save the A/B test, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, test)
+	}
+}
+
+func DeleteABTest(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		campaign, err := storage.GetCampaign(id, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found.",
+			})
+			return
+		}
+
+		if campaign.Status != entities.StatusDraft && campaign.Status != entities.StatusScheduled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "The A/B test can be changed only before the campaign is sent.",
+			})
+			return
+		}
+
+		err = storage.DeleteABTest(id, u.ID)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("Unable to delete A/B test.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to delete the A/B test, please try again.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func GetABTestStats(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		test, err := storage.GetABTest(id, u.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "A/B test not found.",
+				})
+				return
+			}
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("Unable to fetch A/B test.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch the A/B test stats, please try again.",
+			})
+			return
+		}
+
+		stats := make([]entities.VariantStats, 0, len(test.Variants))
+		for _, v := range test.Variants {
+			s, err := storage.GetVariantStats(id, u.ID, v.ID)
+			if err != nil {
+				logger.From(c).WithField("campaign_id", id).WithError(err).Error("Unable to fetch variant stats.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to fetch the A/B test stats, please try again.",
+				})
+				return
+			}
+
+			stats = append(stats, entities.VariantStats{
+				Variant: v,
+				Winner:  test.WinnerVariantID != nil && *test.WinnerVariantID == v.ID,
+				Stats:   s,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"winner_metric": test.WinnerMetric,
+			"collection":    stats,
+		})
+	}
+}
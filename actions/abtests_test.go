@@ -0,0 +1,159 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestABTest(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Twice().Return(&s3.PutObjectAclOutput{}, nil)
+
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	templateName := auth.POST("/api/templates").WithJSON(params.PostTemplate{Name: "abtest1", HTMLPart: "<html> bla </html>", TextPart: "txtpart", SubjectPart: "subpart"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Value("name").String().Raw()
+
+	variantTemplate := auth.POST("/api/templates").WithJSON(params.PostTemplate{Name: "abtest2", HTMLPart: "<html> foo </html>", TextPart: "txtpart", SubjectPart: "subpart"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Value("name").String().Raw()
+
+	id := auth.POST("/api/campaigns").WithJSON(params.PostCampaign{Name: "abtest", TemplateName: templateName}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().Value("id")
+
+	idStr := strconv.FormatFloat(id.Raw().(float64), 'f', 0, 64)
+
+	auth.GET("/api/campaigns/" + idStr + "/ab-test").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.PUT("/api/campaigns/"+idStr+"/ab-test").WithJSON(params.PutABTest{WinnerMetric: "foo"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"test_percentage": "This field is required",
+			"wait_minutes":    "This field is required",
+			"winner_metric":   "Must be one of: opens clicks",
+			"variants":        "This field is required",
+		})
+
+	auth.PUT("/api/campaigns/"+idStr+"/ab-test").WithJSON(params.PutABTest{
+		TestPercentage: 20,
+		WaitMinutes:    60,
+		WinnerMetric:   "opens",
+		Variants: []params.ABTestVariant{
+			{Name: "A"},
+			{Name: "B", TemplateName: "not-found"},
+		},
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Template not-found of variant B not found.")
+
+	variants := auth.PUT("/api/campaigns/"+idStr+"/ab-test").WithJSON(params.PutABTest{
+		TestPercentage: 20,
+		WaitMinutes:    60,
+		WinnerMetric:   "opens",
+		Variants: []params.ABTestVariant{
+			{Name: "A", SubjectPart: "Hello {{name}}"},
+			{Name: "B", TemplateName: variantTemplate},
+		},
+	}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", "draft").
+		Value("variants").Array()
+
+	variants.Length().Equal(2)
+	variants.Element(0).Object().ValueEqual("subject_part", "Hello {{name}}")
+	variants.Element(1).Object().Value("template").Object().ValueEqual("name", variantTemplate)
+
+	auth.GET("/api/campaigns/"+idStr+"/ab-test").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("test_percentage", 20).
+		ValueEqual("winner_metric", "opens")
+
+	stats := auth.GET("/api/campaigns/" + idStr + "/ab-test/stats").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array()
+
+	stats.Length().Equal(2)
+	stats.Element(0).Object().ValueEqual("winner", false)
+	stats.Element(0).Object().Value("stats").Object().ValueEqual("total_sent", 0)
+
+	auth.DELETE("/api/campaigns/" + idStr + "/ab-test").
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/campaigns/" + idStr + "/ab-test").
+		Expect().
+		Status(http.StatusNotFound)
+}
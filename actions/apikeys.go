@@ -0,0 +1,216 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/utils"
+	"github.com/mailbadger/app/validator"
+)
+
+// GetAPIKeys returns the api keys of the user.
+func GetAPIKeys(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := storage.GetAPIKeys(middleware.GetUser(c).ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch api keys.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch api keys. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+// GetAPIKey returns the api key by the given id.
+func GetAPIKey(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k, ok := apiKeyFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, k)
+	}
+}
+
+// PostAPIKey generates a new api key. The key is returned only in the response, afterwards
+// the key can be identified by its prefix.
+func PostAPIKey(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &params.PostAPIKey{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		if body.ExpiresAt != nil && body.ExpiresAt.Before(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The expiry date must be in the future.",
+			})
+			return
+		}
+
+		prefix, err := utils.GenerateRandomString(6)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to generate api key prefix.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create api key. Please try again.",
+			})
+			return
+		}
+		secret, err := utils.GenerateRandomString(24)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to generate api key secret.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create api key. Please try again.",
+			})
+			return
+		}
+
+		prefix = entities.APIKeyPrefix + prefix
+		key := prefix + "." + secret
+
+		k := &entities.APIKey{
+			UserID:    middleware.GetUser(c).ID,
+			Name:      body.Name,
+			Prefix:    prefix,
+			SecretKey: entities.HashAPIKey(key),
+			Scopes:    body.Scopes,
+			Active:    true,
+		}
+		if body.ExpiresAt != nil {
+			k.ExpiresAt = entities.TimeFrom(*body.ExpiresAt)
+		}
+
+		err = storage.CreateAPIKey(k)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create api key.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create api key. Please try again.",
+			})
+			return
+		}
+
+		k.Key = key
+
+		c.JSON(http.StatusCreated, k)
+	}
+}
+
+// PutAPIKey updates the name, scopes, expiry and the status of the api key.
+func PutAPIKey(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k, ok := apiKeyFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		body := &params.PutAPIKey{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		if body.ExpiresAt != nil && body.ExpiresAt.Before(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The expiry date must be in the future.",
+			})
+			return
+		}
+
+		k.Name = body.Name
+		k.Scopes = body.Scopes
+		k.Active = body.Active
+		k.ExpiresAt = entities.NullTime{}
+		if body.ExpiresAt != nil {
+			k.ExpiresAt = entities.TimeFrom(*body.ExpiresAt)
+		}
+
+		err := storage.UpdateAPIKey(k)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to update api key.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update api key. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, k)
+	}
+}
+
+// DeleteAPIKey revokes the api key.
+func DeleteAPIKey(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k, ok := apiKeyFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		err := storage.DeleteAPIKey(k.ID, k.UserID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete api key.")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to delete api key.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func apiKeyFromParam(c *gin.Context, storage storage.Storage) (*entities.APIKey, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	k, err := storage.GetAPIKeyByID(id, middleware.GetUser(c).ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "API key not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch api key.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch api key. Please try again.",
+		})
+		return nil, false
+	}
+
+	return k, true
+}
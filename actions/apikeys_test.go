@@ -0,0 +1,163 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestAPIKeys(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	auth.POST("/api/api-keys").WithJSON(params.PostAPIKey{Name: "integration", Scopes: []string{"users:write"}}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	past := time.Now().Add(-time.Hour)
+	auth.POST("/api/api-keys").WithJSON(params.PostAPIKey{Name: "integration", Scopes: []string{"subscribers:read"}, ExpiresAt: &past}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The expiry date must be in the future.")
+
+	created := auth.POST("/api/api-keys").WithJSON(params.PostAPIKey{Name: "integration", Scopes: []string{"subscribers:read"}}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object()
+
+	created.ValueEqual("name", "integration")
+	created.ValueEqual("active", true)
+	created.NotContainsKey("secret_key")
+	key := created.Value("key").String().Raw()
+	created.Value("prefix").String().Contains("mb_")
+	id := strconv.FormatFloat(created.Value("id").Number().Raw(), 'f', 0, 64)
+
+	// the key is shown only once
+	auth.GET("/api/api-keys").
+		Expect().
+		Status(http.StatusOK).JSON().Array().
+		First().Object().
+		NotContainsKey("key").
+		ValueEqual("last_used_at", nil)
+
+	// a fresh client without the session cookie
+	client := setup(t, s, sess, mockS3, mockPub, mockSender, templatesvc, boundarysvc, subscrsvc, reportsvc, compiler, false, false)
+
+	client.GET("/api/subscribers").WithHeader(middleware.APIKeyAuth, "foobar").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	client.GET("/api/subscribers").WithHeader(middleware.APIKeyAuth, key).
+		Expect().
+		Status(http.StatusOK)
+
+	client.POST("/api/subscribers").WithHeader(middleware.APIKeyAuth, key).
+		WithJSON(params.PostSubscriber{Name: "Jane", Email: "jane@example.com"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	client.GET("/api/campaigns").WithHeader(middleware.APIKeyAuth, key).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	client.GET("/api/api-keys").WithHeader(middleware.APIKeyAuth, key).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.GET("/api/api-keys/" + id).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("last_used_at").NotNull()
+
+	auth.PUT("/api/api-keys/" + id).WithJSON(params.PutAPIKey{Name: "integration", Scopes: []string{"subscribers:write", "campaigns:read"}, Active: true}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("scopes").Array().Length().Equal(2)
+
+	client.POST("/api/subscribers").WithHeader(middleware.APIKeyAuth, key).
+		WithJSON(params.PostSubscriber{Name: "Jane", Email: "jane@example.com"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	client.GET("/api/campaigns").WithHeader(middleware.APIKeyAuth, key).
+		Expect().
+		Status(http.StatusOK)
+
+	auth.PUT("/api/api-keys/" + id).WithJSON(params.PutAPIKey{Name: "integration", Scopes: []string{"*"}, Active: false}).
+		Expect().
+		Status(http.StatusOK)
+
+	client.GET("/api/campaigns").WithHeader(middleware.APIKeyAuth, key).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.DELETE("/api/api-keys/" + id).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/api-keys/" + id).
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.GET("/api/api-keys/foo").
+		Expect().
+		Status(http.StatusBadRequest)
+}
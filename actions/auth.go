@@ -2,14 +2,13 @@ package actions
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v25/github"
@@ -36,7 +35,8 @@ import (
 	"github.com/mailbadger/app/validator"
 )
 
-// PostAuthenticate authenticates a user with the given username and password.
+// PostAuthenticate authenticates a user with the given username and password. When the user has enabled
+// the two-factor authentication, a pending session is created and the code must be entered to sign in.
 func PostAuthenticate(storage storage.Storage, sess session.Session) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		body := &params.PostAuthenticate{}
@@ -79,6 +79,37 @@ func PostAuthenticate(storage storage.Storage, sess session.Session) gin.Handler
 			return
 		}
 
+		required, err := ssoRequired(storage, user)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to check if single sign-on is enforced.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to authenticate, please try again.",
+			})
+			return
+		}
+		if required {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "Your organization requires single sign-on, please sign in with SSO.",
+			})
+			return
+		}
+
+		if user.TwoFactorEnabled {
+			err = sess.CreatePendingSession(c, user.ID)
+			if err != nil {
+				logger.From(c).WithError(err).Error("Cannot persist pending session id.")
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Unable to create session.",
+				})
+				return
+			}
+
+			c.JSON(http.StatusAccepted, gin.H{
+				"two_factor_required": true,
+			})
+			return
+		}
+
 		err = sess.CreateUserSession(c, user.ID)
 		if err != nil {
 			logger.From(c).WithError(err).Error("Cannot persist session id.")
@@ -211,7 +242,7 @@ func PostSignup(
 
 		if verifyEmail {
 			go func(c *gin.Context) {
-				err := sendVerifyEmail(storage, emailSender, user, systemEmailSource, appURL)
+				err := sendVerifyEmail(c, storage, emailSender, user, systemEmailSource, appURL)
 				if err != nil {
 					logger.From(c).WithError(err).Error("Unable to send verification email.")
 				}
@@ -317,7 +348,7 @@ func GithubCallback(
 			return
 		}
 
-		completeCallback(c, storage, sess, ghUser.GetEmail(), "github", appURL)
+		completeCallback(c, storage, sess, ghUser.GetEmail(), "github", appURL, nil)
 	}
 }
 
@@ -424,7 +455,7 @@ func GoogleCallback(
 			return
 		}
 
-		completeCallback(c, storage, sess, gUser.Email, "google", appURL)
+		completeCallback(c, storage, sess, gUser.Email, "google", appURL, nil)
 	}
 }
 
@@ -533,7 +564,7 @@ func FacebookCallback(
 			return
 		}
 
-		completeCallback(c, storage, sess, emailStr, "facebook", appURL)
+		completeCallback(c, storage, sess, emailStr, "facebook", appURL, nil)
 	}
 }
 
@@ -555,6 +586,9 @@ func PostLogout(sess session.Session) gin.HandlerFunc {
 	}
 }
 
+// completeCallback signs in the user with the given email, the user is created if it doesn't exist.
+// The onSignIn func is called with the user before the session is created, it's optional.
+// The callbacks of the POST requests are redirected with a GET request to the app.
 func completeCallback(
 	c *gin.Context,
 	storage storage.Storage,
@@ -562,26 +596,32 @@ func completeCallback(
 	email string,
 	source string,
 	appURL string,
+	onSignIn func(u *entities.User) error,
 ) {
+	status := http.StatusTemporaryRedirect
+	if c.Request.Method == http.MethodPost {
+		status = http.StatusSeeOther
+	}
+
 	u, err := storage.GetUserByUsername(email)
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			logger.From(c).WithError(err).Error("social auth callback: unable to fetch user by username")
-			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=register-failed")
+			c.Redirect(status, appURL+"/login?message=register-failed")
 			return
 		}
 
 		b, err := storage.GetBoundariesByType(entities.BoundaryTypeFree)
 		if err != nil {
 			logger.From(c).WithError(err).Error("social auth callback: unable to fetch boundary")
-			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=register-failed")
+			c.Redirect(status, appURL+"/login?message=register-failed")
 			return
 		}
 
 		r, err := storage.GetRole(entities.AdminRole)
 		if err != nil {
 			logger.From(c).WithError(err).Error("social auth callback: unable to fetch admin role")
-			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=register-failed")
+			c.Redirect(status, appURL+"/login?message=register-failed")
 			return
 		}
 
@@ -600,28 +640,70 @@ func completeCallback(
 		err = storage.CreateUser(u)
 		if err != nil {
 			logger.From(c).WithError(err).Error("social auth callback: unable to create user")
-			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=register-failed")
+			c.Redirect(status, appURL+"/login?message=register-failed")
 			return
 		}
 	}
 
 	if !u.Active {
 		logger.From(c).WithField("user_id", u.ID).Warn("social auth callback: inactive user sign in")
-		c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=forbidden")
+		c.Redirect(status, appURL+"/login?message=forbidden")
+		return
+	}
+
+	if onSignIn != nil {
+		err = onSignIn(u)
+		if err != nil {
+			logger.From(c).WithField("user_id", u.ID).WithError(err).Error("auth callback: unable to complete sign in")
+			c.Redirect(status, appURL+"/login?message=server-error")
+			return
+		}
+	}
+
+	if u.TwoFactorEnabled {
+		err = sess.CreatePendingSession(c, u.ID)
+		if err != nil {
+			logger.From(c).WithField("user_id", u.ID).WithError(err).Error("Cannot persist pending session.")
+			c.Redirect(status, appURL+"/login?message=forbidden")
+			return
+		}
+
+		c.Redirect(status, appURL+"/login/two-factor")
 		return
 	}
 
 	err = sess.CreateUserSession(c, u.ID)
 	if err != nil {
 		logger.From(c).WithField("user_id", u.ID).WithError(err).Error("Cannot persist session.")
-		c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=forbidden")
+		c.Redirect(status, appURL+"/login?message=forbidden")
 		return
 	}
 
-	c.Redirect(http.StatusTemporaryRedirect, appURL+"/dashboard")
+	c.Redirect(status, appURL+"/dashboard")
+}
+
+// ssoRequired reports whether the user must sign in with single sign-on, either because the user
+// was provisioned by a connection or because a team of the user enforces the connection of its domain.
+func ssoRequired(storage storage.Storage, u *entities.User) (bool, error) {
+	if u.Source == entities.SSOProtocolSAML || u.Source == entities.SSOProtocolOIDC {
+		return true, nil
+	}
+
+	conns, err := storage.GetEnforcedSSOConnections(u.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, conn := range conns {
+		if conn.MatchesEmail(u.Username) {
+			return true, nil
+		}
+	}
+
+	return false, nil
 }
 
 func sendVerifyEmail(
+	ctx context.Context,
 	storage storage.Storage,
 	sender emails.Sender,
 	u *entities.User,
@@ -656,24 +738,11 @@ func sendVerifyEmail(
 		return fmt.Errorf("send verify email: exec template: %w", err)
 	}
 
-	charset := aws.String("UTF-8")
-	_, err = sender.SendEmail(&ses.SendEmailInput{
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Html: &ses.Content{
-					Charset: charset,
-					Data:    aws.String(html.String()),
-				},
-			},
-			Subject: &ses.Content{
-				Charset: charset,
-				Data:    aws.String(string("Verify your email address")),
-			},
-		},
-		Source: aws.String(fmt.Sprintf("%s <%s>", "Mailbadger.io", systemEmailSource)),
-		Destination: &ses.Destination{
-			ToAddresses: []*string{aws.String(u.Username)},
-		},
+	_, err = sender.Send(ctx, &emails.Message{
+		From:    fmt.Sprintf("%s <%s>", "Mailbadger.io", systemEmailSource),
+		To:      []string{u.Username},
+		Subject: "Verify your email address",
+		HTML:    html.Bytes(),
 	})
 	if err != nil {
 		return fmt.Errorf("send verify email: %w", err)
@@ -0,0 +1,382 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// GetAutomations returns the automations of the user along with their steps.
+func GetAutomations(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, err := storage.GetAutomations(middleware.GetUser(c).ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch automations.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch automations. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, a)
+	}
+}
+
+// GetAutomation returns the automation by the given id.
+func GetAutomation(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, ok := automationFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, a)
+	}
+}
+
+// PostAutomation creates a new automation, the automation is created as a draft when the status is not provided.
+func PostAutomation(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		body := &params.PostAutomation{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		a := &entities.Automation{
+			UserID:             u.ID,
+			Name:               body.Name,
+			Status:             body.Status,
+			TriggerType:        body.TriggerType,
+			TriggerLink:        body.TriggerLink,
+			TriggerMetadataKey: body.TriggerMetadataKey,
+		}
+		if a.Status == "" {
+			a.Status = entities.AutomationStatusDraft
+		}
+
+		if !setAutomationTrigger(c, storage, a, body.TriggerSegmentID, body.TriggerCampaignID) {
+			return
+		}
+
+		steps, ok := automationSteps(c, storage, u.ID, body.Steps)
+		if !ok {
+			return
+		}
+		a.Steps = steps
+
+		err := storage.CreateAutomation(a)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create automation.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create automation. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, a)
+	}
+}
+
+// PutAutomation updates the automation and replaces its steps.
+func PutAutomation(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, ok := automationFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		body := &params.PutAutomation{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		a.Name = body.Name
+		a.Status = body.Status
+		a.TriggerType = body.TriggerType
+		a.TriggerLink = body.TriggerLink
+		a.TriggerMetadataKey = body.TriggerMetadataKey
+
+		if !setAutomationTrigger(c, storage, a, body.TriggerSegmentID, body.TriggerCampaignID) {
+			return
+		}
+
+		steps, ok := automationSteps(c, storage, a.UserID, body.Steps)
+		if !ok {
+			return
+		}
+		a.Steps = steps
+
+		err := storage.UpdateAutomation(a)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to update automation.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update automation. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, a)
+	}
+}
+
+// DeleteAutomation deletes the automation along with the progress of its subscribers.
+func DeleteAutomation(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a, ok := automationFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		err := storage.DeleteAutomation(a.ID, a.UserID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete automation.")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to delete automation.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetAutomationRuns returns a paginated list of the subscribers which went through the automation.
+func GetAutomationRuns(store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		val, ok := c.Get("cursor")
+		if !ok {
+			logger.From(c).Error("get automation runs: unable to fetch pagination cursor from context")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch automation runs. Please try again.",
+			})
+			return
+		}
+
+		p, ok := val.(*storage.PaginationCursor)
+		if !ok {
+			logger.From(c).Error("get automation runs: unable to cast pagination cursor from context value")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch automation runs. Please try again.",
+			})
+			return
+		}
+
+		a, ok := automationFromParam(c, store)
+		if !ok {
+			return
+		}
+
+		err := store.GetAutomationRuns(a.ID, a.UserID, p)
+		if err != nil {
+			logger.From(c).WithError(err).Error("get automation runs: unable to fetch runs")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch automation runs. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// GetAutomationLogs returns a paginated list of the emails sent by the automation.
+func GetAutomationLogs(store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		val, ok := c.Get("cursor")
+		if !ok {
+			logger.From(c).Error("get automation logs: unable to fetch pagination cursor from context")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch automation logs. Please try again.",
+			})
+			return
+		}
+
+		p, ok := val.(*storage.PaginationCursor)
+		if !ok {
+			logger.From(c).Error("get automation logs: unable to cast pagination cursor from context value")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch automation logs. Please try again.",
+			})
+			return
+		}
+
+		a, ok := automationFromParam(c, store)
+		if !ok {
+			return
+		}
+
+		err := store.GetAutomationLogs(a.ID, a.UserID, p)
+		if err != nil {
+			logger.From(c).WithError(err).Error("get automation logs: unable to fetch logs")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch automation logs. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+func automationFromParam(c *gin.Context, storage storage.Storage) (*entities.Automation, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	a, err := storage.GetAutomation(id, middleware.GetUser(c).ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Automation not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch automation.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch automation. Please try again.",
+		})
+		return nil, false
+	}
+
+	return a, true
+}
+
+// setAutomationTrigger sets the segment or the campaign of the trigger, after it checks that they belong to the user.
+func setAutomationTrigger(c *gin.Context, storage storage.Storage, a *entities.Automation, segmentID, campaignID int64) bool {
+	a.TriggerSegmentID = nil
+	a.TriggerCampaignID = nil
+
+	switch a.TriggerType {
+	case entities.AutomationTriggerSegmentJoined:
+		_, err := storage.GetSegment(segmentID, a.UserID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Segment not found.",
+			})
+			return false
+		}
+		a.TriggerSegmentID = &segmentID
+	case entities.AutomationTriggerLinkClicked:
+		_, err := storage.GetCampaign(campaignID, a.UserID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found.",
+			})
+			return false
+		}
+		a.TriggerCampaignID = &campaignID
+	}
+
+	return true
+}
+
+// automationSteps maps the steps of the request body, the segments and templates used by the
+// steps must belong to the user and the template data must cover the tags of the templates.
+func automationSteps(c *gin.Context, storage storage.Storage, userID int64, body []params.AutomationStep) ([]entities.AutomationStep, bool) {
+	steps := make([]entities.AutomationStep, len(body))
+	for i, p := range body {
+		s := entities.AutomationStep{
+			Position: i,
+			Type:     p.Type,
+		}
+
+		switch p.Type {
+		case entities.AutomationStepDelay:
+			s.DelayMinutes = p.DelayMinutes
+		case entities.AutomationStepCondition:
+			s.ConditionType = p.ConditionType
+			if p.ConditionType == entities.AutomationConditionMetadataEquals {
+				s.ConditionKey = p.ConditionKey
+				s.ConditionValue = p.ConditionValue
+				break
+			}
+
+			segmentID := p.ConditionSegmentID
+			_, err := storage.GetSegment(segmentID, userID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": fmt.Sprintf("Segment of step %d not found.", i+1),
+				})
+				return nil, false
+			}
+			s.ConditionSegmentID = &segmentID
+		case entities.AutomationStepSendTemplate:
+			templateID := p.TemplateID
+			t, err := storage.GetTemplate(templateID, userID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": fmt.Sprintf("Template of step %d not found.", i+1),
+				})
+				return nil, false
+			}
+
+			err = t.ValidateData(p.TemplateData)
+			if err != nil {
+				if errors.Is(err, entities.ErrMissingDefaultData) {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"message": fmt.Sprintf("Incomplete template data of step %d.", i+1),
+					})
+					return nil, false
+				}
+				logger.From(c).WithField("template_id", templateID).WithError(err).Error("Unable to parse template.")
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"message": fmt.Sprintf("Failed to parse the template of step %d.", i+1),
+				})
+				return nil, false
+			}
+
+			data, err := json.Marshal(p.TemplateData)
+			if err != nil {
+				logger.From(c).WithError(err).Error("Unable to encode template data.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to save automation. Please try again.",
+				})
+				return nil, false
+			}
+
+			s.TemplateID = &templateID
+			s.Source = p.Source
+			s.FromName = p.FromName
+			s.TemplateData = data
+		}
+
+		steps[i] = s
+	}
+
+	return steps, true
+}
@@ -0,0 +1,195 @@
+package actions_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestAutomations(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	uuid := auth.GET("/api/users/me").Expect().Status(http.StatusOK).JSON().Object().Value("uuid").String().Raw()
+	u, err := s.GetUserByUUID(uuid)
+	assert.Nil(t, err)
+
+	tmpl := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:      u.ID,
+			Name:        "welcome",
+			SubjectPart: "Welcome {{first_name}}",
+		},
+		TextPart: "Welcome aboard",
+	}
+	err = s.CreateTemplate(tmpl)
+	assert.Nil(t, err)
+
+	seg := &entities.Segment{UserID: u.ID, Name: "customers"}
+	err = s.CreateSegment(seg)
+	assert.Nil(t, err)
+
+	send := params.AutomationStep{
+		Type:       entities.AutomationStepSendTemplate,
+		TemplateID: tmpl.ID,
+		Source:     "hello@example.com",
+		FromName:   "Example",
+	}
+	body := params.PostAutomation{
+		Name:        "welcome series",
+		TriggerType: entities.AutomationTriggerSubscriberCreated,
+		Steps: []params.AutomationStep{
+			send,
+			{Type: entities.AutomationStepDelay, DelayMinutes: 60 * 24},
+			{Type: entities.AutomationStepCondition, ConditionType: entities.AutomationConditionNotInSegment, ConditionSegmentID: seg.ID},
+		},
+	}
+
+	e.GET("/api/automations").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.POST("/api/automations").WithJSON(params.PostAutomation{
+		Name:        "no steps",
+		TriggerType: entities.AutomationTriggerSegmentJoined,
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Invalid parameters, please try again").
+		Value("errors").Object().
+		ContainsKey("trigger_segment_id").
+		ContainsKey("steps")
+
+	auth.POST("/api/automations").WithJSON(params.PostAutomation{
+		Name:             "unknown segment",
+		TriggerType:      entities.AutomationTriggerSegmentJoined,
+		TriggerSegmentID: seg.ID + 1,
+		Steps:            body.Steps,
+	}).
+		Expect().
+		Status(http.StatusNotFound).JSON().Object().
+		ValueEqual("message", "Segment not found.")
+
+	// the template data must cover the tags of the template
+	auth.POST("/api/automations").WithJSON(body).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Incomplete template data of step 1.")
+
+	body.Steps[0].TemplateData = map[string]string{"first_name": "friend"}
+	a := auth.POST("/api/automations").WithJSON(body).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("name", "welcome series").
+		ValueEqual("status", entities.AutomationStatusDraft)
+	a.Value("steps").Array().Length().Equal(3)
+	a.Value("steps").Array().Element(0).Object().
+		ValueEqual("template_id", tmpl.ID).
+		ValueEqual("template_data", map[string]string{"first_name": "friend"})
+
+	auth.GET("/api/automations").
+		Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(1)
+
+	auth.GET("/api/automations/2").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.PUT("/api/automations/1").WithJSON(params.PutAutomation{
+		Name:             "welcome customers",
+		Status:           entities.AutomationStatusActive,
+		TriggerType:      entities.AutomationTriggerSegmentJoined,
+		TriggerSegmentID: seg.ID,
+		Steps:            body.Steps[:1],
+	}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", entities.AutomationStatusActive).
+		ValueEqual("trigger_segment_id", seg.ID).
+		Value("steps").Array().Length().Equal(1)
+
+	// adding the subscriber to the segment enrolls it in the automation
+	auth.POST("/api/subscribers").WithJSON(params.PostSubscriber{Email: "jane@example.com", SegmentIDs: []int64{seg.ID}}).
+		Expect().
+		Status(http.StatusCreated)
+
+	auth.GET("/api/automations/1/runs").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("total", 1).
+		Value("collection").Array().Element(0).Object().
+		ValueEqual("status", entities.AutomationRunStatusActive).
+		ValueEqual("position", 0)
+
+	auth.GET("/api/automations/1/logs").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("total", 0)
+
+	auth.DELETE("/api/automations/1").
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/automations/1").
+		Expect().
+		Status(http.StatusNotFound)
+}
@@ -6,13 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/gin-gonic/gin"
 	"github.com/segmentio/ksuid"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 
 	"github.com/mailbadger/app/emails"
 	"github.com/mailbadger/app/entities"
@@ -22,6 +22,7 @@ import (
 	"github.com/mailbadger/app/services/boundaries"
 	"github.com/mailbadger/app/sqs"
 	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/utils"
 	"github.com/mailbadger/app/validator"
 )
 
@@ -29,6 +30,7 @@ func StartCampaign(
 	storage storage.Storage,
 	publisher sqs.PublisherAPI,
 	queueURL sqs.CampaignerQueueURL,
+	maildir *emails.Maildir,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -79,6 +81,8 @@ func StartCampaign(
 			})
 			return
 		}
+		// the campaign is sent with the current version of the template, even if it's edited meanwhile
+		campaign.TemplateVersion = template.Version
 
 		err = template.ValidateData(body.DefaultTemplateData)
 		if err != nil {
@@ -99,11 +103,9 @@ func StartCampaign(
 			return
 		}
 
-		sesKeys, err := storage.GetSesKeys(u.ID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": "Amazon Ses keys are not set.",
-			})
+		// the campaign is sent with the user's delivery provider, if it's not set we fallback to SES
+		d, ok := deliverySettings(c, storage, maildir, u.ID)
+		if !ok {
 			return
 		}
 
@@ -115,18 +117,36 @@ func StartCampaign(
 			return
 		}
 
-		sender, err := emails.NewSesSenderFromCreds(sesKeys.AccessKey, sesKeys.SecretKey, sesKeys.Region)
-		if err != nil {
-			logger.From(c).WithError(err).Error("send campaign: unable to create SES client")
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": "SES keys are incorrect.",
-			})
-			return
-		}
+		// the variants of the A/B test are sent first, the winner is sent to the remaining subscribers later.
+		var abTestPhase string
+		abTest, err := storage.GetABTest(id, u.ID)
+		if err == nil {
+			abTestPhase = entities.ABTestPhaseTest
+
+			for i, v := range abTest.Variants {
+				if v.TemplateID == nil {
+					continue
+				}
+				t, err := storage.GetTemplate(*v.TemplateID, u.ID)
+				if err != nil || t.ValidateData(body.DefaultTemplateData) != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"message": "Invalid template of variant " + v.Name + ". Unable to send the campaign.",
+					})
+					return
+				}
+				// like the campaign, the variants are sent with the current versions of their templates
+				abTest.Variants[i].TemplateVersion = t.Version
+			}
 
-		_, err = sender.DescribeConfigurationSet(&ses.DescribeConfigurationSetInput{
-			ConfigurationSetName: aws.String(emails.ConfigurationSetName),
-		})
+			err = storage.UpdateVariantTemplateVersions(abTest.Variants)
+			if err != nil {
+				logger.From(c).WithField("campaign_id", id).WithError(err).Error("send campaign: unable to update variant template versions")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to start the campaign, please try again.",
+				})
+				return
+			}
+		}
 
 		msg, err := json.Marshal(entities.CampaignerTopicParams{
 			EventID:                *campaign.EventID, // this id is handled in campaigns SetEventID method
@@ -136,8 +156,11 @@ func StartCampaign(
 			TemplateData:           body.DefaultTemplateData,
 			UserID:                 u.ID,
 			UserUUID:               u.UUID,
-			SesKeys:                *sesKeys,
-			ConfigurationSetExists: err == nil,
+			SesKeys:                d.SesKeys,
+			DeliveryProviderID:     d.ProviderID,
+			ConfigurationSetExists: d.ConfigurationSetExists,
+			ABTestPhase:            abTestPhase,
+			TemplateVersion:        template.Version,
 		})
 		if err != nil {
 			logger.From(c).WithFields(logrus.Fields{
@@ -314,6 +337,8 @@ func PostCampaign(
 			UserID:       user.ID,
 			BaseTemplate: template.GetBase(),
 			Status:       entities.StatusDraft,
+			TrackOpens:   body.TrackOpens,
+			TrackClicks:  body.TrackClicks,
 		}
 
 		err = storage.CreateCampaign(campaign)
@@ -380,6 +405,8 @@ func PutCampaign(storage storage.Storage) gin.HandlerFunc {
 
 		campaign.Name = body.Name
 		campaign.BaseTemplate = template.GetBase()
+		campaign.TrackOpens = body.TrackOpens
+		campaign.TrackClicks = body.TrackClicks
 
 		err = storage.UpdateCampaign(campaign)
 		if err != nil {
@@ -472,6 +499,48 @@ func GetCampaignOpens(store storage.Storage) gin.HandlerFunc {
 	}
 }
 
+// GetCampaignInstances returns a paginated list of the instances sent by the recurring campaign.
+func GetCampaignInstances(store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		val, ok := c.Get("cursor")
+		if !ok {
+			logger.From(c).Error("get campaign instances: unable to fetch pagination cursor from context")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch campaign instances. Please try again.",
+			})
+			return
+		}
+
+		p, ok := val.(*storage.PaginationCursor)
+		if !ok {
+			logger.From(c).Error("get campaign instances: unable to cast pagination cursor from context value")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch campaign instances. Please try again.",
+			})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer",
+			})
+			return
+		}
+
+		err = store.GetCampaignInstances(id, middleware.GetUser(c).ID, p)
+		if err != nil {
+			logger.From(c).WithError(err).Error("get campaign instances: unable to fetch from store")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "We are unable to process the request at the moment, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
 func GetCampaignStats(storage storage.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -531,6 +600,60 @@ func GetCampaignStats(storage storage.Storage) gin.HandlerFunc {
 	}
 }
 
+func GetCampaignProgress(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer",
+			})
+			return
+		}
+		user := middleware.GetUser(c)
+
+		campaign, err := storage.GetCampaign(id, user.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found",
+			})
+			return
+		}
+
+		progress := entities.CampaignProgress{
+			CampaignID: campaign.ID,
+			EventID:    campaign.EventID,
+			Status:     campaign.Status,
+		}
+
+		if campaign.EventID != nil {
+			cp, err := storage.GetCampaignCheckpoint(*campaign.EventID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithField("campaign_id", id).WithError(err).Error("get progress: unable to fetch campaign checkpoint")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to fetch the campaign progress, please try again.",
+				})
+				return
+			}
+			if cp != nil {
+				progress.Enqueued = cp.Enqueued
+				progress.Failed = cp.Failed
+				progress.UpdatedAt = &cp.UpdatedAt
+			}
+
+			progress.Batches, err = storage.GetCampaignBatches(*campaign.EventID, user.ID)
+			if err != nil {
+				logger.From(c).WithField("campaign_id", id).WithError(err).Error("get progress: unable to fetch campaign batches")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to fetch the campaign progress, please try again.",
+				})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
 func GetCampaignClicksStats(storage storage.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -714,12 +837,49 @@ func PatchCampaignSchedule(storage storage.Storage) gin.HandlerFunc {
 			return
 		}
 
-		schAt, err := time.Parse("2006-01-02 15:04:05", body.ScheduledAt)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": "Invalid parameters, scheduled_at should be in this format: 2006-02-01 15:04:05",
-			})
-			return
+		loc := time.UTC
+		if body.Timezone != "" {
+			loc, err = time.LoadLocation(body.Timezone)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Invalid parameters, unknown time zone.",
+				})
+				return
+			}
+		}
+
+		if body.DeliveryMode != "" {
+			_, err = storage.GetABTest(campaign.ID, u.ID)
+			if err == nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Invalid parameters, the delivery mode can't be used with an A/B test.",
+				})
+				return
+			}
+		}
+
+		var recurrence string
+		if body.Recurrence != nil {
+			recurrence = recurrenceExpression(body.Recurrence)
+			_, err = utils.ParseCron(recurrence)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Invalid parameters, the recurrence is not a valid cron expression.",
+				})
+				return
+			}
+		}
+
+		var schAt time.Time
+		if body.ScheduledAt != "" {
+			schAt, err = time.ParseInLocation("2006-01-02 15:04:05", body.ScheduledAt, loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Invalid parameters, scheduled_at should be in this format: 2006-02-01 15:04:05",
+				})
+				return
+			}
+			schAt = schAt.UTC()
 		}
 
 		defMetadata, err := json.Marshal(body.DefaultTemplateData)
@@ -759,6 +919,32 @@ func PatchCampaignSchedule(storage storage.Storage) gin.HandlerFunc {
 			}
 		}
 
+		// the feed is sent from the start when its url is changed
+		if campaign.Schedule.FeedURL != body.FeedURL {
+			campaign.Schedule.FeedLastItemAt = entities.NullTime{}
+			campaign.Schedule.FeedSeenItemsJSON = nil
+		}
+		campaign.Schedule.Recurrence = recurrence
+		campaign.Schedule.Timezone = body.Timezone
+		campaign.Schedule.FeedURL = body.FeedURL
+		campaign.Schedule.DeliveryMode = body.DeliveryMode
+		campaign.Schedule.LocalTime = body.LocalTime
+
+		// the recurring campaign is first sent on the next run after the scheduled time, or from now on
+		if campaign.Schedule.IsRecurring() {
+			from := time.Now().UTC()
+			if schAt.After(from) {
+				from = schAt.Add(-time.Minute)
+			}
+			campaign.Schedule.ScheduledAt, err = campaign.Schedule.NextRun(from)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Invalid parameters, the recurrence has no next run.",
+				})
+				return
+			}
+		}
+
 		err = storage.CreateCampaignSchedule(campaign.Schedule)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
@@ -773,8 +959,301 @@ func PatchCampaignSchedule(storage storage.Storage) gin.HandlerFunc {
 			return
 		}
 
+		if campaign.Schedule.IsRecurring() {
+			c.JSON(http.StatusOK, gin.H{
+				"message": fmt.Sprintf("Campaign %s successfully scheduled, next run at %v", campaign.Name, campaign.Schedule.ScheduledAt.In(loc).Format("2006-01-02 15:04:05")),
+			})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": fmt.Sprintf("Campaign %s successfully scheduled at %v", campaign.Name, body.ScheduledAt),
 		})
 	}
 }
+
+// recurrenceExpression converts the recurrence rule of the schedule to a cron expression.
+func recurrenceExpression(r *params.Recurrence) string {
+	if r.Type == entities.RecurrenceTypeCron {
+		return r.Cron
+	}
+
+	t, _ := time.Parse("15:04", r.Time)
+	if r.Type == entities.RecurrenceTypeMonthly {
+		return fmt.Sprintf("%d %d %d * *", t.Minute(), t.Hour(), r.DayOfMonth)
+	}
+
+	days := make([]string, len(r.Weekdays))
+	for i, d := range r.Weekdays {
+		days[i] = strconv.Itoa(d)
+	}
+	return fmt.Sprintf("%d %d * * %s", t.Minute(), t.Hour(), strings.Join(days, ","))
+}
+
+func PauseCampaign(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		campaign, err := storage.GetCampaign(id, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found.",
+			})
+			return
+		}
+
+		from := []string{entities.StatusSending}
+		pending, err := hasPendingSends(storage, campaign)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("pause campaign: unable to count pending sends")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to pause the campaign, please try again.",
+			})
+			return
+		}
+		if pending {
+			from = append(from, entities.StatusSent)
+		}
+
+		ok, err := storage.UpdateCampaignStatus(id, u.ID, entities.StatusPaused, from...)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("pause campaign: unable to update campaign status")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to pause the campaign, please try again.",
+			})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "Only campaigns which are sending or have pending sends can be paused.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "The campaign is paused.",
+		})
+	}
+}
+
+// hasPendingSends reports whether the messages of a sent campaign are still in the sender queue,
+// the sender logs every message it processes so there are fewer send logs than queued subscribers.
+func hasPendingSends(storage storage.Storage, campaign *entities.Campaign) (bool, error) {
+	if campaign.Status != entities.StatusSent || campaign.EventID == nil {
+		return false, nil
+	}
+
+	cp, err := storage.GetCampaignCheckpoint(*campaign.EventID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	count, err := storage.CountSendLogsByEventID(cp.EventID)
+	if err != nil {
+		return false, err
+	}
+
+	return count < cp.Enqueued+cp.Failed, nil
+}
+
+func ResumeCampaign(
+	storage storage.Storage,
+	publisher sqs.PublisherAPI,
+	queueURL sqs.CampaignerQueueURL,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		campaign, err := storage.GetCampaign(id, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found.",
+			})
+			return
+		}
+
+		if campaign.Status != entities.StatusPaused || campaign.EventID == nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "Only paused campaigns can be resumed.",
+			})
+			return
+		}
+
+		// the campaign was paused after all subscribers were queued, only the sender
+		// was holding back its messages so there is nothing to hand over to the campaigner.
+		if campaign.CompletedAt.Valid {
+			ok, err := storage.UpdateCampaignStatus(id, u.ID, entities.StatusSent, entities.StatusPaused)
+			if err != nil {
+				logger.From(c).WithField("campaign_id", id).WithError(err).Error("resume campaign: unable to update campaign status")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to resume the campaign, please try again.",
+				})
+				return
+			}
+			if !ok {
+				c.JSON(http.StatusConflict, gin.H{
+					"message": "The campaign is not paused anymore.",
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "The campaign is resumed.",
+			})
+			return
+		}
+
+		// the checkpoint is saved by the campaigner once it stops processing the paused campaign.
+		cp, err := storage.GetCampaignCheckpoint(*campaign.EventID)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "The campaign is still being paused, please try again.",
+			})
+			return
+		}
+
+		// the status is updated first, so the campaign can be resumed only once
+		ok, err := storage.UpdateCampaignStatus(id, u.ID, entities.StatusSending, entities.StatusPaused)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("resume campaign: unable to update campaign status")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to resume the campaign, please try again.",
+			})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "The campaign is not paused anymore.",
+			})
+			return
+		}
+
+		// the subscribers of the local time delivery are already grouped in batches,
+		// the scheduler continues releasing them once the campaign is sending again.
+		if cp.Batched {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "The campaign is resumed.",
+			})
+			return
+		}
+
+		// rollback pauses the campaign again when it can't be handed over to the campaigner,
+		// so it can be resumed later.
+		rollback := func(pauseCheckpoint bool) {
+			if pauseCheckpoint {
+				err := storage.PauseCampaignCheckpoint(cp.EventID)
+				if err != nil {
+					logger.From(c).WithField("campaign_id", id).WithError(err).Error("resume campaign: unable to pause checkpoint")
+				}
+			}
+			_, err := storage.UpdateCampaignStatus(id, u.ID, entities.StatusPaused, entities.StatusSending)
+			if err != nil {
+				logger.From(c).WithField("campaign_id", id).WithError(err).Error("resume campaign: unable to pause campaign")
+			}
+		}
+
+		ok, err = storage.ResumeCampaignCheckpoint(cp.EventID)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("resume campaign: unable to update checkpoint")
+			rollback(false)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to resume the campaign, please try again.",
+			})
+			return
+		}
+		if !ok {
+			rollback(false)
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "The campaign is still being paused, please try again.",
+			})
+			return
+		}
+
+		err = publisher.SendMessage(c, queueURL, cp.Params)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("resume campaign: unable to queue campaign for sending")
+			rollback(true)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to resume the campaign, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "The campaign is resumed.",
+		})
+	}
+}
+
+func CancelCampaign(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		campaign, err := storage.GetCampaign(id, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found.",
+			})
+			return
+		}
+
+		from := []string{entities.StatusSending, entities.StatusPaused, entities.StatusTesting}
+		pending, err := hasPendingSends(storage, campaign)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("cancel campaign: unable to count pending sends")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to cancel the campaign, please try again.",
+			})
+			return
+		}
+		if pending {
+			from = append(from, entities.StatusSent)
+		}
+
+		ok, err := storage.UpdateCampaignStatus(id, u.ID, entities.StatusCancelled, from...)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", id).WithError(err).Error("cancel campaign: unable to update campaign status")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to cancel the campaign, please try again.",
+			})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "Only campaigns which are sending, paused, testing or have pending sends can be cancelled.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "The campaign is cancelled.",
+		})
+	}
+}
@@ -1,15 +1,20 @@
 package actions_test
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
 	"github.com/mailbadger/app/config"
 	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/opa"
 	"github.com/mailbadger/app/services/boundaries"
@@ -167,12 +172,12 @@ func TestCampaigns(t *testing.T) {
 	}).Expect().
 		Status(http.StatusOK)*/
 
-	// test without ses keys
+	// the ses keys are not required in the local delivery mode
 	auth.POST("/api/campaigns/"+idStr+"/start").WithJSON(params.StartCampaign{FromName: "gl", Source: "gudgl@example.com", SegmentIDs: []int64{1}}).
 		Expect().
 		Status(http.StatusNotFound).
 		JSON().Object().
-		ValueEqual("message", "Amazon Ses keys are not set.")
+		ValueEqual("message", "Subscriber lists are not found.")
 
 	// successful patch campaign schedule.
 	auth.PATCH("/api/campaigns/1/schedule").WithJSON(params.CampaignSchedule{
@@ -197,6 +202,313 @@ func TestCampaigns(t *testing.T) {
 			"source":       "This field is required",
 		})
 
+	// recurring campaign schedule with an invalid rule.
+	auth.PATCH("/api/campaigns/1/schedule").WithJSON(params.CampaignSchedule{
+		FromName:   "gl",
+		Source:     "gudgl@example.com",
+		SegmentIDs: []int64{1},
+		Recurrence: &params.Recurrence{Type: entities.RecurrenceTypeCron, Cron: "0 25 * * *"},
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Invalid parameters, the recurrence is not a valid cron expression.")
+
+	// the feed can only be sent by a recurring schedule.
+	auth.PATCH("/api/campaigns/1/schedule").WithJSON(params.CampaignSchedule{
+		FromName:    "gl",
+		Source:      "gudgl@example.com",
+		SegmentIDs:  []int64{1},
+		ScheduledAt: "2020-04-04 15:04:03",
+		FeedURL:     "https://example.com/feed.xml",
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"recurrence": "This field is required",
+		})
+
+	// successful patch of a weekly rss campaign schedule.
+	auth.PATCH("/api/campaigns/1/schedule").WithJSON(params.CampaignSchedule{
+		FromName:   "gl",
+		Source:     "gudgl@example.com",
+		SegmentIDs: []int64{1},
+		Recurrence: &params.Recurrence{Type: entities.RecurrenceTypeWeekly, Weekdays: []int{1, 4}, Time: "09:30"},
+		Timezone:   "Europe/Skopje",
+		FeedURL:    "https://example.com/feed.xml",
+	}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("message").String().Contains("successfully scheduled, next run at")
+
+	auth.GET("/api/campaigns/1").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("schedule").Object().
+		ValueEqual("recurrence", "30 9 * * 1,4").
+		ValueEqual("timezone", "Europe/Skopje").
+		ValueEqual("feed_url", "https://example.com/feed.xml")
+
+	// the local time delivery requires the time of the day.
+	auth.PATCH("/api/campaigns/1/schedule").WithJSON(params.CampaignSchedule{
+		FromName:     "gl",
+		Source:       "gudgl@example.com",
+		SegmentIDs:   []int64{1},
+		ScheduledAt:  "2020-04-04 08:00:00",
+		DeliveryMode: entities.DeliveryModeLocalTime,
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"local_time": "This field is required",
+		})
+
+	auth.PATCH("/api/campaigns/1/schedule").WithJSON(params.CampaignSchedule{
+		FromName:     "gl",
+		Source:       "gudgl@example.com",
+		SegmentIDs:   []int64{1},
+		ScheduledAt:  "2020-04-04 08:00:00",
+		DeliveryMode: entities.DeliveryModeLocalTime,
+		LocalTime:    "09:00",
+	}).
+		Expect().
+		Status(http.StatusOK)
+
+	auth.GET("/api/campaigns/1").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("schedule").Object().
+		ValueEqual("delivery_mode", entities.DeliveryModeLocalTime).
+		ValueEqual("local_time", "09:00").
+		ValueEqual("recurrence", "")
+
+	auth.GET("/api/campaigns/1/instances").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("total", 0)
+
+	// captured messages in the local maildir
+	auth.GET("/api/campaigns/" + idStr + "/captured").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().Empty()
+
+	auth.GET("/api/campaigns/"+idStr+"/captured/foo").
+		Expect().
+		Status(http.StatusNotFound).JSON().Object().
+		ValueEqual("message", "Message not found")
+
+	auth.GET("/api/campaigns/2223/captured").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// pause, resume and cancel
+	auth.POST("/api/campaigns/"+idStr+"/pause").
+		Expect().
+		Status(http.StatusForbidden).JSON().Object().
+		ValueEqual("message", "Only campaigns which are sending or have pending sends can be paused.")
+
+	auth.POST("/api/campaigns/"+idStr+"/resume").
+		Expect().
+		Status(http.StatusForbidden).JSON().Object().
+		ValueEqual("message", "Only paused campaigns can be resumed.")
+
+	u, err := s.GetUserByUsername("john")
+	assert.Nil(t, err)
+	campaignID, err := strconv.ParseInt(idStr, 10, 64)
+	assert.Nil(t, err)
+	campaign, err := s.GetCampaign(campaignID, u.ID)
+	assert.Nil(t, err)
+	campaign.Status = entities.StatusSending
+	campaign.SetEventID()
+	assert.Nil(t, s.UpdateCampaign(campaign))
+
+	auth.GET("/api/campaigns/"+idStr+"/progress").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", entities.StatusSending).
+		ValueEqual("enqueued", 0).
+		ValueEqual("updated_at", nil)
+
+	auth.POST("/api/campaigns/"+idStr+"/pause").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message", "The campaign is paused.")
+
+	auth.POST("/api/campaigns/"+idStr+"/resume").
+		Expect().
+		Status(http.StatusConflict).JSON().Object().
+		ValueEqual("message", "The campaign is still being paused, please try again.")
+
+	err = s.SaveCampaignCheckpoint(&entities.CampaignCheckpoint{
+		EventID:    *campaign.EventID,
+		UserID:     u.ID,
+		CampaignID: campaignID,
+		Paused:     true,
+		Enqueued:   1000,
+		Failed:     2,
+		Params:     []byte(`{"campaign_id":1}`),
+	})
+	assert.Nil(t, err)
+
+	auth.GET("/api/campaigns/"+idStr+"/progress").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", entities.StatusPaused).
+		ValueEqual("enqueued", 1000).
+		ValueEqual("failed", 2)
+
+	auth.GET("/api/campaigns/2223/progress").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// the campaign stays paused when it can't be queued
+	mockPub.On("SendMessage", mock.Anything, mock.Anything, []byte(`{"campaign_id":1}`)).Once().Return(errors.New("queue is unavailable"))
+
+	auth.POST("/api/campaigns/" + idStr + "/resume").
+		Expect().
+		Status(http.StatusInternalServerError)
+
+	auth.GET("/api/campaigns/"+idStr).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", entities.StatusPaused)
+
+	cp, err := s.GetCampaignCheckpoint(*campaign.EventID)
+	assert.Nil(t, err)
+	assert.True(t, cp.Paused)
+
+	mockPub.On("SendMessage", mock.Anything, mock.Anything, []byte(`{"campaign_id":1}`)).Once().Return(nil)
+
+	auth.POST("/api/campaigns/"+idStr+"/resume").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message", "The campaign is resumed.")
+
+	auth.GET("/api/campaigns/"+idStr).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", entities.StatusSending)
+
+	// the sent campaign can be paused and cancelled while its messages are still in the sender queue.
+	ok, err := s.UpdateCampaignStatus(campaignID, u.ID, entities.StatusSent, entities.StatusSending)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	auth.POST("/api/campaigns/"+idStr+"/pause").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message", "The campaign is paused.")
+
+	auth.GET("/api/campaigns/"+idStr).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", entities.StatusPaused)
+
+	auth.POST("/api/campaigns/"+idStr+"/resume").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message", "The campaign is resumed.")
+
+	auth.GET("/api/campaigns/"+idStr).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", entities.StatusSent)
+
+	auth.POST("/api/campaigns/"+idStr+"/cancel").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message", "The campaign is cancelled.")
+
+	ok, err = s.UpdateCampaignStatus(campaignID, u.ID, entities.StatusSent, entities.StatusCancelled)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// once the sender has processed all messages the sent campaign can't be paused or cancelled.
+	err = s.SaveCampaignCheckpoint(&entities.CampaignCheckpoint{
+		EventID:    *campaign.EventID,
+		UserID:     u.ID,
+		CampaignID: campaignID,
+		Enqueued:   1,
+		Params:     []byte(`{"campaign_id":1}`),
+	})
+	assert.Nil(t, err)
+	err = s.CreateSendLog(&entities.SendLog{
+		ID:           ksuid.New(),
+		EventID:      *campaign.EventID,
+		UserID:       u.ID,
+		CampaignID:   campaignID,
+		SubscriberID: 1,
+		Status:       entities.SendLogStatusSuccessful,
+		Description:  entities.SendLogDescriptionOnSuccessful,
+	})
+	assert.Nil(t, err)
+
+	auth.POST("/api/campaigns/"+idStr+"/pause").
+		Expect().
+		Status(http.StatusForbidden).JSON().Object().
+		ValueEqual("message", "Only campaigns which are sending or have pending sends can be paused.")
+
+	auth.POST("/api/campaigns/"+idStr+"/cancel").
+		Expect().
+		Status(http.StatusForbidden).JSON().Object().
+		ValueEqual("message", "Only campaigns which are sending, paused, testing or have pending sends can be cancelled.")
+
+	campaign, err = s.GetCampaign(campaignID, u.ID)
+	assert.Nil(t, err)
+	campaign.Status = entities.StatusSending
+	campaign.CompletedAt = entities.NullTime{}
+	assert.Nil(t, s.UpdateCampaign(campaign))
+
+	// the campaign whose subscribers are grouped in batches is resumed without queueing it again.
+	err = s.SaveCampaignCheckpoint(&entities.CampaignCheckpoint{
+		EventID:    *campaign.EventID,
+		UserID:     u.ID,
+		CampaignID: campaignID,
+		Batched:    true,
+		Params:     []byte(`{"campaign_id":1}`),
+	})
+	assert.Nil(t, err)
+	err = s.CreateCampaignBatches([]entities.CampaignBatch{{
+		UserID:     u.ID,
+		CampaignID: campaignID,
+		EventID:    *campaign.EventID,
+		Timezone:   "Asia/Tokyo",
+		ReleaseAt:  time.Now().UTC().Add(time.Hour),
+		Status:     entities.CampaignBatchStatusPending,
+		Size:       1,
+	}})
+	assert.Nil(t, err)
+
+	auth.POST("/api/campaigns/" + idStr + "/pause").
+		Expect().
+		Status(http.StatusOK)
+
+	auth.POST("/api/campaigns/"+idStr+"/resume").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message", "The campaign is resumed.")
+
+	progress := auth.GET("/api/campaigns/" + idStr + "/progress").
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+	progress.ValueEqual("status", entities.StatusSending)
+	progress.Value("batches").Array().Length().Equal(1)
+	progress.Value("batches").Array().Element(0).Object().
+		ValueEqual("timezone", "Asia/Tokyo").
+		ValueEqual("status", entities.CampaignBatchStatusPending)
+
+	auth.POST("/api/campaigns/"+idStr+"/cancel").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message", "The campaign is cancelled.")
+
+	auth.POST("/api/campaigns/"+idStr+"/cancel").
+		Expect().
+		Status(http.StatusForbidden).JSON().Object().
+		ValueEqual("message", "Only campaigns which are sending, paused, testing or have pending sends can be cancelled.")
+
+	mockPub.AssertExpectations(t)
+
 	// delete campaign by id
 	auth.DELETE("/api/campaigns/" + idStr).
 		Expect().
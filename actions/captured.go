@@ -0,0 +1,107 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+)
+
+// GetCapturedMessages lists the messages of the campaign captured in the local
+// maildir. The endpoint is available only when the local delivery mode is enabled.
+func GetCapturedMessages(store storage.Storage, maildir *emails.Maildir) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maildir == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Local delivery mode is not enabled.",
+			})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer",
+			})
+			return
+		}
+
+		u := middleware.GetUser(c)
+
+		_, err = store.GetCampaign(id, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found",
+			})
+			return
+		}
+
+		msgs, err := maildir.List(u.UUID, id)
+		if err != nil {
+			logger.From(c).WithError(err).Error("get captured messages: unable to list messages")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "We are unable to process the request at the moment, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": msgs,
+		})
+	}
+}
+
+// GetCapturedMessage returns the captured message with its html and text parts
+// so it can be previewed.
+func GetCapturedMessage(store storage.Storage, maildir *emails.Maildir) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maildir == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Local delivery mode is not enabled.",
+			})
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer",
+			})
+			return
+		}
+
+		u := middleware.GetUser(c)
+
+		_, err = store.GetCampaign(id, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found",
+			})
+			return
+		}
+
+		msg, err := maildir.Get(u.UUID, id, c.Param("message_id"))
+		if err != nil {
+			if errors.Is(err, emails.ErrMessageNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "Message not found",
+				})
+				return
+			}
+
+			logger.From(c).WithError(err).Error("get captured message: unable to read message")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "We are unable to process the request at the moment, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, msg)
+	}
+}
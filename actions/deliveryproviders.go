@@ -0,0 +1,182 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+func GetDeliveryProvider(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		p, err := storage.GetDeliveryProvider(u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Delivery provider not set.",
+			})
+			return
+		}
+
+		p.ClearSecrets() //do not return the secrets
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+func PutDeliveryProvider(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		body := &params.PutDeliveryProvider{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			logger.From(c).WithError(err).Error("Unable to bind delivery provider params.")
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again",
+			})
+			return
+		}
+
+		p, err := storage.GetDeliveryProvider(u.ID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithError(err).Error("Unable to fetch delivery provider.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to save the delivery provider, please try again.",
+				})
+				return
+			}
+			p = &entities.DeliveryProvider{UserID: u.ID}
+		}
+
+		// the secrets are never returned to the client, so they are kept
+		// when the settings of the same provider are edited without them
+		if p.Type == body.Type {
+			if body.Password == "" {
+				body.Password = p.Password
+			}
+			if body.APIKey == "" {
+				body.APIKey = p.APIKey
+			}
+		}
+
+		if err := validator.Validate(body); err != nil {
+			logger.From(c).WithError(err).Error("Invalid delivery provider params.")
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		p.Type = body.Type
+		p.Host = body.Host
+		p.Port = body.Port
+		p.Username = body.Username
+		p.Password = body.Password
+		p.APIKey = body.APIKey
+		p.Domain = body.Domain
+		p.Region = body.Region
+		p.MessageStream = body.MessageStream
+
+		err = storage.SaveDeliveryProvider(p)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to save delivery provider.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to save the delivery provider, please try again.",
+			})
+			return
+		}
+
+		p.ClearSecrets()
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+func DeleteDeliveryProvider(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		err := storage.DeleteDeliveryProvider(u.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete delivery provider.")
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to delete the delivery provider.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// delivery holds the settings the emails of the user are sent with.
+type delivery struct {
+	ProviderID             int64
+	SesKeys                entities.SesKeys
+	ConfigurationSetExists bool
+}
+
+// deliverySettings returns the delivery settings of the user, the user's delivery provider is used
+// and if it's not set we fallback to SES. In the local delivery mode the emails are written to the
+// maildir, so no settings are needed. The error response is written when the settings can't be used.
+func deliverySettings(c *gin.Context, storage storage.Storage, maildir *emails.Maildir, userID int64) (*delivery, bool) {
+	if maildir != nil {
+		return &delivery{}, true
+	}
+
+	provider, err := storage.GetDeliveryProvider(userID)
+	if err == nil {
+		return &delivery{ProviderID: provider.ID}, true
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.From(c).WithError(err).Error("Unable to fetch delivery provider.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "We are unable to process the request, please try again.",
+		})
+		return nil, false
+	}
+
+	sesKeys, err := storage.GetSesKeys(userID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.From(c).WithError(err).Error("Unable to fetch ses keys.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "We are unable to process the request, please try again.",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Amazon Ses keys are not set.",
+		})
+		return nil, false
+	}
+
+	sender, err := emails.NewSesSenderFromCreds(sesKeys.AccessKey, sesKeys.SecretKey, sesKeys.Region)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to create SES client.")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "SES keys are incorrect.",
+		})
+		return nil, false
+	}
+
+	_, err = sender.DescribeConfigurationSet(&ses.DescribeConfigurationSetInput{
+		ConfigurationSetName: aws.String(emails.ConfigurationSetName),
+	})
+
+	return &delivery{
+		SesKeys:                *sesKeys,
+		ConfigurationSetExists: err == nil,
+	}, true
+}
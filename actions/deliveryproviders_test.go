@@ -0,0 +1,165 @@
+package actions_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeliveryProvider(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Twice().Return(&s3.PutObjectAclOutput{}, nil)
+
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e.GET("/api/delivery-provider").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.GET("/api/delivery-provider").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.PUT("/api/delivery-provider").WithJSON(params.PutDeliveryProvider{Type: "foo"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Invalid parameters, please try again").
+		ValueEqual("errors", map[string]string{
+			"type":    "Must be one of: smtp mailgun postmark sendgrid",
+			"api_key": "This field is required",
+		})
+
+	auth.PUT("/api/delivery-provider").WithJSON(params.PutDeliveryProvider{Type: "smtp"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"host": "This field is required",
+			"port": "This field is required",
+		})
+
+	auth.PUT("/api/delivery-provider").WithJSON(params.PutDeliveryProvider{
+		Type:     "smtp",
+		Host:     "smtp.example.com",
+		Port:     587,
+		Username: "foo",
+		Password: "bar",
+	}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("type", "smtp").
+		ValueEqual("host", "smtp.example.com").
+		NotContainsKey("password")
+
+	auth.PUT("/api/delivery-provider").WithJSON(params.PutDeliveryProvider{Type: "mailgun", APIKey: "key"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{"domain": "This field is required"})
+
+	auth.PUT("/api/delivery-provider").WithJSON(params.PutDeliveryProvider{
+		Type:   "mailgun",
+		APIKey: "key",
+		Domain: "example.com",
+		Region: "eu",
+	}).
+		Expect().
+		Status(http.StatusOK)
+
+	auth.GET("/api/delivery-provider").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("type", "mailgun").
+		ValueEqual("domain", "example.com").
+		NotContainsKey("api_key").
+		NotContainsKey("host")
+
+	// the api key is kept when the provider is edited without it
+	auth.PUT("/api/delivery-provider").WithJSON(params.PutDeliveryProvider{
+		Type:   "mailgun",
+		Domain: "mg.example.com",
+	}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("domain", "mg.example.com")
+
+	john, err := s.GetUserByUsername("john")
+	assert.Nil(t, err)
+	p, err := s.GetDeliveryProvider(john.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "key", p.APIKey)
+
+	// the secrets of a different provider are not reused
+	auth.PUT("/api/delivery-provider").WithJSON(params.PutDeliveryProvider{
+		Type: "postmark",
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{"api_key": "This field is required"})
+
+	auth.DELETE("/api/delivery-provider").
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/delivery-provider").
+		Expect().
+		Status(http.StatusNotFound)
+}
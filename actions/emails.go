@@ -1,9 +1,363 @@
 package actions
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/lint"
+	templatesvc "github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
 )
 
-func PostSendTest(c *gin.Context) {
-	//
+// GetCampaignPreview renders the subject, html and text of the campaign. When the subscriber id is
+// given, the email is personalized with the subscriber's name and metadata.
+func GetCampaignPreview(
+	storage storage.Storage,
+	templatesvc templatesvc.Service,
+	unsubscribeSecret string,
+	appURL string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, ok := campaignFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		subscriberID, ok := subscriberIDFromQuery(c)
+		if !ok {
+			return
+		}
+
+		_, preview, ok := renderCampaign(c, storage, templatesvc, campaign, subscriberID, nil, true, unsubscribeSecret, appURL)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, preview)
+	}
+}
+
+// GetCampaignLint renders the campaign and checks it for a missing unsubscribe url, broken links,
+// images without alt text, html which is clipped by Gmail and a missing text part.
+func GetCampaignLint(
+	storage storage.Storage,
+	templatesvc templatesvc.Service,
+	lintsvc lint.Service,
+	unsubscribeSecret string,
+	appURL string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, ok := campaignFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		subscriberID, ok := subscriberIDFromQuery(c)
+		if !ok {
+			return
+		}
+
+		tmpl, preview, ok := renderCampaign(c, storage, templatesvc, campaign, subscriberID, nil, true, unsubscribeSecret, appURL)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, lintsvc.Lint(c, tmpl, preview))
+	}
+}
+
+// PostSendTest renders the campaign and sends it right away to the given recipients, through the
+// user's delivery provider. In the local delivery mode the emails are written to the maildir.
+func PostSendTest(
+	storage storage.Storage,
+	templatesvc templatesvc.Service,
+	maildir *emails.Maildir,
+	unsubscribeSecret string,
+	appURL string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		campaign, ok := campaignFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		body := &params.SendTestCampaign{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		// the test emails are sent to arbitrary recipients, so they must not carry the signed urls of the subscriber
+		_, preview, ok := renderCampaign(c, storage, templatesvc, campaign, body.SubscriberID, body.TemplateData, false, unsubscribeSecret, appURL)
+		if !ok {
+			return
+		}
+
+		var (
+			sender           emails.Sender = maildir
+			configurationSet string
+		)
+		if maildir == nil {
+			provider, err := storage.GetDeliveryProvider(u.ID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithError(err).Error("send test: unable to fetch delivery provider")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to send the test email, please try again.",
+				})
+				return
+			}
+			if err == nil {
+				sender, err = emails.NewSenderFromProvider(*provider)
+				if err != nil {
+					logger.From(c).WithError(err).Error("send test: unable to create sender")
+					c.JSON(http.StatusBadRequest, gin.H{
+						"message": "The delivery provider is not supported.",
+					})
+					return
+				}
+			} else {
+				keys, err := storage.GetSesKeys(u.ID)
+				if err != nil {
+					c.JSON(http.StatusNotFound, gin.H{
+						"message": "Amazon Ses keys are not set.",
+					})
+					return
+				}
+
+				sesSender, err := emails.NewSesSenderFromCreds(keys.AccessKey, keys.SecretKey, keys.Region)
+				if err != nil {
+					logger.From(c).WithError(err).Error("send test: unable to create SES client")
+					c.JSON(http.StatusBadRequest, gin.H{
+						"message": "SES keys are incorrect.",
+					})
+					return
+				}
+
+				_, err = sesSender.DescribeConfigurationSet(&ses.DescribeConfigurationSetInput{
+					ConfigurationSetName: aws.String(emails.ConfigurationSetName),
+				})
+				if err == nil {
+					configurationSet = emails.ConfigurationSetName
+				}
+				sender = sesSender
+			}
+		}
+
+		msg := &emails.Message{
+			From:    fmt.Sprintf("%s <%s>", body.FromName, body.Source),
+			To:      body.Recipients,
+			Subject: "[Test] " + preview.Subject,
+			HTML:    []byte(preview.HTML),
+			Text:    []byte(preview.Text),
+			Tags: map[string]string{
+				"user_id":     u.UUID,
+				"campaign_id": strconv.FormatInt(campaign.ID, 10),
+			},
+			ConfigurationSet: configurationSet,
+		}
+
+		_, err := sender.Send(c, msg)
+		if err != nil {
+			logger.From(c).WithField("campaign_id", campaign.ID).WithError(err).Error("send test: unable to send email")
+			c.JSON(http.StatusBadGateway, gin.H{
+				"message": "Unable to send the test email, please check the delivery provider settings.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("The test email was sent to %d recipient(s).", len(body.Recipients)),
+		})
+	}
+}
+
+func campaignFromParam(c *gin.Context, storage storage.Storage) (*entities.Campaign, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	campaign, err := storage.GetCampaign(id, middleware.GetUser(c).ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Campaign not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch campaign.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch campaign. Please try again.",
+		})
+		return nil, false
+	}
+
+	return campaign, true
+}
+
+func subscriberIDFromQuery(c *gin.Context) (int64, bool) {
+	if c.Query("subscriber_id") == "" {
+		return 0, true
+	}
+
+	id, err := strconv.ParseInt(c.Query("subscriber_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Subscriber id must be an integer.",
+		})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// renderCampaign renders the template of the campaign with the default template data of the schedule
+// and the given data. The metadata of the subscriber takes precedence, as it does when the campaign is sent.
+// Without a subscriber, or when signURLs is false, the unsubscribe and preferences urls point to the
+// pages without a token, so a rendered email which leaves the app can't be used to manage the subscriber.
+func renderCampaign(
+	c *gin.Context,
+	storage storage.Storage,
+	templatesvc templatesvc.Service,
+	campaign *entities.Campaign,
+	subscriberID int64,
+	data map[string]string,
+	signURLs bool,
+	unsubscribeSecret string,
+	appURL string,
+) (*entities.Template, *entities.EmailPreview, bool) {
+	u := middleware.GetUser(c)
+
+	m := map[string]string{
+		entities.TagUnsubscribeUrl: appURL + "/unsubscribe.html",
+		entities.TagPreferencesUrl: appURL + "/preferences.html",
+	}
+
+	if subscriberID != 0 {
+		s, err := storage.GetSubscriber(subscriberID, u.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Subscriber not found.",
+			})
+			return nil, nil, false
+		}
+
+		meta, err := s.GetMetadata()
+		if err != nil {
+			logger.From(c).WithField("subscriber_id", s.ID).WithError(err).Error("render campaign: unable to get subscriber metadata")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Invalid subscriber metadata.",
+			})
+			return nil, nil, false
+		}
+		for k, v := range meta {
+			m[k] = v
+		}
+		if s.Name != "" {
+			m[entities.TagName] = s.Name
+		}
+
+		if signURLs {
+			m[entities.TagUnsubscribeUrl], err = s.GetUnsubscribeURL(u.UUID, unsubscribeSecret, appURL)
+			if err == nil {
+				m[entities.TagPreferencesUrl], err = s.GetPreferencesURL(u.UUID, unsubscribeSecret, appURL)
+			}
+			if err != nil {
+				logger.From(c).WithField("subscriber_id", s.ID).WithError(err).Error("render campaign: unable to sign subscriber urls")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to render the campaign, please try again.",
+				})
+				return nil, nil, false
+			}
+		}
+	}
+
+	var defaults map[string]string
+	if campaign.Schedule != nil {
+		var err error
+		defaults, err = campaign.Schedule.GetMetadata()
+		if err != nil {
+			logger.From(c).WithField("campaign_id", campaign.ID).WithError(err).Error("render campaign: unable to get default template data")
+		}
+	}
+	for _, d := range []map[string]string{data, defaults} {
+		for k, v := range d {
+			if _, ok := m[k]; !ok {
+				m[k] = v
+			}
+		}
+	}
+
+	// a campaign which was already sent is rendered with the template version it was sent with
+	var (
+		tmpl *entities.CampaignTemplateData
+		err  error
+	)
+	if campaign.TemplateVersion != 0 && campaign.Status != entities.StatusDraft && campaign.Status != entities.StatusScheduled {
+		tmpl, err = templatesvc.ParseTemplateVersion(c, campaign.TemplateID, campaign.TemplateVersion, u.ID)
+	} else {
+		tmpl, err = templatesvc.ParseTemplate(c, campaign.TemplateID, u.ID)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Template not found.",
+			})
+			return nil, nil, false
+		}
+		logger.From(c).WithField("template_id", campaign.TemplateID).WithError(err).Error("render campaign: unable to parse template")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Failed to parse template.",
+		})
+		return nil, nil, false
+	}
+
+	var htmlBuf, subBuf, textBuf bytes.Buffer
+	err = tmpl.HTMLPart.FRender(&htmlBuf, m)
+	if err == nil {
+		err = tmpl.SubjectPart.FRender(&subBuf, m)
+	}
+	if err == nil {
+		err = tmpl.TextPart.FRender(&textBuf, m)
+	}
+	if err != nil {
+		logger.From(c).WithField("template_id", campaign.TemplateID).WithError(err).Error("render campaign: unable to render template")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Failed to render template.",
+		})
+		return nil, nil, false
+	}
+
+	return tmpl.Template, &entities.EmailPreview{
+		Subject: subBuf.String(),
+		HTML:    htmlBuf.String(),
+		Text:    textBuf.String(),
+	}, true
 }
@@ -0,0 +1,170 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestCampaignPreviewAndTestSend(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	html := `<p>Hello {{name}}, {{color}}</p><a href="http://127.0.0.1:1/">shop</a><img src="logo.png">`
+	templatesvc := templates.New(s, htmlPartS3{mockS3, html}, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	uuid := auth.GET("/api/users/me").Expect().Status(http.StatusOK).JSON().Object().Value("uuid").String().Raw()
+	u, err := s.GetUserByUUID(uuid)
+	assert.Nil(t, err)
+
+	tmpl := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:      u.ID,
+			Name:        "preview",
+			SubjectPart: "News for {{name}}",
+		},
+	}
+	err = s.CreateTemplate(tmpl)
+	assert.Nil(t, err)
+
+	campaign := &entities.Campaign{
+		UserID:     u.ID,
+		Name:       "preview",
+		TemplateID: tmpl.ID,
+		Status:     entities.StatusDraft,
+	}
+	err = s.CreateCampaign(campaign)
+	assert.Nil(t, err)
+	idStr := strconv.FormatInt(campaign.ID, 10)
+
+	sub := &entities.Subscriber{
+		UserID:   u.ID,
+		Name:     "Jane",
+		Email:    "jane@example.com",
+		MetaJSON: entities.JSON(`{"color":"blue"}`),
+		Active:   true,
+	}
+	err = s.CreateSubscriber(sub)
+	assert.Nil(t, err)
+
+	auth.GET("/api/campaigns/"+idStr+"/preview").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("subject", "News for ")
+
+	auth.GET("/api/campaigns/"+idStr+"/preview").WithQuery("subscriber_id", sub.ID).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("subject", "News for Jane").
+		Value("html").String().Contains("Hello Jane, blue")
+
+	auth.GET("/api/campaigns/"+idStr+"/preview").WithQuery("subscriber_id", 2223).
+		Expect().
+		Status(http.StatusNotFound).JSON().Object().
+		ValueEqual("message", "Subscriber not found.")
+
+	auth.GET("/api/campaigns/2223/preview").
+		Expect().
+		Status(http.StatusNotFound)
+
+	report := auth.GET("/api/campaigns/" + idStr + "/lint").
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+	report.Value("html_size").Number().Gt(0)
+	issues := report.Value("issues").Array()
+	issues.Length().Equal(4)
+	issues.Element(0).Object().
+		ValueEqual("rule", entities.LintRuleMissingUnsubscribeURL).
+		ValueEqual("severity", entities.LintSeverityError)
+	issues.Element(1).Object().ValueEqual("rule", entities.LintRuleMissingTextPart)
+	issues.Element(2).Object().
+		ValueEqual("rule", entities.LintRuleImageMissingAlt).
+		ValueEqual("detail", "logo.png")
+	issues.Element(3).Object().
+		ValueEqual("rule", entities.LintRuleBrokenLink).
+		ValueEqual("detail", "http://127.0.0.1:1/")
+
+	body := params.SendTestCampaign{
+		Recipients: []string{"qa@example.com", "marketing@example.com"},
+		Source:     "news@example.com",
+		FromName:   "Example",
+	}
+
+	auth.POST("/api/campaigns/"+idStr+"/test-send").WithJSON(params.SendTestCampaign{Recipients: []string{"foo"}}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Invalid parameters, please try again")
+
+	auth.POST("/api/campaigns/"+idStr+"/test-send").WithJSON(body).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message", "The test email was sent to 2 recipient(s).")
+
+	body.SubscriberID = sub.ID
+	auth.POST("/api/campaigns/" + idStr + "/test-send").WithJSON(body).
+		Expect().
+		Status(http.StatusOK)
+
+	auth.GET("/api/campaigns/" + idStr + "/captured").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().Length().Equal(2)
+}
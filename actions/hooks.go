@@ -78,6 +78,18 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 			return
 		}
 
+		// transactional emails are tagged with their message id instead of a campaign id
+		if tidTag, ok := msg.Mail.Tags["transactional_id"]; ok && len(tidTag) > 0 {
+			handleTransactionalHook(c, storage, msg, tidTag[0])
+			return
+		}
+
+		// automation emails are not part of the campaign stats, only the bounces and complaints are handled
+		if _, ok := msg.Mail.Tags["automation_id"]; ok {
+			handleAutomationHook(c, storage, msg)
+			return
+		}
+
 		// fetch the campaign id from tags
 		cidTag, ok := msg.Mail.Tags["campaign_id"]
 		if !ok || len(cidTag) == 0 {
@@ -99,6 +111,15 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 			return
 		}
 
+		// the variant id is set when the campaign is sent as an A/B test
+		var variantID *int64
+		if vidTag, ok := msg.Mail.Tags["variant_id"]; ok && len(vidTag) > 0 {
+			vid, err := strconv.ParseInt(vidTag[0], 10, 64)
+			if err == nil {
+				variantID = &vid
+			}
+		}
+
 		uuid := c.Param("uuid")
 		u, err := storage.GetUserByUUID(uuid)
 		if err != nil {
@@ -120,6 +141,7 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 				err := storage.CreateBounce(&entities.Bounce{
 					UserID:         u.ID,
 					CampaignID:     cid,
+					VariantID:      variantID,
 					Recipient:      recipient.EmailAddress,
 					Action:         recipient.Action,
 					Status:         recipient.Status,
@@ -138,7 +160,7 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 					return
 				}
 
-				if msg.Bounce.BounceType == "Permanent" {
+				if msg.Bounce.BounceType == entities.BounceTypePermanent {
 					err = storage.DeactivateSubscriber(u.ID, recipient.EmailAddress)
 					if err != nil {
 						logger.From(c).WithFields(logrus.Fields{
@@ -161,6 +183,7 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 				err := storage.CreateComplaint(&entities.Complaint{
 					UserID:     u.ID,
 					CampaignID: cid,
+					VariantID:  variantID,
 					Recipient:  recipient.EmailAddress,
 					Type:       msg.Complaint.ComplaintFeedbackType,
 					FeedbackID: msg.Complaint.FeedbackID,
@@ -188,6 +211,7 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 				err := storage.CreateDelivery(&entities.Delivery{
 					UserID:               u.ID,
 					CampaignID:           cid,
+					VariantID:            variantID,
 					Recipient:            r,
 					ProcessingTimeMillis: msg.Delivery.ProcessingTimeMillis,
 					ReportingMTA:         msg.Delivery.ReportingMTA,
@@ -210,6 +234,7 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 				err := storage.CreateSend(&entities.Send{
 					UserID:           u.ID,
 					CampaignID:       cid,
+					VariantID:        variantID,
 					MessageID:        msg.Mail.MessageID,
 					Source:           msg.Mail.Source,
 					SendingAccountID: msg.Mail.SendingAccountID,
@@ -237,6 +262,7 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 				err := storage.CreateClick(&entities.Click{
 					UserID:     u.ID,
 					CampaignID: cid,
+					VariantID:  variantID,
 					Recipient:  d,
 					Link:       msg.Click.Link,
 					UserAgent:  msg.Click.UserAgent,
@@ -265,6 +291,7 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 				err := storage.CreateOpen(&entities.Open{
 					UserID:     u.ID,
 					CampaignID: cid,
+					VariantID:  variantID,
 					Recipient:  d,
 					UserAgent:  msg.Open.UserAgent,
 					IPAddress:  msg.Open.IPAddress,
@@ -294,3 +321,181 @@ func HandleHook(storage storage.Storage) gin.HandlerFunc {
 		}
 	}
 }
+
+// handleTransactionalHook records the SES event of a transactional email in its own stream,
+// separately from the events of the campaigns.
+func handleTransactionalHook(c *gin.Context, storage storage.Storage, msg entities.SesMessage, messageID string) {
+	uuid := c.Param("uuid")
+	u, err := storage.GetUserByUUID(uuid)
+	if err != nil {
+		logger.From(c).WithField("uuid", uuid).WithError(err).Error("handle hook: unable to fetch user by uuid")
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	m, err := storage.GetTransactionalMessage(messageID, u.ID)
+	if err != nil {
+		logger.From(c).WithFields(logrus.Fields{
+			"user_id":          u.ID,
+			"transactional_id": messageID,
+		}).WithError(err).Error("handle hook: unable to fetch transactional message")
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	e := &entities.TransactionalEvent{}
+	switch msg.NotificationType {
+	case emails.BounceType:
+		if msg.Bounce == nil {
+			logger.From(c).WithField("message", msg).Error("BounceType: bounce is nil")
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		m.Status = entities.TransactionalStatusBounced
+		e.Type = entities.TransactionalEventBounce
+		e.Description = msg.Bounce.BounceType + " " + msg.Bounce.BounceSubType
+		if len(msg.Bounce.BouncedRecipients) > 0 && msg.Bounce.BouncedRecipients[0].DiagnosticCode != "" {
+			e.Description += ": " + msg.Bounce.BouncedRecipients[0].DiagnosticCode
+		}
+		e.CreatedAt = msg.Bounce.Timestamp
+	case emails.ComplaintType:
+		if msg.Complaint == nil {
+			logger.From(c).WithField("message", msg).Error("ComplaintType: complaint is nil")
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		m.Status = entities.TransactionalStatusComplained
+		e.Type = entities.TransactionalEventComplaint
+		e.Description = msg.Complaint.ComplaintFeedbackType
+		e.CreatedAt = msg.Complaint.Timestamp
+	case emails.DeliveryType:
+		if msg.Delivery == nil {
+			logger.From(c).WithField("message", msg).Error("DeliveryType: delivery is nil.")
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		m.Status = entities.TransactionalStatusDelivered
+		e.Type = entities.TransactionalEventDelivery
+		e.Description = msg.Delivery.SMTPResponse
+		e.CreatedAt = msg.Delivery.Timestamp
+	case emails.SendType:
+		// the status is already set by the sender consumer, the event is only logged.
+		e.Type = entities.TransactionalEventSend
+		e.Description = "Accepted by the delivery provider."
+		e.CreatedAt = msg.Mail.Timestamp
+	case emails.RenderingFailureType:
+		m.Status = entities.TransactionalStatusFailed
+		e.Type = entities.TransactionalEventFailure
+		if msg.RenderingFailure != nil {
+			e.Description = msg.RenderingFailure.ErrorMessage
+		}
+	default:
+		// opens and clicks are not tracked for the transactional emails.
+		return
+	}
+
+	if m.ProviderMessageID == nil && msg.Mail.MessageID != "" {
+		m.ProviderMessageID = &msg.Mail.MessageID
+	}
+
+	err = storage.LogTransactionalEvent(m, e)
+	if err != nil {
+		logger.From(c).WithFields(logrus.Fields{
+			"user_id":          u.ID,
+			"transactional_id": messageID,
+			"message":          msg,
+		}).WithError(err).Error("Unable to log transactional event.")
+		c.AbortWithStatus(http.StatusBadRequest)
+	}
+}
+
+// handleAutomationHook records the bounces and complaints of the automation emails, the same way as
+// for the campaigns, and deactivates the subscribers which bounced permanently or complained.
+func handleAutomationHook(c *gin.Context, storage storage.Storage, msg entities.SesMessage) {
+	var automationID *int64
+	if tag, ok := msg.Mail.Tags["automation_id"]; ok && len(tag) > 0 {
+		id, err := strconv.ParseInt(tag[0], 10, 64)
+		if err == nil {
+			automationID = &id
+		}
+	}
+	if automationID == nil {
+		logger.From(c).WithField("tags", msg.Mail.Tags).Error("handle hook: unable to parse automation id")
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	uuid := c.Param("uuid")
+	u, err := storage.GetUserByUUID(uuid)
+	if err != nil {
+		logger.From(c).WithField("uuid", uuid).WithError(err).Error("handle hook: unable to fetch user by uuid")
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	log := logger.From(c).WithFields(logrus.Fields{
+		"user_id":       u.ID,
+		"automation_id": *automationID,
+	})
+
+	var deactivate []string
+	switch msg.NotificationType {
+	case emails.BounceType:
+		if msg.Bounce == nil {
+			log.WithField("message", msg).Error("BounceType: bounce is nil")
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		for _, r := range msg.Bounce.BouncedRecipients {
+			err = storage.CreateBounce(&entities.Bounce{
+				UserID:         u.ID,
+				AutomationID:   automationID,
+				Recipient:      r.EmailAddress,
+				Action:         r.Action,
+				Status:         r.Status,
+				DiagnosticCode: r.DiagnosticCode,
+				Type:           msg.Bounce.BounceType,
+				SubType:        msg.Bounce.BounceSubType,
+				FeedbackID:     msg.Bounce.FeedbackID,
+				CreatedAt:      msg.Bounce.Timestamp,
+			})
+			if err != nil {
+				log.WithField("recipient", r.EmailAddress).WithError(err).Error("Unable to create bounce record of automation email")
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			if msg.Bounce.BounceType == entities.BounceTypePermanent {
+				deactivate = append(deactivate, r.EmailAddress)
+			}
+		}
+	case emails.ComplaintType:
+		if msg.Complaint == nil {
+			log.WithField("message", msg).Error("ComplaintType: complaint is nil")
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		for _, r := range msg.Complaint.ComplainedRecipients {
+			err = storage.CreateComplaint(&entities.Complaint{
+				UserID:       u.ID,
+				AutomationID: automationID,
+				Recipient:    r.EmailAddress,
+				Type:         msg.Complaint.ComplaintFeedbackType,
+				FeedbackID:   msg.Complaint.FeedbackID,
+				CreatedAt:    msg.Complaint.Timestamp,
+			})
+			if err != nil {
+				log.WithField("recipient", r.EmailAddress).WithError(err).Error("Unable to create complaint record of automation email")
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			deactivate = append(deactivate, r.EmailAddress)
+		}
+	}
+
+	for _, r := range deactivate {
+		err = storage.DeactivateSubscriber(u.ID, r)
+		if err != nil {
+			log.WithField("recipient", r).WithError(err).Error("Unable to deactivate recipient of automation email")
+		}
+	}
+}
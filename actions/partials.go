@@ -0,0 +1,343 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// GetPartials returns the partials and layouts of the user.
+func GetPartials(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		partials, err := storage.GetPartials(middleware.GetUser(c).ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch partials.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch partials. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": partials,
+		})
+	}
+}
+
+// GetPartial returns the partial by the given id.
+func GetPartial(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := partialFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// PostPartial creates a new partial, or a layout when the layout flag is set.
+func PostPartial(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &params.PostPartial{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		p := &entities.Partial{
+			UserID:  middleware.GetUser(c).ID,
+			Name:    body.Name,
+			Content: body.Content,
+			Layout:  body.Layout,
+		}
+
+		if !checkPartial(c, storage, p) {
+			return
+		}
+
+		err := storage.CreatePartial(p)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create partial.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create partial. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, p)
+	}
+}
+
+// PutPartial updates the partial, the templates which include it use the new content on their next send.
+func PutPartial(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := partialFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		body := &params.PutPartial{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		if body.Name != p.Name {
+			templates, ok := partialTemplates(c, storage, p)
+			if !ok {
+				return
+			}
+			if len(templates) > 0 {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"message": "The partial is used by templates and can't be renamed.",
+				})
+				return
+			}
+		}
+
+		p.Name = body.Name
+		p.Content = body.Content
+
+		if !checkPartial(c, storage, p) {
+			return
+		}
+
+		err := storage.UpdatePartial(p)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to update partial.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update partial. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// DeletePartial deletes the partial, as long as no template depends on it.
+func DeletePartial(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := partialFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		templates, ok := partialTemplates(c, storage, p)
+		if !ok {
+			return
+		}
+		if len(templates) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": fmt.Sprintf("The partial is used by %d template(s).", len(templates)),
+			})
+			return
+		}
+
+		err := storage.DeletePartial(p.ID, p.UserID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete partial.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to delete partial. Please try again.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetPartialTemplates returns the templates which depend on the partial, directly or through other partials.
+func GetPartialTemplates(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := partialFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		templates, ok := partialTemplates(c, storage, p)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": templates,
+		})
+	}
+}
+
+func partialFromParam(c *gin.Context, storage storage.Storage) (*entities.Partial, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	p, err := storage.GetPartial(id, middleware.GetUser(c).ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Partial not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch partial.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch partial. Please try again.",
+		})
+		return nil, false
+	}
+
+	return p, true
+}
+
+// checkPartial validates the partial against the other partials of the user, the name must be unique,
+// the included partials must exist and the partial can't include itself.
+func checkPartial(c *gin.Context, storage storage.Storage, p *entities.Partial) bool {
+	err := p.Validate()
+	if err != nil {
+		var msg string
+		switch {
+		case errors.Is(err, entities.ErrPartialNameIsTaken):
+			msg = "The name of the partial is reserved."
+		case errors.Is(err, entities.ErrLayoutWithoutBody):
+			msg = "The layout must include the body with {{> body}}."
+		default:
+			msg = "Unable to parse the content of the partial."
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": msg,
+		})
+		return false
+	}
+
+	partials, err := storage.GetPartials(p.UserID)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch partials.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to save partial. Please try again.",
+		})
+		return false
+	}
+
+	for _, other := range partials {
+		if other.ID != p.ID && other.Name == p.Name {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Partial with that name already exists.",
+			})
+			return false
+		}
+	}
+
+	set := make(entities.PartialSet)
+	for _, other := range partials {
+		if other.ID != p.ID && !other.Layout {
+			set[other.Name] = other.Content
+		}
+	}
+
+	names, err := entities.PartialNames(p.Content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unable to parse the content of the partial.",
+		})
+		return false
+	}
+	for _, n := range names {
+		if _, ok := set[n]; !ok && !(p.Layout && n == entities.PartialBody) && n != p.Name {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": fmt.Sprintf("The included partial %s does not exist.", n),
+			})
+			return false
+		}
+	}
+
+	if !p.Layout {
+		set[p.Name] = p.Content
+		if errors.Is(set.Check(), entities.ErrPartialCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The partial can't include itself.",
+			})
+			return false
+		}
+	}
+
+	return true
+}
+
+// partialTemplates returns the templates which include the partial, directly or through other partials
+// and layouts. For a layout the templates which use the layout are returned.
+func partialTemplates(c *gin.Context, storage storage.Storage, p *entities.Partial) ([]entities.BaseTemplate, bool) {
+	partials, err := storage.GetPartials(p.UserID)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch partials.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch the templates of the partial. Please try again.",
+		})
+		return nil, false
+	}
+
+	// the layouts are included in the set as well, to find the templates which use them
+	var (
+		all     = make(entities.PartialSet, len(partials))
+		layouts = make(map[string]int64)
+	)
+	for _, other := range partials {
+		all[other.Name] = other.Content
+		if other.Layout {
+			layouts[other.Name] = other.ID
+		}
+	}
+
+	var (
+		names     []string
+		layoutIDs []int64
+	)
+	for _, n := range all.Dependents(p.Name) {
+		if id, ok := layouts[n]; ok {
+			layoutIDs = append(layoutIDs, id)
+		} else {
+			names = append(names, n)
+		}
+	}
+
+	templates, err := storage.GetTemplatesByPartials(p.UserID, names, layoutIDs)
+	if err != nil {
+		logger.From(c).WithError(err).Error("Unable to fetch the templates of the partial.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch the templates of the partial. Please try again.",
+		})
+		return nil, false
+	}
+
+	return templates, true
+}
@@ -0,0 +1,236 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestPartials(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, &objectsS3{MockS3Client: mockS3}, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e.GET("/api/partials").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.POST("/api/partials").WithJSON(params.PostPartial{Name: "body", Content: "foo"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The name of the partial is reserved.")
+
+	auth.POST("/api/partials").WithJSON(params.PostPartial{Name: "footer", Content: "{{> legal}}"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The included partial legal does not exist.")
+
+	legalID := auth.POST("/api/partials").WithJSON(params.PostPartial{Name: "legal", Content: "Example Inc."}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw()
+	legalStr := strconv.FormatFloat(legalID, 'f', 0, 64)
+
+	footerID := auth.POST("/api/partials").WithJSON(params.PostPartial{
+		Name:    "footer",
+		Content: `<a href="{{unsubscribe_url}}">Unsubscribe</a> {{> legal}}`,
+	}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw()
+	footerStr := strconv.FormatFloat(footerID, 'f', 0, 64)
+
+	auth.POST("/api/partials").WithJSON(params.PostPartial{Name: "footer", Content: "foo"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "Partial with that name already exists.")
+
+	// legal -> footer -> legal
+	auth.PUT("/api/partials/"+legalStr).WithJSON(params.PutPartial{Name: "legal", Content: "{{> footer}}"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The partial can't include itself.")
+
+	auth.POST("/api/partials").WithJSON(params.PostPartial{Name: "main", Content: "<div>{{> footer}}</div>", Layout: true}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The layout must include the body with {{> body}}.")
+
+	layoutID := int64(auth.POST("/api/partials").WithJSON(params.PostPartial{
+		Name:    "main",
+		Content: "<main>{{> body}}</main><footer>{{> footer}}</footer>",
+		Layout:  true,
+	}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("layout", true).
+		Value("id").Number().Raw())
+	layoutStr := strconv.FormatInt(layoutID, 10)
+
+	auth.GET("/api/partials").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().Length().Equal(3)
+
+	auth.POST("/api/templates").WithJSON(params.PostTemplate{
+		Name:        "unknown partial",
+		HTMLPart:    "<p>Hello</p>{{> header}}",
+		TextPart:    "Hello",
+		SubjectPart: "Hello",
+	}).Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Unable to create template, an included partial does not exist")
+
+	legalLayout := int64(legalID)
+	auth.POST("/api/templates").WithJSON(params.PostTemplate{
+		Name:        "unknown layout",
+		HTMLPart:    "<p>Hello</p>",
+		TextPart:    "Hello",
+		SubjectPart: "Hello",
+		LayoutID:    &legalLayout,
+	}).Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Unable to create template, the layout does not exist")
+
+	tmplID := int64(auth.POST("/api/templates").WithJSON(params.PostTemplate{
+		Name:        "newsletter",
+		HTMLPart:    "<p>Hello {{name}}</p>",
+		TextPart:    "Hello {{name}} {{> legal}}",
+		SubjectPart: "Hello",
+		LayoutID:    &layoutID,
+	}).Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("layout_id", layoutID).
+		Value("id").Number().Raw())
+
+	auth.POST("/api/templates").WithJSON(params.PostTemplate{
+		Name:        "plain",
+		HTMLPart:    "<p>Hello</p>",
+		TextPart:    "Hello",
+		SubjectPart: "Hello",
+	}).Expect().
+		Status(http.StatusCreated)
+
+	// the template includes legal directly and footer through the layout
+	auth.GET("/api/partials/" + legalStr + "/templates").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().Length().Equal(1)
+
+	auth.GET("/api/partials/"+layoutStr+"/templates").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().First().Object().
+		ValueEqual("name", "newsletter")
+
+	auth.DELETE("/api/partials/"+layoutStr).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "The partial is used by 1 template(s).")
+
+	auth.PUT("/api/partials/" + footerStr).WithJSON(params.PutPartial{Name: "footer2", Content: "foo"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	// the changes of the partial are used on the next render
+	auth.PUT("/api/partials/" + legalStr).WithJSON(params.PutPartial{Name: "legal", Content: "Example Ltd."}).
+		Expect().
+		Status(http.StatusOK)
+
+	uuid := auth.GET("/api/users/me").Expect().Status(http.StatusOK).JSON().Object().Value("uuid").String().Raw()
+	u, err := s.GetUserByUUID(uuid)
+	assert.Nil(t, err)
+
+	campaign := &entities.Campaign{
+		UserID:     u.ID,
+		Name:       "newsletter",
+		TemplateID: tmplID,
+		Status:     entities.StatusDraft,
+	}
+	err = s.CreateCampaign(campaign)
+	assert.Nil(t, err)
+
+	preview := auth.GET("/api/campaigns/" + strconv.FormatInt(campaign.ID, 10) + "/preview").
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+	preview.Value("html").String().
+		Contains("<main><p>Hello </p></main><footer>").
+		Contains("Unsubscribe</a> Example Ltd.</footer>")
+	preview.ValueEqual("text", "Hello  Example Ltd.")
+
+	auth.GET("/api/campaigns/" + strconv.FormatInt(campaign.ID, 10) + "/lint").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("issues").Array().Empty()
+
+	auth.DELETE("/api/templates/" + strconv.FormatInt(tmplID, 10)).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.DELETE("/api/partials/" + layoutStr).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/partials/" + layoutStr).
+		Expect().
+		Status(http.StatusNotFound)
+}
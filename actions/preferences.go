@@ -0,0 +1,208 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+type preferenceSegment struct {
+	ID      int64
+	Name    string
+	Checked bool
+}
+
+type preferenceMetadata struct {
+	Key   string
+	Value string
+}
+
+// GetPreferences renders the preference center of the subscriber from the signed preferences url.
+func GetPreferences(storage storage.Storage, unsubscribeSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := c.Query("email")
+		uuid := c.Query("uuid")
+		t := c.Query("t")
+
+		u, sub, err := verifySubscriberToken(storage, unsubscribeSecret, uuid, email, t)
+		if err != nil {
+			logger.From(c).WithFields(logrus.Fields{
+				"email": email,
+				"uuid":  uuid,
+			}).WithError(err).Warn("Preferences: invalid token")
+
+			c.HTML(http.StatusBadRequest, "preferences.html", gin.H{
+				"invalid": true,
+			})
+			return
+		}
+
+		segments, err := storage.GetSubscriberSegments(sub.ID, u.ID)
+		if err != nil {
+			logger.From(c).WithError(err).WithField("subscriber_id", sub.ID).Error("Preferences: unable to fetch segments")
+			c.HTML(http.StatusInternalServerError, "preferences.html", gin.H{
+				"invalid": true,
+			})
+			return
+		}
+
+		optOuts, err := storage.GetSegmentOptOuts(sub.ID)
+		if err != nil {
+			logger.From(c).WithError(err).WithField("subscriber_id", sub.ID).Error("Preferences: unable to fetch opt-outs")
+			c.HTML(http.StatusInternalServerError, "preferences.html", gin.H{
+				"invalid": true,
+			})
+			return
+		}
+
+		var prefSegments []preferenceSegment
+		for _, s := range segments {
+			checked := true
+			for _, id := range optOuts {
+				checked = checked && id != s.ID
+			}
+			prefSegments = append(prefSegments, preferenceSegment{ID: s.ID, Name: s.Name, Checked: checked})
+		}
+
+		m, err := sub.GetMetadata()
+		if err != nil {
+			logger.From(c).WithError(err).WithField("subscriber_id", sub.ID).Warn("Preferences: unable to decode metadata")
+		}
+		var metadata []preferenceMetadata
+		for k, v := range m {
+			metadata = append(metadata, preferenceMetadata{Key: k, Value: v})
+		}
+		sort.Slice(metadata, func(i, j int) bool {
+			return metadata[i].Key < metadata[j].Key
+		})
+
+		var pausedUntil string
+		if sub.IsPaused() {
+			pausedUntil = sub.PausedUntil.Time.Format("January 2, 2006")
+		}
+
+		c.HTML(http.StatusOK, "preferences.html", gin.H{
+			"email":        email,
+			"uuid":         uuid,
+			"t":            t,
+			"name":         sub.Name,
+			"active":       sub.Active,
+			"segments":     prefSegments,
+			"metadata":     metadata,
+			"paused_until": pausedUntil,
+			"saved":        c.Query("saved"),
+			"failed":       c.Query("failed"),
+		})
+	}
+}
+
+// PostPreferences saves the choices of the subscriber from the preference center. The
+// unchecked segments are stored as opt-outs which are excluded when sending campaigns.
+func PostPreferences(storage storage.Storage, unsubscribeSecret string, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &params.PostPreferences{}
+		if err := c.ShouldBind(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		q := url.Values{}
+		q.Add("email", body.Email)
+		q.Add("uuid", body.UUID)
+		q.Add("t", body.Token)
+		redirURL := appURL + "/preferences.html?" + q.Encode()
+
+		log := logger.From(c).WithFields(logrus.Fields{
+			"email": body.Email,
+			"uuid":  body.UUID,
+		})
+
+		u, sub, err := verifySubscriberToken(storage, unsubscribeSecret, body.UUID, body.Email, body.Token)
+		if err != nil {
+			log.WithError(err).Warn("Preferences: invalid token")
+			c.Redirect(http.StatusSeeOther, redirURL+"&failed=true")
+			return
+		}
+
+		if body.Unsubscribe {
+			err = storage.DeactivateSubscriber(u.ID, body.Email)
+			if err != nil {
+				log.WithError(err).Error("Preferences: unable to deactivate subscriber")
+				c.Redirect(http.StatusSeeOther, redirURL+"&failed=true")
+				return
+			}
+
+			c.Redirect(http.StatusSeeOther, appURL+"/unsubscribe-success.html")
+			return
+		}
+
+		segments, err := storage.GetSubscriberSegments(sub.ID, u.ID)
+		if err != nil {
+			log.WithError(err).Error("Preferences: unable to fetch segments")
+			c.Redirect(http.StatusSeeOther, redirURL+"&failed=true")
+			return
+		}
+
+		var optOuts []int64
+		for _, s := range segments {
+			keep := false
+			for _, id := range body.SegmentIDs {
+				keep = keep || id == s.ID
+			}
+			if !keep {
+				optOuts = append(optOuts, s.ID)
+			}
+		}
+
+		// only the existing metadata keys can be changed.
+		m, err := sub.GetMetadata()
+		if err != nil {
+			log.WithError(err).Error("Preferences: unable to decode metadata")
+			c.Redirect(http.StatusSeeOther, redirURL+"&failed=true")
+			return
+		}
+		for k, v := range c.PostFormMap("metadata") {
+			if _, ok := m[k]; ok {
+				m[k] = v
+			}
+		}
+		sub.MetaJSON, err = json.Marshal(m)
+		if err != nil {
+			log.WithError(err).Error("Preferences: unable to encode metadata")
+			c.Redirect(http.StatusSeeOther, redirURL+"&failed=true")
+			return
+		}
+
+		sub.Name = body.Name
+		sub.PausedUntil.Valid = false
+		if body.PauseDays > 0 {
+			sub.PausedUntil.SetValid(time.Now().UTC().AddDate(0, 0, body.PauseDays))
+		}
+
+		err = storage.SaveSubscriberPreferences(sub, optOuts)
+		if err != nil {
+			log.WithError(err).Error("Preferences: unable to save preferences")
+			c.Redirect(http.StatusSeeOther, redirURL+"&failed=true")
+			return
+		}
+
+		c.Redirect(http.StatusSeeOther, redirURL+"&saved=true")
+	}
+}
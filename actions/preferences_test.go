@@ -0,0 +1,171 @@
+package actions_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPreferences(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Twice().Return(&s3.PutObjectAclOutput{}, nil)
+
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	uuid := auth.GET("/api/users/me").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("uuid").String().Raw()
+
+	u, err := s.GetUserByUUID(uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := u.ID
+
+	for _, name := range []string{"news", "offers"} {
+		auth.POST("/api/segments").WithJSON(params.Segment{Name: name}).
+			Expect().
+			Status(http.StatusCreated)
+	}
+
+	auth.POST("/api/subscribers").WithJSON(params.PostSubscriber{
+		Name:       "jane",
+		Email:      "jane@example.com",
+		SegmentIDs: []int64{1, 2},
+		Metadata:   map[string]string{"city": "Skopje"},
+	}).
+		Expect().
+		Status(http.StatusCreated)
+
+	sub, err := s.GetSubscriberByEmail("jane@example.com", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := sub.GenerateUnsubscribeToken("secretexmplkeythatis32characters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.GET("/preferences.html").WithQuery("email", "jane@example.com").WithQuery("uuid", uuid).WithQuery("t", "invalid").
+		Expect().
+		Status(http.StatusBadRequest).
+		Body().Contains("Invalid link.")
+
+	res := e.GET("/preferences.html").WithQuery("email", "jane@example.com").WithQuery("uuid", uuid).WithQuery("t", token).
+		Expect().
+		Status(http.StatusOK)
+	// the page contains the personal data of the subscriber, so it's never cached
+	res.Header("Cache-Control").Contains("no-store")
+	page := res.Body()
+	page.Contains("news").Contains("offers").Contains("Skopje")
+
+	t.Run("keeps only the selected segments", func(t *testing.T) {
+		e.POST("/api/preferences").
+			WithFormField("email", "jane@example.com").
+			WithFormField("uuid", uuid).
+			WithFormField("t", token).
+			WithFormField("name", "Jane Doe").
+			WithFormField("segments", "2").
+			WithFormField("metadata[city]", "Berlin").
+			WithFormField("metadata[foo]", "bar").
+			WithFormField("pause_days", "7").
+			Expect().
+			Status(http.StatusOK).
+			Body().Contains("Your preferences have been saved.")
+
+		optOuts, err := s.GetSegmentOptOuts(sub.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{1}, optOuts)
+
+		sub, err := s.GetSubscriber(sub.ID, userID)
+		assert.Nil(t, err)
+		assert.Equal(t, "Jane Doe", sub.Name)
+		assert.True(t, sub.IsPaused())
+		assert.True(t, sub.Active)
+		assert.JSONEq(t, `{"city":"Berlin"}`, string(sub.MetaJSON))
+	})
+
+	t.Run("unsubscribes from everything", func(t *testing.T) {
+		e.POST("/api/preferences").
+			WithFormField("email", "jane@example.com").
+			WithFormField("uuid", uuid).
+			WithFormField("t", "invalid").
+			Expect().
+			Status(http.StatusBadRequest).
+			Body().Contains("Invalid link.")
+
+		e.POST("/api/preferences").
+			WithFormField("email", "jane@example.com").
+			WithFormField("uuid", uuid).
+			WithFormField("t", token).
+			WithFormField("unsubscribe", "true").
+			Expect().
+			Status(http.StatusOK)
+
+		sub, err := s.GetSubscriber(sub.ID, userID)
+		assert.Nil(t, err)
+		assert.False(t, sub.Active)
+	})
+}
@@ -1,6 +1,7 @@
 package actions
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 
@@ -109,12 +110,21 @@ func PostSegment(storage storage.Storage) gin.HandlerFunc {
 			return
 		}
 
+		rules, err := segmentRules(body.Rules)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
 		l := &entities.Segment{
 			Name:   body.Name,
 			UserID: middleware.GetUser(c).ID,
+			Rules:  rules,
 		}
 
-		_, err := storage.GetSegmentByName(body.Name, middleware.GetUser(c).ID)
+		_, err = storage.GetSegmentByName(body.Name, middleware.GetUser(c).ID)
 		if err == nil {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{
 				"message": "Segment with that name already exists.",
@@ -171,7 +181,16 @@ func PutSegment(storage storage.Storage) gin.HandlerFunc {
 			return
 		}
 
+		rules, err := segmentRules(body.Rules)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
 		l.Name = body.Name
+		l.Rules = rules
 
 		if err = storage.UpdateSegment(l); err != nil {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{
@@ -184,6 +203,44 @@ func PutSegment(storage storage.Storage) gin.HandlerFunc {
 	}
 }
 
+// PreviewSegment returns the number of subscribers which match the segment rules.
+func PreviewSegment(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &params.SegmentPreview{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		if err := body.Rules.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		total, err := storage.CountSubscribersBySegmentRules(middleware.GetUser(c).ID, body.Rules)
+		if err != nil {
+			logger.From(c).WithError(err).Error("preview segment: unable to count subscribers")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to preview segment. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"total": total,
+		})
+	}
+}
+
 func DeleteSegment(storage storage.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -410,3 +467,20 @@ func DetachSubscriber(storage storage.Storage) gin.HandlerFunc {
 		c.Status(http.StatusNoContent)
 	}
 }
+
+// segmentRules validates and encodes the segment rules, nil rules make the segment a static list.
+func segmentRules(rules *entities.SegmentRules) (entities.JSON, error) {
+	if rules == nil {
+		return nil, nil
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return entities.JSON(b), nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/mailbadger/app/config"
 	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/opa"
 	"github.com/mailbadger/app/services/boundaries"
@@ -99,6 +100,44 @@ func TestSegments(t *testing.T) {
 		Expect().
 		Status(http.StatusOK)
 
+	// test segment rules
+	rules := &entities.SegmentRules{
+		Match: entities.RuleMatchAll,
+		Conditions: []entities.SegmentCondition{
+			{Field: "metadata.plan", Op: entities.RuleOpEquals, Value: "pro"},
+		},
+	}
+
+	auth.PUT("/api/segments/1").WithJSON(params.Segment{Name: "djaleputtest", Rules: rules}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("rules", rules)
+
+	auth.POST("/api/segments").WithJSON(params.Segment{
+		Name:  "invalid",
+		Rules: &entities.SegmentRules{Match: entities.RuleMatchAny},
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "invalid segment rules: between 1 and 20 conditions are allowed")
+
+	auth.POST("/api/segments/preview").WithJSON(params.SegmentPreview{Rules: rules}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("total", 0)
+
+	auth.POST("/api/segments/preview").WithJSON(params.SegmentPreview{
+		Rules: &entities.SegmentRules{
+			Match: entities.RuleMatchAll,
+			Conditions: []entities.SegmentCondition{
+				{Field: entities.RuleFieldOpened, Op: entities.RuleOpEquals, Value: 1},
+			},
+		},
+	}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "invalid segment rules: field opened supports operators: any_of_last none_of_last")
+
 	// delete segment by id
 	auth.DELETE("/api/segments/1").
 		Expect().
@@ -0,0 +1,85 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// currentRateWindow is the time window used for calculating the current send rate.
+const currentRateWindow = time.Minute
+
+func GetSendRate(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		r, err := storage.GetSendRate(u.ID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithError(err).Error("Unable to fetch send rate.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to fetch the send rate, please try again.",
+				})
+				return
+			}
+			r = &entities.SendRate{UserID: u.ID}
+		}
+
+		sent, err := storage.CountSendLogsSince(u.ID, time.Now().UTC().Add(-currentRateWindow))
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to count send logs.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch the send rate, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, entities.SendRateStats{
+			SendRate:    *r,
+			Rate:        r.Rate(),
+			CurrentRate: float64(sent) / currentRateWindow.Seconds(),
+		})
+	}
+}
+
+func PutSendRate(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		body := &params.PutSendRate{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		err := storage.SetMaxSendRate(u.ID, body.MaxSendRate)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to set max send rate.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to save the send rate, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "The send rate is saved. It might take up to a minute for the change to take effect.",
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package actions_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendRate(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Twice().Return(&s3.PutObjectAclOutput{}, nil)
+
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e.GET("/api/send-rate").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.GET("/api/send-rate").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("max_send_rate", 0).
+		ValueEqual("quota_send_rate", 0).
+		ValueEqual("rate", 0).
+		ValueEqual("current_rate", 0)
+
+	auth.PUT("/api/send-rate").WithJSON(params.PutSendRate{MaxSendRate: -1}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{
+			"max_send_rate": "Must be greater than or equal to 0",
+		})
+
+	auth.PUT("/api/send-rate").WithJSON(params.PutSendRate{MaxSendRate: 5}).
+		Expect().
+		Status(http.StatusOK)
+
+	auth.GET("/api/send-rate").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("max_send_rate", 5).
+		ValueEqual("rate", 5)
+}
@@ -94,7 +94,7 @@ func PostSESKeys(store storage.Storage, appURL string) gin.HandlerFunc {
 		//createAWSResources is a slow process and could fail periodically.
 		go func(
 			c *gin.Context,
-			sender emails.Sender,
+			sender emails.SesSender,
 			snsClient events.EventsClient,
 			store storage.Storage,
 			keys *entities.SesKeys,
@@ -120,7 +120,7 @@ func PostSESKeys(store storage.Storage, appURL string) gin.HandlerFunc {
 }
 
 func createAWSResources(
-	sender emails.Sender,
+	sender emails.SesSender,
 	snsClient events.EventsClient,
 	uuid string,
 	appURL string,
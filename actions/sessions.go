@@ -0,0 +1,114 @@
+package actions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/storage"
+)
+
+// GetSessions returns the active sessions of the user, the session of the request is marked as current.
+// The sessions which exceeded their lifetime or idle timeout are not returned.
+func GetSessions(storage storage.Storage, sess session.Session) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		var lastActivityAfter time.Time
+		if sess.IdleTimeout > 0 {
+			lastActivityAfter = now.Add(-sess.IdleTimeout)
+		}
+
+		sessions, err := storage.GetUserSessions(middleware.GetActor(c).ID, lastActivityAfter, now.Add(-sess.Lifetime))
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch sessions.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch sessions. Please try again.",
+			})
+			return
+		}
+
+		if current := middleware.GetSession(c); current != nil {
+			for i := range sessions {
+				sessions[i].Current = sessions[i].ID == current.ID
+			}
+		}
+
+		c.JSON(http.StatusOK, sessions)
+	}
+}
+
+// DeleteSession revokes the session by the given id. Revoking the session of the request signs out the user.
+func DeleteSession(storage storage.Storage, sess session.Session) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		s, err := storage.GetUserSessionByID(id, middleware.GetActor(c).ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "Session not found.",
+				})
+				return
+			}
+
+			logger.From(c).WithError(err).Error("Unable to fetch session.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch session. Please try again.",
+			})
+			return
+		}
+
+		if current := middleware.GetSession(c); current != nil && current.ID == s.ID {
+			err = sess.DeleteUserSession(c)
+		} else {
+			err = storage.DeleteSession(s.SessionID)
+		}
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete session.")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to delete session.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DeleteOtherSessions revokes all sessions of the user except the session of the request.
+// All sessions are revoked when the request is made with an api key.
+func DeleteOtherSessions(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := deleteOtherSessions(c, storage)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete sessions.")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to delete sessions.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func deleteOtherSessions(c *gin.Context, storage storage.Storage) error {
+	var sessionID string
+	if s := middleware.GetSession(c); s != nil {
+		sessionID = s.SessionID
+	}
+	return storage.DeleteOtherUserSessions(middleware.GetActor(c).ID, sessionID)
+}
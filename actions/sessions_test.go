@@ -0,0 +1,209 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestSessions(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+	mockSender.On("Send", mock.Anything, mock.AnythingOfType("*emails.Message")).Return("", nil)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	newExpect := func() *httpexpect.Expect {
+		return setup(
+			t, s,
+			sess,
+			mockS3,
+			mockPub,
+			mockSender,
+			templatesvc,
+			boundarysvc,
+			subscrsvc,
+			reportsvc,
+			compiler,
+			false, // enable signup
+			false, // verify email
+		)
+	}
+
+	auth, err := createAuthenticatedExpect(newExpect(), s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	guest := newExpect()
+	john, err := s.GetUserByUsername("john")
+	assert.Nil(t, err)
+
+	// signIn returns the cookie of a new session of john
+	password := "hunter1"
+	signIn := func() *httpexpect.Cookie {
+		return guest.POST("/api/authenticate").WithHeader("User-Agent", "Firefox").
+			WithJSON(params.PostAuthenticate{
+				Username: "john",
+				Password: password,
+			}).Expect().
+			Status(http.StatusOK).
+			Cookie("mbsess")
+	}
+	me := func(c *httpexpect.Cookie) *httpexpect.Response {
+		return guest.GET("/api/users/me").WithCookie(c.Name().Raw(), c.Value().Raw()).Expect()
+	}
+
+	c := signIn()
+
+	// sessions returns the sessions of john, the current one is first
+	sessions := func() []*httpexpect.Object {
+		var current, others []*httpexpect.Object
+		for _, v := range auth.GET("/api/users/sessions").Expect().Status(http.StatusOK).JSON().Array().Iter() {
+			o := v.Object()
+			o.NotContainsKey("session_id")
+			if o.Value("current").Boolean().Raw() {
+				current = append(current, o)
+			} else {
+				others = append(others, o)
+			}
+		}
+		assert.Len(t, current, 1)
+		return append(current, others...)
+	}
+	sessionURL := func(o *httpexpect.Object) string {
+		return "/api/users/sessions/" + strconv.FormatInt(int64(o.Value("id").Number().Raw()), 10)
+	}
+
+	// the expired sessions are not listed
+	err = s.CreateSession(&entities.Session{
+		UserID:         john.ID,
+		SessionID:      "expired",
+		LastActivityAt: time.Now().Add(-25 * time.Hour),
+	})
+	assert.Nil(t, err)
+
+	list := sessions()
+	assert.Len(t, list, 2)
+	list[1].ValueEqual("user_agent", "Firefox")
+	list[1].ContainsKey("ip")
+	list[1].Value("last_activity_at").String().NotEmpty()
+	list[1].Value("created_at").String().NotEmpty()
+
+	auth.DELETE("/api/users/sessions/2223").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.DELETE(sessionURL(list[1])).
+		Expect().
+		Status(http.StatusNoContent)
+
+	me(c).Status(http.StatusUnauthorized)
+	auth.GET("/api/users/me").Expect().Status(http.StatusOK)
+
+	// all other sessions are revoked
+	c1, c2 := signIn(), signIn()
+	auth.DELETE("/api/users/sessions").
+		Expect().
+		Status(http.StatusNoContent)
+
+	me(c1).Status(http.StatusUnauthorized)
+	me(c2).Status(http.StatusUnauthorized)
+	assert.Len(t, sessions(), 1)
+
+	// changing the password revokes the other sessions
+	c = signIn()
+	auth.POST("/api/users/password").WithJSON(params.ChangePassword{
+		Password:    password,
+		NewPassword: "hunter2foobar",
+	}).Expect().
+		Status(http.StatusOK)
+	password = "hunter2foobar"
+
+	me(c).Status(http.StatusUnauthorized)
+	auth.GET("/api/users/me").Expect().Status(http.StatusOK)
+
+	// revoking the current session signs out the user
+	auth.DELETE(sessionURL(sessions()[0])).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/users/me").Expect().Status(http.StatusUnauthorized)
+
+	// the sessions expire after the idle timeout
+	c = signIn()
+	me(c).Status(http.StatusOK)
+
+	list2, err := s.GetUserSessions(john.ID, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, list2, 1)
+	err = s.UpdateSessionLastActivity(list2[0].SessionID, time.Now().Add(-25*time.Hour))
+	assert.Nil(t, err)
+
+	me(c).Status(http.StatusUnauthorized)
+
+	list2, err = s.GetUserSessions(john.ID, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Empty(t, list2)
+
+	// resetting the forgotten password revokes all sessions
+	c1, c2 = signIn(), signIn()
+	err = s.CreateToken(&entities.Token{
+		UserID:    john.ID,
+		Token:     "forgot-password-token",
+		Type:      entities.ForgotPasswordTokenType,
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	})
+	assert.Nil(t, err)
+
+	guest.PUT("/api/forgot-password/forgot-password-token").WithJSON(params.PutForgotPassword{
+		Password: "hunter3foobar",
+	}).Expect().
+		Status(http.StatusOK)
+	password = "hunter3foobar"
+
+	me(c1).Status(http.StatusUnauthorized)
+	me(c2).Status(http.StatusUnauthorized)
+	me(signIn()).Status(http.StatusOK)
+}
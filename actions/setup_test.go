@@ -9,6 +9,7 @@ import (
 
 	"github.com/gavv/httpexpect/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/open-policy-agent/opa/ast"
 
 	"github.com/mailbadger/app/config"
@@ -21,6 +22,7 @@ import (
 	"github.com/mailbadger/app/services/reports"
 	"github.com/mailbadger/app/services/subscribers"
 	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/services/webhooks"
 	"github.com/mailbadger/app/session"
 	"github.com/mailbadger/app/sqs"
 	"github.com/mailbadger/app/storage"
@@ -49,6 +51,7 @@ func setup(
 	mode.SetMode("test")
 
 	queueURL := "http://example.com/campaigns-queue"
+	transactionalQueueURL := "http://example.com/transactional-queue"
 	api := routes.New(
 		sess,
 		s,
@@ -60,7 +63,9 @@ func setup(
 		boundarysvc,
 		subscrsvc,
 		reportsvc,
+		webhooks.New(s, &http.Client{}), // the test servers listen on the loopback address
 		&queueURL,
+		&transactionalQueueURL,
 		"/var/www/app",       // app dir
 		"http://example.com", // app url
 		"files-bucket",
@@ -70,6 +75,8 @@ func setup(
 		"secretexmplkeythatis32characters", // unsubscribe token secret
 		"test@example.com",                 // system email
 		config.Social{},
+		emails.DeliveryModeLocal,
+		t.TempDir(), // local maildir
 	)
 
 	handler := api.Handler()
@@ -88,6 +95,12 @@ func setup(
 }
 
 func createAuthenticatedExpect(e *httpexpect.Expect, s storage.Storage) (*httpexpect.Expect, error) {
+	return createAuthenticatedUser(e, s, "john", []entities.Role{{Name: "admin"}})
+}
+
+// createAuthenticatedUser creates a user with the given username and roles, and returns
+// the expect which is authenticated as the user.
+func createAuthenticatedUser(e *httpexpect.Expect, s storage.Storage, username string, roles []entities.Role) (*httpexpect.Expect, error) {
 	pass, err := bcrypt.GenerateFromPassword([]byte("hunter1"), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
@@ -99,16 +112,15 @@ func createAuthenticatedExpect(e *httpexpect.Expect, s storage.Storage) (*httpex
 	}
 
 	u := &entities.User{
+		UUID:     uuid.New().String(),
 		Active:   true,
-		Username: "john",
+		Username: username,
 		Password: sql.NullString{
 			String: string(pass),
 			Valid:  true,
 		},
 		Boundaries: b,
-		Roles: []entities.Role{
-			{Name: "admin"},
-		},
+		Roles:      roles,
 	}
 	err = s.CreateUser(u)
 	if err != nil {
@@ -116,7 +128,7 @@ func createAuthenticatedExpect(e *httpexpect.Expect, s storage.Storage) (*httpex
 	}
 
 	c := e.POST("/api/authenticate").WithJSON(params.PostAuthenticate{
-		Username: "john",
+		Username: username,
 		Password: "hunter1",
 	}).Expect().Status(http.StatusOK).Cookie("mbsess")
 
@@ -0,0 +1,580 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/ezzarghili/recaptcha-go.v3"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/boundaries"
+	templatesvc "github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/templates"
+	"github.com/mailbadger/app/utils"
+	"github.com/mailbadger/app/validator"
+)
+
+// confirmSubscriptionTTL is the time after which the subscription confirmation link expires.
+const confirmSubscriptionTTL = 48 * time.Hour
+
+func GetSignupForm(storage storage.Storage, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		f, err := storage.GetSignupForm(u.ID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithError(err).Error("Unable to fetch signup form.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to fetch the signup form, please try again.",
+				})
+				return
+			}
+			f = &entities.SignupForm{UserID: u.ID}
+		}
+
+		f.SubscribeURL = fmt.Sprintf("%s/api/subscribe/%s", appURL, u.UUID)
+
+		c.JSON(http.StatusOK, f)
+	}
+}
+
+func PutSignupForm(storage storage.Storage, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		body := &params.PutSignupForm{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		for _, id := range []*int64{body.ConfirmTemplateID, body.SuccessTemplateID} {
+			if id == nil {
+				continue
+			}
+			_, err := storage.GetTemplate(*id, u.ID)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"message": fmt.Sprintf("Template with id %d not found.", *id),
+				})
+				return
+			}
+		}
+
+		segments, err := storage.GetSegmentsByIDs(u.ID, body.SegmentIDs)
+		if err != nil || len(segments) != len(body.SegmentIDs) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Invalid data",
+				"errors": map[string]string{
+					"segment_ids": "Unable to find the specified segments.",
+				},
+			})
+			return
+		}
+
+		ids, err := json.Marshal(body.SegmentIDs)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to encode signup form segment ids.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to save the signup form, please try again.",
+			})
+			return
+		}
+
+		f := &entities.SignupForm{
+			UserID:            u.ID,
+			Enabled:           body.Enabled,
+			Recaptcha:         body.Recaptcha,
+			ConfirmTemplateID: body.ConfirmTemplateID,
+			SuccessTemplateID: body.SuccessTemplateID,
+			SegmentIDs:        ids,
+		}
+
+		err = storage.SaveSignupForm(f)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to save signup form.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to save the signup form, please try again.",
+			})
+			return
+		}
+
+		f.SubscribeURL = fmt.Sprintf("%s/api/subscribe/%s", appURL, u.UUID)
+
+		c.JSON(http.StatusOK, f)
+	}
+}
+
+// PostSubscribe creates an inactive subscriber for the user's signup form and sends a
+// confirmation email. The subscriber is activated and added to the segments only after
+// the email address is confirmed.
+func PostSubscribe(
+	storage storage.Storage,
+	templatesvc templatesvc.Service,
+	boundarysvc boundaries.Service,
+	emailSender emails.Sender,
+	recaptchaSecret string,
+	systemEmailSource string,
+	appURL string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &params.PostSubscribe{}
+		if err := c.ShouldBind(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		u, err := storage.GetUserByUUID(c.Param("uuid"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Signup form not found.",
+			})
+			return
+		}
+
+		f, err := storage.GetSignupForm(u.ID)
+		if err != nil || !f.Enabled {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Signup form not found.",
+			})
+			return
+		}
+
+		if f.Recaptcha {
+			// the form requires a captcha, subscriptions are rejected until the secret is configured.
+			if recaptchaSecret == "" {
+				logger.From(c).WithField("user_id", u.ID).Error("subscribe: recaptcha is enabled but the secret is not set")
+				c.JSON(http.StatusForbidden, gin.H{
+					"message": "Unable to subscribe. Captcha is invalid.",
+				})
+				return
+			}
+
+			captcha, err := recaptcha.NewReCAPTCHA(recaptchaSecret, recaptcha.V2, 10*time.Second)
+			if err != nil {
+				logger.From(c).WithError(err).Error("subscribe: recaptcha initialize error")
+				c.JSON(http.StatusForbidden, gin.H{
+					"message": "Unable to subscribe. Captcha is invalid.",
+				})
+				return
+			}
+
+			err = captcha.Verify(body.TokenResponse)
+			if err != nil {
+				logger.From(c).WithField("email", body.Email).WithError(err).Info("subscribe: recaptcha invalid response")
+				c.JSON(http.StatusForbidden, gin.H{
+					"message": "Unable to subscribe. Captcha is invalid.",
+				})
+				return
+			}
+		}
+
+		segmentIDs, err := selectSegmentIDs(f, body.SegmentIDs)
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Invalid data",
+				"errors": map[string]string{
+					"segments": "Unable to find the specified segments.",
+				},
+			})
+			return
+		}
+
+		sub, err := storage.GetSubscriberByEmail(body.Email, u.ID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithError(err).Error("subscribe: unable to fetch subscriber")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to subscribe, please try again.",
+				})
+				return
+			}
+
+			sub, err = createPendingSubscriber(storage, boundarysvc, u, body)
+			if err != nil {
+				logger.From(c).WithError(err).Error("subscribe: unable to create subscriber")
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"message": "Unable to subscribe, please try again.",
+				})
+				return
+			}
+		}
+
+		// blacklisted subscribers get the same response, but no email is sent to them.
+		if !sub.Blacklisted {
+			token, err := createSubscriberConsent(c, storage, sub, segmentIDs)
+			if err != nil {
+				logger.From(c).WithError(err).Error("subscribe: unable to create consent")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to subscribe, please try again.",
+				})
+				return
+			}
+
+			go func(c *gin.Context) {
+				err := sendConfirmSubscription(c, templatesvc, emailSender, f, sub, token, systemEmailSource, appURL)
+				if err != nil {
+					logger.From(c).WithError(err).Error("subscribe: unable to send confirmation email")
+				}
+			}(c.Copy())
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Please check your email to confirm your subscription.",
+		})
+	}
+}
+
+// GetConfirmSubscription renders the landing page of the confirmation link. The subscription is
+// confirmed by the form on the page, so that link scanners which prefetch the link don't confirm it.
+func GetConfirmSubscription(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenStr := c.Param("token")
+
+		t, err := storage.GetToken(tokenStr)
+		if err != nil || t.Type != entities.ConfirmSubscriptionTokenType {
+			logger.From(c).WithError(err).Info("confirm subscription: token is invalid")
+			c.HTML(http.StatusBadRequest, "subscribe-success.html", gin.H{
+				"failed": true,
+			})
+			return
+		}
+
+		c.HTML(http.StatusOK, "subscribe-confirm.html", gin.H{
+			"token": tokenStr,
+		})
+	}
+}
+
+// PostConfirmSubscription confirms the subscription by the given token and renders the success page.
+func PostConfirmSubscription(storage storage.Storage, templatesvc templatesvc.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenStr := c.Param("token")
+
+		t, err := storage.GetToken(tokenStr)
+		if err != nil || t.Type != entities.ConfirmSubscriptionTokenType {
+			logger.From(c).WithError(err).Info("confirm subscription: token is invalid")
+			c.HTML(http.StatusBadRequest, "subscribe-success.html", gin.H{
+				"failed": true,
+			})
+			return
+		}
+
+		consent, err := storage.GetSubscriberConsentByTokenID(t.ID)
+		if err != nil {
+			logger.From(c).WithError(err).WithField("token_id", t.ID).Error("confirm subscription: unable to fetch consent")
+			c.HTML(http.StatusBadRequest, "subscribe-success.html", gin.H{
+				"failed": true,
+			})
+			return
+		}
+
+		// the segments might have been deleted in the meantime.
+		var segmentIDs []int64
+		ids, err := consent.GetSegmentIDs()
+		if err == nil && len(ids) > 0 {
+			segments, err := storage.GetSegmentsByIDs(consent.UserID, ids)
+			if err == nil {
+				for _, s := range segments {
+					segmentIDs = append(segmentIDs, s.ID)
+				}
+			}
+		}
+
+		consent.ConfirmIP = c.ClientIP()
+		err = storage.ConfirmSubscription(consent, segmentIDs)
+		if err != nil {
+			logger.From(c).WithError(err).WithField("consent_id", consent.ID).Error("confirm subscription: unable to confirm")
+			c.HTML(http.StatusInternalServerError, "subscribe-success.html", gin.H{
+				"failed": true,
+			})
+			return
+		}
+
+		f, err := storage.GetSignupForm(consent.UserID)
+		if err != nil || f.SuccessTemplateID == nil {
+			c.HTML(http.StatusOK, "subscribe-success.html", nil)
+			return
+		}
+
+		html, err := renderSuccessTemplate(c, storage, templatesvc, f, consent.SubscriberID)
+		if err != nil {
+			logger.From(c).WithError(err).WithField("user_id", consent.UserID).Error("confirm subscription: unable to render success template")
+			c.HTML(http.StatusOK, "subscribe-success.html", nil)
+			return
+		}
+
+		// the page is served from the app origin, so the browser isn't allowed to run any script of the tenant's html.
+		c.Header("Content-Security-Policy", successTemplatePolicy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", html)
+	}
+}
+
+func GetSubscriberConsents(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		consents, err := storage.GetSubscriberConsents(id, middleware.GetUser(c).ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch subscriber consents.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch the subscriber consents, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": consents,
+		})
+	}
+}
+
+// selectSegmentIDs returns the selected segment ids, they must be a subset of the form segments.
+// All form segments are returned if none are selected.
+func selectSegmentIDs(f *entities.SignupForm, selected []int64) ([]int64, error) {
+	allowed, err := f.GetSegmentIDs()
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return allowed, nil
+	}
+
+	for _, id := range selected {
+		var ok bool
+		for _, a := range allowed {
+			ok = ok || a == id
+		}
+		if !ok {
+			return nil, fmt.Errorf("segment %d is not allowed", id)
+		}
+	}
+
+	return selected, nil
+}
+
+func createPendingSubscriber(
+	storage storage.Storage,
+	boundarysvc boundaries.Service,
+	u *entities.User,
+	body *params.PostSubscribe,
+) (*entities.Subscriber, error) {
+	limitexceeded, _, err := boundarysvc.SubscribersLimitExceeded(u)
+	if err != nil {
+		return nil, fmt.Errorf("check subscribers limit: %w", err)
+	}
+	if limitexceeded {
+		return nil, errors.New("subscribers limit exceeded")
+	}
+
+	meta, err := json.Marshal(body.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("encode metadata: %w", err)
+	}
+
+	s := &entities.Subscriber{
+		UserID:   u.ID,
+		Name:     body.Name,
+		Email:    body.Email,
+		MetaJSON: meta,
+		Active:   false,
+	}
+
+	err = storage.CreateSubscriber(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// createSubscriberConsent creates the confirmation token and records the consent of the subscriber.
+func createSubscriberConsent(
+	c *gin.Context,
+	storage storage.Storage,
+	sub *entities.Subscriber,
+	segmentIDs []int64,
+) (string, error) {
+	token, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("gen token: %w", err)
+	}
+
+	t := &entities.Token{
+		UserID:    sub.UserID,
+		Token:     token,
+		Type:      entities.ConfirmSubscriptionTokenType,
+		ExpiresAt: time.Now().UTC().Add(confirmSubscriptionTTL),
+	}
+
+	err = storage.CreateToken(t)
+	if err != nil {
+		return "", fmt.Errorf("create token: %w", err)
+	}
+
+	ids, err := json.Marshal(segmentIDs)
+	if err != nil {
+		return "", fmt.Errorf("encode segment ids: %w", err)
+	}
+
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 191 {
+		userAgent = userAgent[:191]
+	}
+
+	err = storage.CreateSubscriberConsent(&entities.SubscriberConsent{
+		UserID:       sub.UserID,
+		SubscriberID: sub.ID,
+		TokenID:      t.ID,
+		SegmentIDs:   ids,
+		SignupIP:     c.ClientIP(),
+		UserAgent:    userAgent,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create consent: %w", err)
+	}
+
+	return token, nil
+}
+
+// sendConfirmSubscription sends the confirmation email using the user's confirmation
+// template, or the default one if it is not set.
+func sendConfirmSubscription(
+	ctx context.Context,
+	templatesvc templatesvc.Service,
+	sender emails.Sender,
+	f *entities.SignupForm,
+	sub *entities.Subscriber,
+	token string,
+	systemEmailSource string,
+	appURL string,
+) error {
+	url := fmt.Sprintf("%s/api/subscribe/confirm/%s", appURL, token)
+
+	msg := &emails.Message{
+		From:    fmt.Sprintf("%s <%s>", "Mailbadger.io", systemEmailSource),
+		To:      []string{sub.Email},
+		Subject: "Confirm your subscription",
+	}
+
+	if f.ConfirmTemplateID != nil {
+		tmpl, err := templatesvc.ParseTemplate(ctx, *f.ConfirmTemplateID, f.UserID)
+		if err != nil {
+			return fmt.Errorf("send confirm subscription: parse template: %w", err)
+		}
+
+		m := subscriberTemplateData(sub)
+		m[entities.TagConfirmUrl] = url
+
+		var html, subject, text bytes.Buffer
+		if err := tmpl.HTMLPart.FRender(&html, m); err != nil {
+			return fmt.Errorf("send confirm subscription: render html: %w", err)
+		}
+		if err := tmpl.SubjectPart.FRender(&subject, m); err != nil {
+			return fmt.Errorf("send confirm subscription: render subject: %w", err)
+		}
+		if err := tmpl.TextPart.FRender(&text, m); err != nil {
+			return fmt.Errorf("send confirm subscription: render text: %w", err)
+		}
+
+		msg.HTML = html.Bytes()
+		msg.Subject = subject.String()
+		msg.Text = text.Bytes()
+	} else {
+		var html bytes.Buffer
+		err := templates.GetEmailTemplates().ExecuteTemplate(&html, "confirm-subscription.html", map[string]string{
+			"url": url,
+		})
+		if err != nil {
+			return fmt.Errorf("send confirm subscription: exec template: %w", err)
+		}
+		msg.HTML = html.Bytes()
+	}
+
+	_, err := sender.Send(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("send confirm subscription: %w", err)
+	}
+
+	return nil
+}
+
+// successTemplatePolicy sandboxes the success page, the scripts, forms and plugins are blocked
+// while the styles and the images of the template are still loaded.
+const successTemplatePolicy = "sandbox allow-popups allow-popups-to-escape-sandbox; default-src 'none'; " +
+	"style-src 'unsafe-inline' https:; img-src https: data:; font-src https:"
+
+// renderSuccessTemplate renders the success template of the signup form for the subscriber,
+// the html is sanitized since it's served from the app origin.
+func renderSuccessTemplate(
+	ctx context.Context,
+	storage storage.Storage,
+	svc templatesvc.Service,
+	f *entities.SignupForm,
+	subscriberID int64,
+) ([]byte, error) {
+	sub, err := storage.GetSubscriber(subscriberID, f.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get subscriber: %w", err)
+	}
+
+	tmpl, err := svc.ParseTemplate(ctx, *f.SuccessTemplateID, f.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	var html bytes.Buffer
+	err = tmpl.HTMLPart.FRender(&html, subscriberTemplateData(sub))
+	if err != nil {
+		return nil, fmt.Errorf("render html: %w", err)
+	}
+
+	return []byte(templatesvc.SanitizeHTML(html.String())), nil
+}
+
+// subscriberTemplateData returns the metadata of the subscriber along with the name and email.
+func subscriberTemplateData(sub *entities.Subscriber) map[string]string {
+	m, err := sub.GetMetadata()
+	if err != nil {
+		logrus.WithError(err).WithField("subscriber_id", sub.ID).Warn("unable to decode subscriber metadata")
+		m = make(map[string]string)
+	}
+	m[entities.TagName] = sub.Name
+	m["email"] = sub.Email
+	return m
+}
@@ -0,0 +1,220 @@
+package actions_test
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+	"github.com/stretchr/testify/mock"
+)
+
+var confirmURLRegex = regexp.MustCompile(`/api/subscribe/confirm/[^"]+`)
+
+func TestSignupForm(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Twice().Return(&s3.PutObjectAclOutput{}, nil)
+
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+	confirmURLs := make(chan string, 1)
+	mockSender.On("Send", mock.Anything, mock.AnythingOfType("*emails.Message")).Run(func(args mock.Arguments) {
+		msg := args.Get(1).(*emails.Message)
+		confirmURLs <- confirmURLRegex.FindString(string(msg.HTML))
+	}).Return("", nil)
+
+	templatesvc := templates.New(s, &objectsS3{MockS3Client: mockS3}, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	uuid := auth.GET("/api/users/me").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("uuid").String().Raw()
+
+	auth.GET("/api/signup-form").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("enabled", false).
+		Value("subscribe_url").String().Contains(uuid)
+
+	// the form is disabled by default
+	e.POST("/api/subscribe/" + uuid).WithJSON(params.PostSubscribe{Email: "jane@example.com"}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.PUT("/api/signup-form").WithJSON(params.PutSignupForm{Enabled: true, SegmentIDs: []int64{1}}).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("errors", map[string]string{"segment_ids": "Unable to find the specified segments."})
+
+	segID := int64(auth.POST("/api/segments").WithJSON(params.Segment{Name: "newsletter"}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw())
+
+	successID := int64(auth.POST("/api/templates").WithJSON(params.PostTemplate{
+		Name:        "subscribed",
+		HTMLPart:    `<p onclick="alert(1)">Thanks {{name}}</p><script>alert(document.cookie)</script>`,
+		TextPart:    "Thanks {{name}}",
+		SubjectPart: "Subscribed",
+	}).Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw())
+
+	auth.PUT("/api/signup-form").WithJSON(params.PutSignupForm{Enabled: true, SegmentIDs: []int64{segID}, SuccessTemplateID: &successID}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("enabled", true).
+		ValueEqual("segment_ids", []int64{segID})
+
+	e.POST("/api/subscribe/"+uuid).WithJSON(params.PostSubscribe{Email: "jane"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("errors", map[string]string{"email": "Invalid email format"})
+
+	e.POST("/api/subscribe/" + uuid).WithJSON(params.PostSubscribe{Email: "jane@example.com", SegmentIDs: []int64{segID + 1}}).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	e.POST("/api/subscribe/"+uuid).
+		WithFormField("email", "jane@example.com").
+		WithFormField("name", "Jane").
+		WithHeader("User-Agent", "test-agent").
+		Expect().
+		Status(http.StatusOK)
+
+	var confirmURL string
+	select {
+	case confirmURL = <-confirmURLs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("confirmation email was not sent")
+	}
+
+	// the subscriber is not active until confirmed
+	sub := auth.GET("/api/subscribers/1").
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+	sub.ValueEqual("email", "jane@example.com").
+		ValueEqual("name", "Jane").
+		ValueEqual("active", false)
+
+	e.GET("/api/subscribe/confirm/invalid").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// the landing page doesn't confirm the subscription
+	e.GET(confirmURL).
+		Expect().
+		Status(http.StatusOK).
+		ContentType("text/html").
+		Body().Contains(`method="post"`)
+
+	auth.GET("/api/subscribers/1").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("active", false)
+
+	e.POST("/api/subscribe/confirm/invalid").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// the success template is sanitized and sandboxed, since it's served from the app origin
+	success := e.POST(confirmURL).
+		Expect().
+		Status(http.StatusOK).
+		ContentType("text/html")
+	success.Header("Content-Security-Policy").Contains("sandbox")
+	success.Body().Contains("Thanks Jane").
+		NotContains("<script").
+		NotContains("onclick")
+
+	// the token can be used only once
+	e.POST(confirmURL).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.GET(confirmURL).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	auth.GET("/api/subscribers/1").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("active", true)
+
+	auth.GET(fmt.Sprintf("/api/segments/%d", segID)).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("subscribers_in_segment", 1)
+
+	consent := auth.GET("/api/subscribers/1/consents").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().First().Object()
+	consent.ValueEqual("user_agent", "test-agent").
+		ValueEqual("segment_ids", []int64{segID})
+	consent.Value("confirmed_at").NotNull()
+
+	// the captcha can't be verified without the secret, so the subscription is rejected
+	auth.PUT("/api/signup-form").WithJSON(params.PutSignupForm{Enabled: true, Recaptcha: true, SegmentIDs: []int64{segID}}).
+		Expect().
+		Status(http.StatusOK)
+
+	e.POST("/api/subscribe/" + uuid).WithJSON(params.PostSubscribe{Email: "john@example.com"}).
+		Expect().
+		Status(http.StatusForbidden)
+}
@@ -0,0 +1,815 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sso"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/utils"
+	"github.com/mailbadger/app/validator"
+)
+
+// GetSSOConnections returns the single sign-on connections of the account.
+func GetSSOConnections(storage storage.Storage, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conns, err := storage.GetSSOConnections(middleware.GetUser(c).ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch sso connections.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch sso connections. Please try again.",
+			})
+			return
+		}
+
+		for i := range conns {
+			presentSSOConnection(&conns[i], appURL)
+		}
+
+		c.JSON(http.StatusOK, conns)
+	}
+}
+
+// GetSSOConnection returns the connection along with the urls which are configured in the identity provider.
+func GetSSOConnection(storage storage.Storage, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, ok := ssoConnectionFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		presentSSOConnection(conn, appURL)
+
+		c.JSON(http.StatusOK, conn)
+	}
+}
+
+// PostSSOConnection creates a single sign-on connection for the email domain. The users who sign in
+// with the connection become members of the team of the account, so the team must exist.
+func PostSSOConnection(storage storage.Storage, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := ssoTeamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		conn := &entities.SSOConnection{
+			UserID: middleware.GetUser(c).ID,
+		}
+		if !bindSSOConnection(c, storage, t, conn) {
+			return
+		}
+
+		err := storage.CreateSSOConnection(conn)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create sso connection.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create sso connection. Please try again.",
+			})
+			return
+		}
+
+		presentSSOConnection(conn, appURL)
+
+		c.JSON(http.StatusCreated, conn)
+	}
+}
+
+// PutSSOConnection edits the connection, the client secret is kept when it's omitted.
+func PutSSOConnection(storage storage.Storage, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := ssoTeamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		conn, ok := ssoConnectionFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		if !bindSSOConnection(c, storage, t, conn) {
+			return
+		}
+
+		err := storage.UpdateSSOConnection(conn)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to update sso connection.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update sso connection. Please try again.",
+			})
+			return
+		}
+
+		presentSSOConnection(conn, appURL)
+
+		c.JSON(http.StatusOK, conn)
+	}
+}
+
+// VerifySSOConnection verifies the ownership of the domain of the connection with the TXT record
+// which holds the verification token. A domain can be verified only by a single connection.
+func VerifySSOConnection(storage storage.Storage, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, ok := ssoConnectionFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		if !conn.Verified() {
+			other, err := storage.GetSSOConnectionByDomain(conn.Domain)
+			if err == nil && other.ID != conn.ID {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"message": "The domain is already used by another connection.",
+				})
+				return
+			}
+
+			err = sso.VerifyDomain(c, conn.Domain, conn.VerificationToken)
+			if err != nil {
+				if errors.Is(err, sso.ErrDomainNotVerified) {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{
+						"message": "The TXT record was not found on the domain, please add it and try again.",
+					})
+					return
+				}
+
+				logger.From(c).WithError(err).WithField("sso_connection_id", conn.ID).Warn("SSO: unable to verify domain")
+				c.JSON(http.StatusBadGateway, gin.H{
+					"message": "Unable to look up the TXT records of the domain. Please try again.",
+				})
+				return
+			}
+
+			conn.VerifiedAt = entities.TimeFrom(time.Now())
+			err = storage.UpdateSSOConnection(conn)
+			if err != nil {
+				logger.From(c).WithError(err).Error("Unable to update sso connection.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to update sso connection. Please try again.",
+				})
+				return
+			}
+		}
+
+		presentSSOConnection(conn, appURL)
+
+		c.JSON(http.StatusOK, conn)
+	}
+}
+
+// DeleteSSOConnection deletes the connection, the provisioned users remain members of the team.
+func DeleteSSOConnection(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, ok := ssoConnectionFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		err := storage.DeleteSSOConnection(conn.ID, conn.UserID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete sso connection.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to delete sso connection. Please try again.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetSSOAuth finds the verified connection of the email domain and redirects the user to the identity provider.
+func GetSSOAuth(storage storage.Storage, sess session.Session, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := strings.TrimSpace(c.Query("email"))
+		i := strings.LastIndex(email, "@")
+		if i < 1 {
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=sso-not-configured")
+			return
+		}
+
+		conn, err := storage.GetSSOConnectionByDomain(strings.ToLower(email[i+1:]))
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithError(err).Error("SSO: unable to fetch connection by domain")
+				c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+				return
+			}
+
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=sso-not-configured")
+			return
+		}
+
+		switch conn.Protocol {
+		case entities.SSOProtocolSAML:
+			url, id, err := samlServiceProvider(conn, appURL).AuthnRequestURL(time.Now())
+			if err != nil {
+				logger.From(c).WithError(err).Error("SAML: unable to create authentication request")
+				c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+				return
+			}
+
+			session := sessions.Default(c)
+
+			// the identity provider posts the response from its own site, so the cookie must be sent
+			// with the cross-site requests, which the browsers allow only for the secure cookies.
+			opts := sessions.Options{
+				HttpOnly: true,
+				Secure:   sess.Secure,
+				Path:     "/api",
+			}
+			if sess.Secure {
+				opts.SameSite = http.SameSiteNoneMode
+			}
+			session.Options(opts)
+			session.Set("saml_request_id", id)
+			err = session.Save()
+			if err != nil {
+				logger.From(c).WithError(err).Error("SAML: unable to save session")
+				c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+				return
+			}
+
+			c.Redirect(http.StatusTemporaryRedirect, url)
+		case entities.SSOProtocolOIDC:
+			state, err := utils.GenerateRandomString(12)
+			if err != nil {
+				logger.From(c).WithError(err).Error("OIDC: unable to generate random string")
+				c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+				return
+			}
+			nonce, err := utils.GenerateRandomString(16)
+			if err != nil {
+				logger.From(c).WithError(err).Error("OIDC: unable to generate random string")
+				c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+				return
+			}
+			verifier, err := utils.GenerateRandomString(32)
+			if err != nil {
+				logger.From(c).WithError(err).Error("OIDC: unable to generate random string")
+				c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+				return
+			}
+
+			session := sessions.Default(c)
+
+			session.Set("state", state)
+			session.Set("nonce", nonce)
+			session.Set("code_verifier", verifier)
+			err = session.Save()
+			if err != nil {
+				logger.From(c).WithError(err).Error("OIDC: unable to save session")
+				c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+				return
+			}
+
+			c.Redirect(http.StatusTemporaryRedirect, oidcClient(conn, appURL).AuthCodeURL(state, nonce, verifier))
+		default:
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=sso-not-configured")
+		}
+	}
+}
+
+// GetSAMLMetadata returns the metadata of our service provider, which is imported in the identity provider.
+func GetSAMLMetadata(storage storage.Storage, appURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, ok := ssoConnectionFromPath(c, storage, entities.SSOProtocolSAML)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "SSO connection not found.",
+			})
+			return
+		}
+
+		c.Data(http.StatusOK, "application/samlmetadata+xml", samlServiceProvider(conn, appURL).Metadata())
+	}
+}
+
+// SAMLCallback validates the response which the identity provider posts to the assertion consumer
+// service and signs in the user of the assertion. The response must be for the authentication
+// request which was made in the session of the user.
+func SAMLCallback(
+	storage storage.Storage,
+	sess session.Session,
+	boundarysvc boundaries.Service,
+	appURL string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, ok := ssoConnectionFromPath(c, storage, entities.SSOProtocolSAML)
+		if !ok {
+			c.Redirect(http.StatusSeeOther, appURL+"/login?message=sso-not-configured")
+			return
+		}
+
+		session := sessions.Default(c)
+
+		requestID, _ := session.Get("saml_request_id").(string)
+		session.Delete("saml_request_id")
+
+		a, err := samlServiceProvider(conn, appURL).ParseResponse(c.PostForm("SAMLResponse"), requestID, time.Now())
+		if err != nil {
+			logger.From(c).WithError(err).WithField("sso_connection_id", conn.ID).Warn("SAML: invalid response")
+			c.Redirect(http.StatusSeeOther, appURL+"/login?message=sso-failed")
+			return
+		}
+
+		email := a.NameID
+		if conn.EmailAttribute != "" {
+			email = ""
+			if v := a.Attributes[conn.EmailAttribute]; len(v) > 0 {
+				email = v[0]
+			}
+		}
+
+		completeSSOCallback(c, storage, sess, boundarysvc, conn, email, a.Attributes[conn.RoleAttribute], appURL)
+	}
+}
+
+// OIDCCallback exchanges the authorization code for the id token of the user and signs in the user.
+func OIDCCallback(
+	storage storage.Storage,
+	sess session.Session,
+	boundarysvc boundaries.Service,
+	appURL string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, ok := ssoConnectionFromPath(c, storage, entities.SSOProtocolOIDC)
+		if !ok {
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=sso-not-configured")
+			return
+		}
+
+		session := sessions.Default(c)
+
+		state, ok := session.Get("state").(string)
+		nonce, _ := session.Get("nonce").(string)
+		verifier, _ := session.Get("code_verifier").(string)
+		if !ok || nonce == "" || verifier == "" {
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+			return
+		}
+
+		session.Clear()
+
+		if state != c.Query("state") {
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=server-error")
+			return
+		}
+
+		client := oidcClient(conn, appURL)
+
+		raw, err := client.Exchange(c, c.Query("code"), verifier)
+		if err != nil {
+			logger.From(c).WithError(err).WithField("sso_connection_id", conn.ID).Warn("OIDC: unable to exchange code")
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=sso-failed")
+			return
+		}
+
+		claims, err := client.VerifyIDToken(c, raw, nonce, time.Now())
+		if err != nil {
+			logger.From(c).WithError(err).WithField("sso_connection_id", conn.ID).Warn("OIDC: invalid id token")
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=sso-failed")
+			return
+		}
+
+		if !claims.EmailVerified() {
+			c.Redirect(http.StatusTemporaryRedirect, appURL+"/login?message=sso-forbidden")
+			return
+		}
+
+		attr := conn.EmailAttribute
+		if attr == "" {
+			attr = "email"
+		}
+
+		completeSSOCallback(c, storage, sess, boundarysvc, conn, claims.String(attr), claims.Values(conn.RoleAttribute), appURL)
+	}
+}
+
+// completeSSOCallback signs in the user of the verified connection. The existing users must be the owner or
+// members of the team of the account, the new users are provisioned as members of the team with
+// the role which is mapped from the values of the role attribute.
+func completeSSOCallback(
+	c *gin.Context,
+	storage storage.Storage,
+	sess session.Session,
+	boundarysvc boundaries.Service,
+	conn *entities.SSOConnection,
+	email string,
+	roleValues []string,
+	appURL string,
+) {
+	status := http.StatusTemporaryRedirect
+	if c.Request.Method == http.MethodPost {
+		status = http.StatusSeeOther
+	}
+
+	log := logger.From(c).WithField("sso_connection_id", conn.ID)
+
+	if !conn.Verified() {
+		log.Warn("SSO: the domain of the connection is not verified")
+		c.Redirect(status, appURL+"/login?message=sso-not-configured")
+		return
+	}
+
+	email = strings.TrimSpace(email)
+	if !conn.MatchesEmail(email) {
+		log.WithField("email", email).Warn("SSO: the email does not belong to the domain of the connection")
+		c.Redirect(status, appURL+"/login?message=sso-forbidden")
+		return
+	}
+
+	owner, err := storage.GetUser(conn.UserID)
+	if err != nil {
+		log.WithError(err).Error("SSO: unable to fetch the owner of the connection")
+		c.Redirect(status, appURL+"/login?message=server-error")
+		return
+	}
+	if owner.Boundaries == nil || !owner.Boundaries.SAMLEnabled {
+		log.Warn("SSO: single sign-on is not available on the plan of the account")
+		c.Redirect(status, appURL+"/login?message=sso-forbidden")
+		return
+	}
+
+	t, err := storage.GetTeam(owner.ID)
+	if err != nil {
+		log.WithError(err).Error("SSO: unable to fetch the team of the connection")
+		c.Redirect(status, appURL+"/login?message=server-error")
+		return
+	}
+
+	role, err := storage.GetTeamRole(conn.RoleID(roleValues), t.ID)
+	if err != nil {
+		log.WithError(err).Error("SSO: unable to fetch the mapped team role")
+		c.Redirect(status, appURL+"/login?message=server-error")
+		return
+	}
+
+	var member *entities.TeamMember
+	u, err := storage.GetUserByUsername(email)
+	switch {
+	case err == nil:
+		if u.ID == owner.ID {
+			break
+		}
+		member, err = storage.GetTeamMember(t.ID, u.ID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.WithError(err).Error("SSO: unable to fetch team member")
+				c.Redirect(status, appURL+"/login?message=server-error")
+				return
+			}
+
+			log.WithField("user_id", u.ID).Warn("SSO: the user is not a member of the team")
+			c.Redirect(status, appURL+"/login?message=sso-forbidden")
+			return
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		limitexceeded, err := boundarysvc.TeamMembersLimitExceeded(owner, t.ID)
+		if err != nil {
+			log.WithError(err).Error("SSO: unable to check team members limit")
+			c.Redirect(status, appURL+"/login?message=server-error")
+			return
+		}
+		if limitexceeded {
+			c.Redirect(status, appURL+"/login?message=team-members-limit-exceeded")
+			return
+		}
+	default:
+		log.WithError(err).Error("SSO: unable to fetch user by username")
+		c.Redirect(status, appURL+"/login?message=server-error")
+		return
+	}
+
+	completeCallback(c, storage, sess, email, conn.Protocol, appURL, func(u *entities.User) error {
+		if u.ID == owner.ID {
+			return nil
+		}
+
+		if member == nil {
+			return storage.CreateTeamMember(&entities.TeamMember{
+				TeamID: t.ID,
+				UserID: u.ID,
+				RoleID: role.ID,
+			})
+		}
+
+		// the role of the existing members is synced only when it's mapped from the attributes,
+		// otherwise the role which was assigned in the team is kept.
+		if conn.RoleAttribute != "" && member.RoleID != role.ID {
+			member.RoleID = role.ID
+			return storage.UpdateTeamMember(member)
+		}
+
+		return nil
+	})
+}
+
+// ssoTeamFromContext returns the team of the account, the plan of the account must include single sign-on.
+func ssoTeamFromContext(c *gin.Context, storage storage.Storage) (*entities.Team, bool) {
+	u := middleware.GetUser(c)
+	if u.Boundaries == nil || !u.Boundaries.SAMLEnabled {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Single sign-on is not available on your plan, please upgrade to a bigger plan or contact support.",
+		})
+		return nil, false
+	}
+
+	t, err := storage.GetTeam(u.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Create a team before configuring single sign-on.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch team.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch team. Please try again.",
+		})
+		return nil, false
+	}
+
+	return t, true
+}
+
+func ssoConnectionFromParam(c *gin.Context, storage storage.Storage) (*entities.SSOConnection, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	conn, err := storage.GetSSOConnection(id, middleware.GetUser(c).ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "SSO connection not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch sso connection.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch sso connection. Please try again.",
+		})
+		return nil, false
+	}
+
+	return conn, true
+}
+
+// ssoConnectionFromPath returns the connection of the sign in routes, which are not scoped to a user.
+func ssoConnectionFromPath(c *gin.Context, storage storage.Storage, protocol string) (*entities.SSOConnection, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	conn, err := storage.GetSSOConnectionByID(id)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.From(c).WithError(err).Error("SSO: unable to fetch connection")
+		}
+		return nil, false
+	}
+
+	return conn, conn.Protocol == protocol
+}
+
+// bindSSOConnection binds the request body to the connection. The settings of the identity provider
+// are imported from the metadata or the discovery url when they are given.
+func bindSSOConnection(c *gin.Context, storage storage.Storage, t *entities.Team, conn *entities.SSOConnection) bool {
+	body := &params.SSOConnection{}
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again.",
+		})
+		return false
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return false
+	}
+
+	roleIDs := []int64{body.DefaultRoleID}
+	for _, m := range body.RoleMappings {
+		roleIDs = append(roleIDs, m.RoleID)
+	}
+	for _, id := range roleIDs {
+		_, err := storage.GetTeamRole(id, t.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The role does not exist.",
+			})
+			return false
+		}
+	}
+
+	other, err := storage.GetSSOConnectionByDomain(body.Domain)
+	if err == nil && other.ID != conn.ID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "The domain is already used by another connection.",
+		})
+		return false
+	}
+
+	// the ownership of a new domain must be verified again
+	if conn.Domain != body.Domain || conn.VerificationToken == "" {
+		token, err := utils.GenerateRandomString(24)
+		if err != nil {
+			logger.From(c).WithError(err).Error("SSO: unable to generate verification token")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to save sso connection. Please try again.",
+			})
+			return false
+		}
+		conn.VerificationToken = token
+		conn.VerifiedAt = entities.NullTime{}
+	}
+
+	conn.Protocol = body.Protocol
+	conn.Domain = body.Domain
+	conn.Enforced = body.Enforced
+	conn.DefaultRoleID = body.DefaultRoleID
+	conn.RoleAttribute = body.RoleAttribute
+	conn.RoleMappings = body.RoleMappings
+	conn.EmailAttribute = body.EmailAttribute
+
+	switch body.Protocol {
+	case entities.SSOProtocolSAML:
+		return bindSAMLIdentityProvider(c, body, conn)
+	case entities.SSOProtocolOIDC:
+		return bindOIDCProvider(c, body, conn)
+	}
+
+	return true
+}
+
+func bindSAMLIdentityProvider(c *gin.Context, body *params.SSOConnection, conn *entities.SSOConnection) bool {
+	var (
+		idp = &sso.SAMLIdentityProvider{
+			EntityID:    body.IdPEntityID,
+			SSOURL:      body.IdPSSOURL,
+			Certificate: body.IdPCertificate,
+		}
+		err error
+	)
+	if body.Metadata != "" {
+		idp, err = sso.ParseSAMLMetadata([]byte(body.Metadata))
+	} else if body.MetadataURL != "" {
+		idp, err = sso.FetchSAMLMetadata(c, body.MetadataURL)
+	}
+	if err != nil {
+		logger.From(c).WithError(err).Warn("SSO: unable to import saml metadata")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unable to import the metadata of the identity provider.",
+		})
+		return false
+	}
+
+	if idp.EntityID == "" || idp.SSOURL == "" || idp.Certificate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "The entity id, the single sign-on url and the certificate of the identity provider are required.",
+		})
+		return false
+	}
+
+	if _, err := sso.ParseCertificates(idp.Certificate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "The certificate of the identity provider is invalid.",
+		})
+		return false
+	}
+
+	conn.IdPEntityID = idp.EntityID
+	conn.IdPSSOURL = idp.SSOURL
+	conn.IdPCertificate = idp.Certificate
+	conn.Issuer = ""
+	conn.AuthorizationURL = ""
+	conn.TokenURL = ""
+	conn.JWKSURL = ""
+	conn.ClientID = ""
+	conn.ClientSecret = ""
+
+	return true
+}
+
+func bindOIDCProvider(c *gin.Context, body *params.SSOConnection, conn *entities.SSOConnection) bool {
+	var (
+		p = &sso.OIDCProvider{
+			Issuer:           body.Issuer,
+			AuthorizationURL: body.AuthorizationURL,
+			TokenURL:         body.TokenURL,
+			JWKSURL:          body.JWKSURL,
+		}
+		err error
+	)
+	if body.DiscoveryURL != "" {
+		p, err = sso.DiscoverOIDC(c, body.DiscoveryURL)
+		if err != nil {
+			logger.From(c).WithError(err).Warn("SSO: unable to discover openid provider")
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to import the configuration of the OpenID Connect provider.",
+			})
+			return false
+		}
+	}
+
+	if p.Issuer == "" || p.AuthorizationURL == "" || p.TokenURL == "" || p.JWKSURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "The issuer, the authorization, token and jwks urls of the provider are required.",
+		})
+		return false
+	}
+
+	if body.ClientSecret != "" {
+		conn.ClientSecret = body.ClientSecret
+	}
+	if body.ClientID == "" || conn.ClientSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "The client id and the client secret are required.",
+		})
+		return false
+	}
+
+	conn.Issuer = p.Issuer
+	conn.AuthorizationURL = p.AuthorizationURL
+	conn.TokenURL = p.TokenURL
+	conn.JWKSURL = p.JWKSURL
+	conn.ClientID = body.ClientID
+	conn.IdPEntityID = ""
+	conn.IdPSSOURL = ""
+	conn.IdPCertificate = ""
+
+	return true
+}
+
+// presentSSOConnection sets the status of the connection, the TXT record which verifies its domain
+// and the urls of our service provider.
+func presentSSOConnection(conn *entities.SSOConnection, appURL string) {
+	conn.Status = entities.SSOConnectionStatusPending
+	if conn.Verified() {
+		conn.Status = entities.SSOConnectionStatusVerified
+	}
+	conn.VerificationRecord = sso.DomainVerificationRecord(conn.VerificationToken)
+	setSSOConnectionURLs(conn, appURL)
+}
+
+// setSSOConnectionURLs sets the urls of our service provider which are configured in the identity provider.
+func setSSOConnectionURLs(conn *entities.SSOConnection, appURL string) {
+	switch conn.Protocol {
+	case entities.SSOProtocolSAML:
+		conn.SPEntityID = fmt.Sprintf("%s/api/auth/saml/%d/metadata", appURL, conn.ID)
+		conn.SPACSURL = fmt.Sprintf("%s/api/auth/saml/%d/acs", appURL, conn.ID)
+	case entities.SSOProtocolOIDC:
+		conn.RedirectURL = fmt.Sprintf("%s/api/auth/oidc/%d/callback", appURL, conn.ID)
+	}
+}
+
+func samlServiceProvider(conn *entities.SSOConnection, appURL string) sso.SAMLServiceProvider {
+	setSSOConnectionURLs(conn, appURL)
+	return sso.SAMLServiceProvider{
+		EntityID: conn.SPEntityID,
+		ACSURL:   conn.SPACSURL,
+		IdP: sso.SAMLIdentityProvider{
+			EntityID:    conn.IdPEntityID,
+			SSOURL:      conn.IdPSSOURL,
+			Certificate: conn.IdPCertificate,
+		},
+	}
+}
+
+func oidcClient(conn *entities.SSOConnection, appURL string) sso.OIDCClient {
+	setSSOConnectionURLs(conn, appURL)
+	return sso.OIDCClient{
+		Provider: sso.OIDCProvider{
+			Issuer:           conn.Issuer,
+			AuthorizationURL: conn.AuthorizationURL,
+			TokenURL:         conn.TokenURL,
+			JWKSURL:          conn.JWKSURL,
+		},
+		ClientID:     conn.ClientID,
+		ClientSecret: conn.ClientSecret,
+		RedirectURL:  conn.RedirectURL,
+	}
+}
@@ -0,0 +1,555 @@
+package actions_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/sso"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+// oidcProvider is a fake OpenID Connect provider which issues the id tokens with the given claims.
+type oidcProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+}
+
+func newOIDCProvider(t *testing.T) *oidcProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &oidcProvider{key: key}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 p.URL,
+				"authorization_endpoint": p.URL + "/authorize",
+				"token_endpoint":         p.URL + "/token",
+				"jwks_uri":               p.URL + "/jwks",
+			})
+		case "/jwks":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"keys": []map[string]string{
+					{
+						"kty": "RSA",
+						"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+						"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+					},
+				},
+			})
+		case "/token":
+			id, secret, ok := r.BasicAuth()
+			if !ok || id != "client" || secret != "secret" || r.FormValue("code") != "foo" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"access_token": "bar",
+				"token_type":   "Bearer",
+				"id_token":     p.idToken(t),
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return p
+}
+
+func (p *oidcProvider) idToken(t *testing.T) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(p.claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hashed := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// signInWithOIDC goes through the sign in with the fake provider and returns the callback response.
+func signInWithOIDC(e *httpexpect.Expect, p *oidcProvider, email string, claims map[string]interface{}) *httpexpect.Response {
+	res := e.GET("/api/auth/sso").WithQuery("email", email).
+		Expect().
+		Status(http.StatusTemporaryRedirect)
+
+	u, err := url.Parse(res.Header("Location").Raw())
+	if err != nil {
+		panic(err)
+	}
+
+	if _, ok := claims["email_verified"]; !ok {
+		claims["email_verified"] = true
+	}
+	claims["iss"] = p.URL
+	claims["aud"] = "client"
+	claims["nonce"] = u.Query().Get("nonce")
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	p.claims = claims
+
+	c := res.Cookie("mbsess")
+	return e.GET(strings.TrimPrefix(u.Query().Get("redirect_uri"), "http://example.com")).
+		WithQuery("code", "foo").
+		WithQuery("state", u.Query().Get("state")).
+		WithCookie(c.Name().Raw(), c.Value().Raw()).
+		Expect()
+}
+
+func samlMetadata(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.saml.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fmt.Sprintf(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="https://idp.saml.com">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.saml.com/sso"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`, base64.StdEncoding.EncodeToString(der))
+}
+
+func TestSSO(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+	mockSender.On("Send", mock.Anything, mock.AnythingOfType("*emails.Message")).Return("", nil)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	newExpect := func() *httpexpect.Expect {
+		return setup(
+			t, s,
+			sess,
+			mockS3,
+			mockPub,
+			mockSender,
+			templatesvc,
+			boundarysvc,
+			subscrsvc,
+			reportsvc,
+			compiler,
+			false, // enable signup
+			false, // verify email
+		)
+	}
+
+	auth, err := createAuthenticatedExpect(newExpect(), s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	guest := newExpect()
+
+	p := newOIDCProvider(t)
+	defer p.Close()
+
+	// the TXT records of the domains
+	records := make(map[string]string)
+	lookup := sso.LookupTXT
+	defer func() { sso.LookupTXT = lookup }()
+	sso.LookupTXT = func(_ context.Context, domain string) ([]string, error) {
+		return []string{records[domain]}, nil
+	}
+
+	auth.POST("/api/sso-connections").WithJSON(params.SSOConnection{
+		Protocol:      entities.SSOProtocolOIDC,
+		Domain:        "acme.com",
+		DefaultRoleID: 1,
+		DiscoveryURL:  p.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+	}).Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "Create a team before configuring single sign-on.")
+
+	teamID := int64(auth.POST("/api/team").WithJSON(params.PostTeam{Name: "Acme"}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw())
+
+	editor, err := s.GetTeamRoleByName(entities.TeamRoleEditor, teamID)
+	assert.Nil(t, err)
+	viewer, err := s.GetTeamRoleByName(entities.TeamRoleViewer, teamID)
+	assert.Nil(t, err)
+
+	oidcParams := params.SSOConnection{
+		Protocol:      entities.SSOProtocolOIDC,
+		Domain:        "Acme.com",
+		DefaultRoleID: viewer.ID,
+		RoleAttribute: "groups",
+		RoleMappings:  entities.SSORoleMappings{{Value: "marketing", RoleID: editor.ID}},
+		DiscoveryURL:  p.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+	}
+
+	auth.POST("/api/sso-connections").WithJSON(params.SSOConnection{
+		Protocol:      "ldap",
+		Domain:        "acme.com",
+		DefaultRoleID: viewer.ID,
+	}).Expect().
+		Status(http.StatusBadRequest)
+
+	invalid := oidcParams
+	invalid.RoleMappings = entities.SSORoleMappings{{Value: "marketing", RoleID: 2223}}
+	auth.POST("/api/sso-connections").WithJSON(invalid).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The role does not exist.")
+
+	invalid = oidcParams
+	invalid.DiscoveryURL = p.URL + "/foo"
+	auth.POST("/api/sso-connections").WithJSON(invalid).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Unable to import the configuration of the OpenID Connect provider.")
+
+	invalid = oidcParams
+	invalid.ClientSecret = ""
+	auth.POST("/api/sso-connections").WithJSON(invalid).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The client id and the client secret are required.")
+
+	err = db.Model(&entities.Boundaries{}).Where("type = ?", "db_test").Update("saml_enabled", false).Error
+	assert.Nil(t, err)
+	auth.POST("/api/sso-connections").WithJSON(oidcParams).
+		Expect().
+		Status(http.StatusForbidden)
+	err = db.Model(&entities.Boundaries{}).Where("type = ?", "db_test").Update("saml_enabled", true).Error
+	assert.Nil(t, err)
+
+	oidc := auth.POST("/api/sso-connections").WithJSON(oidcParams).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("domain", "acme.com").
+		ValueEqual("issuer", p.URL).
+		ValueEqual("token_url", p.URL+"/token").
+		ValueEqual("status", entities.SSOConnectionStatusPending).
+		ValueEqual("verified_at", nil).
+		NotContainsKey("client_secret").
+		NotContainsKey("verification_token")
+	oidcID := int64(oidc.Value("id").Number().Raw())
+	oidcStr := strconv.FormatInt(oidcID, 10)
+	oidcRecord := oidc.Value("verification_record").String().Raw()
+	assert.True(t, strings.HasPrefix(oidcRecord, "mailbadger-verification="))
+
+	auth.GET("/api/sso-connections/"+oidcStr).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("redirect_url", "http://example.com/api/auth/oidc/"+oidcStr+"/callback").
+		ValueEqual("verification_record", oidcRecord)
+
+	// the users can't sign in until the domain is verified
+	guest.GET("/api/auth/sso").WithQuery("email", "jane@acme.com").
+		Expect().
+		Status(http.StatusTemporaryRedirect).
+		Header("Location").Equal("http://example.com/login?message=sso-not-configured")
+
+	auth.POST("/api/sso-connections/"+oidcStr+"/verify").
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "The TXT record was not found on the domain, please add it and try again.")
+
+	// another account can claim the domain, but it can't verify it without the record of its own connection
+	other, err := createAuthenticatedUser(newExpect(), s, "mallory@evil.com", []entities.Role{{Name: "admin"}})
+	assert.Nil(t, err)
+	otherTeamID := int64(other.POST("/api/team").WithJSON(params.PostTeam{Name: "Evil"}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw())
+	otherViewer, err := s.GetTeamRoleByName(entities.TeamRoleViewer, otherTeamID)
+	assert.Nil(t, err)
+	claim := oidcParams
+	claim.DefaultRoleID = otherViewer.ID
+	claim.RoleMappings = nil
+	claimID := int64(other.POST("/api/sso-connections").WithJSON(claim).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw())
+
+	records["acme.com"] = oidcRecord
+
+	other.POST("/api/sso-connections/" + strconv.FormatInt(claimID, 10) + "/verify").
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	auth.POST("/api/sso-connections/"+oidcStr+"/verify").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("status", entities.SSOConnectionStatusVerified).
+		Value("verified_at").NotNull()
+
+	other.POST("/api/sso-connections/"+strconv.FormatInt(claimID, 10)+"/verify").
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "The domain is already used by another connection.")
+
+	auth.POST("/api/sso-connections").WithJSON(oidcParams).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "The domain is already used by another connection.")
+
+	// the client secret is kept when it's omitted
+	update := oidcParams
+	update.ClientSecret = ""
+	auth.PUT("/api/sso-connections/" + oidcStr).WithJSON(update).
+		Expect().
+		Status(http.StatusOK)
+
+	samlParams := params.SSOConnection{
+		Protocol:      entities.SSOProtocolSAML,
+		Domain:        "saml.com",
+		DefaultRoleID: viewer.ID,
+		Metadata:      "<foo/>",
+	}
+	auth.POST("/api/sso-connections").WithJSON(samlParams).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Unable to import the metadata of the identity provider.")
+
+	samlParams.Metadata = samlMetadata(t)
+	saml := auth.POST("/api/sso-connections").WithJSON(samlParams).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("idp_entity_id", "https://idp.saml.com").
+		ValueEqual("idp_sso_url", "https://idp.saml.com/sso")
+	samlID := int64(saml.Value("id").Number().Raw())
+	samlStr := strconv.FormatInt(samlID, 10)
+	records["saml.com"] = saml.Value("verification_record").String().Raw()
+
+	auth.POST("/api/sso-connections/" + samlStr + "/verify").
+		Expect().
+		Status(http.StatusOK)
+
+	auth.GET("/api/sso-connections").
+		Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(2)
+
+	guest.GET("/api/auth/saml/" + samlStr + "/metadata").
+		Expect().
+		Status(http.StatusOK).
+		ContentType("application/samlmetadata+xml").
+		Body().Contains("http://example.com/api/auth/saml/" + samlStr + "/acs")
+
+	guest.GET("/api/auth/saml/" + oidcStr + "/metadata").
+		Expect().
+		Status(http.StatusNotFound)
+
+	samlAuth := guest.GET("/api/auth/sso").WithQuery("email", "jane@saml.com").
+		Expect().
+		Status(http.StatusTemporaryRedirect)
+	samlAuth.Header("Location").Match(`^https://idp\.saml\.com/sso\?SAMLRequest=`)
+	// the id of the request is kept in the session, which is sent with the response of the identity provider
+	assert.Equal(t, http.SameSiteNoneMode, samlAuth.Cookie("mbsess").Raw().SameSite)
+
+	// the client follows the redirect of the assertion consumer service to the login page
+	acs := guest.POST("/api/auth/saml/"+samlStr+"/acs").WithFormField("SAMLResponse", "Zm9v").
+		Expect().Raw()
+	assert.Equal(t, http.MethodGet, acs.Request.Method)
+	assert.Equal(t, "/login?message=sso-failed", acs.Request.URL.RequestURI())
+
+	auth.DELETE("/api/sso-connections/" + samlStr).
+		Expect().
+		Status(http.StatusNoContent)
+
+	guest.GET("/api/auth/sso").WithQuery("email", "jane@saml.com").
+		Expect().
+		Status(http.StatusTemporaryRedirect).
+		Header("Location").Equal("http://example.com/login?message=sso-not-configured")
+
+	// the new users are provisioned as members of the team with the mapped role
+	res := signInWithOIDC(guest, p, "jane@acme.com", map[string]interface{}{
+		"email":  "jane@acme.com",
+		"groups": []string{"marketing"},
+	})
+	res.Status(http.StatusTemporaryRedirect).
+		Header("Location").Equal("http://example.com/dashboard")
+
+	c := res.Cookie("mbsess")
+	guest.GET("/api/users/me").WithCookie(c.Name().Raw(), c.Value().Raw()).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("username", "jane@acme.com").
+		ValueEqual("source", entities.SSOProtocolOIDC)
+
+	jane, err := s.GetUserByUsername("jane@acme.com")
+	assert.Nil(t, err)
+	m, err := s.GetTeamMember(teamID, jane.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, editor.ID, m.RoleID)
+
+	// the role of the member follows the attributes
+	signInWithOIDC(guest, p, "jane@acme.com", map[string]interface{}{
+		"email": "jane@acme.com",
+	}).Status(http.StatusTemporaryRedirect).
+		Header("Location").Equal("http://example.com/dashboard")
+
+	m, err = s.GetTeamMember(teamID, jane.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, viewer.ID, m.RoleID)
+
+	signInWithOIDC(guest, p, "joe@acme.com", map[string]interface{}{
+		"email":          "joe@acme.com",
+		"email_verified": false,
+	}).Status(http.StatusTemporaryRedirect).
+		Header("Location").Equal("http://example.com/login?message=sso-forbidden")
+
+	// the email is not verified when the provider doesn't send the claim
+	signInWithOIDC(guest, p, "joe@acme.com", map[string]interface{}{
+		"email":          "joe@acme.com",
+		"email_verified": nil,
+	}).Status(http.StatusTemporaryRedirect).
+		Header("Location").Equal("http://example.com/login?message=sso-forbidden")
+
+	signInWithOIDC(guest, p, "joe@acme.com", map[string]interface{}{
+		"email": "joe@example.com",
+	}).Status(http.StatusTemporaryRedirect).
+		Header("Location").Equal("http://example.com/login?message=sso-forbidden")
+
+	// the existing users who are not members of the team can't sign in with the connection
+	_, err = createAuthenticatedUser(newExpect(), s, "bob@acme.com", nil)
+	assert.Nil(t, err)
+
+	signInWithOIDC(guest, p, "bob@acme.com", map[string]interface{}{
+		"email": "bob@acme.com",
+	}).Status(http.StatusTemporaryRedirect).
+		Header("Location").Equal("http://example.com/login?message=sso-forbidden")
+
+	bob, err := s.GetUserByUsername("bob@acme.com")
+	assert.Nil(t, err)
+	err = s.CreateTeamMember(&entities.TeamMember{TeamID: teamID, UserID: bob.ID, RoleID: viewer.ID})
+	assert.Nil(t, err)
+
+	// the members can't sign in with a password when the connection is enforced
+	guest.POST("/api/authenticate").WithJSON(params.PostAuthenticate{
+		Username: "bob@acme.com",
+		Password: "hunter1",
+	}).Expect().
+		Status(http.StatusOK)
+
+	update.Enforced = true
+	auth.PUT("/api/sso-connections/"+oidcStr).WithJSON(update).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("enforced", true)
+
+	guest.POST("/api/authenticate").WithJSON(params.PostAuthenticate{
+		Username: "bob@acme.com",
+		Password: "hunter1",
+	}).Expect().
+		Status(http.StatusForbidden).JSON().Object().
+		ValueEqual("message", "Your organization requires single sign-on, please sign in with SSO.")
+
+	// the owner is not restricted
+	guest.POST("/api/authenticate").WithJSON(params.PostAuthenticate{
+		Username: "john",
+		Password: "hunter1",
+	}).Expect().
+		Status(http.StatusOK)
+
+	// the provisioned users can't sign in with a password
+	pass, err := bcrypt.GenerateFromPassword([]byte("hunter1"), bcrypt.DefaultCost)
+	assert.Nil(t, err)
+	b, err := s.GetBoundariesByType("db_test")
+	assert.Nil(t, err)
+	err = s.CreateUser(&entities.User{
+		UUID:       uuid.New().String(),
+		Active:     true,
+		Username:   "alice@example.com",
+		Password:   sql.NullString{String: string(pass), Valid: true},
+		Source:     entities.SSOProtocolSAML,
+		Boundaries: b,
+	})
+	assert.Nil(t, err)
+
+	guest.POST("/api/authenticate").WithJSON(params.PostAuthenticate{
+		Username: "alice@example.com",
+		Password: "hunter1",
+	}).Expect().
+		Status(http.StatusForbidden)
+}
@@ -293,72 +293,101 @@ func PostUnsubscribe(
 
 		redirWithError = redirWithError + "?" + params.Encode()
 
-		u, err := storage.GetUserByUUID(body.UUID)
+		u, _, err := verifySubscriberToken(storage, unsubscribeSecret, body.UUID, body.Email, body.Token)
 		if err != nil {
 			logger.From(c).WithFields(logrus.Fields{
 				"email": body.Email,
 				"uuid":  body.UUID,
-			}).WithError(err).Error("Unsubscribe: cannot find user by uuid")
+			}).WithError(err).Warn("Unsubscribe: invalid token")
 
 			c.Redirect(http.StatusTemporaryRedirect, redirWithError)
 			return
 		}
 
-		sub, err := storage.GetSubscriberByEmail(body.Email, u.ID)
+		err = storage.DeactivateSubscriber(u.ID, body.Email)
 		if err != nil {
 			logger.From(c).WithFields(logrus.Fields{
 				"email": body.Email,
 				"uuid":  body.UUID,
-			}).WithError(err).Error("Unsubscribe: unable to fetch subscriber by email")
+			}).WithError(err).Error("Unsubscribe: unable to deactivate subscriber")
 
 			c.Redirect(http.StatusTemporaryRedirect, redirWithError)
 			return
 		}
 
-		hash, err := sub.GenerateUnsubscribeToken(unsubscribeSecret)
-		if err != nil {
-			logger.From(c).WithFields(logrus.Fields{
-				"email": body.Email,
-				"uuid":  body.UUID,
-			}).WithError(err).Error("Unsubscribe: unable to generate hash")
+		c.Redirect(http.StatusTemporaryRedirect, appURL+"/unsubscribe-success.html")
+	}
+}
 
-			c.Redirect(http.StatusTemporaryRedirect, redirWithError)
-			return
-		}
+// PostOneClickUnsubscribe handles the one-click unsubscribe requests (RFC 8058) from the
+// List-Unsubscribe header. The signed params are in the query string and the mailbox provider
+// posts "List-Unsubscribe=One-Click" as body, so the subscriber is deactivated right away.
+func PostOneClickUnsubscribe(storage storage.Storage, unsubscribeSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := c.Query("email")
+		uuid := c.Query("uuid")
+		t := c.Query("t")
 
-		if len(body.Token) != len(hash) {
-			logger.From(c).WithFields(logrus.Fields{
-				"email": body.Email,
-				"uuid":  body.UUID,
-			}).WithError(err).Warn("Unsubscribe: hashes are not the same length")
+		log := logger.From(c).WithFields(logrus.Fields{
+			"email": email,
+			"uuid":  uuid,
+		})
 
-			c.Redirect(http.StatusTemporaryRedirect, redirWithError)
+		u, sub, err := verifySubscriberToken(storage, unsubscribeSecret, uuid, email, t)
+		if err != nil {
+			log.WithError(err).Warn("One-click unsubscribe: invalid token")
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid unsubscribe token.",
+			})
 			return
 		}
 
-		if subtle.ConstantTimeCompare([]byte(body.Token), []byte(hash)) != 1 {
-			logger.From(c).WithFields(logrus.Fields{
-				"email": body.Email,
-				"uuid":  body.UUID,
-			}).WithError(err).Warn("Unsubscribe: hashes don't match.")
-
-			c.Redirect(http.StatusTemporaryRedirect, redirWithError)
-			return
+		if sub.Active {
+			err = storage.DeactivateSubscriber(u.ID, sub.Email)
+			if err != nil {
+				log.WithError(err).Error("One-click unsubscribe: unable to deactivate subscriber")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to unsubscribe, please try again.",
+				})
+				return
+			}
 		}
 
-		err = storage.DeactivateSubscriber(u.ID, body.Email)
-		if err != nil {
-			logger.From(c).WithFields(logrus.Fields{
-				"email": body.Email,
-				"uuid":  body.UUID,
-			}).WithError(err).Error("Unsubscribe: unable to deactivate subscriber")
+		c.JSON(http.StatusOK, gin.H{
+			"message": "You have been unsubscribed.",
+		})
+	}
+}
 
-			c.Redirect(http.StatusTemporaryRedirect, redirWithError)
-			return
-		}
+// verifySubscriberToken checks the signed token from the unsubscribe and the preferences urls,
+// it returns the user and the subscriber which the token belongs to.
+func verifySubscriberToken(
+	storage storage.Storage,
+	unsubscribeSecret string,
+	uuid string,
+	email string,
+	token string,
+) (*entities.User, *entities.Subscriber, error) {
+	u, err := storage.GetUserByUUID(uuid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find user by uuid: %w", err)
+	}
 
-		c.Redirect(http.StatusTemporaryRedirect, appURL+"/unsubscribe-success.html")
+	sub, err := storage.GetSubscriberByEmail(email, u.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find subscriber by email: %w", err)
+	}
+
+	hash, err := sub.GenerateUnsubscribeToken(unsubscribeSecret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate hash: %w", err)
 	}
+
+	if len(token) != len(hash) || subtle.ConstantTimeCompare([]byte(token), []byte(hash)) != 1 {
+		return nil, nil, errors.New("hashes don't match")
+	}
+
+	return u, sub, nil
 }
 
 func ImportSubscribers(
@@ -18,6 +18,7 @@ import (
 	"github.com/mailbadger/app/sqs"
 	"github.com/mailbadger/app/storage"
 	awss3 "github.com/mailbadger/app/storage/s3"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -155,6 +156,36 @@ func TestSubscribers(t *testing.T) {
 		ValueEqual("blacklisted", false).
 		ValueEqual("active", true)
 
+	// test one-click unsubscribe
+	uuid := auth.GET("/api/users/me").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("uuid").String().Raw()
+
+	u, err := s.GetUserByUUID(uuid)
+	assert.Nil(t, err)
+	sub, err := s.GetSubscriberByEmail("foo@email.com", u.ID)
+	assert.Nil(t, err)
+	token, err := sub.GenerateUnsubscribeToken("secretexmplkeythatis32characters")
+	assert.Nil(t, err)
+
+	e.POST("/api/unsubscribe/one-click").
+		WithQuery("email", "foo@email.com").WithQuery("uuid", uuid).WithQuery("t", "invalid").
+		WithFormField("List-Unsubscribe", "One-Click").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.POST("/api/unsubscribe/one-click").
+		WithQuery("email", "foo@email.com").WithQuery("uuid", uuid).WithQuery("t", token).
+		WithFormField("List-Unsubscribe", "One-Click").
+		Expect().
+		Status(http.StatusOK)
+
+	auth.GET("/api/subscribers/2").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("active", false)
+
 	// delete subscriber by id
 	auth.DELETE("/api/subscribers/1").
 		Expect().
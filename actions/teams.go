@@ -0,0 +1,703 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/templates"
+	"github.com/mailbadger/app/utils"
+	"github.com/mailbadger/app/validator"
+)
+
+// teamInvitationTTL is the time in which the invitation must be accepted.
+const teamInvitationTTL = 7 * 24 * time.Hour
+
+// GetTeam returns the team of the user.
+func GetTeam(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// PostTeam creates the team of the user, along with the default editor and viewer roles.
+func PostTeam(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &params.PostTeam{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		u := middleware.GetUser(c)
+		_, err := storage.GetTeam(u.ID)
+		if err == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "The team already exists.",
+			})
+			return
+		}
+
+		t := &entities.Team{
+			UserID: u.ID,
+			Name:   body.Name,
+		}
+		err = storage.CreateTeam(t)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create team.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create team. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, t)
+	}
+}
+
+// PutTeam updates the team of the user.
+func PutTeam(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		body := &params.PutTeam{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		t.Name = body.Name
+		err := storage.UpdateTeam(t)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to update team.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update team. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// GetTeamRoles returns the roles of the team with their permissions.
+func GetTeamRoles(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		roles, err := storage.GetTeamRoles(t.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch team roles.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch team roles. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": roles,
+		})
+	}
+}
+
+// PostTeamRole creates a new role with the given permissions.
+func PostTeamRole(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		r := &entities.TeamRole{TeamID: t.ID}
+		if !bindTeamRole(c, storage, r) {
+			return
+		}
+
+		err := storage.CreateTeamRole(r)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create team role.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create team role. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, r)
+	}
+}
+
+// PutTeamRole updates the role, the changes of the permissions apply to the next request of the members.
+func PutTeamRole(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := teamRoleFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		if !bindTeamRole(c, storage, r) {
+			return
+		}
+
+		err := storage.UpdateTeamRole(r)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to update team role.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update team role. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// DeleteTeamRole deletes the role, as long as it's not assigned to a member or an invitation.
+func DeleteTeamRole(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := teamRoleFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		count, err := storage.GetTotalTeamRoleAssignments(r.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to count team role assignments.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to delete team role. Please try again.",
+			})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": fmt.Sprintf("The role is assigned to %d member(s) or invitation(s).", count),
+			})
+			return
+		}
+
+		err = storage.DeleteTeamRole(r.ID, r.TeamID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete team role.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to delete team role. Please try again.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetTeamMembers returns the members of the team.
+func GetTeamMembers(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		members, err := storage.GetTeamMembers(t.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch team members.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch team members. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": members,
+		})
+	}
+}
+
+// PutTeamMember changes the role of the member.
+func PutTeamMember(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m, ok := teamMemberFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		body := &params.PutTeamMember{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		r, err := storage.GetTeamRole(body.RoleID, m.TeamID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The role does not exist.",
+			})
+			return
+		}
+
+		m.RoleID = r.ID
+		m.Role = r
+		err = storage.UpdateTeamMember(m)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to update team member.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update team member. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// DeleteTeamMember removes the member from the team.
+func DeleteTeamMember(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m, ok := teamMemberFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		err := storage.DeleteTeamMember(m.ID, m.TeamID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete team member.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to delete team member. Please try again.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetTeamInvitations returns the pending invitations of the team.
+func GetTeamInvitations(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		invitations, err := storage.GetTeamInvitations(t.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch team invitations.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch team invitations. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": invitations,
+		})
+	}
+}
+
+// PostTeamInvitation invites the email to the team with the given role, the invitation link is sent by email.
+// The pending invitations count towards the team members limit.
+func PostTeamInvitation(
+	storage storage.Storage,
+	boundarysvc boundaries.Service,
+	emailSender emails.Sender,
+	systemEmailSource string,
+	appURL string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		body := &params.PostTeamInvitation{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		r, err := storage.GetTeamRole(body.RoleID, t.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The role does not exist.",
+			})
+			return
+		}
+
+		_, err = storage.GetTeamInvitationByEmail(body.Email, t.ID)
+		if err == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "The email is already invited to the team.",
+			})
+			return
+		}
+
+		u := middleware.GetUser(c)
+		limitexceeded, err := boundarysvc.TeamMembersLimitExceeded(u, t.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("team invitation: unable to check team members limit")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to check team members limit. Please try again.",
+			})
+			return
+		}
+		if limitexceeded {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "You have exceeded your team members limit, please upgrade to a bigger plan or contact support.",
+			})
+			return
+		}
+
+		token, err := utils.GenerateRandomString(32)
+		if err != nil {
+			logger.From(c).WithError(err).Error("team invitation: unable to generate random string")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create team invitation. Please try again.",
+			})
+			return
+		}
+
+		i := &entities.TeamInvitation{
+			TeamID:    t.ID,
+			Email:     body.Email,
+			RoleID:    r.ID,
+			Token:     token,
+			ExpiresAt: time.Now().Add(teamInvitationTTL),
+		}
+		err = storage.CreateTeamInvitation(i)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create team invitation.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create team invitation. Please try again.",
+			})
+			return
+		}
+
+		go func(c *gin.Context) {
+			err := sendTeamInvitationEmail(c, token, t.Name, i.Email, emailSender, systemEmailSource, appURL)
+			if err != nil {
+				logger.From(c).WithError(err).Error("team invitation: unable to send email")
+			}
+		}(c.Copy())
+
+		c.JSON(http.StatusCreated, i)
+	}
+}
+
+func sendTeamInvitationEmail(
+	ctx context.Context,
+	token string,
+	team string,
+	email string,
+	sender emails.Sender,
+	systemEmailSource string,
+	appURL string,
+) error {
+	var html bytes.Buffer
+	emailTmpls := templates.GetEmailTemplates()
+	url := fmt.Sprintf("%s/invitations/%s", appURL, token)
+
+	err := emailTmpls.ExecuteTemplate(&html, "team-invitation.html", map[string]string{
+		"url":  url,
+		"team": team,
+	})
+	if err != nil {
+		return fmt.Errorf("send team invitation email: exec template: %w", err)
+	}
+
+	_, err = sender.Send(ctx, &emails.Message{
+		From:    fmt.Sprintf("%s <%s>", "Mailbadger.io", systemEmailSource),
+		To:      []string{email},
+		Subject: fmt.Sprintf("You are invited to join %s", team),
+		HTML:    html.Bytes(),
+	})
+
+	return err
+}
+
+// DeleteTeamInvitation revokes the invitation.
+func DeleteTeamInvitation(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer.",
+			})
+			return
+		}
+
+		err = storage.DeleteTeamInvitation(id, t.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete team invitation.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to delete team invitation. Please try again.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetTeams returns the teams in which the user is a member, the id of the team is sent
+// with the X-Team-ID header to act on behalf of the team.
+func GetTeams(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memberships, err := storage.GetTeamMemberships(middleware.GetActor(c).ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch team memberships.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch teams. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": memberships,
+		})
+	}
+}
+
+// PostAcceptTeamInvitation adds the user to the team of the invitation.
+func PostAcceptTeamInvitation(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &params.AcceptTeamInvitation{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		i, err := storage.GetTeamInvitationByToken(body.Token)
+		if err != nil || i.Expired() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to accept the invitation. The token is invalid or expired.",
+			})
+			return
+		}
+
+		// the token is sent to the invited email, so it can't be passed on to other users
+		u := middleware.GetActor(c)
+		if !strings.EqualFold(i.Email, u.Username) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "The invitation was sent to another email.",
+			})
+			return
+		}
+		if i.Team.UserID == u.ID {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "You can't join your own team.",
+			})
+			return
+		}
+
+		_, err = storage.GetTeamMember(i.TeamID, u.ID)
+		if err == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "You are already a member of the team.",
+			})
+			return
+		}
+
+		m, err := storage.AcceptTeamInvitation(i, u.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Unable to accept the invitation. The token is invalid or expired.",
+				})
+				return
+			}
+			logger.From(c).WithError(err).Error("Unable to accept team invitation.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to accept the invitation. Please try again.",
+			})
+			return
+		}
+		m.Team = i.Team
+
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// teamFromContext returns the team of the user in the context.
+func teamFromContext(c *gin.Context, storage storage.Storage) (*entities.Team, bool) {
+	t, err := storage.GetTeam(middleware.GetUser(c).ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Team not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch team.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch team. Please try again.",
+		})
+		return nil, false
+	}
+
+	return t, true
+}
+
+func teamRoleFromParam(c *gin.Context, storage storage.Storage) (*entities.TeamRole, bool) {
+	t, ok := teamFromContext(c, storage)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	r, err := storage.GetTeamRole(id, t.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Team role not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch team role.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch team role. Please try again.",
+		})
+		return nil, false
+	}
+
+	return r, true
+}
+
+func teamMemberFromParam(c *gin.Context, storage storage.Storage) (*entities.TeamMember, bool) {
+	t, ok := teamFromContext(c, storage)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	m, err := storage.GetTeamMemberByID(id, t.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Team member not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch team member.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch team member. Please try again.",
+		})
+		return nil, false
+	}
+
+	return m, true
+}
+
+// bindTeamRole binds the request body to the role, the name of the role must be unique within the team.
+func bindTeamRole(c *gin.Context, storage storage.Storage, r *entities.TeamRole) bool {
+	body := &params.TeamRole{}
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again.",
+		})
+		return false
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return false
+	}
+
+	r.Name = body.Name
+	r.Permissions = body.Permissions
+	if err := r.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "The name of the role is reserved.",
+		})
+		return false
+	}
+
+	other, err := storage.GetTeamRoleByName(r.Name, r.TeamID)
+	if err == nil && other.ID != r.ID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Team role with that name already exists.",
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,278 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestTeams(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+	mockSender.On("Send", mock.Anything, mock.AnythingOfType("*emails.Message")).Return("", nil)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	newExpect := func() *httpexpect.Expect {
+		return setup(
+			t, s,
+			sess,
+			mockS3,
+			mockPub,
+			mockSender,
+			templatesvc,
+			boundarysvc,
+			subscrsvc,
+			reportsvc,
+			compiler,
+			false, // enable signup
+			false, // verify email
+		)
+	}
+
+	auth, err := createAuthenticatedExpect(newExpect(), s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// the member is authenticated with its own cookie jar
+	member, err := createAuthenticatedUser(newExpect(), s, "jane@example.com", nil)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	auth.GET("/api/team").
+		Expect().
+		Status(http.StatusNotFound)
+
+	teamID := int64(auth.POST("/api/team").WithJSON(params.PostTeam{Name: "Marketing"}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("name", "Marketing").
+		Value("id").Number().Raw())
+	teamStr := strconv.FormatInt(teamID, 10)
+
+	auth.POST("/api/team").WithJSON(params.PostTeam{Name: "Sales"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	auth.GET("/api/team/roles").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().Length().Equal(2)
+
+	auth.POST("/api/team/roles").WithJSON(params.TeamRole{
+		Name:        "admin",
+		Permissions: []entities.Permission{{Method: "GET", Path: "/api/campaigns"}},
+	}).Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The name of the role is reserved.")
+
+	roleID := int64(auth.POST("/api/team/roles").WithJSON(params.TeamRole{
+		Name:        "reader",
+		Permissions: []entities.Permission{{Method: "get", Path: "/api/campaigns"}},
+	}).Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw())
+	roleStr := strconv.FormatInt(roleID, 10)
+
+	auth.POST("/api/team/invitations").WithJSON(params.PostTeamInvitation{Email: "jane@example.com", RoleID: 2223}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The role does not exist.")
+
+	auth.POST("/api/team/invitations").WithJSON(params.PostTeamInvitation{Email: "jane@example.com", RoleID: roleID}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("email", "jane@example.com").
+		NotContainsKey("token")
+
+	auth.POST("/api/team/invitations").WithJSON(params.PostTeamInvitation{Email: "jane@example.com", RoleID: roleID}).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	// the pending invitation counts towards the limit
+	err = db.Model(&entities.Boundaries{}).Where("type = ?", "db_test").Update("team_members_limit", 1).Error
+	assert.Nil(t, err)
+	auth.POST("/api/team/invitations").WithJSON(params.PostTeamInvitation{Email: "bob@example.com", RoleID: roleID}).
+		Expect().
+		Status(http.StatusForbidden)
+
+	invitations, err := s.GetTeamInvitations(teamID)
+	assert.Nil(t, err)
+	assert.Len(t, invitations, 1)
+
+	auth.POST("/api/invitations/accept").WithJSON(params.AcceptTeamInvitation{Token: invitations[0].Token}).
+		Expect().
+		Status(http.StatusForbidden)
+
+	member.POST("/api/invitations/accept").WithJSON(params.AcceptTeamInvitation{Token: "foo"}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	memberID := member.POST("/api/invitations/accept").WithJSON(params.AcceptTeamInvitation{Token: invitations[0].Token}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("role_id", roleID).
+		Value("id").Number().Raw()
+	memberStr := strconv.FormatFloat(memberID, 'f', 0, 64)
+
+	member.POST("/api/invitations/accept").WithJSON(params.AcceptTeamInvitation{Token: invitations[0].Token}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	member.GET("/api/teams").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().First().Object().
+		Value("team").Object().ValueEqual("name", "Marketing")
+
+	uuid := auth.GET("/api/users/me").Expect().Status(http.StatusOK).JSON().Object().Value("uuid").String().Raw()
+	owner, err := s.GetUserByUUID(uuid)
+	assert.Nil(t, err)
+	err = s.CreateCampaign(&entities.Campaign{UserID: owner.ID, Name: "newsletter", Status: entities.StatusDraft})
+	assert.Nil(t, err)
+
+	// without the team the member can only access its account
+	member.GET("/api/campaigns").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	member.GET("/api/campaigns").WithHeader(middleware.TeamHeader, teamStr).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().Length().Equal(1)
+
+	member.GET("/api/users/me").WithHeader(middleware.TeamHeader, teamStr).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("username", "jane@example.com")
+
+	member.GET("/api/templates").WithHeader(middleware.TeamHeader, teamStr).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	member.GET("/api/campaigns").WithHeader(middleware.TeamHeader, "2223").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	member.GET("/api/campaigns").WithHeader(middleware.TeamHeader, "foo").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// the permissions of the role are applied on the next request
+	auth.PUT("/api/team/roles/" + roleStr).WithJSON(params.TeamRole{
+		Name: "reader",
+		Permissions: []entities.Permission{
+			{Method: "GET", Path: "/api/campaigns"},
+			{Method: "*", Path: "/api/templates*"},
+			{Method: "*", Path: "/api/api-keys*"},
+			{Method: "*", Path: "/api/delivery-provider"},
+		},
+	}).Expect().
+		Status(http.StatusOK)
+
+	// the api keys and the delivery settings are managed only by the owner
+	member.POST("/api/api-keys").WithHeader(middleware.TeamHeader, teamStr).WithJSON(params.PostAPIKey{Name: "ci", Scopes: []string{"*"}}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	member.GET("/api/delivery-provider").WithHeader(middleware.TeamHeader, teamStr).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	member.GET("/api/templates").WithHeader(middleware.TeamHeader, teamStr).
+		Expect().
+		Status(http.StatusOK)
+
+	member.GET("/api/team/members").WithHeader(middleware.TeamHeader, teamStr).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.GET("/api/team/members").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("collection").Array().First().Object().
+		Value("user").Object().ValueEqual("username", "jane@example.com")
+
+	auth.DELETE("/api/team/roles/"+roleStr).
+		Expect().
+		Status(http.StatusUnprocessableEntity).JSON().Object().
+		ValueEqual("message", "The role is assigned to 1 member(s) or invitation(s).")
+
+	viewer, err := s.GetTeamRoleByName(entities.TeamRoleViewer, teamID)
+	assert.Nil(t, err)
+	auth.PUT("/api/team/members/"+memberStr).WithJSON(params.PutTeamMember{RoleID: viewer.ID}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("role").Object().ValueEqual("name", entities.TeamRoleViewer)
+
+	// the viewers don't see the secrets
+	err = s.CreateWebhook(&entities.Webhook{
+		UserID: owner.ID,
+		URL:    "https://example.com/hook",
+		Secret: "secret",
+		Events: entities.JSON(`["subscriber.created"]`),
+		Active: true,
+	})
+	assert.Nil(t, err)
+
+	member.GET("/api/webhooks").WithHeader(middleware.TeamHeader, teamStr).
+		Expect().
+		Status(http.StatusOK).JSON().Array().First().Object().
+		NotContainsKey("secret")
+
+	auth.DELETE("/api/team/roles/" + roleStr).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.DELETE("/api/team/members/" + memberStr).
+		Expect().
+		Status(http.StatusNoContent)
+
+	member.GET("/api/campaigns").WithHeader(middleware.TeamHeader, teamStr).
+		Expect().
+		Status(http.StatusUnauthorized)
+}
@@ -129,8 +129,10 @@ func PostTemplate(
 				Name:        body.Name,
 				SubjectPart: body.SubjectPart,
 			},
-			HTMLPart: body.HTMLPart,
-			TextPart: body.TextPart,
+			HTMLPart:     body.HTMLPart,
+			TextPart:     body.TextPart,
+			LayoutID:     body.LayoutID,
+			HTMLPipeline: body.HTMLPipeline,
 		}
 
 		_, err := storage.GetTemplateByName(template.Name, u.ID)
@@ -156,6 +158,14 @@ func PostTemplate(
 				c.JSON(http.StatusBadRequest, gin.H{
 					"message": "Unable to create template, failed to parse subject_part",
 				})
+			case errors.Is(err, templates.ErrPartialNotFound):
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Unable to create template, an included partial does not exist",
+				})
+			case errors.Is(err, templates.ErrLayoutNotFound):
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Unable to create template, the layout does not exist",
+				})
 			default:
 				logger.From(c).WithFields(logrus.Fields{
 					"template": template,
@@ -219,6 +229,8 @@ func PutTemplate(
 		template.HTMLPart = body.HTMLPart
 		template.TextPart = body.TextPart
 		template.SubjectPart = body.SubjectPart
+		template.LayoutID = body.LayoutID
+		template.HTMLPipeline = body.HTMLPipeline
 
 		err = svc.UpdateTemplate(c, template)
 		if err != nil {
@@ -235,6 +247,14 @@ func PutTemplate(
 				c.JSON(http.StatusBadRequest, gin.H{
 					"message": "Unable to update template, failed to parse subject_part",
 				})
+			case errors.Is(err, templates.ErrPartialNotFound):
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Unable to update template, an included partial does not exist",
+				})
+			case errors.Is(err, templates.ErrLayoutNotFound):
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Unable to update template, the layout does not exist",
+				})
 			default:
 				logger.From(c).WithFields(logrus.Fields{
 					"template": template,
@@ -279,3 +299,167 @@ func DeleteTemplate(svc templates.Service) gin.HandlerFunc {
 		c.Status(http.StatusNoContent)
 	}
 }
+
+func GetTemplateVersions(svc templates.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer",
+			})
+			return
+		}
+
+		u := middleware.GetUser(c)
+
+		versions, err := svc.GetTemplateVersions(c, id, u.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "Template not found.",
+				})
+				return
+			}
+			logger.From(c).WithFields(logrus.Fields{
+				"user_id":     u.ID,
+				"template_id": id,
+			}).WithError(err).Error("get template versions: unable to list versions")
+
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch template versions. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"collection": versions,
+		})
+	}
+}
+
+func GetTemplateVersion(svc templates.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, version, ok := templateVersionFromParams(c)
+		if !ok {
+			return
+		}
+
+		u := middleware.GetUser(c)
+
+		v, err := svc.GetTemplateVersion(c, id, version, u.ID)
+		if err != nil {
+			templateVersionError(c, err, id, "get template version: unable to get version")
+			return
+		}
+
+		c.JSON(http.StatusOK, v)
+	}
+}
+
+// GetTemplateDiff returns the unified diffs of the parts of the two versions given in the `from` and `to` query params.
+func GetTemplateDiff(svc templates.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Id must be an integer",
+			})
+			return
+		}
+
+		from, err := strconv.ParseInt(c.Query("from"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "From must be an integer",
+			})
+			return
+		}
+		to, err := strconv.ParseInt(c.Query("to"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "To must be an integer",
+			})
+			return
+		}
+
+		u := middleware.GetUser(c)
+
+		diff, err := svc.DiffTemplateVersions(c, id, from, to, u.ID)
+		if err != nil {
+			templateVersionError(c, err, id, "get template diff: unable to diff versions")
+			return
+		}
+
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
+// PostTemplateRollback restores the template to the given version, the restored template is saved as a new version.
+func PostTemplateRollback(svc templates.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, version, ok := templateVersionFromParams(c)
+		if !ok {
+			return
+		}
+
+		u := middleware.GetUser(c)
+
+		template, err := svc.RollbackTemplate(c, id, version, u.ID)
+		if err != nil {
+			templateVersionError(c, err, id, "rollback template: unable to restore version")
+			return
+		}
+
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+func templateVersionFromParams(c *gin.Context) (int64, int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer",
+		})
+		return 0, 0, false
+	}
+
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Version must be an integer",
+		})
+		return 0, 0, false
+	}
+
+	return id, version, true
+}
+
+func templateVersionError(c *gin.Context, err error, templateID int64, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Template version not found.",
+		})
+	case errors.Is(err, templates.ErrPartialNotFound), errors.Is(err, templates.ErrLayoutNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "The partials or the layout of the template version no longer exist.",
+		})
+	case errors.Is(err, templates.ErrHTMLPartNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "HTML part not found.",
+		})
+	case errors.Is(err, templates.ErrHTMLPartInvalidState):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "The state of the HTML part is invalid.",
+		})
+	default:
+		logger.From(c).WithFields(logrus.Fields{
+			"user_id":     middleware.GetUser(c).ID,
+			"template_id": templateID,
+		}).WithError(err).Error(msg)
+
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Unable to get template version.",
+		})
+	}
+}
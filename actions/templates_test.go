@@ -6,14 +6,17 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
 	"github.com/mailbadger/app/config"
 	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/entities/params"
 	"github.com/mailbadger/app/opa"
 	"github.com/mailbadger/app/services/boundaries"
@@ -51,7 +54,7 @@ func TestTemplates(t *testing.T) {
 	mockS3.On("GetObject", mock.AnythingOfType("*s3.GetObjectInput")).Once().Return(&s3.GetObjectOutput{
 		Body: readCloser,
 	}, nil)
-	mockS3.On("DeleteObject", mock.AnythingOfType("*s3.DeleteObjectInput")).Twice().Return(&s3.DeleteObjectOutput{}, nil)
+	mockS3.On("DeleteObject", mock.AnythingOfType("*s3.DeleteObjectInput")).Times(3).Return(&s3.DeleteObjectOutput{}, nil)
 
 	mockPub := new(sqs.MockPublisher)
 	mockSender := new(emails.MockSender)
@@ -389,3 +392,315 @@ func TestTemplates(t *testing.T) {
 		Expect().
 		Status(http.StatusNoContent)
 }
+
+func TestTemplateVersions(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, &objectsS3{MockS3Client: mockS3}, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	id := auth.POST("/api/templates").WithJSON(params.PostTemplate{
+		Name:        "newsletter",
+		HTMLPart:    "<p>Old offer</p>",
+		TextPart:    "Old offer",
+		SubjectPart: "Hello {{name}}",
+	}).Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("version", 1).
+		Value("id").Number().Raw()
+	idStr := strconv.FormatFloat(id, 'f', 0, 64)
+
+	auth.PUT("/api/templates/"+idStr).WithJSON(params.PutTemplate{
+		Name:        "newsletter",
+		HTMLPart:    "<p>New offer</p>",
+		TextPart:    "New offer",
+		SubjectPart: "Hello {{name}}",
+	}).Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("version", 2)
+
+	versions := auth.GET("/api/templates/" + idStr + "/versions").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("collection").Array()
+	versions.Length().Equal(2)
+	versions.Element(0).Object().ValueEqual("version", 2).NotContainsKey("html_part")
+
+	auth.GET("/api/templates/"+idStr+"/versions/1").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("html_part", "<p>Old offer</p>").
+		ValueEqual("text_part", "Old offer")
+
+	auth.GET("/api/templates/"+idStr+"/versions/3").
+		Expect().
+		Status(http.StatusNotFound).
+		JSON().Object().
+		ValueEqual("message", "Template version not found.")
+
+	auth.GET("/api/templates/9933209/versions").
+		Expect().
+		Status(http.StatusNotFound)
+
+	diff := auth.GET("/api/templates/"+idStr+"/diff").
+		WithQuery("from", 1).
+		WithQuery("to", 2).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	diff.ValueEqual("subject_part", "")
+	diff.Value("html_part").String().Contains("-<p>Old offer</p>").Contains("+<p>New offer</p>")
+
+	auth.GET("/api/templates/"+idStr+"/diff").
+		WithQuery("from", "foo").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// the rollback is saved as a new version
+	auth.POST("/api/templates/"+idStr+"/versions/1/rollback").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("version", 3).
+		ValueEqual("html_part", "<p>Old offer</p>")
+
+	auth.GET("/api/templates/"+idStr).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("version", 3).
+		ValueEqual("html_part", "<p>Old offer</p>").
+		ValueEqual("text_part", "Old offer")
+
+	auth.GET("/api/templates/"+idStr+"/versions/2").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("html_part", "<p>New offer</p>")
+}
+
+func TestTemplateHTMLPipeline(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, &objectsS3{MockS3Client: mockS3}, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	html := `<html><head><style>
+  p { color: #333; margin: 0 }
+  .note { font-size: 12px }
+  td > a { color: red }
+  @media (max-width: 600px) { p { margin: 4px } }
+  a:hover { color: blue }
+</style></head>
+<body>
+  <!-- internal note -->
+  <!--[if mso]><p>Outlook</p><![endif]-->
+  <p class="note" style="color: #000">Hello   {{name}}</p>
+  <table><tr><td><a href="https://example.com">Shop</a></td></tr></table>
+  <a href="javascript:alert(1)" onclick="steal()">Click</a>
+  <script>alert(1)</script>
+  <a href="{{unsubscribe_url}}">Unsubscribe</a>
+</body></html>`
+
+	// the text part is required unless it's generated
+	auth.POST("/api/templates").WithJSON(params.PostTemplate{
+		Name:        "pipeline",
+		HTMLPart:    html,
+		SubjectPart: "Hello",
+	}).Expect().
+		Status(http.StatusBadRequest)
+
+	id := int64(auth.POST("/api/templates").WithJSON(params.PostTemplate{
+		Name:        "pipeline",
+		HTMLPart:    html,
+		SubjectPart: "Hello",
+		HTMLPipeline: entities.HTMLPipeline{
+			InlineCSS:    true,
+			MinifyHTML:   true,
+			SanitizeHTML: true,
+			GenerateText: true,
+		},
+	}).Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("inline_css", true).
+		ValueEqual("generate_text", true).
+		Value("id").Number().Raw())
+
+	// the source is kept for editing
+	auth.GET("/api/templates/"+strconv.FormatInt(id, 10)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("html_part", html).
+		ValueEqual("text_part", "").
+		ValueEqual("sanitize_html", true)
+
+	uuid := auth.GET("/api/users/me").Expect().Status(http.StatusOK).JSON().Object().Value("uuid").String().Raw()
+	u, err := s.GetUserByUUID(uuid)
+	assert.Nil(t, err)
+
+	campaign := &entities.Campaign{
+		UserID:     u.ID,
+		Name:       "pipeline",
+		TemplateID: id,
+		Status:     entities.StatusDraft,
+	}
+	err = s.CreateCampaign(campaign)
+	assert.Nil(t, err)
+
+	preview := auth.GET("/api/campaigns/" + strconv.FormatInt(campaign.ID, 10) + "/preview").
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+
+	processed := preview.Value("html").String()
+	processed.Contains(`<style>@media (max-width: 600px) { p { margin: 4px } } a:hover { color: blue }</style>`).
+		Contains(`<p class="note" style="color: #000; margin: 0; font-size: 12px">Hello </p>`).
+		Contains(`<td><a href="https://example.com" style="color: red">Shop</a></td>`).
+		Contains(`Click`).
+		NotContains("javascript").
+		NotContains("onclick").
+		Contains(`<a href="http://example.com/unsubscribe.html">Unsubscribe</a>`).
+		Contains(`<!--[if mso]><p>Outlook</p><![endif]-->`).
+		NotContains("internal note").
+		NotContains("alert").
+		NotContains("\n")
+
+	// the pipeline runs when the template is saved
+	v, err := s.GetTemplateVersion(id, 1, u.ID)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, v.ProcessedHTMLKey)
+	assert.Contains(t, v.ProcessedTextPart, "Shop (https://example.com)")
+
+	text := preview.Value("text").String()
+	text.Contains("Hello \n\nShop (https://example.com)\n\nClick Unsubscribe (http://example.com/unsubscribe.html").
+		NotContains("Outlook").
+		NotContains("color")
+}
+
+// objectsS3 keeps the uploaded objects in memory.
+type objectsS3 struct {
+	*s3mock.MockS3Client
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (m *objectsS3) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	b, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objects == nil {
+		m.objects = make(map[string]string)
+	}
+	m.objects[*in.Key] = string(b)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *objectsS3) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[*in.Key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "no such key", errors.New("key not found"))
+	}
+	return &s3.GetObjectOutput{
+		Body: ioutil.NopCloser(strings.NewReader(obj)),
+	}, nil
+}
+
+func (m *objectsS3) DeleteObject(in *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, *in.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
@@ -0,0 +1,127 @@
+package actions
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/storage"
+)
+
+// transparent 1x1 gif
+var trackingPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// The lengths of the user agent and the link columns of the opens and the clicks.
+const (
+	trackingUserAgentMaxLength = 191
+	trackingLinkMaxLength      = 2048
+)
+
+// TrackOpen records an open from the tracking pixel of the campaign email and responds with the pixel.
+func TrackOpen(storage storage.Storage, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, err := entities.DecodeTrackingToken(c.Param("token"), secret)
+		if err != nil {
+			logger.From(c).WithError(err).Warn("Track open: invalid token")
+			c.Data(http.StatusOK, "image/gif", trackingPixel)
+			return
+		}
+
+		recipient, ok := trackingRecipient(c, storage, t)
+		if ok {
+			err = storage.CreateOpen(&entities.Open{
+				UserID:     t.UserID,
+				CampaignID: t.CampaignID,
+				VariantID:  trackingVariantID(t),
+				Recipient:  recipient,
+				UserAgent:  truncate(c.Request.UserAgent(), trackingUserAgentMaxLength),
+				IPAddress:  c.ClientIP(),
+				CreatedAt:  time.Now().UTC(),
+			})
+			if err != nil {
+				logger.From(c).WithFields(logrus.Fields{
+					"user_id":     t.UserID,
+					"campaign_id": t.CampaignID,
+				}).WithError(err).Error("Track open: unable to create open record")
+			}
+		}
+
+		c.Data(http.StatusOK, "image/gif", trackingPixel)
+	}
+}
+
+// TrackClick records a click on a rewritten link of the campaign email and redirects to the original link.
+func TrackClick(storage storage.Storage, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, err := entities.DecodeTrackingToken(c.Param("token"), secret)
+		if err != nil || t.Link == "" {
+			logger.From(c).WithError(err).Warn("Track click: invalid token")
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Not found.",
+			})
+			return
+		}
+
+		recipient, ok := trackingRecipient(c, storage, t)
+		if ok {
+			err = storage.CreateClick(&entities.Click{
+				UserID:     t.UserID,
+				CampaignID: t.CampaignID,
+				VariantID:  trackingVariantID(t),
+				Recipient:  recipient,
+				Link:       truncate(t.Link, trackingLinkMaxLength),
+				UserAgent:  truncate(c.Request.UserAgent(), trackingUserAgentMaxLength),
+				IPAddress:  c.ClientIP(),
+				CreatedAt:  time.Now().UTC(),
+			})
+			if err != nil {
+				logger.From(c).WithFields(logrus.Fields{
+					"user_id":     t.UserID,
+					"campaign_id": t.CampaignID,
+				}).WithError(err).Error("Track click: unable to create click record")
+			}
+		}
+
+		c.Redirect(http.StatusFound, t.Link)
+	}
+}
+
+// trackingRecipient fetches the email of the subscriber from the tracking token,
+// the event is not recorded if the subscriber no longer exists.
+func trackingRecipient(c *gin.Context, storage storage.Storage, t *entities.TrackingToken) (string, bool) {
+	s, err := storage.GetSubscriber(t.SubscriberID, t.UserID)
+	if err != nil {
+		logger.From(c).WithFields(logrus.Fields{
+			"user_id":       t.UserID,
+			"subscriber_id": t.SubscriberID,
+		}).WithError(err).Warn("Tracking: unable to fetch subscriber")
+		return "", false
+	}
+
+	return s.Email, true
+}
+
+func trackingVariantID(t *entities.TrackingToken) *int64 {
+	if t.VariantID == 0 {
+		return nil
+	}
+	id := t.VariantID
+	return &id
+}
+
+// truncate cuts the value to the length of its column, an incomplete rune at the end is dropped.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "")
+}
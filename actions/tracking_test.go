@@ -0,0 +1,177 @@
+package actions_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestTracking(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Twice().Return(&s3.PutObjectAclOutput{}, nil)
+
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	uuid := auth.GET("/api/users/me").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("uuid").String().Raw()
+
+	u, err := s.GetUserByUUID(uuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth.POST("/api/subscribers").WithJSON(params.PostSubscriber{
+		Name:  "jane",
+		Email: "jane@example.com",
+	}).
+		Expect().
+		Status(http.StatusCreated)
+
+	sub, err := s.GetSubscriberByEmail("jane@example.com", u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := entities.TrackingToken{
+		UserID:       u.ID,
+		CampaignID:   1,
+		VariantID:    2,
+		SubscriberID: sub.ID,
+	}
+
+	openToken, err := tt.Encode("secretexmplkeythatis32characters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt.Link = "http://example.com/api/users/me"
+	clickToken, err := tt.Encode("secretexmplkeythatis32characters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.GET("/t/o/invalid").
+		Expect().
+		Status(http.StatusOK).
+		ContentType("image/gif")
+
+	e.GET("/t/o/" + openToken).
+		Expect().
+		Status(http.StatusOK).
+		ContentType("image/gif")
+
+	opens, err := s.GetOpensStats(1, u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), opens.Total)
+
+	e.GET("/t/c/invalid").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// the redirect is followed to the original link
+	auth.GET("/t/c/"+clickToken).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("uuid", uuid)
+
+	clicks, err := s.GetClicksStats(1, u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), clicks.TotalClicks)
+
+	// the user agent is cut to the length of its column, the links longer than 191 characters are kept
+	userAgent := "Mozilla/5.0 " + strings.Repeat("a", 300)
+	e.GET("/t/o/"+openToken).
+		WithHeader("User-Agent", userAgent).
+		Expect().
+		Status(http.StatusOK)
+
+	var open entities.Open
+	err = db.Order("id desc").First(&open).Error
+	assert.Nil(t, err)
+	assert.Equal(t, userAgent[:191], open.UserAgent)
+
+	tt.Link = "http://example.com/api/users/me?utm_content=" + strings.Repeat("a", 300)
+	longToken, err := tt.Encode("secretexmplkeythatis32characters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth.GET("/t/c/"+longToken).
+		WithHeader("User-Agent", userAgent).
+		Expect().
+		Status(http.StatusOK)
+
+	var click entities.Click
+	err = db.Order("id desc").First(&click).Error
+	assert.Nil(t, err)
+	assert.Equal(t, tt.Link, click.Link)
+	assert.Equal(t, userAgent[:191], click.UserAgent)
+
+	stats, err := s.GetCampaignClicksStats(1, u.ID)
+	assert.Nil(t, err)
+	assert.Len(t, stats, 2)
+}
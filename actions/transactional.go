@@ -0,0 +1,232 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	templatesvc "github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/validator"
+)
+
+// PostTransactionalSend renders the template with the given data and queues the email for the
+// recipient. When the idempotency key matches a previous request, the existing message is returned
+// and the email is not sent again.
+func PostTransactionalSend(
+	storage storage.Storage,
+	templatesvc templatesvc.Service,
+	publisher sqs.PublisherAPI,
+	queueURL sqs.TransactionalQueueURL,
+	maildir *emails.Maildir,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetUser(c)
+
+		body := &params.SendTransactional{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		if body.IdempotencyKey != "" {
+			m, err := storage.GetTransactionalMessageByIdempotencyKey(body.IdempotencyKey, u.ID)
+			if err == nil {
+				c.JSON(http.StatusOK, m)
+				return
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithError(err).Error("send transactional: unable to fetch message by idempotency key")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to send the email, please try again.",
+				})
+				return
+			}
+		}
+
+		// the recipients which complained or bounced are suppressed, as they are for the campaigns
+		suppressed, err := storage.IsRecipientSuppressed(u.ID, body.To)
+		if err != nil {
+			logger.From(c).WithError(err).Error("send transactional: unable to check the recipient")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to send the email, please try again.",
+			})
+			return
+		}
+		if suppressed {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "The recipient is suppressed because of an earlier bounce or complaint.",
+			})
+			return
+		}
+
+		tmpl, err := templatesvc.ParseTemplate(c, body.TemplateID, u.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "Template not found.",
+				})
+				return
+			}
+			logger.From(c).WithField("template_id", body.TemplateID).WithError(err).
+				Error("send transactional: unable to parse template")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Failed to parse template. Unable to send the email.",
+			})
+			return
+		}
+
+		err = tmpl.Template.ValidateData(body.TemplateData)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Incomplete template data. Unable to send the email.",
+			})
+			return
+		}
+
+		var htmlBuf, subBuf, textBuf bytes.Buffer
+		err = tmpl.HTMLPart.FRender(&htmlBuf, body.TemplateData)
+		if err == nil {
+			err = tmpl.SubjectPart.FRender(&subBuf, body.TemplateData)
+		}
+		if err == nil {
+			err = tmpl.TextPart.FRender(&textBuf, body.TemplateData)
+		}
+		if err != nil {
+			logger.From(c).WithField("template_id", body.TemplateID).WithError(err).
+				Error("send transactional: unable to render template")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Failed to render template. Unable to send the email.",
+			})
+			return
+		}
+
+		// the email is sent with the user's delivery provider, if it's not set we fallback to SES
+		d, ok := deliverySettings(c, storage, maildir, u.ID)
+		if !ok {
+			return
+		}
+
+		m := entities.NewTransactionalMessage(u.ID, body.TemplateID, body.To)
+		if body.IdempotencyKey != "" {
+			m.IdempotencyKey = &body.IdempotencyKey
+		}
+
+		err = storage.CreateTransactionalMessage(m)
+		if err != nil {
+			// a concurrent request with the same idempotency key has created the message first
+			if body.IdempotencyKey != "" {
+				existing, gerr := storage.GetTransactionalMessageByIdempotencyKey(body.IdempotencyKey, u.ID)
+				if gerr == nil {
+					c.JSON(http.StatusOK, existing)
+					return
+				}
+			}
+			logger.From(c).WithError(err).Error("send transactional: unable to create message")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to send the email, please try again.",
+			})
+			return
+		}
+
+		logEntry := logger.From(c).WithFields(logrus.Fields{
+			"message_id":  m.MessageID,
+			"template_id": body.TemplateID,
+		})
+
+		msg, err := json.Marshal(entities.SenderTopicParams{
+			EventID:                ksuid.New(),
+			UserID:                 u.ID,
+			UserUUID:               u.UUID,
+			SubscriberEmail:        body.To,
+			Source:                 fmt.Sprintf("%s <%s>", body.FromName, body.Source),
+			ConfigurationSetExists: d.ConfigurationSetExists,
+			HTMLPart:               htmlBuf.Bytes(),
+			SubjectPart:            subBuf.Bytes(),
+			TextPart:               textBuf.Bytes(),
+			SesKeys:                d.SesKeys,
+			DeliveryProviderID:     d.ProviderID,
+			TransactionalID:        m.MessageID,
+		})
+		if err == nil {
+			err = publisher.SendMessage(c, queueURL, msg)
+		}
+		if err != nil {
+			logEntry.WithError(err).Error("send transactional: unable to queue message")
+
+			// the idempotency key is released, so the email can be sent again with the same key.
+			m.Status = entities.TransactionalStatusFailed
+			m.Description = "Unable to queue the email."
+			m.IdempotencyKey = nil
+			lerr := storage.LogTransactionalEvent(m, &entities.TransactionalEvent{
+				Type:        entities.TransactionalEventFailure,
+				Description: m.Description,
+			})
+			if lerr != nil {
+				logEntry.WithError(lerr).Error("send transactional: unable to log failure")
+			}
+
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to send the email, please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, m)
+	}
+}
+
+// GetTransactionalMessage returns the status of the transactional message along with its events.
+func GetTransactionalMessage(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m, err := storage.GetTransactionalMessage(c.Param("message_id"), middleware.GetUser(c).ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "Message not found.",
+				})
+				return
+			}
+
+			logger.From(c).WithError(err).Error("Unable to fetch transactional message.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch message. Please try again.",
+			})
+			return
+		}
+
+		events, err := storage.GetTransactionalEvents(m.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch transactional events.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch message. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"transactional_message": m,
+			"events":                events,
+		})
+	}
+}
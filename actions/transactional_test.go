@@ -0,0 +1,197 @@
+package actions_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+)
+
+func TestTransactional(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+
+	var published []byte
+	mockPub := new(sqs.MockPublisher)
+	mockPub.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Once().Return(errors.New("queue is unavailable"))
+	mockPub.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Once().Run(func(args mock.Arguments) {
+		published = args.Get(2).([]byte)
+	}).Return(nil)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, htmlPartS3{mockS3, "<p>Reset your password: {{reset_url}}</p>"}, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	uuid := auth.GET("/api/users/me").Expect().Status(http.StatusOK).JSON().Object().Value("uuid").String().Raw()
+	u, err := s.GetUserByUUID(uuid)
+	assert.Nil(t, err)
+
+	tmpl := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:      u.ID,
+			Name:        "password-reset",
+			SubjectPart: "Password reset",
+		},
+		TextPart: "Reset your password: {{reset_url}}",
+	}
+	err = s.CreateTemplate(tmpl)
+	assert.Nil(t, err)
+
+	err = s.SaveDeliveryProvider(&entities.DeliveryProvider{
+		UserID: u.ID,
+		Type:   entities.DeliveryProviderSMTP,
+		Host:   "localhost",
+		Port:   1025,
+	})
+	assert.Nil(t, err)
+
+	body := params.SendTransactional{
+		TemplateID:     tmpl.ID,
+		To:             "jane@example.com",
+		Source:         "noreply@example.com",
+		FromName:       "Example",
+		IdempotencyKey: "reset-1",
+	}
+
+	e.POST("/api/transactional/send").WithJSON(body).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.POST("/api/transactional/send").WithJSON(params.SendTransactional{TemplateID: tmpl.ID, To: "foo"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Invalid parameters, please try again")
+
+	auth.POST("/api/transactional/send").WithJSON(params.SendTransactional{
+		TemplateID: tmpl.ID + 1,
+		To:         body.To,
+		Source:     body.Source,
+		FromName:   body.FromName,
+	}).
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.POST("/api/transactional/send").WithJSON(body).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Incomplete template data. Unable to send the email.")
+
+	body.TemplateData = map[string]string{"reset_url": "https://example.com/reset"}
+	auth.POST("/api/transactional/send").WithJSON(body).
+		Expect().
+		Status(http.StatusInternalServerError).JSON().Object().
+		ValueEqual("message", "Unable to send the email, please try again.")
+
+	// the message which couldn't be queued doesn't hold the idempotency key, so the retry sends the email
+	messageID := auth.POST("/api/transactional/send").WithJSON(body).
+		Expect().
+		Status(http.StatusAccepted).JSON().Object().
+		ValueEqual("status", entities.TransactionalStatusQueued).
+		ValueEqual("recipient", "jane@example.com").
+		ValueEqual("idempotency_key", "reset-1").
+		Value("message_id").String().NotEmpty().Raw()
+
+	assert.Contains(t, string(published), `"transactional_id":"`+messageID+`"`)
+	assert.NotContains(t, string(published), "list_unsubscribe_url")
+
+	// the same idempotency key returns the existing message without sending it again
+	auth.POST("/api/transactional/send").WithJSON(body).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("message_id", messageID)
+	mockPub.AssertNumberOfCalls(t, "SendMessage", 2)
+
+	res := auth.GET("/api/transactional/messages/" + messageID).
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+	res.Value("transactional_message").Object().
+		ValueEqual("message_id", messageID).
+		ValueEqual("status", entities.TransactionalStatusQueued)
+	res.Value("events").Array().Length().Equal(1)
+
+	auth.GET("/api/transactional/messages/foo").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// the blacklisted recipients are not sent to
+	err = s.CreateSubscriber(&entities.Subscriber{UserID: u.ID, Email: "john@example.com", Blacklisted: true})
+	assert.Nil(t, err)
+
+	body.To = "john@example.com"
+	body.IdempotencyKey = ""
+	auth.POST("/api/transactional/send").WithJSON(body).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+	mockPub.AssertNumberOfCalls(t, "SendMessage", 2)
+}
+
+// htmlPartS3 returns the same html part for every template, the body of the object
+// is not kept by the mocked client.
+type htmlPartS3 struct {
+	*s3mock.MockS3Client
+	html string
+}
+
+func (m htmlPartS3) GetObject(*s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{
+		Body: ioutil.NopCloser(strings.NewReader(m.html)),
+	}, nil
+}
@@ -0,0 +1,485 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/templates"
+	"github.com/mailbadger/app/utils"
+	"github.com/mailbadger/app/validator"
+)
+
+// twoFactorIssuer is the name of the account in the authenticator apps.
+const twoFactorIssuer = "Mailbadger"
+
+// GetTwoFactor returns the status of the two-factor authentication of the user.
+func GetTwoFactor(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetActor(c)
+
+		count, err := storage.GetTotalRecoveryCodes(u.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to count recovery codes.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch two-factor authentication. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":        u.TwoFactorEnabled,
+			"recovery_codes": count,
+		})
+	}
+}
+
+// PostTwoFactor starts the enrollment of the two-factor authentication, it returns the secret along with
+// the provisioning uri which is shown as a QR code. The enrollment is completed by confirming a code.
+func PostTwoFactor(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetActor(c)
+		if u.TwoFactorEnabled {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Two-factor authentication is already enabled.",
+			})
+			return
+		}
+
+		secret, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			logger.From(c).WithError(err).Error("two factor: unable to generate secret")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to enable two-factor authentication. Please try again.",
+			})
+			return
+		}
+
+		err = storage.SetTwoFactorSecret(u.ID, secret)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to set two factor secret.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to enable two-factor authentication. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"secret":           secret,
+			"provisioning_uri": utils.TOTPProvisioningURI(secret, twoFactorIssuer, u.Username),
+		})
+	}
+}
+
+// PostConfirmTwoFactor enables the two-factor authentication with the first code of the authenticator app.
+// The recovery codes are returned once.
+func PostConfirmTwoFactor(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := middleware.GetActor(c)
+		if u.TwoFactorEnabled {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Two-factor authentication is already enabled.",
+			})
+			return
+		}
+		if u.TwoFactorSecret == "" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Start the two-factor authentication enrollment first.",
+			})
+			return
+		}
+
+		body, ok := bindTwoFactorCode(c)
+		if !ok {
+			return
+		}
+
+		counter, ok := utils.ValidateTOTP(u.TwoFactorSecret, body.Code, time.Now(), u.TwoFactorCounter)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The code is invalid.",
+			})
+			return
+		}
+
+		codes, hashes, err := generateRecoveryCodes()
+		if err != nil {
+			logger.From(c).WithError(err).Error("two factor: unable to generate recovery codes")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to enable two-factor authentication. Please try again.",
+			})
+			return
+		}
+
+		err = storage.EnableTwoFactor(u.ID, counter, hashes)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to enable two factor.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to enable two-factor authentication. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// PostRecoveryCodes replaces the recovery codes of the user, the code of the authenticator app is required.
+func PostRecoveryCodes(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := twoFactorUserFromContext(c)
+		if !ok {
+			return
+		}
+
+		body, ok := bindTwoFactorCode(c)
+		if !ok {
+			return
+		}
+
+		valid, err := verifyTOTP(storage, u, body.Code)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to verify two factor code.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to verify the code. Please try again.",
+			})
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The code is invalid.",
+			})
+			return
+		}
+
+		codes, hashes, err := generateRecoveryCodes()
+		if err != nil {
+			logger.From(c).WithError(err).Error("two factor: unable to generate recovery codes")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to generate recovery codes. Please try again.",
+			})
+			return
+		}
+
+		err = storage.ReplaceRecoveryCodes(u.ID, hashes)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to replace recovery codes.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to generate recovery codes. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DeleteTwoFactor disables the two-factor authentication of the user, either the code of the
+// authenticator app or a recovery code is required.
+func DeleteTwoFactor(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := twoFactorUserFromContext(c)
+		if !ok {
+			return
+		}
+
+		body, ok := bindTwoFactorCode(c)
+		if !ok {
+			return
+		}
+
+		valid, err := verifyTwoFactorCode(storage, u, body.Code)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to verify two factor code.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to verify the code. Please try again.",
+			})
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The code is invalid.",
+			})
+			return
+		}
+
+		err = storage.DisableTwoFactor(u.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to disable two factor.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to disable two-factor authentication. Please try again.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// DeleteTeamMemberTwoFactor resets the two-factor authentication of the member, e.g. when the member
+// lost both the device and the recovery codes. Only the members who sign in with a verified single
+// sign-on connection of the account can be reset, since the identity provider of the account vouches
+// for them, and the member is notified by email.
+func DeleteTeamMemberTwoFactor(
+	storage storage.Storage,
+	emailSender emails.Sender,
+	systemEmailSource string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := teamFromContext(c, storage)
+		if !ok {
+			return
+		}
+
+		m, ok := teamMemberFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		managed, err := isSSOManagedUser(storage, t.UserID, m.User)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch sso connection of team member.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to reset two-factor authentication. Please try again.",
+			})
+			return
+		}
+		if !managed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "The two-factor authentication can be reset only for the members who sign in with the single sign-on of your verified domain.",
+			})
+			return
+		}
+
+		err = storage.DisableTwoFactor(m.UserID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to reset two factor of team member.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to reset two-factor authentication. Please try again.",
+			})
+			return
+		}
+
+		go func(c *gin.Context) {
+			err := sendTwoFactorResetEmail(c, t.Name, m.User.Username, emailSender, systemEmailSource)
+			if err != nil {
+				logger.From(c).WithError(err).Error("two factor reset: unable to send email")
+			}
+		}(c.Copy())
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// isSSOManagedUser reports whether the user was provisioned by a single sign-on connection and
+// belongs to the verified domain of a connection of the account.
+func isSSOManagedUser(storage storage.Storage, ownerID int64, u *entities.User) (bool, error) {
+	if u == nil || (u.Source != entities.SSOProtocolSAML && u.Source != entities.SSOProtocolOIDC) {
+		return false, nil
+	}
+
+	i := strings.LastIndex(u.Username, "@")
+	if i < 1 {
+		return false, nil
+	}
+
+	conn, err := storage.GetSSOConnectionByDomain(strings.ToLower(u.Username[i+1:]))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return conn.UserID == ownerID && conn.MatchesEmail(u.Username), nil
+}
+
+func sendTwoFactorResetEmail(
+	ctx context.Context,
+	team string,
+	email string,
+	sender emails.Sender,
+	systemEmailSource string,
+) error {
+	var html bytes.Buffer
+	emailTmpls := templates.GetEmailTemplates()
+
+	err := emailTmpls.ExecuteTemplate(&html, "two-factor-reset.html", map[string]string{
+		"team": team,
+	})
+	if err != nil {
+		return fmt.Errorf("send two factor reset email: exec template: %w", err)
+	}
+
+	_, err = sender.Send(ctx, &emails.Message{
+		From:    fmt.Sprintf("%s <%s>", "Mailbadger.io", systemEmailSource),
+		To:      []string{email},
+		Subject: "Your two-factor authentication was reset",
+		HTML:    html.Bytes(),
+	})
+
+	return err
+}
+
+// PostAuthenticateTwoFactor completes the sign in of the user who entered the password, with the code
+// of the authenticator app or a recovery code.
+func PostAuthenticateTwoFactor(storage storage.Storage, sess session.Session) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s, err := sess.GetPendingSession(c)
+		if err != nil {
+			if !errors.Is(err, session.ErrNotFound) &&
+				!errors.Is(err, session.ErrExpired) &&
+				!errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.From(c).WithError(err).Error("Unable to fetch pending session.")
+			}
+
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": "Your sign in has expired, please sign in again.",
+			})
+			return
+		}
+
+		body, ok := bindTwoFactorCode(c)
+		if !ok {
+			return
+		}
+
+		err = sess.AttemptPendingSession(c, s)
+		if err != nil {
+			if !errors.Is(err, session.ErrTooManyAttempts) {
+				logger.From(c).WithError(err).Error("Unable to count two factor attempt.")
+			}
+
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": "Too many invalid codes, please sign in again.",
+			})
+			return
+		}
+
+		u := &s.User
+		valid, err := verifyTwoFactorCode(storage, u, body.Code)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to verify two factor code.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to verify the code. Please try again.",
+			})
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "The code is invalid.",
+			})
+			return
+		}
+
+		err = sess.CompletePendingSession(c, s)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Cannot persist session id.")
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Unable to create session.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"user": u,
+		})
+	}
+}
+
+// twoFactorUserFromContext returns the user of the request, the two-factor authentication must be enabled.
+func twoFactorUserFromContext(c *gin.Context) (*entities.User, bool) {
+	u := middleware.GetActor(c)
+	if !u.TwoFactorEnabled {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Two-factor authentication is not enabled.",
+		})
+		return nil, false
+	}
+
+	return u, true
+}
+
+func bindTwoFactorCode(c *gin.Context) (*params.TwoFactorCode, bool) {
+	body := &params.TwoFactorCode{}
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid parameters, please try again.",
+		})
+		return nil, false
+	}
+
+	if err := validator.Validate(body); err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	return body, true
+}
+
+// verifyTOTP verifies the code of the authenticator app, the code can be used only once.
+func verifyTOTP(storage storage.Storage, u *entities.User, code string) (bool, error) {
+	counter, ok := utils.ValidateTOTP(u.TwoFactorSecret, code, time.Now(), u.TwoFactorCounter)
+	if !ok {
+		return false, nil
+	}
+
+	return storage.UseTwoFactorCounter(u.ID, counter)
+}
+
+// verifyTwoFactorCode verifies either the code of the authenticator app or a recovery code,
+// the recovery code is deleted once it's used.
+func verifyTwoFactorCode(storage storage.Storage, u *entities.User, code string) (bool, error) {
+	if len(code) == utils.TOTPDigits {
+		return verifyTOTP(storage, u, code)
+	}
+
+	err := storage.UseRecoveryCode(u.ID, entities.HashRecoveryCode(code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// generateRecoveryCodes returns the recovery codes along with their hashes, the codes
+// are formatted as xxxxx-xxxxx.
+func generateRecoveryCodes() ([]string, []string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, entities.RecoveryCodesCount)
+	hashes := make([]string, entities.RecoveryCodesCount)
+	for i := range codes {
+		b, err := utils.GenerateRandomBytes(10)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(enc.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = entities.HashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
@@ -0,0 +1,297 @@
+package actions_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+	"github.com/mailbadger/app/utils"
+)
+
+func TestTwoFactor(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockPub := new(sqs.MockPublisher)
+	sent := make(chan *emails.Message, 10)
+	mockSender := new(emails.MockSender)
+	mockSender.On("Send", mock.Anything, mock.AnythingOfType("*emails.Message")).Run(func(args mock.Arguments) {
+		sent <- args.Get(1).(*emails.Message)
+	}).Return("", nil)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	newExpect := func() *httpexpect.Expect {
+		return setup(
+			t, s,
+			sess,
+			mockS3,
+			mockPub,
+			mockSender,
+			templatesvc,
+			boundarysvc,
+			subscrsvc,
+			reportsvc,
+			compiler,
+			false, // enable signup
+			false, // verify email
+		)
+	}
+
+	auth, err := createAuthenticatedExpect(newExpect(), s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	guest := newExpect()
+
+	// signIn enters the password and returns the cookie of the pending session
+	signIn := func(username string) *httpexpect.Cookie {
+		return guest.POST("/api/authenticate").WithJSON(params.PostAuthenticate{
+			Username: username,
+			Password: "hunter1",
+		}).Expect().
+			Status(http.StatusAccepted).
+			Cookie("mbsess")
+	}
+
+	auth.GET("/api/users/two-factor").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("enabled", false)
+
+	auth.POST("/api/users/two-factor/confirm").WithJSON(params.TwoFactorCode{Code: "123456"}).
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	obj := auth.POST("/api/users/two-factor").
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+	secret := obj.Value("secret").String().Raw()
+	obj.Value("provisioning_uri").String().Contains("otpauth://totp/Mailbadger:john?").Contains("secret=" + secret)
+
+	// the codes of the authenticator app are generated from the previous period,
+	// so each of the next codes is accepted once
+	var counter int64
+	nextCode := func() string {
+		now := utils.TOTPCounter(time.Now()) - 1
+		if counter < now {
+			counter = now
+		} else {
+			counter++
+		}
+		code, err := utils.TOTPCode(secret, counter)
+		assert.Nil(t, err)
+		return code
+	}
+
+	auth.POST("/api/users/two-factor/confirm").WithJSON(params.TwoFactorCode{Code: "abcdef"}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "The code is invalid.")
+
+	codes := auth.POST("/api/users/two-factor/confirm").WithJSON(params.TwoFactorCode{Code: nextCode()}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("recovery_codes").Array()
+	codes.Length().Equal(entities.RecoveryCodesCount)
+	recoveryCode := codes.First().String().Raw()
+
+	auth.GET("/api/users/two-factor").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("enabled", true).
+		ValueEqual("recovery_codes", entities.RecoveryCodesCount)
+
+	auth.POST("/api/users/two-factor").
+		Expect().
+		Status(http.StatusUnprocessableEntity)
+
+	// the pending session doesn't sign in the user
+	c := signIn("john")
+	guest.GET("/api/users/me").WithCookie(c.Name().Raw(), c.Value().Raw()).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	guest.POST("/api/authenticate/two-factor").WithJSON(params.TwoFactorCode{Code: "abcdef"}).
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	guest.POST("/api/authenticate/two-factor").WithCookie(c.Name().Raw(), c.Value().Raw()).
+		WithJSON(params.TwoFactorCode{Code: "abcdef"}).
+		Expect().
+		Status(http.StatusForbidden).JSON().Object().
+		ValueEqual("message", "The code is invalid.")
+
+	res := guest.POST("/api/authenticate/two-factor").WithCookie(c.Name().Raw(), c.Value().Raw()).
+		WithJSON(params.TwoFactorCode{Code: strings.ToUpper(recoveryCode)}).
+		Expect().
+		Status(http.StatusOK)
+	res.JSON().Object().Value("user").Object().ValueEqual("username", "john")
+
+	c = res.Cookie("mbsess")
+	guest.GET("/api/users/me").WithCookie(c.Name().Raw(), c.Value().Raw()).
+		Expect().
+		Status(http.StatusOK)
+
+	// the recovery codes are single-use and the pending session is deleted after too many attempts
+	c = signIn("john")
+	for i := 0; i < session.MaxTwoFactorAttempts; i++ {
+		guest.POST("/api/authenticate/two-factor").WithCookie(c.Name().Raw(), c.Value().Raw()).
+			WithJSON(params.TwoFactorCode{Code: recoveryCode}).
+			Expect().
+			Status(http.StatusForbidden)
+	}
+	guest.POST("/api/authenticate/two-factor").WithCookie(c.Name().Raw(), c.Value().Raw()).
+		WithJSON(params.TwoFactorCode{Code: recoveryCode}).
+		Expect().
+		Status(http.StatusUnauthorized).JSON().Object().
+		ValueEqual("message", "Too many invalid codes, please sign in again.")
+
+	// the codes of the authenticator app can't be reused
+	code := nextCode()
+	c = signIn("john")
+	guest.POST("/api/authenticate/two-factor").WithCookie(c.Name().Raw(), c.Value().Raw()).
+		WithJSON(params.TwoFactorCode{Code: code}).
+		Expect().
+		Status(http.StatusOK)
+
+	c = signIn("john")
+	guest.POST("/api/authenticate/two-factor").WithCookie(c.Name().Raw(), c.Value().Raw()).
+		WithJSON(params.TwoFactorCode{Code: code}).
+		Expect().
+		Status(http.StatusForbidden)
+
+	codes = auth.POST("/api/users/two-factor/recovery-codes").WithJSON(params.TwoFactorCode{Code: nextCode()}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		Value("recovery_codes").Array()
+	codes.Length().Equal(entities.RecoveryCodesCount)
+
+	auth.DELETE("/api/users/two-factor").WithJSON(params.TwoFactorCode{Code: "abcdef"}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	auth.DELETE("/api/users/two-factor").WithJSON(params.TwoFactorCode{Code: codes.Last().String().Raw()}).
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/users/two-factor").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("enabled", false).
+		ValueEqual("recovery_codes", 0)
+
+	guest.POST("/api/authenticate").WithJSON(params.PostAuthenticate{
+		Username: "john",
+		Password: "hunter1",
+	}).Expect().
+		Status(http.StatusOK)
+
+	// the owner of the team resets the two factor of a member
+	_, err = createAuthenticatedUser(newExpect(), s, "jane@example.com", nil)
+	assert.Nil(t, err)
+	jane, err := s.GetUserByUsername("jane@example.com")
+	assert.Nil(t, err)
+	err = s.SetTwoFactorSecret(jane.ID, secret)
+	assert.Nil(t, err)
+	err = s.EnableTwoFactor(jane.ID, 0, []string{entities.HashRecoveryCode("aaaaa-bbbbb")})
+	assert.Nil(t, err)
+
+	signIn("jane@example.com")
+
+	teamID := int64(auth.POST("/api/team").WithJSON(params.PostTeam{Name: "Marketing"}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		Value("id").Number().Raw())
+	viewer, err := s.GetTeamRoleByName(entities.TeamRoleViewer, teamID)
+	assert.Nil(t, err)
+	m := &entities.TeamMember{TeamID: teamID, UserID: jane.ID, RoleID: viewer.ID}
+	err = s.CreateTeamMember(m)
+	assert.Nil(t, err)
+
+	auth.DELETE("/api/team/members/2223/two-factor").
+		Expect().
+		Status(http.StatusNotFound)
+
+	// only the members who sign in with a verified sso connection of the account can be reset
+	auth.DELETE("/api/team/members/" + strconv.FormatInt(m.ID, 10) + "/two-factor").
+		Expect().
+		Status(http.StatusForbidden)
+
+	err = db.Model(jane).Update("source", entities.SSOProtocolOIDC).Error
+	assert.Nil(t, err)
+	john, err := s.GetUserByUsername("john")
+	assert.Nil(t, err)
+	conn := &entities.SSOConnection{
+		UserID:        john.ID,
+		Protocol:      entities.SSOProtocolOIDC,
+		Domain:        "example.com",
+		DefaultRoleID: viewer.ID,
+	}
+	err = s.CreateSSOConnection(conn)
+	assert.Nil(t, err)
+
+	auth.DELETE("/api/team/members/" + strconv.FormatInt(m.ID, 10) + "/two-factor").
+		Expect().
+		Status(http.StatusForbidden)
+
+	conn.VerifiedAt = entities.TimeFrom(time.Now())
+	err = s.UpdateSSOConnection(conn)
+	assert.Nil(t, err)
+
+	auth.DELETE("/api/team/members/" + strconv.FormatInt(m.ID, 10) + "/two-factor").
+		Expect().
+		Status(http.StatusNoContent)
+
+	// the member is notified
+	select {
+	case msg := <-sent:
+		assert.Equal(t, []string{"jane@example.com"}, msg.To)
+		assert.Contains(t, string(msg.HTML), "Marketing")
+	case <-time.After(5 * time.Second):
+		t.Error("the two factor reset email was not sent")
+	}
+
+	jane, err = s.GetUser(jane.ID)
+	assert.Nil(t, err)
+	assert.False(t, jane.TwoFactorEnabled)
+}
@@ -2,13 +2,12 @@ package actions
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/csrf"
 	"github.com/sirupsen/logrus"
@@ -27,12 +26,12 @@ import (
 
 func GetMe(c *gin.Context) {
 	c.Header("X-CSRF-Token", csrf.Token(c.Request))
-	c.JSON(http.StatusOK, middleware.GetUser(c))
+	c.JSON(http.StatusOK, middleware.GetActor(c))
 }
 
 func ChangePassword(storage storage.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		u := middleware.GetUser(c)
+		u := middleware.GetActor(c)
 		if u == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"message": "Unable to fetch user.",
@@ -84,6 +83,12 @@ func ChangePassword(storage storage.Storage) gin.HandlerFunc {
 			return
 		}
 
+		// the sessions which were signed in with the old password are revoked
+		err = deleteOtherSessions(c, storage)
+		if err != nil {
+			logger.From(c).WithError(err).Error("change pass: unable to delete the other sessions")
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Your password was updated successfully.",
 		})
@@ -145,7 +150,7 @@ func PostForgotPassword(
 		}
 
 		go func(c *gin.Context) {
-			err := sendForgotPasswordEmail(tokenStr, u.Username, emailSender, systemEmailSource, appURL)
+			err := sendForgotPasswordEmail(c, tokenStr, u.Username, emailSender, systemEmailSource, appURL)
 			if err != nil {
 				logger.From(c).WithError(err).Error("forgot pass: unable to send email")
 			}
@@ -154,6 +159,7 @@ func PostForgotPassword(
 }
 
 func sendForgotPasswordEmail(
+	ctx context.Context,
 	token string,
 	email string,
 	sender emails.Sender,
@@ -171,24 +177,11 @@ func sendForgotPasswordEmail(
 		return fmt.Errorf("send forgot password email: exec template: %w", err)
 	}
 
-	charset := aws.String("UTF-8")
-	_, err = sender.SendEmail(&ses.SendEmailInput{
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Html: &ses.Content{
-					Charset: charset,
-					Data:    aws.String(html.String()),
-				},
-			},
-			Subject: &ses.Content{
-				Charset: charset,
-				Data:    aws.String(string("Reset your password")),
-			},
-		},
-		Source: aws.String(fmt.Sprintf("%s <%s>", "Mailbadger.io", systemEmailSource)),
-		Destination: &ses.Destination{
-			ToAddresses: []*string{aws.String(email)},
-		},
+	_, err = sender.Send(ctx, &emails.Message{
+		From:    fmt.Sprintf("%s <%s>", "Mailbadger.io", systemEmailSource),
+		To:      []string{email},
+		Subject: "Reset your password",
+		HTML:    html.Bytes(),
 	})
 
 	return err
@@ -251,6 +244,12 @@ func PutForgotPassword(storage storage.Storage) gin.HandlerFunc {
 			return
 		}
 
+		// the password was reset because it might have been compromised, so all sessions are revoked
+		err = storage.DeleteOtherUserSessions(user.ID, "")
+		if err != nil {
+			logger.From(c).WithError(err).Error("forgot pass: unable to delete the sessions")
+		}
+
 		err = storage.DeleteToken(tokenStr)
 		if err != nil {
 			logger.From(c).WithFields(logrus.Fields{
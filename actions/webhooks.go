@@ -0,0 +1,414 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/routes/middleware"
+	"github.com/mailbadger/app/services/webhooks"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/utils"
+	"github.com/mailbadger/app/validator"
+)
+
+// GetWebhooks returns the webhooks of the user.
+func GetWebhooks(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, err := storage.GetWebhooks(middleware.GetUser(c).ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch webhooks.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch webhooks. Please try again.",
+			})
+			return
+		}
+		for i := range w {
+			w[i].ClearSecret()
+		}
+
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// GetWebhook returns the webhook by the given id.
+func GetWebhook(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, ok := webhookFromParam(c, storage)
+		if !ok {
+			return
+		}
+		w.ClearSecret()
+
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// PostWebhook registers a new webhook, a signing secret is generated when it is not provided.
+// The secret is returned only in this response.
+func PostWebhook(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := &params.PostWebhook{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		if !isHTTPURL(body.URL) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The webhook url must use the http or https scheme.",
+			})
+			return
+		}
+
+		secret := body.Secret
+		if secret == "" {
+			var err error
+			secret, err = utils.GenerateRandomString(32)
+			if err != nil {
+				logger.From(c).WithError(err).Error("Unable to generate webhook secret.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": "Unable to create webhook. Please try again.",
+				})
+				return
+			}
+		}
+
+		events, err := json.Marshal(body.Events)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to encode webhook events.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create webhook. Please try again.",
+			})
+			return
+		}
+
+		w := &entities.Webhook{
+			UserID: middleware.GetUser(c).ID,
+			URL:    body.URL,
+			Secret: secret,
+			Events: events,
+			Active: true,
+		}
+
+		err = storage.CreateWebhook(w)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create webhook.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to create webhook. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, w)
+	}
+}
+
+// PutWebhook updates the webhook, the secret is kept when it is not provided.
+func PutWebhook(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, ok := webhookFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		body := &params.PutWebhook{}
+		if err := c.ShouldBindJSON(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid parameters, please try again.",
+			})
+			return
+		}
+
+		if err := validator.Validate(body); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		if !isHTTPURL(body.URL) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "The webhook url must use the http or https scheme.",
+			})
+			return
+		}
+
+		events, err := json.Marshal(body.Events)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to encode webhook events.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update webhook. Please try again.",
+			})
+			return
+		}
+
+		w.URL = body.URL
+		w.Events = events
+		w.Active = body.Active
+		if body.Secret != "" {
+			w.Secret = body.Secret
+		}
+
+		err = storage.UpdateWebhook(w)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to update webhook.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to update webhook. Please try again.",
+			})
+			return
+		}
+		if body.Secret == "" {
+			w.ClearSecret()
+		}
+
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// DeleteWebhook deletes the webhook along with the log of its deliveries.
+func DeleteWebhook(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, ok := webhookFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		err := storage.DeleteWebhook(w.ID, w.UserID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to delete webhook.")
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "Unable to delete webhook.",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetWebhookDeliveries returns a paginated list of the deliveries of the webhook.
+func GetWebhookDeliveries(store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		val, ok := c.Get("cursor")
+		if !ok {
+			logger.From(c).Error("get webhook deliveries: unable to fetch pagination cursor from context")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch webhook deliveries. Please try again.",
+			})
+			return
+		}
+
+		p, ok := val.(*storage.PaginationCursor)
+		if !ok {
+			logger.From(c).Error("get webhook deliveries: unable to cast pagination cursor from context value")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch webhook deliveries. Please try again.",
+			})
+			return
+		}
+
+		w, ok := webhookFromParam(c, store)
+		if !ok {
+			return
+		}
+
+		err := store.GetWebhookDeliveries(w.ID, w.UserID, p)
+		if err != nil {
+			logger.From(c).WithError(err).Error("get webhook deliveries: unable to fetch deliveries")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch webhook deliveries. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// GetWebhookDelivery returns the delivery along with the log of its attempts.
+func GetWebhookDelivery(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d, ok := webhookDeliveryFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		attempts, err := storage.GetWebhookDeliveryAttempts(d.ID)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to fetch webhook delivery attempts.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to fetch webhook delivery. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"delivery": d,
+			"attempts": attempts,
+		})
+	}
+}
+
+// ReplayWebhookDelivery queues a new delivery with the payload of an existing one.
+func ReplayWebhookDelivery(storage storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d, ok := webhookDeliveryFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		replay := &entities.WebhookDelivery{
+			UserID:    d.UserID,
+			WebhookID: d.WebhookID,
+			Event:     d.Event,
+			Payload:   d.Payload,
+			Status:    entities.WebhookDeliveryStatusPending,
+		}
+		replay.NextAttemptAt.SetValid(time.Now().UTC())
+
+		err := storage.CreateWebhookDelivery(replay)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to replay webhook delivery.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to replay webhook delivery. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, replay)
+	}
+}
+
+// TestWebhook sends a test event to the webhook right away and returns the outcome of the attempt.
+func TestWebhook(storage storage.Storage, webhooksvc webhooks.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, ok := webhookFromParam(c, storage)
+		if !ok {
+			return
+		}
+
+		payload, err := entities.NewWebhookPayload(entities.WebhookEventTest, gin.H{
+			"webhook_id": w.ID,
+			"message":    "This is a test event.",
+		})
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to encode test webhook payload.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to send test event. Please try again.",
+			})
+			return
+		}
+
+		d := &entities.WebhookDelivery{
+			UserID:    w.UserID,
+			WebhookID: w.ID,
+			Event:     entities.WebhookEventTest,
+			Payload:   payload,
+			Status:    entities.WebhookDeliveryStatusPending,
+		}
+
+		err = storage.CreateWebhookDelivery(d)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to create test webhook delivery.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to send test event. Please try again.",
+			})
+			return
+		}
+
+		a, err := webhooksvc.Deliver(c, d)
+		if err != nil {
+			logger.From(c).WithError(err).Error("Unable to deliver test webhook event.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Unable to send test event. Please try again.",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"delivery": d,
+			"attempt":  a,
+		})
+	}
+}
+
+func webhookFromParam(c *gin.Context, storage storage.Storage) (*entities.Webhook, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	w, err := storage.GetWebhook(id, middleware.GetUser(c).ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Webhook not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch webhook.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch webhook. Please try again.",
+		})
+		return nil, false
+	}
+
+	return w, true
+}
+
+func webhookDeliveryFromParam(c *gin.Context, storage storage.Storage) (*entities.WebhookDelivery, bool) {
+	w, ok := webhookFromParam(c, storage)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Id must be an integer.",
+		})
+		return nil, false
+	}
+
+	d, err := storage.GetWebhookDelivery(id, w.ID, w.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Webhook delivery not found.",
+			})
+			return nil, false
+		}
+
+		logger.From(c).WithError(err).Error("Unable to fetch webhook delivery.")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Unable to fetch webhook delivery. Please try again.",
+		})
+		return nil, false
+	}
+
+	return d, true
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
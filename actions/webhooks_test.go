@@ -0,0 +1,215 @@
+package actions_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/entities/params"
+	"github.com/mailbadger/app/opa"
+	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/reports"
+	"github.com/mailbadger/app/services/subscribers"
+	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/session"
+	"github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+	s3mock "github.com/mailbadger/app/storage/s3"
+	"github.com/mailbadger/app/utils"
+)
+
+func TestWebhooks(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+	sess := session.New(s, "foo", "secretexmplkeythatis32characters", true)
+
+	mockS3 := new(s3mock.MockS3Client)
+	mockS3.On("PutObject", mock.AnythingOfType("*s3.PutObjectInput")).Twice().Return(&s3.PutObjectAclOutput{}, nil)
+
+	mockPub := new(sqs.MockPublisher)
+	mockSender := new(emails.MockSender)
+
+	templatesvc := templates.New(s, mockS3, "test_bucket")
+	boundarysvc := boundaries.New(s)
+	subscrsvc := subscribers.New(mockS3, s)
+	reportsvc := reports.New(exporters.NewSubscribersExporter(mockS3, s), s)
+
+	compiler, err := opa.NewCompiler()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	e := setup(
+		t, s,
+		sess,
+		mockS3,
+		mockPub,
+		mockSender,
+		templatesvc,
+		boundarysvc,
+		subscrsvc,
+		reportsvc,
+		compiler,
+		false, // enable signup
+		false, // verify email
+	)
+	auth, err := createAuthenticatedExpect(e, s)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	var (
+		signature string
+		body      []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(entities.WebhookSignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	e.GET("/api/webhooks").
+		Expect().
+		Status(http.StatusUnauthorized)
+
+	auth.POST("/api/webhooks").WithJSON(params.PostWebhook{URL: "foo", Events: []string{"foo"}}).
+		Expect().
+		Status(http.StatusBadRequest).JSON().Object().
+		ValueEqual("message", "Invalid parameters, please try again").
+		Value("errors").Object().
+		ValueEqual("url", "Invalid url format").
+		ValueEqual("events[0]", "Must be one of: subscriber.created subscriber.unsubscribed campaign.bounce campaign.complaint campaign.click automation.bounce automation.complaint")
+
+	auth.POST("/api/webhooks").WithJSON(params.PostWebhook{URL: "ftp://example.com", Events: []string{entities.WebhookEventCampaignClick}}).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	secret := auth.POST("/api/webhooks").WithJSON(params.PostWebhook{
+		URL:    srv.URL,
+		Events: []string{entities.WebhookEventSubscriberCreated},
+	}).
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("url", srv.URL).
+		ValueEqual("active", true).
+		ValueEqual("events", []string{entities.WebhookEventSubscriberCreated}).
+		Value("secret").String().NotEmpty().Raw()
+
+	auth.GET("/api/webhooks").
+		Expect().
+		Status(http.StatusOK).JSON().Array().Length().Equal(1)
+
+	auth.GET("/api/webhooks/2").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.PUT("/api/webhooks/1").WithJSON(params.PutWebhook{
+		URL:    srv.URL,
+		Events: []string{entities.WebhookEventSubscriberCreated, entities.WebhookEventSubscriberUnsubscribed},
+		Active: true,
+	}).
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		NotContainsKey("secret").
+		Value("events").Array().Length().Equal(2)
+
+	// the secret is returned only when the webhook is created
+	auth.GET("/api/webhooks/1").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		NotContainsKey("secret")
+
+	// test event is sent right away and signed with the secret
+	attempt := auth.POST("/api/webhooks/1/test").
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+	attempt.Value("attempt").Object().
+		ValueEqual("status_code", http.StatusNoContent).
+		NotContainsKey("response_body")
+	attempt.Value("delivery").Object().
+		ValueEqual("event", entities.WebhookEventTest).
+		ValueEqual("status", entities.WebhookDeliveryStatusDelivered)
+
+	sig, err := utils.SignData(string(body), secret)
+	assert.Nil(t, err)
+	assert.Equal(t, sig, signature)
+
+	// creating a subscriber queues a delivery
+	auth.POST("/api/subscribers").WithJSON(params.PostSubscriber{Email: "jane@example.com"}).
+		Expect().
+		Status(http.StatusCreated)
+
+	auth.GET("/api/webhooks/1/deliveries").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("total", 2)
+
+	auth.GET("/api/webhooks/1/deliveries/2").
+		Expect().
+		Status(http.StatusOK).JSON().Object().
+		ValueEqual("attempts", []interface{}{}).
+		Value("delivery").Object().
+		ValueEqual("event", entities.WebhookEventSubscriberCreated).
+		ValueEqual("status", entities.WebhookDeliveryStatusPending).
+		Value("payload").Object().
+		ValueEqual("event", entities.WebhookEventSubscriberCreated).
+		Value("data").Object().
+		ValueEqual("email", "jane@example.com")
+
+	auth.GET("/api/webhooks/1/deliveries/3").
+		Expect().
+		Status(http.StatusNotFound)
+
+	auth.POST("/api/webhooks/1/deliveries/1/replay").
+		Expect().
+		Status(http.StatusCreated).JSON().Object().
+		ValueEqual("event", entities.WebhookEventTest).
+		ValueEqual("status", entities.WebhookDeliveryStatusPending)
+
+	// the events are not sent to inactive webhooks
+	auth.PUT("/api/webhooks/1").WithJSON(params.PutWebhook{
+		URL:    srv.URL,
+		Events: []string{entities.WebhookEventSubscriberCreated},
+		Active: false,
+	}).
+		Expect().
+		Status(http.StatusOK)
+
+	signature = ""
+	attempt = auth.POST("/api/webhooks/1/test").
+		Expect().
+		Status(http.StatusOK).JSON().Object()
+	attempt.Value("attempt").Object().
+		ValueEqual("status_code", 0).
+		ValueEqual("error", "webhook is inactive")
+	attempt.Value("delivery").Object().
+		ValueEqual("status", entities.WebhookDeliveryStatusFailed)
+	assert.Empty(t, signature)
+
+	auth.DELETE("/api/webhooks/1").
+		Expect().
+		Status(http.StatusNoContent)
+
+	auth.GET("/api/webhooks/1").
+		Expect().
+		Status(http.StatusNotFound)
+}
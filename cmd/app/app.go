@@ -8,21 +8,29 @@ import (
 
 	"github.com/mailbadger/app/config"
 	"github.com/mailbadger/app/server"
+	"github.com/mailbadger/app/services/automations"
 	"github.com/mailbadger/app/services/campaigns/scheduler"
+	"github.com/mailbadger/app/services/webhooks"
 )
 
 type app struct {
-	srv           *server.Server
-	campaignsched *scheduler.Scheduler
+	srv             *server.Server
+	campaignsched   *scheduler.Scheduler
+	automationsched *automations.Scheduler
+	webhooksvc      webhooks.Service
 }
 
 func newApp(
 	srv *server.Server,
 	campaignsched *scheduler.Scheduler,
+	automationsched *automations.Scheduler,
+	webhooksvc webhooks.Service,
 ) app {
 	return app{
-		srv:           srv,
-		campaignsched: campaignsched,
+		srv:             srv,
+		campaignsched:   campaignsched,
+		automationsched: automationsched,
+		webhooksvc:      webhooksvc,
 	}
 }
 
@@ -10,13 +10,18 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/google/wire"
 
+	appconfig "github.com/mailbadger/app/config"
 	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/services/automations"
 	boundarysvc "github.com/mailbadger/app/services/boundaries"
+	campaignsvc "github.com/mailbadger/app/services/campaigns"
 	"github.com/mailbadger/app/services/campaigns/scheduler"
 	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/feeds"
 	reportsvc "github.com/mailbadger/app/services/reports"
 	subscrsvc "github.com/mailbadger/app/services/subscribers"
 	templatesvc "github.com/mailbadger/app/services/templates"
+	webhooksvc "github.com/mailbadger/app/services/webhooks"
 	"github.com/mailbadger/app/session"
 	awssqs "github.com/mailbadger/app/sqs"
 	awss3 "github.com/mailbadger/app/storage/s3"
@@ -26,23 +31,38 @@ import (
 var svcSet = wire.NewSet(
 	initAwsConfig,
 	session.From,
-	awssqs.NewClient,
+	awssqs.NewClientFrom,
 	awss3.NewClient,
-	emails.NewSesSender,
+	newEmailSender,
 	wire.Bind(new(s3iface.S3API), new(*s3.S3)),
 	awssqs.GetCampaignerQueueURL,
+	awssqs.GetTransactionalQueueURL,
+	awssqs.GetSendEmailQueueURL,
 	wire.Bind(new(awssqs.SendReceiveMessageAPI), new(*sqs.Client)),
 	awssqs.NewPublisher,
 	wire.Bind(new(awssqs.PublisherAPI), new(awssqs.Publisher)),
 	scheduler.New,
+	feeds.New,
+	campaignsvc.From,
+	automations.New,
 	templatesvc.From,
 	boundarysvc.New,
 	subscrsvc.New,
 	exporters.NewSubscribersExporter,
 	wire.Bind(new(exporters.Exporter), new(*exporters.SubscribersExporter)),
 	reportsvc.New,
+	webhooksvc.From,
 )
 
 func initAwsConfig(ctx context.Context) (aws.Config, error) {
 	return config.LoadDefaultConfig(ctx)
 }
+
+// newEmailSender creates the sender for the system emails, in local delivery
+// mode the emails are written to the local maildir.
+func newEmailSender(conf appconfig.Config) (emails.Sender, error) {
+	if conf.Delivery.Mode == emails.DeliveryModeLocal {
+		return emails.NewMaildir(conf.Delivery.Maildir), nil
+	}
+	return emails.NewSesSender()
+}
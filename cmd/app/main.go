@@ -5,6 +5,8 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	// the time zones of the recurring campaigns are loaded from the embedded database
+	_ "time/tzdata"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
@@ -38,6 +40,14 @@ func main() {
 		return app.campaignsched.Start(ctx, 2*time.Minute)
 	})
 
+	g.Go(func() error {
+		return app.automationsched.Start(ctx, time.Minute)
+	})
+
+	g.Go(func() error {
+		return app.webhooksvc.Start(ctx, 30*time.Second)
+	})
+
 	if err := g.Wait(); err != nil {
 		logrus.WithError(err).Error("app terminated")
 	}
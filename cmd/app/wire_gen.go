@@ -9,16 +9,19 @@ package main
 import (
 	"context"
 	"github.com/mailbadger/app/config"
-	"github.com/mailbadger/app/emails"
 	"github.com/mailbadger/app/opa"
 	"github.com/mailbadger/app/routes"
 	"github.com/mailbadger/app/server"
+	"github.com/mailbadger/app/services/automations"
 	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/campaigns"
 	"github.com/mailbadger/app/services/campaigns/scheduler"
 	"github.com/mailbadger/app/services/exporters"
+	"github.com/mailbadger/app/services/feeds"
 	"github.com/mailbadger/app/services/reports"
 	"github.com/mailbadger/app/services/subscribers"
 	"github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/services/webhooks"
 	"github.com/mailbadger/app/session"
 	"github.com/mailbadger/app/sqs"
 	"github.com/mailbadger/app/storage"
@@ -39,13 +42,13 @@ func initApp(ctx context.Context, conf config.Config) (app, error) {
 	if err != nil {
 		return app{}, err
 	}
-	client := sqs.NewClient(awsConfig)
+	client := sqs.NewClientFrom(awsConfig, conf)
 	publisher := sqs.NewPublisher(client)
 	s3S3, err := s3.NewClient()
 	if err != nil {
 		return app{}, err
 	}
-	sender, err := emails.NewSesSender()
+	sender, err := newEmailSender(conf)
 	if err != nil {
 		return app{}, err
 	}
@@ -58,26 +61,44 @@ func initApp(ctx context.Context, conf config.Config) (app, error) {
 	if err != nil {
 		return app{}, err
 	}
-	api := routes.From(sessionSession, storageStorage, compiler, publisher, s3S3, sender, service, boundariesService, subscribersService, reportsService, campaignerQueueURL, conf)
+	webhooksService := webhooks.From(storageStorage)
+	transactionalQueueURL, err := sqs.GetTransactionalQueueURL(ctx, client)
+	if err != nil {
+		return app{}, err
+	}
+	api := routes.From(sessionSession, storageStorage, compiler, publisher, s3S3, sender, service, boundariesService, subscribersService, reportsService, webhooksService, campaignerQueueURL, transactionalQueueURL, conf)
 	serverServer := server.From(api, conf)
-	schedulerScheduler := scheduler.New(storageStorage, publisher, campaignerQueueURL)
-	mainApp := newApp(serverServer, schedulerScheduler)
+	feedsService := feeds.New()
+	schedulerScheduler := scheduler.New(storageStorage, publisher, campaignerQueueURL, feedsService)
+	campaignsService := campaigns.From(storageStorage, client, conf)
+	sendEmailQueueURL, err := sqs.GetSendEmailQueueURL(ctx, client)
+	if err != nil {
+		return app{}, err
+	}
+	automationsScheduler := automations.New(storageStorage, service, campaignsService, sendEmailQueueURL)
+	mainApp := newApp(serverServer, schedulerScheduler, automationsScheduler, webhooksService)
 	return mainApp, nil
 }
 
 // app.go:
 
 type app struct {
-	srv           *server.Server
-	campaignsched *scheduler.Scheduler
+	srv             *server.Server
+	campaignsched   *scheduler.Scheduler
+	automationsched *automations.Scheduler
+	webhooksvc      webhooks.Service
 }
 
 func newApp(
 	srv *server.Server,
 	campaignsched *scheduler.Scheduler,
+	automationsched *automations.Scheduler,
+	webhooksvc webhooks.Service,
 ) app {
 	return app{
-		srv:           srv,
-		campaignsched: campaignsched,
+		srv:             srv,
+		campaignsched:   campaignsched,
+		automationsched: automationsched,
+		webhooksvc:      webhooksvc,
 	}
 }
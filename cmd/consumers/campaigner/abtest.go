@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cbroglie/mustache"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// templateSelector selects the template which is sent to the subscriber. When the campaign is
+// sent as an A/B test the subscribers are split in the test group, which receives the variants,
+// and the remaining subscribers which receive the winning variant.
+type templateSelector struct {
+	base     *entities.CampaignTemplateData
+	phase    string
+	test     *entities.ABTest
+	variants []*entities.CampaignTemplateData
+	winner   int
+}
+
+// newTemplateSelector parses the templates of the A/B test variants for the given phase.
+func (h *handler) newTemplateSelector(
+	ctx context.Context,
+	msg *entities.CampaignerTopicParams,
+	base *entities.CampaignTemplateData,
+) (*templateSelector, error) {
+	ts := &templateSelector{
+		base:   base,
+		phase:  msg.ABTestPhase,
+		winner: -1,
+	}
+	if ts.phase == "" {
+		return ts, nil
+	}
+
+	test, err := h.store.GetABTest(msg.CampaignID, msg.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get ab test: %w", err)
+	}
+	ts.test = test
+
+	for i, v := range test.Variants {
+		tmpl := base
+		switch {
+		case v.TemplateID != nil && v.TemplateVersion != 0:
+			tmpl, err = h.templatesvc.ParseTemplateVersion(ctx, *v.TemplateID, v.TemplateVersion, msg.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("parse variant template: %w", err)
+			}
+		case v.TemplateID != nil:
+			// the variants of the tests which were started before the versions were stored
+			tmpl, err = h.templatesvc.ParseTemplate(ctx, *v.TemplateID, msg.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("parse variant template: %w", err)
+			}
+		}
+		if v.SubjectPart != nil {
+			sub, err := mustache.ParseString(*v.SubjectPart)
+			if err != nil {
+				return nil, fmt.Errorf("parse variant subject: %w", err)
+			}
+			t := *tmpl
+			t.SubjectPart = sub
+			tmpl = &t
+		}
+		ts.variants = append(ts.variants, tmpl)
+
+		if test.WinnerVariantID != nil && *test.WinnerVariantID == v.ID {
+			ts.winner = i
+		}
+	}
+
+	if ts.phase == entities.ABTestPhaseRemainder && ts.winner == -1 {
+		return nil, fmt.Errorf("winner variant not found")
+	}
+
+	return ts, nil
+}
+
+// selectTemplate returns the template and the variant id for the subscriber, it returns false
+// when the subscriber doesn't receive the campaign in the current phase.
+func (ts *templateSelector) selectTemplate(subscriberID int64) (*entities.CampaignTemplateData, int64, bool) {
+	switch ts.phase {
+	case entities.ABTestPhaseTest:
+		g := ts.test.Group(subscriberID)
+		if g == -1 {
+			return nil, 0, false
+		}
+		return ts.variants[g], ts.test.Variants[g].ID, true
+	case entities.ABTestPhaseRemainder:
+		if ts.test.Group(subscriberID) != -1 {
+			return nil, 0, false
+		}
+		return ts.variants[ts.winner], ts.test.Variants[ts.winner].ID, true
+	default:
+		return ts.base, 0, true
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/storage"
+)
+
+// bestTimeLookback is the period of the opens from which the best time of the subscriber is picked.
+const bestTimeLookback = 90 * 24 * time.Hour
+
+// deliveryPlanner groups the subscribers of a local time or best time delivery in batches by their
+// delivery time. Subscribers without a time zone are sent to in the time zone of the schedule.
+type deliveryPlanner struct {
+	store        storage.Storage
+	mode         string
+	hour, minute int
+	hasLocalTime bool
+	fallback     *time.Location
+}
+
+func newDeliveryPlanner(store storage.Storage, msg *entities.CampaignerTopicParams) (*deliveryPlanner, error) {
+	p := &deliveryPlanner{
+		store:    store,
+		mode:     msg.DeliveryMode,
+		fallback: time.UTC,
+	}
+
+	if msg.Timezone != "" {
+		loc, err := time.LoadLocation(msg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("load location: %w", err)
+		}
+		p.fallback = loc
+	}
+
+	if msg.LocalTime != "" {
+		t, err := time.Parse("15:04", msg.LocalTime)
+		if err != nil {
+			return nil, fmt.Errorf("parse local time: %w", err)
+		}
+		p.hour, p.minute, p.hasLocalTime = t.Hour(), t.Minute(), true
+	}
+
+	if p.mode == entities.DeliveryModeLocalTime && !p.hasLocalTime {
+		return nil, errors.New("local time delivery without a local time")
+	}
+
+	return p, nil
+}
+
+// batches returns the batches of the subscribers, one for each delivery time and time zone.
+func (p *deliveryPlanner) batches(
+	msg *entities.CampaignerTopicParams,
+	subs []entities.Subscriber,
+	now time.Time,
+) ([]entities.CampaignBatch, error) {
+	var opens map[string][]time.Time
+	if p.mode == entities.DeliveryModeBestTime && len(subs) > 0 {
+		emails := make([]string, len(subs))
+		for i, s := range subs {
+			emails[i] = s.Email
+		}
+
+		var err error
+		opens, err = p.store.GetOpenTimesByRecipients(msg.UserID, emails, now.Add(-bestTimeLookback))
+		if err != nil {
+			return nil, fmt.Errorf("get open times: %w", err)
+		}
+	}
+
+	type key struct {
+		releaseAt int64
+		timezone  string
+	}
+
+	var (
+		keys   []key
+		groups = make(map[key][]int64)
+	)
+	for i := range subs {
+		s := &subs[i]
+		loc := s.Location(p.fallback)
+
+		releaseAt := now
+		if hour, ok := entities.PeakOpenHour(opens[s.Email], loc); ok {
+			releaseAt = entities.NextLocalTime(now, loc, hour, 0)
+		} else if p.hasLocalTime {
+			releaseAt = entities.NextLocalTime(now, loc, p.hour, p.minute)
+		}
+
+		k := key{releaseAt: releaseAt.Unix(), timezone: loc.String()}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], s.ID)
+	}
+
+	batches := make([]entities.CampaignBatch, 0, len(keys))
+	for _, k := range keys {
+		ids, err := json.Marshal(groups[k])
+		if err != nil {
+			return nil, fmt.Errorf("marshal subscriber ids: %w", err)
+		}
+
+		batches = append(batches, entities.CampaignBatch{
+			UserID:            msg.UserID,
+			CampaignID:        msg.CampaignID,
+			EventID:           msg.EventID,
+			Timezone:          k.timezone,
+			ReleaseAt:         time.Unix(k.releaseAt, 0).UTC(),
+			Status:            entities.CampaignBatchStatusPending,
+			Size:              len(groups[k]),
+			SubscriberIDsJSON: ids,
+		})
+	}
+
+	return batches, nil
+}
@@ -71,7 +71,7 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 
 	logEntry.Info("Received a message, processing..")
 
-	campaign, err := h.store.GetCampaign(msg.UserID, msg.CampaignID)
+	campaign, err := h.store.GetCampaign(msg.CampaignID, msg.UserID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			logEntry.WithError(err).Warn("campaign does not exist")
@@ -81,9 +81,20 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 		return err
 	}
 
+	if campaign.Status == entities.StatusCancelled {
+		logEntry.Info("campaign is cancelled, skipping")
+		return nil
+	}
+
 	logEntry.WithField("template_id", campaign.TemplateID)
 
-	parsedTemplate, err := h.templatesvc.ParseTemplate(ctx, msg.UserID, campaign.TemplateID)
+	// the template is pinned to the version with which the campaign was started
+	var parsedTemplate *entities.CampaignTemplateData
+	if msg.TemplateVersion != 0 {
+		parsedTemplate, err = h.templatesvc.ParseTemplateVersion(ctx, campaign.TemplateID, msg.TemplateVersion, msg.UserID)
+	} else {
+		parsedTemplate, err = h.templatesvc.ParseTemplate(ctx, campaign.TemplateID, msg.UserID)
+	}
 	if err != nil {
 		logEntry.WithError(err).Error("unable to prepare campaign template data")
 
@@ -95,7 +106,23 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 		return nil
 	}
 
-	err = h.processSubscribers(ctx, msg, campaign, parsedTemplate, logEntry, m.ReceiptHandle)
+	selector, err := h.newTemplateSelector(ctx, msg, parsedTemplate)
+	if err != nil {
+		logEntry.WithError(err).Error("unable to prepare A/B test variants")
+
+		err = h.logFailedCampaign(ctx, campaign, "failed to prepare A/B test variants")
+		if err != nil {
+			logEntry.WithError(err).Errorf("unable to set campaign status to '%s'", entities.StatusFailed)
+		}
+
+		return nil
+	}
+
+	if msg.BatchID != 0 {
+		return h.processBatch(ctx, msg, campaign, selector, logEntry)
+	}
+
+	err = h.processSubscribers(ctx, msg, campaign, selector, logEntry, m.ReceiptHandle)
 	if err != nil {
 		// TODO return wrapped errors and do the logging here instead of inside processSubscribers
 		err = h.logFailedCampaign(ctx, campaign, "failed to process subscribers")
@@ -114,30 +141,73 @@ func (h *handler) processSubscribers(
 	ctx context.Context,
 	msg *entities.CampaignerTopicParams,
 	campaign *entities.Campaign,
-	parsedTemplate *entities.CampaignTemplateData,
+	selector *templateSelector,
 	logEntry *logrus.Entry,
 	receiptHandle *string,
 ) error {
-	var (
-		timestamp time.Time
-		nextID    int64
-		limit     int64 = 1000
-	)
+	var limit int64 = 1000
 
 	id := ksuid.New() // this id will be only used for saving failed send logs
 
+	// in the local time and best time delivery the subscribers are grouped in batches,
+	// which are released by the scheduler once their delivery time comes.
+	var planner *deliveryPlanner
+	if msg.DeliveryMode != "" {
+		var err error
+		planner, err = newDeliveryPlanner(h.store, msg)
+		if err != nil {
+			logEntry.WithError(err).Error("unable to prepare the delivery planner")
+			return err
+		}
+	}
+
+	// continue from the saved cursor if the campaign was paused and resumed or
+	// if the message was redelivered after the campaigner stopped.
+	cp, err := h.store.GetCampaignCheckpoint(msg.EventID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logEntry.WithError(err).Error("unable to fetch campaign checkpoint")
+			return err
+		}
+
+		params, err := json.Marshal(msg)
+		if err != nil {
+			logEntry.WithError(err).Error("unable to marshal campaign params")
+			return err
+		}
+
+		cp = &entities.CampaignCheckpoint{
+			EventID:    msg.EventID,
+			UserID:     msg.UserID,
+			CampaignID: msg.CampaignID,
+			Params:     params,
+		}
+	} else {
+		logEntry.WithField("last_subscriber_id", cp.LastSubscriberID).Info("continuing campaign from checkpoint")
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
+			stop, err := h.checkStatus(msg, cp)
+			if err != nil {
+				logEntry.WithError(err).Error("unable to check campaign status")
+				return err
+			}
+			if stop {
+				logEntry.Info("campaign is paused or cancelled, stopping")
+				return nil
+			}
+
 			subs, err := h.store.GetDistinctSubscribersBySegmentIDs(
 				msg.SegmentIDs,
 				msg.UserID,
 				false, // not in a denylist
 				true,  // active
-				timestamp,
-				nextID,
+				cp.LastCreatedAt,
+				cp.LastSubscriberID,
 				limit,
 			)
 			if err != nil {
@@ -154,79 +224,305 @@ func (h *handler) processSubscribers(
 				logrus.WithError(err).Error("unable to extend the message visibility timeout")
 			}
 
-			for _, s := range subs {
-				id = id.Next()
-				params, err := h.campaignsvc.PrepareSubscriberEmailData(
-					s,
-					*msg,
-					campaign.ID,
-					parsedTemplate.HTMLPart,
-					parsedTemplate.SubjectPart,
-					parsedTemplate.TextPart,
-				)
+			if planner != nil {
+				batches, err := planner.batches(msg, subs, time.Now().UTC())
 				if err != nil {
-					logEntry.WithField("subscriber_id", s.ID).WithError(err).Error("unable to prepare subscriber email data")
-
-					err := h.store.CreateSendLog(&entities.SendLog{
-						ID:           id,
-						UserID:       msg.UserID,
-						EventID:      msg.EventID,
-						SubscriberID: s.ID,
-						CampaignID:   msg.CampaignID,
-						Status:       entities.SendLogStatusFailed,
-						Description:  fmt.Sprintf("Failed to prepare subscriber email data error: %s", err),
-					})
-					if err != nil {
-						logEntry.WithFields(logrus.Fields{
-							"subscriber_id": s.ID,
-							"event_id":      msg.EventID,
-						}).WithError(err).Error("unable to insert send logs for subscriber.")
-					}
-
-					continue
+					logEntry.WithError(err).Error("unable to group subscribers in batches")
+					return err
 				}
-
-				err = h.campaignsvc.PublishSubscriberEmailParams(ctx, params, h.sendEmailQueueURL)
+				err = h.store.CreateCampaignBatches(batches)
 				if err != nil {
-					logEntry.WithField("subscriber_id", s.ID).WithError(err).Error("unable to publish subscriber email params")
-
-					err := h.store.CreateSendLog(&entities.SendLog{
-						ID:           id,
-						UserID:       msg.UserID,
-						EventID:      msg.EventID,
-						SubscriberID: s.ID,
-						CampaignID:   msg.CampaignID,
-						Status:       entities.SendLogStatusFailed,
-						Description:  fmt.Sprintf("Failed to publish subscriber email data error: %s", err),
-					})
-					if err != nil {
-						logEntry.WithFields(logrus.Fields{
-							"subscriber_id": s.ID,
-							"event_id":      msg.EventID,
-						}).WithError(err).Error("unable to insert send logs for subscriber.")
-					}
+					logEntry.WithError(err).Error("unable to create campaign batches")
+					return err
+				}
+			} else {
+				for i := range subs {
+					id = id.Next()
+					h.enqueue(ctx, msg, campaign, selector, &subs[i], id, cp, logEntry)
+				}
+			}
 
-					continue
+			// set the cursor for the next batch and persist it, a redelivered message
+			// continues from this checkpoint instead of the first subscriber.
+			if len(subs) > 0 {
+				lastSub := subs[len(subs)-1]
+				cp.LastSubscriberID = lastSub.ID
+				cp.LastCreatedAt = lastSub.CreatedAt
+			}
+
+			err = h.store.SaveCampaignCheckpoint(cp)
+			if err != nil {
+				logEntry.WithError(err).Error("unable to save campaign checkpoint")
+				return err
+			}
+
+			if int64(len(subs)) < limit && planner != nil {
+				cp.Batched = true
+				err = h.store.SaveCampaignCheckpoint(cp)
+				if err != nil {
+					logEntry.WithError(err).Error("unable to save campaign checkpoint")
+					return err
 				}
+				return h.completeBatches(msg, campaign, logEntry)
 			}
 
-			if len(subs) < 1000 {
-				err := h.setStatusSent(ctx, campaign)
+			if int64(len(subs)) < limit {
+				status := entities.StatusSent
+				if msg.ABTestPhase == entities.ABTestPhaseTest {
+					// the remaining subscribers are sent to once the winner is picked.
+					status = entities.StatusTesting
+				}
+
+				ok, err := h.store.UpdateCampaignStatus(campaign.ID, msg.UserID, status, entities.StatusSending)
 				if err != nil {
-					logEntry.WithError(err).Errorf("unable to set campaign status to '%s'", entities.StatusSent)
+					logEntry.WithError(err).Errorf("unable to set campaign status to '%s'", status)
 					return err
 				}
+				if ok && status == entities.StatusTesting {
+					err = h.startWaitingForWinner(selector.test, cp.Params)
+					if err != nil {
+						logEntry.WithError(err).Error("unable to update A/B test")
+						return err
+					}
+				}
+				if !ok {
+					// the campaign was paused or cancelled while the last batch was processed.
+					_, err = h.checkStatus(msg, cp)
+					if err != nil {
+						logEntry.WithError(err).Error("unable to check campaign status")
+						return err
+					}
+				}
 				return nil
 			}
+		}
+	}
+}
+
+// processBatch sends the campaign to the subscribers of the batch released by the scheduler.
+// The batch is put back when the campaign is paused, so it's released again once it's resumed.
+func (h *handler) processBatch(
+	ctx context.Context,
+	msg *entities.CampaignerTopicParams,
+	campaign *entities.Campaign,
+	selector *templateSelector,
+	logEntry *logrus.Entry,
+) error {
+	logEntry = logEntry.WithField("batch_id", msg.BatchID)
+
+	batch, err := h.store.GetCampaignBatch(msg.BatchID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logEntry.WithError(err).Warn("campaign batch does not exist")
+			return nil
+		}
+		logEntry.WithError(err).Error("unable to fetch campaign batch")
+		return err
+	}
+	if batch.Status != entities.CampaignBatchStatusReleased {
+		logEntry.WithField("status", batch.Status).Warn("campaign batch is not released, skipping")
+		return nil
+	}
+
+	if campaign.Status != entities.StatusSending {
+		logEntry.Info("campaign is not sending, putting the batch back")
+		_, err = h.store.UpdateCampaignBatchStatus(batch.ID, entities.CampaignBatchStatusPending, entities.CampaignBatchStatusReleased)
+		return err
+	}
+
+	ids, err := batch.GetSubscriberIDs()
+	if err != nil {
+		logEntry.WithError(err).Error("unable to unmarshal subscriber ids of the batch")
+		return err
+	}
+
+	subs, err := h.store.GetSubscribersByIDs(ids, msg.UserID)
+	if err != nil {
+		logEntry.WithError(err).Error("unable to fetch subscribers of the batch")
+		return err
+	}
+
+	// the subscriber could have opted out of the segments from the preference center since the batch was created.
+	memberIDs, err := h.store.GetSegmentMemberIDs(msg.SegmentIDs, msg.UserID, ids)
+	if err != nil {
+		logEntry.WithError(err).Error("unable to fetch segment members of the batch")
+		return err
+	}
+	members := make(map[int64]bool, len(memberIDs))
+	for _, memberID := range memberIDs {
+		members[memberID] = true
+	}
+
+	var (
+		id       = ksuid.New() // this id will be only used for saving failed send logs
+		counters = new(entities.CampaignCheckpoint)
+	)
+	for i := range subs {
+		s := &subs[i]
+		// the subscriber could have unsubscribed since the batch was created.
+		if !s.Active || s.Blacklisted || s.IsPaused() || !members[s.ID] {
+			continue
+		}
+		id = id.Next()
+		h.enqueue(ctx, msg, campaign, selector, s, id, counters, logEntry)
+	}
+
+	_, err = h.store.UpdateCampaignBatchStatus(batch.ID, entities.CampaignBatchStatusSent, entities.CampaignBatchStatusReleased)
+	if err != nil {
+		logEntry.WithError(err).Error("unable to set campaign batch status to 'sent'")
+		return err
+	}
+
+	err = h.store.IncrementCampaignCheckpoint(msg.EventID, counters.Enqueued, counters.Failed)
+	if err != nil {
+		logEntry.WithError(err).Error("unable to update campaign checkpoint counters")
+		return err
+	}
+
+	cp, err := h.store.GetCampaignCheckpoint(msg.EventID)
+	if err != nil {
+		logEntry.WithError(err).Error("unable to fetch campaign checkpoint")
+		return err
+	}
+	if !cp.Batched {
+		// the campaigner is still grouping the subscribers in batches.
+		return nil
+	}
+
+	return h.completeBatches(msg, campaign, logEntry)
+}
+
+// completeBatches sets the campaign status to sent once all of its batches are sent.
+func (h *handler) completeBatches(
+	msg *entities.CampaignerTopicParams,
+	campaign *entities.Campaign,
+	logEntry *logrus.Entry,
+) error {
+	count, err := h.store.CountUnsentCampaignBatches(msg.EventID)
+	if err != nil {
+		logEntry.WithError(err).Error("unable to count unsent campaign batches")
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	_, err = h.store.UpdateCampaignStatus(campaign.ID, msg.UserID, entities.StatusSent, entities.StatusSending)
+	if err != nil {
+		logEntry.WithError(err).Errorf("unable to set campaign status to '%s'", entities.StatusSent)
+		return err
+	}
+	return nil
+}
 
-			// set  vars for next batches
-			lastSub := subs[len(subs)-1]
-			nextID = lastSub.ID
-			timestamp = lastSub.CreatedAt
+// enqueue prepares and publishes the email params of the subscriber, the counters of the checkpoint
+// are updated and the failures are saved in the send logs.
+func (h *handler) enqueue(
+	ctx context.Context,
+	msg *entities.CampaignerTopicParams,
+	campaign *entities.Campaign,
+	selector *templateSelector,
+	s *entities.Subscriber,
+	id ksuid.KSUID,
+	cp *entities.CampaignCheckpoint,
+	logEntry *logrus.Entry,
+) {
+	parsedTemplate, variantID, ok := selector.selectTemplate(s.ID)
+	if !ok {
+		return
+	}
+
+	params, err := h.campaignsvc.PrepareSubscriberEmailData(
+		*s,
+		*msg,
+		campaign,
+		variantID,
+		parsedTemplate.HTMLPart,
+		parsedTemplate.SubjectPart,
+		parsedTemplate.TextPart,
+	)
+	if err != nil {
+		logEntry.WithField("subscriber_id", s.ID).WithError(err).Error("unable to prepare subscriber email data")
+		cp.Failed++
+
+		err := h.store.CreateSendLog(&entities.SendLog{
+			ID:           id,
+			UserID:       msg.UserID,
+			EventID:      msg.EventID,
+			SubscriberID: s.ID,
+			CampaignID:   msg.CampaignID,
+			Status:       entities.SendLogStatusFailed,
+			Description:  fmt.Sprintf("Failed to prepare subscriber email data error: %s", err),
+		})
+		if err != nil {
+			logEntry.WithFields(logrus.Fields{
+				"subscriber_id": s.ID,
+				"event_id":      msg.EventID,
+			}).WithError(err).Error("unable to insert send logs for subscriber.")
 		}
+
+		return
+	}
+
+	err = h.campaignsvc.PublishSubscriberEmailParams(ctx, params, h.sendEmailQueueURL)
+	if err != nil {
+		logEntry.WithField("subscriber_id", s.ID).WithError(err).Error("unable to publish subscriber email params")
+		cp.Failed++
+
+		err := h.store.CreateSendLog(&entities.SendLog{
+			ID:           id,
+			UserID:       msg.UserID,
+			EventID:      msg.EventID,
+			SubscriberID: s.ID,
+			CampaignID:   msg.CampaignID,
+			Status:       entities.SendLogStatusFailed,
+			Description:  fmt.Sprintf("Failed to publish subscriber email data error: %s", err),
+		})
+		if err != nil {
+			logEntry.WithFields(logrus.Fields{
+				"subscriber_id": s.ID,
+				"event_id":      msg.EventID,
+			}).WithError(err).Error("unable to insert send logs for subscriber.")
+		}
+
+		return
+	}
+
+	cp.Enqueued++
+}
+
+// checkStatus reports whether the campaign was paused or cancelled in the meantime. When the
+// campaign is paused the checkpoint is marked as paused so the campaign can be resumed later.
+func (h *handler) checkStatus(msg *entities.CampaignerTopicParams, cp *entities.CampaignCheckpoint) (bool, error) {
+	campaign, err := h.store.GetCampaign(msg.CampaignID, msg.UserID)
+	if err != nil {
+		return false, fmt.Errorf("get campaign: %w", err)
+	}
+
+	switch campaign.Status {
+	case entities.StatusCancelled:
+		return true, nil
+	case entities.StatusPaused:
+		cp.Paused = true
+		err = h.store.SaveCampaignCheckpoint(cp)
+		if err != nil {
+			return false, fmt.Errorf("save checkpoint: %w", err)
+		}
+		return true, nil
+	default:
+		return false, nil
 	}
 }
 
+// startWaitingForWinner sets the time when the winner of the A/B test is picked, the campaigner
+// params are stored so the winning variant can be sent to the remaining subscribers.
+func (h *handler) startWaitingForWinner(test *entities.ABTest, params entities.JSON) error {
+	test.Status = entities.ABTestStatusTesting
+	test.PickWinnerAt.SetValid(time.Now().UTC().Add(time.Duration(test.WaitMinutes) * time.Minute))
+	test.Params = params
+	return h.store.UpdateABTest(test)
+}
+
 // logFailedCampaign updates campaign status to failed & inserts campaign  failed log.
 func (h *handler) logFailedCampaign(ctx context.Context, campaign *entities.Campaign, description string) error {
 	campaign.Status = entities.StatusFailed
@@ -234,12 +530,6 @@ func (h *handler) logFailedCampaign(ctx context.Context, campaign *entities.Camp
 	return h.store.LogFailedCampaign(campaign, description)
 }
 
-func (h *handler) setStatusSent(ctx context.Context, campaign *entities.Campaign) error {
-	campaign.Status = entities.StatusSent
-	campaign.CompletedAt.SetValid(time.Now().UTC())
-	return h.store.UpdateCampaign(campaign)
-}
-
 func (h *handler) DeleteMessage(ctx context.Context, m types.Message) error {
 	_, err := h.sqsclient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
 		QueueUrl:      h.queueURL,
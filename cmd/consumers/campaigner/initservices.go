@@ -19,7 +19,7 @@ import (
 var svcSet = wire.NewSet(
 	initAwsConfig,
 	awss3.NewClient,
-	awssqs.NewClient,
+	awssqs.NewClientFrom,
 	wire.Bind(new(awssqs.SendReceiveMessageAPI), new(*sqs.Client)),
 	wire.Bind(new(s3iface.S3API), new(*s3.S3)),
 	awssqs.GetCampaignerQueueURL,
@@ -25,7 +25,7 @@ func initApp(ctx context.Context, conf config.Config) (app, error) {
 	if err != nil {
 		return app{}, err
 	}
-	client := sqs.NewClient(awsConfig)
+	client := sqs.NewClientFrom(awsConfig, conf)
 	service := campaigns.From(storageStorage, client, conf)
 	s3S3, err := s3.NewClient()
 	if err != nil {
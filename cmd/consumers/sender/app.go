@@ -11,14 +11,16 @@ import (
 )
 
 type app struct {
-	handler  *handler
-	consumer sqs.Consumer
+	handler               *handler
+	consumer              sqs.Consumer
+	transactionalConsumer transactionalConsumer
 }
 
-func newApp(h *handler, c sqs.Consumer) app {
+func newApp(h *handler, c sqs.Consumer, tc transactionalConsumer) app {
 	return app{
-		handler:  h,
-		consumer: c,
+		handler:               h,
+		consumer:              c,
+		transactionalConsumer: tc,
 	}
 }
 
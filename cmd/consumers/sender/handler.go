@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
@@ -18,7 +20,9 @@ import (
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/segmentio/ksuid"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 
+	"github.com/mailbadger/app/config"
 	"github.com/mailbadger/app/emails"
 	"github.com/mailbadger/app/entities"
 	awssqs "github.com/mailbadger/app/sqs"
@@ -37,26 +41,84 @@ const (
 	cacheDuration = 7 * 24 * time.Hour // & days cache duration
 )
 
-// CharSet is used for the SES message body charset
-const CharSet = "UTF-8"
+// Campaign status parameters
+const (
+	// statusCacheDuration is how long the campaign status is cached in memory,
+	// so the status is not fetched from the database for every message.
+	statusCacheDuration = 10 * time.Second
+	// pausedDelaySeconds is the delay after which a message of a paused campaign
+	// becomes visible again in the queue.
+	pausedDelaySeconds = 300
+)
+
+// Rate limit parameters
+const (
+	rateLimitPrefix = "sender:ratelimit:"
+	// rateCacheDuration is how long the send rate of the user is cached in memory.
+	rateCacheDuration = time.Minute
+	// quotaRefreshInterval is how often the max send rate of the SES account is refreshed.
+	quotaRefreshInterval = time.Hour
+	// maxDelaySeconds is the max delay of a message supported by SQS.
+	maxDelaySeconds = 900
+)
+
+type campaignStatus struct {
+	status    string
+	expiresAt time.Time
+}
+
+type sendRate struct {
+	rate      float64
+	expiresAt time.Time
+}
 
 type handler struct {
 	storage   storage.Storage
 	cache     redis.Store
 	sqsclient *sqs.Client
 	queueURL  awssqs.SendEmailQueueURL
+
+	// maxTokenWait is how long a message waits for a token of the rate limiter, it's kept below
+	// the visibility timeout so the message isn't received again while it's being handled.
+	maxTokenWait time.Duration
+
+	// transactionalQueueURL is the queue of the transactional emails,
+	// it is polled separately so they are not delayed by the campaigns.
+	transactionalQueueURL awssqs.TransactionalQueueURL
+
+	// localSender is set when the local delivery mode is enabled,
+	// all messages are then written to the local maildir.
+	localSender emails.Sender
+
+	mu       sync.Mutex
+	statuses map[int64]campaignStatus
+	rates    map[int64]sendRate
 }
 
 func newHandler(
 	storage storage.Storage,
 	cache redis.Store,
+	sqsclient *sqs.Client,
 	queueURL awssqs.SendEmailQueueURL,
+	transactionalQueueURL awssqs.TransactionalQueueURL,
+	conf config.Config,
 ) *handler {
-	return &handler{
-		storage:  storage,
-		cache:    cache,
-		queueURL: queueURL,
+	h := &handler{
+		storage:               storage,
+		cache:                 cache,
+		sqsclient:             sqsclient,
+		queueURL:              queueURL,
+		transactionalQueueURL: transactionalQueueURL,
+		maxTokenWait:          time.Duration(conf.Consumer.Timeout) * time.Second / 2,
+		statuses:              make(map[int64]campaignStatus),
+		rates:                 make(map[int64]sendRate),
 	}
+
+	if conf.Delivery.Mode == emails.DeliveryModeLocal {
+		h.localSender = emails.NewMaildir(conf.Delivery.Maildir)
+	}
+
+	return h
 }
 
 // HandleMessage is the only requirement needed to fulfill the
@@ -83,9 +145,40 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 		"subscriber_id": msg.SubscriberID,
 		"cache_key":     cacheKey,
 	})
+	if msg.TransactionalID != "" {
+		logEntry = logEntry.WithField("transactional_id", msg.TransactionalID)
+	}
+	if msg.AutomationID != 0 {
+		logEntry = logEntry.WithField("automation_id", msg.AutomationID)
+	}
 
 	logEntry.Info("Received message, processing..")
 
+	// transactional and automation emails don't belong to a campaign, so they are never paused or cancelled.
+	if msg.TransactionalID == "" && msg.AutomationID == 0 {
+		status, err := h.campaignStatus(msg.CampaignID, msg.UserID)
+		if err != nil {
+			logEntry.WithError(err).Error("Unable to fetch campaign status")
+			return err
+		}
+
+		switch status {
+		case entities.StatusCancelled:
+			logEntry.Info("Campaign is cancelled, skipping message")
+			return nil
+		case entities.StatusPaused:
+			// the message is published again with a delay, so it doesn't count towards the
+			// receive count of the queue while the campaign is paused.
+			err := h.requeue(ctx, m, msg, pausedDelaySeconds)
+			if err != nil {
+				logEntry.WithError(err).Error("Unable to requeue message of paused campaign")
+				return err
+			}
+			logEntry.Info("Campaign is paused, message requeued")
+			return nil
+		}
+	}
+
 	// check if the message is processing (if the uuid exists in redis that means it is in progress)
 	exist, err := h.cache.Exists(ctx, cacheKey)
 	if err != nil {
@@ -98,6 +191,25 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 		return nil
 	}
 
+	retryAfter, err := h.waitForToken(ctx, msg)
+	if err != nil {
+		logEntry.WithError(err).Error("Unable to take a token from the rate limiter")
+		return err
+	}
+	if retryAfter > 0 {
+		delay := int32(math.Ceil(retryAfter.Seconds()))
+		if delay > maxDelaySeconds {
+			delay = maxDelaySeconds
+		}
+		err = h.requeue(ctx, m, msg, delay)
+		if err != nil {
+			logEntry.WithError(err).Error("Unable to requeue rate limited message")
+			return err
+		}
+		logEntry.WithField("delay_seconds", delay).Info("Send rate exceeded, message requeued")
+		return nil
+	}
+
 	if err := h.cache.Set(ctx, cacheKey, []byte("1"), cacheDuration); err != nil {
 		logEntry.WithError(err).Error("Unable to write to cache")
 		return err
@@ -112,8 +224,25 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 		Status:       entities.SendLogStatusSuccessful,
 		Description:  entities.SendLogDescriptionOnSuccessful,
 	}
+	if msg.VariantID != 0 {
+		sendLog.VariantID = &msg.VariantID
+	}
 
 	defer func() {
+		if err == nil && msg.TransactionalID != "" {
+			err = h.logTransactional(msg, sendLog)
+			if err != nil {
+				logEntry.WithError(err).Error("Unable to log the result of the transactional email.")
+			}
+			return
+		}
+		if err == nil && msg.AutomationID != 0 {
+			err = h.storage.CreateAutomationLog(newAutomationLog(msg, sendLog))
+			if err != nil {
+				logEntry.WithError(err).Error("Unable to log the result of the automation email.")
+			}
+			return
+		}
 		if err == nil {
 			err = h.storage.CreateSendLog(sendLog)
 			if err != nil {
@@ -128,9 +257,9 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 		}
 	}()
 
-	client, err := newSesClient(msg.SesKeys)
+	sender, err := h.newSender(*msg)
 	if err != nil {
-		logEntry.WithError(err).Error("Unable to create ses sender")
+		logEntry.WithError(err).Error("Unable to create email sender")
 
 		sendLog.Status = entities.StatusFailed
 		sendLog.Description = entities.SendLogDescriptionOnSesClientError
@@ -138,14 +267,19 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 		return nil
 	}
 
-	resp, err := sendEmail(client, *msg)
+	messageID, err := sender.Send(ctx, newMessage(*msg))
 	if err != nil {
 		sendLog.Status = entities.StatusFailed
 		sendLog.Description = entities.SendLogDescriptionOnSendEmailError
 
 		// First check errors for retrying (returning) they don't need to be inserted in send logs
 		// also if the error is retryable delete it from cache
-		if aerr, ok := err.(awserr.Error); ok {
+		var aerr awserr.Error
+		switch {
+		case errors.Is(err, emails.ErrMessageRejected):
+			sendLog.Description = "Unable to send email, message rejected."
+			logEntry.WithError(err).Error("Unable to send email. Message rejected.")
+		case errors.As(err, &aerr):
 			switch aerr.Code() {
 			case ses.ErrCodeMessageRejected:
 				sendLog.Description = "Unable to send email, message rejected."
@@ -178,7 +312,7 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 				}
 				return err
 			}
-		} else {
+		default:
 			logEntry.WithError(err).Error("Unable to send templated email.")
 			rerr := h.cache.Delete(ctx, cacheKey)
 			if rerr != nil {
@@ -186,10 +320,12 @@ func (h *handler) HandleMessage(ctx context.Context, m types.Message) (err error
 			}
 			return err
 		}
+
+		return nil
 	}
 
-	if resp != nil {
-		sendLog.MessageID = resp.MessageId
+	if messageID != "" {
+		sendLog.MessageID = &messageID
 	}
 
 	return nil
@@ -203,7 +339,217 @@ func (h *handler) DeleteMessage(ctx context.Context, m types.Message) error {
 	return err
 }
 
-func newSesClient(keys entities.SesKeys) (emails.Sender, error) {
+// DeleteTransactionalMessage deletes the message from the queue of the transactional emails.
+func (h *handler) DeleteTransactionalMessage(ctx context.Context, m types.Message) error {
+	_, err := h.sqsclient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      h.transactionalQueueURL,
+		ReceiptHandle: m.ReceiptHandle,
+	})
+	return err
+}
+
+// logTransactional records the result of sending the transactional email, instead of the send log
+// which is kept for the campaigns.
+func (h *handler) logTransactional(msg *entities.SenderTopicParams, sendLog *entities.SendLog) error {
+	m, err := h.storage.GetTransactionalMessage(msg.TransactionalID, msg.UserID)
+	if err != nil {
+		return fmt.Errorf("get transactional message: %w", err)
+	}
+
+	e := &entities.TransactionalEvent{
+		Type:        entities.TransactionalEventSend,
+		Description: sendLog.Description,
+	}
+	m.Status = entities.TransactionalStatusSent
+	m.ProviderMessageID = sendLog.MessageID
+	if sendLog.Status != entities.SendLogStatusSuccessful {
+		e.Type = entities.TransactionalEventFailure
+		m.Status = entities.TransactionalStatusFailed
+	}
+	m.Description = sendLog.Description
+
+	return h.storage.LogTransactionalEvent(m, e)
+}
+
+// newAutomationLog returns the result of sending the automation email, instead of the send log
+// which is kept for the campaigns.
+func newAutomationLog(msg *entities.SenderTopicParams, sendLog *entities.SendLog) *entities.AutomationLog {
+	l := &entities.AutomationLog{
+		UserID:       msg.UserID,
+		AutomationID: msg.AutomationID,
+		StepID:       msg.AutomationStepID,
+		SubscriberID: msg.SubscriberID,
+		Status:       entities.AutomationLogStatusSent,
+		Description:  sendLog.Description,
+		MessageID:    sendLog.MessageID,
+	}
+	if sendLog.Status != entities.SendLogStatusSuccessful {
+		l.Status = entities.AutomationLogStatusFailed
+	}
+	return l
+}
+
+// campaignStatus returns the status of the campaign, the status is cached for a short duration.
+func (h *handler) campaignStatus(campaignID, userID int64) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s, ok := h.statuses[campaignID]; ok && time.Now().Before(s.expiresAt) {
+		return s.status, nil
+	}
+
+	campaign, err := h.storage.GetCampaign(campaignID, userID)
+	if err != nil {
+		return "", fmt.Errorf("get campaign: %w", err)
+	}
+
+	h.statuses[campaignID] = campaignStatus{
+		status:    campaign.Status,
+		expiresAt: time.Now().Add(statusCacheDuration),
+	}
+
+	return campaign.Status, nil
+}
+
+// waitForToken blocks until the user's send rate allows sending the message. The tokens are
+// shared between all sender instances, so the send rate is enforced across the consumers.
+// When the token isn't available within the max wait, the duration after which the message
+// should be retried is returned instead.
+func (h *handler) waitForToken(ctx context.Context, msg *entities.SenderTopicParams) (time.Duration, error) {
+	if h.localSender != nil {
+		return 0, nil
+	}
+
+	rate, err := h.sendRate(msg)
+	if err != nil {
+		return 0, fmt.Errorf("get send rate: %w", err)
+	}
+	if rate <= 0 {
+		return 0, nil
+	}
+
+	deadline := time.Now().Add(h.maxTokenWait)
+	burst := int64(math.Ceil(rate))
+	key := rateLimitPrefix + strconv.FormatInt(msg.UserID, 10)
+	for {
+		wait, err := h.cache.TakeToken(ctx, key, rate, burst)
+		if err != nil {
+			return 0, err
+		}
+		if wait == 0 {
+			return 0, nil
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return wait, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// requeue publishes the message again in its queue with the given delay, the received
+// message must be deleted afterwards.
+func (h *handler) requeue(ctx context.Context, m types.Message, msg *entities.SenderTopicParams, delaySeconds int32) error {
+	queueURL := h.queueURL
+	if msg.TransactionalID != "" {
+		queueURL = awssqs.SendEmailQueueURL(h.transactionalQueueURL)
+	}
+
+	_, err := h.sqsclient.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:     queueURL,
+		MessageBody:  m.Body,
+		DelaySeconds: delaySeconds,
+	})
+	return err
+}
+
+// sendRate returns the send rate of the user, the rate is cached for a short duration.
+// When the campaign is sent using SES the max send rate of the account is refreshed periodically.
+func (h *handler) sendRate(msg *entities.SenderTopicParams) (float64, error) {
+	h.mu.Lock()
+	r, ok := h.rates[msg.UserID]
+	h.mu.Unlock()
+	if ok && time.Now().Before(r.expiresAt) {
+		return r.rate, nil
+	}
+
+	rate, err := h.storage.GetSendRate(msg.UserID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+		rate = &entities.SendRate{UserID: msg.UserID}
+	}
+
+	if msg.DeliveryProviderID != 0 {
+		// the quota of the SES account doesn't apply to the other delivery providers
+		rate.QuotaSendRate = 0
+	} else if !rate.QuotaUpdatedAt.Valid || time.Since(rate.QuotaUpdatedAt.Time) > quotaRefreshInterval {
+		quota, err := h.refreshQuota(msg)
+		if err != nil {
+			// the previous quota is used until it is refreshed successfully.
+			logrus.WithField("user_id", msg.UserID).WithError(err).Warn("Unable to refresh the send quota")
+		} else {
+			rate.QuotaSendRate = quota
+		}
+	}
+
+	h.mu.Lock()
+	h.rates[msg.UserID] = sendRate{
+		rate:      rate.Rate(),
+		expiresAt: time.Now().Add(rateCacheDuration),
+	}
+	h.mu.Unlock()
+
+	return rate.Rate(), nil
+}
+
+// refreshQuota fetches the max send rate of the user's SES account and stores it.
+func (h *handler) refreshQuota(msg *entities.SenderTopicParams) (float64, error) {
+	keys := msg.SesKeys
+	if keys.AccessKey == "" || keys.SecretKey == "" || keys.Region == "" {
+		return 0, ErrInvalidSesKeys
+	}
+
+	client, err := emails.NewSesSenderFromCreds(keys.AccessKey, keys.SecretKey, keys.Region)
+	if err != nil {
+		return 0, fmt.Errorf("new ses sender: %w", err)
+	}
+
+	res, err := client.GetSendQuota(&ses.GetSendQuotaInput{})
+	if err != nil {
+		return 0, fmt.Errorf("get send quota: %w", err)
+	}
+
+	quota := aws.Float64Value(res.MaxSendRate)
+	err = h.storage.SetQuotaSendRate(msg.UserID, quota)
+	if err != nil {
+		return 0, fmt.Errorf("set quota send rate: %w", err)
+	}
+
+	return quota, nil
+}
+
+// newSender creates the sender for the user's delivery provider,
+// when the provider is not set the SES keys are used.
+func (h *handler) newSender(msg entities.SenderTopicParams) (emails.Sender, error) {
+	if h.localSender != nil {
+		return h.localSender, nil
+	}
+
+	if msg.DeliveryProviderID != 0 {
+		p, err := h.storage.GetDeliveryProviderByID(msg.DeliveryProviderID, msg.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("get delivery provider: %w", err)
+		}
+		return emails.NewSenderFromProvider(*p)
+	}
+
+	keys := msg.SesKeys
 	if keys.AccessKey == "" || keys.SecretKey == "" || keys.Region == "" {
 		return nil, ErrInvalidSesKeys
 	}
@@ -216,45 +562,44 @@ func newSesClient(keys entities.SesKeys) (emails.Sender, error) {
 	return client, nil
 }
 
-func sendEmail(client emails.Sender, msg entities.SenderTopicParams) (*ses.SendEmailOutput, error) {
-	input := &ses.SendEmailInput{
-		Destination: &ses.Destination{
-			ToAddresses: []*string{aws.String(msg.SubscriberEmail)},
-		},
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Html: &ses.Content{
-					Charset: aws.String(CharSet),
-					Data:    aws.String(string(msg.HTMLPart)),
-				},
-				Text: &ses.Content{
-					Charset: aws.String(CharSet),
-					Data:    aws.String(string(msg.TextPart)),
-				},
-			},
-			Subject: &ses.Content{
-				Charset: aws.String(CharSet),
-				Data:    aws.String(string(msg.SubjectPart)),
-			},
-		},
-		Source: aws.String(msg.Source),
-		Tags: []*ses.MessageTag{
-			{
-				Name:  aws.String("campaign_id"),
-				Value: aws.String(strconv.FormatInt(msg.CampaignID, 10)),
-			},
-			{
-				Name:  aws.String("user_id"),
-				Value: aws.String(msg.UserUUID),
-			},
+func newMessage(msg entities.SenderTopicParams) *emails.Message {
+	m := &emails.Message{
+		From:    msg.Source,
+		To:      []string{msg.SubscriberEmail},
+		Subject: string(msg.SubjectPart),
+		HTML:    msg.HTMLPart,
+		Text:    msg.TextPart,
+		Tags: map[string]string{
+			"user_id": msg.UserUUID,
 		},
 	}
 
+	switch {
+	case msg.TransactionalID != "":
+		m.Tags["transactional_id"] = msg.TransactionalID
+	case msg.AutomationID != 0:
+		m.Tags["automation_id"] = strconv.FormatInt(msg.AutomationID, 10)
+	default:
+		m.Tags["campaign_id"] = strconv.FormatInt(msg.CampaignID, 10)
+	}
+
+	if msg.VariantID != 0 {
+		m.Tags["variant_id"] = strconv.FormatInt(msg.VariantID, 10)
+	}
+
 	if msg.ConfigurationSetExists {
-		input.ConfigurationSetName = aws.String(emails.ConfigurationSetName)
+		m.ConfigurationSet = emails.ConfigurationSetName
+	}
+
+	// one-click unsubscribe headers required by the mailbox providers for bulk senders (RFC 8058).
+	if msg.ListUnsubscribeURL != "" {
+		m.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + msg.ListUnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
 	}
 
-	return client.SendEmail(input)
+	return m
 }
 
 func genCacheKey(prefix string, key string) string {
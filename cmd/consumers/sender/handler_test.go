@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/entities"
+	awssqs "github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+)
+
+func TestHandleMessageOfPausedCampaign(t *testing.T) {
+	db := storage.New(config.Config{
+		Storage: config.Storage{
+			DB: config.DB{
+				Driver:        "sqlite3",
+				Sqlite3Source: ":memory:",
+			},
+		},
+	})
+	s := storage.From(db)
+
+	// the fake queue records the messages which are sent to it.
+	sent := make(chan url.Values, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		form, err := url.ParseQuery(string(body))
+		assert.Nil(t, err)
+		sent <- form
+
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<SendMessageResponse><SendMessageResult><MessageId>1</MessageId></SendMessageResult></SendMessageResponse>`))
+	}))
+	defer srv.Close()
+
+	conf := config.Config{}
+	conf.Queue.Endpoint = srv.URL
+	client := awssqs.NewClientFrom(aws.Config{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+	}, conf)
+
+	u := &entities.User{
+		UUID:       "0a8f6c5e-07b1-4b4e-9d4c-0b6ad1e1c2f4",
+		Username:   "jane",
+		Active:     true,
+		Verified:   true,
+		BoundaryID: 1,
+	}
+	assert.Nil(t, s.CreateUser(u))
+
+	// the campaign was paused after the campaigner queued all of its subscribers.
+	campaign := &entities.Campaign{
+		Name:   "foo",
+		UserID: u.ID,
+		Status: entities.StatusSent,
+	}
+	campaign.SetEventID()
+	assert.Nil(t, s.CreateCampaign(campaign))
+	ok, err := s.UpdateCampaignStatus(campaign.ID, u.ID, entities.StatusPaused, entities.StatusSent)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	queueURL := srv.URL + "/queue/send-email"
+	h := newHandler(s, nil, client, awssqs.SendEmailQueueURL(&queueURL), nil, conf)
+
+	body, err := json.Marshal(entities.SenderTopicParams{
+		EventID:      *campaign.EventID,
+		UserID:       u.ID,
+		CampaignID:   campaign.ID,
+		SubscriberID: 1,
+	})
+	assert.Nil(t, err)
+	msg := string(body)
+
+	err = h.HandleMessage(context.Background(), types.Message{Body: &msg})
+	assert.Nil(t, err)
+
+	select {
+	case form := <-sent:
+		assert.Equal(t, "SendMessage", form.Get("Action"))
+		assert.Equal(t, queueURL, form.Get("QueueUrl"))
+		assert.Equal(t, msg, form.Get("MessageBody"))
+		assert.Equal(t, "300", form.Get("DelaySeconds"))
+	default:
+		t.Fatal("the message of the paused campaign wasn't requeued")
+	}
+
+	// the message is sent once the campaign is resumed, so nothing is logged while it's paused.
+	n, err := s.CountSendLogsByEventID(*campaign.EventID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+}
@@ -7,17 +7,21 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/google/wire"
+
+	appconfig "github.com/mailbadger/app/config"
 	awssqs "github.com/mailbadger/app/sqs"
 )
 
 //nolint
 var svcSet = wire.NewSet(
 	initAwsConfig,
-	awssqs.NewClient,
+	awssqs.NewClientFrom,
 	wire.Bind(new(awssqs.SendReceiveMessageAPI), new(*sqs.Client)),
 	awssqs.GetSendEmailQueueURL,
+	awssqs.GetTransactionalQueueURL,
 	newQueueURL,
 	awssqs.NewConsumerFrom,
+	newTransactionalConsumer,
 )
 
 func initAwsConfig(ctx context.Context) (aws.Config, error) {
@@ -27,3 +31,14 @@ func initAwsConfig(ctx context.Context) (aws.Config, error) {
 func newQueueURL(url awssqs.SendEmailQueueURL) awssqs.QueueURL {
 	return awssqs.QueueURL(url)
 }
+
+// transactionalConsumer polls the queue of the transactional emails.
+type transactionalConsumer awssqs.Consumer
+
+func newTransactionalConsumer(
+	conf appconfig.Config,
+	url awssqs.TransactionalQueueURL,
+	api awssqs.SendReceiveMessageAPI,
+) transactionalConsumer {
+	return transactionalConsumer(awssqs.NewConsumerFrom(conf, awssqs.QueueURL(url), api))
+}
@@ -10,6 +10,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/mailbadger/app/config"
+	"github.com/mailbadger/app/sqs"
 )
 
 func main() {
@@ -29,19 +30,29 @@ func main() {
 		logrus.WithError(err).Fatalln("unable to initialize app")
 	}
 
-	fn := func(ctx context.Context, m types.Message) func() error {
+	fn := func(ctx context.Context, m types.Message, del func(context.Context, types.Message) error) func() error {
 		return func() error {
-			err = app.handler.HandleMessage(ctx, m)
+			err := app.handler.HandleMessage(ctx, m)
 			if err != nil {
 				return err
 			}
-			return app.handler.DeleteMessage(ctx, m)
+			return del(ctx, m)
 		}
 	}
 
 	g := new(errgroup.Group)
+
+	// the transactional emails are polled from their own queue, so they are
+	// sent right away even when the campaigns have a large backlog.
+	g.Go(func() error {
+		for m := range sqs.Consumer(app.transactionalConsumer).PollSQS(ctx) {
+			g.Go(fn(ctx, m, app.handler.DeleteTransactionalMessage))
+		}
+		return nil
+	})
+
 	for m := range app.consumer.PollSQS(ctx) {
-		g.Go(fn(ctx, m))
+		g.Go(fn(ctx, m, app.handler.DeleteMessage))
 	}
 
 	if err := g.Wait(); err != nil {
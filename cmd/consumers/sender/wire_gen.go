@@ -27,28 +27,35 @@ func initApp(ctx context.Context, conf config.Config) (app, error) {
 	if err != nil {
 		return app{}, err
 	}
-	client := sqs.NewClient(awsConfig)
+	client := sqs.NewClientFrom(awsConfig, conf)
 	sendEmailQueueURL, err := sqs.GetSendEmailQueueURL(ctx, client)
 	if err != nil {
 		return app{}, err
 	}
-	mainHandler := newHandler(storageStorage, redisStore, sendEmailQueueURL)
+	transactionalQueueURL, err := sqs.GetTransactionalQueueURL(ctx, client)
+	if err != nil {
+		return app{}, err
+	}
+	mainHandler := newHandler(storageStorage, redisStore, client, sendEmailQueueURL, transactionalQueueURL, conf)
 	queueURL := newQueueURL(sendEmailQueueURL)
 	consumer := sqs.NewConsumerFrom(conf, queueURL, client)
-	mainApp := newApp(mainHandler, consumer)
+	mainTransactionalConsumer := newTransactionalConsumer(conf, transactionalQueueURL, client)
+	mainApp := newApp(mainHandler, consumer, mainTransactionalConsumer)
 	return mainApp, nil
 }
 
 // app.go:
 
 type app struct {
-	handler  *handler
-	consumer sqs.Consumer
+	handler               *handler
+	consumer              sqs.Consumer
+	transactionalConsumer transactionalConsumer
 }
 
-func newApp(h *handler, c sqs.Consumer) app {
+func newApp(h *handler, c sqs.Consumer, tc transactionalConsumer) app {
 	return app{
-		handler:  h,
-		consumer: c,
+		handler:               h,
+		consumer:              c,
+		transactionalConsumer: tc,
 	}
 }
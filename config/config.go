@@ -1,6 +1,10 @@
 package config
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
 
 type Config struct {
 	Storage  Storage
@@ -9,6 +13,8 @@ type Config struct {
 	Logging  Logging
 	Consumer Consumer
 	Social   Social
+	Delivery Delivery
+	Queue    Queue
 	Mode     string `envconfig:"MB_APP_MODE"`
 }
 
@@ -44,6 +50,10 @@ type Session struct {
 	Secure     bool   `envconfig:"MB_APP_SECURE_COOKIE"`
 	AuthKey    string `envconfig:"MB_APP_SESSION_AUTH_KEY"`
 	EncryptKey string `envconfig:"MB_APP_SESSION_ENCRYPT_KEY"`
+	// IdleTimeout is the time after which a session without any activity expires, zero disables it.
+	IdleTimeout time.Duration `envconfig:"MB_APP_SESSION_IDLE_TIMEOUT" default:"24h"`
+	// Lifetime is the time after which a session expires, regardless of its activity.
+	Lifetime time.Duration `envconfig:"MB_APP_SESSION_LIFETIME" default:"72h"`
 }
 
 type Server struct {
@@ -70,6 +80,19 @@ type Consumer struct {
 	MaxInFlightMsgs int32 `envconfig:"MB_APP_CONSUMER_MAX_INFLIGHT_MSGS" default:"10"`
 }
 
+// Delivery configures how the emails are delivered. In "local" mode the
+// emails are not sent, they are written to a maildir on the local disk.
+type Delivery struct {
+	Mode    string `envconfig:"MB_APP_DELIVERY_MODE"`
+	Maildir string `envconfig:"MB_APP_LOCAL_MAILDIR" default:"./maildir"`
+}
+
+// Queue configures the SQS client. The endpoint is set only when the queues are not
+// hosted on AWS, such as the localstack queues in the local setup.
+type Queue struct {
+	Endpoint string `envconfig:"MB_APP_SQS_ENDPOINT"`
+}
+
 type Social struct {
 	Github struct {
 		ClientID     string `envconfig:"MB_APP_GITHUB_CLIENT_ID"`
@@ -0,0 +1,189 @@
+package emails
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/utils"
+)
+
+func testMessage() *Message {
+	return &Message{
+		From:    "Foo <foo@example.com>",
+		To:      []string{"bar@example.com"},
+		Subject: "Hello",
+		HTML:    []byte("<p>Hello</p>"),
+		Text:    []byte("Hello"),
+		Headers: map[string]string{"X-Foo": "bar"},
+		Tags:    map[string]string{"campaign_id": "1"},
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	raw, err := testMessage().Bytes()
+	assert.Nil(t, err)
+
+	m, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	assert.Nil(t, err)
+	assert.Equal(t, "bar", m.Header.Get("X-Foo"))
+	assert.Equal(t, "Hello", m.Header.Get("Subject"))
+	assert.True(t, strings.HasPrefix(m.Header.Get("Content-Type"), "multipart/alternative"))
+
+	body, err := io.ReadAll(m.Body)
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), "<p>Hello</p>")
+
+	_, err = (&Message{From: "invalid"}).Bytes()
+	assert.NotNil(t, err)
+}
+
+func TestPostmarkSender(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token", r.Header.Get("X-Postmark-Server-Token"))
+
+		var email postmarkEmail
+		err := json.NewDecoder(r.Body).Decode(&email)
+		assert.Nil(t, err)
+
+		if email.To == "rejected@example.com" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		if email.To == "error@example.com" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		assert.Equal(t, "broadcast", email.MessageStream)
+		_, _ = w.Write([]byte(`{"MessageID":"abc"}`))
+	}))
+	defer srv.Close()
+
+	s := NewPostmarkSender("token", "broadcast").(*postmarkSender)
+	s.baseURL = srv.URL
+
+	id, err := s.Send(context.Background(), testMessage())
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", id)
+
+	msg := testMessage()
+	msg.To = []string{"rejected@example.com"}
+	_, err = s.Send(context.Background(), msg)
+	assert.True(t, errors.Is(err, ErrMessageRejected))
+
+	msg.To = []string{"error@example.com"}
+	_, err = s.Send(context.Background(), msg)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrMessageRejected))
+}
+
+func TestMailgunSender(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "api", user)
+		assert.Equal(t, "key", pass)
+		assert.Equal(t, "/example.com/messages", r.URL.Path)
+		assert.Equal(t, "bar", r.FormValue("h:X-Foo"))
+		assert.Equal(t, "1", r.FormValue("v:campaign_id"))
+
+		_, _ = w.Write([]byte(`{"id":"<abc@example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer srv.Close()
+
+	s := NewMailgunSender("example.com", "key", "").(*mailgunSender)
+	s.baseURL = srv.URL
+
+	id, err := s.Send(context.Background(), testMessage())
+	assert.Nil(t, err)
+	assert.Equal(t, "<abc@example.com>", id)
+}
+
+func TestSendGridSender(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+
+		var email sendgridEmail
+		err := json.NewDecoder(r.Body).Decode(&email)
+		assert.Nil(t, err)
+		assert.Equal(t, "foo@example.com", email.From.Email)
+		assert.Equal(t, "text/plain", email.Content[0].Type)
+
+		w.Header().Set("X-Message-Id", "abc")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s := NewSendGridSender("key").(*sendgridSender)
+	s.baseURL = srv.URL
+
+	id, err := s.Send(context.Background(), testMessage())
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", id)
+}
+
+func TestMaildir(t *testing.T) {
+	m := NewMaildir(t.TempDir())
+
+	msgs, err := m.List("uuid", 1)
+	assert.Nil(t, err)
+	assert.Empty(t, msgs)
+
+	msg := testMessage()
+	msg.Tags = map[string]string{"campaign_id": "1", "user_id": "uuid"}
+	msg.Subject = "Здраво"
+
+	id, err := m.Send(context.Background(), msg)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, id)
+
+	// system messages are written outside the campaign maildirs
+	_, err = m.Send(context.Background(), testMessage())
+	assert.Nil(t, err)
+
+	msgs, err = m.List("uuid", 1)
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, id, msgs[0].ID)
+	assert.Equal(t, "Здраво", msgs[0].Subject)
+	assert.Empty(t, msgs[0].HTML)
+
+	captured, err := m.Get("uuid", 1, id)
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>Hello</p>", captured.HTML)
+	assert.Equal(t, "Hello", captured.Text)
+	assert.Equal(t, "bar", captured.Headers["X-Foo"])
+
+	_, err = m.Get("uuid", 1, "../"+id)
+	assert.True(t, errors.Is(err, ErrMessageNotFound))
+
+	_, err = m.Get("uuid", 2, id)
+	assert.True(t, errors.Is(err, ErrMessageNotFound))
+
+	_, err = m.List("..", 1)
+	assert.NotNil(t, err)
+}
+
+func TestSMTPForbiddenAddress(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	for _, host := range []string{"127.0.0.1", "localhost", "169.254.169.254"} {
+		_, err = NewSMTPSender(host, port, "", "").Send(context.Background(), testMessage())
+		assert.True(t, errors.Is(err, utils.ErrForbiddenAddress), host)
+	}
+}
@@ -0,0 +1,36 @@
+package emails
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const httpTimeout = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+// doRequest executes the provider api request and returns the response body.
+// Client errors (except 429) are marked as rejected messages since retrying them won't help.
+func doRequest(provider string, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: do request: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: read response: %w", provider, err)
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp, body, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, nil, fmt.Errorf("%s: unexpected status %d: %s", provider, resp.StatusCode, body)
+	default:
+		return nil, nil, fmt.Errorf("%w: %s: status %d: %s", ErrMessageRejected, provider, resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,244 @@
+package emails
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// DeliveryModeLocal is the delivery mode in which the messages are written
+// to the local maildir instead of sending them.
+const DeliveryModeLocal = "local"
+
+// systemFolder is the folder for the messages which don't belong to a campaign.
+const systemFolder = "_system"
+
+// ErrMessageNotFound is returned when the captured message does not exist.
+var ErrMessageNotFound = errors.New("message not found")
+
+var maildirSeq uint64
+
+// Maildir is a Sender which writes the rendered MIME messages to a maildir
+// on the local disk. The messages are stored in a separate maildir per user and campaign
+// (<root>/<user uuid>/<campaign id>) so they can be listed by campaign.
+type Maildir struct {
+	root string
+}
+
+// NewMaildir creates a new Maildir sender with the given root directory.
+func NewMaildir(root string) *Maildir {
+	return &Maildir{root: root}
+}
+
+// Send writes the message to the maildir of the campaign.
+func (m *Maildir) Send(ctx context.Context, msg *Message) (string, error) {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	userUUID, campaignID := msg.Tags["user_id"], msg.Tags["campaign_id"]
+	if userUUID == "" || campaignID == "" {
+		userUUID, campaignID = systemFolder, systemFolder
+	}
+
+	dir, err := m.dir(userUUID, campaignID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o750); err != nil {
+			return "", fmt.Errorf("maildir: create dir: %w", err)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	id := fmt.Sprintf("%d.%d_%d.%s", time.Now().Unix(), os.Getpid(), atomic.AddUint64(&maildirSeq, 1), hostname)
+
+	// the message is written in tmp first and then moved to new, this way
+	// the readers never see partially written messages.
+	tmp := filepath.Join(dir, "tmp", id)
+	if err := os.WriteFile(tmp, raw, 0o640); err != nil {
+		return "", fmt.Errorf("maildir: write message: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "new", id)); err != nil {
+		return "", fmt.Errorf("maildir: move message: %w", err)
+	}
+
+	return id, nil
+}
+
+// List returns the messages captured for the given campaign, newest first.
+// The returned messages contain only the headers.
+func (m *Maildir) List(userUUID string, campaignID int64) ([]entities.CapturedMessage, error) {
+	dir, err := m.dir(userUUID, fmt.Sprint(campaignID))
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := []entities.CapturedMessage{}
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("maildir: read dir: %w", err)
+		}
+
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			msg, err := readMessage(filepath.Join(dir, sub, e.Name()), false)
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, *msg)
+		}
+	}
+
+	sort.Slice(msgs, func(i, j int) bool {
+		return msgs[i].Date.After(msgs[j].Date)
+	})
+
+	return msgs, nil
+}
+
+// Get returns the captured message with the decoded html and text parts.
+func (m *Maildir) Get(userUUID string, campaignID int64, id string) (*entities.CapturedMessage, error) {
+	if id == "" || filepath.Base(id) != id || strings.HasPrefix(id, ".") {
+		return nil, ErrMessageNotFound
+	}
+
+	dir, err := m.dir(userUUID, fmt.Sprint(campaignID))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sub := range []string{"new", "cur"} {
+		msg, err := readMessage(filepath.Join(dir, sub, id), true)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	return nil, ErrMessageNotFound
+}
+
+func (m *Maildir) dir(userUUID, campaignID string) (string, error) {
+	for _, p := range []string{userUUID, campaignID} {
+		if p == "" || filepath.Base(p) != p || strings.HasPrefix(p, ".") {
+			return "", fmt.Errorf("maildir: invalid path element %q", p)
+		}
+	}
+	return filepath.Join(m.root, userUUID, campaignID), nil
+}
+
+func readMessage(path string, withBody bool) (*entities.CapturedMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		return nil, fmt.Errorf("maildir: read message: %w", err)
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	captured := &entities.CapturedMessage{
+		ID:      filepath.Base(path),
+		From:    msg.Header.Get("From"),
+		To:      msg.Header.Get("To"),
+		Subject: subject,
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		captured.Date = date
+	}
+
+	if !withBody {
+		return captured, nil
+	}
+
+	captured.Headers = make(map[string]string, len(msg.Header))
+	for k := range msg.Header {
+		captured.Headers[k] = msg.Header.Get(k)
+	}
+
+	err = readBody(captured, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return captured, nil
+}
+
+func readBody(captured *entities.CapturedMessage, contentType, encoding string, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("maildir: read part: %w", err)
+			}
+
+			// the multipart reader decodes the quoted-printable parts transparently
+			err = readBody(captured, p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if strings.EqualFold(encoding, "quoted-printable") {
+		body = quotedprintable.NewReader(body)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, body); err != nil {
+		return fmt.Errorf("maildir: read body: %w", err)
+	}
+
+	switch mediaType {
+	case "text/html":
+		captured.HTML = buf.String()
+	case "text/plain":
+		captured.Text = buf.String()
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package emails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Mailgun api base urls
+const (
+	MailgunBaseURL   = "https://api.mailgun.net/v3"
+	MailgunEUBaseURL = "https://api.eu.mailgun.net/v3"
+)
+
+type mailgunSender struct {
+	baseURL string
+	domain  string
+	apiKey  string
+}
+
+// NewMailgunSender creates a new sender which delivers the messages using the Mailgun messages api.
+// When region is "eu" the EU api endpoint is used.
+func NewMailgunSender(domain, apiKey, region string) Sender {
+	baseURL := MailgunBaseURL
+	if strings.EqualFold(region, "eu") {
+		baseURL = MailgunEUBaseURL
+	}
+
+	return &mailgunSender{
+		baseURL: baseURL,
+		domain:  domain,
+		apiKey:  apiKey,
+	}
+}
+
+func (s *mailgunSender) Send(ctx context.Context, msg *Message) (string, error) {
+	form := url.Values{}
+	form.Set("from", msg.From)
+	for _, to := range msg.To {
+		form.Add("to", to)
+	}
+	form.Set("subject", msg.Subject)
+	if len(msg.HTML) > 0 {
+		form.Set("html", string(msg.HTML))
+	}
+	if len(msg.Text) > 0 {
+		form.Set("text", string(msg.Text))
+	}
+	if msg.ReplyTo != "" {
+		form.Set("h:Reply-To", msg.ReplyTo)
+	}
+	for k, v := range msg.Headers {
+		form.Set("h:"+k, v)
+	}
+	for k, v := range msg.Tags {
+		form.Set("v:"+k, v)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/messages", s.baseURL, url.PathEscape(s.domain))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("mailgun: new request: %w", err)
+	}
+	req.SetBasicAuth("api", s.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, body, err := doRequest("mailgun", req)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", fmt.Errorf("mailgun: unmarshal response: %w", err)
+	}
+
+	return res.ID, nil
+}
@@ -0,0 +1,139 @@
+package emails
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is a provider-neutral representation of an email message.
+type Message struct {
+	From    string
+	To      []string
+	ReplyTo string
+	Subject string
+	HTML    []byte
+	Text    []byte
+	// Headers holds additional headers which are added to the message.
+	Headers map[string]string
+	// Tags are key/value pairs attached to the message, used for tracking
+	// the events of the message on the provider side.
+	Tags map[string]string
+	// ConfigurationSet is the name of the SES configuration set, other providers ignore it.
+	ConfigurationSet string
+}
+
+// Bytes renders the message as a MIME encoded message with a multipart/alternative
+// body when both the text and html parts are set.
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("emails: parse from address: %w", err)
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("From", from.String())
+	h.Set("To", strings.Join(m.To, ", "))
+	if m.ReplyTo != "" {
+		h.Set("Reply-To", m.ReplyTo)
+	}
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	h.Set("Date", time.Now().UTC().Format(time.RFC1123Z))
+	h.Set("Message-ID", newMessageID(from.Address))
+	h.Set("MIME-Version", "1.0")
+	for k, v := range m.Headers {
+		h.Set(k, v)
+	}
+
+	switch {
+	case len(m.HTML) > 0 && len(m.Text) > 0:
+		mw := multipart.NewWriter(&buf)
+		h.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+		writeHeader(&buf, h)
+
+		if err := writePart(mw, "text/plain", m.Text); err != nil {
+			return nil, err
+		}
+		if err := writePart(mw, "text/html", m.HTML); err != nil {
+			return nil, err
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+	case len(m.HTML) > 0:
+		h.Set("Content-Type", "text/html; charset=UTF-8")
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, h)
+		if err := writeQuotedPrintable(&buf, m.HTML); err != nil {
+			return nil, err
+		}
+	default:
+		h.Set("Content-Type", "text/plain; charset=UTF-8")
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, h)
+		if err := writeQuotedPrintable(&buf, m.Text); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeHeader(buf *bytes.Buffer, h textproto.MIMEHeader) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func writePart(mw *multipart.Writer, contentType string, body []byte) error {
+	w, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return fmt.Errorf("emails: create %s part: %w", contentType, err)
+	}
+
+	return writeQuotedPrintable(w, body)
+}
+
+func writeQuotedPrintable(w io.Writer, body []byte) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write(body); err != nil {
+		return fmt.Errorf("emails: write body: %w", err)
+	}
+	return qw.Close()
+}
+
+func newMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
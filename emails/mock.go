@@ -1,6 +1,8 @@
 package emails
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/stretchr/testify/mock"
 )
@@ -10,9 +12,9 @@ type MockSender struct {
 	mock.Mock
 }
 
-func (m *MockSender) SendEmail(input *ses.SendEmailInput) (*ses.SendEmailOutput, error) {
-	args := m.Called(input)
-	return nil, args.Error(1)
+func (m *MockSender) Send(ctx context.Context, msg *Message) (string, error) {
+	args := m.Called(ctx, msg)
+	return args.String(0), args.Error(1)
 }
 
 func (m *MockSender) CreateConfigurationSet(input *ses.CreateConfigurationSetInput) (*ses.CreateConfigurationSetOutput, error) {
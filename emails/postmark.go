@@ -0,0 +1,89 @@
+package emails
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PostmarkBaseURL is the base url of the Postmark api.
+const PostmarkBaseURL = "https://api.postmarkapp.com"
+
+type postmarkSender struct {
+	baseURL       string
+	serverToken   string
+	messageStream string
+}
+
+type postmarkHeader struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+type postmarkEmail struct {
+	From          string            `json:"From"`
+	To            string            `json:"To"`
+	ReplyTo       string            `json:"ReplyTo,omitempty"`
+	Subject       string            `json:"Subject"`
+	HTMLBody      string            `json:"HtmlBody,omitempty"`
+	TextBody      string            `json:"TextBody,omitempty"`
+	Headers       []postmarkHeader  `json:"Headers,omitempty"`
+	Metadata      map[string]string `json:"Metadata,omitempty"`
+	MessageStream string            `json:"MessageStream,omitempty"`
+}
+
+// NewPostmarkSender creates a new sender which delivers the messages using the Postmark email api.
+// When the message stream is empty Postmark's default transactional stream is used.
+func NewPostmarkSender(serverToken, messageStream string) Sender {
+	return &postmarkSender{
+		baseURL:       PostmarkBaseURL,
+		serverToken:   serverToken,
+		messageStream: messageStream,
+	}
+}
+
+func (s *postmarkSender) Send(ctx context.Context, msg *Message) (string, error) {
+	email := postmarkEmail{
+		From:          msg.From,
+		To:            strings.Join(msg.To, ","),
+		ReplyTo:       msg.ReplyTo,
+		Subject:       msg.Subject,
+		HTMLBody:      string(msg.HTML),
+		TextBody:      string(msg.Text),
+		Metadata:      msg.Tags,
+		MessageStream: s.messageStream,
+	}
+	for k, v := range msg.Headers {
+		email.Headers = append(email.Headers, postmarkHeader{Name: k, Value: v})
+	}
+
+	payload, err := json.Marshal(email)
+	if err != nil {
+		return "", fmt.Errorf("postmark: marshal email: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/email", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("postmark: new request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Postmark-Server-Token", s.serverToken)
+
+	_, body, err := doRequest("postmark", req)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		MessageID string `json:"MessageID"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", fmt.Errorf("postmark: unmarshal response: %w", err)
+	}
+
+	return res.MessageID, nil
+}
@@ -0,0 +1,23 @@
+package emails
+
+import (
+	"fmt"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// NewSenderFromProvider creates a sender for the delivery provider configured by the user.
+func NewSenderFromProvider(p entities.DeliveryProvider) (Sender, error) {
+	switch p.Type {
+	case entities.DeliveryProviderSMTP:
+		return NewSMTPSender(p.Host, p.Port, p.Username, p.Password), nil
+	case entities.DeliveryProviderMailgun:
+		return NewMailgunSender(p.Domain, p.APIKey, p.Region), nil
+	case entities.DeliveryProviderPostmark:
+		return NewPostmarkSender(p.APIKey, p.MessageStream), nil
+	case entities.DeliveryProviderSendGrid:
+		return NewSendGridSender(p.APIKey), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedProvider, p.Type)
+	}
+}
@@ -1,14 +1,31 @@
 package emails
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"context"
+	"errors"
+
 	"github.com/aws/aws-sdk-go/service/ses"
 )
 
+// Sender errors
+var (
+	// ErrMessageRejected is returned when the provider permanently rejects the message,
+	// retrying the same message will not succeed.
+	ErrMessageRejected = errors.New("message rejected")
+	// ErrUnsupportedProvider is returned when the delivery provider type is unknown.
+	ErrUnsupportedProvider = errors.New("unsupported delivery provider")
+)
+
+// Sender is a provider-neutral interface for delivering email messages.
+// Send returns the message id assigned by the provider.
 type Sender interface {
-	SendEmail(input *ses.SendEmailInput) (*ses.SendEmailOutput, error)
+	Send(ctx context.Context, msg *Message) (string, error)
+}
+
+// SesSender is a Sender backed by Amazon SES which additionally exposes the
+// configuration set and quota operations of the SES api.
+type SesSender interface {
+	Sender
 	CreateConfigurationSet(input *ses.CreateConfigurationSetInput) (*ses.CreateConfigurationSetOutput, error)
 	DescribeConfigurationSet(input *ses.DescribeConfigurationSetInput) (*ses.DescribeConfigurationSetOutput, error)
 	CreateConfigurationSetEventDestination(input *ses.CreateConfigurationSetEventDestinationInput) (*ses.CreateConfigurationSetEventDestinationOutput, error)
@@ -16,10 +33,6 @@ type Sender interface {
 	GetSendQuota(input *ses.GetSendQuotaInput) (*ses.GetSendQuotaOutput, error)
 }
 
-type senderImpl struct {
-	*ses.SES
-}
-
 // SES Notification Types
 const (
 	SendType             = "Send"
@@ -33,33 +46,3 @@ const (
 
 	ConfigurationSetName = "MailbadgerEvents"
 )
-
-func NewSesSenderFromCreds(key, secret, region string) (Sender, error) {
-	conf := &aws.Config{
-		Region: aws.String(region),
-	}
-
-	if key != "" && secret != "" {
-		conf.Credentials = credentials.NewStaticCredentials(key, secret, "")
-	}
-
-	sess, err := session.NewSession(conf)
-	if err != nil {
-		return nil, err
-	}
-
-	client := ses.New(sess)
-
-	return &senderImpl{client}, nil
-}
-
-func NewSesSender() (Sender, error) {
-	sess, err := session.NewSession()
-	if err != nil {
-		return nil, err
-	}
-
-	client := ses.New(sess)
-
-	return &senderImpl{client}, nil
-}
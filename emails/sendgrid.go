@@ -0,0 +1,103 @@
+package emails
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+)
+
+// SendGridBaseURL is the base url of the SendGrid v3 api.
+const SendGridBaseURL = "https://api.sendgrid.com/v3"
+
+type sendgridSender struct {
+	baseURL string
+	apiKey  string
+}
+
+type sendgridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendgridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendgridPersonalization struct {
+	To []sendgridAddress `json:"to"`
+}
+
+type sendgridEmail struct {
+	Personalizations []sendgridPersonalization `json:"personalizations"`
+	From             sendgridAddress           `json:"from"`
+	ReplyTo          *sendgridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendgridContent         `json:"content"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	CustomArgs       map[string]string         `json:"custom_args,omitempty"`
+}
+
+// NewSendGridSender creates a new sender which delivers the messages using the SendGrid mail send api.
+func NewSendGridSender(apiKey string) Sender {
+	return &sendgridSender{
+		baseURL: SendGridBaseURL,
+		apiKey:  apiKey,
+	}
+}
+
+func (s *sendgridSender) Send(ctx context.Context, msg *Message) (string, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return "", fmt.Errorf("sendgrid: parse from address: %w", err)
+	}
+
+	p := sendgridPersonalization{}
+	for _, to := range msg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return "", fmt.Errorf("%w: sendgrid: parse recipient address: %s", ErrMessageRejected, err)
+		}
+		p.To = append(p.To, sendgridAddress{Email: addr.Address, Name: addr.Name})
+	}
+
+	email := sendgridEmail{
+		Personalizations: []sendgridPersonalization{p},
+		From:             sendgridAddress{Email: from.Address, Name: from.Name},
+		Subject:          msg.Subject,
+		Headers:          msg.Headers,
+		CustomArgs:       msg.Tags,
+	}
+	if msg.ReplyTo != "" {
+		email.ReplyTo = &sendgridAddress{Email: msg.ReplyTo}
+	}
+	// the text part must be first in the content list
+	if len(msg.Text) > 0 {
+		email.Content = append(email.Content, sendgridContent{Type: "text/plain", Value: string(msg.Text)})
+	}
+	if len(msg.HTML) > 0 {
+		email.Content = append(email.Content, sendgridContent{Type: "text/html", Value: string(msg.HTML)})
+	}
+
+	payload, err := json.Marshal(email)
+	if err != nil {
+		return "", fmt.Errorf("sendgrid: marshal email: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/mail/send", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("sendgrid: new request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _, err := doRequest("sendgrid", req)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Header.Get("X-Message-Id"), nil
+}
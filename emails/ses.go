@@ -0,0 +1,124 @@
+package emails
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+)
+
+// CharSet is used for the SES message body charset
+const CharSet = "UTF-8"
+
+type sesSender struct {
+	*ses.SES
+}
+
+// NewSesSenderFromCreds creates a new SES sender with the given credentials.
+func NewSesSenderFromCreds(key, secret, region string) (SesSender, error) {
+	conf := &aws.Config{
+		Region: aws.String(region),
+	}
+
+	if key != "" && secret != "" {
+		conf.Credentials = credentials.NewStaticCredentials(key, secret, "")
+	}
+
+	sess, err := session.NewSession(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	client := ses.New(sess)
+
+	return &sesSender{client}, nil
+}
+
+// NewSesSender creates a new SES sender using the default aws credentials chain.
+func NewSesSender() (Sender, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	client := ses.New(sess)
+
+	return &sesSender{client}, nil
+}
+
+// Send sends the message using the SES SendEmail api. Custom headers are not supported
+// by SendEmail, so messages with headers are sent as raw MIME messages.
+func (s *sesSender) Send(ctx context.Context, msg *Message) (string, error) {
+	var tags []*ses.MessageTag
+	for name, value := range msg.Tags {
+		tags = append(tags, &ses.MessageTag{
+			Name:  aws.String(name),
+			Value: aws.String(value),
+		})
+	}
+
+	var confSet *string
+	if msg.ConfigurationSet != "" {
+		confSet = aws.String(msg.ConfigurationSet)
+	}
+
+	if len(msg.Headers) > 0 {
+		raw, err := msg.Bytes()
+		if err != nil {
+			return "", err
+		}
+
+		out, err := s.SendRawEmailWithContext(ctx, &ses.SendRawEmailInput{
+			Destinations:         aws.StringSlice(msg.To),
+			Source:               aws.String(msg.From),
+			RawMessage:           &ses.RawMessage{Data: raw},
+			Tags:                 tags,
+			ConfigurationSetName: confSet,
+		})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(out.MessageId), nil
+	}
+
+	body := &ses.Body{
+		Html: &ses.Content{
+			Charset: aws.String(CharSet),
+			Data:    aws.String(string(msg.HTML)),
+		},
+	}
+	if len(msg.Text) > 0 {
+		body.Text = &ses.Content{
+			Charset: aws.String(CharSet),
+			Data:    aws.String(string(msg.Text)),
+		}
+	}
+
+	input := &ses.SendEmailInput{
+		Destination: &ses.Destination{
+			ToAddresses: aws.StringSlice(msg.To),
+		},
+		Message: &ses.Message{
+			Body: body,
+			Subject: &ses.Content{
+				Charset: aws.String(CharSet),
+				Data:    aws.String(msg.Subject),
+			},
+		},
+		Source:               aws.String(msg.From),
+		Tags:                 tags,
+		ConfigurationSetName: confSet,
+	}
+	if msg.ReplyTo != "" {
+		input.ReplyToAddresses = []*string{aws.String(msg.ReplyTo)}
+	}
+
+	out, err := s.SendEmailWithContext(ctx, input)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(out.MessageId), nil
+}
@@ -0,0 +1,148 @@
+package emails
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/mailbadger/app/utils"
+)
+
+const smtpTimeout = 30 * time.Second
+
+type smtpSender struct {
+	host     string
+	port     int
+	username string
+	password string
+}
+
+// NewSMTPSender creates a new sender which delivers the messages to the given SMTP server.
+// Port 465 uses implicit TLS, on the other ports STARTTLS is used when the server supports it.
+// The server is set by the users, so only the public addresses can be connected to.
+func NewSMTPSender(host string, port int, username, password string) Sender {
+	return &smtpSender{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+	}
+}
+
+func (s *smtpSender) Send(ctx context.Context, msg *Message) (string, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return "", fmt.Errorf("smtp: parse from address: %w", err)
+	}
+
+	id := newMessageID(from.Address)
+	m := *msg
+	m.Headers = map[string]string{"Message-ID": id}
+	for k, v := range msg.Headers {
+		m.Headers[k] = v
+	}
+
+	raw, err := m.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	c, err := s.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return "", smtpError("auth", err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return "", smtpError("mail from", err)
+	}
+	for _, to := range msg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return "", fmt.Errorf("%w: smtp: parse recipient address: %s", ErrMessageRejected, err)
+		}
+		if err := c.Rcpt(addr.Address); err != nil {
+			return "", smtpError("rcpt to", err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return "", smtpError("data", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return "", smtpError("write data", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", smtpError("data", err)
+	}
+
+	return id, c.Quit()
+}
+
+func (s *smtpSender) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	dialer := &net.Dialer{
+		Timeout: smtpTimeout,
+		Control: utils.PublicDialControl,
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp: dial: %w", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp: new client: %w", err)
+	}
+
+	if s.port != 465 {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+				c.Close()
+				return nil, fmt.Errorf("smtp: starttls: %w", err)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// smtpError marks the permanent (5xx) smtp errors as rejected messages,
+// the transient (4xx) and network errors are returned as they are.
+func smtpError(cmd string, err error) error {
+	var perr *textproto.Error
+	if errors.As(err, &perr) && perr.Code >= 500 {
+		return fmt.Errorf("%w: smtp: %s: %s", ErrMessageRejected, cmd, perr)
+	}
+	return fmt.Errorf("smtp: %s: %w", cmd, err)
+}
@@ -0,0 +1,114 @@
+package entities
+
+import (
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// A/B test winner metrics
+const (
+	WinnerMetricOpens  = "opens"
+	WinnerMetricClicks = "clicks"
+)
+
+// A/B test phases and statuses
+const (
+	// ABTestPhaseTest is the phase in which the variants are sent to the test group.
+	ABTestPhaseTest = "test"
+	// ABTestPhaseRemainder is the phase in which the winning variant is sent to the remaining subscribers.
+	ABTestPhaseRemainder = "remainder"
+
+	// ABTestStatusDraft indicates that the A/B test is not started yet.
+	ABTestStatusDraft = "draft"
+	// ABTestStatusTesting indicates that the variants are sent and the test waits for the winner to be picked.
+	ABTestStatusTesting = "testing"
+	// ABTestStatusCompleted indicates that the winner is picked and sent to the remaining subscribers.
+	ABTestStatusCompleted = "completed"
+)
+
+// ABTest holds the A/B test settings of a campaign. Each variant is sent to an equal part of
+// the test percentage of subscribers, after the wait duration the variant with the highest open
+// or click rate is picked as the winner and it's sent to the remaining subscribers.
+type ABTest struct {
+	CampaignID      int64             `json:"campaign_id" gorm:"column:campaign_id; primary_key:yes"`
+	UserID          int64             `json:"-" gorm:"column:user_id; index"`
+	TestPercentage  int               `json:"test_percentage"`
+	WaitMinutes     int               `json:"wait_minutes"`
+	WinnerMetric    string            `json:"winner_metric"`
+	WinnerVariantID *int64            `json:"winner_variant_id"`
+	Status          string            `json:"status"`
+	PickWinnerAt    NullTime          `json:"pick_winner_at"`
+	Params          JSON              `json:"-" gorm:"column:params; type:json"`
+	Variants        []CampaignVariant `json:"variants" gorm:"foreignKey:campaign_id;references:campaign_id"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// TableName overrides the default table name.
+func (ABTest) TableName() string {
+	return "campaign_ab_tests"
+}
+
+// CampaignVariant represents a variant of the campaign in an A/B test. The variant overrides
+// the subject or the template of the campaign.
+type CampaignVariant struct {
+	ID          int64   `json:"id" gorm:"column:id; primary_key:yes"`
+	UserID      int64   `json:"-" gorm:"column:user_id; index"`
+	CampaignID  int64   `json:"-" gorm:"column:campaign_id; index"`
+	Name        string  `json:"name"`
+	SubjectPart *string `json:"subject_part"`
+	TemplateID  *int64  `json:"-"`
+	// TemplateVersion is the version of the template which the variant is sent with, it's set when the campaign starts.
+	TemplateVersion int64         `json:"template_version,omitempty"`
+	BaseTemplate    *BaseTemplate `json:"template" gorm:"foreignKey:template_id"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// VariantStats represents the campaign stats of a single variant.
+type VariantStats struct {
+	Variant CampaignVariant `json:"variant"`
+	Winner  bool            `json:"winner"`
+	Stats   *CampaignStats  `json:"stats"`
+}
+
+// Group returns the index of the variant which the subscriber is assigned to, or -1 when the
+// subscriber is not in the test group. The subscribers are assigned by hashing the campaign
+// and subscriber ids, so the groups are the same in both phases of the test.
+func (t *ABTest) Group(subscriberID int64) int {
+	if len(t.Variants) == 0 {
+		return -1
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatInt(t.CampaignID, 10) + ":" + strconv.FormatInt(subscriberID, 10)))
+	sum := h.Sum64()
+
+	if sum%100 >= uint64(t.TestPercentage) {
+		return -1
+	}
+
+	return int((sum / 100) % uint64(len(t.Variants)))
+}
+
+// Rate returns the unique opens or clicks rate of the variant depending on the winner metric.
+func (s *VariantStats) Rate(metric string) float64 {
+	if s.Stats == nil || s.Stats.TotalSent == 0 {
+		return 0
+	}
+
+	var unique int64
+	switch metric {
+	case WinnerMetricClicks:
+		if s.Stats.Clicks != nil {
+			unique = s.Stats.Clicks.UniqueClicks
+		}
+	default:
+		if s.Stats.Opens != nil {
+			unique = s.Stats.Opens.Unique
+		}
+	}
+
+	return float64(unique) / float64(s.Stats.TotalSent)
+}
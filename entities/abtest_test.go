@@ -0,0 +1,44 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestABTestGroup(t *testing.T) {
+	test := &ABTest{CampaignID: 1, TestPercentage: 30}
+	assert.Equal(t, -1, test.Group(1))
+
+	test.Variants = []CampaignVariant{{ID: 1}, {ID: 2}}
+
+	groups := make(map[int]int)
+	for i := int64(1); i <= 10000; i++ {
+		g := test.Group(i)
+		assert.Equal(t, g, test.Group(i))
+		groups[g]++
+	}
+
+	// roughly 15% of the subscribers in each variant
+	assert.InDelta(t, 7000, groups[-1], 300)
+	assert.InDelta(t, 1500, groups[0], 200)
+	assert.InDelta(t, 1500, groups[1], 200)
+
+	test.TestPercentage = 100
+	for i := int64(1); i <= 100; i++ {
+		assert.NotEqual(t, -1, test.Group(i))
+	}
+}
+
+func TestVariantStatsRate(t *testing.T) {
+	s := &VariantStats{}
+	assert.Equal(t, float64(0), s.Rate(WinnerMetricOpens))
+
+	s.Stats = &CampaignStats{
+		TotalSent: 10,
+		Opens:     &OpensStats{Unique: 5},
+		Clicks:    &ClicksStats{UniqueClicks: 2},
+	}
+	assert.Equal(t, 0.5, s.Rate(WinnerMetricOpens))
+	assert.Equal(t, 0.2, s.Rate(WinnerMetricClicks))
+}
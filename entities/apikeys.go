@@ -1,15 +1,83 @@
 package entities
 
-import "time"
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// APIKeyScopeAll grants the access to the whole api.
+const APIKeyScopeAll = "*"
+
+const (
+	// APIKeyPrefix is prepended to the generated keys, so they are easy to recognize.
+	APIKeyPrefix = "mb_"
+	// APIKeyLegacyPrefix is the prefix of the keys which were created before the keys had a prefix.
+	APIKeyLegacyPrefix = "legacy"
+)
 
 // APIKey represents the user key used to authenticate requests
-// with the API.
+// with the API. Only the hash of the secret is stored, the key is returned once when it's created.
 type APIKey struct {
-	ID        int64     `json:"id" gorm:"column:id; primary_key:yes"`
-	UserID    int64     `json:"-"`
-	User      User      `json:"-"`
-	SecretKey string    `json:"secret_key"`
-	Active    bool      `json:"active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int64     `json:"id" gorm:"column:id; primary_key:yes"`
+	UserID     int64     `json:"-"`
+	User       User      `json:"-"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	SecretKey  string    `json:"-"`
+	Key        string    `json:"key,omitempty" gorm:"-"`
+	Scopes     Scopes    `json:"scopes" gorm:"column:scopes; type:json"`
+	Active     bool      `json:"active"`
+	ExpiresAt  NullTime  `json:"expires_at"`
+	LastUsedAt NullTime  `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Expired reports whether the key has expired.
+func (k APIKey) Expired() bool {
+	return k.ExpiresAt.Valid && time.Now().After(k.ExpiresAt.Time)
+}
+
+// HashAPIKey returns the hash of the key which is stored as the secret of the key.
+// The keys are random strings long enough, so a fast hash is sufficient.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Scopes is the list of scopes of an api key, e.g. subscribers:write or campaigns:read, stored as json.
+// The write scope includes the read scope of the resource.
+type Scopes []string
+
+// Value returns the json encoded scopes.
+func (s Scopes) Value() (driver.Value, error) {
+	if s == nil {
+		s = Scopes{}
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan decodes the json encoded scopes.
+func (s *Scopes) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*s = Scopes{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("invalid Scan Source")
+	}
+	return json.Unmarshal(b, s)
 }
@@ -0,0 +1,147 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Automation statuses.
+const (
+	AutomationStatusDraft  = "draft"
+	AutomationStatusActive = "active"
+	AutomationStatusPaused = "paused"
+)
+
+// Automation triggers, the subscribers are enrolled in the automation when the trigger occurs.
+const (
+	AutomationTriggerSubscriberCreated = "subscriber_created"
+	AutomationTriggerSegmentJoined     = "segment_joined"
+	AutomationTriggerLinkClicked       = "link_clicked"
+	AutomationTriggerMetadataChanged   = "metadata_changed"
+)
+
+// Automation step types.
+const (
+	AutomationStepDelay        = "delay"
+	AutomationStepCondition    = "condition"
+	AutomationStepSendTemplate = "send_template"
+)
+
+// Automation condition types, when the condition is not met the subscriber exits the automation.
+const (
+	AutomationConditionInSegment      = "in_segment"
+	AutomationConditionNotInSegment   = "not_in_segment"
+	AutomationConditionMetadataEquals = "metadata_equals"
+)
+
+// Automation run statuses.
+const (
+	AutomationRunStatusActive    = "active"
+	AutomationRunStatusCompleted = "completed"
+	AutomationRunStatusExited    = "exited"
+	AutomationRunStatusFailed    = "failed"
+)
+
+// Automation log statuses.
+const (
+	AutomationLogStatusSent   = "sent"
+	AutomationLogStatusFailed = "failed"
+)
+
+// Automation is a workflow of steps which each subscriber goes through once,
+// after the trigger of the automation occurs for the subscriber.
+type Automation struct {
+	Model
+	UserID             int64            `json:"-" gorm:"column:user_id; index"`
+	Name               string           `json:"name"`
+	Status             string           `json:"status"`
+	TriggerType        string           `json:"trigger_type"`
+	TriggerSegmentID   *int64           `json:"trigger_segment_id"`
+	TriggerCampaignID  *int64           `json:"trigger_campaign_id"`
+	TriggerLink        string           `json:"trigger_link"`
+	TriggerMetadataKey string           `json:"trigger_metadata_key"`
+	Steps              []AutomationStep `json:"steps" gorm:"foreignKey:AutomationID"`
+}
+
+// AutomationStep is a single step of the automation, the steps are executed in the order of their position.
+type AutomationStep struct {
+	ID                 int64     `json:"id" gorm:"column:id; primary_key:yes"`
+	AutomationID       int64     `json:"-"`
+	Position           int       `json:"position"`
+	Type               string    `json:"type"`
+	DelayMinutes       int       `json:"delay_minutes"`
+	ConditionType      string    `json:"condition_type"`
+	ConditionSegmentID *int64    `json:"condition_segment_id"`
+	ConditionKey       string    `json:"condition_key"`
+	ConditionValue     string    `json:"condition_value"`
+	TemplateID         *int64    `json:"template_id"`
+	Source             string    `json:"source"`
+	FromName           string    `json:"from_name"`
+	TemplateData       JSON      `json:"template_data" gorm:"column:template_data; type:json"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// AutomationRun holds the progress of a subscriber through the steps of the automation. The event id
+// is kept while the email of a step is being queued, so it is not sent twice if the step is retried.
+type AutomationRun struct {
+	ID           int64       `json:"id" gorm:"column:id; primary_key:yes"`
+	UserID       int64       `json:"-" gorm:"column:user_id; index"`
+	AutomationID int64       `json:"automation_id"`
+	SubscriberID int64       `json:"subscriber_id"`
+	Status       string      `json:"status"`
+	Position     int         `json:"position"`
+	EventID      ksuid.KSUID `json:"-"`
+	NextRunAt    NullTime    `json:"next_run_at"`
+	Description  string      `json:"description"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// AutomationLog is the result of sending the email of an automation step to the subscriber.
+type AutomationLog struct {
+	ID           int64     `json:"id" gorm:"column:id; primary_key:yes"`
+	UserID       int64     `json:"-" gorm:"column:user_id; index"`
+	AutomationID int64     `json:"automation_id"`
+	StepID       int64     `json:"step_id"`
+	SubscriberID int64     `json:"subscriber_id"`
+	Status       string    `json:"status"`
+	Description  string    `json:"description"`
+	MessageID    *string   `json:"message_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// GetTemplateData returns the default template data of the send template step.
+func (s *AutomationStep) GetTemplateData() (map[string]string, error) {
+	m := make(map[string]string)
+	if s.TemplateData.IsNull() {
+		return m, nil
+	}
+	err := json.Unmarshal(s.TemplateData, &m)
+	return m, err
+}
+
+// ConditionMet checks whether the subscriber meets the condition of the step.
+func (s *AutomationStep) ConditionMet(sub *Subscriber) (bool, error) {
+	switch s.ConditionType {
+	case AutomationConditionInSegment, AutomationConditionNotInSegment:
+		var in bool
+		for _, seg := range sub.Segments {
+			if s.ConditionSegmentID != nil && seg.ID == *s.ConditionSegmentID {
+				in = true
+				break
+			}
+		}
+		return in == (s.ConditionType == AutomationConditionInSegment), nil
+	case AutomationConditionMetadataEquals:
+		m, err := sub.GetMetadata()
+		if err != nil {
+			return false, err
+		}
+		return m[s.ConditionKey] == s.ConditionValue, nil
+	default:
+		return false, nil
+	}
+}
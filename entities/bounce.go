@@ -2,11 +2,16 @@ package entities
 
 import "time"
 
+// BounceTypePermanent is the type of the bounces after which the emails to the recipient should not be sent.
+const BounceTypePermanent = "Permanent"
+
 // Bounce entity holds information regarding bounced emails.
 type Bounce struct {
 	ID             int64     `json:"id" gorm:"column:id; primary_key:yes"`
 	UserID         int64     `json:"-"`
-	CampaignID     int64     `json:"campaign_id"`
+	CampaignID     int64     `json:"campaign_id" gorm:"default:null"`
+	VariantID      *int64    `json:"variant_id,omitempty"`
+	AutomationID   *int64    `json:"automation_id,omitempty"`
 	Recipient      string    `json:"recipient"`
 	Type           string    `json:"type"`
 	SubType        string    `json:"sub_type"`
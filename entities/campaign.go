@@ -16,21 +16,31 @@ const (
 	StatusSent = "sent"
 	// StatusScheduled indicates a scheduled campaign status.
 	StatusScheduled = "scheduled"
+	// StatusPaused indicates that the sending of the campaign is paused and it can be resumed.
+	StatusPaused = "paused"
+	// StatusCancelled indicates that the sending of the campaign was stopped for good.
+	StatusCancelled = "cancelled"
+	// StatusTesting indicates that the A/B test variants are sent and the campaign waits for the winner.
+	StatusTesting = "testing"
 )
 
 // Campaign represents the campaign entity
 type Campaign struct {
 	Model
-	UserID       int64             `json:"-" gorm:"column:user_id; index"`
-	EventID      *ksuid.KSUID      `json:"-"`
-	Name         string            `json:"name" gorm:"not null"`
-	TemplateID   int64             `json:"-"`
-	BaseTemplate *BaseTemplate     `json:"template" gorm:"foreignKey:template_id"`
-	Schedule     *CampaignSchedule `json:"schedule" gorm:"foreignKey:campaign_id"`
-	Status       string            `json:"status"`
-	CompletedAt  NullTime          `json:"completed_at" gorm:"column:completed_at"`
-	DeletedAt    NullTime          `json:"-" gorm:"column:deleted_at"`
-	StartedAt    NullTime          `json:"started_at" gorm:"column:started_at"`
+	UserID          int64             `json:"-" gorm:"column:user_id; index"`
+	EventID         *ksuid.KSUID      `json:"-"`
+	ParentID        *int64            `json:"parent_id"`
+	Name            string            `json:"name" gorm:"not null"`
+	TemplateID      int64             `json:"-"`
+	TemplateVersion int64             `json:"template_version"`
+	BaseTemplate    *BaseTemplate     `json:"template" gorm:"foreignKey:template_id"`
+	Schedule        *CampaignSchedule `json:"schedule" gorm:"foreignKey:campaign_id"`
+	Status          string            `json:"status"`
+	CompletedAt     NullTime          `json:"completed_at" gorm:"column:completed_at"`
+	DeletedAt       NullTime          `json:"-" gorm:"column:deleted_at"`
+	StartedAt       NullTime          `json:"started_at" gorm:"column:started_at"`
+	TrackOpens      bool              `json:"track_opens"`
+	TrackClicks     bool              `json:"track_clicks"`
 }
 
 // CampaignerTopicParams represent the request params used
@@ -45,6 +55,17 @@ type CampaignerTopicParams struct {
 	UserUUID               string            `json:"user_uuid"`
 	ConfigurationSetExists bool              `json:"configuration_set_exists"`
 	SesKeys                `json:"ses_keys"`
+	DeliveryProviderID     int64      `json:"delivery_provider_id,omitempty"`
+	ABTestPhase            string     `json:"ab_test_phase,omitempty"`
+	TemplateVersion        int64      `json:"template_version,omitempty"`
+	FeedItems              []FeedItem `json:"feed_items,omitempty"`
+	// DeliveryMode, LocalTime and Timezone are copied from the schedule, the subscribers are
+	// grouped in batches by their delivery time instead of being sent to right away.
+	DeliveryMode string `json:"delivery_mode,omitempty"`
+	LocalTime    string `json:"local_time,omitempty"`
+	Timezone     string `json:"timezone,omitempty"`
+	// BatchID is set when the scheduler releases a batch of subscribers of the campaign.
+	BatchID int64 `json:"batch_id,omitempty"`
 }
 
 // SenderTopicParams represent the request params used
@@ -62,6 +83,12 @@ type SenderTopicParams struct {
 	SubjectPart            []byte      `json:"subject_part"`
 	TextPart               []byte      `json:"text_part"`
 	SesKeys                SesKeys     `json:"ses_keys"`
+	DeliveryProviderID     int64       `json:"delivery_provider_id,omitempty"`
+	VariantID              int64       `json:"variant_id,omitempty"`
+	ListUnsubscribeURL     string      `json:"list_unsubscribe_url,omitempty"`
+	TransactionalID        string      `json:"transactional_id,omitempty"`
+	AutomationID           int64       `json:"automation_id,omitempty"`
+	AutomationStepID       int64       `json:"automation_step_id,omitempty"`
 }
 
 type CampaignTemplateData struct {
@@ -103,6 +130,23 @@ func (c *Campaign) SetEventID() {
 	c.EventID = &uid
 }
 
+// NewInstance creates an instance of the recurring campaign with the given name, the instance
+// is sent with its own event id so its stats are kept apart from the other instances.
+func (c *Campaign) NewInstance(name string) *Campaign {
+	uid := ksuid.New()
+	return &Campaign{
+		UserID:          c.UserID,
+		EventID:         &uid,
+		ParentID:        &c.ID,
+		Name:            name,
+		TemplateID:      c.TemplateID,
+		TemplateVersion: c.TemplateVersion,
+		Status:          StatusSending,
+		TrackOpens:      c.TrackOpens,
+		TrackClicks:     c.TrackClicks,
+	}
+}
+
 type OpensStats struct {
 	Unique int64 `json:"unique"`
 	Total  int64 `json:"total"`
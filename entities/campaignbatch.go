@@ -0,0 +1,84 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Campaign batch statuses.
+const (
+	CampaignBatchStatusPending  = "pending"
+	CampaignBatchStatusReleased = "released"
+	CampaignBatchStatusSent     = "sent"
+)
+
+// LocalTimeGrace is the period after the delivery time in which the subscribers are still sent to
+// right away, instead of waiting for the delivery time on the next day.
+const LocalTimeGrace = time.Hour
+
+// CampaignBatch holds the subscribers of a campaign which are sent to at the same time. The campaigner
+// groups the subscribers by their delivery time and the scheduler releases the batch once it's due.
+type CampaignBatch struct {
+	ID                int64       `json:"id" gorm:"column:id; primary_key:yes"`
+	UserID            int64       `json:"-"`
+	CampaignID        int64       `json:"campaign_id"`
+	EventID           ksuid.KSUID `json:"event_id"`
+	Timezone          string      `json:"timezone"`
+	ReleaseAt         time.Time   `json:"release_at"`
+	Status            string      `json:"status"`
+	Size              int         `json:"size"`
+	SubscriberIDsJSON JSON        `json:"-" gorm:"column:subscriber_ids; type:json"`
+	SubscriberIDs     []int64     `json:"-" sql:"-" gorm:"-"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+}
+
+// GetSubscriberIDs returns the ids of the subscribers in the batch.
+func (b *CampaignBatch) GetSubscriberIDs() ([]int64, error) {
+	var ids []int64
+
+	if !b.SubscriberIDsJSON.IsNull() {
+		err := json.Unmarshal(b.SubscriberIDsJSON, &ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.SubscriberIDs = ids
+
+	return ids, nil
+}
+
+// NextLocalTime returns the first occurrence of the time of the day in the location after t. When the
+// time of the day passed less than LocalTimeGrace ago, today's occurrence is returned.
+func NextLocalTime(t time.Time, loc *time.Location, hour, min int) time.Time {
+	lt := t.In(loc)
+	next := time.Date(lt.Year(), lt.Month(), lt.Day(), hour, min, 0, 0, loc)
+	if next.Before(t.Add(-LocalTimeGrace)) {
+		next = time.Date(lt.Year(), lt.Month(), lt.Day()+1, hour, min, 0, 0, loc)
+	}
+	return next.UTC()
+}
+
+// PeakOpenHour returns the hour of the day in the location at which most of the opens happened,
+// on a tie the earlier hour is returned. It returns false when there are no opens.
+func PeakOpenHour(opens []time.Time, loc *time.Location) (int, bool) {
+	if len(opens) == 0 {
+		return 0, false
+	}
+
+	var hours [24]int
+	for _, o := range opens {
+		hours[o.In(loc).Hour()]++
+	}
+
+	peak := 0
+	for h := 1; h < len(hours); h++ {
+		if hours[h] > hours[peak] {
+			peak = h
+		}
+	}
+
+	return peak, true
+}
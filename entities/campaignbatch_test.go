@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextLocalTime(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(t, err)
+	ny, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+
+	now := time.Date(2021, 3, 10, 6, 0, 0, 0, time.UTC)
+
+	// 15:00 in Tokyo, 9:00 already passed and the campaign is delivered tomorrow
+	assert.Equal(t, time.Date(2021, 3, 11, 0, 0, 0, 0, time.UTC), NextLocalTime(now, tokyo, 9, 0))
+	// 1:00 in New York, 9:00 is later today
+	assert.Equal(t, time.Date(2021, 3, 10, 14, 0, 0, 0, time.UTC), NextLocalTime(now, ny, 9, 0))
+	// 5:30 passed half an hour ago in UTC and it's delivered right away
+	assert.Equal(t, time.Date(2021, 3, 10, 5, 30, 0, 0, time.UTC), NextLocalTime(now, time.UTC, 5, 30))
+}
+
+func TestPeakOpenHour(t *testing.T) {
+	_, ok := PeakOpenHour(nil, time.UTC)
+	assert.False(t, ok)
+
+	opens := []time.Time{
+		time.Date(2021, 3, 1, 7, 10, 0, 0, time.UTC),
+		time.Date(2021, 3, 2, 18, 40, 0, 0, time.UTC),
+		time.Date(2021, 3, 3, 18, 5, 0, 0, time.UTC),
+		time.Date(2021, 3, 4, 7, 55, 0, 0, time.UTC),
+		time.Date(2021, 3, 5, 18, 20, 0, 0, time.UTC),
+	}
+	h, ok := PeakOpenHour(opens, time.UTC)
+	assert.True(t, ok)
+	assert.Equal(t, 18, h)
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(t, err)
+	h, _ = PeakOpenHour(opens, tokyo)
+	assert.Equal(t, 3, h)
+
+	// on a tie the earlier hour wins
+	h, _ = PeakOpenHour(opens[:2], time.UTC)
+	assert.Equal(t, 7, h)
+}
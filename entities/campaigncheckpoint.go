@@ -0,0 +1,44 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// CampaignCheckpoint holds the keyset cursor of the campaigner for a campaign event.
+// The cursor points to the last subscriber that was queued for sending, it is saved after
+// every batch and used for continuing the campaign from where it stopped when it's resumed
+// or when the campaigner message is redelivered.
+type CampaignCheckpoint struct {
+	EventID          ksuid.KSUID `json:"event_id" gorm:"column:event_id; primary_key:yes"`
+	UserID           int64       `json:"-" gorm:"column:user_id; index"`
+	CampaignID       int64       `json:"campaign_id"`
+	LastSubscriberID int64       `json:"last_subscriber_id"`
+	LastCreatedAt    time.Time   `json:"last_created_at"`
+	// Paused is set by the campaigner once it stops processing the paused campaign.
+	Paused bool `json:"paused"`
+	// Batched is set once all the subscribers of a local time or best time delivery are grouped
+	// in batches, the remaining batches are released by the scheduler.
+	Batched bool `json:"batched"`
+	// Enqueued and Failed are the number of subscribers which were queued for sending
+	// and the number of subscribers which failed to be queued.
+	Enqueued int64 `json:"enqueued"`
+	Failed   int64 `json:"failed"`
+	// Params are the campaigner params which are published again when the campaign is resumed.
+	Params    JSON      `json:"-" gorm:"column:params; type:json"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CampaignProgress represents the sending progress of a campaign.
+type CampaignProgress struct {
+	CampaignID int64        `json:"campaign_id"`
+	EventID    *ksuid.KSUID `json:"event_id"`
+	Status     string       `json:"status"`
+	Enqueued   int64        `json:"enqueued"`
+	Failed     int64        `json:"failed"`
+	UpdatedAt  *time.Time   `json:"updated_at"`
+	// Batches are the batches of the local time or best time delivery.
+	Batches []CampaignBatch `json:"batches,omitempty"`
+}
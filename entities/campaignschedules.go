@@ -2,11 +2,32 @@ package entities
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/segmentio/ksuid"
+
+	"github.com/mailbadger/app/utils"
+)
+
+// Recurrence rule types of the campaign schedule, the weekly and monthly rules are stored as cron expressions.
+const (
+	RecurrenceTypeCron    = "cron"
+	RecurrenceTypeWeekly  = "weekly"
+	RecurrenceTypeMonthly = "monthly"
 )
 
+// Delivery modes of the campaign schedule. In the local time mode each subscriber receives the campaign
+// at the local time of the schedule in their own time zone, in the best time mode at the hour of the day
+// when they usually open the emails.
+const (
+	DeliveryModeLocalTime = "local_time"
+	DeliveryModeBestTime  = "best_time"
+)
+
+// CampaignSchedule holds the time at which the campaign is sent. A recurring schedule sends a new
+// instance of the campaign on each occurrence of the cron expression, in the time zone of the schedule.
+// When the feed url is set, the instance is sent only if the feed has new items since the last one.
 type CampaignSchedule struct {
 	ID                      ksuid.KSUID       `json:"-" gorm:"column:id; primary_key:yes"`
 	UserID                  int64             `json:"-"`
@@ -18,6 +39,14 @@ type CampaignSchedule struct {
 	SegmentIDs              []int64           `json:"segment_ids" sql:"-" gorm:"-"`
 	DefaultTemplateDataJSON JSON              `json:"-"  gorm:"column:default_template_data; type:json"`
 	DefaultTemplateData     map[string]string `json:"default_template_data" sql:"-" gorm:"-"`
+	Recurrence              string            `json:"recurrence"`
+	Timezone                string            `json:"timezone"`
+	FeedURL                 string            `json:"feed_url"`
+	FeedLastItemAt          NullTime          `json:"feed_last_item_at"`
+	FeedSeenItemsJSON       JSON              `json:"-" gorm:"column:feed_seen_items; type:json"`
+	LastRunAt               NullTime          `json:"last_run_at"`
+	DeliveryMode            string            `json:"delivery_mode"`
+	LocalTime               string            `json:"local_time"`
 	CreatedAt               time.Time         `json:"created_at"`
 	UpdatedAt               time.Time         `json:"updated_at"`
 }
@@ -51,3 +80,63 @@ func (s *CampaignSchedule) GetSegmentIDs() ([]int64, error) {
 
 	return seg, nil
 }
+
+// GetFeedSeenItems returns the keys of the feed items which were seen on the last run.
+func (s *CampaignSchedule) GetFeedSeenItems() ([]string, error) {
+	var keys []string
+	if s.FeedSeenItemsJSON.IsNull() {
+		return keys, nil
+	}
+	err := json.Unmarshal(s.FeedSeenItemsJSON, &keys)
+	return keys, err
+}
+
+// SetFeedSeenItems stores the keys of the given feed items, so they are not sent again on the next runs.
+func (s *CampaignSchedule) SetFeedSeenItems(items []FeedItem) error {
+	keys := make([]string, 0, len(items))
+	for _, i := range items {
+		if len(keys) == MaxFeedSeenItems {
+			break
+		}
+		keys = append(keys, i.Key())
+	}
+	b, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	s.FeedSeenItemsJSON = b
+	return nil
+}
+
+// IsRecurring checks whether the schedule sends the campaign on each occurrence of the recurrence.
+func (s *CampaignSchedule) IsRecurring() bool {
+	return s.Recurrence != ""
+}
+
+// Location returns the time zone of the schedule, UTC is used when it's not set.
+func (s *CampaignSchedule) Location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+// NextRun returns the first occurrence of the recurrence after the given time.
+func (s *CampaignSchedule) NextRun(after time.Time) (time.Time, error) {
+	loc, err := s.Location()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load location: %w", err)
+	}
+
+	c, err := utils.ParseCron(s.Recurrence)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := c.Next(after.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("recurrence %q has no next occurrence", s.Recurrence)
+	}
+
+	return next.UTC(), nil
+}
@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+// CapturedMessage represents an email message which was written to the
+// local maildir when the local delivery mode is enabled.
+type CapturedMessage struct {
+	ID      string            `json:"id"`
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Subject string            `json:"subject"`
+	Date    time.Time         `json:"date"`
+	Headers map[string]string `json:"headers,omitempty"`
+	HTML    string            `json:"html,omitempty"`
+	Text    string            `json:"text,omitempty"`
+}
@@ -7,6 +7,7 @@ type Click struct {
 	ID         int64     `json:"id" gorm:"column:id; primary_key:yes"`
 	UserID     int64     `json:"-"`
 	CampaignID int64     `json:"campaign_id"`
+	VariantID  *int64    `json:"variant_id,omitempty"`
 	Recipient  string    `json:"recipient"`
 	Link       string    `json:"link"`
 	UserAgent  string    `json:"user_agent"`
@@ -7,6 +7,7 @@ type Delivery struct {
 	ID                   int64     `json:"id" gorm:"column:id; primary_key:yes"`
 	UserID               int64     `json:"-"`
 	CampaignID           int64     `json:"campaign_id"`
+	VariantID            *int64    `json:"variant_id,omitempty"`
 	Recipient            string    `json:"recipient"`
 	ProcessingTimeMillis int64     `json:"processing_time_millis"`
 	SMTPResponse         string    `json:"smtp_response"`
@@ -0,0 +1,35 @@
+package entities
+
+import "time"
+
+// Delivery provider types
+const (
+	DeliveryProviderSMTP     = "smtp"
+	DeliveryProviderMailgun  = "mailgun"
+	DeliveryProviderPostmark = "postmark"
+	DeliveryProviderSendGrid = "sendgrid"
+)
+
+// DeliveryProvider holds the settings of the email delivery provider chosen by the user.
+// When the user doesn't have a delivery provider set, the campaigns are sent using the SES keys.
+type DeliveryProvider struct {
+	ID            int64     `json:"id" gorm:"column:id; primary_key:yes"`
+	UserID        int64     `json:"-" gorm:"column:user_id; index"`
+	Type          string    `json:"type" gorm:"not null"`
+	Host          string    `json:"host,omitempty"`
+	Port          int       `json:"port,omitempty"`
+	Username      string    `json:"username,omitempty"`
+	Password      string    `json:"password,omitempty"`
+	APIKey        string    `json:"api_key,omitempty" gorm:"column:api_key"`
+	Domain        string    `json:"domain,omitempty"`
+	Region        string    `json:"region,omitempty"`
+	MessageStream string    `json:"message_stream,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ClearSecrets removes the password and api key so the provider can be returned to the client.
+func (p *DeliveryProvider) ClearSecrets() {
+	p.Password = ""
+	p.APIKey = ""
+}
@@ -0,0 +1,92 @@
+package entities
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// MaxFeedItems is the max number of feed items which are exposed to the template.
+	MaxFeedItems = 20
+	// MaxFeedSeenItems is the max number of feed items which are remembered as seen by the schedule.
+	MaxFeedSeenItems = 500
+)
+
+// FeedItem is an item of the RSS or Atom feed of a recurring campaign.
+type FeedItem struct {
+	Title       string    `json:"title"`
+	Link        string    `json:"link"`
+	Description string    `json:"description"`
+	GUID        string    `json:"guid"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// TemplateData returns the fields of the item, as they are exposed to the template
+// inside the feed_items section.
+func (i FeedItem) TemplateData() map[string]string {
+	m := map[string]string{
+		"title":       i.Title,
+		"link":        i.Link,
+		"description": i.Description,
+		"guid":        i.GUID,
+	}
+	if !i.PublishedAt.IsZero() {
+		m["published_at"] = i.PublishedAt.Format("January 2, 2006")
+	}
+	return m
+}
+
+// Key identifies the item across the fetches of the feed, the guid is used if it's set, then the link.
+func (i FeedItem) Key() string {
+	switch {
+	case i.GUID != "":
+		return i.GUID
+	case i.Link != "":
+		return i.Link
+	default:
+		return i.Title
+	}
+}
+
+// NewFeedItems returns the items which were not seen before, newest first. The publish date is used
+// only when the seen items are not known, for the feeds which were sent before the items were remembered,
+// since the dates are often missing or changed when an item is edited. All items are new when the feed
+// has not been sent before.
+func NewFeedItems(items []FeedItem, seen []string, after NullTime) []FeedItem {
+	seenKeys := make(map[string]bool, len(seen))
+	for _, k := range seen {
+		seenKeys[k] = true
+	}
+
+	var res []FeedItem
+	for _, i := range items {
+		switch {
+		case len(seenKeys) > 0:
+			if !seenKeys[i.Key()] {
+				res = append(res, i)
+			}
+		case !after.Valid || i.PublishedAt.After(after.Time):
+			res = append(res, i)
+		}
+	}
+
+	sort.SliceStable(res, func(a, b int) bool {
+		return res[a].PublishedAt.After(res[b].PublishedAt)
+	})
+	if len(res) > MaxFeedItems {
+		res = res[:MaxFeedItems]
+	}
+
+	return res
+}
+
+// LatestFeedItemAt returns the publish date of the newest item.
+func LatestFeedItemAt(items []FeedItem) time.Time {
+	var latest time.Time
+	for _, i := range items {
+		if i.PublishedAt.After(latest) {
+			latest = i.PublishedAt
+		}
+	}
+	return latest
+}
@@ -0,0 +1,58 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFeedItems(t *testing.T) {
+	now := time.Now().UTC()
+	items := []FeedItem{
+		{Title: "old", PublishedAt: now.Add(-48 * time.Hour)},
+		{Title: "undated"},
+		{Title: "newest", PublishedAt: now},
+		{Title: "new", PublishedAt: now.Add(-time.Hour)},
+	}
+
+	// all items are new when the feed was not sent before
+	res := NewFeedItems(items, nil, NullTime{})
+	assert.Len(t, res, 4)
+	assert.Equal(t, "newest", res[0].Title)
+
+	var after NullTime
+	after.SetValid(now.Add(-24 * time.Hour))
+	res = NewFeedItems(items, nil, after)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "newest", res[0].Title)
+	assert.Equal(t, "new", res[1].Title)
+	assert.Equal(t, now, LatestFeedItemAt(res))
+
+	after.SetValid(now)
+	assert.Empty(t, NewFeedItems(items, nil, after))
+
+	// the seen items are used instead of the publish date when they are known
+	items = []FeedItem{
+		{GUID: "1", Title: "edited", PublishedAt: now.Add(time.Hour)},
+		{Link: "https://example.com/2", Title: "seen link"},
+		{GUID: "3", Title: "backdated", PublishedAt: now.Add(-48 * time.Hour)},
+	}
+	cs := &CampaignSchedule{}
+	assert.NoError(t, cs.SetFeedSeenItems(items[:2]))
+	seen, err := cs.GetFeedSeenItems()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "https://example.com/2"}, seen)
+
+	res = NewFeedItems(items, seen, after)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "backdated", res[0].Title)
+
+	assert.Equal(t, map[string]string{
+		"title":        "newest",
+		"link":         "https://example.com/newest",
+		"description":  "",
+		"guid":         "",
+		"published_at": "March 1, 2021",
+	}, FeedItem{Title: "newest", Link: "https://example.com/newest", PublishedAt: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}.TemplateData())
+}
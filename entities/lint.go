@@ -0,0 +1,50 @@
+package entities
+
+// GmailClipSize is the size of the html part, in bytes, above which Gmail clips the message.
+const GmailClipSize = 102 * 1024
+
+// Lint rules of the campaign lint report.
+const (
+	LintRuleMissingUnsubscribeURL = "missing_unsubscribe_url"
+	LintRuleBrokenLink            = "broken_link"
+	LintRuleImageMissingAlt       = "image_missing_alt"
+	LintRuleHTMLTooLarge          = "html_too_large"
+	LintRuleMissingTextPart       = "missing_text_part"
+)
+
+// Severities of the lint issues.
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+)
+
+// EmailPreview holds the rendered subject, html and text parts of the email.
+type EmailPreview struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// LintIssue is a problem found in the email before it's sent.
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// LintReport lists the problems found in the email.
+type LintReport struct {
+	HTMLSize int         `json:"html_size"`
+	Issues   []LintIssue `json:"issues"`
+}
+
+// Add appends the issue to the report.
+func (r *LintReport) Add(rule, severity, message, detail string) {
+	r.Issues = append(r.Issues, LintIssue{
+		Rule:     rule,
+		Severity: severity,
+		Message:  message,
+		Detail:   detail,
+	})
+}
@@ -1,11 +1,23 @@
 package entities
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+// proxyUserAgents are the user agents of the image proxies which fetch the images on behalf of the
+// recipients, the ip address of such opens belongs to the mail provider and not to the recipient.
+var proxyUserAgents = []string{
+	"GoogleImageProxy",
+	"YahooMailProxy",
+	"ggpht.com",
+}
 
 type Open struct {
 	ID         int64     `json:"id" gorm:"column:id; primary_key:yes"`
 	UserID     int64     `json:"-"`
 	CampaignID int64     `json:"campaign_id"`
+	VariantID  *int64    `json:"variant_id,omitempty"`
 	Recipient  string    `json:"recipient"`
 	UserAgent  string    `json:"user_agent"`
 	IPAddress  string    `json:"ip_address"`
@@ -23,3 +35,17 @@ func (c Open) GetID() int64 {
 func (c Open) GetUpdatedAt() time.Time {
 	return time.Time{}
 }
+
+// IsProxied checks whether the open was made by an image proxy of the mail provider. Apple Mail Privacy
+// Protection fetches the images with the bare "Mozilla/5.0" user agent.
+func (c Open) IsProxied() bool {
+	if c.UserAgent == "Mozilla/5.0" {
+		return true
+	}
+	for _, ua := range proxyUserAgents {
+		if strings.Contains(c.UserAgent, ua) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package params
+
+import (
+	"strings"
+)
+
+// PutABTest represents request body for PUT /api/campaigns/{id}/ab-test
+type PutABTest struct {
+	TestPercentage int             `json:"test_percentage" validate:"required,gte=1,lte=100"`
+	WaitMinutes    int             `json:"wait_minutes" validate:"required,gte=1,lte=10080"`
+	WinnerMetric   string          `json:"winner_metric" validate:"required,oneof=opens clicks"`
+	Variants       []ABTestVariant `json:"variants" validate:"required,min=2,max=5,dive"`
+}
+
+// ABTestVariant represents a variant in the PutABTest request body. When the subject
+// or the template name are empty the ones of the campaign are used.
+type ABTestVariant struct {
+	Name         string `json:"name" validate:"required,max=191"`
+	SubjectPart  string `json:"subject_part" validate:"max=191"`
+	TemplateName string `json:"template_name" validate:"max=191"`
+}
+
+func (p *PutABTest) TrimSpaces() {
+	p.WinnerMetric = strings.TrimSpace(p.WinnerMetric)
+	for i := range p.Variants {
+		p.Variants[i].Name = strings.TrimSpace(p.Variants[i].Name)
+		p.Variants[i].TemplateName = strings.TrimSpace(p.Variants[i].TemplateName)
+	}
+}
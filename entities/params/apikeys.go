@@ -0,0 +1,30 @@
+package params
+
+import (
+	"strings"
+	"time"
+)
+
+// PostAPIKey represents request body for POST /api/api-keys. The scopes are read or write per resource,
+// the routes of the account are accessible only with the * scope.
+type PostAPIKey struct {
+	Name      string     `json:"name" validate:"required,max=191"`
+	Scopes    []string   `json:"scopes" validate:"required,gt=0,dive,oneof=* campaigns:read campaigns:write templates:read templates:write partials:read partials:write segments:read segments:write subscribers:read subscribers:write automations:read automations:write transactional:read transactional:write webhooks:read webhooks:write ses:read ses:write delivery-provider:read delivery-provider:write send-rate:read send-rate:write signup-form:read signup-form:write s3:read s3:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (p *PostAPIKey) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+}
+
+// PutAPIKey represents request body for PUT /api/api-keys/{id}
+type PutAPIKey struct {
+	Name      string     `json:"name" validate:"required,max=191"`
+	Scopes    []string   `json:"scopes" validate:"required,gt=0,dive,oneof=* campaigns:read campaigns:write templates:read templates:write partials:read partials:write segments:read segments:write subscribers:read subscribers:write automations:read automations:write transactional:read transactional:write webhooks:read webhooks:write ses:read ses:write delivery-provider:read delivery-provider:write send-rate:read send-rate:write signup-form:read signup-form:write s3:read s3:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Active    bool       `json:"active"`
+}
+
+func (p *PutAPIKey) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+}
@@ -1,5 +1,7 @@
 package params
 
+import "strings"
+
 // PostAuthenticate represents request body for POST /api/authenticate
 type PostAuthenticate struct {
 	Username string `json:"username" validate:"required"`
@@ -20,3 +22,14 @@ type PostSignUp struct {
 func (p *PostSignUp) TrimSpaces() {
 	// no trimming needed
 }
+
+// TwoFactorCode represents request body for POST /api/authenticate/two-factor and the endpoints of
+// /api/users/two-factor which require a code. The code is either the code of the authenticator app
+// or one of the recovery codes.
+type TwoFactorCode struct {
+	Code string `json:"code" validate:"required,max=191"`
+}
+
+func (p *TwoFactorCode) TrimSpaces() {
+	p.Code = strings.TrimSpace(p.Code)
+}
@@ -0,0 +1,67 @@
+package params
+
+import (
+	"strings"
+)
+
+// AutomationStep represents a single step in the request body of the automation.
+type AutomationStep struct {
+	Type               string            `json:"type" validate:"required,oneof=delay condition send_template"`
+	DelayMinutes       int               `json:"delay_minutes" validate:"required_if=Type delay,min=0,max=525600"`
+	ConditionType      string            `json:"condition_type" validate:"required_if=Type condition,omitempty,oneof=in_segment not_in_segment metadata_equals"`
+	ConditionSegmentID int64             `json:"condition_segment_id" validate:"required_if=ConditionType in_segment,required_if=ConditionType not_in_segment"`
+	ConditionKey       string            `json:"condition_key" validate:"required_if=ConditionType metadata_equals,max=191"`
+	ConditionValue     string            `json:"condition_value" validate:"max=191"`
+	TemplateID         int64             `json:"template_id" validate:"required_if=Type send_template"`
+	Source             string            `json:"source" validate:"required_if=Type send_template,omitempty,email,max=191"`
+	FromName           string            `json:"from_name" validate:"required_if=Type send_template,max=191"`
+	TemplateData       map[string]string `json:"template_data"`
+}
+
+func (p *AutomationStep) TrimSpaces() {
+	p.ConditionKey = strings.TrimSpace(p.ConditionKey)
+	p.Source = strings.TrimSpace(p.Source)
+	p.FromName = strings.TrimSpace(p.FromName)
+}
+
+// PostAutomation represents request body for POST /api/automations
+type PostAutomation struct {
+	Name               string           `json:"name" validate:"required,max=191"`
+	Status             string           `json:"status" validate:"omitempty,oneof=draft active paused"`
+	TriggerType        string           `json:"trigger_type" validate:"required,oneof=subscriber_created segment_joined link_clicked metadata_changed"`
+	TriggerSegmentID   int64            `json:"trigger_segment_id" validate:"required_if=TriggerType segment_joined"`
+	TriggerCampaignID  int64            `json:"trigger_campaign_id" validate:"required_if=TriggerType link_clicked"`
+	TriggerLink        string           `json:"trigger_link" validate:"omitempty,url,max=2048"`
+	TriggerMetadataKey string           `json:"trigger_metadata_key" validate:"max=191"`
+	Steps              []AutomationStep `json:"steps" validate:"required,gt=0,max=50,dive"`
+}
+
+func (p *PostAutomation) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+	p.TriggerLink = strings.TrimSpace(p.TriggerLink)
+	p.TriggerMetadataKey = strings.TrimSpace(p.TriggerMetadataKey)
+	for i := range p.Steps {
+		p.Steps[i].TrimSpaces()
+	}
+}
+
+// PutAutomation represents request body for PUT /api/automations/{id}
+type PutAutomation struct {
+	Name               string           `json:"name" validate:"required,max=191"`
+	Status             string           `json:"status" validate:"required,oneof=draft active paused"`
+	TriggerType        string           `json:"trigger_type" validate:"required,oneof=subscriber_created segment_joined link_clicked metadata_changed"`
+	TriggerSegmentID   int64            `json:"trigger_segment_id" validate:"required_if=TriggerType segment_joined"`
+	TriggerCampaignID  int64            `json:"trigger_campaign_id" validate:"required_if=TriggerType link_clicked"`
+	TriggerLink        string           `json:"trigger_link" validate:"omitempty,url,max=2048"`
+	TriggerMetadataKey string           `json:"trigger_metadata_key" validate:"max=191"`
+	Steps              []AutomationStep `json:"steps" validate:"required,gt=0,max=50,dive"`
+}
+
+func (p *PutAutomation) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+	p.TriggerLink = strings.TrimSpace(p.TriggerLink)
+	p.TriggerMetadataKey = strings.TrimSpace(p.TriggerMetadataKey)
+	for i := range p.Steps {
+		p.Steps[i].TrimSpaces()
+	}
+}
@@ -8,6 +8,8 @@ import (
 type PostCampaign struct {
 	Name         string `json:"name" validate:"required,max=191"`
 	TemplateName string `json:"template_name" validate:"required,max=191"`
+	TrackOpens   bool   `json:"track_opens"`
+	TrackClicks  bool   `json:"track_clicks"`
 }
 
 func (p *PostCampaign) TrimSpaces() {
@@ -19,6 +21,8 @@ func (p *PostCampaign) TrimSpaces() {
 type PutCampaign struct {
 	Name         string `json:"name" validate:"required,max=191"`
 	TemplateName string `json:"template_name" validate:"required,max=191"`
+	TrackOpens   bool   `json:"track_opens"`
+	TrackClicks  bool   `json:"track_clicks"`
 }
 
 func (p *PutCampaign) TrimSpaces() {
@@ -39,12 +43,50 @@ func (p *StartCampaign) TrimSpaces() {
 }
 
 type CampaignSchedule struct {
-	ScheduledAt         string            `json:"scheduled_at" validate:"required,datetime=2006-01-02 15:04:05,max=191"`
+	ScheduledAt         string            `json:"scheduled_at" validate:"required_without=Recurrence,omitempty,datetime=2006-01-02 15:04:05,max=191"`
 	FromName            string            `json:"from_name" validate:"required,max=191"`
 	DefaultTemplateData map[string]string `json:"default_template_data" validate:"dive,keys,required,alphanumhyphen,endkeys,required"`
 	Source              string            `json:"source" validate:"required,email,max=191"`
 	SegmentIDs          []int64           `json:"segment_ids" validate:"required,gt=0,dive,required"`
+	Recurrence          *Recurrence       `json:"recurrence" validate:"required_with=FeedURL"`
+	Timezone            string            `json:"timezone" validate:"omitempty,timezone,max=191"`
+	FeedURL             string            `json:"feed_url" validate:"omitempty,url,max=2048"`
+	DeliveryMode        string            `json:"delivery_mode" validate:"omitempty,oneof=local_time best_time"`
+	LocalTime           string            `json:"local_time" validate:"required_if=DeliveryMode local_time,omitempty,datetime=15:04"`
 }
 
 func (p *CampaignSchedule) TrimSpaces() {
+	p.Timezone = strings.TrimSpace(p.Timezone)
+	p.FeedURL = strings.TrimSpace(p.FeedURL)
+	p.LocalTime = strings.TrimSpace(p.LocalTime)
+	if p.Recurrence != nil {
+		p.Recurrence.Cron = strings.TrimSpace(p.Recurrence.Cron)
+	}
+}
+
+// Recurrence represents the rule of a recurring campaign schedule, either a cron
+// expression or a weekly or monthly rule at the given time of the day.
+type Recurrence struct {
+	Type       string `json:"type" validate:"required,oneof=cron weekly monthly"`
+	Cron       string `json:"cron" validate:"required_if=Type cron,max=191"`
+	Weekdays   []int  `json:"weekdays" validate:"required_if=Type weekly,max=7,dive,min=0,max=6"`
+	DayOfMonth int    `json:"day_of_month" validate:"required_if=Type monthly,min=0,max=31"`
+	Time       string `json:"time" validate:"required_unless=Type cron,omitempty,datetime=15:04"`
+}
+
+// SendTestCampaign represents request body for POST /api/campaigns/{id}/test-send
+type SendTestCampaign struct {
+	Recipients   []string          `json:"recipients" validate:"required,gt=0,max=10,dive,required,email,max=191"`
+	SubscriberID int64             `json:"subscriber_id"`
+	Source       string            `json:"source" validate:"required,email,max=191"`
+	FromName     string            `json:"from_name" validate:"required,max=191"`
+	TemplateData map[string]string `json:"template_data" validate:"dive,keys,required,alphanumhyphen,endkeys,required"`
+}
+
+func (p *SendTestCampaign) TrimSpaces() {
+	for i := range p.Recipients {
+		p.Recipients[i] = strings.TrimSpace(p.Recipients[i])
+	}
+	p.Source = strings.TrimSpace(p.Source)
+	p.FromName = strings.TrimSpace(p.FromName)
 }
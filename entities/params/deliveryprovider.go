@@ -0,0 +1,28 @@
+package params
+
+import (
+	"strings"
+)
+
+// PutDeliveryProvider represents request body for PUT /api/delivery-provider
+type PutDeliveryProvider struct {
+	Type          string `json:"type" validate:"required,oneof=smtp mailgun postmark sendgrid"`
+	Host          string `json:"host" validate:"required_if=Type smtp,max=191"`
+	Port          int    `json:"port" validate:"required_if=Type smtp,max=65535"`
+	Username      string `json:"username" validate:"max=191"`
+	Password      string `json:"password" validate:"max=191"`
+	APIKey        string `json:"api_key" validate:"required_unless=Type smtp,max=191"`
+	Domain        string `json:"domain" validate:"required_if=Type mailgun,max=191"`
+	Region        string `json:"region" validate:"max=30"`
+	MessageStream string `json:"message_stream" validate:"max=191"`
+}
+
+func (p *PutDeliveryProvider) TrimSpaces() {
+	p.Type = strings.TrimSpace(p.Type)
+	p.Host = strings.TrimSpace(p.Host)
+	p.Username = strings.TrimSpace(p.Username)
+	p.APIKey = strings.TrimSpace(p.APIKey)
+	p.Domain = strings.TrimSpace(p.Domain)
+	p.Region = strings.TrimSpace(p.Region)
+	p.MessageStream = strings.TrimSpace(p.MessageStream)
+}
@@ -2,17 +2,29 @@ package params
 
 import (
 	"strings"
+
+	"github.com/mailbadger/app/entities"
 )
 
 // Segment represents request body for POST /api/segments & PUT /api/segments/{id}
 type Segment struct {
-	Name string `json:"name" validate:"required,max=191"`
+	Name  string                 `json:"name" validate:"required,max=191"`
+	Rules *entities.SegmentRules `json:"rules"`
 }
 
 func (p *Segment) TrimSpaces() {
 	p.Name = strings.TrimSpace(p.Name)
 }
 
+// SegmentPreview represents request body for POST /api/segments/preview
+type SegmentPreview struct {
+	Rules *entities.SegmentRules `json:"rules" validate:"required"`
+}
+
+func (p *SegmentPreview) TrimSpaces() {
+	// no op
+}
+
 // SegmentSubs represents request body for PUT /api/segments/{id}/subscribers
 type SegmentSubs struct {
 	Ids []int64 `json:"ids[]" validate:"gt=0,dive,required"`
@@ -0,0 +1,10 @@
+package params
+
+// PutSendRate represents request body for PUT /api/send-rate
+type PutSendRate struct {
+	MaxSendRate float64 `json:"max_send_rate" validate:"gte=0,lte=100000"`
+}
+
+func (p *PutSendRate) TrimSpaces() {
+	// no-op
+}
@@ -0,0 +1,31 @@
+package params
+
+import "strings"
+
+// PutSignupForm represents request body for PUT /api/signup-form
+type PutSignupForm struct {
+	Enabled           bool    `json:"enabled"`
+	Recaptcha         bool    `json:"recaptcha"`
+	ConfirmTemplateID *int64  `json:"confirm_template_id" validate:"omitempty,gt=0"`
+	SuccessTemplateID *int64  `json:"success_template_id" validate:"omitempty,gt=0"`
+	SegmentIDs        []int64 `json:"segment_ids" validate:"omitempty,dive,gt=0"`
+}
+
+func (p *PutSignupForm) TrimSpaces() {
+	// no-op
+}
+
+// PostSubscribe represents request body for POST /api/subscribe/:uuid, the body
+// can be sent either as json or as a submitted html form.
+type PostSubscribe struct {
+	Name          string            `json:"name" form:"name" validate:"omitempty,min=1,max=191"`
+	Email         string            `json:"email" form:"email" validate:"required,email"`
+	SegmentIDs    []int64           `json:"segments" form:"segments" validate:"omitempty"`
+	Metadata      map[string]string `json:"metadata" form:"-" validate:"omitempty,dive,keys,required,alphanumhyphen,endkeys,required"`
+	TokenResponse string            `json:"token_response" form:"g-recaptcha-response" validate:"omitempty"`
+}
+
+func (p *PostSubscribe) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Email = strings.TrimSpace(p.Email)
+}
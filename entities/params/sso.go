@@ -0,0 +1,55 @@
+package params
+
+import (
+	"strings"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// SSOConnection represents request body for POST /api/sso-connections and PUT /api/sso-connections/{id}.
+// The settings of the identity provider are either set directly or imported from its SAML metadata,
+// given as a document or an url, or from the discovery url of the OpenID Connect provider.
+type SSOConnection struct {
+	Protocol       string                   `json:"protocol" validate:"required,oneof=saml oidc"`
+	Domain         string                   `json:"domain" validate:"required,fqdn,max=191"`
+	Enforced       bool                     `json:"enforced"`
+	DefaultRoleID  int64                    `json:"default_role_id" validate:"required"`
+	RoleAttribute  string                   `json:"role_attribute" validate:"max=191"`
+	RoleMappings   entities.SSORoleMappings `json:"role_mappings" validate:"max=50,dive"`
+	EmailAttribute string                   `json:"email_attribute" validate:"max=191"`
+
+	Metadata       string `json:"metadata"`
+	MetadataURL    string `json:"metadata_url" validate:"omitempty,url"`
+	IdPEntityID    string `json:"idp_entity_id" validate:"max=191"`
+	IdPSSOURL      string `json:"idp_sso_url" validate:"omitempty,url"`
+	IdPCertificate string `json:"idp_certificate"`
+
+	DiscoveryURL     string `json:"discovery_url" validate:"omitempty,url"`
+	Issuer           string `json:"issuer" validate:"omitempty,url,max=191"`
+	AuthorizationURL string `json:"authorization_url" validate:"omitempty,url"`
+	TokenURL         string `json:"token_url" validate:"omitempty,url"`
+	JWKSURL          string `json:"jwks_url" validate:"omitempty,url"`
+	ClientID         string `json:"client_id" validate:"max=191"`
+	ClientSecret     string `json:"client_secret" validate:"max=191"`
+}
+
+func (p *SSOConnection) TrimSpaces() {
+	p.Protocol = strings.TrimSpace(p.Protocol)
+	p.Domain = strings.ToLower(strings.TrimSpace(p.Domain))
+	p.RoleAttribute = strings.TrimSpace(p.RoleAttribute)
+	p.EmailAttribute = strings.TrimSpace(p.EmailAttribute)
+	for i := range p.RoleMappings {
+		p.RoleMappings[i].Value = strings.TrimSpace(p.RoleMappings[i].Value)
+	}
+	p.MetadataURL = strings.TrimSpace(p.MetadataURL)
+	p.IdPEntityID = strings.TrimSpace(p.IdPEntityID)
+	p.IdPSSOURL = strings.TrimSpace(p.IdPSSOURL)
+	p.IdPCertificate = strings.TrimSpace(p.IdPCertificate)
+	p.DiscoveryURL = strings.TrimSpace(p.DiscoveryURL)
+	p.Issuer = strings.TrimSpace(p.Issuer)
+	p.AuthorizationURL = strings.TrimSpace(p.AuthorizationURL)
+	p.TokenURL = strings.TrimSpace(p.TokenURL)
+	p.JWKSURL = strings.TrimSpace(p.JWKSURL)
+	p.ClientID = strings.TrimSpace(p.ClientID)
+	p.ClientSecret = strings.TrimSpace(p.ClientSecret)
+}
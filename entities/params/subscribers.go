@@ -56,3 +56,21 @@ type BulkRemoveSubscribers struct {
 func (p *BulkRemoveSubscribers) TrimSpaces() {
 	p.Filename = strings.TrimSpace(p.Filename)
 }
+
+// PostPreferences represents the form body for POST /api/preferences
+type PostPreferences struct {
+	Email       string  `form:"email" validate:"required,email"`
+	UUID        string  `form:"uuid" validate:"required,uuid"`
+	Token       string  `form:"t" validate:"required"`
+	Name        string  `form:"name" validate:"omitempty,max=191"`
+	SegmentIDs  []int64 `form:"segments" validate:"omitempty"`
+	PauseDays   int     `form:"pause_days" validate:"gte=0,lte=365"`
+	Unsubscribe bool    `form:"unsubscribe"`
+}
+
+func (p *PostPreferences) TrimSpaces() {
+	p.Email = strings.TrimSpace(p.Email)
+	p.UUID = strings.TrimSpace(p.UUID)
+	p.Token = strings.TrimSpace(p.Token)
+	p.Name = strings.TrimSpace(p.Name)
+}
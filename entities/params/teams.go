@@ -0,0 +1,67 @@
+package params
+
+import (
+	"strings"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// PostTeam represents request body for POST /api/team
+type PostTeam struct {
+	Name string `json:"name" validate:"required,max=191"`
+}
+
+func (p *PostTeam) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+}
+
+// PutTeam represents request body for PUT /api/team
+type PutTeam struct {
+	Name string `json:"name" validate:"required,max=191"`
+}
+
+func (p *PutTeam) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+}
+
+// TeamRole represents request body for POST /api/team/roles and PUT /api/team/roles/{id}
+type TeamRole struct {
+	Name        string                `json:"name" validate:"required,max=191,alphanumhyphen"`
+	Permissions []entities.Permission `json:"permissions" validate:"required,dive"`
+}
+
+func (p *TeamRole) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+	for i := range p.Permissions {
+		p.Permissions[i].Method = strings.ToUpper(strings.TrimSpace(p.Permissions[i].Method))
+		p.Permissions[i].Path = strings.TrimSpace(p.Permissions[i].Path)
+	}
+}
+
+// PutTeamMember represents request body for PUT /api/team/members/{id}
+type PutTeamMember struct {
+	RoleID int64 `json:"role_id" validate:"required"`
+}
+
+func (p *PutTeamMember) TrimSpaces() {
+	// no-op
+}
+
+// PostTeamInvitation represents request body for POST /api/team/invitations
+type PostTeamInvitation struct {
+	Email  string `json:"email" validate:"required,email,max=191"`
+	RoleID int64  `json:"role_id" validate:"required"`
+}
+
+func (p *PostTeamInvitation) TrimSpaces() {
+	p.Email = strings.TrimSpace(p.Email)
+}
+
+// AcceptTeamInvitation represents request body for POST /api/invitations/accept
+type AcceptTeamInvitation struct {
+	Token string `json:"token" validate:"required,max=191"`
+}
+
+func (p *AcceptTeamInvitation) TrimSpaces() {
+	p.Token = strings.TrimSpace(p.Token)
+}
@@ -1,13 +1,19 @@
 package params
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/mailbadger/app/entities"
+)
 
 // PostTemplate represents request body for POST /api/templates
 type PostTemplate struct {
 	Name        string `json:"name" validate:"required,max=191"`
 	HTMLPart    string `json:"html_part" validate:"required,html"`
-	TextPart    string `json:"text_part" validate:"required"`
+	TextPart    string `json:"text_part" validate:"required_unless=GenerateText true"`
 	SubjectPart string `json:"subject_part" validate:"required,max=191"`
+	LayoutID    *int64 `json:"layout_id"`
+	entities.HTMLPipeline
 }
 
 func (p *PostTemplate) TrimSpaces() {
@@ -18,12 +24,35 @@ func (p *PostTemplate) TrimSpaces() {
 // PutTemplate represents request body for PUT /api/templates
 type PutTemplate struct {
 	HTMLPart    string `json:"html_part" validate:"required,html"`
-	TextPart    string `json:"text_part" validate:"required"`
+	TextPart    string `json:"text_part" validate:"required_unless=GenerateText true"`
 	SubjectPart string `json:"subject_part" validate:"required,max=191"`
 	Name        string `json:"name" validate:"required,max=191"`
+	LayoutID    *int64 `json:"layout_id"`
+	entities.HTMLPipeline
 }
 
 func (p *PutTemplate) TrimSpaces() {
 	p.Name = strings.TrimSpace(p.Name)
 	p.SubjectPart = strings.TrimSpace(p.SubjectPart)
 }
+
+// PostPartial represents request body for POST /api/partials
+type PostPartial struct {
+	Name    string `json:"name" validate:"required,max=191,alphanumhyphen"`
+	Content string `json:"content" validate:"required"`
+	Layout  bool   `json:"layout"`
+}
+
+func (p *PostPartial) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+}
+
+// PutPartial represents request body for PUT /api/partials/:id
+type PutPartial struct {
+	Name    string `json:"name" validate:"required,max=191,alphanumhyphen"`
+	Content string `json:"content" validate:"required"`
+}
+
+func (p *PutPartial) TrimSpaces() {
+	p.Name = strings.TrimSpace(p.Name)
+}
@@ -0,0 +1,22 @@
+package params
+
+import (
+	"strings"
+)
+
+// SendTransactional represents request body for POST /api/transactional/send
+type SendTransactional struct {
+	TemplateID     int64             `json:"template_id" validate:"required"`
+	To             string            `json:"to" validate:"required,email,max=191"`
+	Source         string            `json:"source" validate:"required,email,max=191"`
+	FromName       string            `json:"from_name" validate:"required,max=191"`
+	TemplateData   map[string]string `json:"template_data" validate:"dive,keys,required,alphanumhyphen,endkeys,required"`
+	IdempotencyKey string            `json:"idempotency_key" validate:"max=191"`
+}
+
+func (p *SendTransactional) TrimSpaces() {
+	p.To = strings.TrimSpace(p.To)
+	p.Source = strings.TrimSpace(p.Source)
+	p.FromName = strings.TrimSpace(p.FromName)
+	p.IdempotencyKey = strings.TrimSpace(p.IdempotencyKey)
+}
@@ -0,0 +1,30 @@
+package params
+
+import (
+	"strings"
+)
+
+// PostWebhook represents request body for POST /api/webhooks
+type PostWebhook struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=191"`
+	Events []string `json:"events" validate:"required,gt=0,dive,oneof=subscriber.created subscriber.unsubscribed campaign.bounce campaign.complaint campaign.click automation.bounce automation.complaint"`
+}
+
+func (p *PostWebhook) TrimSpaces() {
+	p.URL = strings.TrimSpace(p.URL)
+	p.Secret = strings.TrimSpace(p.Secret)
+}
+
+// PutWebhook represents request body for PUT /api/webhooks/{id}
+type PutWebhook struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=191"`
+	Events []string `json:"events" validate:"required,gt=0,dive,oneof=subscriber.created subscriber.unsubscribed campaign.bounce campaign.complaint campaign.click automation.bounce automation.complaint"`
+	Active bool     `json:"active"`
+}
+
+func (p *PutWebhook) TrimSpaces() {
+	p.URL = strings.TrimSpace(p.URL)
+	p.Secret = strings.TrimSpace(p.Secret)
+}
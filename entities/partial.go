@@ -0,0 +1,174 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cbroglie/mustache"
+)
+
+// PartialBody is the name of the partial which holds the html part of the template in a layout.
+const PartialBody = "body"
+
+var (
+	ErrPartialCycle       = errors.New("partial includes itself")
+	ErrLayoutWithoutBody  = errors.New("layout without a body placeholder")
+	ErrPartialNameIsTaken = errors.New("partial name is reserved")
+)
+
+// Partial is a reusable block of content, included in the templates with `{{> name}}`. A layout is a
+// partial which wraps the html part of the template, placed where the layout includes `{{> body}}`.
+type Partial struct {
+	Model
+	UserID  int64  `json:"-"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Layout  bool   `json:"layout"`
+}
+
+// Validate checks that the layout has a body placeholder and that the name is not reserved.
+func (p *Partial) Validate() error {
+	if p.Name == PartialBody {
+		return ErrPartialNameIsTaken
+	}
+
+	names, err := PartialNames(p.Content)
+	if err != nil {
+		return err
+	}
+	if p.Layout {
+		for _, n := range names {
+			if n == PartialBody {
+				return nil
+			}
+		}
+		return ErrLayoutWithoutBody
+	}
+
+	return nil
+}
+
+// PartialSet holds the content of the partials by their name, it resolves the partials of the templates.
+// Unknown partials are rendered as empty, same as with the other mustache partial providers.
+type PartialSet map[string]string
+
+// NewPartialSet returns the set of the partials, the layouts can't be included so they are left out.
+func NewPartialSet(partials []Partial) PartialSet {
+	set := make(PartialSet, len(partials))
+	for _, p := range partials {
+		if !p.Layout {
+			set[p.Name] = p.Content
+		}
+	}
+	return set
+}
+
+// Get returns the content of the partial, it implements the mustache.PartialProvider interface.
+func (ps PartialSet) Get(name string) (string, error) {
+	return ps[name], nil
+}
+
+// Check returns an error when a partial includes itself, directly or through other partials,
+// since the template would be rendered endlessly.
+func (ps PartialSet) Check() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(ps))
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("%s: %w", name, ErrPartialCycle)
+		case done:
+			return nil
+		}
+
+		state[name] = visiting
+		names, err := PartialNames(ps[name])
+		if err != nil {
+			return fmt.Errorf("parse partial %s: %w", name, err)
+		}
+		for _, n := range names {
+			if _, ok := ps[n]; !ok {
+				continue
+			}
+			if err := visit(n); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+
+	for name := range ps {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dependents returns the name of the partial with the names of all the partials which include it,
+// directly or through other partials.
+func (ps PartialSet) Dependents(name string) []string {
+	includedBy := make(map[string][]string)
+	for p, content := range ps {
+		names, err := PartialNames(content)
+		if err != nil {
+			continue
+		}
+		for _, n := range names {
+			includedBy[n] = append(includedBy[n], p)
+		}
+	}
+
+	res := []string{name}
+	seen := map[string]bool{name: true}
+	for i := 0; i < len(res); i++ {
+		for _, p := range includedBy[res[i]] {
+			if !seen[p] {
+				seen[p] = true
+				res = append(res, p)
+			}
+		}
+	}
+	return res
+}
+
+// PartialNames returns the distinct names of the partials which are included in the mustache template.
+func PartialNames(content string) ([]string, error) {
+	tmpl, err := mustache.ParseStringPartials(content, PartialSet{})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(tags []mustache.Tag)
+	walk = func(tags []mustache.Tag) {
+		for _, tag := range tags {
+			switch tag.Type() {
+			case mustache.Partial:
+				if !seen[tag.Name()] {
+					seen[tag.Name()] = true
+					names = append(names, tag.Name())
+				}
+			case mustache.Section, mustache.InvertedSection:
+				walk(tag.Tags())
+			}
+		}
+	}
+	walk(tmpl.Tags())
+
+	return names, nil
+}
+
+// TemplatePartial records that the template includes the partial with the given name.
+type TemplatePartial struct {
+	UserID     int64  `json:"-"`
+	TemplateID int64  `json:"template_id" gorm:"primaryKey"`
+	Name       string `json:"name" gorm:"primaryKey"`
+}
@@ -0,0 +1,63 @@
+package entities
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialSet(t *testing.T) {
+	set := NewPartialSet([]Partial{
+		{Name: "footer", Content: "{{> legal}} <a href=\"{{unsubscribe_url}}\">Unsubscribe</a>"},
+		{Name: "legal", Content: "Example Inc."},
+		{Name: "header", Content: "<h1>News</h1>"},
+		{Name: "main", Content: "{{> header}}{{> body}}{{> footer}}", Layout: true},
+	})
+	assert.Nil(t, set.Check())
+
+	_, ok := set["main"]
+	assert.False(t, ok)
+
+	content, err := set.Get("footer")
+	assert.Nil(t, err)
+	assert.Contains(t, content, "Unsubscribe")
+
+	assert.ElementsMatch(t, []string{"legal", "footer"}, set.Dependents("legal"))
+	assert.Equal(t, []string{"header"}, set.Dependents("header"))
+
+	set["legal"] = "{{#show}}{{> footer}}{{/show}}"
+	assert.True(t, errors.Is(set.Check(), ErrPartialCycle))
+}
+
+func TestPartialValidate(t *testing.T) {
+	p := &Partial{Name: "body", Content: "foo"}
+	assert.Equal(t, ErrPartialNameIsTaken, p.Validate())
+
+	p = &Partial{Name: "main", Content: "<div>{{> header}}</div>", Layout: true}
+	assert.Equal(t, ErrLayoutWithoutBody, p.Validate())
+
+	p.Content = "<div>{{> header}}{{> body}}</div>"
+	assert.Nil(t, p.Validate())
+}
+
+func TestTemplateHasTagInPartials(t *testing.T) {
+	tmpl := Template{
+		HTMLPart: "<p>Hello</p>{{> footer}}",
+		Partials: PartialSet{
+			"footer": "{{> legal}}",
+			"legal":  "<a href=\"{{unsubscribe_url}}\">Unsubscribe</a>",
+		},
+	}
+	assert.True(t, tmpl.HasTag(TagUnsubscribeUrl))
+	assert.False(t, tmpl.HasTag(TagPreferencesUrl))
+
+	tmpl = Template{
+		HTMLPart: "<p>Hello</p>",
+		Layout:   &Partial{Content: "{{> body}}{{preferences_url}}", Layout: true},
+	}
+	assert.True(t, tmpl.HasTag(TagPreferencesUrl))
+
+	err := Template{BaseTemplate: BaseTemplate{SubjectPart: "Hi"}, HTMLPart: "{{> footer}}"}.ValidateData(nil)
+	assert.Nil(t, err)
+}
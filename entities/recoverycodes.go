@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// RecoveryCodesCount is the number of recovery codes which are generated for a user.
+const RecoveryCodesCount = 10
+
+// RecoveryCode is a single-use code which signs in the user instead of the code of the
+// two-factor authentication, e.g. when the device is lost. Only the hash of the code is stored.
+type RecoveryCode struct {
+	ID        int64     `json:"-" gorm:"column:id; primary_key:yes"`
+	UserID    int64     `json:"-" gorm:"column:user_id; index"`
+	Code      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HashRecoveryCode returns the sha256 hash of the normalized code, the codes are
+// matched regardless of the case and the dashes.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
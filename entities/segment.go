@@ -9,6 +9,7 @@ type Segment struct {
 	Model
 	Name        string       `json:"name" gorm:"not null" valid:"required,stringlength(1|191)"`
 	UserID      int64        `json:"-" gorm:"column:user_id; index"`
+	Rules       JSON         `json:"rules,omitempty" gorm:"column:rules; type:json"`
 	Subscribers []Subscriber `json:"-" gorm:"many2many:subscribers_segments;"`
 }
 
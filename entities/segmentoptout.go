@@ -0,0 +1,11 @@
+package entities
+
+import "time"
+
+// SegmentOptOut is created when a subscriber chooses to stop receiving
+// the campaigns sent to a segment from the preference center.
+type SegmentOptOut struct {
+	SegmentID    int64     `json:"segment_id" gorm:"column:segment_id; primary_key:yes"`
+	SubscriberID int64     `json:"subscriber_id" gorm:"column:subscriber_id; primary_key:yes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,167 @@
+package entities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Segment rule fields, metadata keys are prefixed with "metadata."
+const (
+	RuleFieldEmail      = "email"
+	RuleFieldName       = "name"
+	RuleFieldCreatedAt  = "created_at"
+	RuleFieldOpened     = "opened"
+	RuleFieldClicked    = "clicked"
+	RuleFieldBounced    = "bounced"
+	RuleFieldComplained = "complained"
+
+	RuleFieldMetadataPrefix = "metadata."
+)
+
+// Segment rule operators
+const (
+	RuleOpEquals        = "eq"
+	RuleOpNotEquals     = "neq"
+	RuleOpContains      = "contains"
+	RuleOpExists        = "exists"
+	RuleOpNotExists     = "not_exists"
+	RuleOpWithinDays    = "within_days"
+	RuleOpOlderThanDays = "older_than_days"
+	RuleOpAnyOfLast     = "any_of_last"
+	RuleOpNoneOfLast    = "none_of_last"
+	RuleOpIs            = "is"
+)
+
+// Segment rules match types
+const (
+	RuleMatchAll = "all"
+	RuleMatchAny = "any"
+)
+
+// ErrInvalidSegmentRules is returned when the segment rules can't be evaluated.
+var ErrInvalidSegmentRules = errors.New("invalid segment rules")
+
+var metadataKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9-_]{1,191}$`)
+
+// SegmentRules defines the subscribers of a dynamic segment. The conditions are
+// evaluated when the campaign is sent, so the segment is always up to date.
+type SegmentRules struct {
+	Match      string             `json:"match"`
+	Conditions []SegmentCondition `json:"conditions"`
+}
+
+// SegmentCondition is a single condition of the segment rules. The value is a string
+// for the text fields and a number of days or campaigns for the date and activity fields.
+//
+// Examples:
+//
+//	{"field": "metadata.plan", "op": "eq", "value": "pro"}
+//	{"field": "created_at", "op": "within_days", "value": 30}
+//	{"field": "opened", "op": "any_of_last", "value": 3}
+//	{"field": "bounced", "op": "is", "value": false}
+type SegmentCondition struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value"`
+}
+
+// GetRules returns the rules of the segment, it returns nil if the segment is a static list.
+func (s *Segment) GetRules() (*SegmentRules, error) {
+	if s.Rules.IsNull() {
+		return nil, nil
+	}
+
+	r := new(SegmentRules)
+	err := json.Unmarshal(s.Rules, r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSegmentRules, err)
+	}
+
+	return r, r.Validate()
+}
+
+// Validate checks that the fields, operators and values of the conditions are supported.
+func (r *SegmentRules) Validate() error {
+	if r.Match != RuleMatchAll && r.Match != RuleMatchAny {
+		return fmt.Errorf("%w: match must be one of: all any", ErrInvalidSegmentRules)
+	}
+	if len(r.Conditions) == 0 || len(r.Conditions) > 20 {
+		return fmt.Errorf("%w: between 1 and 20 conditions are allowed", ErrInvalidSegmentRules)
+	}
+
+	for _, c := range r.Conditions {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the operator and the value are supported by the field.
+func (c SegmentCondition) Validate() error {
+	var ops []string
+	switch {
+	case c.Field == RuleFieldEmail || c.Field == RuleFieldName:
+		ops = []string{RuleOpEquals, RuleOpNotEquals, RuleOpContains}
+	case strings.HasPrefix(c.Field, RuleFieldMetadataPrefix):
+		if !metadataKeyRegex.MatchString(c.MetadataKey()) {
+			return fmt.Errorf("%w: invalid metadata key in field %s", ErrInvalidSegmentRules, c.Field)
+		}
+		ops = []string{RuleOpEquals, RuleOpNotEquals, RuleOpContains, RuleOpExists, RuleOpNotExists}
+	case c.Field == RuleFieldCreatedAt:
+		ops = []string{RuleOpWithinDays, RuleOpOlderThanDays}
+	case c.Field == RuleFieldOpened || c.Field == RuleFieldClicked:
+		ops = []string{RuleOpAnyOfLast, RuleOpNoneOfLast}
+	case c.Field == RuleFieldBounced || c.Field == RuleFieldComplained:
+		ops = []string{RuleOpIs}
+	default:
+		return fmt.Errorf("%w: unknown field %s", ErrInvalidSegmentRules, c.Field)
+	}
+
+	var supported bool
+	for _, op := range ops {
+		supported = supported || op == c.Op
+	}
+	if !supported {
+		return fmt.Errorf("%w: field %s supports operators: %s", ErrInvalidSegmentRules, c.Field, strings.Join(ops, " "))
+	}
+
+	switch c.Op {
+	case RuleOpExists, RuleOpNotExists:
+	case RuleOpIs:
+		if _, ok := c.Value.(bool); !ok {
+			return fmt.Errorf("%w: value of field %s must be a boolean", ErrInvalidSegmentRules, c.Field)
+		}
+	case RuleOpWithinDays, RuleOpOlderThanDays, RuleOpAnyOfLast, RuleOpNoneOfLast:
+		if n, ok := c.IntValue(); !ok || n < 1 || n > 3650 {
+			return fmt.Errorf("%w: value of field %s must be a number between 1 and 3650", ErrInvalidSegmentRules, c.Field)
+		}
+	default:
+		if _, ok := c.Value.(string); !ok {
+			return fmt.Errorf("%w: value of field %s must be a string", ErrInvalidSegmentRules, c.Field)
+		}
+	}
+
+	return nil
+}
+
+// MetadataKey returns the metadata key of the field.
+func (c SegmentCondition) MetadataKey() string {
+	return strings.TrimPrefix(c.Field, RuleFieldMetadataPrefix)
+}
+
+// IntValue returns the value as an integer, json numbers are decoded as floats.
+func (c SegmentCondition) IntValue() (int, bool) {
+	switch v := c.Value.(type) {
+	case float64:
+		return int(v), v == float64(int(v))
+	case int:
+		return v, true
+	default:
+		return 0, false
+	}
+}
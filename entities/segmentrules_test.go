@@ -0,0 +1,65 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentRulesValidate(t *testing.T) {
+	cases := []struct {
+		desc  string
+		cond  SegmentCondition
+		valid bool
+	}{
+		{"email contains", SegmentCondition{Field: RuleFieldEmail, Op: RuleOpContains, Value: "@example.com"}, true},
+		{"name exists", SegmentCondition{Field: RuleFieldName, Op: RuleOpExists}, false},
+		{"metadata exists", SegmentCondition{Field: "metadata.plan", Op: RuleOpExists}, true},
+		{"invalid metadata key", SegmentCondition{Field: "metadata.pl\"an", Op: RuleOpExists}, false},
+		{"metadata numeric value", SegmentCondition{Field: "metadata.plan", Op: RuleOpEquals, Value: float64(1)}, false},
+		{"created within days", SegmentCondition{Field: RuleFieldCreatedAt, Op: RuleOpWithinDays, Value: float64(30)}, true},
+		{"created within fractional days", SegmentCondition{Field: RuleFieldCreatedAt, Op: RuleOpWithinDays, Value: 1.5}, false},
+		{"opened any of last", SegmentCondition{Field: RuleFieldOpened, Op: RuleOpAnyOfLast, Value: float64(3)}, true},
+		{"clicked none of last zero", SegmentCondition{Field: RuleFieldClicked, Op: RuleOpNoneOfLast, Value: float64(0)}, false},
+		{"bounced is", SegmentCondition{Field: RuleFieldBounced, Op: RuleOpIs, Value: false}, true},
+		{"complained is string", SegmentCondition{Field: RuleFieldComplained, Op: RuleOpIs, Value: "true"}, false},
+		{"unknown field", SegmentCondition{Field: "foo", Op: RuleOpEquals, Value: "bar"}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := &SegmentRules{Match: RuleMatchAll, Conditions: []SegmentCondition{tc.cond}}
+			err := r.Validate()
+			if tc.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidSegmentRules)
+			}
+		})
+	}
+
+	r := &SegmentRules{Match: "none", Conditions: []SegmentCondition{{Field: RuleFieldBounced, Op: RuleOpIs, Value: true}}}
+	assert.ErrorIs(t, r.Validate(), ErrInvalidSegmentRules)
+
+	r = &SegmentRules{Match: RuleMatchAny}
+	assert.ErrorIs(t, r.Validate(), ErrInvalidSegmentRules)
+}
+
+func TestSegmentGetRules(t *testing.T) {
+	s := &Segment{}
+	rules, err := s.GetRules()
+	assert.Nil(t, err)
+	assert.Nil(t, rules)
+
+	s.Rules = JSON(`{"match":"any","conditions":[{"field":"opened","op":"any_of_last","value":3}]}`)
+	rules, err = s.GetRules()
+	assert.Nil(t, err)
+	assert.Equal(t, RuleMatchAny, rules.Match)
+	n, ok := rules.Conditions[0].IntValue()
+	assert.True(t, ok)
+	assert.Equal(t, 3, n)
+
+	s.Rules = JSON(`{"match":`)
+	_, err = s.GetRules()
+	assert.ErrorIs(t, err, ErrInvalidSegmentRules)
+}
@@ -27,6 +27,7 @@ type SendLog struct {
 	EventID      ksuid.KSUID `json:"event_id"`
 	SubscriberID int64       `json:"-" gorm:"column:subscriber_id"`
 	CampaignID   int64       `json:"campaign_id" gorm:"column:campaign_id; index"`
+	VariantID    *int64      `json:"variant_id,omitempty"`
 	Status       string      `json:"status"`
 	Description  string      `json:"description"`
 	CreatedAt    time.Time   `json:"created_at"`
@@ -0,0 +1,37 @@
+package entities
+
+import "time"
+
+// SendRate holds the sending rate limits of the user in emails per second. The quota rate is
+// the max send rate of the SES account which is refreshed periodically by the sender, while
+// the max send rate is an optional cap configured by the user.
+type SendRate struct {
+	UserID         int64     `json:"-" gorm:"column:user_id; primary_key:yes"`
+	MaxSendRate    float64   `json:"max_send_rate"`
+	QuotaSendRate  float64   `json:"quota_send_rate"`
+	QuotaUpdatedAt NullTime  `json:"quota_updated_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// SendRateStats represents the configured and the current sending rate of the user.
+type SendRateStats struct {
+	SendRate
+	Rate        float64 `json:"rate"`
+	CurrentRate float64 `json:"current_rate"`
+}
+
+// Rate returns the effective sending rate, the lower of the quota and the user cap.
+// Zero means the sending rate is not limited.
+func (r *SendRate) Rate() float64 {
+	switch {
+	case r.MaxSendRate <= 0:
+		return r.QuotaSendRate
+	case r.QuotaSendRate <= 0:
+		return r.MaxSendRate
+	case r.MaxSendRate < r.QuotaSendRate:
+		return r.MaxSendRate
+	default:
+		return r.QuotaSendRate
+	}
+}
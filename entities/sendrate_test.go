@@ -0,0 +1,24 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendRate(t *testing.T) {
+	r := &SendRate{}
+	assert.Equal(t, float64(0), r.Rate())
+
+	r.QuotaSendRate = 14
+	assert.Equal(t, float64(14), r.Rate())
+
+	r.MaxSendRate = 5
+	assert.Equal(t, float64(5), r.Rate())
+
+	r.MaxSendRate = 20
+	assert.Equal(t, float64(14), r.Rate())
+
+	r.QuotaSendRate = 0
+	assert.Equal(t, float64(20), r.Rate())
+}
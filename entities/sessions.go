@@ -5,10 +5,20 @@ import "time"
 // Session represents a user session which maps the session id stored in the cookie
 // to the user that is currently signed in.
 type Session struct {
-	ID        int64 `gorm:"column:id; primary_key:yes"`
-	UserID    int64 `gorm:"column:user_id; index"`
-	User      User
-	SessionID string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID        int64  `json:"id" gorm:"column:id; primary_key:yes"`
+	UserID    int64  `json:"-" gorm:"column:user_id; index"`
+	User      User   `json:"-"`
+	SessionID string `json:"-"`
+	// TwoFactorPending is set for the sessions of the users who entered their password
+	// and have yet to enter the code of the two-factor authentication.
+	TwoFactorPending  bool      `json:"-"`
+	TwoFactorAttempts int       `json:"-"`
+	IP                string    `json:"ip"`
+	UserAgent         string    `json:"user_agent"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"-"`
+
+	// Current is set for the session of the request.
+	Current bool `json:"current" gorm:"-"`
 }
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SignupForm holds the settings of the user's public subscribe endpoint which is used
+// by embeddable signup forms. Subscribers are added only after they confirm their email address.
+type SignupForm struct {
+	UserID            int64     `json:"-" gorm:"column:user_id; primary_key:yes"`
+	Enabled           bool      `json:"enabled"`
+	Recaptcha         bool      `json:"recaptcha"`
+	ConfirmTemplateID *int64    `json:"confirm_template_id"`
+	SuccessTemplateID *int64    `json:"success_template_id"`
+	SegmentIDs        JSON      `json:"segment_ids" gorm:"column:segment_ids; type:json"`
+	SubscribeURL      string    `json:"subscribe_url" gorm:"-"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// SubscriberConsent is the record of a subscription made through the public subscribe endpoint,
+// it is kept as a proof of consent.
+type SubscriberConsent struct {
+	ID           int64     `json:"id" gorm:"column:id; primary_key:yes"`
+	UserID       int64     `json:"-" gorm:"column:user_id; index"`
+	SubscriberID int64     `json:"subscriber_id"`
+	TokenID      int64     `json:"-"`
+	SegmentIDs   JSON      `json:"segment_ids" gorm:"column:segment_ids; type:json"`
+	SignupIP     string    `json:"signup_ip"`
+	UserAgent    string    `json:"user_agent"`
+	ConfirmIP    string    `json:"confirm_ip"`
+	ConfirmedAt  NullTime  `json:"confirmed_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// GetSegmentIDs returns the ids of the segments which the subscribers can select.
+func (f *SignupForm) GetSegmentIDs() ([]int64, error) {
+	return decodeIDs(f.SegmentIDs)
+}
+
+// GetSegmentIDs returns the ids of the segments which the subscriber is added to on confirmation.
+func (c *SubscriberConsent) GetSegmentIDs() ([]int64, error) {
+	return decodeIDs(c.SegmentIDs)
+}
+
+func decodeIDs(j JSON) ([]int64, error) {
+	var ids []int64
+	if j.IsNull() {
+		return ids, nil
+	}
+	err := json.Unmarshal(j, &ids)
+	return ids, err
+}
@@ -0,0 +1,124 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// SSO protocols, they are also the source of the users which are provisioned by the connections.
+const (
+	SSOProtocolSAML = "saml"
+	SSOProtocolOIDC = "oidc"
+)
+
+// The statuses of the connections.
+const (
+	SSOConnectionStatusPending  = "pending"
+	SSOConnectionStatusVerified = "verified"
+)
+
+// SSOConnection is the single sign-on of an account with a SAML or an OpenID Connect identity provider.
+// The users sign in with the connection of their email domain. The users who don't exist are provisioned
+// just in time as members of the team of the account, with the role mapped from their attributes,
+// the existing users must be the owner or members of the team. When the connection is enforced,
+// the members of the team can't sign in with a password. The connection is pending until the ownership
+// of the domain is verified with a TXT record, the users sign in only with the verified connections.
+type SSOConnection struct {
+	Model
+	UserID        int64           `json:"-" gorm:"column:user_id; index"`
+	Protocol      string          `json:"protocol"`
+	Domain        string          `json:"domain"`
+	Enforced      bool            `json:"enforced"`
+	DefaultRoleID int64           `json:"default_role_id"`
+	RoleAttribute string          `json:"role_attribute"`
+	RoleMappings  SSORoleMappings `json:"role_mappings" gorm:"column:role_mappings; type:json"`
+	// EmailAttribute is the attribute or the claim with the email of the user, by default
+	// the name id of the SAML subject or the email claim of the id token.
+	EmailAttribute string `json:"email_attribute"`
+
+	IdPEntityID    string `json:"idp_entity_id" gorm:"column:idp_entity_id"`
+	IdPSSOURL      string `json:"idp_sso_url" gorm:"column:idp_sso_url"`
+	IdPCertificate string `json:"idp_certificate" gorm:"column:idp_certificate"`
+
+	Issuer           string `json:"issuer"`
+	AuthorizationURL string `json:"authorization_url" gorm:"column:authorization_url"`
+	TokenURL         string `json:"token_url" gorm:"column:token_url"`
+	JWKSURL          string `json:"jwks_url" gorm:"column:jwks_url"`
+	ClientID         string `json:"client_id"`
+	ClientSecret     string `json:"-"`
+
+	VerificationToken string   `json:"-"`
+	VerifiedAt        NullTime `json:"verified_at"`
+	// Status and VerificationRecord are set for the owner who adds the TXT record to the domain.
+	Status             string `json:"status,omitempty" gorm:"-"`
+	VerificationRecord string `json:"verification_record,omitempty" gorm:"-"`
+
+	// The endpoints of our service provider which are configured in the identity provider.
+	SPEntityID  string `json:"sp_entity_id,omitempty" gorm:"-"`
+	SPACSURL    string `json:"sp_acs_url,omitempty" gorm:"-"`
+	RedirectURL string `json:"redirect_url,omitempty" gorm:"-"`
+}
+
+// SSORoleMapping maps a value of the role attribute to a role of the team.
+type SSORoleMapping struct {
+	Value  string `json:"value" validate:"required,max=191"`
+	RoleID int64  `json:"role_id" validate:"required"`
+}
+
+// SSORoleMappings is the list of role mappings of a connection, stored as json.
+type SSORoleMappings []SSORoleMapping
+
+// Value returns the json encoded role mappings.
+func (m SSORoleMappings) Value() (driver.Value, error) {
+	if m == nil {
+		m = SSORoleMappings{}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan decodes the json encoded role mappings.
+func (m *SSORoleMappings) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*m = SSORoleMappings{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("invalid Scan Source")
+	}
+	return json.Unmarshal(b, m)
+}
+
+// RoleID returns the role of the first mapping which matches one of the values of the role attribute,
+// or the default role of the connection.
+func (c SSOConnection) RoleID(values []string) int64 {
+	for _, m := range c.RoleMappings {
+		for _, v := range values {
+			if strings.EqualFold(m.Value, v) {
+				return m.RoleID
+			}
+		}
+	}
+	return c.DefaultRoleID
+}
+
+// Verified reports whether the ownership of the domain is verified.
+func (c SSOConnection) Verified() bool {
+	return c.VerifiedAt.Valid
+}
+
+// MatchesEmail reports whether the email belongs to the domain of the connection.
+func (c SSOConnection) MatchesEmail(email string) bool {
+	i := strings.LastIndex(email, "@")
+	return i > 0 && strings.EqualFold(email[i+1:], c.Domain)
+}
@@ -5,11 +5,15 @@ import (
 	"errors"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mailbadger/app/utils"
 )
 
+// MetadataKeyTimezone is the metadata field which holds the time zone of the subscriber.
+const MetadataKeyTimezone = "timezone"
+
 // Subscriber represents the subscriber entity
 type Subscriber struct {
 	Model
@@ -20,6 +24,8 @@ type Subscriber struct {
 	Segments    []Segment         `json:"segments,omitempty" gorm:"many2many:subscribers_segments;"`
 	Blacklisted bool              `json:"blacklisted"`
 	Active      bool              `json:"active"`
+	PausedUntil NullTime          `json:"paused_until"`
+	Timezone    string            `json:"timezone"`
 	Metadata    map[string]string `json:"-" sql:"-" gorm:"-"`
 }
 
@@ -53,6 +59,72 @@ func (s *Subscriber) GetUnsubscribeURL(uuid, secret, appURL string) (string, err
 	return appURL + "/unsubscribe.html?" + params.Encode(), nil
 }
 
+// GetOneClickUnsubscribeURL creates a signed url for the List-Unsubscribe header (RFC 8058).
+// Mailbox providers POST to it and the subscriber is deactivated without any interaction.
+func (s *Subscriber) GetOneClickUnsubscribeURL(uuid, secret, appURL string) (string, error) {
+	t, err := s.GenerateUnsubscribeToken(secret)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Add("email", s.Email)
+	params.Add("uuid", uuid)
+	params.Add("t", t)
+
+	return appURL + "/api/unsubscribe/one-click?" + params.Encode(), nil
+}
+
+// GetPreferencesURL creates a signed url of the preference center, where the subscriber can choose
+// which segments to receive, update the name or metadata, pause or stop the emails.
+// It is signed with the same token as the unsubscribe url.
+func (s *Subscriber) GetPreferencesURL(uuid, secret, appURL string) (string, error) {
+	t, err := s.GenerateUnsubscribeToken(secret)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Add("email", s.Email)
+	params.Add("uuid", uuid)
+	params.Add("t", t)
+
+	return appURL + "/preferences.html?" + params.Encode(), nil
+}
+
+// SetTimezoneFromMetadata sets the time zone of the subscriber from the timezone metadata field,
+// the inferred time zone is kept when the field is missing or is not a valid IANA time zone.
+func (s *Subscriber) SetTimezoneFromMetadata() {
+	m, err := s.GetMetadata()
+	if err != nil {
+		return
+	}
+
+	tz := strings.TrimSpace(m[MetadataKeyTimezone])
+	if tz == "" {
+		return
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return
+	}
+	s.Timezone = tz
+}
+
+// Location returns the time zone of the subscriber, the fallback location is used when it's not known.
+func (s *Subscriber) Location(fallback *time.Location) *time.Location {
+	if s.Timezone == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return fallback
+	}
+	return loc
+}
+
+// IsPaused checks whether the subscriber has paused the emails.
+func (s *Subscriber) IsPaused() bool {
+	return s.PausedUntil.Valid && s.PausedUntil.Time.After(time.Now())
+}
+
 // GenerateUnsubscribeToken generates and signs a new unsubscribe token with the given key, from the
 // ID of the subscriber. When a subscriber wants to unsubscribe from future emails, we check this hash
 // against a newly generated hash and compare them, if they match we unsubscribe the user.
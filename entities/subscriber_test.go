@@ -32,6 +32,10 @@ func TestSubscriberEntity(t *testing.T) {
 	assert.Equal(t, m["foo"], "bar")
 	assert.Equal(t, url, "http://example.com/unsubscribe.html?email=john.doe%40example.com&t=77de38e4b50e618a0ebb95db61e2f42697391659d82c064a5f81b9f48d85ccd5&uuid=foobar")
 
+	url, err = sub.GetOneClickUnsubscribeURL("foobar", "secret", "http://example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, url, "http://example.com/api/unsubscribe/one-click?email=john.doe%40example.com&t=77de38e4b50e618a0ebb95db61e2f42697391659d82c064a5f81b9f48d85ccd5&uuid=foobar")
+
 	tt, err := sub.GenerateUnsubscribeToken("secret")
 	assert.Nil(t, err)
 	assert.Equal(t, tt, "77de38e4b50e618a0ebb95db61e2f42697391659d82c064a5f81b9f48d85ccd5")
@@ -45,3 +49,20 @@ func TestSubscriberEntity(t *testing.T) {
 	updatedAt := sub.GetUpdatedAt()
 	assert.Equal(t, now, updatedAt)
 }
+
+func TestSubscriberTimezone(t *testing.T) {
+	sub := &Subscriber{MetaJSON: []byte(`{"timezone": "Europe/Skopje"}`)}
+	sub.SetTimezoneFromMetadata()
+	assert.Equal(t, "Europe/Skopje", sub.Timezone)
+	assert.Equal(t, "Europe/Skopje", sub.Location(time.UTC).String())
+
+	// an invalid time zone keeps the current one
+	sub.MetaJSON = []byte(`{"timezone": "Mars/Olympus"}`)
+	sub.SetTimezoneFromMetadata()
+	assert.Equal(t, "Europe/Skopje", sub.Timezone)
+
+	sub = &Subscriber{}
+	sub.SetTimezoneFromMetadata()
+	assert.Equal(t, "", sub.Timezone)
+	assert.Equal(t, time.UTC, sub.Location(time.UTC))
+}
@@ -0,0 +1,142 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Roles which are created with each team, they can be edited like the other roles of the team.
+const (
+	TeamRoleEditor = "editor"
+	TeamRoleViewer = "viewer"
+)
+
+// PermissionAny matches any method, or any path when it's the suffix of the path.
+const PermissionAny = "*"
+
+var ErrTeamRoleNameIsTaken = errors.New("team role name is reserved")
+
+// Team is a workspace which is shared with the members of the team. The campaigns, segments, templates,
+// SES keys and the other resources of the team are owned by the account of the user who created it,
+// the members act on behalf of that account with the permissions of their role.
+type Team struct {
+	Model
+	UserID int64  `json:"-" gorm:"column:user_id; index"`
+	Name   string `json:"name"`
+}
+
+// Permission allows the requests with the method to the path of the route, e.g. GET /api/campaigns/:id.
+// The path may end with a wildcard to match all the routes with the prefix, e.g. /api/campaigns*.
+type Permission struct {
+	Method string `json:"method" validate:"required,max=10"`
+	Path   string `json:"path" validate:"required,max=191"`
+}
+
+// Permissions is the list of permissions of a role, stored as json.
+type Permissions []Permission
+
+// Value returns the json encoded permissions.
+func (p Permissions) Value() (driver.Value, error) {
+	if p == nil {
+		p = Permissions{}
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan decodes the json encoded permissions.
+func (p *Permissions) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*p = Permissions{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("invalid Scan Source")
+	}
+	return json.Unmarshal(b, p)
+}
+
+// TeamRole maps the role of the members to their permissions. The roles are evaluated by the
+// authorization policy along with their permissions.
+type TeamRole struct {
+	Model
+	TeamID      int64       `json:"team_id" gorm:"column:team_id; index"`
+	Name        string      `json:"name"`
+	Permissions Permissions `json:"permissions" gorm:"column:permissions; type:json"`
+}
+
+// Validate checks that the name of the role doesn't clash with the roles of the users.
+func (r TeamRole) Validate() error {
+	if r.Name == AdminRole || r.Name == BillingRole {
+		return ErrTeamRoleNameIsTaken
+	}
+	return nil
+}
+
+// DefaultTeamRoles returns the roles which are created with a new team.
+func DefaultTeamRoles(teamID int64) []TeamRole {
+	var editor Permissions
+	for _, path := range []string{
+		"/api/campaigns*",
+		"/api/templates*",
+		"/api/partials*",
+		"/api/segments*",
+		"/api/subscribers*",
+		"/api/automations*",
+		"/api/transactional*",
+		"/api/s3*",
+	} {
+		editor = append(editor, Permission{Method: PermissionAny, Path: path})
+	}
+
+	return []TeamRole{
+		{
+			TeamID:      teamID,
+			Name:        TeamRoleEditor,
+			Permissions: editor,
+		},
+		{
+			TeamID:      teamID,
+			Name:        TeamRoleViewer,
+			Permissions: Permissions{{Method: "GET", Path: "/api/*"}},
+		},
+	}
+}
+
+// TeamMember is a user who is a member of the team, with the given role.
+type TeamMember struct {
+	Model
+	TeamID int64     `json:"team_id" gorm:"column:team_id; index"`
+	UserID int64     `json:"-" gorm:"column:user_id; index"`
+	RoleID int64     `json:"role_id"`
+	User   *User     `json:"user,omitempty"`
+	Role   *TeamRole `json:"role,omitempty"`
+	Team   *Team     `json:"team,omitempty"`
+}
+
+// TeamInvitation is an invitation to join the team which is sent by email, the user who accepts it
+// with the token becomes a member of the team.
+type TeamInvitation struct {
+	Model
+	TeamID    int64     `json:"team_id" gorm:"column:team_id; index"`
+	Email     string    `json:"email"`
+	RoleID    int64     `json:"role_id"`
+	Token     string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Team      *Team     `json:"team,omitempty"`
+}
+
+// Expired reports whether the invitation can't be accepted anymore.
+func (i TeamInvitation) Expired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
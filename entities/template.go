@@ -17,6 +17,9 @@ var (
 const (
 	TagName           = "name"
 	TagUnsubscribeUrl = "unsubscribe_url"
+	TagConfirmUrl     = "confirm_url"
+	TagPreferencesUrl = "preferences_url"
+	TagFeedItems      = "feed_items"
 )
 
 // BaseTemplate represents the base params of each template
@@ -25,6 +28,8 @@ type BaseTemplate struct {
 	UserID      int64  `json:"-"`
 	Name        string `json:"name"`
 	SubjectPart string `json:"subject_part"`
+	// Version is the current version of the template, it's incremented on each save.
+	Version int64 `json:"version"`
 }
 
 // GetID returns the id of the template
@@ -42,6 +47,35 @@ type Template struct {
 	BaseTemplate
 	HTMLPart string `json:"html_part" gorm:"-"`
 	TextPart string `json:"text_part"`
+	LayoutID *int64 `json:"layout_id"`
+	HTMLPipeline
+	// Layout and Partials are set when the template is parsed for sending.
+	Layout   *Partial   `json:"-" gorm:"-"`
+	Partials PartialSet `json:"-" gorm:"-"`
+	// ProcessedHTMLPart and ProcessedTextPart are the parts after the html pipeline, they are
+	// processed when the template is saved.
+	ProcessedHTMLPart string `json:"-" gorm:"-"`
+	ProcessedTextPart string `json:"-" gorm:"-"`
+}
+
+// HTMLPipeline holds the processing steps which are applied to the html part of the template when it's
+// saved. The source of the template is stored as it was written, so it can be edited later.
+type HTMLPipeline struct {
+	// InlineCSS moves the rules of the style blocks into the style attributes of the matching elements,
+	// the rules which can't be inlined, like media queries, are kept in the style blocks.
+	InlineCSS bool `json:"inline_css"`
+	// MinifyHTML removes the comments and collapses the whitespace, the conditional comments are kept.
+	MinifyHTML bool `json:"minify_html"`
+	// SanitizeHTML removes the elements, the attributes and the urls which are not in the allowlist of the
+	// email html, such as the scripts, the embedded content, the event handlers and the javascript urls.
+	SanitizeHTML bool `json:"sanitize_html"`
+	// GenerateText generates the text part from the html part when the text part is left empty.
+	GenerateText bool `json:"generate_text"`
+}
+
+// Enabled reports whether any of the processing steps are enabled.
+func (p HTMLPipeline) Enabled() bool {
+	return p.InlineCSS || p.MinifyHTML || p.SanitizeHTML || p.GenerateText
 }
 
 // GetBase returns the base of the template
@@ -55,6 +89,7 @@ func (t Template) GetBase() *BaseTemplate {
 		UserID:      t.UserID,
 		Name:        t.Name,
 		SubjectPart: t.SubjectPart,
+		Version:     t.Version,
 	}
 }
 
@@ -92,6 +127,55 @@ func (t Template) ValidateData(data map[string]string) error {
 	return g.Wait()
 }
 
+// HasTag checks whether the tag is used in the html or the text part of the template,
+// including the layout and the partials of the template.
+func (t Template) HasTag(name string) bool {
+	parts := []string{t.HTMLPart, t.TextPart}
+	if t.Layout != nil {
+		parts = append(parts, t.Layout.Content)
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		if t.hasTag(part, name, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Template) hasTag(content, name string, seen map[string]bool) bool {
+	tmpl, err := mustache.ParseStringPartials(content, PartialSet{})
+	if err != nil {
+		return false
+	}
+
+	var walk func(tags []mustache.Tag) bool
+	walk = func(tags []mustache.Tag) bool {
+		for _, tag := range tags {
+			switch tag.Type() {
+			case mustache.Partial:
+				if !seen[tag.Name()] {
+					seen[tag.Name()] = true
+					if t.hasTag(t.Partials[tag.Name()], name, seen) {
+						return true
+					}
+				}
+			case mustache.Section, mustache.InvertedSection:
+				if tag.Name() == name || walk(tag.Tags()) {
+					return true
+				}
+			default:
+				if tag.Name() == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(tmpl.Tags())
+}
+
 func validateData(templateString string, data map[string]string) error {
 	template, err := mustache.ParseString(templateString)
 	if err != nil {
@@ -99,7 +183,10 @@ func validateData(templateString string, data map[string]string) error {
 	}
 
 	for _, tag := range template.Tags() {
-		if tag.Name() == TagName || tag.Name() == TagUnsubscribeUrl {
+		if tag.Type() == mustache.Partial {
+			continue
+		}
+		if tag.Name() == TagName || tag.Name() == TagUnsubscribeUrl || tag.Name() == TagFeedItems {
 			continue
 		}
 
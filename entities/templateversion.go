@@ -0,0 +1,99 @@
+package entities
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// TemplateVersion is an immutable snapshot of the template, a new version is created each time
+// the template is saved. The html part is stored in S3 under the html key of the version.
+type TemplateVersion struct {
+	Model
+	UserID      int64  `json:"-"`
+	TemplateID  int64  `json:"template_id"`
+	Version     int64  `json:"version"`
+	SubjectPart string `json:"subject_part"`
+	TextPart    string `json:"text_part"`
+	LayoutID    *int64 `json:"layout_id"`
+	HTMLPipeline
+	HTMLKey  string `json:"-" gorm:"column:html_key"`
+	HTMLPart string `json:"html_part,omitempty" gorm:"-"`
+	// ProcessedHTMLKey is the key of the html part after the html pipeline, the text part
+	// is processed as well when it's generated from the html part.
+	ProcessedHTMLKey  string `json:"-" gorm:"column:processed_html_key"`
+	ProcessedHTMLPart string `json:"-" gorm:"-"`
+	ProcessedTextPart string `json:"-"`
+}
+
+// Template returns the template as it was at this version.
+func (v TemplateVersion) Template(name string) *Template {
+	return &Template{
+		BaseTemplate: BaseTemplate{
+			Model: Model{
+				ID:        v.TemplateID,
+				CreatedAt: v.CreatedAt,
+				UpdatedAt: v.UpdatedAt,
+			},
+			UserID:      v.UserID,
+			Name:        name,
+			SubjectPart: v.SubjectPart,
+			Version:     v.Version,
+		},
+		HTMLPart:          v.HTMLPart,
+		TextPart:          v.TextPart,
+		LayoutID:          v.LayoutID,
+		HTMLPipeline:      v.HTMLPipeline,
+		ProcessedHTMLPart: v.ProcessedHTMLPart,
+		ProcessedTextPart: v.ProcessedTextPart,
+	}
+}
+
+// TemplateDiff holds the unified diffs of the parts of two template versions,
+// a part which has not changed has an empty diff.
+type TemplateDiff struct {
+	From        int64  `json:"from"`
+	To          int64  `json:"to"`
+	SubjectPart string `json:"subject_part"`
+	HTMLPart    string `json:"html_part"`
+	TextPart    string `json:"text_part"`
+}
+
+// DiffTemplateVersions returns the line by line differences between the two versions.
+func DiffTemplateVersions(from, to *TemplateVersion) (*TemplateDiff, error) {
+	d := &TemplateDiff{
+		From: from.Version,
+		To:   to.Version,
+	}
+
+	var err error
+	d.SubjectPart, err = diffPart("subject_part", from, to, from.SubjectPart, to.SubjectPart)
+	if err != nil {
+		return nil, err
+	}
+	d.HTMLPart, err = diffPart("html_part", from, to, from.HTMLPart, to.HTMLPart)
+	if err != nil {
+		return nil, err
+	}
+	d.TextPart, err = diffPart("text_part", from, to, from.TextPart, to.TextPart)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func diffPart(part string, from, to *TemplateVersion, a, b string) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(strings.TrimSuffix(a, "\n")),
+		B:        difflib.SplitLines(strings.TrimSuffix(b, "\n")),
+		FromFile: fmt.Sprintf("%s@v%d", part, from.Version),
+		ToFile:   fmt.Sprintf("%s@v%d", part, to.Version),
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("diff %s: %w", part, err)
+	}
+	return diff, nil
+}
@@ -0,0 +1,35 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffTemplateVersions(t *testing.T) {
+	v1 := &TemplateVersion{
+		Version:     1,
+		SubjectPart: "Hello {{name}}",
+		HTMLPart:    "<h1>Hello</h1>\n<p>Old offer</p>",
+		TextPart:    "Hello",
+	}
+	v2 := &TemplateVersion{
+		Version:     2,
+		SubjectPart: "Hello {{name}}",
+		HTMLPart:    "<h1>Hello</h1>\n<p>New offer</p>\n",
+		TextPart:    "Hello",
+	}
+
+	d, err := DiffTemplateVersions(v1, v2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), d.From)
+	assert.Equal(t, int64(2), d.To)
+	assert.Empty(t, d.SubjectPart)
+	assert.Empty(t, d.TextPart)
+	assert.Equal(t, "--- html_part@v1\n+++ html_part@v2\n@@ -1,2 +1,2 @@\n <h1>Hello</h1>\n-<p>Old offer</p>\n+<p>New offer</p>\n", d.HTMLPart)
+
+	tmpl := v2.Template("newsletter")
+	assert.Equal(t, "newsletter", tmpl.Name)
+	assert.Equal(t, int64(2), tmpl.Version)
+	assert.Equal(t, v2.HTMLPart, tmpl.HTMLPart)
+}
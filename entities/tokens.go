@@ -4,13 +4,14 @@ import "time"
 
 // Different types to identify tokens.
 const (
-	UnsubscribeTokenType    = "unsubscribe"
-	ForgotPasswordTokenType = "forgot_password"
-	VerifyEmailTokenType    = "verify_email"
+	UnsubscribeTokenType         = "unsubscribe"
+	ForgotPasswordTokenType      = "forgot_password"
+	VerifyEmailTokenType         = "verify_email"
+	ConfirmSubscriptionTokenType = "confirm_subscription"
 )
 
 // Token entity represents a one-time token which a user can use
-// in an "unsubscribe" or "forgot password" scenario.
+// in an "unsubscribe", "forgot password" or "confirm subscription" scenario.
 type Token struct {
 	ID        int64     `json:"id"`
 	UserID    int64     `json:"-" gorm:"column:user_id; index"`
@@ -0,0 +1,93 @@
+package entities
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/mailbadger/app/utils"
+)
+
+// trackingKeyLabel is used to derive the tracking key from the secret, so that the tracking tokens
+// which are exposed in every email can't be used as unsubscribe tokens and vice versa.
+const trackingKeyLabel = "tracking"
+
+// ErrInvalidTrackingToken is returned when the tracking token is malformed or the signature does not match.
+var ErrInvalidTrackingToken = errors.New("entities: invalid tracking token")
+
+// TrackingToken holds the data encoded in the signed open and click tracking urls.
+type TrackingToken struct {
+	UserID       int64  `json:"u"`
+	CampaignID   int64  `json:"c"`
+	VariantID    int64  `json:"v,omitempty"`
+	SubscriberID int64  `json:"s"`
+	Link         string `json:"l,omitempty"`
+}
+
+// Encode encodes the token data and signs it with a key derived from the given secret. The token
+// is url safe and in the format of "<base64 payload>.<signature>".
+func (t TrackingToken) Encode(secret string) (string, error) {
+	if secret == "" {
+		return "", errors.New("entities: unable to encode tracking token: key is empty")
+	}
+
+	key, err := trackingKey(secret)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	sig, err := utils.SignData(payload, key)
+	if err != nil {
+		return "", err
+	}
+
+	return payload + "." + sig, nil
+}
+
+// DecodeTrackingToken verifies the signature of the token with a key derived from the given secret
+// and decodes its data.
+func DecodeTrackingToken(token, secret string) (*TrackingToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || secret == "" {
+		return nil, ErrInvalidTrackingToken
+	}
+
+	key, err := trackingKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := utils.SignData(parts[0], key)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(sig), []byte(parts[1])) {
+		return nil, ErrInvalidTrackingToken
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidTrackingToken
+	}
+
+	t := &TrackingToken{}
+	err = json.Unmarshal(b, t)
+	if err != nil {
+		return nil, ErrInvalidTrackingToken
+	}
+
+	return t, nil
+}
+
+// trackingKey derives the signing key of the tracking tokens from the secret.
+func trackingKey(secret string) (string, error) {
+	return utils.SignData(trackingKeyLabel, secret)
+}
@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/utils"
+)
+
+func TestTrackingToken(t *testing.T) {
+	tt := TrackingToken{
+		UserID:       1,
+		CampaignID:   2,
+		VariantID:    3,
+		SubscriberID: 4,
+		Link:         "https://example.com/foo?bar=baz",
+	}
+
+	token, err := tt.Encode("secret")
+	assert.Nil(t, err)
+
+	decoded, err := DecodeTrackingToken(token, "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, tt, *decoded)
+
+	_, err = DecodeTrackingToken(token, "other")
+	assert.Equal(t, ErrInvalidTrackingToken, err)
+
+	// the token is not signed with the secret itself, which also signs the unsubscribe tokens
+	payload := strings.Split(token, ".")[0]
+	sig, err := utils.SignData(payload, "secret")
+	assert.Nil(t, err)
+	_, err = DecodeTrackingToken(payload+"."+sig, "secret")
+	assert.Equal(t, ErrInvalidTrackingToken, err)
+
+	_, err = DecodeTrackingToken("foo."+token, "secret")
+	assert.Equal(t, ErrInvalidTrackingToken, err)
+
+	_, err = DecodeTrackingToken("", "secret")
+	assert.Equal(t, ErrInvalidTrackingToken, err)
+
+	_, err = tt.Encode("")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,64 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Transactional message statuses.
+const (
+	TransactionalStatusQueued     = "queued"
+	TransactionalStatusSent       = "sent"
+	TransactionalStatusDelivered  = "delivered"
+	TransactionalStatusBounced    = "bounced"
+	TransactionalStatusComplained = "complained"
+	TransactionalStatusFailed     = "failed"
+)
+
+// Transactional event types.
+const (
+	TransactionalEventQueued    = "queued"
+	TransactionalEventSend      = "send"
+	TransactionalEventDelivery  = "delivery"
+	TransactionalEventBounce    = "bounce"
+	TransactionalEventComplaint = "complaint"
+	TransactionalEventFailure   = "failure"
+)
+
+// TransactionalMessage is a one-off email rendered from a stored template and sent to a single
+// recipient, it is tracked separately from the campaigns.
+type TransactionalMessage struct {
+	ID                int64     `json:"-" gorm:"column:id; primary_key:yes"`
+	UserID            int64     `json:"-" gorm:"column:user_id; index"`
+	MessageID         string    `json:"message_id"`
+	IdempotencyKey    *string   `json:"idempotency_key"`
+	TemplateID        int64     `json:"template_id"`
+	Recipient         string    `json:"recipient"`
+	Status            string    `json:"status"`
+	ProviderMessageID *string   `json:"provider_message_id"`
+	Description       string    `json:"description"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TransactionalEvent is a change in the status of the transactional message, as reported
+// by the sender consumer or the delivery provider.
+type TransactionalEvent struct {
+	ID                     int64     `json:"-" gorm:"column:id; primary_key:yes"`
+	TransactionalMessageID int64     `json:"-"`
+	Type                   string    `json:"type"`
+	Description            string    `json:"description"`
+	CreatedAt              time.Time `json:"created_at"`
+}
+
+// NewTransactionalMessage returns a queued transactional message with a newly generated message id.
+func NewTransactionalMessage(userID, templateID int64, recipient string) *TransactionalMessage {
+	return &TransactionalMessage{
+		UserID:     userID,
+		MessageID:  ksuid.New().String(),
+		TemplateID: templateID,
+		Recipient:  recipient,
+		Status:     TransactionalStatusQueued,
+	}
+}
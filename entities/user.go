@@ -17,8 +17,16 @@ type User struct {
 	Boundaries *Boundaries    `json:"boundaries" gorm:"foreignKey:boundary_id"`
 	Roles      []Role         `json:"roles" gorm:"many2many:users_roles;"`
 	Source     string         `json:"source,omitempty"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+	// TwoFactorSecret is the secret of the time-based one-time passwords, it's set when the
+	// enrollment starts and the two-factor authentication is enabled once a code is confirmed.
+	TwoFactorSecret string `json:"-"`
+	// TwoFactorCounter is the time step of the last used code, so the codes can't be reused.
+	TwoFactorCounter int64 `json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func (u *User) RoleNames() []string {
@@ -0,0 +1,119 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/segmentio/ksuid"
+)
+
+// Webhook event types.
+const (
+	WebhookEventSubscriberCreated      = "subscriber.created"
+	WebhookEventSubscriberUnsubscribed = "subscriber.unsubscribed"
+	WebhookEventCampaignBounce         = "campaign.bounce"
+	WebhookEventCampaignComplaint      = "campaign.complaint"
+	WebhookEventCampaignClick          = "campaign.click"
+	WebhookEventAutomationBounce       = "automation.bounce"
+	WebhookEventAutomationComplaint    = "automation.complaint"
+	WebhookEventTest                   = "webhook.test"
+)
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	WebhookDeliveryStatusFailed    = "failed"
+)
+
+// WebhookSignatureHeader is the header which holds the HMAC signature of the payload,
+// signed with the secret of the webhook.
+const WebhookSignatureHeader = "X-Mailbadger-Signature"
+
+// Webhook is an endpoint of the user which receives the subscriber and campaign events.
+type Webhook struct {
+	Model
+	UserID int64  `json:"-" gorm:"column:user_id; index"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	Events JSON   `json:"events" gorm:"column:events; type:json"`
+	Active bool   `json:"active"`
+}
+
+// ClearSecret removes the signing secret so the webhook can be returned to the client,
+// the secret is returned only when it's set.
+func (w *Webhook) ClearSecret() {
+	w.Secret = ""
+}
+
+// WebhookDelivery holds the payload of an event which is sent to a webhook, the delivery
+// is retried with exponential backoff until it succeeds or the attempts run out.
+type WebhookDelivery struct {
+	Model
+	UserID        int64    `json:"-" gorm:"column:user_id; index"`
+	WebhookID     int64    `json:"webhook_id"`
+	Event         string   `json:"event"`
+	Payload       JSON     `json:"payload" gorm:"column:payload; type:json"`
+	Status        string   `json:"status"`
+	Attempts      int      `json:"attempts"`
+	NextAttemptAt NullTime `json:"next_attempt_at"`
+}
+
+// WebhookDeliveryAttempt is the log of a single request made to the webhook. The response body
+// is not exposed, so the webhooks can't be used to read the responses of arbitrary urls.
+type WebhookDeliveryAttempt struct {
+	ID           int64     `json:"id" gorm:"column:id; primary_key:yes"`
+	DeliveryID   int64     `json:"delivery_id"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody string    `json:"-"`
+	Error        string    `json:"error"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WebhookPayload is the body which is posted to the webhooks.
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NewWebhookPayload encodes the event data as a webhook payload.
+func NewWebhookPayload(event string, data interface{}) (JSON, error) {
+	b, err := json.Marshal(WebhookPayload{
+		ID:        ksuid.New().String(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return JSON(b), nil
+}
+
+// GetEvents returns the events which the webhook is subscribed to.
+func (w *Webhook) GetEvents() ([]string, error) {
+	var events []string
+	if w.Events.IsNull() {
+		return events, nil
+	}
+	err := json.Unmarshal(w.Events, &events)
+	return events, err
+}
+
+// HasEvent checks whether the webhook is subscribed to the given event.
+func (w *Webhook) HasEvent(event string) bool {
+	events, err := w.GetEvents()
+	if err != nil {
+		return false
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
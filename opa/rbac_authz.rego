@@ -1,18 +1,15 @@
 package rbac.authz
 
-# role-permissions assignments
-role_permissions := {
-  # test roles and permissions
-  "engineering": [{"method": "GET",  "path": "/api/campaigns"},
-                  {"method": "GET",  "path": "/api/campaigns/:id"}],
-  "webdev":      [{"method": "GET",  "path": "/api/campaigns"},
-                  {"method": "PUT",  "path": "/api/campaigns/:id"}]
-}
-
 default allow = false
 
-# Allow admins to do anything.
 allow {
+	role_allows
+	scope_allows
+	owner_allows
+}
+
+# Allow admins to do anything.
+role_allows {
 	user_is_admin
 }
 
@@ -20,9 +17,87 @@ user_is_admin {
 	input.roles[_] == "admin"
 }
 
-allow {
-    r := input.roles[_]
-    permissions := role_permissions[r]
-    p := permissions[_]
-    p == {"method": input.method, "path": input.path}
-}
\ No newline at end of file
+# The account routes are allowed for every user, including the members of a team.
+account_paths := {
+  "/api/logout",
+  "/api/users/me",
+  "/api/users/password",
+  "/api/users/two-factor",
+  "/api/users/two-factor/confirm",
+  "/api/users/two-factor/recovery-codes",
+  "/api/users/sessions",
+  "/api/users/sessions/:id",
+  "/api/teams",
+  "/api/invitations/accept"
+}
+
+role_allows {
+	account_paths[input.path]
+}
+
+# The permissions of the roles are provided with the input, so the teams can edit them.
+role_allows {
+	r := input.roles[_]
+	p := input.role_permissions[r][_]
+	method_matches(p.method)
+	path_matches(p.path)
+}
+
+method_matches(m) {
+	m == "*"
+}
+
+method_matches(m) {
+	upper(m) == input.method
+}
+
+path_matches(p) {
+	p == input.path
+}
+
+path_matches(p) {
+	endswith(p, "*")
+	startswith(input.path, trim_suffix(p, "*"))
+}
+
+# The api keys, the sso connections and the delivery settings give access to the whole account,
+# so they are managed only by the owner and not by the members of a team.
+owner_paths := {
+  "/api/api-keys",
+  "/api/api-keys/:id",
+  "/api/sso-connections",
+  "/api/sso-connections/:id",
+  "/api/sso-connections/:id/verify",
+  "/api/delivery-provider",
+  "/api/ses/keys"
+}
+
+owner_allows {
+	not input.team
+}
+
+owner_allows {
+	not owner_paths[input.path]
+}
+
+# The scopes are set only for the requests made with an api key.
+scope_allows {
+	not input.scopes
+}
+
+scope_allows {
+	input.scopes[_] == "*"
+}
+
+# The write scope includes the read scope of the resource.
+scope_allows {
+	input.scopes[_] == sprintf("%s:write", [resource])
+}
+
+scope_allows {
+	input.method == "GET"
+	input.scopes[_] == sprintf("%s:read", [resource])
+}
+
+# The resource is the first segment of the path, e.g. subscribers for /api/subscribers/:id.
+resource := split(trim_prefix(input.path, "/api/"), "/")[0]
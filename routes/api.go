@@ -12,9 +12,11 @@ import (
 	"github.com/mailbadger/app/emails"
 	"github.com/mailbadger/app/routes/middleware"
 	"github.com/mailbadger/app/services/boundaries"
+	"github.com/mailbadger/app/services/lint"
 	"github.com/mailbadger/app/services/reports"
 	"github.com/mailbadger/app/services/subscribers"
 	templatesvc "github.com/mailbadger/app/services/templates"
+	"github.com/mailbadger/app/services/webhooks"
 	"github.com/mailbadger/app/session"
 	"github.com/mailbadger/app/sqs"
 	"github.com/mailbadger/app/storage"
@@ -34,10 +36,13 @@ type API struct {
 	boundarysvc  boundaries.Service
 	subscrsvc    subscribers.Service
 	reportsvc    reports.Service
+	webhooksvc   webhooks.Service
+	lintsvc      lint.Service
 
-	campaignerQueueURL sqs.CampaignerQueueURL
-	appDir             string
-	appURL             string
+	campaignerQueueURL    sqs.CampaignerQueueURL
+	transactionalQueueURL sqs.TransactionalQueueURL
+	appDir                string
+	appURL                string
 
 	filesBucket string
 
@@ -47,6 +52,9 @@ type API struct {
 	unsubscribeTokenSecret string
 	systemEmail            string
 	social                 config.Social
+
+	// maildir is set when the local delivery mode is enabled
+	maildir *emails.Maildir
 }
 
 func From(
@@ -60,7 +68,9 @@ func From(
 	boundarysvc boundaries.Service,
 	subscrsvc subscribers.Service,
 	reportsvc reports.Service,
+	webhooksvc webhooks.Service,
 	campaignerQueueURL sqs.CampaignerQueueURL,
+	transactionalQueueURL sqs.TransactionalQueueURL,
 	conf config.Config,
 ) API {
 	return New(
@@ -74,7 +84,9 @@ func From(
 		boundarysvc,
 		subscrsvc,
 		reportsvc,
+		webhooksvc,
 		campaignerQueueURL,
+		transactionalQueueURL,
 		conf.Server.AppDir,
 		conf.Server.AppURL,
 		conf.Storage.S3.FilesBucket,
@@ -84,6 +96,8 @@ func From(
 		conf.Server.UnsubscribeSecret,
 		conf.Server.SystemEmailSource,
 		conf.Social,
+		conf.Delivery.Mode,
+		conf.Delivery.Maildir,
 	)
 }
 func New(
@@ -97,7 +111,9 @@ func New(
 	boundarysvc boundaries.Service,
 	subscrsvc subscribers.Service,
 	reportsvc reports.Service,
+	webhooksvc webhooks.Service,
 	campaignerQueueURL sqs.CampaignerQueueURL,
+	transactionalQueueURL sqs.TransactionalQueueURL,
 	appDir string,
 	appURL string,
 	filesBucket string,
@@ -107,7 +123,18 @@ func New(
 	unsubscribeTokenSecret string,
 	systemEmail string,
 	social config.Social,
+	deliveryMode string,
+	localMaildir string,
 ) API {
+	if recaptchaSecret == "" {
+		logrus.Warn("api: recaptcha secret is not set, subscriptions to signup forms with recaptcha enabled will be rejected")
+	}
+
+	var maildir *emails.Maildir
+	if deliveryMode == emails.DeliveryModeLocal {
+		maildir = emails.NewMaildir(localMaildir)
+	}
+
 	return API{
 		sess:                   sess,
 		store:                  store,
@@ -119,7 +146,10 @@ func New(
 		boundarysvc:            boundarysvc,
 		subscrsvc:              subscrsvc,
 		reportsvc:              reportsvc,
+		webhooksvc:             webhooksvc,
+		lintsvc:                lint.New(appURL),
 		campaignerQueueURL:     campaignerQueueURL,
+		transactionalQueueURL:  transactionalQueueURL,
 		appDir:                 appDir,
 		appURL:                 appURL,
 		filesBucket:            filesBucket,
@@ -129,6 +159,7 @@ func New(
 		unsubscribeTokenSecret: unsubscribeTokenSecret,
 		systemEmail:            systemEmail,
 		social:                 social,
+		maildir:                maildir,
 	}
 }
 
@@ -178,6 +209,17 @@ func (api API) Handler() http.Handler {
 
 	handler.Static("/static", api.appDir+"/static")
 
+	api.SetTrackingRoutes(
+		handler,
+		middleware.NoCache(),
+	)
+
+	api.SetPreferencesRoutes(
+		handler,
+		middleware.NoCache(),
+		middleware.Limiter(),
+	)
+
 	api.SetGuestRoutes(
 		handler,
 		middleware.NoCache(),
@@ -194,6 +236,25 @@ func (api API) Handler() http.Handler {
 	return handler
 }
 
+// SetTrackingRoutes sets the public open and click tracking routes, which are
+// requested by the mail clients of the subscribers.
+func (api API) SetTrackingRoutes(handler *gin.Engine, middleware ...gin.HandlerFunc) {
+	tracking := handler.Group("/t")
+	tracking.Use(middleware...)
+
+	tracking.GET("/o/:token", actions.TrackOpen(api.store, api.unsubscribeTokenSecret))
+	tracking.GET("/c/:token", actions.TrackClick(api.store, api.unsubscribeTokenSecret))
+}
+
+// SetPreferencesRoutes sets the preference center page, which is opened from the
+// links in the emails sent to the subscribers.
+func (api API) SetPreferencesRoutes(handler *gin.Engine, middleware ...gin.HandlerFunc) {
+	preferences := handler.Group("/preferences.html")
+	preferences.Use(middleware...)
+
+	preferences.GET("", actions.GetPreferences(api.store, api.unsubscribeTokenSecret))
+}
+
 // SetGuestRoutes sets the guest routes to the gin engine handler along with
 // a number of middleware that we set.
 func (api API) SetGuestRoutes(handler *gin.Engine, middleware ...gin.HandlerFunc) {
@@ -243,7 +304,12 @@ func (api API) SetGuestRoutes(handler *gin.Engine, middleware ...gin.HandlerFunc
 			api.appURL,
 		))
 
+	guest.GET("/auth/sso", actions.GetSSOAuth(api.store, api.sess, api.appURL))
+	guest.GET("/auth/saml/:id/metadata", actions.GetSAMLMetadata(api.store, api.appURL))
+	guest.POST("/auth/saml/:id/acs", actions.SAMLCallback(api.store, api.sess, api.boundarysvc, api.appURL))
+	guest.GET("/auth/oidc/:id/callback", actions.OIDCCallback(api.store, api.sess, api.boundarysvc, api.appURL))
 	guest.POST("/authenticate", actions.PostAuthenticate(api.store, api.sess))
+	guest.POST("/authenticate/two-factor", actions.PostAuthenticateTwoFactor(api.store, api.sess))
 	guest.POST("/forgot-password",
 		actions.PostForgotPassword(
 			api.store,
@@ -274,6 +340,32 @@ func (api API) SetGuestRoutes(handler *gin.Engine, middleware ...gin.HandlerFunc
 			api.appURL,
 		),
 	)
+	guest.POST("/unsubscribe/one-click",
+		actions.PostOneClickUnsubscribe(
+			api.store,
+			api.unsubscribeTokenSecret,
+		),
+	)
+	guest.POST("/preferences",
+		actions.PostPreferences(
+			api.store,
+			api.unsubscribeTokenSecret,
+			api.appURL,
+		),
+	)
+	guest.POST("/subscribe/:uuid",
+		actions.PostSubscribe(
+			api.store,
+			api.templatesvc,
+			api.boundarysvc,
+			api.emailSender,
+			api.recaptchaSecret,
+			api.systemEmail,
+			api.appURL,
+		),
+	)
+	guest.GET("/subscribe/confirm/:token", actions.GetConfirmSubscription(api.store))
+	guest.POST("/subscribe/confirm/:token", actions.PostConfirmSubscription(api.store, api.templatesvc))
 }
 
 // SetAuthorizedRoutes sets the authorized routes to the gin engine handler along with
@@ -290,6 +382,14 @@ func (api API) SetAuthorizedRoutes(handler *gin.Engine, middlewares ...gin.Handl
 		{
 			users.GET("/me", actions.GetMe)
 			users.POST("/password", actions.ChangePassword(api.store))
+			users.GET("/two-factor", actions.GetTwoFactor(api.store))
+			users.POST("/two-factor", actions.PostTwoFactor(api.store))
+			users.POST("/two-factor/confirm", actions.PostConfirmTwoFactor(api.store))
+			users.POST("/two-factor/recovery-codes", actions.PostRecoveryCodes(api.store))
+			users.DELETE("/two-factor", actions.DeleteTwoFactor(api.store))
+			users.GET("/sessions", actions.GetSessions(api.store, api.sess))
+			users.DELETE("/sessions", actions.DeleteOtherSessions(api.store))
+			users.DELETE("/sessions/:id", actions.DeleteSession(api.store, api.sess))
 		}
 
 		templates := authorized.Group("/templates")
@@ -299,6 +399,20 @@ func (api API) SetAuthorizedRoutes(handler *gin.Engine, middlewares ...gin.Handl
 			templates.POST("", actions.PostTemplate(api.templatesvc, api.store))
 			templates.PUT("/:id", actions.PutTemplate(api.templatesvc, api.store))
 			templates.DELETE("/:id", actions.DeleteTemplate(api.templatesvc))
+			templates.GET("/:id/versions", actions.GetTemplateVersions(api.templatesvc))
+			templates.GET("/:id/versions/:version", actions.GetTemplateVersion(api.templatesvc))
+			templates.POST("/:id/versions/:version/rollback", actions.PostTemplateRollback(api.templatesvc))
+			templates.GET("/:id/diff", actions.GetTemplateDiff(api.templatesvc))
+		}
+
+		partials := authorized.Group("/partials")
+		{
+			partials.GET("", actions.GetPartials(api.store))
+			partials.GET("/:id", actions.GetPartial(api.store))
+			partials.POST("", actions.PostPartial(api.store))
+			partials.PUT("/:id", actions.PutPartial(api.store))
+			partials.DELETE("/:id", actions.DeletePartial(api.store))
+			partials.GET("/:id/templates", actions.GetPartialTemplates(api.store))
 		}
 
 		campaigns := authorized.Group("/campaigns")
@@ -308,14 +422,28 @@ func (api API) SetAuthorizedRoutes(handler *gin.Engine, middlewares ...gin.Handl
 			campaigns.POST("", actions.PostCampaign(api.boundarysvc, api.store))
 			campaigns.PUT("/:id", actions.PutCampaign(api.store))
 			campaigns.DELETE("/:id", actions.DeleteCampaign(api.store))
-			campaigns.POST("/:id/start", actions.StartCampaign(api.store, api.sqsPublisher, api.campaignerQueueURL))
+			campaigns.POST("/:id/start", actions.StartCampaign(api.store, api.sqsPublisher, api.campaignerQueueURL, api.maildir))
+			campaigns.POST("/:id/pause", actions.PauseCampaign(api.store))
+			campaigns.POST("/:id/resume", actions.ResumeCampaign(api.store, api.sqsPublisher, api.campaignerQueueURL))
+			campaigns.POST("/:id/cancel", actions.CancelCampaign(api.store))
 			campaigns.GET("/:id/opens", middleware.PaginateWithCursor(), actions.GetCampaignOpens(api.store))
+			campaigns.GET("/:id/instances", middleware.PaginateWithCursor(), actions.GetCampaignInstances(api.store))
 			campaigns.GET("/:id/stats", actions.GetCampaignStats(api.store))
+			campaigns.GET("/:id/progress", actions.GetCampaignProgress(api.store))
+			campaigns.GET("/:id/ab-test", actions.GetABTest(api.store))
+			campaigns.PUT("/:id/ab-test", actions.PutABTest(api.store))
+			campaigns.DELETE("/:id/ab-test", actions.DeleteABTest(api.store))
+			campaigns.GET("/:id/ab-test/stats", actions.GetABTestStats(api.store))
 			campaigns.GET("/:id/clicks", actions.GetCampaignClicksStats(api.store))
 			campaigns.GET("/:id/complaints", middleware.PaginateWithCursor(), actions.GetCampaignComplaints(api.store))
 			campaigns.GET("/:id/bounces", middleware.PaginateWithCursor(), actions.GetCampaignBounces(api.store))
 			campaigns.PATCH("/:id/schedule", actions.PatchCampaignSchedule(api.store))
 			campaigns.DELETE("/:id/schedule", actions.DeleteCampaignSchedule(api.store))
+			campaigns.GET("/:id/preview", actions.GetCampaignPreview(api.store, api.templatesvc, api.unsubscribeTokenSecret, api.appURL))
+			campaigns.GET("/:id/lint", actions.GetCampaignLint(api.store, api.templatesvc, api.lintsvc, api.unsubscribeTokenSecret, api.appURL))
+			campaigns.POST("/:id/test-send", actions.PostSendTest(api.store, api.templatesvc, api.maildir, api.unsubscribeTokenSecret, api.appURL))
+			campaigns.GET("/:id/captured", actions.GetCapturedMessages(api.store, api.maildir))
+			campaigns.GET("/:id/captured/:message_id", actions.GetCapturedMessage(api.store, api.maildir))
 		}
 
 		segments := authorized.Group("/segments")
@@ -323,6 +451,7 @@ func (api API) SetAuthorizedRoutes(handler *gin.Engine, middlewares ...gin.Handl
 			segments.GET("", middleware.PaginateWithCursor(), actions.GetSegments(api.store))
 			segments.GET("/:id", actions.GetSegment(api.store))
 			segments.POST("", actions.PostSegment(api.store))
+			segments.POST("/preview", actions.PreviewSegment(api.store))
 			segments.PUT("/:id", actions.PutSegment(api.store))
 			segments.DELETE("/:id", actions.DeleteSegment(api.store))
 			segments.PUT("/:id/subscribers", actions.PutSegmentSubscribers(api.store))
@@ -335,6 +464,7 @@ func (api API) SetAuthorizedRoutes(handler *gin.Engine, middlewares ...gin.Handl
 		{
 			subscribers.GET("", middleware.PaginateWithCursor(), actions.GetSubscribers(api.store))
 			subscribers.GET("/:id", actions.GetSubscriber(api.store))
+			subscribers.GET("/:id/consents", actions.GetSubscriberConsents(api.store))
 			subscribers.GET("/export/download", actions.DownloadSubscribersReport(api.store, api.s3Client, api.filesBucket))
 			subscribers.POST("", actions.PostSubscriber(api.boundarysvc, api.store))
 			subscribers.PUT("/:id", actions.PutSubscriber(api.store))
@@ -358,6 +488,105 @@ func (api API) SetAuthorizedRoutes(handler *gin.Engine, middlewares ...gin.Handl
 			ses.GET("/quota", actions.GetSESQuota(api.store))
 		}
 
+		deliveryProvider := authorized.Group("/delivery-provider")
+		{
+			deliveryProvider.GET("", actions.GetDeliveryProvider(api.store))
+			deliveryProvider.PUT("", actions.PutDeliveryProvider(api.store))
+			deliveryProvider.DELETE("", actions.DeleteDeliveryProvider(api.store))
+		}
+
+		sendRate := authorized.Group("/send-rate")
+		{
+			sendRate.GET("", actions.GetSendRate(api.store))
+			sendRate.PUT("", actions.PutSendRate(api.store))
+		}
+
+		signupForm := authorized.Group("/signup-form")
+		{
+			signupForm.GET("", actions.GetSignupForm(api.store, api.appURL))
+			signupForm.PUT("", actions.PutSignupForm(api.store, api.appURL))
+		}
+
+		webhooks := authorized.Group("/webhooks")
+		{
+			webhooks.GET("", actions.GetWebhooks(api.store))
+			webhooks.GET("/:id", actions.GetWebhook(api.store))
+			webhooks.POST("", actions.PostWebhook(api.store))
+			webhooks.PUT("/:id", actions.PutWebhook(api.store))
+			webhooks.DELETE("/:id", actions.DeleteWebhook(api.store))
+			webhooks.POST("/:id/test", actions.TestWebhook(api.store, api.webhooksvc))
+			webhooks.GET("/:id/deliveries", middleware.PaginateWithCursor(), actions.GetWebhookDeliveries(api.store))
+			webhooks.GET("/:id/deliveries/:delivery_id", actions.GetWebhookDelivery(api.store))
+			webhooks.POST("/:id/deliveries/:delivery_id/replay", actions.ReplayWebhookDelivery(api.store))
+		}
+
+		apiKeys := authorized.Group("/api-keys")
+		{
+			apiKeys.GET("", actions.GetAPIKeys(api.store))
+			apiKeys.GET("/:id", actions.GetAPIKey(api.store))
+			apiKeys.POST("", actions.PostAPIKey(api.store))
+			apiKeys.PUT("/:id", actions.PutAPIKey(api.store))
+			apiKeys.DELETE("/:id", actions.DeleteAPIKey(api.store))
+		}
+
+		automations := authorized.Group("/automations")
+		{
+			automations.GET("", actions.GetAutomations(api.store))
+			automations.GET("/:id", actions.GetAutomation(api.store))
+			automations.POST("", actions.PostAutomation(api.store))
+			automations.PUT("/:id", actions.PutAutomation(api.store))
+			automations.DELETE("/:id", actions.DeleteAutomation(api.store))
+			automations.GET("/:id/runs", middleware.PaginateWithCursor(), actions.GetAutomationRuns(api.store))
+			automations.GET("/:id/logs", middleware.PaginateWithCursor(), actions.GetAutomationLogs(api.store))
+		}
+
+		transactional := authorized.Group("/transactional")
+		{
+			transactional.POST("/send", actions.PostTransactionalSend(api.store, api.templatesvc, api.sqsPublisher, api.transactionalQueueURL, api.maildir))
+			transactional.GET("/messages/:message_id", actions.GetTransactionalMessage(api.store))
+		}
+
+		authorized.GET("/teams", actions.GetTeams(api.store))
+		authorized.POST("/invitations/accept", actions.PostAcceptTeamInvitation(api.store))
+
+		team := authorized.Group("/team")
+		{
+			team.GET("", actions.GetTeam(api.store))
+			team.POST("", actions.PostTeam(api.store))
+			team.PUT("", actions.PutTeam(api.store))
+			team.GET("/roles", actions.GetTeamRoles(api.store))
+			team.POST("/roles", actions.PostTeamRole(api.store))
+			team.PUT("/roles/:id", actions.PutTeamRole(api.store))
+			team.DELETE("/roles/:id", actions.DeleteTeamRole(api.store))
+			team.GET("/members", actions.GetTeamMembers(api.store))
+			team.PUT("/members/:id", actions.PutTeamMember(api.store))
+			team.DELETE("/members/:id", actions.DeleteTeamMember(api.store))
+			team.DELETE("/members/:id/two-factor", actions.DeleteTeamMemberTwoFactor(
+				api.store,
+				api.emailSender,
+				api.systemEmail,
+			))
+			team.GET("/invitations", actions.GetTeamInvitations(api.store))
+			team.POST("/invitations", actions.PostTeamInvitation(
+				api.store,
+				api.boundarysvc,
+				api.emailSender,
+				api.systemEmail,
+				api.appURL,
+			))
+			team.DELETE("/invitations/:id", actions.DeleteTeamInvitation(api.store))
+		}
+
+		ssoConnections := authorized.Group("/sso-connections")
+		{
+			ssoConnections.GET("", actions.GetSSOConnections(api.store, api.appURL))
+			ssoConnections.GET("/:id", actions.GetSSOConnection(api.store, api.appURL))
+			ssoConnections.POST("", actions.PostSSOConnection(api.store, api.appURL))
+			ssoConnections.PUT("/:id", actions.PutSSOConnection(api.store, api.appURL))
+			ssoConnections.POST("/:id/verify", actions.VerifySSOConnection(api.store, api.appURL))
+			ssoConnections.DELETE("/:id", actions.DeleteSSOConnection(api.store))
+		}
+
 		s3 := authorized.Group("/s3")
 		{
 			s3.POST("/sign", actions.GetSignedURL(api.s3Client, api.filesBucket))
@@ -3,6 +3,8 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/csrf"
 	"github.com/sirupsen/logrus"
@@ -20,7 +22,11 @@ import (
 // Authorization header prefixes.
 const (
 	APIKeyAuth = "X-API-Key"
+	// TeamHeader selects the team on whose behalf the request is made.
+	TeamHeader = "X-Team-ID"
 	userKey    = "user"
+	actorKey   = "actor"
+	sessionKey = "session"
 )
 
 // GetUser returns the user set in the context
@@ -38,6 +44,38 @@ func GetUser(c *gin.Context) *entities.User {
 	return user
 }
 
+// GetActor returns the user who made the request. It's the same as the user set in the context,
+// except for the members of a team, where the user in the context is the owner of the team.
+func GetActor(c *gin.Context) *entities.User {
+	val, ok := c.Get(actorKey)
+	if !ok {
+		return GetUser(c)
+	}
+
+	user, ok := val.(*entities.User)
+	if !ok {
+		return GetUser(c)
+	}
+
+	return user
+}
+
+// GetSession returns the session set in the context, it's nil for the requests
+// authenticated with an api key.
+func GetSession(c *gin.Context) *entities.Session {
+	val, ok := c.Get(sessionKey)
+	if !ok {
+		return nil
+	}
+
+	s, ok := val.(*entities.Session)
+	if !ok {
+		return nil
+	}
+
+	return s
+}
+
 // Authorized is a middleware that checks if the user is authorized to do the
 // requested action.
 func Authorized(
@@ -46,7 +84,11 @@ func Authorized(
 	compiler *ast.Compiler,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var u *entities.User
+		var (
+			u      *entities.User
+			s      *entities.Session
+			scopes []string
+		)
 
 		authHeader := c.GetHeader(APIKeyAuth)
 		if authHeader != "" {
@@ -58,13 +100,28 @@ func Authorized(
 			}
 
 			u = &key.User
+			scopes = key.Scopes
+			if scopes == nil {
+				scopes = []string{}
+			}
+
+			// the last use is tracked with a minute precision to avoid writing on every request
+			if !key.LastUsedAt.Valid || time.Since(key.LastUsedAt.Time) > time.Minute {
+				err = storage.UpdateAPIKeyLastUsedAt(key.ID, time.Now())
+				if err != nil {
+					logger.From(c).WithError(err).Error("auth: unable to update the last use of the api key")
+				}
+			}
+
 			// When using api keys it's ok to skip the csrf token
 			// since we are not using cookies to authenticate the user
 			c.Request = csrf.UnsafeSkipCheck(c.Request)
 		} else {
-			s, err := sess.GetUserSession(c)
+			var err error
+			s, err = sess.GetUserSession(c)
 			if err != nil {
-				if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, session.ErrNotFound) {
+				if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, session.ErrNotFound) &&
+					!errors.Is(err, session.ErrExpired) {
 					logrus.WithError(err).Error("authorized: unable to get user session")
 				}
 				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "You are not authorized to perform this request."})
@@ -74,11 +131,52 @@ func Authorized(
 			u = &s.User
 		}
 
+		var (
+			actor       = u
+			roles       = u.RoleNames()
+			permissions = map[string]entities.Permissions{}
+			team        bool
+		)
+		if h := c.GetHeader(TeamHeader); h != "" {
+			// members of a team act on behalf of the owner of the team, with the permissions of their role
+			teamID, err := strconv.ParseInt(h, 10, 64)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "Team id must be an integer."})
+				return
+			}
+
+			m, err := storage.GetTeamMember(teamID, u.ID)
+			if err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					logger.From(c).WithError(err).Error("auth: unable to fetch team member")
+				}
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "You are not authorized to perform this request."})
+				return
+			}
+
+			u, err = storage.GetUser(m.Team.UserID)
+			if err != nil {
+				logger.From(c).WithError(err).Error("auth: unable to fetch team owner")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "You are not authorized to perform this request."})
+				return
+			}
+
+			roles = []string{m.Role.Name}
+			permissions[m.Role.Name] = m.Role.Permissions
+			team = true
+		}
+
 		// Create a new query that uses the compiled policy from above.
 		input := map[string]interface{}{
-			"roles":  u.RoleNames(),
-			"method": c.Request.Method,
-			"path":   c.FullPath(),
+			"roles":            roles,
+			"role_permissions": permissions,
+			"method":           c.Request.Method,
+			"path":             c.FullPath(),
+			"team":             team,
+		}
+		if scopes != nil {
+			// the requests made with an api key are limited to the scopes of the key
+			input["scopes"] = scopes
 		}
 		rego := rego.New(
 			rego.Query("data.rbac.authz.allow"),
@@ -112,8 +210,15 @@ func Authorized(
 		}
 
 		c.Set(userKey, u)
+		c.Set(actorKey, actor)
+		if s != nil {
+			c.Set(sessionKey, s)
+		}
 
 		entry := logger.From(c).WithField("user_id", u.ID)
+		if actor.ID != u.ID {
+			entry = entry.WithField("actor_id", actor.ID)
+		}
 		logger.SetToContext(c, entry)
 
 		c.Next()
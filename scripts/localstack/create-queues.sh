@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+
+set -euxo pipefail
+
+# SendCampaign is consumed by the campaigner, which queues an email for each subscriber.
+awslocal sqs create-queue --queue-name SendCampaign
+# SendEmail is consumed by the sender, it holds the campaign and automation emails.
+awslocal sqs create-queue --queue-name SendEmail
+# SendTransactionalEmail is consumed by the sender ahead of the SendEmail queue.
+awslocal sqs create-queue --queue-name SendTransactionalEmail
@@ -0,0 +1,322 @@
+package automations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/emails"
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/services/campaigns"
+	"github.com/mailbadger/app/services/templates"
+	awssqs "github.com/mailbadger/app/sqs"
+	"github.com/mailbadger/app/storage"
+)
+
+const (
+	batchSize = 100
+	// retryDelay is the delay before the step is retried, when its email could not be prepared or queued.
+	retryDelay = 5 * time.Minute
+)
+
+// errPermanent is returned when the email of the step can't be prepared until the automation or
+// its template is changed, so the step is not retried.
+var errPermanent = errors.New("permanent error")
+
+// Scheduler advances the subscribers through the steps of the automations. The progress of each
+// subscriber is stored after every step, so the scheduler continues where it left off after a restart.
+type Scheduler struct {
+	s           storage.Storage
+	templatesvc templates.Service
+	campaignsvc campaigns.Service
+	queueURL    awssqs.SendEmailQueueURL
+}
+
+// tick holds the delivery settings of the users and the parsed templates, so they are
+// fetched once for all the runs which are due.
+type tick struct {
+	senders   map[int64]*entities.CampaignerTopicParams
+	templates map[int64]*entities.CampaignTemplateData
+}
+
+func New(
+	s storage.Storage,
+	templatesvc templates.Service,
+	campaignsvc campaigns.Service,
+	queueURL awssqs.SendEmailQueueURL,
+) *Scheduler {
+	return &Scheduler{
+		s:           s,
+		templatesvc: templatesvc,
+		campaignsvc: campaignsvc,
+		queueURL:    queueURL,
+	}
+}
+
+func (sched *Scheduler) Start(ctx context.Context, d time.Duration) error {
+	logger.From(ctx).Debug("automations: starting automations scheduler")
+
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err := sched.execute(ctx)
+			if err != nil {
+				logger.From(ctx).WithError(err).Error("automations: execute returned error")
+			}
+		}
+	}
+}
+
+func (sched *Scheduler) execute(ctx context.Context) error {
+	runs, err := sched.s.GetDueAutomationRuns(time.Now().UTC(), batchSize)
+	if err != nil {
+		return fmt.Errorf("automations: failed to get due runs: %w", err)
+	}
+
+	t := &tick{
+		senders:   make(map[int64]*entities.CampaignerTopicParams),
+		templates: make(map[int64]*entities.CampaignTemplateData),
+	}
+
+	for i := range runs {
+		err := sched.advance(ctx, t, &runs[i])
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"run_id":        runs[i].ID,
+				"automation_id": runs[i].AutomationID,
+				"subscriber_id": runs[i].SubscriberID,
+			}).WithError(err).Error("automations: failed to advance run")
+		}
+	}
+
+	return nil
+}
+
+// advance executes the steps of the automation for the subscriber, until it reaches a delay
+// or the end of the automation.
+func (sched *Scheduler) advance(ctx context.Context, t *tick, r *entities.AutomationRun) error {
+	a, err := sched.s.GetAutomation(r.AutomationID, r.UserID)
+	if err != nil {
+		return fmt.Errorf("get automation: %w", err)
+	}
+
+	sub, err := sched.s.GetSubscriber(r.SubscriberID, r.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return sched.finish(r, entities.AutomationRunStatusExited, "Subscriber not found.")
+		}
+		return fmt.Errorf("get subscriber: %w", err)
+	}
+
+	for r.Position < len(a.Steps) {
+		step := &a.Steps[r.Position]
+
+		switch step.Type {
+		case entities.AutomationStepDelay:
+			r.Position++
+			r.NextRunAt.SetValid(time.Now().UTC().Add(time.Duration(step.DelayMinutes) * time.Minute))
+			return sched.s.UpdateAutomationRun(r)
+		case entities.AutomationStepCondition:
+			sub.Segments, err = sched.s.GetSubscriberSegments(sub.ID, sub.UserID)
+			if err != nil {
+				return fmt.Errorf("get subscriber segments: %w", err)
+			}
+			ok, err := step.ConditionMet(sub)
+			if err != nil {
+				return fmt.Errorf("check condition: %w", err)
+			}
+			if !ok {
+				return sched.finish(r, entities.AutomationRunStatusExited, fmt.Sprintf("Condition of step %d is not met.", r.Position+1))
+			}
+			r.Position++
+		case entities.AutomationStepSendTemplate:
+			if !sub.Active || sub.Blacklisted {
+				return sched.finish(r, entities.AutomationRunStatusExited, "Subscriber is not active.")
+			}
+			// the emails are postponed while the subscriber has paused them in the preference center
+			if sub.PausedUntil.Valid && sub.PausedUntil.Time.After(time.Now()) {
+				r.NextRunAt.SetValid(sub.PausedUntil.Time)
+				return sched.s.UpdateAutomationRun(r)
+			}
+
+			params, err := sched.prepare(ctx, t, r, a, step, sub)
+			if err != nil {
+				if errors.Is(err, errPermanent) {
+					return sched.finish(r, entities.AutomationRunStatusFailed, fmt.Sprintf("Unable to prepare the email of step %d.", r.Position+1))
+				}
+				return sched.retry(r, fmt.Errorf("prepare email: %w", err))
+			}
+
+			// the event id is stored before the email is queued, when the step is retried the email
+			// is queued with the same event id and the sender skips it if it was already sent.
+			if r.EventID.IsNil() {
+				r.EventID = ksuid.New()
+				err = sched.s.UpdateAutomationRun(r)
+				if err != nil {
+					return fmt.Errorf("update run: %w", err)
+				}
+			}
+			params.EventID = r.EventID
+
+			err = sched.campaignsvc.PublishSubscriberEmailParams(ctx, params, sched.queueURL)
+			if err != nil {
+				return sched.retry(r, fmt.Errorf("publish email: %w", err))
+			}
+
+			r.Position++
+			r.EventID = ksuid.Nil
+		default:
+			r.Position++
+		}
+	}
+
+	return sched.finish(r, entities.AutomationRunStatusCompleted, "")
+}
+
+// prepare renders the template of the step for the subscriber. The errors which won't be resolved
+// by retrying the step, such as a missing or invalid template, are wrapped with errPermanent.
+func (sched *Scheduler) prepare(
+	ctx context.Context,
+	t *tick,
+	r *entities.AutomationRun,
+	a *entities.Automation,
+	step *entities.AutomationStep,
+	sub *entities.Subscriber,
+) (*entities.SenderTopicParams, error) {
+	logEntry := logrus.WithFields(logrus.Fields{
+		"automation_id": a.ID,
+		"step_id":       step.ID,
+		"subscriber_id": sub.ID,
+	})
+
+	if step.TemplateID == nil {
+		logEntry.Error("automations: template of step is not set")
+		return nil, fmt.Errorf("template is not set: %w", errPermanent)
+	}
+
+	sender, err := sched.sender(t, r.UserID)
+	if err != nil {
+		logEntry.WithError(err).Error("automations: failed to get delivery settings")
+		return nil, err
+	}
+
+	tmpl, ok := t.templates[*step.TemplateID]
+	if !ok {
+		tmpl, err = sched.templatesvc.ParseTemplate(ctx, *step.TemplateID, r.UserID)
+		if err != nil {
+			logEntry.WithError(err).Error("automations: failed to parse template")
+			if templates.IsPermanentError(err) {
+				return nil, fmt.Errorf("%w: %s", errPermanent, err)
+			}
+			return nil, err
+		}
+		t.templates[*step.TemplateID] = tmpl
+	}
+
+	data, err := step.GetTemplateData()
+	if err != nil {
+		logEntry.WithError(err).Error("automations: failed to unmarshal template data")
+		return nil, fmt.Errorf("%w: %s", errPermanent, err)
+	}
+
+	msg := *sender
+	msg.Source = fmt.Sprintf("%s <%s>", step.FromName, step.Source)
+	msg.TemplateData = data
+
+	// the automation emails are not tracked, as they don't belong to a campaign
+	params, err := sched.campaignsvc.PrepareSubscriberEmailData(*sub, msg, &entities.Campaign{}, 0, tmpl.HTMLPart, tmpl.SubjectPart, tmpl.TextPart)
+	if err != nil {
+		logEntry.WithError(err).Error("automations: failed to prepare email data")
+		return nil, fmt.Errorf("%w: %s", errPermanent, err)
+	}
+	params.AutomationID = a.ID
+	params.AutomationStepID = step.ID
+
+	return params, nil
+}
+
+// sender returns the delivery settings of the user, the user's delivery provider is used
+// and if it's not set we fallback to SES.
+func (sched *Scheduler) sender(t *tick, userID int64) (*entities.CampaignerTopicParams, error) {
+	if s, ok := t.senders[userID]; ok {
+		return s, nil
+	}
+
+	u, err := sched.s.GetUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	var (
+		sesKeys    = &entities.SesKeys{}
+		providerID int64
+	)
+	provider, err := sched.s.GetDeliveryProvider(u.ID)
+	if err == nil {
+		providerID = provider.ID
+	} else {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("get delivery provider: %w", err)
+		}
+		sesKeys, err = sched.s.GetSesKeys(u.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get ses keys: %w", err)
+		}
+	}
+
+	var confSetExists bool
+	if providerID == 0 {
+		sender, err := emails.NewSesSenderFromCreds(sesKeys.AccessKey, sesKeys.SecretKey, sesKeys.Region)
+		if err != nil {
+			return nil, fmt.Errorf("new ses sender: %w", err)
+		}
+
+		_, err = sender.DescribeConfigurationSet(&ses.DescribeConfigurationSetInput{
+			ConfigurationSetName: aws.String(emails.ConfigurationSetName),
+		})
+		confSetExists = err == nil
+	}
+
+	s := &entities.CampaignerTopicParams{
+		UserID:                 u.ID,
+		UserUUID:               u.UUID,
+		SesKeys:                *sesKeys,
+		DeliveryProviderID:     providerID,
+		ConfigurationSetExists: confSetExists,
+	}
+	t.senders[userID] = s
+
+	return s, nil
+}
+
+// retry postpones the step of the run by the retry delay, it returns the error which caused the retry.
+func (sched *Scheduler) retry(r *entities.AutomationRun, cause error) error {
+	r.NextRunAt.SetValid(time.Now().UTC().Add(retryDelay))
+	err := sched.s.UpdateAutomationRun(r)
+	if err != nil {
+		return fmt.Errorf("update run: %w", err)
+	}
+	return cause
+}
+
+// finish ends the run of the subscriber with the given status.
+func (sched *Scheduler) finish(r *entities.AutomationRun, status, description string) error {
+	r.Status = status
+	r.Description = description
+	r.EventID = ksuid.Nil
+	r.NextRunAt.Valid = false
+	return sched.s.UpdateAutomationRun(r)
+}
@@ -11,6 +11,7 @@ import (
 type Service interface {
 	CampaignsLimitExceeded(user *entities.User) (bool, error)
 	SubscribersLimitExceeded(user *entities.User) (bool, int64, error)
+	TeamMembersLimitExceeded(user *entities.User, teamID int64) (bool, error)
 }
 
 type service struct {
@@ -47,3 +48,17 @@ func (s *service) SubscribersLimitExceeded(user *entities.User) (bool, int64, er
 	}
 	return false, 0, nil
 }
+
+// TeamMembersLimitExceeded checks whether the team of the user has reached the members limit,
+// the pending invitations are counted as members.
+func (s *service) TeamMembersLimitExceeded(user *entities.User, teamID int64) (bool, error) {
+	limit := user.Boundaries.TeamMembersLimit
+	if limit > 0 {
+		count, err := s.store.GetTotalTeamMembers(teamID)
+		if err != nil {
+			return true, fmt.Errorf("boundaries: get total team members: %w", err)
+		}
+		return count >= limit, nil
+	}
+	return false, nil
+}
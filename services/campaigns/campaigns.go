@@ -19,7 +19,8 @@ type Service interface {
 	PrepareSubscriberEmailData(
 		s entities.Subscriber,
 		msg entities.CampaignerTopicParams,
-		campaignID int64,
+		campaign *entities.Campaign,
+		variantID int64,
 		html *mustache.Template,
 		sub *mustache.Template,
 		text *mustache.Template,
@@ -61,7 +62,8 @@ func New(
 func (svc *service) PrepareSubscriberEmailData(
 	s entities.Subscriber,
 	msg entities.CampaignerTopicParams,
-	campaignID int64,
+	campaign *entities.Campaign,
+	variantID int64,
 	html *mustache.Template,
 	sub *mustache.Template,
 	text *mustache.Template,
@@ -95,15 +97,47 @@ func (svc *service) PrepareSubscriberEmailData(
 
 	m[entities.TagUnsubscribeUrl] = url
 
-	err = html.FRender(&htmlBuf, m)
+	prefURL, err := s.GetPreferencesURL(msg.UserUUID, svc.unsubscribeSecret, svc.appURL)
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: get preferences url: %w", err)
+	}
+
+	m[entities.TagPreferencesUrl] = prefURL
+
+	oneClickURL, err := s.GetOneClickUnsubscribeURL(msg.UserUUID, svc.unsubscribeSecret, svc.appURL)
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: get one-click unsubscribe url: %w", err)
+	}
+
+	// the items of the feed are rendered inside the feed_items section
+	contexts := []interface{}{m}
+	if len(msg.FeedItems) > 0 {
+		items := make([]map[string]string, len(msg.FeedItems))
+		for i, item := range msg.FeedItems {
+			items[i] = item.TemplateData()
+		}
+		contexts = append(contexts, map[string]interface{}{entities.TagFeedItems: items})
+	}
+
+	err = html.FRender(&htmlBuf, contexts...)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: prepare email data: render html: %w", err)
 	}
-	err = sub.FRender(&subBuf, m)
+	htmlPart, err := svc.addTracking(htmlBuf.Bytes(), campaign, entities.TrackingToken{
+		UserID:       msg.UserID,
+		CampaignID:   campaign.ID,
+		VariantID:    variantID,
+		SubscriberID: s.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: prepare email data: add tracking: %w", err)
+	}
+
+	err = sub.FRender(&subBuf, contexts...)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: prepare email data: render subject: %w", err)
 	}
-	err = text.FRender(&textBuf, m)
+	err = text.FRender(&textBuf, contexts...)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: prepare email data: render text: %w", err)
 	}
@@ -114,13 +148,16 @@ func (svc *service) PrepareSubscriberEmailData(
 		SubscriberEmail:        s.Email,
 		Source:                 msg.Source,
 		ConfigurationSetExists: msg.ConfigurationSetExists,
-		CampaignID:             campaignID,
+		CampaignID:             campaign.ID,
 		SesKeys:                msg.SesKeys,
-		HTMLPart:               htmlBuf.Bytes(),
+		DeliveryProviderID:     msg.DeliveryProviderID,
+		HTMLPart:               htmlPart,
 		SubjectPart:            subBuf.Bytes(),
 		TextPart:               textBuf.Bytes(),
 		UserUUID:               msg.UserUUID,
 		UserID:                 msg.UserID,
+		ListUnsubscribeURL:     oneClickURL,
+		VariantID:              variantID,
 	}
 
 	return &sender, nil
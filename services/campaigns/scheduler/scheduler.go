@@ -3,6 +3,7 @@ package scheduler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,26 +12,39 @@ import (
 	"github.com/mailbadger/app/emails"
 	"github.com/mailbadger/app/entities"
 	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/services/feeds"
 	awssqs "github.com/mailbadger/app/sqs"
 	"github.com/mailbadger/app/storage"
+	"github.com/segmentio/ksuid"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// feedRetryDelay is the delay before the feed of a recurring campaign is fetched again, after it failed.
+	feedRetryDelay = 15 * time.Minute
+	// batchesLimit is the max number of campaign batches released on each tick.
+	batchesLimit = 100
 )
 
 type Scheduler struct {
 	s                    storage.Storage
 	p                    awssqs.PublisherAPI
 	sendCampaignQueueURL awssqs.CampaignerQueueURL
+	feedsvc              feeds.Service
 }
 
 func New(
 	s storage.Storage,
 	p awssqs.PublisherAPI,
 	queueURL awssqs.CampaignerQueueURL,
+	feedsvc feeds.Service,
 ) *Scheduler {
 	return &Scheduler{
 		s:                    s,
 		p:                    p,
 		sendCampaignQueueURL: queueURL,
+		feedsvc:              feedsvc,
 	}
 }
 
@@ -49,6 +63,14 @@ func (sched *Scheduler) Start(ctx context.Context, d time.Duration) error {
 			if err != nil {
 				logger.From(ctx).WithError(err).Error("scheduler: execute returned error")
 			}
+			err = sched.pickWinners(ctx)
+			if err != nil {
+				logger.From(ctx).WithError(err).Error("scheduler: pick winners returned error")
+			}
+			err = sched.releaseBatches(ctx)
+			if err != nil {
+				logger.From(ctx).WithError(err).Error("scheduler: release batches returned error")
+			}
 		}
 	}
 }
@@ -95,11 +117,25 @@ func (sched *Scheduler) execute(ctx context.Context) error {
 			logEntry.WithError(err).Error("sched: failed to validate template data")
 			continue
 		}
+		campaign.TemplateVersion = template.Version
 
-		sesKeys, err := sched.s.GetSesKeys(u.ID)
-		if err != nil {
-			logEntry.WithError(err).Error("sched: failed to get ses keys")
-			continue
+		var (
+			sesKeys    = &entities.SesKeys{}
+			providerID int64
+		)
+		provider, err := sched.s.GetDeliveryProvider(u.ID)
+		if err == nil {
+			providerID = provider.ID
+		} else {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				logEntry.WithError(err).Error("sched: failed to get delivery provider")
+				continue
+			}
+			sesKeys, err = sched.s.GetSesKeys(u.ID)
+			if err != nil {
+				logEntry.WithError(err).Error("sched: failed to get ses keys")
+				continue
+			}
 		}
 
 		segmentIDs, err := cs.GetSegmentIDs()
@@ -114,15 +150,30 @@ func (sched *Scheduler) execute(ctx context.Context) error {
 			continue
 		}
 
-		sender, err := emails.NewSesSenderFromCreds(sesKeys.AccessKey, sesKeys.SecretKey, sesKeys.Region)
-		if err != nil {
-			logEntry.WithError(err).Error("sched: failed to create new ses sender")
-			continue
+		var confSetExists bool
+		if providerID == 0 {
+			sender, err := emails.NewSesSenderFromCreds(sesKeys.AccessKey, sesKeys.SecretKey, sesKeys.Region)
+			if err != nil {
+				logEntry.WithError(err).Error("sched: failed to create new ses sender")
+				continue
+			}
+
+			_, err = sender.DescribeConfigurationSet(&ses.DescribeConfigurationSetInput{
+				ConfigurationSetName: aws.String(emails.ConfigurationSetName),
+			})
+			confSetExists = err == nil
 		}
 
-		_, err = sender.DescribeConfigurationSet(&ses.DescribeConfigurationSetInput{
-			ConfigurationSetName: aws.String(emails.ConfigurationSetName),
-		})
+		var abTestPhase string
+		abTest, err := sched.s.GetABTest(campaign.ID, u.ID)
+		if err == nil {
+			err = sched.setVariantTemplateVersions(abTest)
+			if err != nil {
+				logEntry.WithError(err).Error("sched: failed to set variant template versions")
+				continue
+			}
+			abTestPhase = entities.ABTestPhaseTest
+		}
 
 		params := &entities.CampaignerTopicParams{
 			EventID:                cs.ID,
@@ -132,9 +183,24 @@ func (sched *Scheduler) execute(ctx context.Context) error {
 			Source:                 fmt.Sprintf("%s <%s>", cs.FromName, cs.Source),
 			UserID:                 u.ID,
 			UserUUID:               u.UUID,
-			ConfigurationSetExists: err == nil,
+			ConfigurationSetExists: confSetExists,
 			SesKeys:                *sesKeys,
+			DeliveryProviderID:     providerID,
+			ABTestPhase:            abTestPhase,
+			TemplateVersion:        template.Version,
+			DeliveryMode:           cs.DeliveryMode,
+			LocalTime:              cs.LocalTime,
+			Timezone:               cs.Timezone,
+		}
+
+		if cs.IsRecurring() {
+			err = sched.sendInstance(ctx, &cs, campaign, params)
+			if err != nil {
+				logEntry.WithError(err).Error("sched: failed to send instance of recurring campaign")
+			}
+			continue
 		}
+
 		paramsByte, err := json.Marshal(params)
 		if err != nil {
 			logEntry.WithError(err).Error("sched: failed to marshal params for campaigner")
@@ -146,6 +212,7 @@ func (sched *Scheduler) execute(ctx context.Context) error {
 			continue
 		}
 		campaign.Status = entities.StatusSending
+		campaign.EventID = &cs.ID
 		err = sched.s.UpdateCampaign(campaign)
 		if err != nil {
 			logEntry.WithError(err).Error("sched: failed to update status of campaign")
@@ -155,3 +222,289 @@ func (sched *Scheduler) execute(ctx context.Context) error {
 
 	return nil
 }
+
+// sendInstance publishes a new instance of the recurring campaign and moves the schedule to its next run.
+// In the RSS mode the instance is sent only when the feed has new items, otherwise the run is skipped.
+func (sched *Scheduler) sendInstance(
+	ctx context.Context,
+	cs *entities.CampaignSchedule,
+	parent *entities.Campaign,
+	params *entities.CampaignerTopicParams,
+) error {
+	now := time.Now().UTC()
+	next, err := cs.NextRun(now)
+	if err != nil {
+		return fmt.Errorf("next run: %w", err)
+	}
+	loc, err := cs.Location()
+	if err != nil {
+		return fmt.Errorf("load location: %w", err)
+	}
+
+	if cs.FeedURL != "" {
+		items, err := sched.feedsvc.Fetch(ctx, cs.FeedURL)
+		if err != nil {
+			// the run is retried later, as long as the next run is not due
+			cs.ScheduledAt = now.Add(feedRetryDelay)
+			if cs.ScheduledAt.After(next) {
+				cs.ScheduledAt = next
+			}
+			uerr := sched.s.UpdateCampaignSchedule(cs)
+			if uerr != nil {
+				return fmt.Errorf("update schedule: %w", uerr)
+			}
+			return fmt.Errorf("fetch feed: %w", err)
+		}
+
+		cs.ScheduledAt = next
+		cs.LastRunAt.SetValid(now)
+
+		seen, err := cs.GetFeedSeenItems()
+		if err != nil {
+			logrus.WithField("campaign_id", parent.ID).WithError(err).Warn("sched: unable to decode seen feed items")
+		}
+		newItems := entities.NewFeedItems(items, seen, cs.FeedLastItemAt)
+
+		// the items of the current feed are remembered, the items which dropped out of it are not published again
+		err = cs.SetFeedSeenItems(items)
+		if err != nil {
+			return fmt.Errorf("set seen feed items: %w", err)
+		}
+
+		if len(newItems) == 0 {
+			logrus.WithField("campaign_id", parent.ID).Info("sched: feed has no new items, skipping run")
+			return sched.s.UpdateCampaignSchedule(cs)
+		}
+
+		latest := entities.LatestFeedItemAt(newItems)
+		if latest.IsZero() {
+			latest = now
+		}
+		cs.FeedLastItemAt.SetValid(latest)
+		params.FeedItems = newItems
+	}
+
+	cs.ScheduledAt = next
+	cs.LastRunAt.SetValid(now)
+
+	instance := parent.NewInstance(fmt.Sprintf("%s (%s)", parent.Name, now.In(loc).Format("2006-01-02 15:04")))
+	err = sched.s.CreateCampaignInstance(instance, cs)
+	if err != nil {
+		return fmt.Errorf("create instance: %w", err)
+	}
+
+	params.EventID = *instance.EventID
+	params.CampaignID = instance.ID
+	params.ABTestPhase = ""
+
+	paramsByte, err := json.Marshal(params)
+	if err == nil {
+		err = sched.p.SendMessage(ctx, sched.sendCampaignQueueURL, paramsByte)
+	}
+	if err != nil {
+		lerr := sched.s.LogFailedCampaign(instance, "Unable to queue the campaign.")
+		if lerr != nil {
+			logrus.WithField("campaign_id", instance.ID).WithError(lerr).Error("sched: failed to log failed instance")
+		}
+		return fmt.Errorf("publish instance: %w", err)
+	}
+
+	return nil
+}
+
+// pickWinners picks the winning variants of the A/B tests which finished waiting and publishes
+// the campaigns for sending the winning variant to the remaining subscribers.
+func (sched *Scheduler) pickWinners(ctx context.Context) error {
+	tests, err := sched.s.GetABTestsToPickWinner(time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("scheduler: failed to get ab tests: %w", err)
+	}
+
+	for i := range tests {
+		test := &tests[i]
+		logEntry := logrus.WithFields(logrus.Fields{
+			"campaign_id": test.CampaignID,
+			"user_id":     test.UserID,
+		})
+
+		campaign, err := sched.s.GetCampaign(test.CampaignID, test.UserID)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to get campaign")
+			continue
+		}
+
+		if campaign.Status != entities.StatusTesting {
+			// the campaign was cancelled while waiting for the winner
+			logEntry.WithField("status", campaign.Status).Warn("sched: campaign status is not 'testing'")
+			test.Status = entities.ABTestStatusCompleted
+			err = sched.s.UpdateABTest(test)
+			if err != nil {
+				logEntry.WithError(err).Error("sched: failed to update ab test")
+			}
+			continue
+		}
+
+		winner, err := sched.winner(test)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to pick winner")
+			continue
+		}
+
+		params := new(entities.CampaignerTopicParams)
+		err = json.Unmarshal(test.Params, params)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to unmarshal campaigner params")
+			continue
+		}
+		params.EventID = ksuid.New()
+		params.ABTestPhase = entities.ABTestPhaseRemainder
+
+		paramsByte, err := json.Marshal(params)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to marshal params for campaigner")
+			continue
+		}
+
+		// the campaigner reads the winner of the test, so the test and the campaign are updated before
+		// publishing. They are rolled back when publishing fails and the winner is picked on the next tick.
+		testEventID := campaign.EventID
+		test.WinnerVariantID = &winner.ID
+		test.Status = entities.ABTestStatusCompleted
+		err = sched.s.UpdateABTest(test)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to update ab test")
+			continue
+		}
+
+		campaign.Status = entities.StatusSending
+		campaign.EventID = &params.EventID
+		err = sched.s.UpdateCampaign(campaign)
+		if err == nil {
+			err = sched.p.SendMessage(ctx, sched.sendCampaignQueueURL, paramsByte)
+			if err != nil {
+				campaign.Status = entities.StatusTesting
+				campaign.EventID = testEventID
+				if rerr := sched.s.UpdateCampaign(campaign); rerr != nil {
+					logEntry.WithError(rerr).Error("sched: failed to roll back status of campaign")
+				}
+			}
+		}
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to publish campaign to campaigner")
+
+			test.WinnerVariantID = nil
+			test.Status = entities.ABTestStatusTesting
+			if rerr := sched.s.UpdateABTest(test); rerr != nil {
+				logEntry.WithError(rerr).Error("sched: failed to roll back ab test")
+			}
+			continue
+		}
+
+		logEntry.WithField("variant_id", winner.ID).Info("sched: picked A/B test winner")
+	}
+
+	return nil
+}
+
+// releaseBatches publishes the campaign batches whose delivery time has come, the campaigner
+// sends the campaign to the subscribers of the batch.
+func (sched *Scheduler) releaseBatches(ctx context.Context) error {
+	batches, err := sched.s.GetDueCampaignBatches(time.Now().UTC(), batchesLimit)
+	if err != nil {
+		return fmt.Errorf("scheduler: failed to get campaign batches: %w", err)
+	}
+
+	for _, b := range batches {
+		logEntry := logrus.WithFields(logrus.Fields{
+			"campaign_id": b.CampaignID,
+			"user_id":     b.UserID,
+			"batch_id":    b.ID,
+		})
+
+		cp, err := sched.s.GetCampaignCheckpoint(b.EventID)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to get campaign checkpoint")
+			continue
+		}
+
+		params := new(entities.CampaignerTopicParams)
+		err = json.Unmarshal(cp.Params, params)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to unmarshal campaigner params")
+			continue
+		}
+		params.BatchID = b.ID
+
+		paramsByte, err := json.Marshal(params)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to marshal params for campaigner")
+			continue
+		}
+
+		ok, err := sched.s.UpdateCampaignBatchStatus(b.ID, entities.CampaignBatchStatusReleased, entities.CampaignBatchStatusPending)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to update status of campaign batch")
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		err = sched.p.SendMessage(ctx, sched.sendCampaignQueueURL, paramsByte)
+		if err != nil {
+			logEntry.WithError(err).Error("sched: failed to publish campaign batch to campaigner")
+
+			// the batch is released again on the next tick
+			_, err = sched.s.UpdateCampaignBatchStatus(b.ID, entities.CampaignBatchStatusPending, entities.CampaignBatchStatusReleased)
+			if err != nil {
+				logEntry.WithError(err).Error("sched: failed to update status of campaign batch")
+			}
+			continue
+		}
+	}
+
+	return nil
+}
+
+// setVariantTemplateVersions stores the current versions of the variant templates, so the variants
+// are sent with the templates they had when the campaign started.
+func (sched *Scheduler) setVariantTemplateVersions(test *entities.ABTest) error {
+	for i, v := range test.Variants {
+		if v.TemplateID == nil {
+			continue
+		}
+		t, err := sched.s.GetTemplate(*v.TemplateID, test.UserID)
+		if err != nil {
+			return fmt.Errorf("get template of variant %d: %w", v.ID, err)
+		}
+		test.Variants[i].TemplateVersion = t.Version
+	}
+	return sched.s.UpdateVariantTemplateVersions(test.Variants)
+}
+
+// winner returns the variant with the highest open or click rate, on a tie the first variant wins.
+func (sched *Scheduler) winner(test *entities.ABTest) (*entities.CampaignVariant, error) {
+	if len(test.Variants) == 0 {
+		return nil, errors.New("ab test has no variants")
+	}
+
+	var (
+		winner = &test.Variants[0]
+		best   = -1.0
+	)
+	for i := range test.Variants {
+		v := &test.Variants[i]
+		stats, err := sched.s.GetVariantStats(test.CampaignID, test.UserID, v.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get variant stats: %w", err)
+		}
+
+		vs := entities.VariantStats{Variant: *v, Stats: stats}
+		if rate := vs.Rate(test.WinnerMetric); rate > best {
+			best = rate
+			winner = v
+		}
+	}
+
+	return winner, nil
+}
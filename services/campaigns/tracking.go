@@ -0,0 +1,96 @@
+package campaigns
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/mailbadger/app/entities"
+)
+
+var (
+	anchorRegexp = regexp.MustCompile(`(?is)<a\s[^>]*>`)
+	hrefRegexp   = regexp.MustCompile(`(?is)(\shref\s*=\s*)("[^"]*"|'[^']*')`)
+	bodyRegexp   = regexp.MustCompile(`(?i)</body\s*>`)
+)
+
+// addTracking rewrites the links in the html into signed click tracking urls and
+// injects the open tracking pixel, depending on the tracking settings of the campaign.
+func (svc *service) addTracking(htmlPart []byte, campaign *entities.Campaign, t entities.TrackingToken) ([]byte, error) {
+	var err error
+
+	if campaign.TrackClicks {
+		htmlPart, err = svc.rewriteLinks(htmlPart, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if campaign.TrackOpens {
+		token, err := t.Encode(svc.unsubscribeSecret)
+		if err != nil {
+			return nil, fmt.Errorf("encode open token: %w", err)
+		}
+
+		pixel := []byte(`<img src="` + svc.appURL + `/t/o/` + token + `" width="1" height="1" alt="" style="display:none" />`)
+
+		loc := bodyRegexp.FindIndex(htmlPart)
+		if loc == nil {
+			htmlPart = append(htmlPart, pixel...)
+		} else {
+			var buf bytes.Buffer
+			buf.Write(htmlPart[:loc[0]])
+			buf.Write(pixel)
+			buf.Write(htmlPart[loc[0]:])
+			htmlPart = buf.Bytes()
+		}
+	}
+
+	return htmlPart, nil
+}
+
+// rewriteLinks replaces the http(s) links of the anchors with click tracking urls. The links
+// which point to the app itself, like the unsubscribe and preferences urls, are left as they are.
+func (svc *service) rewriteLinks(htmlPart []byte, t entities.TrackingToken) ([]byte, error) {
+	var err error
+
+	out := anchorRegexp.ReplaceAllFunc(htmlPart, func(anchor []byte) []byte {
+		if err != nil {
+			return anchor
+		}
+
+		return hrefRegexp.ReplaceAllFunc(anchor, func(attr []byte) []byte {
+			if err != nil {
+				return attr
+			}
+
+			m := hrefRegexp.FindSubmatch(attr)
+			quoted := string(m[2])
+			link := html.UnescapeString(strings.TrimSpace(quoted[1 : len(quoted)-1]))
+
+			lower := strings.ToLower(link)
+			if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+				return attr
+			}
+			if svc.appURL != "" && strings.HasPrefix(link, svc.appURL) {
+				return attr
+			}
+
+			t.Link = link
+			token, encErr := t.Encode(svc.unsubscribeSecret)
+			if encErr != nil {
+				err = fmt.Errorf("encode click token: %w", encErr)
+				return attr
+			}
+
+			return []byte(string(m[1]) + `"` + svc.appURL + `/t/c/` + token + `"`)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
@@ -0,0 +1,194 @@
+package feeds
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/utils"
+)
+
+const (
+	requestTimeout = 15 * time.Second
+	// maxFeedSize is the max size of the feed which is read, in bytes.
+	maxFeedSize = 5 << 20
+	// maxRedirects is the number of redirects which are followed, the feeds are often moved.
+	maxRedirects = 5
+)
+
+// ErrUnsupportedFeed is returned when the document is neither an RSS nor an Atom feed.
+var ErrUnsupportedFeed = errors.New("feeds: unsupported feed format")
+
+// Service describes the feeds service which fetches the items of the RSS and Atom feeds.
+type Service interface {
+	Fetch(ctx context.Context, url string) ([]entities.FeedItem, error)
+}
+
+type service struct {
+	client *http.Client
+}
+
+// New returns a new feeds service.
+func New() Service {
+	return &service{
+		client: utils.NewPublicHTTPClient(requestTimeout, maxRedirects),
+	}
+}
+
+// Fetch downloads the feed from the url and returns its items.
+func (svc *service) Fetch(ctx context.Context, url string) ([]entities.FeedItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("feeds: new request: %w", err)
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8")
+	req.Header.Set("User-Agent", "Mailbadger-Feeds/1.0")
+
+	resp, err := svc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("feeds: get feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("feeds: get feed: unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("feeds: read feed: %w", err)
+	}
+
+	return Parse(body)
+}
+
+type rssFeed struct {
+	Channel struct {
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			GUID        string `xml:"guid"`
+			PubDate     string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomFeed struct {
+	Entries []struct {
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		ID        string `xml:"id"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// Parse parses the RSS 2.0 or Atom document and returns its items.
+func Parse(data []byte) ([]entities.FeedItem, error) {
+	var root struct {
+		XMLName xml.Name
+	}
+	err := decode(data, &root)
+	if err != nil {
+		return nil, fmt.Errorf("feeds: decode feed: %w", err)
+	}
+
+	switch strings.ToLower(root.XMLName.Local) {
+	case "rss":
+		var feed rssFeed
+		err = decode(data, &feed)
+		if err != nil {
+			return nil, fmt.Errorf("feeds: decode rss feed: %w", err)
+		}
+
+		items := make([]entities.FeedItem, 0, len(feed.Channel.Items))
+		for _, i := range feed.Channel.Items {
+			items = append(items, entities.FeedItem{
+				Title:       strings.TrimSpace(i.Title),
+				Link:        strings.TrimSpace(i.Link),
+				Description: strings.TrimSpace(i.Description),
+				GUID:        strings.TrimSpace(i.GUID),
+				PublishedAt: parseDate(i.PubDate),
+			})
+		}
+		return items, nil
+	case "feed":
+		var feed atomFeed
+		err = decode(data, &feed)
+		if err != nil {
+			return nil, fmt.Errorf("feeds: decode atom feed: %w", err)
+		}
+
+		items := make([]entities.FeedItem, 0, len(feed.Entries))
+		for _, e := range feed.Entries {
+			item := entities.FeedItem{
+				Title:       strings.TrimSpace(e.Title),
+				Description: strings.TrimSpace(e.Summary),
+				GUID:        strings.TrimSpace(e.ID),
+				PublishedAt: parseDate(e.Published),
+			}
+			if item.Description == "" {
+				item.Description = strings.TrimSpace(e.Content)
+			}
+			if item.PublishedAt.IsZero() {
+				item.PublishedAt = parseDate(e.Updated)
+			}
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					item.Link = l.Href
+					break
+				}
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, ErrUnsupportedFeed
+	}
+}
+
+func decode(data []byte, v interface{}) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = charset.NewReaderLabel
+	d.Strict = false
+	return d.Decode(v)
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate parses the date of the item, the zero time is returned when the format is not known.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
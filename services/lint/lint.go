@@ -0,0 +1,210 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/utils"
+)
+
+const (
+	requestTimeout = 5 * time.Second
+	// maxLinks is the max number of distinct links which are checked.
+	maxLinks = 50
+	// concurrency is the number of links which are checked at the same time.
+	concurrency = 5
+	// maxRedirects is the number of redirects which are followed, the links are often shortened or tracked.
+	maxRedirects = 10
+)
+
+// Service describes the lint service which checks the email for common problems before it's sent.
+type Service interface {
+	Lint(ctx context.Context, tmpl *entities.Template, email *entities.EmailPreview) *entities.LintReport
+}
+
+type service struct {
+	client *http.Client
+	appURL string
+}
+
+// New returns a new lint service. The links to the app itself, like the unsubscribe
+// and preferences urls, are not checked.
+func New(appURL string) Service {
+	return &service{
+		client: utils.NewPublicHTTPClient(requestTimeout, maxRedirects),
+		appURL: appURL,
+	}
+}
+
+// Lint checks the template and the rendered email for a missing unsubscribe url, broken links,
+// images without alt text, html which is clipped by Gmail and a missing text part.
+func (svc *service) Lint(ctx context.Context, tmpl *entities.Template, email *entities.EmailPreview) *entities.LintReport {
+	r := &entities.LintReport{
+		HTMLSize: len(email.HTML),
+		Issues:   []entities.LintIssue{},
+	}
+
+	if !tmpl.HasTag(entities.TagUnsubscribeUrl) {
+		r.Add(
+			entities.LintRuleMissingUnsubscribeURL,
+			entities.LintSeverityError,
+			"The template does not contain the {{unsubscribe_url}} tag.",
+			"",
+		)
+	}
+
+	if strings.TrimSpace(email.Text) == "" {
+		r.Add(
+			entities.LintRuleMissingTextPart,
+			entities.LintSeverityWarning,
+			"The email does not have a text part.",
+			"",
+		)
+	}
+
+	if r.HTMLSize > entities.GmailClipSize {
+		r.Add(
+			entities.LintRuleHTMLTooLarge,
+			entities.LintSeverityWarning,
+			fmt.Sprintf("The html part is %d KB, Gmail clips messages larger than %d KB.", r.HTMLSize/1024, entities.GmailClipSize/1024),
+			"",
+		)
+	}
+
+	links, images := scan(email.HTML, svc.appURL)
+	for _, src := range images {
+		r.Add(
+			entities.LintRuleImageMissingAlt,
+			entities.LintSeverityWarning,
+			"The image does not have an alt text.",
+			src,
+		)
+	}
+
+	for _, l := range svc.brokenLinks(ctx, links) {
+		r.Add(
+			entities.LintRuleBrokenLink,
+			entities.LintSeverityError,
+			"The link is broken.",
+			l,
+		)
+	}
+
+	return r
+}
+
+// scan returns the distinct http(s) links of the anchors and the sources of the images without an alt attribute.
+func scan(htmlPart, appURL string) (links, images []string) {
+	seen := make(map[string]bool)
+	z := html.NewTokenizer(strings.NewReader(htmlPart))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links, images
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		switch t.DataAtom {
+		case atom.A:
+			href := strings.TrimSpace(attr(t, "href"))
+			lower := strings.ToLower(href)
+			if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+				continue
+			}
+			if appURL != "" && strings.HasPrefix(href, appURL) {
+				continue
+			}
+			if !seen[href] && len(links) < maxLinks {
+				seen[href] = true
+				links = append(links, href)
+			}
+		case atom.Img:
+			if !hasAttr(t, "alt") {
+				images = append(images, attr(t, "src"))
+			}
+		}
+	}
+}
+
+func attr(t html.Token, key string) string {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(t html.Token, key string) bool {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// brokenLinks requests the links and returns the ones which fail or respond with an error status code.
+func (svc *service) brokenLinks(ctx context.Context, links []string) []string {
+	var (
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, concurrency)
+		broken = make([]bool, len(links))
+	)
+	for i, l := range links {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, l string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			broken[i] = !svc.check(ctx, l)
+		}(i, l)
+	}
+	wg.Wait()
+
+	var res []string
+	for i, l := range links {
+		if broken[i] {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// check requests the link with HEAD, some servers don't support it so GET is used as a fallback.
+func (svc *service) check(ctx context.Context, link string) bool {
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, link, nil)
+		if err != nil {
+			return false
+		}
+		req.Header.Set("User-Agent", "Mailbadger-Lint/1.0")
+
+		resp, err := svc.client.Do(req)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 400 {
+			return true
+		}
+		if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,831 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// The html is processed with the tokenizer instead of the html parser, the parser moves the text out of
+// the tables, which would break the mustache sections around the table rows.
+
+var (
+	partialTagRegexp = regexp.MustCompile(`\{\{>\s*([^\s}]+)\s*\}\}`)
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+	newlinesRegexp   = regexp.MustCompile(`\n{3,}`)
+	cssCommentRegexp = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	compoundRegexp   = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*|\*)?((?:[.#][a-zA-Z0-9_-]+)*)$`)
+	qualifierRegexp  = regexp.MustCompile(`[.#][^.#]+`)
+	mustacheRegexp   = regexp.MustCompile(`\{\{.*?\}\}\}?`)
+	backgroundRegexp = regexp.MustCompile(`^(?i)(https?://|\{\{)`)
+)
+
+// sanitizePolicy is the allowlist which the html is sanitized with, the policy for user generated content
+// extended with the document, the style blocks and the presentational attributes used by the email layouts.
+var sanitizePolicy = newSanitizePolicy()
+
+func newSanitizePolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// the style blocks are kept as they are, the scripts are still dropped since they are not allowed
+	p.AllowUnsafe(true)
+	p.AllowElements("html", "head", "body", "title", "style", "center", "font")
+	p.AllowAttrs("type", "media").OnElements("style")
+	p.AllowAttrs("charset", "content", "name", "http-equiv").OnElements("meta")
+	p.AllowAttrs("xmlns", "xmlns:v", "xmlns:o").OnElements("html")
+	p.AllowAttrs("color", "face", "size").OnElements("font")
+	p.AllowAttrs("target").OnElements("a")
+	p.AllowAttrs("style", "class", "id", "role", "align", "valign", "bgcolor", "width", "height",
+		"border", "cellpadding", "cellspacing").Globally()
+	p.AllowAttrs("background").Matching(backgroundRegexp).Globally()
+	p.AllowURLSchemes("mailto", "http", "https", "tel")
+	p.AllowRelativeURLs(true)
+	p.AllowDataURIImages()
+	p.RequireNoFollowOnLinks(false)
+	// the conditional comments of Outlook are kept, the other comments are removed when the html is minified
+	p.AllowComments()
+	return p
+}
+
+// voidElements don't have an end tag, so they are never open.
+var voidElements = map[atom.Atom]bool{
+	atom.Area:   true,
+	atom.Base:   true,
+	atom.Br:     true,
+	atom.Col:    true,
+	atom.Embed:  true,
+	atom.Hr:     true,
+	atom.Img:    true,
+	atom.Input:  true,
+	atom.Link:   true,
+	atom.Meta:   true,
+	atom.Param:  true,
+	atom.Source: true,
+	atom.Track:  true,
+	atom.Wbr:    true,
+}
+
+// blockElements start on a new line in the generated text part.
+var blockElements = map[atom.Atom]bool{
+	atom.Address:    true,
+	atom.Article:    true,
+	atom.Aside:      true,
+	atom.Blockquote: true,
+	atom.Br:         true,
+	atom.Dd:         true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Footer:     true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Header:     true,
+	atom.Hr:         true,
+	atom.Li:         true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Section:    true,
+	atom.Table:      true,
+	atom.Tr:         true,
+	atom.Ul:         true,
+}
+
+// expandPartials replaces the partial tags with the content of the partials, so the pipeline processes
+// the html as it's sent. The set must be checked for cycles beforehand.
+func expandPartials(content string, set entities.PartialSet) string {
+	return partialTagRegexp.ReplaceAllStringFunc(content, func(tag string) string {
+		name := partialTagRegexp.FindStringSubmatch(tag)[1]
+		return expandPartials(set[name], set)
+	})
+}
+
+// processHTML runs the enabled steps of the pipeline on the html.
+func processHTML(src string, p entities.HTMLPipeline) (string, error) {
+	if p.SanitizeHTML {
+		src = SanitizeHTML(src)
+	}
+	if !p.InlineCSS && !p.MinifyHTML {
+		return src, nil
+	}
+
+	var sheets []stylesheet
+	if p.InlineCSS {
+		sheets = collectStylesheets(src)
+	}
+
+	var rules []cssRule
+	for _, sheet := range sheets {
+		rules = append(rules, sheet.rules...)
+	}
+
+	var (
+		buf   bytes.Buffer
+		z     = nethtml.NewTokenizer(strings.NewReader(src))
+		open  []element
+		style = 0
+		// skip holds the element which is dropped together with its content.
+		skip      atom.Atom
+		skipDepth int
+		// raw is set in the elements whose whitespace is preserved.
+		raw int
+	)
+
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			if z.Err() == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("tokenize html: %w", z.Err())
+		}
+
+		rawToken := string(z.Raw())
+		tok := z.Token()
+
+		if skipDepth > 0 {
+			// the style blocks are counted, so the following blocks are matched with their stylesheets
+			if tt != nethtml.EndTagToken && tok.DataAtom == atom.Style && skip != atom.Style {
+				style++
+			}
+			switch {
+			case tt == nethtml.StartTagToken && tok.DataAtom == skip:
+				skipDepth++
+			case tt == nethtml.EndTagToken && tok.DataAtom == skip:
+				skipDepth--
+			}
+			continue
+		}
+
+		switch tt {
+		case nethtml.CommentToken:
+			if p.MinifyHTML && !isConditionalComment(tok.Data) {
+				continue
+			}
+			buf.WriteString(rawToken)
+
+		case nethtml.TextToken:
+			switch {
+			case len(open) > 0 && open[len(open)-1].atom == atom.Style:
+				css := rawToken
+				if p.InlineCSS && style <= len(sheets) && style > 0 {
+					css = sheets[style-1].remaining
+				}
+				if p.MinifyHTML {
+					css = minifyCSS(css)
+				}
+				buf.WriteString(css)
+			case p.MinifyHTML && raw == 0:
+				buf.WriteString(whitespaceRegexp.ReplaceAllString(rawToken, " "))
+			default:
+				buf.WriteString(rawToken)
+			}
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if tok.DataAtom == atom.Style {
+				style++
+				if p.InlineCSS && style <= len(sheets) && strings.TrimSpace(sheets[style-1].remaining) == "" {
+					if tt == nethtml.StartTagToken {
+						skip, skipDepth = atom.Style, 1
+					}
+					continue
+				}
+			}
+
+			el := newElement(tok)
+			changed := false
+			if p.InlineCSS && len(rules) > 0 && inBody(open, tok.DataAtom) {
+				changed = inlineStyle(&tok, el, open, rules)
+			}
+
+			if changed {
+				buf.WriteString(renderTag(tok, tt == nethtml.SelfClosingTagToken))
+			} else {
+				buf.WriteString(rawToken)
+			}
+
+			if tt == nethtml.StartTagToken && !voidElements[tok.DataAtom] {
+				open = append(open, el)
+				if tok.DataAtom == atom.Pre || tok.DataAtom == atom.Textarea {
+					raw++
+				}
+			}
+
+		case nethtml.EndTagToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i].name == tok.Data {
+					for _, el := range open[i:] {
+						if el.atom == atom.Pre || el.atom == atom.Textarea {
+							raw--
+						}
+					}
+					open = open[:i]
+					break
+				}
+			}
+			buf.WriteString(rawToken)
+
+		default:
+			buf.WriteString(rawToken)
+		}
+	}
+
+	out := buf.String()
+	if p.MinifyHTML {
+		out = strings.TrimSpace(out)
+	}
+	return out, nil
+}
+
+// generateText returns the text of the html, with the blocks on separate lines and the
+// urls of the links after the link text.
+func generateText(src string) string {
+	var (
+		buf  strings.Builder
+		z    = nethtml.NewTokenizer(strings.NewReader(src))
+		skip int
+		// hrefs holds the urls of the open links.
+		hrefs []string
+		// linkText holds the start of the text of the open links.
+		linkText []int
+	)
+
+	newline := func() {
+		s := buf.String()
+		if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+
+		switch tt {
+		case nethtml.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := strings.ReplaceAll(tok.Data, "\u00a0", " ")
+			text = whitespaceRegexp.ReplaceAllString(text, " ")
+			if strings.HasSuffix(buf.String(), "\n") || buf.Len() == 0 {
+				text = strings.TrimLeft(text, " ")
+			}
+			buf.WriteString(text)
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			switch tok.DataAtom {
+			case atom.Head, atom.Style, atom.Script, atom.Title, atom.Noscript:
+				if tt == nethtml.StartTagToken {
+					skip++
+				}
+				continue
+			case atom.A:
+				if tt == nethtml.StartTagToken {
+					hrefs = append(hrefs, attr(tok, "href"))
+					linkText = append(linkText, buf.Len())
+				}
+				continue
+			}
+			if blockElements[tok.DataAtom] {
+				newline()
+			}
+			switch tok.DataAtom {
+			case atom.Li:
+				buf.WriteString("- ")
+			case atom.Hr:
+				buf.WriteString("----------\n")
+			case atom.Td, atom.Th:
+				if s := buf.String(); len(s) > 0 && !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, " ") {
+					buf.WriteString(" ")
+				}
+			}
+
+		case nethtml.EndTagToken:
+			switch tok.DataAtom {
+			case atom.Head, atom.Style, atom.Script, atom.Title, atom.Noscript:
+				if skip > 0 {
+					skip--
+				}
+				continue
+			case atom.A:
+				if len(hrefs) == 0 {
+					continue
+				}
+				href, start := hrefs[len(hrefs)-1], linkText[len(linkText)-1]
+				hrefs, linkText = hrefs[:len(hrefs)-1], linkText[:len(linkText)-1]
+				text := strings.TrimSpace(buf.String()[start:])
+				if href != "" && href != text && !strings.HasPrefix(href, "#") {
+					buf.WriteString(" (" + href + ")")
+				}
+				continue
+			}
+			if blockElements[tok.DataAtom] {
+				newline()
+				switch tok.DataAtom {
+				case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Table, atom.Ul, atom.Ol:
+					buf.WriteString("\n")
+				}
+			}
+		}
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	text := newlinesRegexp.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// element is an open element of the html, it's used to match the css selectors.
+type element struct {
+	atom    atom.Atom
+	name    string
+	id      string
+	classes []string
+}
+
+func newElement(tok nethtml.Token) element {
+	return element{
+		atom:    tok.DataAtom,
+		name:    tok.Data,
+		id:      attr(tok, "id"),
+		classes: strings.Fields(attr(tok, "class")),
+	}
+}
+
+func (el element) hasClass(class string) bool {
+	for _, c := range el.classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// stylesheet holds the rules of a style block which can be inlined, and the css which remains in the block.
+type stylesheet struct {
+	rules     []cssRule
+	remaining string
+}
+
+type cssRule struct {
+	selector    selector
+	specificity [3]int
+	order       int
+	decls       []cssDecl
+}
+
+type cssDecl struct {
+	property  string
+	value     string
+	important bool
+}
+
+// selector is a list of compound selectors, from the element to its ancestors.
+type selector []compound
+
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+	// child is set when the compound must match the parent of the previous one.
+	child bool
+}
+
+// collectStylesheets parses the style blocks of the html.
+func collectStylesheets(src string) []stylesheet {
+	var (
+		sheets  []stylesheet
+		z       = nethtml.NewTokenizer(strings.NewReader(src))
+		inStyle bool
+		order   int
+	)
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		switch tt {
+		case nethtml.StartTagToken:
+			name, _ := z.TagName()
+			if atom.Lookup(name) == atom.Style {
+				inStyle = true
+				sheets = append(sheets, stylesheet{})
+			}
+		case nethtml.SelfClosingTagToken:
+			name, _ := z.TagName()
+			if atom.Lookup(name) == atom.Style {
+				sheets = append(sheets, stylesheet{})
+			}
+		case nethtml.EndTagToken:
+			inStyle = false
+		case nethtml.TextToken:
+			if inStyle {
+				sheets[len(sheets)-1] = parseStylesheet(string(z.Text()), &order)
+			}
+		}
+	}
+	return sheets
+}
+
+// parseStylesheet splits the css into the rules which can be inlined and the remaining css. The at-rules
+// and the selectors with pseudo classes or attributes can't be inlined, so they are kept as they are.
+func parseStylesheet(css string, order *int) stylesheet {
+	var (
+		sheet     stylesheet
+		remaining strings.Builder
+	)
+
+	css = cssCommentRegexp.ReplaceAllString(css, "")
+	for {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			break
+		}
+
+		if strings.HasPrefix(css, "@") {
+			end := atRuleEnd(css)
+			remaining.WriteString(css[:end])
+			remaining.WriteString("\n")
+			css = css[end:]
+			continue
+		}
+
+		open := strings.Index(css, "{")
+		if open == -1 {
+			break
+		}
+		close := strings.Index(css[open:], "}")
+		if close == -1 {
+			break
+		}
+		close += open
+
+		prelude, body := strings.TrimSpace(css[:open]), css[open+1:close]
+		css = css[close+1:]
+
+		decls := parseDeclarations(body)
+		var kept []string
+		for _, sel := range strings.Split(prelude, ",") {
+			sel = strings.TrimSpace(sel)
+			parsed, ok := parseSelector(sel)
+			if !ok {
+				kept = append(kept, sel)
+				continue
+			}
+			*order++
+			sheet.rules = append(sheet.rules, cssRule{
+				selector:    parsed,
+				specificity: parsed.specificity(),
+				order:       *order,
+				decls:       decls,
+			})
+		}
+		if len(kept) > 0 {
+			remaining.WriteString(strings.Join(kept, ", ") + " {" + body + "}\n")
+		}
+	}
+
+	sheet.remaining = remaining.String()
+	return sheet
+}
+
+// atRuleEnd returns the end of the at-rule, either the end of its block or the semicolon.
+func atRuleEnd(css string) int {
+	depth := 0
+	for i, r := range css {
+		switch r {
+		case ';':
+			if depth == 0 {
+				return i + 1
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(css)
+}
+
+func parseDeclarations(body string) []cssDecl {
+	var decls []cssDecl
+	for _, d := range strings.Split(body, ";") {
+		parts := strings.SplitN(d, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		decl := cssDecl{
+			property: strings.ToLower(strings.TrimSpace(parts[0])),
+			value:    strings.TrimSpace(parts[1]),
+		}
+		if i := strings.Index(strings.ToLower(decl.value), "!important"); i != -1 {
+			decl.important = true
+			decl.value = strings.TrimSpace(decl.value[:i])
+		}
+		if decl.property == "" || decl.value == "" {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	return decls
+}
+
+// parseSelector parses the selectors which are made of tags, classes and ids, combined with the
+// descendant and the child combinators.
+func parseSelector(sel string) (selector, bool) {
+	fields := strings.Fields(strings.ReplaceAll(sel, ">", " > "))
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	var (
+		res   selector
+		child bool
+	)
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i] == ">" {
+			if child || len(res) == 0 {
+				return nil, false
+			}
+			child = true
+			continue
+		}
+
+		m := compoundRegexp.FindStringSubmatch(fields[i])
+		if m == nil || (m[1] == "" && m[2] == "") {
+			return nil, false
+		}
+
+		c := compound{tag: strings.ToLower(m[1])}
+		if c.tag == "*" {
+			c.tag = ""
+		}
+		for _, part := range qualifierRegexp.FindAllString(m[2], -1) {
+			if part[0] == '#' {
+				c.id = part[1:]
+			} else {
+				c.classes = append(c.classes, part[1:])
+			}
+		}
+		if len(res) > 0 {
+			res[len(res)-1].child = child
+		}
+		child = false
+		res = append(res, c)
+	}
+	if child {
+		return nil, false
+	}
+	return res, true
+}
+
+func (s selector) specificity() [3]int {
+	var spec [3]int
+	for _, c := range s {
+		if c.id != "" {
+			spec[0]++
+		}
+		spec[1] += len(c.classes)
+		if c.tag != "" {
+			spec[2]++
+		}
+	}
+	return spec
+}
+
+func (c compound) matches(el element) bool {
+	if c.tag != "" && c.tag != el.name {
+		return false
+	}
+	if c.id != "" && c.id != el.id {
+		return false
+	}
+	for _, class := range c.classes {
+		if !el.hasClass(class) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the selector matches the element with the given open ancestors.
+func (s selector) matches(el element, ancestors []element) bool {
+	if !s[0].matches(el) {
+		return false
+	}
+	return s.matchAncestors(1, ancestors)
+}
+
+func (s selector) matchAncestors(i int, ancestors []element) bool {
+	if i == len(s) {
+		return true
+	}
+	for j := len(ancestors) - 1; j >= 0; j-- {
+		if s[i].matches(ancestors[j]) && s.matchAncestors(i+1, ancestors[:j]) {
+			return true
+		}
+		if s[i-1].child {
+			return false
+		}
+	}
+	return false
+}
+
+// inBody reports whether the element is rendered, the styles are not inlined in the head of the html.
+func inBody(open []element, a atom.Atom) bool {
+	switch a {
+	case atom.Html, atom.Head, atom.Style, atom.Meta, atom.Link, atom.Title:
+		return false
+	}
+	for _, el := range open {
+		if el.atom == atom.Head {
+			return false
+		}
+	}
+	return true
+}
+
+// inlineStyle sets the style attribute of the element with the declarations of the matching rules,
+// ordered by their importance, specificity and order. The inline style of the element overrides the rules
+// which are not important.
+func inlineStyle(tok *nethtml.Token, el element, open []element, rules []cssRule) bool {
+	var matched []cssRule
+	for _, r := range rules {
+		if r.selector.matches(el, open) {
+			matched = append(matched, r)
+		}
+	}
+	if len(matched) == 0 {
+		return false
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i].specificity, matched[j].specificity
+		if a != b {
+			return a[0] < b[0] || (a[0] == b[0] && (a[1] < b[1] || (a[1] == b[1] && a[2] < b[2])))
+		}
+		return matched[i].order < matched[j].order
+	})
+
+	var normal, important []cssDecl
+	for _, r := range matched {
+		for _, d := range r.decls {
+			if d.important {
+				important = append(important, d)
+			} else {
+				normal = append(normal, d)
+			}
+		}
+	}
+
+	idx := -1
+	for i, a := range tok.Attr {
+		if a.Key == "style" {
+			idx = i
+		}
+	}
+	if idx != -1 {
+		for _, d := range parseDeclarations(tok.Attr[idx].Val) {
+			if d.important {
+				important = append(important, d)
+			} else {
+				normal = append(normal, d)
+			}
+		}
+	}
+
+	var (
+		props  []string
+		values = make(map[string]cssDecl)
+	)
+	for _, d := range append(normal, important...) {
+		if _, ok := values[d.property]; !ok {
+			props = append(props, d.property)
+		}
+		values[d.property] = d
+	}
+
+	decls := make([]string, len(props))
+	for i, p := range props {
+		d := values[p]
+		decls[i] = d.property + ": " + d.value
+		if d.important {
+			decls[i] += " !important"
+		}
+	}
+	style := strings.Join(decls, "; ")
+
+	if idx == -1 {
+		tok.Attr = append(tok.Attr, nethtml.Attribute{Key: "style", Val: style})
+	} else {
+		tok.Attr[idx].Val = style
+	}
+	return true
+}
+
+// SanitizeHTML removes the elements and the attributes which are not in the allowlist, such as the scripts
+// and the event handlers. The mustache tags are replaced with placeholders while the html is sanitized, since
+// the tags with spaces, such as {{ unsubscribe_url }}, are not valid urls. The doctype is dropped by the
+// sanitizer, so it's added back.
+func SanitizeHTML(src string) string {
+	var (
+		tags    []string
+		doctype string
+	)
+	src = mustacheRegexp.ReplaceAllStringFunc(src, func(tag string) string {
+		tags = append(tags, tag)
+		return mustachePlaceholder(len(tags) - 1)
+	})
+
+	z := nethtml.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == nethtml.TextToken && strings.TrimSpace(string(z.Raw())) == "" {
+			continue
+		}
+		if tt == nethtml.DoctypeToken {
+			doctype = z.Token().String()
+		}
+		break
+	}
+
+	out := doctype + sanitizePolicy.Sanitize(src)
+	for i := len(tags) - 1; i >= 0; i-- {
+		out = strings.ReplaceAll(out, mustachePlaceholder(i), tags[i])
+	}
+	return out
+}
+
+func mustachePlaceholder(i int) string {
+	return fmt.Sprintf("mustache-tag-%d-placeholder", i)
+}
+
+// renderTag renders the start tag, the values of the attributes are escaped apart from the mustache tags.
+func renderTag(tok nethtml.Token, selfClosing bool) string {
+	var b strings.Builder
+	b.WriteString("<" + tok.Data)
+	for _, a := range tok.Attr {
+		b.WriteString(" " + a.Key + `="` + escapeAttr(a.Val) + `"`)
+	}
+	if selfClosing {
+		b.WriteString(" /")
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// escapeAttr escapes the value of the attribute, the mustache tags are kept as they are.
+func escapeAttr(val string) string {
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, loc := range mustacheRegexp.FindAllStringIndex(val, -1) {
+		b.WriteString(html.EscapeString(val[last:loc[0]]))
+		b.WriteString(val[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(val[last:]))
+	return b.String()
+}
+
+func minifyCSS(css string) string {
+	css = cssCommentRegexp.ReplaceAllString(css, "")
+	return strings.TrimSpace(whitespaceRegexp.ReplaceAllString(css, " "))
+}
+
+// isConditionalComment reports whether the comment is a conditional comment of Outlook.
+func isConditionalComment(data string) bool {
+	return strings.HasPrefix(data, "[if") || strings.HasPrefix(data, "<![endif]")
+}
+
+func attr(tok nethtml.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
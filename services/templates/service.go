@@ -6,12 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/cbroglie/mustache"
+	"github.com/segmentio/ksuid"
+	"gorm.io/gorm"
 
 	"github.com/mailbadger/app/config"
 	"github.com/mailbadger/app/entities"
@@ -25,8 +29,23 @@ var (
 	ErrParseHTMLPart    = errors.New("failed to parse HTMLPart")
 	ErrParseTextPart    = errors.New("failed to parse TextPart")
 	ErrParseSubjectPart = errors.New("failed to parse SubjectPart")
+
+	ErrPartialNotFound = errors.New("partial not found")
+	ErrLayoutNotFound  = errors.New("layout not found")
 )
 
+// IsPermanentError checks whether the template can't be parsed until it is changed, because it doesn't
+// exist or it is invalid. The other errors, such as a failed request to the database or S3, are transient.
+func IsPermanentError(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) ||
+		errors.Is(err, ErrHTMLPartNotFound) ||
+		errors.Is(err, ErrParseHTMLPart) ||
+		errors.Is(err, ErrParseTextPart) ||
+		errors.Is(err, ErrParseSubjectPart) ||
+		errors.Is(err, ErrPartialNotFound) ||
+		errors.Is(err, ErrLayoutNotFound)
+}
+
 type Service interface {
 	AddTemplate(c context.Context, input *entities.Template) error
 	UpdateTemplate(c context.Context, input *entities.Template) error
@@ -34,6 +53,11 @@ type Service interface {
 	DeleteTemplate(c context.Context, templateID, userID int64) error
 	GetTemplate(c context.Context, templateID int64, userID int64) (*entities.Template, error)
 	ParseTemplate(c context.Context, templateID int64, userID int64) (*entities.CampaignTemplateData, error)
+	GetTemplateVersions(c context.Context, templateID, userID int64) ([]entities.TemplateVersion, error)
+	GetTemplateVersion(c context.Context, templateID, version, userID int64) (*entities.TemplateVersion, error)
+	DiffTemplateVersions(c context.Context, templateID, from, to, userID int64) (*entities.TemplateDiff, error)
+	RollbackTemplate(c context.Context, templateID, version, userID int64) (*entities.Template, error)
+	ParseTemplateVersion(c context.Context, templateID, version, userID int64) (*entities.CampaignTemplateData, error)
 }
 
 // service implements the Service interface
@@ -72,23 +96,22 @@ func (s service) AddTemplate(c context.Context, template *entities.Template) err
 		return ErrParseSubjectPart
 	}
 
-	err = s.db.CreateTemplate(template)
+	names, err := s.checkPartials(template)
 	if err != nil {
-		return fmt.Errorf("create template: %w", err)
+		return err
 	}
 
-	s3Input := &s3.PutObjectInput{
-		Bucket: aws.String(s.templatesBucket),
-		Key:    aws.String(templateKey(template.UserID, template.ID)),
-		Body:   bytes.NewReader([]byte(template.HTMLPart)),
+	err = s.process(template)
+	if err != nil {
+		return err
 	}
 
-	_, err = s.s3.PutObject(s3Input)
+	err = s.db.CreateTemplate(template)
 	if err != nil {
-		return fmt.Errorf("upload template: put s3 object: %w", err)
+		return fmt.Errorf("create template: %w", err)
 	}
 
-	return nil
+	return s.createVersion(template, names)
 }
 
 func (s service) UpdateTemplate(c context.Context, template *entities.Template) error {
@@ -108,20 +131,143 @@ func (s service) UpdateTemplate(c context.Context, template *entities.Template)
 		return ErrParseSubjectPart
 	}
 
-	s3Input := &s3.PutObjectInput{
+	names, err := s.checkPartials(template)
+	if err != nil {
+		return err
+	}
+
+	err = s.process(template)
+	if err != nil {
+		return err
+	}
+
+	return s.createVersion(template, names)
+}
+
+// checkPartials returns the names of the partials which are included in the template. The partials and
+// the layout of the template must exist, so a typo doesn't end up as an empty block in the sent emails.
+func (s service) checkPartials(template *entities.Template) ([]string, error) {
+	var names []string
+	for _, part := range []string{template.SubjectPart, template.HTMLPart, template.TextPart} {
+		n, err := entities.PartialNames(part)
+		if err != nil {
+			return nil, fmt.Errorf("partial names: %w", err)
+		}
+		names = append(names, n...)
+	}
+
+	if len(names) == 0 && template.LayoutID == nil {
+		return nil, nil
+	}
+
+	partials, err := s.db.GetPartials(template.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get partials: %w", err)
+	}
+
+	set := entities.NewPartialSet(partials)
+	seen := make(map[string]bool)
+	distinct := names[:0]
+	for _, n := range names {
+		if seen[n] {
+			continue
+		}
+		if _, ok := set[n]; !ok {
+			return nil, fmt.Errorf("%s: %w", n, ErrPartialNotFound)
+		}
+		seen[n] = true
+		distinct = append(distinct, n)
+	}
+
+	if template.LayoutID != nil {
+		found := false
+		for _, p := range partials {
+			if p.ID == *template.LayoutID && p.Layout {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrLayoutNotFound
+		}
+	}
+
+	return distinct, nil
+}
+
+// process runs the html pipeline of the template with the current partials and layout, the processed
+// parts are stored with the version so the pipeline doesn't run each time the template is parsed.
+func (s service) process(template *entities.Template) error {
+	if !template.HTMLPipeline.Enabled() {
+		return nil
+	}
+
+	partials, err := s.db.GetPartials(template.UserID)
+	if err != nil {
+		return fmt.Errorf("get partials: %w", err)
+	}
+
+	htmlPart, set, err := expandLayout(template, partials)
+	if err != nil {
+		return err
+	}
+
+	template.ProcessedHTMLPart, template.ProcessedTextPart, err = runPipeline(template.HTMLPipeline, htmlPart, template.TextPart, set)
+	return err
+}
+
+// putHTMLPart uploads the html part under a new key of the template.
+func (s service) putHTMLPart(template *entities.Template, html string) (string, error) {
+	key := versionKey(template.UserID, template.ID)
+	_, err := s.s3.PutObject(&s3.PutObjectInput{
 		Bucket: aws.String(s.templatesBucket),
-		Key:    aws.String(templateKey(template.UserID, template.ID)),
-		Body:   bytes.NewReader([]byte(template.HTMLPart)),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader([]byte(html)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("upload template: put s3 object: %w", err)
+	}
+	return key, nil
+}
+
+// newVersion returns the version of the processed template, the processed html part is uploaded
+// next to the source which is kept for editing.
+func (s service) newVersion(template *entities.Template, htmlKey string) (*entities.TemplateVersion, error) {
+	v := &entities.TemplateVersion{HTMLKey: htmlKey}
+	if template.ProcessedHTMLPart == "" {
+		return v, nil
+	}
+
+	key, err := s.putHTMLPart(template, template.ProcessedHTMLPart)
+	if err != nil {
+		return nil, err
+	}
+	v.ProcessedHTMLKey = key
+	v.ProcessedTextPart = template.ProcessedTextPart
+	return v, nil
+}
+
+// createVersion uploads the html part under a new key and saves the template as a new version,
+// the html parts of the previous versions are kept so they can be restored.
+func (s service) createVersion(template *entities.Template, partials []string) error {
+	key, err := s.putHTMLPart(template, template.HTMLPart)
+	if err != nil {
+		return err
+	}
+
+	v, err := s.newVersion(template, key)
+	if err != nil {
+		return err
 	}
 
-	_, err = s.s3.PutObject(s3Input)
+	err = s.db.CreateTemplateVersion(template, v)
 	if err != nil {
-		return fmt.Errorf("upload template: put s3 object: %w", err)
+		return fmt.Errorf("create template version: %w", err)
 	}
 
-	err = s.db.UpdateTemplate(template)
+	err = s.db.SetTemplatePartials(template.UserID, template.ID, partials)
 	if err != nil {
-		return fmt.Errorf("update template: %w", err)
+		return fmt.Errorf("set template partials: %w", err)
 	}
 
 	return nil
@@ -133,14 +279,35 @@ func (s service) GetTemplates(c context.Context, userID int64, p *storage.Pagina
 	return s.db.GetTemplates(userID, p, scopeMap)
 }
 
-// DeleteTemplate deletes the given template
+// DeleteTemplate deletes the given template with the html parts of all its versions
 func (s *service) DeleteTemplate(c context.Context, templateID, userID int64) error {
-	_, err := s.s3.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: aws.String(s.templatesBucket),
-		Key:    aws.String(templateKey(userID, templateID)),
-	})
+	versions, err := s.db.GetTemplateVersions(templateID, userID)
 	if err != nil {
-		return fmt.Errorf("delete object: %w", err)
+		return fmt.Errorf("get template versions: %w", err)
+	}
+
+	var (
+		keys []string
+		seen = make(map[string]bool)
+	)
+	for _, v := range versions {
+		if !seen[v.HTMLKey] {
+			seen[v.HTMLKey] = true
+			keys = append(keys, v.HTMLKey)
+		}
+	}
+	if len(keys) == 0 {
+		keys = append(keys, templateKey(userID, templateID))
+	}
+
+	for _, key := range keys {
+		_, err = s.s3.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(s.templatesBucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return fmt.Errorf("delete object: %w", err)
+		}
 	}
 
 	err = s.db.DeleteTemplate(templateID, userID)
@@ -152,28 +319,63 @@ func (s *service) DeleteTemplate(c context.Context, templateID, userID int64) er
 }
 
 // GetTemplate returns the template with given template id and user id
-func (s service) GetTemplate(c context.Context, templateID int64, userID int64) (template *entities.Template, err error) {
+func (s service) GetTemplate(c context.Context, templateID int64, userID int64) (*entities.Template, error) {
+	return s.getTemplate(templateID, userID, false)
+}
+
+// getTemplate returns the template with its html part, the processed html part is fetched as well
+// when the template is parsed.
+func (s service) getTemplate(templateID int64, userID int64, processed bool) (template *entities.Template, err error) {
 	template, err = s.db.GetTemplate(templateID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("get template: %w", err)
 	}
 
+	// the templates which were created before versioning are kept under the key without a version
+	key := templateKey(template.UserID, template.ID)
+	if template.Version > 0 {
+		v, err := s.db.GetTemplateVersion(template.ID, template.Version, userID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("get template version: %w", err)
+		}
+		if err == nil {
+			key = v.HTMLKey
+			if processed && v.ProcessedHTMLKey != "" {
+				template.ProcessedHTMLPart, err = s.getHTMLPart(v.ProcessedHTMLKey)
+				if err != nil {
+					return nil, err
+				}
+				template.ProcessedTextPart = v.ProcessedTextPart
+			}
+		}
+	}
+
+	template.HTMLPart, err = s.getHTMLPart(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// getHTMLPart returns the html part stored under the given key.
+func (s service) getHTMLPart(key string) (html string, err error) {
 	resp, err := s.s3.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(s.templatesBucket),
-		Key:    aws.String(templateKey(template.UserID, template.ID)),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case s3.ErrCodeNoSuchKey:
-				return nil, ErrHTMLPartNotFound
+				return "", ErrHTMLPartNotFound
 			case s3.ErrCodeInvalidObjectState:
-				return nil, ErrHTMLPartInvalidState
+				return "", ErrHTMLPartInvalidState
 			default:
-				return nil, fmt.Errorf("get object: %w", aerr)
+				return "", fmt.Errorf("get object: %w", aerr)
 			}
 		}
-		return nil, fmt.Errorf("get object: %w", err)
+		return "", fmt.Errorf("get object: %w", err)
 	}
 
 	defer func() {
@@ -184,31 +386,80 @@ func (s service) GetTemplate(c context.Context, templateID int64, userID int64)
 
 	htmlBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read: %w", err)
+		return "", fmt.Errorf("read: %w", err)
 	}
 
-	template.HTMLPart = string(htmlBytes)
-
-	return
+	return string(htmlBytes), nil
 }
 
 func (s *service) ParseTemplate(c context.Context, templateID int64, userID int64) (*entities.CampaignTemplateData, error) {
-	template, err := s.GetTemplate(c, templateID, userID)
+	template, err := s.getTemplate(templateID, userID, true)
 	if err != nil {
 		return nil, fmt.Errorf("campaign service: get template: %w", err)
 	}
 
-	html, err := mustache.ParseString(template.HTMLPart)
+	return s.parse(template)
+}
+
+// ParseTemplateVersion parses the template as it was at the given version.
+func (s *service) ParseTemplateVersion(c context.Context, templateID, version, userID int64) (*entities.CampaignTemplateData, error) {
+	template, err := s.db.GetTemplate(templateID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("campaign service: parse html part: %w", err)
+		return nil, fmt.Errorf("campaign service: get template: %w", err)
 	}
-	text, err := mustache.ParseString(template.TextPart)
+
+	v, err := s.GetTemplateVersion(c, templateID, version, userID)
 	if err != nil {
-		return nil, fmt.Errorf("campaign service: parse text part: %w", err)
+		return nil, fmt.Errorf("campaign service: get template version: %w", err)
 	}
-	sub, err := mustache.ParseString(template.SubjectPart)
+	if v.ProcessedHTMLKey != "" {
+		v.ProcessedHTMLPart, err = s.getHTMLPart(v.ProcessedHTMLKey)
+		if err != nil {
+			return nil, fmt.Errorf("campaign service: get processed html part: %w", err)
+		}
+	}
+
+	return s.parse(v.Template(template.Name))
+}
+
+// parse parses the parts of the template, the partials are resolved from the partials of the user
+// at the time of parsing, so changes of a shared partial are applied to every template on the next send.
+// The html part which was processed when the template was saved is used, unless the partials were changed since.
+func (s *service) parse(template *entities.Template) (*entities.CampaignTemplateData, error) {
+	partials, err := s.db.GetPartials(template.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: get partials: %w", err)
+	}
+
+	htmlPart, set, err := expandLayout(template, partials)
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: %w", err)
+	}
+
+	textPart := template.TextPart
+	if template.HTMLPipeline.Enabled() {
+		if template.ProcessedHTMLPart != "" && !partialsChangedSince(partials, template.UpdatedAt) {
+			htmlPart, textPart = template.ProcessedHTMLPart, template.ProcessedTextPart
+		} else {
+			// the versions which were saved before the processed html part was stored
+			htmlPart, textPart, err = runPipeline(template.HTMLPipeline, htmlPart, textPart, set)
+			if err != nil {
+				return nil, fmt.Errorf("campaign service: %w", err)
+			}
+		}
+	}
+
+	html, err := mustache.ParseStringPartials(htmlPart, set)
 	if err != nil {
-		return nil, fmt.Errorf("campaign service: parse subject part: %w", err)
+		return nil, fmt.Errorf("campaign service: %w: %s", ErrParseHTMLPart, err)
+	}
+	text, err := mustache.ParseStringPartials(textPart, set)
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: %w: %s", ErrParseTextPart, err)
+	}
+	sub, err := mustache.ParseStringPartials(template.SubjectPart, set)
+	if err != nil {
+		return nil, fmt.Errorf("campaign service: %w: %s", ErrParseSubjectPart, err)
 	}
 	return &entities.CampaignTemplateData{
 		Template:    template,
@@ -218,7 +469,154 @@ func (s *service) ParseTemplate(c context.Context, templateID int64, userID int6
 	}, nil
 }
 
+// expandLayout returns the html part of the template wrapped in its layout and the partials which the
+// template is parsed with.
+func expandLayout(template *entities.Template, partials []entities.Partial) (string, entities.PartialSet, error) {
+	set := entities.NewPartialSet(partials)
+	err := set.Check()
+	if err != nil {
+		return "", nil, fmt.Errorf("check partials: %w: %s", ErrParseHTMLPart, err)
+	}
+	template.Partials = set
+
+	if template.LayoutID == nil {
+		return template.HTMLPart, set, nil
+	}
+
+	for i := range partials {
+		if partials[i].ID == *template.LayoutID && partials[i].Layout {
+			template.Layout = &partials[i]
+			break
+		}
+	}
+	if template.Layout == nil {
+		return "", nil, ErrLayoutNotFound
+	}
+	set[entities.PartialBody] = template.HTMLPart
+	return template.Layout.Content, set, nil
+}
+
+// runPipeline processes the html part with the partials expanded, the text part is generated from the
+// processed html when it's enabled and the text part is empty.
+func runPipeline(p entities.HTMLPipeline, htmlPart, textPart string, set entities.PartialSet) (string, string, error) {
+	html, err := processHTML(expandPartials(htmlPart, set), p)
+	if err != nil {
+		return "", "", fmt.Errorf("process html part: %w: %s", ErrParseHTMLPart, err)
+	}
+	if p.GenerateText && strings.TrimSpace(textPart) == "" {
+		textPart = generateText(html)
+	}
+	return html, textPart, nil
+}
+
+// partialsChangedSince reports whether any of the partials were changed after the given time.
+func partialsChangedSince(partials []entities.Partial, t time.Time) bool {
+	for _, p := range partials {
+		if p.UpdatedAt.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTemplateVersions returns the versions of the template without their html parts, the latest version first.
+func (s *service) GetTemplateVersions(c context.Context, templateID, userID int64) ([]entities.TemplateVersion, error) {
+	_, err := s.db.GetTemplate(templateID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get template: %w", err)
+	}
+
+	versions, err := s.db.GetTemplateVersions(templateID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get template versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// GetTemplateVersion returns the version of the template with its html part.
+func (s *service) GetTemplateVersion(c context.Context, templateID, version, userID int64) (*entities.TemplateVersion, error) {
+	v, err := s.db.GetTemplateVersion(templateID, version, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get template version: %w", err)
+	}
+
+	v.HTMLPart, err = s.getHTMLPart(v.HTMLKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// DiffTemplateVersions returns the differences between the two versions of the template.
+func (s *service) DiffTemplateVersions(c context.Context, templateID, from, to, userID int64) (*entities.TemplateDiff, error) {
+	a, err := s.GetTemplateVersion(c, templateID, from, userID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.GetTemplateVersion(c, templateID, to, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return entities.DiffTemplateVersions(a, b)
+}
+
+// RollbackTemplate restores the template to the given version. The history is kept intact, the
+// restored content is saved as a new version which shares the html part with the old one.
+func (s *service) RollbackTemplate(c context.Context, templateID, version, userID int64) (*entities.Template, error) {
+	template, err := s.db.GetTemplate(templateID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get template: %w", err)
+	}
+
+	v, err := s.GetTemplateVersion(c, templateID, version, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	template.SubjectPart = v.SubjectPart
+	template.TextPart = v.TextPart
+	template.HTMLPart = v.HTMLPart
+	template.LayoutID = v.LayoutID
+	template.HTMLPipeline = v.HTMLPipeline
+
+	names, err := s.checkPartials(template)
+	if err != nil {
+		return nil, err
+	}
+
+	// the processed html part is not shared, since the partials might have changed since
+	err = s.process(template)
+	if err != nil {
+		return nil, err
+	}
+
+	nv, err := s.newVersion(template, v.HTMLKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.CreateTemplateVersion(template, nv)
+	if err != nil {
+		return nil, fmt.Errorf("create template version: %w", err)
+	}
+
+	err = s.db.SetTemplatePartials(template.UserID, template.ID, names)
+	if err != nil {
+		return nil, fmt.Errorf("set template partials: %w", err)
+	}
+
+	return template, nil
+}
+
 // templateKey generates template key
 func templateKey(userID, templateID int64) string {
 	return fmt.Sprintf("templates/%d/%d", userID, templateID)
 }
+
+// versionKey generates a unique key for the html part of a new template version
+func versionKey(userID, templateID int64) string {
+	return fmt.Sprintf("templates/%d/%d/%s", userID, templateID, ksuid.New().String())
+}
@@ -0,0 +1,197 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/mailbadger/app/logger"
+	"github.com/mailbadger/app/storage"
+	"github.com/mailbadger/app/utils"
+)
+
+const (
+	// MaxAttempts is the number of attempts after which the delivery is marked as failed.
+	MaxAttempts = 8
+	// baseBackoff is the delay before the first retry, it doubles with each attempt.
+	baseBackoff = 30 * time.Second
+
+	requestTimeout  = 10 * time.Second
+	maxResponseBody = 1024
+	batchSize       = 100
+	// claimTimeout is the time after which a claimed delivery is attempted again,
+	// in case the worker which claimed it stopped before logging the attempt.
+	claimTimeout = 5 * time.Minute
+)
+
+// Service describes the webhooks service which delivers the events to the webhooks of the users.
+type Service interface {
+	Deliver(ctx context.Context, d *entities.WebhookDelivery) (*entities.WebhookDeliveryAttempt, error)
+	Start(ctx context.Context, d time.Duration) error
+}
+
+type service struct {
+	store  storage.Storage
+	client *http.Client
+}
+
+// From returns a new webhooks service which posts only to public addresses and doesn't follow redirects,
+// since the urls are set by the users.
+func From(store storage.Storage) Service {
+	return New(store, utils.NewPublicHTTPClient(requestTimeout, 0))
+}
+
+// New returns a new webhooks service with the given http client.
+func New(store storage.Storage, client *http.Client) Service {
+	return &service{
+		store:  store,
+		client: client,
+	}
+}
+
+// Start runs the delivery worker, on each tick it attempts the pending deliveries which are due.
+func (svc *service) Start(ctx context.Context, d time.Duration) error {
+	logger.From(ctx).Debug("webhooks: starting delivery worker")
+
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err := svc.deliverDue(ctx)
+			if err != nil {
+				logger.From(ctx).WithError(err).Error("webhooks: deliver due returned error")
+			}
+		}
+	}
+}
+
+func (svc *service) deliverDue(ctx context.Context) error {
+	deliveries, err := svc.store.GetDueWebhookDeliveries(time.Now().UTC(), batchSize)
+	if err != nil {
+		return fmt.Errorf("webhooks: get due deliveries: %w", err)
+	}
+
+	for i := range deliveries {
+		// the delivery might have been claimed by the worker of another instance in the meantime.
+		ok, err := svc.store.ClaimWebhookDelivery(deliveries[i].ID, time.Now().UTC(), claimTimeout)
+		if err != nil {
+			return fmt.Errorf("webhooks: claim delivery: %w", err)
+		}
+		if !ok {
+			continue
+		}
+
+		_, err = svc.Deliver(ctx, &deliveries[i])
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"delivery_id": deliveries[i].ID,
+				"webhook_id":  deliveries[i].WebhookID,
+			}).WithError(err).Error("webhooks: unable to deliver")
+		}
+	}
+
+	return nil
+}
+
+// Deliver posts the payload of the delivery to the webhook and logs the attempt. When the
+// attempt fails the next one is scheduled with exponential backoff, until the attempts run out.
+// Test events are attempted only once and the deliveries of inactive webhooks are not sent.
+func (svc *service) Deliver(ctx context.Context, d *entities.WebhookDelivery) (*entities.WebhookDeliveryAttempt, error) {
+	w, err := svc.store.GetWebhook(d.WebhookID, d.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: get webhook: %w", err)
+	}
+
+	var a *entities.WebhookDeliveryAttempt
+	if w.Active {
+		a = svc.post(ctx, w, d)
+	} else {
+		a = &entities.WebhookDeliveryAttempt{
+			DeliveryID: d.ID,
+			Error:      "webhook is inactive",
+			CreatedAt:  time.Now().UTC(),
+		}
+	}
+
+	d.Attempts++
+	d.UpdatedAt = time.Now().UTC()
+	switch {
+	case a.StatusCode >= 200 && a.StatusCode < 300:
+		d.Status = entities.WebhookDeliveryStatusDelivered
+		d.NextAttemptAt.Valid = false
+	case d.Attempts >= MaxAttempts || d.Event == entities.WebhookEventTest || !w.Active:
+		d.Status = entities.WebhookDeliveryStatusFailed
+		d.NextAttemptAt.Valid = false
+	default:
+		d.Status = entities.WebhookDeliveryStatusPending
+		d.NextAttemptAt.SetValid(time.Now().UTC().Add(Backoff(d.Attempts)))
+	}
+
+	err = svc.store.LogWebhookDeliveryAttempt(d, a)
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: log attempt: %w", err)
+	}
+
+	return a, nil
+}
+
+func (svc *service) post(ctx context.Context, w *entities.Webhook, d *entities.WebhookDelivery) *entities.WebhookDeliveryAttempt {
+	a := &entities.WebhookDeliveryAttempt{
+		DeliveryID: d.ID,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	sig, err := utils.SignData(string(d.Payload), w.Secret)
+	if err != nil {
+		a.Error = fmt.Sprintf("sign payload: %s", err)
+		return a
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		a.Error = fmt.Sprintf("new request: %s", err)
+		return a
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mailbadger-Webhooks")
+	req.Header.Set("X-Mailbadger-Event", d.Event)
+	req.Header.Set("X-Mailbadger-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set(entities.WebhookSignatureHeader, sig)
+
+	start := time.Now()
+	resp, err := svc.client.Do(req)
+	a.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	defer resp.Body.Close()
+
+	a.StatusCode = resp.StatusCode
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		a.Error = fmt.Sprintf("read response: %s", err)
+	}
+	a.ResponseBody = string(body)
+
+	return a
+}
+
+// Backoff returns the delay before the next attempt, after the given number of attempts.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return baseBackoff
+	}
+	return baseBackoff * time.Duration(1<<uint(attempts-1))
+}
@@ -3,6 +3,7 @@ package session
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -16,6 +17,8 @@ import (
 type Store interface {
 	GetSession(id string) (*entities.Session, error)
 	CreateSession(sess *entities.Session) error
+	UpdateSessionLastActivity(id string, t time.Time) error
+	IncrementTwoFactorAttempts(id string) (int, error)
 	DeleteSession(id string) error
 }
 
@@ -24,27 +27,54 @@ type Session struct {
 	AuthKey     string
 	EncryptKey  string
 	CookieStore cookie.Store
+	// IdleTimeout is the time after which a session without any activity expires, zero disables it.
+	IdleTimeout time.Duration
+	// Lifetime is the time after which a session expires, regardless of its activity.
+	Lifetime time.Duration
 
 	store Store
 }
 
 const (
-	sessKey      = "sess_id"
-	sessDuration = 72 * time.Hour
+	sessKey = "sess_id"
+
+	defaultIdleTimeout = 24 * time.Hour
+	defaultLifetime    = 72 * time.Hour
+
+	// The last activity of a session is tracked with a minute precision to avoid writing on every request.
+	activityPrecision = time.Minute
+
+	// userAgentMaxLength is the length of the user agent column.
+	userAgentMaxLength = 191
+
+	// The pending session is created when the password of a user with two-factor authentication
+	// is verified, the user must enter the code before it expires.
+	pendingSessKey      = "pending_sess_id"
+	pendingSessDuration = 5 * time.Minute
+
+	// MaxTwoFactorAttempts is the number of codes which can be entered in a pending session.
+	MaxTwoFactorAttempts = 5
 )
 
 var (
 	ErrNotFound         = errors.New("session not found")
 	ErrInvalidValueType = errors.New("session has invalid value type")
+	ErrExpired          = errors.New("session expired")
+	ErrTooManyAttempts  = errors.New("session has too many attempts")
 )
 
 func From(store Store, conf config.Config) Session {
-	return New(
+	sess := New(
 		store,
 		conf.Session.AuthKey,
 		conf.Session.EncryptKey,
 		conf.Session.Secure,
 	)
+	sess.IdleTimeout = conf.Session.IdleTimeout
+	if conf.Session.Lifetime > 0 {
+		sess.Lifetime = conf.Session.Lifetime
+	}
+	return sess
 }
 
 func New(store Store, authKey, encryptKey string, secure bool) Session {
@@ -63,9 +93,13 @@ func New(store Store, authKey, encryptKey string, secure bool) Session {
 		Secure:      secure,
 		AuthKey:     authKey,
 		EncryptKey:  encryptKey,
+		IdleTimeout: defaultIdleTimeout,
+		Lifetime:    defaultLifetime,
 	}
 }
 
+// GetUserSession returns the session of the signed in user. The session is deleted and ErrExpired
+// is returned when it exceeds its lifetime or idle timeout, otherwise its last activity is updated.
 func (sess Session) GetUserSession(c *gin.Context) (*entities.Session, error) {
 	defaultsess := sessions.Default(c)
 	v := defaultsess.Get(sessKey)
@@ -77,7 +111,31 @@ func (sess Session) GetUserSession(c *gin.Context) (*entities.Session, error) {
 		return nil, ErrInvalidValueType
 	}
 	s, err := sess.store.GetSession(sessID)
-	return s, err
+	if err != nil {
+		return nil, err
+	}
+	if s.TwoFactorPending {
+		return nil, ErrNotFound
+	}
+
+	now := time.Now()
+	if now.Sub(s.CreatedAt) > sess.Lifetime ||
+		(sess.IdleTimeout > 0 && now.Sub(s.LastActivityAt) > sess.IdleTimeout) {
+		err = sess.store.DeleteSession(s.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("session: delete expired session: %w", err)
+		}
+		return nil, ErrExpired
+	}
+
+	if now.Sub(s.LastActivityAt) > activityPrecision {
+		err = sess.store.UpdateSessionLastActivity(s.SessionID, now)
+		if err != nil {
+			return nil, fmt.Errorf("session: update last activity: %w", err)
+		}
+		s.LastActivityAt = now
+	}
+	return s, nil
 }
 
 func (sess Session) CreateUserSession(c *gin.Context, userID int64) error {
@@ -86,20 +144,26 @@ func (sess Session) CreateUserSession(c *gin.Context, userID int64) error {
 		return fmt.Errorf("session: gen session id: %w", err)
 	}
 
+	ua := c.Request.UserAgent()
+	if len(ua) > userAgentMaxLength {
+		ua = strings.ToValidUTF8(ua[:userAgentMaxLength], "")
+	}
+
 	err = sess.store.CreateSession(&entities.Session{
-		UserID:    userID,
-		SessionID: sessID,
+		UserID:         userID,
+		SessionID:      sessID,
+		IP:             c.ClientIP(),
+		UserAgent:      ua,
+		LastActivityAt: time.Now(),
 	})
 	if err != nil {
 		return fmt.Errorf("session: create session: %w", err)
 	}
 
 	session := sessions.Default(c)
-	exp := time.Now().Add(sessDuration).Unix() - time.Now().Unix()
-
 	session.Options(sessions.Options{
 		HttpOnly: true,
-		MaxAge:   int(exp),
+		MaxAge:   int(sess.Lifetime.Seconds()),
 		Secure:   sess.Secure,
 		Path:     "/api",
 	})
@@ -130,3 +194,109 @@ func (sess Session) DeleteUserSession(c *gin.Context) error {
 	}
 	return nil
 }
+
+// CreatePendingSession creates the short-lived session of the user who has yet to enter the code
+// of the two-factor authentication, the user is not signed in until the session is completed.
+func (sess Session) CreatePendingSession(c *gin.Context, userID int64) error {
+	sessID, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return fmt.Errorf("session: gen session id: %w", err)
+	}
+
+	err = sess.store.CreateSession(&entities.Session{
+		UserID:           userID,
+		SessionID:        sessID,
+		TwoFactorPending: true,
+		LastActivityAt:   time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("session: create pending session: %w", err)
+	}
+
+	session := sessions.Default(c)
+	session.Options(sessions.Options{
+		HttpOnly: true,
+		MaxAge:   int(pendingSessDuration.Seconds()),
+		Secure:   sess.Secure,
+		Path:     "/api",
+	})
+	session.Set(pendingSessKey, sessID)
+
+	err = session.Save()
+	if err != nil {
+		return fmt.Errorf("session: save: %w", err)
+	}
+	return nil
+}
+
+// GetPendingSession returns the pending session of the request, ErrExpired is returned when the user
+// didn't enter the code in time.
+func (sess Session) GetPendingSession(c *gin.Context) (*entities.Session, error) {
+	v := sessions.Default(c).Get(pendingSessKey)
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	sessID, ok := v.(string)
+	if !ok {
+		return nil, ErrInvalidValueType
+	}
+
+	s, err := sess.store.GetSession(sessID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.TwoFactorPending {
+		return nil, ErrNotFound
+	}
+	if time.Since(s.CreatedAt) > pendingSessDuration {
+		return nil, ErrExpired
+	}
+	return s, nil
+}
+
+// AttemptPendingSession counts an attempt to enter the code, it must be called before the code
+// is verified. The session is deleted once the user reaches the maximum number of attempts,
+// so the password must be entered again.
+func (sess Session) AttemptPendingSession(c *gin.Context, s *entities.Session) error {
+	attempts, err := sess.store.IncrementTwoFactorAttempts(s.SessionID)
+	if err != nil {
+		return fmt.Errorf("session: increment two factor attempts: %w", err)
+	}
+	if attempts <= MaxTwoFactorAttempts {
+		return nil
+	}
+
+	err = sess.DeletePendingSession(c, s)
+	if err != nil {
+		return err
+	}
+	return ErrTooManyAttempts
+}
+
+// DeletePendingSession deletes the pending session along with its id in the cookie.
+func (sess Session) DeletePendingSession(c *gin.Context, s *entities.Session) error {
+	err := sess.store.DeleteSession(s.SessionID)
+	if err != nil {
+		return fmt.Errorf("session: delete pending session: %w", err)
+	}
+
+	session := sessions.Default(c)
+	session.Delete(pendingSessKey)
+	err = session.Save()
+	if err != nil {
+		return fmt.Errorf("session: save: %w", err)
+	}
+	return nil
+}
+
+// CompletePendingSession signs in the user of the pending session, once the code is verified.
+func (sess Session) CompletePendingSession(c *gin.Context, s *entities.Session) error {
+	err := sess.store.DeleteSession(s.SessionID)
+	if err != nil {
+		return fmt.Errorf("session: delete pending session: %w", err)
+	}
+
+	sessions.Default(c).Delete(pendingSessKey)
+
+	return sess.CreateUserSession(c, s.UserID)
+}
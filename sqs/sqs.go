@@ -15,6 +15,9 @@ const (
 	CampaignerTopic = "SendCampaign"
 	// SenderTopic is the topic used by the sender consumer.
 	SenderTopic = "SendEmail"
+	// TransactionalTopic is the topic of the transactional emails, consumed by the sender
+	// consumer ahead of the campaign emails.
+	TransactionalTopic = "SendTransactionalEmail"
 )
 
 // QueueURL is a pointer to a URL string, used by the SQS client.
@@ -26,6 +29,9 @@ type CampaignerQueueURL QueueURL
 // CampaignerQueueURL represents the queue url of the SendEmail queue.
 type SendEmailQueueURL QueueURL
 
+// TransactionalQueueURL represents the queue url of the SendTransactionalEmail queue.
+type TransactionalQueueURL QueueURL
+
 // SendReceiveMessageAPI defines the interface for the GetQueueUrl function.
 // We use this interface to test the function using a mocked service.
 type SendReceiveMessageAPI interface {
@@ -63,6 +69,14 @@ func NewClient(cfg aws.Config) *sqs.Client {
 	return sqs.NewFromConfig(cfg)
 }
 
+// NewClientFrom returns a new SQS client, which uses the endpoint from the config if it is set.
+func NewClientFrom(cfg aws.Config, conf config.Config) *sqs.Client {
+	if conf.Queue.Endpoint == "" {
+		return NewClient(cfg)
+	}
+	return sqs.NewFromConfig(cfg, sqs.WithEndpointResolver(sqs.EndpointResolverFromURL(conf.Queue.Endpoint)))
+}
+
 func NewConsumerFrom(
 	conf config.Config,
 	queueURL QueueURL,
@@ -198,3 +212,16 @@ func GetSendEmailQueueURL(ctx context.Context, api SendReceiveMessageAPI) (SendE
 	}
 	return urlResult.QueueUrl, nil
 }
+
+func GetTransactionalQueueURL(ctx context.Context, api SendReceiveMessageAPI) (TransactionalQueueURL, error) {
+	queueStr := TransactionalTopic
+	gQInput := &sqs.GetQueueUrlInput{
+		QueueName: &queueStr,
+	}
+	// Get URL of queue
+	urlResult, err := api.GetQueueUrl(ctx, gQInput)
+	if err != nil {
+		return nil, err
+	}
+	return urlResult.QueueUrl, nil
+}
@@ -0,0 +1,49 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// domainVerificationPrefix prefixes the token in the TXT record which proves the ownership of a domain.
+const domainVerificationPrefix = "mailbadger-verification="
+
+// ErrDomainNotVerified is returned when the domain has no TXT record with the verification token.
+var ErrDomainNotVerified = errors.New("sso: the domain has no verification record")
+
+// LookupTXT returns the TXT records of the domain, it is replaced in the tests.
+var LookupTXT = net.DefaultResolver.LookupTXT
+
+// DomainVerificationRecord returns the value of the TXT record which is added to the domain
+// to verify its ownership.
+func DomainVerificationRecord(token string) string {
+	return domainVerificationPrefix + token
+}
+
+// VerifyDomain checks that the domain has a TXT record with the verification token.
+func VerifyDomain(ctx context.Context, domain, token string) error {
+	if token == "" {
+		return ErrDomainNotVerified
+	}
+
+	records, err := LookupTXT(ctx, domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return ErrDomainNotVerified
+		}
+		return fmt.Errorf("sso: lookup txt records: %w", err)
+	}
+
+	want := DomainVerificationRecord(token)
+	for _, r := range records {
+		if strings.TrimSpace(r) == want {
+			return nil
+		}
+	}
+
+	return ErrDomainNotVerified
+}
@@ -0,0 +1,38 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyDomain(t *testing.T) {
+	lookup := LookupTXT
+	defer func() { LookupTXT = lookup }()
+
+	LookupTXT = func(_ context.Context, domain string) ([]string, error) {
+		switch domain {
+		case "acme.com":
+			return []string{"v=spf1 -all", " mailbadger-verification=token "}, nil
+		case "timeout.com":
+			return nil, &net.DNSError{Err: "i/o timeout", Name: domain, IsTimeout: true}
+		default:
+			return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+		}
+	}
+
+	ctx := context.Background()
+
+	assert.Equal(t, "mailbadger-verification=token", DomainVerificationRecord("token"))
+	assert.Nil(t, VerifyDomain(ctx, "acme.com", "token"))
+	assert.True(t, errors.Is(VerifyDomain(ctx, "acme.com", "other"), ErrDomainNotVerified))
+	assert.True(t, errors.Is(VerifyDomain(ctx, "acme.com", ""), ErrDomainNotVerified))
+	assert.True(t, errors.Is(VerifyDomain(ctx, "example.com", "token"), ErrDomainNotVerified))
+
+	err := VerifyDomain(ctx, "timeout.com", "token")
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrDomainNotVerified))
+}
@@ -0,0 +1,383 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// wellKnownPath is appended to the issuer to discover the configuration of the provider.
+const wellKnownPath = "/.well-known/openid-configuration"
+
+const (
+	// jwksTTL is the time for which the keys of a provider are cached.
+	jwksTTL = time.Hour
+	// jwksRefreshInterval limits how often the cached keys are fetched again when none of them
+	// verifies the id token, which happens when the provider rotates its keys.
+	jwksRefreshInterval = time.Minute
+)
+
+// OIDCProvider holds the endpoints of the OpenID Connect provider, they are imported from its discovery document.
+type OIDCProvider struct {
+	Issuer           string `json:"issuer"`
+	AuthorizationURL string `json:"authorization_endpoint"`
+	TokenURL         string `json:"token_endpoint"`
+	JWKSURL          string `json:"jwks_uri"`
+}
+
+// OIDCClient authenticates the users with the authorization code flow of the provider.
+type OIDCClient struct {
+	Provider     OIDCProvider
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Claims are the claims of a verified id token.
+type Claims map[string]interface{}
+
+// DiscoverOIDC fetches the discovery document of the provider. The url is either the issuer
+// or the full url of the discovery document.
+func DiscoverOIDC(ctx context.Context, discoveryURL string) (*OIDCProvider, error) {
+	if !strings.Contains(discoveryURL, "/.well-known/") {
+		discoveryURL = strings.TrimSuffix(discoveryURL, "/") + wellKnownPath
+	}
+
+	data, err := fetch(ctx, discoveryURL)
+	if err != nil {
+		return nil, fmt.Errorf("sso: fetch discovery document: %w", err)
+	}
+
+	p := &OIDCProvider{}
+	err = json.Unmarshal(data, p)
+	if err != nil {
+		return nil, fmt.Errorf("sso: decode discovery document: %w", err)
+	}
+
+	if p.Issuer == "" || p.AuthorizationURL == "" || p.TokenURL == "" || p.JWKSURL == "" {
+		return nil, errors.New("sso: the discovery document is incomplete")
+	}
+
+	return p, nil
+}
+
+func (c OIDCClient) config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  c.Provider.AuthorizationURL,
+			TokenURL: c.Provider.TokenURL,
+		},
+	}
+}
+
+// AuthCodeURL returns the url of the authorization page of the provider. The nonce is verified
+// with the id token and the verifier is used for the proof key for code exchange.
+func (c OIDCClient) AuthCodeURL(state, nonce, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	return c.config().AuthCodeURL(
+		state,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+}
+
+// Exchange exchanges the authorization code for the tokens and returns the raw id token.
+func (c OIDCClient) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+	tok, err := c.config().Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		return "", fmt.Errorf("sso: exchange code: %w", err)
+	}
+
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return "", errors.New("sso: the token response has no id token")
+	}
+
+	return raw, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type cachedJWKS struct {
+	set       jwks
+	fetchedAt time.Time
+}
+
+// jwksCache holds the keys of the providers by their jwks url.
+type jwksCache struct {
+	mu   sync.Mutex
+	sets map[string]cachedJWKS
+}
+
+var keySets = &jwksCache{sets: make(map[string]cachedJWKS)}
+
+// get returns the cached keys of the url, they are fetched when they are missing, expired or when
+// refresh is set and they were fetched more than the refresh interval ago.
+func (c *jwksCache) get(ctx context.Context, url string, refresh bool, now time.Time) (jwks, bool, error) {
+	c.mu.Lock()
+	cached, ok := c.sets[url]
+	c.mu.Unlock()
+
+	age := now.Sub(cached.fetchedAt)
+	if ok && age < jwksTTL && (!refresh || age < jwksRefreshInterval) {
+		return cached.set, false, nil
+	}
+
+	data, err := fetch(ctx, url)
+	if err != nil {
+		return jwks{}, false, fmt.Errorf("sso: fetch jwks: %w", err)
+	}
+	var set jwks
+	err = json.Unmarshal(data, &set)
+	if err != nil {
+		return jwks{}, false, fmt.Errorf("sso: decode jwks: %w", err)
+	}
+
+	c.mu.Lock()
+	c.sets[url] = cachedJWKS{set: set, fetchedAt: now}
+	c.mu.Unlock()
+
+	return set, true, nil
+}
+
+// VerifyIDToken verifies the signature of the id token with the keys of the provider and validates
+// its issuer, audience, expiry and nonce.
+func (c OIDCClient) VerifyIDToken(ctx context.Context, raw, nonce string, now time.Time) (Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("sso: malformed id token")
+	}
+
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("sso: decode id token header: %w", err)
+	}
+
+	var (
+		h   crypto.Hash
+		kty string
+		pss bool
+	)
+	switch header.Alg {
+	case "RS256":
+		h, kty = crypto.SHA256, "RSA"
+	case "RS384":
+		h, kty = crypto.SHA384, "RSA"
+	case "RS512":
+		h, kty = crypto.SHA512, "RSA"
+	case "PS256":
+		h, kty, pss = crypto.SHA256, "RSA", true
+	case "ES256":
+		h, kty = crypto.SHA256, "EC"
+	case "ES384":
+		h, kty = crypto.SHA384, "EC"
+	default:
+		return nil, fmt.Errorf("sso: unsupported id token algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("sso: decode id token signature: %w", err)
+	}
+
+	hasher := h.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	hashed := hasher.Sum(nil)
+
+	set, fetched, err := keySets.get(ctx, c.Provider.JWKSURL, false, now)
+	if err != nil {
+		return nil, err
+	}
+	verified := verifyWithKeys(set, header.Kid, kty, pss, h, hashed, signature)
+	if !verified && !fetched {
+		set, _, err = keySets.get(ctx, c.Provider.JWKSURL, true, now)
+		if err != nil {
+			return nil, err
+		}
+		verified = verifyWithKeys(set, header.Kid, kty, pss, h, hashed, signature)
+	}
+	if !verified {
+		return nil, errors.New("sso: invalid id token signature")
+	}
+
+	claims := Claims{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("sso: decode id token claims: %w", err)
+	}
+
+	if claims.String("iss") != c.Provider.Issuer {
+		return nil, errors.New("sso: the id token is issued by another provider")
+	}
+
+	aud := claims.Values("aud")
+	found := false
+	for _, a := range aud {
+		if a == c.ClientID {
+			found = true
+		}
+	}
+	if !found || (len(aud) > 1 && claims.String("azp") != c.ClientID) {
+		return nil, errors.New("sso: the id token is for another client")
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || !now.Add(-clockSkew).Before(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("sso: the id token has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("sso: the id token is not valid yet")
+	}
+
+	if claims.String("nonce") != nonce {
+		return nil, errors.New("sso: invalid id token nonce")
+	}
+
+	return claims, nil
+}
+
+// String returns the claim as a string.
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Values returns the claim which is either a string or a list of strings.
+func (c Claims) Values(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// EmailVerified reports whether the email of the user is verified by the provider, the email
+// is not verified when the claim is missing.
+func (c Claims) EmailVerified() bool {
+	switch v := c["email_verified"].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// verifyWithKeys verifies the signature with the keys of the set which match the key id and the key type.
+func verifyWithKeys(set jwks, kid, kty string, pss bool, h crypto.Hash, hashed, signature []byte) bool {
+	for _, k := range set.Keys {
+		if k.Kty != kty || (kid != "" && k.Kid != "" && k.Kid != kid) {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		if rsaKey, ok := key.(*rsa.PublicKey); ok && pss {
+			if rsa.VerifyPSS(rsaKey, h, hashed, signature, nil) == nil {
+				return true
+			}
+		} else if verifyWithKey(key, h, hashed, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,187 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hashed := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		srv      *httptest.Server
+		idToken  string
+		jwksHits int
+	)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 srv.URL,
+				"authorization_endpoint": srv.URL + "/authorize",
+				"token_endpoint":         srv.URL + "/token",
+				"jwks_uri":               srv.URL + "/jwks",
+			})
+		case "/jwks":
+			jwksHits++
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"keys": []map[string]string{
+					{
+						"kty": "RSA",
+						"kid": "1",
+						"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+						"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+					},
+				},
+			})
+		case "/token":
+			_ = r.ParseForm()
+			if r.Form.Get("code") != "foo" || r.Form.Get("code_verifier") != "verifier" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"access_token": "bar",
+				"token_type":   "Bearer",
+				"id_token":     idToken,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	p, err := DiscoverOIDC(ctx, srv.URL+"/")
+	assert.Nil(t, err)
+	assert.Equal(t, srv.URL+"/token", p.TokenURL)
+
+	_, err = DiscoverOIDC(ctx, srv.URL+"/foo/.well-known/openid-configuration")
+	assert.NotNil(t, err)
+
+	client := OIDCClient{
+		Provider:     *p,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://app.example.com/callback",
+	}
+
+	u, err := url.Parse(client.AuthCodeURL("state", "nonce", "verifier"))
+	assert.Nil(t, err)
+	assert.Equal(t, "state", u.Query().Get("state"))
+	assert.Equal(t, "nonce", u.Query().Get("nonce"))
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Contains(t, u.Query().Get("scope"), "openid")
+
+	now := time.Now()
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":            srv.URL,
+			"aud":            "client",
+			"sub":            "123",
+			"email":          "jane@acme.com",
+			"email_verified": true,
+			"groups":         []string{"marketing"},
+			"nonce":          "nonce",
+			"exp":            now.Add(time.Hour).Unix(),
+		}
+	}
+
+	idToken = signJWT(t, key, "1", claims())
+	raw, err := client.Exchange(ctx, "foo", "verifier")
+	assert.Nil(t, err)
+	assert.Equal(t, idToken, raw)
+
+	_, err = client.Exchange(ctx, "foo", "bar")
+	assert.NotNil(t, err)
+
+	c, err := client.VerifyIDToken(ctx, raw, "nonce", now)
+	assert.Nil(t, err)
+	assert.Equal(t, "jane@acme.com", c.String("email"))
+	assert.Equal(t, []string{"marketing"}, c.Values("groups"))
+	assert.True(t, c.EmailVerified())
+
+	_, err = client.VerifyIDToken(ctx, raw, "other", now)
+	assert.NotNil(t, err)
+
+	// the keys are cached
+	_, err = client.VerifyIDToken(ctx, raw, "nonce", now.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, jwksHits)
+
+	// the email is not verified when the claim is missing
+	cl := claims()
+	delete(cl, "email_verified")
+	c, err = client.VerifyIDToken(ctx, signJWT(t, key, "1", cl), "nonce", now)
+	assert.Nil(t, err)
+	assert.False(t, c.EmailVerified())
+
+	_, err = client.VerifyIDToken(ctx, raw, "nonce", now.Add(2*time.Hour))
+	assert.NotNil(t, err)
+
+	cl = claims()
+	cl["aud"] = []string{"client", "other"}
+	_, err = client.VerifyIDToken(ctx, signJWT(t, key, "1", cl), "nonce", now)
+	assert.NotNil(t, err)
+
+	cl["azp"] = "client"
+	_, err = client.VerifyIDToken(ctx, signJWT(t, key, "1", cl), "nonce", now)
+	assert.Nil(t, err)
+
+	cl = claims()
+	cl["iss"] = "https://evil.example.com"
+	_, err = client.VerifyIDToken(ctx, signJWT(t, key, "1", cl), "nonce", now)
+	assert.NotNil(t, err)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.VerifyIDToken(ctx, signJWT(t, other, "1", claims()), "nonce", now)
+	assert.NotNil(t, err)
+
+	// the unsigned tokens are rejected
+	parts := strings.Split(raw, ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+	_, err = client.VerifyIDToken(ctx, none, "nonce", now)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,497 @@
+package sso
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mailbadger/app/utils"
+)
+
+// SAML namespaces, bindings and values.
+const (
+	nsSAMLProtocol      = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsSAMLAssertion     = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsSAMLMetadata      = "urn:oasis:names:tc:SAML:2.0:metadata"
+	samlBindingRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	samlBindingPOST     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	samlStatusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlNameIDEmail     = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	samlMethodBearer    = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// clockSkew is the tolerated difference between the clocks of the identity provider and ours.
+const clockSkew = 3 * time.Minute
+
+// samlRequestTTL is the time in which the identity provider has to respond to the authentication request.
+const samlRequestTTL = 10 * time.Minute
+
+// SAMLIdentityProvider holds the settings of the identity provider, they are imported from its metadata.
+type SAMLIdentityProvider struct {
+	EntityID string
+	SSOURL   string
+	// Certificate holds the PEM encoded certificates which sign the responses.
+	Certificate string
+}
+
+// SAMLServiceProvider initiates the login with the identity provider and validates its responses.
+// The id of the authentication request is kept in the session of the user, so the response
+// is accepted only in the session which made the request.
+type SAMLServiceProvider struct {
+	EntityID string
+	ACSURL   string
+	IdP      SAMLIdentityProvider
+}
+
+// SAMLAssertion holds the subject and the attributes of a validated assertion.
+type SAMLAssertion struct {
+	NameID     string
+	Attributes map[string][]string
+}
+
+type samlEntityDescriptor struct {
+	XMLName           xml.Name
+	EntityID          string `xml:"entityID,attr"`
+	IDPSSODescriptors []struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+	EntityDescriptors []samlEntityDescriptor `xml:"EntityDescriptor"`
+}
+
+// FetchSAMLMetadata fetches and parses the metadata of the identity provider from the url.
+func FetchSAMLMetadata(ctx context.Context, metadataURL string) (*SAMLIdentityProvider, error) {
+	data, err := fetch(ctx, metadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("sso: fetch saml metadata: %w", err)
+	}
+	return ParseSAMLMetadata(data)
+}
+
+// ParseSAMLMetadata parses the entity id, the single sign-on url with the HTTP-Redirect binding and
+// the signing certificates from the metadata of the identity provider.
+func ParseSAMLMetadata(data []byte) (*SAMLIdentityProvider, error) {
+	var ed samlEntityDescriptor
+	err := xml.Unmarshal(data, &ed)
+	if err != nil {
+		return nil, fmt.Errorf("sso: parse saml metadata: %w", err)
+	}
+
+	if ed.XMLName.Local == "EntitiesDescriptor" {
+		for _, d := range ed.EntityDescriptors {
+			if len(d.IDPSSODescriptors) > 0 {
+				ed = d
+				break
+			}
+		}
+	}
+	if ed.EntityID == "" || len(ed.IDPSSODescriptors) == 0 {
+		return nil, errors.New("sso: the metadata doesn't describe an identity provider")
+	}
+
+	idp := &SAMLIdentityProvider{
+		EntityID: ed.EntityID,
+	}
+
+	var certs []string
+	for _, d := range ed.IDPSSODescriptors {
+		for _, s := range d.SingleSignOnServices {
+			if s.Binding == samlBindingRedirect && idp.SSOURL == "" {
+				idp.SSOURL = s.Location
+			}
+		}
+		for _, k := range d.KeyDescriptors {
+			if k.Use != "" && k.Use != "signing" {
+				continue
+			}
+			for _, c := range k.Certificates {
+				der, err := decodeBase64(c)
+				if err != nil {
+					return nil, fmt.Errorf("sso: decode certificate: %w", err)
+				}
+				if _, err := x509.ParseCertificate(der); err != nil {
+					return nil, fmt.Errorf("sso: parse certificate: %w", err)
+				}
+				certs = append(certs, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+			}
+		}
+	}
+
+	if idp.SSOURL == "" {
+		return nil, errors.New("sso: the identity provider doesn't support the HTTP-Redirect binding")
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("sso: the metadata has no signing certificates")
+	}
+	idp.Certificate = strings.Join(certs, "")
+
+	return idp, nil
+}
+
+// ParseCertificates parses the PEM encoded certificates.
+func ParseCertificates(data string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("sso: parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("sso: no certificates found")
+	}
+	return certs, nil
+}
+
+// Metadata returns the metadata of the service provider which is imported in the identity provider.
+func (sp SAMLServiceProvider) Metadata() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&b, `<md:EntityDescriptor xmlns:md="%s" entityID="%s">`, nsSAMLMetadata, escapeXML(sp.EntityID))
+	fmt.Fprintf(&b, `<md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="%s">`, nsSAMLProtocol)
+	fmt.Fprintf(&b, `<md:NameIDFormat>%s</md:NameIDFormat>`, samlNameIDEmail)
+	fmt.Fprintf(&b, `<md:AssertionConsumerService Binding="%s" Location="%s" index="0" isDefault="true"/>`, samlBindingPOST, escapeXML(sp.ACSURL))
+	b.WriteString(`</md:SPSSODescriptor></md:EntityDescriptor>`)
+	return b.Bytes()
+}
+
+// AuthnRequestURL returns the url of the identity provider with the authentication request,
+// using the HTTP-Redirect binding, along with the id of the request which must be kept
+// until the response is parsed.
+func (sp SAMLServiceProvider) AuthnRequestURL(now time.Time) (string, string, error) {
+	id, err := newRequestID(now)
+	if err != nil {
+		return "", "", err
+	}
+
+	var req bytes.Buffer
+	fmt.Fprintf(&req,
+		`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" ProtocolBinding="%s" AssertionConsumerServiceURL="%s">`,
+		nsSAMLProtocol,
+		nsSAMLAssertion,
+		id,
+		now.UTC().Format(time.RFC3339),
+		escapeXML(sp.IdP.SSOURL),
+		samlBindingPOST,
+		escapeXML(sp.ACSURL),
+	)
+	fmt.Fprintf(&req, `<saml:Issuer>%s</saml:Issuer>`, escapeXML(sp.EntityID))
+	fmt.Fprintf(&req, `<samlp:NameIDPolicy Format="%s" AllowCreate="true"/>`, samlNameIDEmail)
+	req.WriteString(`</samlp:AuthnRequest>`)
+
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		return "", "", fmt.Errorf("sso: deflate authn request: %w", err)
+	}
+	_, err = w.Write(req.Bytes())
+	if err != nil {
+		return "", "", fmt.Errorf("sso: deflate authn request: %w", err)
+	}
+	err = w.Close()
+	if err != nil {
+		return "", "", fmt.Errorf("sso: deflate authn request: %w", err)
+	}
+
+	u, err := url.Parse(sp.IdP.SSOURL)
+	if err != nil {
+		return "", "", fmt.Errorf("sso: parse sso url: %w", err)
+	}
+	q := u.Query()
+	q.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	u.RawQuery = q.Encode()
+
+	return u.String(), id, nil
+}
+
+// newRequestID returns a random id of the authentication request which expires after the request ttl.
+func newRequestID(now time.Time) (string, error) {
+	b, err := utils.GenerateRandomBytes(16)
+	if err != nil {
+		return "", fmt.Errorf("sso: generate request id: %w", err)
+	}
+
+	return fmt.Sprintf("_%s.%d", hex.EncodeToString(b), now.Add(samlRequestTTL).Unix()), nil
+}
+
+// verifyRequestID checks that the response is for the unexpired request which was made in the session.
+func verifyRequestID(id, requestID string, now time.Time) error {
+	if requestID == "" || id != requestID {
+		return errors.New("sso: the response is not for our request")
+	}
+
+	i := strings.LastIndex(id, ".")
+	if i < 0 {
+		return errors.New("sso: invalid request id")
+	}
+	exp, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil || now.After(time.Unix(exp, 0)) {
+		return errors.New("sso: the request has expired")
+	}
+
+	return nil
+}
+
+// ParseResponse validates the base64 encoded response of the identity provider to the request with
+// the given id and returns the assertion. Either the response or the assertion must be signed by
+// the identity provider, the encrypted assertions and the unsolicited responses are not supported.
+// Each assertion is accepted only once.
+func (sp SAMLServiceProvider) ParseResponse(encoded, requestID string, now time.Time) (*SAMLAssertion, error) {
+	certs, err := ParseCertificates(sp.IdP.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("sso: decode response: %w", err)
+	}
+
+	res, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if res.local != "Response" || res.space() != nsSAMLProtocol {
+		return nil, errors.New("sso: the document is not a saml response")
+	}
+
+	if dest := res.attr("Destination"); dest != "" && dest != sp.ACSURL {
+		return nil, errors.New("sso: the response is for another destination")
+	}
+
+	err = verifyRequestID(res.attr("InResponseTo"), requestID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if issuer := res.child(nsSAMLAssertion, "Issuer"); issuer != nil && issuer.text() != sp.IdP.EntityID {
+		return nil, errors.New("sso: the response is issued by another identity provider")
+	}
+
+	status := res.child(nsSAMLProtocol, "Status")
+	if status == nil {
+		return nil, errors.New("sso: the response has no status")
+	}
+	code := status.child(nsSAMLProtocol, "StatusCode")
+	if code == nil || code.attr("Value") != samlStatusSuccess {
+		var v string
+		if code != nil {
+			v = code.attr("Value")
+		}
+		return nil, fmt.Errorf("sso: the identity provider responded with status %q", v)
+	}
+
+	if res.child(nsSAMLAssertion, "EncryptedAssertion") != nil {
+		return nil, errors.New("sso: encrypted assertions are not supported")
+	}
+	assertions := res.childrenNamed(nsSAMLAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("sso: the response must have exactly one assertion")
+	}
+	a := assertions[0]
+
+	// the assertion is trusted when it's signed, or when it's a part of a signed response
+	signed := false
+	err = verifySignature(res, certs)
+	if err == nil {
+		signed = true
+	} else if !errors.Is(err, errNotSigned) {
+		return nil, err
+	}
+	err = verifySignature(a, certs)
+	if err != nil && (!signed || !errors.Is(err, errNotSigned)) {
+		return nil, err
+	}
+
+	assertion, expiresAt, err := sp.parseAssertion(a, requestID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if !usedAssertions.add(a.attr("ID"), expiresAt, now) {
+		return nil, errors.New("sso: the assertion was already used")
+	}
+
+	return assertion, nil
+}
+
+// parseAssertion validates the assertion and returns it along with the time until which it can be used.
+func (sp SAMLServiceProvider) parseAssertion(a *xmlNode, requestID string, now time.Time) (*SAMLAssertion, time.Time, error) {
+	if a.attr("ID") == "" {
+		return nil, time.Time{}, errors.New("sso: the assertion has no id")
+	}
+
+	issuer := a.child(nsSAMLAssertion, "Issuer")
+	if issuer == nil || issuer.text() != sp.IdP.EntityID {
+		return nil, time.Time{}, errors.New("sso: the assertion is issued by another identity provider")
+	}
+
+	subject := a.child(nsSAMLAssertion, "Subject")
+	if subject == nil {
+		return nil, time.Time{}, errors.New("sso: the assertion has no subject")
+	}
+
+	// the assertion is kept in the cache of the used assertions until its earliest expiry
+	expiresAt := now.Add(samlRequestTTL)
+	confirmed := false
+	for _, sc := range subject.childrenNamed(nsSAMLAssertion, "SubjectConfirmation") {
+		if sc.attr("Method") != samlMethodBearer {
+			continue
+		}
+		data := sc.child(nsSAMLAssertion, "SubjectConfirmationData")
+		if data == nil || data.attr("Recipient") != sp.ACSURL {
+			continue
+		}
+		if id := data.attr("InResponseTo"); id != "" && id != requestID {
+			continue
+		}
+		if err := checkTimeRange(data, now); err != nil {
+			continue
+		}
+		expiresAt = earliestExpiry(data, expiresAt)
+		confirmed = true
+		break
+	}
+	if !confirmed {
+		return nil, time.Time{}, errors.New("sso: the subject of the assertion is not confirmed")
+	}
+
+	conditions := a.child(nsSAMLAssertion, "Conditions")
+	if conditions != nil {
+		if err := checkTimeRange(conditions, now); err != nil {
+			return nil, time.Time{}, err
+		}
+		expiresAt = earliestExpiry(conditions, expiresAt)
+		for _, r := range conditions.childrenNamed(nsSAMLAssertion, "AudienceRestriction") {
+			ok := false
+			for _, aud := range r.childrenNamed(nsSAMLAssertion, "Audience") {
+				if aud.text() == sp.EntityID {
+					ok = true
+				}
+			}
+			if !ok {
+				return nil, time.Time{}, errors.New("sso: the assertion is for another audience")
+			}
+		}
+	}
+
+	assertion := &SAMLAssertion{
+		Attributes: make(map[string][]string),
+	}
+	if nameID := subject.child(nsSAMLAssertion, "NameID"); nameID != nil {
+		assertion.NameID = nameID.text()
+	}
+
+	for _, st := range a.childrenNamed(nsSAMLAssertion, "AttributeStatement") {
+		for _, attr := range st.childrenNamed(nsSAMLAssertion, "Attribute") {
+			var values []string
+			for _, v := range attr.childrenNamed(nsSAMLAssertion, "AttributeValue") {
+				values = append(values, v.text())
+			}
+			for _, name := range []string{attr.attr("Name"), attr.attr("FriendlyName")} {
+				if name != "" {
+					assertion.Attributes[name] = append(assertion.Attributes[name], values...)
+				}
+			}
+		}
+	}
+
+	return assertion, expiresAt, nil
+}
+
+// checkTimeRange checks the NotBefore and NotOnOrAfter attributes of the element.
+func checkTimeRange(el *xmlNode, now time.Time) error {
+	if v := el.attr("NotBefore"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return fmt.Errorf("sso: parse time: %w", err)
+		}
+		if now.Add(clockSkew).Before(t) {
+			return errors.New("sso: the assertion is not valid yet")
+		}
+	}
+	if v := el.attr("NotOnOrAfter"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return fmt.Errorf("sso: parse time: %w", err)
+		}
+		if !now.Add(-clockSkew).Before(t) {
+			return errors.New("sso: the assertion has expired")
+		}
+	}
+	return nil
+}
+
+// earliestExpiry returns the NotOnOrAfter attribute of the element, including the tolerated clock skew,
+// when it's earlier than the given time.
+func earliestExpiry(el *xmlNode, t time.Time) time.Time {
+	v, err := time.Parse(time.RFC3339Nano, el.attr("NotOnOrAfter"))
+	if err != nil {
+		return t
+	}
+	if v = v.Add(clockSkew); v.Before(t) {
+		return v
+	}
+	return t
+}
+
+// assertionCache holds the ids of the used assertions until they expire, so a response
+// can't be replayed.
+type assertionCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+var usedAssertions = &assertionCache{ids: make(map[string]time.Time)}
+
+// add adds the id of the assertion to the cache, it reports false when the assertion was already used.
+func (c *assertionCache) add(id string, expiresAt, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, exp := range c.ids {
+		if now.After(exp) {
+			delete(c.ids, k)
+		}
+	}
+
+	if _, ok := c.ids[id]; ok {
+		return false
+	}
+	c.ids[id] = expiresAt
+	return true
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,320 @@
+package sso
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalize(t *testing.T) {
+	doc := `<root xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b"><a:child b:attr="1" z="2" a="&lt;&quot;" xmlns:c="urn:c">` +
+		`<empty/>text &amp; &gt; more<!-- comment --><c:x xmlns:a="urn:a"/></a:child></root>`
+
+	root, err := parseXML([]byte(doc))
+	assert.Nil(t, err)
+
+	child := root.children[0].node
+	assert.Equal(
+		t,
+		`<a:child xmlns:a="urn:a" xmlns:b="urn:b" a="&lt;&quot;" z="2" b:attr="1">`+
+			`<empty xmlns="urn:d"></empty>text &amp; &gt; more<c:x xmlns:c="urn:c"></c:x></a:child>`,
+		string(canonicalize(child, nil, nil)),
+	)
+
+	// the inclusive prefixes are rendered when they are in scope
+	assert.Equal(
+		t,
+		`<empty xmlns="urn:d" xmlns:b="urn:b"></empty>`,
+		string(canonicalize(child.children[0].node, nil, []string{"b", "foo"})),
+	)
+
+	// the element without a namespace undeclares the default namespace of the output ancestor
+	root, err = parseXML([]byte(`<a xmlns="urn:d"><b xmlns=""/></a>`))
+	assert.Nil(t, err)
+	assert.Equal(t, `<a xmlns="urn:d"><b xmlns=""></b></a>`, string(canonicalize(root, nil, nil)))
+
+	_, err = parseXML([]byte(`<!DOCTYPE foo [<!ENTITY x "y">]><foo>&x;</foo>`))
+	assert.NotNil(t, err)
+}
+
+type testIdP struct {
+	key  *rsa.PrivateKey
+	cert string
+	der  []byte
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testIdP{
+		key:  key,
+		der:  der,
+		cert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}
+}
+
+// sign returns the element with an enveloped signature, which is inserted after the issuer.
+func (idp *testIdP) sign(t *testing.T, el, id string) string {
+	n, err := parseXML([]byte(el))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(canonicalize(n, nil, nil))
+
+	signedInfo := fmt.Sprintf(
+		`<ds:SignedInfo><ds:CanonicalizationMethod Algorithm="%s"/><ds:SignatureMethod Algorithm="%s"/>`+
+			`<ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="%s"/><ds:Transform Algorithm="%s"/></ds:Transforms>`+
+			`<ds:DigestMethod Algorithm="%s"/><ds:DigestValue>%s</ds:DigestValue></ds:Reference></ds:SignedInfo>`,
+		algExcC14N, algRSASHA256, id, algEnveloped, algExcC14N, algSHA256, base64.StdEncoding.EncodeToString(digest[:]),
+	)
+	sig, err := parseXML([]byte(`<ds:Signature xmlns:ds="` + nsDSig + `">` + signedInfo + `</ds:Signature>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := sha256.Sum256(canonicalize(sig.children[0].node, nil, nil))
+	value, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := `<ds:Signature xmlns:ds="` + nsDSig + `">` + signedInfo +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(value) + `</ds:SignatureValue></ds:Signature>`
+
+	i := strings.Index(el, "</saml:Issuer>") + len("</saml:Issuer>")
+	return el[:i] + signature + el[i:]
+}
+
+func testAssertion(sp SAMLServiceProvider, requestID string, now time.Time) string {
+	return fmt.Sprintf(
+		`<saml:Assertion xmlns:saml="%s" xmlns:xs="http://www.w3.org/2001/XMLSchema" ID="_assertion" Version="2.0" IssueInstant="%s">`+
+			`<saml:Issuer>%s</saml:Issuer>`+
+			`<saml:Subject><saml:NameID Format="%s">jane@acme.com</saml:NameID>`+
+			`<saml:SubjectConfirmation Method="%s"><saml:SubjectConfirmationData InResponseTo="%s" NotOnOrAfter="%s" Recipient="%s"/></saml:SubjectConfirmation></saml:Subject>`+
+			`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s"><saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+			`<saml:AttributeStatement><saml:Attribute Name="groups" FriendlyName="Groups">`+
+			`<saml:AttributeValue>marketing</saml:AttributeValue><saml:AttributeValue>sales</saml:AttributeValue>`+
+			`</saml:Attribute></saml:AttributeStatement>`+
+			`</saml:Assertion>`,
+		nsSAMLAssertion,
+		now.UTC().Format(time.RFC3339),
+		sp.IdP.EntityID,
+		samlNameIDEmail,
+		samlMethodBearer,
+		requestID,
+		now.Add(5*time.Minute).UTC().Format(time.RFC3339Nano),
+		sp.ACSURL,
+		now.Add(-time.Minute).UTC().Format(time.RFC3339),
+		now.Add(5*time.Minute).UTC().Format(time.RFC3339),
+		sp.EntityID,
+	)
+}
+
+func testResponse(sp SAMLServiceProvider, requestID, assertion string) string {
+	res := fmt.Sprintf(
+		`<samlp:Response xmlns:samlp="%s" xmlns:saml="%s" ID="_response" Version="2.0" Destination="%s" InResponseTo="%s">`+
+			`<saml:Issuer>%s</saml:Issuer><samlp:Status><samlp:StatusCode Value="%s"/></samlp:Status>%s</samlp:Response>`,
+		nsSAMLProtocol,
+		nsSAMLAssertion,
+		sp.ACSURL,
+		requestID,
+		sp.IdP.EntityID,
+		samlStatusSuccess,
+		assertion,
+	)
+	return res
+}
+
+// requestID returns the id of the authentication request from the redirect url.
+func requestID(t *testing.T, redirect string) string {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := parseXML(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n.attr("ID")
+}
+
+func TestSAMLMetadata(t *testing.T) {
+	idp := newTestIdP(t)
+
+	metadata := fmt.Sprintf(
+		`<md:EntityDescriptor xmlns:md="%s" xmlns:ds="%s" entityID="https://idp.example.com">`+
+			`<md:IDPSSODescriptor protocolSupportEnumeration="%s">`+
+			`<md:KeyDescriptor use="encryption"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>foo</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`+
+			`<md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`+
+			`<md:SingleSignOnService Binding="%s" Location="https://idp.example.com/sso/post"/>`+
+			`<md:SingleSignOnService Binding="%s" Location="https://idp.example.com/sso"/>`+
+			`</md:IDPSSODescriptor></md:EntityDescriptor>`,
+		nsSAMLMetadata, nsDSig, nsSAMLProtocol, base64.StdEncoding.EncodeToString(idp.der), samlBindingPOST, samlBindingRedirect,
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(metadata))
+	}))
+	defer srv.Close()
+
+	p, err := FetchSAMLMetadata(context.Background(), srv.URL+"/metadata")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://idp.example.com", p.EntityID)
+	assert.Equal(t, "https://idp.example.com/sso", p.SSOURL)
+	assert.Equal(t, idp.cert, p.Certificate)
+
+	_, err = FetchSAMLMetadata(context.Background(), srv.URL+"/foo")
+	assert.NotNil(t, err)
+
+	_, err = ParseSAMLMetadata([]byte(`<md:EntityDescriptor xmlns:md="` + nsSAMLMetadata + `" entityID="foo"/>`))
+	assert.NotNil(t, err)
+
+	sp := SAMLServiceProvider{EntityID: "https://app.example.com/metadata", ACSURL: "https://app.example.com/acs"}
+	spMetadata, err := parseXML(sp.Metadata())
+	assert.Nil(t, err)
+	assert.Equal(t, "https://app.example.com/metadata", spMetadata.attr("entityID"))
+}
+
+func TestSAMLResponse(t *testing.T) {
+	idp := newTestIdP(t)
+	sp := SAMLServiceProvider{
+		EntityID: "https://app.example.com/metadata",
+		ACSURL:   "https://app.example.com/acs",
+		IdP: SAMLIdentityProvider{
+			EntityID:    "https://idp.example.com",
+			SSOURL:      "https://idp.example.com/sso?tenant=acme",
+			Certificate: idp.cert,
+		},
+	}
+	now := time.Now()
+
+	redirect, id, err := sp.AuthnRequestURL(now)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(redirect, "https://idp.example.com/sso?"))
+	assert.Contains(t, redirect, "tenant=acme")
+	assert.Equal(t, id, requestID(t, redirect))
+
+	assert.Nil(t, verifyRequestID(id, id, now))
+	assert.NotNil(t, verifyRequestID(id, id, now.Add(time.Hour)))
+	assert.NotNil(t, verifyRequestID(id, id+"0", now))
+	assert.NotNil(t, verifyRequestID("", "", now))
+
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	// signed assertion
+	res := testResponse(sp, id, idp.sign(t, testAssertion(sp, id, now), "_assertion"))
+	a, err := sp.ParseResponse(encode(res), id, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "jane@acme.com", a.NameID)
+	assert.Equal(t, []string{"marketing", "sales"}, a.Attributes["groups"])
+	assert.Equal(t, []string{"marketing", "sales"}, a.Attributes["Groups"])
+
+	// the assertion is accepted only once
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.NotNil(t, err)
+
+	// the used assertions are kept until they expire
+	assert.False(t, usedAssertions.add("_assertion", now, now.Add(time.Minute)))
+	assert.True(t, usedAssertions.add("_assertion", now, now.Add(9*time.Minute)))
+	usedAssertions.ids = make(map[string]time.Time)
+
+	// signed response
+	res = idp.sign(t, testResponse(sp, id, testAssertion(sp, id, now)), "_response")
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.Nil(t, err)
+
+	// a response which is not for the request of the session
+	usedAssertions.ids = make(map[string]time.Time)
+	_, err = sp.ParseResponse(encode(res), "", now)
+	assert.NotNil(t, err)
+
+	// unsigned
+	res = testResponse(sp, id, testAssertion(sp, id, now))
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.NotNil(t, err)
+
+	// the assertion is modified after it was signed
+	res = testResponse(sp, id, idp.sign(t, testAssertion(sp, id, now), "_assertion"))
+	res = strings.Replace(res, "jane@acme.com", "john@acme.com", 1)
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.NotNil(t, err)
+
+	// signed by another key
+	other := newTestIdP(t)
+	res = testResponse(sp, id, other.sign(t, testAssertion(sp, id, now), "_assertion"))
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.NotNil(t, err)
+
+	// a signed assertion is wrapped in another one
+	signed := idp.sign(t, testAssertion(sp, id, now), "_assertion")
+	wrapped := strings.Replace(testAssertion(sp, id, now), "jane@acme.com", "john@acme.com", 1)
+	res = testResponse(sp, id, wrapped+signed)
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.NotNil(t, err)
+
+	// expired
+	res = testResponse(sp, id, idp.sign(t, testAssertion(sp, id, now), "_assertion"))
+	_, err = sp.ParseResponse(encode(res), id, now.Add(9*time.Minute))
+	assert.NotNil(t, err)
+
+	// a response to another request
+	res = testResponse(sp, "_foo", idp.sign(t, testAssertion(sp, "_foo", now), "_assertion"))
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.NotNil(t, err)
+
+	// another audience
+	res = testResponse(sp, id, idp.sign(t, strings.Replace(testAssertion(sp, id, now), "<saml:Audience>"+sp.EntityID, "<saml:Audience>foo", 1), "_assertion"))
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.NotNil(t, err)
+
+	// failed authentication
+	res = strings.Replace(testResponse(sp, id, ""), samlStatusSuccess, "urn:oasis:names:tc:SAML:2.0:status:Requester", 1)
+	_, err = sp.ParseResponse(encode(res), id, now)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,37 @@
+// Package sso implements the single sign-on with the SAML 2.0 and the OpenID Connect identity providers.
+package sso
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxResponseSize limits the size of the documents which are fetched from the identity providers.
+const maxResponseSize = 1 << 20
+
+var httpClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// fetch returns the body of the document at the given url.
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+}
@@ -0,0 +1,472 @@
+package sso
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// XML signature algorithms. The SHA-1 based algorithms are not supported.
+const (
+	nsDSig       = "http://www.w3.org/2000/09/xmldsig#"
+	nsXML        = "http://www.w3.org/XML/1998/namespace"
+	algExcC14N   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algEnveloped = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algRSASHA512 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	algECSHA256  = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	algSHA256    = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algSHA512    = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+var errNotSigned = errors.New("sso: the element is not signed")
+
+// xmlNode is a minimal DOM of the parsed document. Unlike encoding/xml it keeps the prefixes
+// and the declarations of the namespaces, which are needed to canonicalize the signed elements.
+type xmlNode struct {
+	parent   *xmlNode
+	prefix   string
+	local    string
+	attrs    []xmlAttr
+	ns       map[string]string
+	children []xmlChild
+}
+
+type xmlAttr struct {
+	prefix string
+	local  string
+	value  string
+}
+
+// xmlChild is either an element, a text or a processing instruction.
+type xmlChild struct {
+	node *xmlNode
+	text string
+	pi   *xml.ProcInst
+}
+
+// parseXML parses the document, the comments are dropped and the documents with a DTD are rejected.
+func parseXML(data []byte) (*xmlNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+
+	var root, cur *xmlNode
+	for {
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sso: parse xml: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{
+				parent: cur,
+				prefix: t.Name.Space,
+				local:  t.Name.Local,
+				ns:     make(map[string]string),
+			}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					n.ns[""] = a.Value
+				case a.Name.Space == "xmlns":
+					n.ns[a.Name.Local] = a.Value
+				default:
+					n.attrs = append(n.attrs, xmlAttr{prefix: a.Name.Space, local: a.Name.Local, value: a.Value})
+				}
+			}
+
+			if cur == nil {
+				if root != nil {
+					return nil, errors.New("sso: parse xml: multiple root elements")
+				}
+				root = n
+			} else {
+				cur.children = append(cur.children, xmlChild{node: n})
+			}
+			cur = n
+		case xml.EndElement:
+			if cur == nil || t.Name.Space != cur.prefix || t.Name.Local != cur.local {
+				return nil, errors.New("sso: parse xml: unexpected end element")
+			}
+			cur = cur.parent
+		case xml.CharData:
+			if cur != nil {
+				cur.children = append(cur.children, xmlChild{text: string(t)})
+			}
+		case xml.ProcInst:
+			if cur != nil {
+				pi := t.Copy()
+				cur.children = append(cur.children, xmlChild{pi: &pi})
+			}
+		case xml.Directive:
+			return nil, errors.New("sso: parse xml: directives are not allowed")
+		}
+	}
+
+	if root == nil || cur != nil {
+		return nil, errors.New("sso: parse xml: incomplete document")
+	}
+
+	return root, nil
+}
+
+// lookupNS returns the namespace which is bound to the prefix in the scope of the element.
+func (n *xmlNode) lookupNS(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return nsXML, true
+	}
+	for e := n; e != nil; e = e.parent {
+		if uri, ok := e.ns[prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// space returns the namespace of the element.
+func (n *xmlNode) space() string {
+	uri, _ := n.lookupNS(n.prefix)
+	return uri
+}
+
+// attr returns the value of the attribute without a namespace.
+func (n *xmlNode) attr(local string) string {
+	for _, a := range n.attrs {
+		if a.prefix == "" && a.local == local {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element with the given namespace and name.
+func (n *xmlNode) child(space, local string) *xmlNode {
+	for _, c := range n.children {
+		if c.node != nil && c.node.local == local && c.node.space() == space {
+			return c.node
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns the child elements with the given namespace and name.
+func (n *xmlNode) childrenNamed(space, local string) []*xmlNode {
+	var nodes []*xmlNode
+	for _, c := range n.children {
+		if c.node != nil && c.node.local == local && c.node.space() == space {
+			nodes = append(nodes, c.node)
+		}
+	}
+	return nodes
+}
+
+// text returns the text content of the element.
+func (n *xmlNode) text() string {
+	var b strings.Builder
+	for _, c := range n.children {
+		if c.node != nil {
+			b.WriteString(c.node.text())
+		} else {
+			b.WriteString(c.text)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// canonicalize serializes the element with the exclusive XML canonicalization, without comments.
+// The exclude element is omitted from the output, it's used by the enveloped signature transform.
+// The inclusive prefixes are rendered as in the inclusive canonicalization, when they are in scope.
+func canonicalize(n, exclude *xmlNode, inclusive []string) []byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, n, exclude, inclusive, map[string]string{})
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, n, exclude *xmlNode, inclusive []string, rendered map[string]string) {
+	// the namespaces which are visibly utilized by the element and its attributes
+	utilized := map[string]bool{n.prefix: true}
+	for _, a := range n.attrs {
+		if a.prefix != "" {
+			utilized[a.prefix] = true
+		}
+	}
+	for _, p := range inclusive {
+		if _, ok := n.lookupNS(p); ok {
+			utilized[p] = true
+		}
+	}
+
+	var decls []string
+	scope := make(map[string]string, len(rendered))
+	for p, uri := range rendered {
+		scope[p] = uri
+	}
+	for p := range utilized {
+		if p == "xml" {
+			continue
+		}
+		uri, _ := n.lookupNS(p)
+		prev, ok := rendered[p]
+		if (ok && prev == uri) || (!ok && uri == "") {
+			continue
+		}
+		decls = append(decls, p)
+		scope[p] = uri
+	}
+	sort.Strings(decls)
+
+	attrs := make([]xmlAttr, len(n.attrs))
+	copy(attrs, n.attrs)
+	sort.SliceStable(attrs, func(i, j int) bool {
+		si, _ := n.lookupNS(attrs[i].prefix)
+		sj, _ := n.lookupNS(attrs[j].prefix)
+		if attrs[i].prefix == "" {
+			si = ""
+		}
+		if attrs[j].prefix == "" {
+			sj = ""
+		}
+		if si != sj {
+			return si < sj
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	name := qualifiedName(n.prefix, n.local)
+	buf.WriteString("<" + name)
+	for _, p := range decls {
+		if p == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + p + `="`)
+		}
+		buf.WriteString(escapeCanonicalAttr(scope[p]))
+		buf.WriteString(`"`)
+	}
+	for _, a := range attrs {
+		buf.WriteString(" " + qualifiedName(a.prefix, a.local) + `="`)
+		buf.WriteString(escapeCanonicalAttr(a.value))
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+
+	for _, c := range n.children {
+		switch {
+		case c.node != nil:
+			if c.node != exclude {
+				writeCanonical(buf, c.node, exclude, inclusive, scope)
+			}
+		case c.pi != nil:
+			buf.WriteString("<?" + c.pi.Target)
+			if len(c.pi.Inst) > 0 {
+				buf.WriteString(" " + string(c.pi.Inst))
+			}
+			buf.WriteString("?>")
+		default:
+			buf.WriteString(escapeCanonicalText(c.text))
+		}
+	}
+
+	buf.WriteString("</" + name + ">")
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var (
+	canonicalTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	canonicalAttrEscaper = strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		`"`, "&quot;",
+		"\t", "&#x9;",
+		"\n", "&#xA;",
+		"\r", "&#xD;",
+	)
+)
+
+func escapeCanonicalText(s string) string {
+	return canonicalTextEscaper.Replace(s)
+}
+
+func escapeCanonicalAttr(s string) string {
+	return canonicalAttrEscaper.Replace(s)
+}
+
+// inclusivePrefixes returns the prefixes of the InclusiveNamespaces of the exclusive canonicalization.
+func inclusivePrefixes(method *xmlNode) []string {
+	var prefixes []string
+	for _, c := range method.children {
+		if c.node == nil || c.node.local != "InclusiveNamespaces" || c.node.space() != algExcC14N {
+			continue
+		}
+		for _, p := range strings.Fields(c.node.attr("PrefixList")) {
+			if p == "#default" {
+				p = ""
+			}
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// verifySignature verifies the enveloped signature of the element with one of the certificates.
+// The signature must reference the element itself, so only the verified element can be trusted,
+// errNotSigned is returned when the element has no signature.
+func verifySignature(el *xmlNode, certs []*x509.Certificate) error {
+	sig := el.child(nsDSig, "Signature")
+	if sig == nil {
+		return errNotSigned
+	}
+
+	id := el.attr("ID")
+	if id == "" {
+		return errors.New("sso: the signed element has no id")
+	}
+
+	signedInfo := sig.child(nsDSig, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("sso: the signature has no signed info")
+	}
+
+	c14nMethod := signedInfo.child(nsDSig, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.attr("Algorithm") != algExcC14N {
+		return errors.New("sso: unsupported canonicalization method")
+	}
+
+	sigMethod := signedInfo.child(nsDSig, "SignatureMethod")
+	if sigMethod == nil {
+		return errors.New("sso: the signature has no signature method")
+	}
+	var sigHash crypto.Hash
+	switch sigMethod.attr("Algorithm") {
+	case algRSASHA256, algECSHA256:
+		sigHash = crypto.SHA256
+	case algRSASHA512:
+		sigHash = crypto.SHA512
+	default:
+		return fmt.Errorf("sso: unsupported signature method %q", sigMethod.attr("Algorithm"))
+	}
+
+	refs := signedInfo.childrenNamed(nsDSig, "Reference")
+	if len(refs) != 1 {
+		return errors.New("sso: the signature must have exactly one reference")
+	}
+	ref := refs[0]
+	if ref.attr("URI") != "#"+id {
+		return errors.New("sso: the signature doesn't reference the signed element")
+	}
+
+	var (
+		enveloped bool
+		exclusive bool
+		prefixes  []string
+	)
+	if transforms := ref.child(nsDSig, "Transforms"); transforms != nil {
+		for _, t := range transforms.childrenNamed(nsDSig, "Transform") {
+			switch t.attr("Algorithm") {
+			case algEnveloped:
+				enveloped = true
+			case algExcC14N:
+				exclusive = true
+				prefixes = inclusivePrefixes(t)
+			default:
+				return fmt.Errorf("sso: unsupported transform %q", t.attr("Algorithm"))
+			}
+		}
+	}
+	if !enveloped || !exclusive {
+		return errors.New("sso: the signature must be enveloped and canonicalized with the exclusive canonicalization")
+	}
+
+	digestMethod := ref.child(nsDSig, "DigestMethod")
+	if digestMethod == nil {
+		return errors.New("sso: the reference has no digest method")
+	}
+	var digestHash crypto.Hash
+	switch digestMethod.attr("Algorithm") {
+	case algSHA256:
+		digestHash = crypto.SHA256
+	case algSHA512:
+		digestHash = crypto.SHA512
+	default:
+		return fmt.Errorf("sso: unsupported digest method %q", digestMethod.attr("Algorithm"))
+	}
+
+	digestValue := ref.child(nsDSig, "DigestValue")
+	if digestValue == nil {
+		return errors.New("sso: the reference has no digest value")
+	}
+	expected, err := decodeBase64(digestValue.text())
+	if err != nil {
+		return fmt.Errorf("sso: decode digest value: %w", err)
+	}
+
+	h := digestHash.New()
+	h.Write(canonicalize(el, sig, prefixes))
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		return errors.New("sso: the digest of the signed element doesn't match")
+	}
+
+	sigValue := sig.child(nsDSig, "SignatureValue")
+	if sigValue == nil {
+		return errors.New("sso: the signature has no signature value")
+	}
+	signature, err := decodeBase64(sigValue.text())
+	if err != nil {
+		return fmt.Errorf("sso: decode signature value: %w", err)
+	}
+
+	h = sigHash.New()
+	h.Write(canonicalize(signedInfo, nil, inclusivePrefixes(c14nMethod)))
+	hashed := h.Sum(nil)
+
+	for _, cert := range certs {
+		if verifyWithKey(cert.PublicKey, sigHash, hashed, signature) {
+			return nil
+		}
+	}
+
+	return errors.New("sso: invalid signature")
+}
+
+// verifyWithKey verifies the PKCS #1 v1.5 signature with a RSA key or the raw r || s signature with an EC key.
+func verifyWithKey(key crypto.PublicKey, h crypto.Hash, hashed, signature []byte) bool {
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, h, hashed, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(pub, hashed, r, s)
+	default:
+		return false
+	}
+}
+
+// decodeBase64 decodes the base64 encoded value, which may be wrapped on multiple lines.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
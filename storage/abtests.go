@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func preloadVariants(db *gorm.DB) *gorm.DB {
+	return db.Order("id asc").Preload("BaseTemplate")
+}
+
+// GetABTest returns the A/B test of the campaign with its variants.
+func (db *store) GetABTest(campaignID, userID int64) (*entities.ABTest, error) {
+	var t = new(entities.ABTest)
+	err := db.Where("campaign_id = ? AND user_id = ?", campaignID, userID).
+		Preload("Variants", preloadVariants).
+		First(t).Error
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetABTestsToPickWinner returns the A/B tests which are waiting for the winner to be picked before the given time.
+func (db *store) GetABTestsToPickWinner(t time.Time) ([]entities.ABTest, error) {
+	var tests []entities.ABTest
+	err := db.Where("status = ? AND pick_winner_at <= ?", entities.ABTestStatusTesting, t).
+		Preload("Variants", preloadVariants).
+		Find(&tests).Error
+	return tests, err
+}
+
+// SaveABTest creates or updates the A/B test and replaces its variants.
+func (db *store) SaveABTest(t *entities.ABTest) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Omit(clause.Associations).Save(t).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: save ab test: %w", err)
+	}
+
+	err = tx.Where("campaign_id = ? AND user_id = ?", t.CampaignID, t.UserID).
+		Delete(&entities.CampaignVariant{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete variants: %w", err)
+	}
+
+	for i := range t.Variants {
+		v := &t.Variants[i]
+		v.ID = 0
+		v.UserID = t.UserID
+		v.CampaignID = t.CampaignID
+
+		err = tx.Omit(clause.Associations).Create(v).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: create variant: %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// UpdateABTest updates the A/B test without changing its variants.
+func (db *store) UpdateABTest(t *entities.ABTest) error {
+	return db.Omit(clause.Associations).
+		Where("campaign_id = ? AND user_id = ?", t.CampaignID, t.UserID).
+		Save(t).Error
+}
+
+// UpdateVariantTemplateVersions stores the template versions which the variants are sent with.
+func (db *store) UpdateVariantTemplateVersions(variants []entities.CampaignVariant) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, v := range variants {
+		err := tx.Model(&entities.CampaignVariant{}).
+			Where("id = ? AND user_id = ?", v.ID, v.UserID).
+			Update("template_version", v.TemplateVersion).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: update variant template version: %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteABTest deletes the A/B test of the campaign with its variants.
+func (db *store) DeleteABTest(campaignID, userID int64) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Where("campaign_id = ? AND user_id = ?", campaignID, userID).
+		Delete(&entities.CampaignVariant{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete variants: %w", err)
+	}
+
+	err = tx.Where("campaign_id = ? AND user_id = ?", campaignID, userID).
+		Delete(&entities.ABTest{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete ab test: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// GetVariantStats returns the campaign stats of the given variant.
+func (db *store) GetVariantStats(campaignID, userID, variantID int64) (*entities.CampaignStats, error) {
+	stats := &entities.CampaignStats{
+		Opens:  &entities.OpensStats{},
+		Clicks: &entities.ClicksStats{},
+	}
+
+	where := "campaign_id = ? AND user_id = ? AND variant_id = ?"
+
+	// the send logs are written for every delivery provider, unlike the sends which are reported by SES
+	err := db.Table("send_logs").Where(where, campaignID, userID, variantID).
+		Where("status = ?", entities.SendLogStatusSuccessful).
+		Count(&stats.TotalSent).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Table("deliveries").Where(where, campaignID, userID, variantID).Count(&stats.Delivered).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Table("opens").Where(where, campaignID, userID, variantID).
+		Select("count(distinct(recipient))").Count(&stats.Opens.Unique).
+		Select("count(recipient)").Count(&stats.Opens.Total).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Table("clicks").Where(where, campaignID, userID, variantID).
+		Select("count(distinct(recipient))").Count(&stats.Clicks.UniqueClicks).
+		Select("count(recipient)").Count(&stats.Clicks.TotalClicks).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Table("bounces").Where(where, campaignID, userID, variantID).Count(&stats.Bounces).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Table("complaints").Where(where, campaignID, userID, variantID).Count(&stats.Complaints).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
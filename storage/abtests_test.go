@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestABTest(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	_, err := store.GetABTest(1, 1)
+	assert.NotNil(t, err)
+
+	subject := "Hello {{name}}"
+	test := &entities.ABTest{
+		CampaignID:     1,
+		UserID:         1,
+		TestPercentage: 20,
+		WaitMinutes:    60,
+		WinnerMetric:   entities.WinnerMetricOpens,
+		Status:         entities.ABTestStatusDraft,
+		Variants: []entities.CampaignVariant{
+			{Name: "A"},
+			{Name: "B", SubjectPart: &subject},
+		},
+	}
+
+	err = store.SaveABTest(test)
+	assert.Nil(t, err)
+
+	test, err = store.GetABTest(1, 1)
+	assert.Nil(t, err)
+	assert.Len(t, test.Variants, 2)
+	assert.Equal(t, "A", test.Variants[0].Name)
+	assert.Equal(t, subject, *test.Variants[1].SubjectPart)
+
+	// the variants are replaced
+	test.Variants = test.Variants[1:]
+	err = store.SaveABTest(test)
+	assert.Nil(t, err)
+
+	test, err = store.GetABTest(1, 1)
+	assert.Nil(t, err)
+	assert.Len(t, test.Variants, 1)
+	variantID := test.Variants[0].ID
+
+	test.Variants[0].TemplateVersion = 3
+	err = store.UpdateVariantTemplateVersions(test.Variants)
+	assert.Nil(t, err)
+
+	test, err = store.GetABTest(1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), test.Variants[0].TemplateVersion)
+
+	tests, err := store.GetABTestsToPickWinner(time.Now())
+	assert.Nil(t, err)
+	assert.Empty(t, tests)
+
+	test.Status = entities.ABTestStatusTesting
+	test.PickWinnerAt.SetValid(time.Now().Add(-time.Minute))
+	err = store.UpdateABTest(test)
+	assert.Nil(t, err)
+
+	tests, err = store.GetABTestsToPickWinner(time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, tests, 1)
+	assert.Len(t, tests[0].Variants, 1)
+	assert.Equal(t, variantID, tests[0].Variants[0].ID)
+
+	err = store.CreateOpen(&entities.Open{UserID: 1, CampaignID: 1, VariantID: &variantID, Recipient: "foo@example.com"})
+	assert.Nil(t, err)
+	err = store.CreateOpen(&entities.Open{UserID: 1, CampaignID: 1, Recipient: "bar@example.com"})
+	assert.Nil(t, err)
+
+	err = store.CreateSendLog(&entities.SendLog{
+		ID:         ksuid.New(),
+		EventID:    ksuid.New(),
+		UserID:     1,
+		CampaignID: 1,
+		VariantID:  &variantID,
+		Status:     entities.SendLogStatusSuccessful,
+	})
+	assert.Nil(t, err)
+	err = store.CreateSendLog(&entities.SendLog{
+		ID:         ksuid.New(),
+		EventID:    ksuid.New(),
+		UserID:     1,
+		CampaignID: 1,
+		VariantID:  &variantID,
+		Status:     entities.SendLogStatusFailed,
+	})
+	assert.Nil(t, err)
+
+	stats, err := store.GetVariantStats(1, 1, variantID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), stats.Opens.Unique)
+	assert.Equal(t, int64(1), stats.TotalSent)
+
+	err = store.DeleteABTest(1, 1)
+	assert.Nil(t, err)
+
+	_, err = store.GetABTest(1, 1)
+	assert.NotNil(t, err)
+}
@@ -1,26 +1,64 @@
 package storage
 
 import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
 	"github.com/mailbadger/app/entities"
 )
 
 // GetAPIKeys fetches api keys by user id.
 func (db *store) GetAPIKeys(userID int64) ([]*entities.APIKey, error) {
 	var keys []*entities.APIKey
-	err := db.Where("user_id = ?", userID).Find(&keys).Error
+	err := db.Where("user_id = ?", userID).Order("id desc").Find(&keys).Error
 	return keys, err
 }
 
-// GetAPIKey fetches access keys by the given secret.
-func (db *store) GetAPIKey(identifier string) (*entities.APIKey, error) {
+// GetAPIKeyByID fetches the api key by id and user id.
+func (db *store) GetAPIKeyByID(id, userID int64) (*entities.APIKey, error) {
 	var key = new(entities.APIKey)
+	err := db.Where("id = ? and user_id = ?", id, userID).First(key).Error
+	return key, err
+}
+
+// GetAPIKey fetches the active and unexpired api key by the given key.
+func (db *store) GetAPIKey(key string) (*entities.APIKey, error) {
+	var ak = new(entities.APIKey)
 	err := db.
-		Where("secret_key = ? and active = ?", identifier, true).
+		Where("secret_key = ? and active = ?", entities.HashAPIKey(key), true).
+		Where("(expires_at is null or expires_at > ?)", time.Now()).
 		Preload("User.Boundaries").Preload("User.Roles").
-		First(key).
+		First(ak).
 		Error
 
-	return key, err
+	return ak, err
+}
+
+// hashLegacyAPIKeys hashes the api keys which were created before the secrets were hashed, they are
+// stored without a prefix. The mysql migration hashes them with sql, sqlite doesn't have a sha256 function
+// so they are hashed after the migrations are run.
+func hashLegacyAPIKeys(db *gorm.DB) error {
+	var keys []entities.APIKey
+	err := db.Where("prefix = ''").Find(&keys).Error
+	if err != nil {
+		return fmt.Errorf("get legacy api keys: %w", err)
+	}
+
+	for _, k := range keys {
+		err = db.Model(&entities.APIKey{}).
+			Where("id = ? and prefix = ''", k.ID).
+			Updates(map[string]interface{}{
+				"secret_key": entities.HashAPIKey(k.SecretKey),
+				"prefix":     entities.APIKeyLegacyPrefix,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("hash legacy api key: %w", err)
+		}
+	}
+
+	return nil
 }
 
 // CreateAPIKey creates a new api key in the database.
@@ -30,7 +68,12 @@ func (db *store) CreateAPIKey(ak *entities.APIKey) error {
 
 // UpdateAccessKey edits an existing api key in the database.
 func (db *store) UpdateAPIKey(ak *entities.APIKey) error {
-	return db.Where("id = ? and user_id = ?", ak.ID, ak.UserID).Save(ak).Error
+	return db.Where("id = ? and user_id = ?", ak.ID, ak.UserID).Omit("last_used_at").Save(ak).Error
+}
+
+// UpdateAPIKeyLastUsedAt sets the time when the api key was last used.
+func (db *store) UpdateAPIKeyLastUsedAt(id int64, t time.Time) error {
+	return db.Model(&entities.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", t).Error
 }
 
 // DeleteAccessKey deletes an existing api key from the database.
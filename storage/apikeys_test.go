@@ -3,6 +3,7 @@ package storage
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/mailbadger/app/entities"
 	"github.com/stretchr/testify/assert"
@@ -20,6 +21,7 @@ func TestAPIKeys(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Empty(t, keys)
 
+	// the keys which were created before the secrets were hashed are hashed on startup
 	k := &entities.APIKey{
 		UserID:    1,
 		Active:    true,
@@ -29,9 +31,16 @@ func TestAPIKeys(t *testing.T) {
 	err = store.CreateAPIKey(k)
 	assert.Nil(t, err)
 
+	_, err = store.GetAPIKey("foobar")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	err = hashLegacyAPIKeys(db)
+	assert.Nil(t, err)
+
 	k, err = store.GetAPIKey("foobar")
 	assert.Nil(t, err)
-	assert.Equal(t, k.SecretKey, "foobar")
+	assert.Equal(t, entities.HashAPIKey("foobar"), k.SecretKey)
+	assert.Equal(t, entities.APIKeyLegacyPrefix, k.Prefix)
 	assert.True(t, k.Active)
 	assert.Equal(t, k.User.Username, "admin")
 	assert.NotNil(t, k.User.Boundaries)
@@ -56,4 +65,41 @@ func TestAPIKeys(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
 
+	k = &entities.APIKey{
+		UserID:    1,
+		Name:      "integration",
+		Prefix:    "mb_foo",
+		SecretKey: entities.HashAPIKey("mb_foo.bar"),
+		Scopes:    entities.Scopes{"subscribers:write"},
+		Active:    true,
+		ExpiresAt: entities.TimeFrom(time.Now().Add(time.Hour)),
+	}
+	err = store.CreateAPIKey(k)
+	assert.Nil(t, err)
+
+	// only the hash of the key is stored
+	_, err = store.GetAPIKey(k.SecretKey)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	key, err := store.GetAPIKey("mb_foo.bar")
+	assert.Nil(t, err)
+	assert.Equal(t, entities.Scopes{"subscribers:write"}, key.Scopes)
+	assert.False(t, key.LastUsedAt.Valid)
+
+	err = store.UpdateAPIKeyLastUsedAt(k.ID, time.Now())
+	assert.Nil(t, err)
+
+	key, err = store.GetAPIKeyByID(k.ID, 1)
+	assert.Nil(t, err)
+	assert.True(t, key.LastUsedAt.Valid)
+
+	_, err = store.GetAPIKeyByID(k.ID, 2)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	key.ExpiresAt = entities.TimeFrom(time.Now().Add(-time.Hour))
+	err = store.UpdateAPIKey(key)
+	assert.Nil(t, err)
+
+	_, err = store.GetAPIKey("mb_foo.bar")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
 }
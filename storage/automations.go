@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetAutomations fetches the automations of the user along with their steps.
+func (db *store) GetAutomations(userID int64) ([]entities.Automation, error) {
+	var automations []entities.Automation
+	err := db.Preload("Steps", orderedSteps).Where("user_id = ?", userID).Order("id").Find(&automations).Error
+	return automations, err
+}
+
+// GetAutomation fetches an automation by the given id and user id, along with its steps.
+func (db *store) GetAutomation(id, userID int64) (*entities.Automation, error) {
+	var a = new(entities.Automation)
+	err := db.Preload("Steps", orderedSteps).Where("id = ? AND user_id = ?", id, userID).First(a).Error
+	return a, err
+}
+
+// CreateAutomation creates a new automation along with its steps.
+func (db *store) CreateAutomation(a *entities.Automation) error {
+	return db.Create(a).Error
+}
+
+// UpdateAutomation edits an existing automation and replaces its steps. The subscribers which
+// are in the middle of the automation continue from the same position with the new steps.
+func (db *store) UpdateAutomation(a *entities.Automation) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Where("automation_id = ?", a.ID).Delete(&entities.AutomationStep{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete automation steps: %w", err)
+	}
+
+	for i := range a.Steps {
+		a.Steps[i].ID = 0
+		a.Steps[i].AutomationID = a.ID
+	}
+
+	err = tx.Session(&gorm.Session{FullSaveAssociations: true}).
+		Where("id = ? AND user_id = ?", a.ID, a.UserID).
+		Save(a).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: update automation: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteAutomation deletes the automation along with its steps, runs and logs.
+func (db *store) DeleteAutomation(id, userID int64) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Where("automation_id = ? AND user_id = ?", id, userID).Delete(&entities.AutomationLog{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete automation logs: %w", err)
+	}
+
+	err = tx.Where("automation_id = ? AND user_id = ?", id, userID).Delete(&entities.AutomationRun{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete automation runs: %w", err)
+	}
+
+	err = tx.Where("automation_id IN (?)", tx.Table("automations").Select("id").Where("id = ? AND user_id = ?", id, userID)).
+		Delete(&entities.AutomationStep{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete automation steps: %w", err)
+	}
+
+	err = tx.Where("id = ? AND user_id = ?", id, userID).Delete(&entities.Automation{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete automation: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// GetAutomationRuns fetches the runs of the automation, and populates the pagination obj.
+func (db *store) GetAutomationRuns(automationID, userID int64, p *PaginationCursor) error {
+	p.SetCollection(new([]entities.AutomationRun))
+	p.SetResource("automation_runs")
+	p.AddScope(BelongsToAutomation(automationID))
+
+	query := db.Table(p.Resource).
+		Where("user_id = ?", userID).
+		Order("created_at desc, id desc").
+		Limit(p.PerPage)
+
+	p.SetQuery(query)
+
+	return db.Paginate(p, userID)
+}
+
+// GetAutomationLogs fetches the sent emails of the automation, and populates the pagination obj.
+func (db *store) GetAutomationLogs(automationID, userID int64, p *PaginationCursor) error {
+	p.SetCollection(new([]entities.AutomationLog))
+	p.SetResource("automation_logs")
+	p.AddScope(BelongsToAutomation(automationID))
+
+	query := db.Table(p.Resource).
+		Where("user_id = ?", userID).
+		Order("created_at desc, id desc").
+		Limit(p.PerPage)
+
+	p.SetQuery(query)
+
+	return db.Paginate(p, userID)
+}
+
+// GetDueAutomationRuns fetches the runs of the active automations which should be advanced before the given time.
+func (db *store) GetDueAutomationRuns(t time.Time, limit int) ([]entities.AutomationRun, error) {
+	var runs []entities.AutomationRun
+	err := db.Select("automation_runs.*").
+		Joins("INNER JOIN automations ON automations.id = automation_runs.automation_id").
+		Where("automations.status = ?", entities.AutomationStatusActive).
+		Where("automation_runs.status = ? AND automation_runs.next_run_at <= ?", entities.AutomationRunStatusActive, t).
+		Order("automation_runs.next_run_at, automation_runs.id").
+		Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
+
+// UpdateAutomationRun saves the progress of the subscriber through the automation.
+func (db *store) UpdateAutomationRun(r *entities.AutomationRun) error {
+	r.UpdatedAt = time.Now().UTC()
+	return db.Model(r).Select("status", "position", "event_id", "next_run_at", "description", "updated_at").Updates(r).Error
+}
+
+// CreateAutomationLog creates a new automation log in the database.
+func (db *store) CreateAutomationLog(l *entities.AutomationLog) error {
+	return db.Create(l).Error
+}
+
+// BelongsToAutomation applies a scope for the automation runs and logs by the given automation id.
+func BelongsToAutomation(automationID int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("automation_id = ?", automationID)
+	}
+}
+
+func orderedSteps(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+// enrollInAutomations starts a run for the subscriber in each active automation of the user with the
+// given trigger, the scope narrows down the automations by the details of the trigger. A subscriber goes
+// through an automation only once. It is invoked in the same transaction in which the trigger occurs.
+func enrollInAutomations(tx *gorm.DB, userID, subscriberID int64, trigger string, scope func(*gorm.DB) *gorm.DB) error {
+	query := tx.Where("user_id = ? AND status = ? AND trigger_type = ?", userID, entities.AutomationStatusActive, trigger)
+	if scope != nil {
+		query = query.Scopes(scope)
+	}
+
+	var automations []entities.Automation
+	err := query.Find(&automations).Error
+	if err != nil {
+		return fmt.Errorf("fetch automations: %w", err)
+	}
+
+	for _, a := range automations {
+		r := &entities.AutomationRun{
+			UserID:       userID,
+			AutomationID: a.ID,
+			SubscriberID: subscriberID,
+			Status:       entities.AutomationRunStatusActive,
+		}
+		r.NextRunAt.SetValid(time.Now().UTC())
+
+		err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(r).Error
+		if err != nil {
+			return fmt.Errorf("create automation run: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// enrollInSegmentAutomations enrolls the subscriber in the automations triggered by joining one of the segments.
+func enrollInSegmentAutomations(tx *gorm.DB, userID, subscriberID int64, segmentIDs []int64) error {
+	if len(segmentIDs) == 0 {
+		return nil
+	}
+	return enrollInAutomations(tx, userID, subscriberID, entities.AutomationTriggerSegmentJoined, func(db *gorm.DB) *gorm.DB {
+		return db.Where("trigger_segment_id IN (?)", segmentIDs)
+	})
+}
+
+// enrollClickedSubscriber enrolls the subscriber who clicked a link of the campaign in the automations
+// triggered by it, the click is ignored when the recipient is not a subscriber.
+func enrollClickedSubscriber(tx *gorm.DB, c *entities.Click) error {
+	var s entities.Subscriber
+	err := tx.Where("user_id = ? AND email = ?", c.UserID, c.Recipient).First(&s).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("fetch subscriber: %w", err)
+	}
+
+	return enrollInAutomations(tx, c.UserID, s.ID, entities.AutomationTriggerLinkClicked, func(db *gorm.DB) *gorm.DB {
+		return db.Where("trigger_campaign_id = ? AND (trigger_link = '' OR trigger_link = ?)", c.CampaignID, c.Link)
+	})
+}
+
+// enrollOnMetadataChange enrolls the subscriber in the automations triggered by the change of the
+// metadata. It compares the stored metadata with the new one, so it is invoked before the subscriber is saved.
+func enrollOnMetadataChange(tx *gorm.DB, s *entities.Subscriber) error {
+	current := new(entities.Subscriber)
+	err := tx.Select("id", "metadata").Where("id = ? AND user_id = ?", s.ID, s.UserID).First(current).Error
+	if err != nil {
+		return fmt.Errorf("fetch subscriber: %w", err)
+	}
+
+	before, err := current.GetMetadata()
+	if err != nil {
+		return fmt.Errorf("decode current metadata: %w", err)
+	}
+	after, err := (&entities.Subscriber{MetaJSON: s.MetaJSON}).GetMetadata()
+	if err != nil {
+		return fmt.Errorf("decode new metadata: %w", err)
+	}
+
+	var changed []string
+	for k, v := range after {
+		if old, ok := before[k]; !ok || old != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	return enrollInAutomations(tx, s.UserID, s.ID, entities.AutomationTriggerMetadataChanged, func(db *gorm.DB) *gorm.DB {
+		return db.Where("trigger_metadata_key = '' OR trigger_metadata_key IN (?)", changed)
+	})
+}
+
+func segmentIDs(segments []entities.Segment) []int64 {
+	ids := make([]int64, 0, len(segments))
+	for _, s := range segments {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestAutomations(t *testing.T) {
+	db := openTestDb()
+
+	store := From(db)
+
+	seg := &entities.Segment{UserID: 1, Name: "vip"}
+	err := store.CreateSegment(seg)
+	assert.Nil(t, err)
+
+	campaignID := int64(10)
+	welcome := &entities.Automation{
+		UserID:      1,
+		Name:        "welcome",
+		Status:      entities.AutomationStatusActive,
+		TriggerType: entities.AutomationTriggerSubscriberCreated,
+		Steps: []entities.AutomationStep{
+			{Position: 0, Type: entities.AutomationStepDelay, DelayMinutes: 60},
+			{Position: 1, Type: entities.AutomationStepCondition, ConditionType: entities.AutomationConditionInSegment, ConditionSegmentID: &seg.ID},
+		},
+	}
+	err = store.CreateAutomation(welcome)
+	assert.Nil(t, err)
+
+	joined := &entities.Automation{
+		UserID:           1,
+		Name:             "joined vip",
+		Status:           entities.AutomationStatusActive,
+		TriggerType:      entities.AutomationTriggerSegmentJoined,
+		TriggerSegmentID: &seg.ID,
+		Steps:            []entities.AutomationStep{{Type: entities.AutomationStepDelay, DelayMinutes: 1}},
+	}
+	err = store.CreateAutomation(joined)
+	assert.Nil(t, err)
+
+	clicked := &entities.Automation{
+		UserID:            1,
+		Name:              "clicked",
+		Status:            entities.AutomationStatusActive,
+		TriggerType:       entities.AutomationTriggerLinkClicked,
+		TriggerCampaignID: &campaignID,
+		TriggerLink:       "https://example.com/pricing",
+		Steps:             []entities.AutomationStep{{Type: entities.AutomationStepDelay, DelayMinutes: 1}},
+	}
+	err = store.CreateAutomation(clicked)
+	assert.Nil(t, err)
+
+	plan := &entities.Automation{
+		UserID:             1,
+		Name:               "plan changed",
+		Status:             entities.AutomationStatusDraft,
+		TriggerType:        entities.AutomationTriggerMetadataChanged,
+		TriggerMetadataKey: "plan",
+		Steps:              []entities.AutomationStep{{Type: entities.AutomationStepDelay, DelayMinutes: 1}},
+	}
+	err = store.CreateAutomation(plan)
+	assert.Nil(t, err)
+
+	automations, err := store.GetAutomations(1)
+	assert.Nil(t, err)
+	assert.Len(t, automations, 4)
+
+	a, err := store.GetAutomation(welcome.ID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, a.Steps, 2)
+	assert.Equal(t, entities.AutomationStepDelay, a.Steps[0].Type)
+
+	_, err = store.GetAutomation(welcome.ID, 2)
+	assert.NotNil(t, err)
+
+	// the draft automation is activated and its steps are replaced
+	plan.Status = entities.AutomationStatusActive
+	plan.Steps = []entities.AutomationStep{
+		{Position: 0, Type: entities.AutomationStepCondition, ConditionType: entities.AutomationConditionMetadataEquals, ConditionKey: "plan", ConditionValue: "pro"},
+		{Position: 1, Type: entities.AutomationStepDelay, DelayMinutes: 5},
+	}
+	err = store.UpdateAutomation(plan)
+	assert.Nil(t, err)
+
+	a, err = store.GetAutomation(plan.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.AutomationStatusActive, a.Status)
+	assert.Len(t, a.Steps, 2)
+	assert.Equal(t, entities.AutomationStepCondition, a.Steps[0].Type)
+
+	// creating a subscriber enrolls it in the welcome automation
+	sub := &entities.Subscriber{Email: "jane@example.com", UserID: 1, Active: true, MetaJSON: entities.JSON(`{"plan":"free"}`)}
+	err = store.CreateSubscriber(sub)
+	assert.Nil(t, err)
+
+	// joining the segment and changing the plan enrolls it in the segment and metadata automations
+	sub.Segments = []entities.Segment{*seg}
+	sub.MetaJSON = entities.JSON(`{"plan":"pro"}`)
+	err = store.UpdateSubscriber(sub)
+	assert.Nil(t, err)
+
+	// clicking another link of the campaign is ignored
+	err = store.CreateClick(&entities.Click{UserID: 1, CampaignID: campaignID, Recipient: sub.Email, Link: "https://example.com"})
+	assert.Nil(t, err)
+	err = store.CreateClick(&entities.Click{UserID: 1, CampaignID: campaignID, Recipient: sub.Email, Link: "https://example.com/pricing"})
+	assert.Nil(t, err)
+
+	// the subscriber goes through an automation only once
+	err = store.CreateClick(&entities.Click{UserID: 1, CampaignID: campaignID, Recipient: sub.Email, Link: "https://example.com/pricing"})
+	assert.Nil(t, err)
+
+	due, err := store.GetDueAutomationRuns(time.Now().UTC().Add(time.Second), 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 4)
+
+	for _, id := range []int64{welcome.ID, joined.ID, clicked.ID, plan.ID} {
+		p := NewPaginationCursor("/api/automations/runs", 10)
+		err = store.GetAutomationRuns(id, 1, p)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), p.Total)
+	}
+
+	r := due[0]
+	r.Position = 1
+	r.EventID = ksuid.New()
+	r.NextRunAt.SetValid(time.Now().UTC().Add(time.Hour))
+	err = store.UpdateAutomationRun(&r)
+	assert.Nil(t, err)
+
+	// pausing an automation pauses the runs of its subscribers
+	joined.Status = entities.AutomationStatusPaused
+	err = store.UpdateAutomation(joined)
+	assert.Nil(t, err)
+
+	due, err = store.GetDueAutomationRuns(time.Now().UTC().Add(time.Second), 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 2)
+
+	messageID := "message-id"
+	err = store.CreateAutomationLog(&entities.AutomationLog{
+		UserID:       1,
+		AutomationID: welcome.ID,
+		StepID:       welcome.Steps[0].ID,
+		SubscriberID: sub.ID,
+		Status:       entities.AutomationLogStatusSent,
+		MessageID:    &messageID,
+	})
+	assert.Nil(t, err)
+
+	p := NewPaginationCursor("/api/automations/logs", 10)
+	err = store.GetAutomationLogs(welcome.ID, 1, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), p.Total)
+
+	err = store.DeleteAutomation(welcome.ID, 1)
+	assert.Nil(t, err)
+
+	_, err = store.GetAutomation(welcome.ID, 1)
+	assert.NotNil(t, err)
+
+	p = NewPaginationCursor("/api/automations/runs", 10)
+	err = store.GetAutomationRuns(welcome.ID, 1, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), p.Total)
+}
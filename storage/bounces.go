@@ -4,6 +4,12 @@ import (
 	"github.com/mailbadger/app/entities"
 )
 
+// CreateBounce creates a new bounce and queues the webhook deliveries of the event, the event
+// depends on whether the bounced email was sent by a campaign or an automation.
 func (db *store) CreateBounce(b *entities.Bounce) error {
-	return db.Create(b).Error
+	event := entities.WebhookEventCampaignBounce
+	if b.AutomationID != nil {
+		event = entities.WebhookEventAutomationBounce
+	}
+	return db.createWithWebhookEvent(b, b.UserID, event)
 }
@@ -57,3 +57,36 @@ func TestBounces(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, int64(2), totalBounces)
 }
+
+func TestAutomationBounces(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	err := store.CreateWebhook(&entities.Webhook{
+		UserID: 1,
+		URL:    "https://example.com/hook",
+		Secret: "secret",
+		Events: entities.JSON(`["automation.bounce","automation.complaint"]`),
+		Active: true,
+	})
+	assert.Nil(t, err)
+
+	automationID := int64(2)
+	b := &entities.Bounce{UserID: 1, AutomationID: &automationID, Recipient: "jane@example.com", Type: entities.BounceTypePermanent}
+	err = store.CreateBounce(b)
+	assert.Nil(t, err)
+
+	err = store.CreateComplaint(&entities.Complaint{UserID: 1, AutomationID: &automationID, Recipient: "john@example.com"})
+	assert.Nil(t, err)
+
+	// the bounces of the automations are not counted in the campaign stats
+	total, err := store.GetTotalBounces(0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+
+	due, err := store.GetDueWebhookDeliveries(time.Now().UTC().Add(time.Second), 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 2)
+	assert.Equal(t, entities.WebhookEventAutomationBounce, due[0].Event)
+	assert.Equal(t, entities.WebhookEventAutomationComplaint, due[1].Event)
+}
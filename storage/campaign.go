@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"github.com/jinzhu/now"
@@ -30,6 +32,28 @@ func (db *store) GetCampaigns(userID int64, p *PaginationCursor, scopeMap map[st
 	return db.Paginate(p, userID)
 }
 
+// GetCampaignInstances fetches the instances sent by the recurring campaign, and populates the pagination obj
+func (db *store) GetCampaignInstances(parentID, userID int64, p *PaginationCursor) error {
+	p.SetCollection(new([]entities.Campaign))
+	p.SetResource("campaigns")
+	p.SetScopes(NotDeleted, BelongsToUser(userID), ChildOf(parentID))
+
+	query := db.Table(p.Resource).Preload("BaseTemplate").
+		Order("created_at desc, id desc").
+		Limit(p.PerPage)
+
+	p.SetQuery(query)
+
+	return db.Paginate(p, userID)
+}
+
+// ChildOf applies a scope for the instances of the recurring campaign.
+func ChildOf(parentID int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("parent_id = ?", parentID)
+	}
+}
+
 // GetMonthlyTotalCampaigns fetches the total count by user id in the current month
 func (db *store) GetMonthlyTotalCampaigns(userID int64) (int64, error) {
 	var count int64
@@ -68,6 +92,25 @@ func (db *store) UpdateCampaign(c *entities.Campaign) error {
 	return db.Where("id = ? and user_id = ?", c.ID, c.UserID).Save(c).Error
 }
 
+// UpdateCampaignStatus sets the status of the campaign only if its current status is one of the given
+// statuses, it returns false when the campaign is not updated. The completed at timestamp is set
+// when the campaign is sent or cancelled.
+func (db *store) UpdateCampaignStatus(id, userID int64, status string, from ...string) (bool, error) {
+	updates := map[string]interface{}{
+		"status":     status,
+		"updated_at": time.Now().UTC(),
+	}
+	if status == entities.StatusSent || status == entities.StatusCancelled {
+		updates["completed_at"] = time.Now().UTC()
+	}
+
+	res := db.Model(&entities.Campaign{}).
+		Where("id = ? AND user_id = ? AND status IN (?)", id, userID, from).
+		Updates(updates)
+
+	return res.RowsAffected > 0, res.Error
+}
+
 // DeleteCampaign deletes an existing campaign from the database.
 func (db *store) DeleteCampaign(id, userID int64) error {
 	return db.Where("user_id = ?", userID).Delete(entities.Campaign{Model: entities.Model{ID: id}}).Error
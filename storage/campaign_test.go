@@ -58,6 +58,20 @@ func TestCampaign(t *testing.T) {
 	assert.True(t, campaign.CompletedAt.Valid)
 	assert.Equal(t, campaign.CompletedAt.Time, now)
 
+	//Test update campaign status
+	ok, err := store.UpdateCampaignStatus(campaign.ID, 1, entities.StatusPaused, entities.StatusSending)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.UpdateCampaignStatus(campaign.ID, 1, entities.StatusCancelled, entities.StatusDraft, entities.StatusPaused)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	campaign, err = store.GetCampaign(campaign.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.StatusCancelled, campaign.Status)
+	assert.True(t, campaign.CompletedAt.Valid)
+
 	//Test get campaigns
 	p := NewPaginationCursor("/api/campaigns", 13)
 	for i := 0; i < 10; i++ {
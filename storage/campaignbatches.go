@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/segmentio/ksuid"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// CreateCampaignBatches creates the batches of subscribers of the campaign.
+func (db *store) CreateCampaignBatches(batches []entities.CampaignBatch) error {
+	if len(batches) == 0 {
+		return nil
+	}
+	return db.Create(&batches).Error
+}
+
+// GetCampaignBatch returns the campaign batch by the given id.
+func (db *store) GetCampaignBatch(id int64) (*entities.CampaignBatch, error) {
+	var b = new(entities.CampaignBatch)
+	err := db.Where("id = ?", id).First(b).Error
+	return b, err
+}
+
+// GetCampaignBatches returns the batches of the campaign event ordered by their release time.
+func (db *store) GetCampaignBatches(eventID ksuid.KSUID, userID int64) ([]entities.CampaignBatch, error) {
+	var batches []entities.CampaignBatch
+	err := db.Where("event_id = ? AND user_id = ?", eventID, userID).
+		Order("release_at, id").
+		Find(&batches).Error
+	return batches, err
+}
+
+// GetDueCampaignBatches returns the pending batches which are due for release, the batches
+// of the campaigns which are paused or cancelled are not released.
+func (db *store) GetDueCampaignBatches(now time.Time, limit int) ([]entities.CampaignBatch, error) {
+	var batches []entities.CampaignBatch
+	err := db.Joins("JOIN campaigns ON campaigns.id = campaign_batches.campaign_id").
+		Where("campaign_batches.status = ? AND campaign_batches.release_at <= ? AND campaigns.status = ?",
+			entities.CampaignBatchStatusPending, now, entities.StatusSending).
+		Order("campaign_batches.release_at").
+		Limit(limit).
+		Find(&batches).Error
+	return batches, err
+}
+
+// UpdateCampaignBatchStatus sets the status of the batch only if its current status is the
+// given old status. It returns false if the status was changed in the meantime.
+func (db *store) UpdateCampaignBatchStatus(id int64, status, oldStatus string) (bool, error) {
+	res := db.Model(&entities.CampaignBatch{}).
+		Where("id = ? AND status = ?", id, oldStatus).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"updated_at": time.Now().UTC(),
+		})
+
+	return res.RowsAffected > 0, res.Error
+}
+
+// CountUnsentCampaignBatches returns the number of batches of the campaign event which are not sent yet.
+func (db *store) CountUnsentCampaignBatches(eventID ksuid.KSUID) (int64, error) {
+	var count int64
+	err := db.Model(&entities.CampaignBatch{}).
+		Where("event_id = ? AND status <> ?", eventID, entities.CampaignBatchStatusSent).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestCampaignBatches(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	now := time.Now().UTC()
+	eventID := ksuid.New()
+
+	campaign := &entities.Campaign{UserID: 1, Name: "local time", Status: entities.StatusSending, EventID: &eventID}
+	err := store.CreateCampaign(campaign)
+	assert.Nil(t, err)
+
+	batches := []entities.CampaignBatch{
+		{
+			UserID:            1,
+			CampaignID:        campaign.ID,
+			EventID:           eventID,
+			Timezone:          "Asia/Tokyo",
+			ReleaseAt:         now.Add(-time.Minute),
+			Status:            entities.CampaignBatchStatusPending,
+			Size:              2,
+			SubscriberIDsJSON: entities.JSON(`[1,2]`),
+		},
+		{
+			UserID:            1,
+			CampaignID:        campaign.ID,
+			EventID:           eventID,
+			Timezone:          "America/New_York",
+			ReleaseAt:         now.Add(10 * time.Hour),
+			Status:            entities.CampaignBatchStatusPending,
+			Size:              1,
+			SubscriberIDsJSON: entities.JSON(`[3]`),
+		},
+	}
+	err = store.CreateCampaignBatches(batches)
+	assert.Nil(t, err)
+
+	res, err := store.GetCampaignBatches(eventID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "Asia/Tokyo", res[0].Timezone)
+
+	due, err := store.GetDueCampaignBatches(now, 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 1)
+
+	b, err := store.GetCampaignBatch(due[0].ID)
+	assert.Nil(t, err)
+	ids, err := b.GetSubscriberIDs()
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	// the batch is released only once
+	ok, err := store.UpdateCampaignBatchStatus(b.ID, entities.CampaignBatchStatusReleased, entities.CampaignBatchStatusPending)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = store.UpdateCampaignBatchStatus(b.ID, entities.CampaignBatchStatusReleased, entities.CampaignBatchStatusPending)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	_, err = store.UpdateCampaignBatchStatus(b.ID, entities.CampaignBatchStatusSent, entities.CampaignBatchStatusReleased)
+	assert.Nil(t, err)
+
+	count, err := store.CountUnsentCampaignBatches(eventID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// the batches of a paused campaign are not released
+	_, err = store.UpdateCampaignStatus(campaign.ID, 1, entities.StatusPaused, entities.StatusSending)
+	assert.Nil(t, err)
+
+	due, err = store.GetDueCampaignBatches(now.Add(11*time.Hour), 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 0)
+}
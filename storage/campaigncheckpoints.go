@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"github.com/segmentio/ksuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetCampaignCheckpoint returns the campaign checkpoint by the given event id.
+func (db *store) GetCampaignCheckpoint(eventID ksuid.KSUID) (*entities.CampaignCheckpoint, error) {
+	var cp = new(entities.CampaignCheckpoint)
+	err := db.Where("event_id = ?", eventID).First(cp).Error
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// SaveCampaignCheckpoint creates or updates the campaign checkpoint.
+func (db *store) SaveCampaignCheckpoint(cp *entities.CampaignCheckpoint) error {
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "event_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"last_subscriber_id",
+			"last_created_at",
+			"paused",
+			"batched",
+			"enqueued",
+			"failed",
+			"params",
+			"updated_at",
+		}),
+	}).Create(cp).Error
+}
+
+// ResumeCampaignCheckpoint unsets the paused flag of the checkpoint. It returns false if the
+// checkpoint is not paused, this way the paused campaign can be resumed only once.
+func (db *store) ResumeCampaignCheckpoint(eventID ksuid.KSUID) (bool, error) {
+	res := db.Model(&entities.CampaignCheckpoint{}).
+		Where("event_id = ? AND paused = ?", eventID, true).
+		Update("paused", false)
+
+	return res.RowsAffected > 0, res.Error
+}
+
+// PauseCampaignCheckpoint sets the paused flag of the checkpoint.
+func (db *store) PauseCampaignCheckpoint(eventID ksuid.KSUID) error {
+	return db.Model(&entities.CampaignCheckpoint{}).
+		Where("event_id = ?", eventID).
+		Update("paused", true).Error
+}
+
+// IncrementCampaignCheckpoint adds the number of enqueued and failed subscribers of a released batch
+// to the counters of the checkpoint.
+func (db *store) IncrementCampaignCheckpoint(eventID ksuid.KSUID, enqueued, failed int64) error {
+	return db.Model(&entities.CampaignCheckpoint{}).
+		Where("event_id = ?", eventID).
+		Updates(map[string]interface{}{
+			"enqueued": gorm.Expr("enqueued + ?", enqueued),
+			"failed":   gorm.Expr("failed + ?", failed),
+		}).Error
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestCampaignCheckpoint(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	eventID := ksuid.New()
+
+	_, err := store.GetCampaignCheckpoint(eventID)
+	assert.NotNil(t, err)
+
+	now := time.Now().UTC()
+	cp := &entities.CampaignCheckpoint{
+		EventID:          eventID,
+		UserID:           1,
+		CampaignID:       1,
+		LastSubscriberID: 10,
+		LastCreatedAt:    now,
+		Params:           entities.JSON(`{"campaign_id":1}`),
+	}
+
+	err = store.SaveCampaignCheckpoint(cp)
+	assert.Nil(t, err)
+
+	// the checkpoint can't be resumed if it's not paused
+	ok, err := store.ResumeCampaignCheckpoint(eventID)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	cp.LastSubscriberID = 20
+	cp.Paused = true
+	cp.Enqueued = 20
+	cp.Failed = 1
+	err = store.SaveCampaignCheckpoint(cp)
+	assert.Nil(t, err)
+
+	cp, err = store.GetCampaignCheckpoint(eventID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), cp.LastSubscriberID)
+	assert.True(t, cp.Paused)
+	assert.Equal(t, int64(20), cp.Enqueued)
+	assert.Equal(t, int64(1), cp.Failed)
+	assert.Equal(t, now.Unix(), cp.LastCreatedAt.Unix())
+	assert.JSONEq(t, `{"campaign_id":1}`, string(cp.Params))
+
+	ok, err = store.ResumeCampaignCheckpoint(eventID)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.ResumeCampaignCheckpoint(eventID)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	err = store.IncrementCampaignCheckpoint(eventID, 5, 2)
+	assert.Nil(t, err)
+
+	cp, err = store.GetCampaignCheckpoint(eventID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(25), cp.Enqueued)
+	assert.Equal(t, int64(3), cp.Failed)
+}
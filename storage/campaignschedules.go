@@ -81,3 +81,36 @@ func (db *store) GetScheduledCampaigns(time time.Time) ([]entities.CampaignSched
 	}
 	return campaignsSchedule, err
 }
+
+// UpdateCampaignSchedule saves the progress of the recurring schedule, the time of its
+// next run, the publish date of the last sent feed item and the seen feed items.
+func (db *store) UpdateCampaignSchedule(c *entities.CampaignSchedule) error {
+	c.UpdatedAt = time.Now().UTC()
+	return db.Model(c).Select("scheduled_at", "last_run_at", "feed_last_item_at", "feed_seen_items", "updated_at").Updates(c).Error
+}
+
+// CreateCampaignInstance creates the instance of the recurring campaign and moves the schedule
+// to its next run in a single transaction, so the occurrence is not sent twice.
+func (db *store) CreateCampaignInstance(c *entities.Campaign, s *entities.CampaignSchedule) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Create(c).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: create campaign instance: %w", err)
+	}
+
+	s.UpdatedAt = time.Now().UTC()
+	err = tx.Model(s).Select("scheduled_at", "last_run_at", "feed_last_item_at", "feed_seen_items", "updated_at").Updates(s).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: update campaign schedule: %w", err)
+	}
+
+	return tx.Commit().Error
+}
@@ -118,3 +118,86 @@ func TestScheduledCampaign(t *testing.T) {
 	assert.Equal(t, cam[0].Name, fetchedCampaign.Name)
 	assert.Equal(t, entities.StatusDraft, fetchedCampaign.Status)
 }
+
+func TestRecurringCampaign(t *testing.T) {
+	db := openTestDb()
+
+	store := From(db)
+
+	parent := &entities.Campaign{
+		UserID:     1,
+		Name:       "weekly digest",
+		TemplateID: 1,
+		Status:     entities.StatusDraft,
+		TrackOpens: true,
+	}
+	err := store.CreateCampaign(parent)
+	assert.Nil(t, err)
+
+	now := time.Now().UTC()
+	cs := &entities.CampaignSchedule{
+		ID:          ksuid.New(),
+		UserID:      1,
+		CampaignID:  parent.ID,
+		ScheduledAt: now,
+		Source:      "bla@email.com",
+		FromName:    "from name",
+		Recurrence:  "0 9 * * 1",
+		Timezone:    "Europe/Skopje",
+		FeedURL:     "https://example.com/feed.xml",
+	}
+	err = store.CreateCampaignSchedule(cs)
+	assert.Nil(t, err)
+
+	campSch, err := store.GetScheduledCampaigns(now)
+	assert.Nil(t, err)
+	assert.Len(t, campSch, 1)
+	assert.True(t, campSch[0].IsRecurring())
+	assert.Equal(t, "https://example.com/feed.xml", campSch[0].FeedURL)
+
+	next, err := campSch[0].NextRun(now)
+	assert.Nil(t, err)
+	assert.True(t, next.After(now))
+
+	sch := &campSch[0]
+	sch.ScheduledAt = next
+	sch.LastRunAt.SetValid(now)
+	sch.FeedLastItemAt.SetValid(now.Add(-time.Hour))
+
+	instance := parent.NewInstance("weekly digest (1)")
+	err = store.CreateCampaignInstance(instance, sch)
+	assert.Nil(t, err)
+
+	// the parent stays scheduled until the next run
+	campSch, err = store.GetScheduledCampaigns(now)
+	assert.Nil(t, err)
+	assert.Len(t, campSch, 0)
+
+	campSch, err = store.GetScheduledCampaigns(next)
+	assert.Nil(t, err)
+	assert.Len(t, campSch, 1)
+	assert.True(t, campSch[0].LastRunAt.Valid)
+	assert.True(t, campSch[0].FeedLastItemAt.Valid)
+
+	fetched, err := store.GetCampaign(instance.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, parent.ID, *fetched.ParentID)
+	assert.Equal(t, entities.StatusSending, fetched.Status)
+	assert.True(t, fetched.TrackOpens)
+	assert.Equal(t, *instance.EventID, *fetched.EventID)
+
+	p := NewPaginationCursor("/api/campaigns/1/instances", 10)
+	err = store.GetCampaignInstances(parent.ID, 1, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), p.Total)
+
+	// skipping a run only moves the schedule
+	sch = &campSch[0]
+	sch.ScheduledAt = next.AddDate(0, 0, 7)
+	err = store.UpdateCampaignSchedule(sch)
+	assert.Nil(t, err)
+
+	campSch, err = store.GetScheduledCampaigns(next)
+	assert.Nil(t, err)
+	assert.Len(t, campSch, 0)
+}
@@ -1,9 +1,17 @@
 package storage
 
-import "github.com/mailbadger/app/entities"
+import (
+	"gorm.io/gorm"
 
+	"github.com/mailbadger/app/entities"
+)
+
+// CreateClick creates a new click and queues the webhook deliveries of the event,
+// the subscriber is enrolled in the automations triggered by the click.
 func (db *store) CreateClick(c *entities.Click) error {
-	return db.Create(c).Error
+	return db.createWithWebhookEvent(c, c.UserID, entities.WebhookEventCampaignClick, func(tx *gorm.DB) error {
+		return enrollClickedSubscriber(tx, c)
+	})
 }
 
 // GetCampaignClicksStats fetches collection of clicks stats by campaign id and user id from database
@@ -4,6 +4,12 @@ import (
 	"github.com/mailbadger/app/entities"
 )
 
+// CreateComplaint creates a new complaint and queues the webhook deliveries of the event, the event
+// depends on whether the email was sent by a campaign or an automation.
 func (db *store) CreateComplaint(c *entities.Complaint) error {
-	return db.Create(c).Error
+	event := entities.WebhookEventCampaignComplaint
+	if c.AutomationID != nil {
+		event = entities.WebhookEventAutomationComplaint
+	}
+	return db.createWithWebhookEvent(c, c.UserID, event)
 }
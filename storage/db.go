@@ -2,6 +2,7 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/mailbadger/app/entities"
 	_ "github.com/mailbadger/app/statik"
 	"github.com/mailbadger/app/utils"
+	sqlite3 "github.com/mattn/go-sqlite3"
 	"github.com/rakyll/statik/fs"
 	migrate "github.com/rubenv/sql-migrate"
 	log "github.com/sirupsen/logrus"
@@ -20,6 +22,47 @@ import (
 	"gorm.io/gorm"
 )
 
+// sqliteDriver is the sqlite3 driver extended with the functions used by the queries.
+const sqliteDriver = "sqlite3_mailbadger"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("metadata_value", metadataValue, true)
+		},
+	})
+}
+
+// metadataValue returns the value of the key from the subscriber's metadata json,
+// it returns nil (NULL) if the key doesn't exist. It is used because sqlite
+// is not built with the json1 extension.
+func metadataValue(metadata interface{}, key string) []byte {
+	var raw []byte
+	switch v := metadata.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil
+	}
+
+	switch v := m[key].(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(v)
+	default:
+		b, _ := json.Marshal(v)
+		return b
+	}
+}
+
 // store implements the Storage interface
 type store struct {
 	*gorm.DB
@@ -42,7 +85,7 @@ func openDbConn(driver, dsn string) *gorm.DB {
 	if driver == "mysql" {
 		dialect = mysql.Open(dsn)
 	} else {
-		dialect = sqlite.Open(dsn)
+		dialect = sqlite.Dialector{DriverName: sqliteDriver, DSN: dsn}
 	}
 
 	conf := &gorm.Config{}
@@ -140,6 +183,11 @@ func setupDb(driver, config string, fresh bool, db *gorm.DB) error {
 		return err
 	}
 
+	err = hashLegacyAPIKeys(db)
+	if err != nil {
+		return err
+	}
+
 	// If the database didn't exist, initialize it with an admin user
 	if fresh {
 		err = initDb(config, db)
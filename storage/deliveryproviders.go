@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"github.com/mailbadger/app/entities"
+)
+
+// GetDeliveryProvider returns the delivery provider by the given user id
+func (db *store) GetDeliveryProvider(userID int64) (*entities.DeliveryProvider, error) {
+	var p = new(entities.DeliveryProvider)
+	err := db.Where("user_id = ?", userID).First(p).Error
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetDeliveryProviderByID returns the delivery provider by the given id and user id.
+func (db *store) GetDeliveryProviderByID(id, userID int64) (*entities.DeliveryProvider, error) {
+	var p = new(entities.DeliveryProvider)
+	err := db.Where("id = ? AND user_id = ?", id, userID).First(p).Error
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SaveDeliveryProvider creates or updates the delivery provider.
+func (db *store) SaveDeliveryProvider(p *entities.DeliveryProvider) error {
+	return db.Save(p).Error
+}
+
+// DeleteDeliveryProvider deletes the delivery provider by the given user id.
+func (db *store) DeleteDeliveryProvider(userID int64) error {
+	return db.Where("user_id = ?", userID).Delete(&entities.DeliveryProvider{}).Error
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestDeliveryProvider(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	_, err := store.GetDeliveryProvider(1)
+	assert.NotNil(t, err)
+
+	p := &entities.DeliveryProvider{
+		UserID:   1,
+		Type:     entities.DeliveryProviderSMTP,
+		Host:     "smtp.example.com",
+		Port:     587,
+		Username: "foo",
+		Password: "bar",
+	}
+
+	err = store.SaveDeliveryProvider(p)
+	assert.Nil(t, err)
+
+	p, err = store.GetDeliveryProvider(1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.DeliveryProviderSMTP, p.Type)
+	assert.Equal(t, "smtp.example.com", p.Host)
+	assert.Equal(t, 587, p.Port)
+
+	p.Type = entities.DeliveryProviderPostmark
+	p.APIKey = "token"
+	err = store.SaveDeliveryProvider(p)
+	assert.Nil(t, err)
+
+	p, err = store.GetDeliveryProvider(1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.DeliveryProviderPostmark, p.Type)
+	assert.Equal(t, "token", p.APIKey)
+
+	byID, err := store.GetDeliveryProviderByID(p.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "token", byID.APIKey)
+
+	_, err = store.GetDeliveryProviderByID(p.ID, 2)
+	assert.NotNil(t, err)
+
+	err = store.DeleteDeliveryProvider(1)
+	assert.Nil(t, err)
+
+	p, err = store.GetDeliveryProvider(1)
+	assert.NotNil(t, err)
+	assert.Nil(t, p)
+}
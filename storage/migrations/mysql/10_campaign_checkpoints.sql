@@ -0,0 +1,19 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `campaign_checkpoints` (
+    `event_id`           varbinary(27)    primary key,
+    `user_id`            integer unsigned NOT NULL,
+    `campaign_id`        integer unsigned NOT NULL,
+    `last_subscriber_id` integer unsigned NOT NULL DEFAULT 0,
+    `last_created_at`    datetime(6),
+    `paused`             tinyint(1)       NOT NULL DEFAULT 0,
+    `params`             JSON,
+    `created_at`         datetime(6)      NOT NULL,
+    `updated_at`         datetime(6)      NOT NULL,
+    INDEX idx_user_id (`user_id`),
+    FOREIGN KEY (`campaign_id`) REFERENCES campaigns (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `campaign_checkpoints`;
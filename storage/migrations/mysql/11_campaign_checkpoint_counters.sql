@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE `campaign_checkpoints`
+    ADD COLUMN `enqueued` integer unsigned NOT NULL DEFAULT 0 AFTER `paused`,
+    ADD COLUMN `failed`   integer unsigned NOT NULL DEFAULT 0 AFTER `enqueued`;
+
+-- +migrate Down
+
+ALTER TABLE `campaign_checkpoints`
+    DROP COLUMN `failed`,
+    DROP COLUMN `enqueued`;
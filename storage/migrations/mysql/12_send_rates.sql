@@ -0,0 +1,19 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `send_rates` (
+    `user_id`          integer unsigned PRIMARY KEY,
+    `max_send_rate`    decimal(10, 2) NOT NULL DEFAULT 0,
+    `quota_send_rate`  decimal(10, 2) NOT NULL DEFAULT 0,
+    `quota_updated_at` datetime(6),
+    `created_at`       datetime(6)    NOT NULL,
+    `updated_at`       datetime(6)    NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE INDEX idx_user_id_created_at ON `send_logs` (`user_id`, `created_at`);
+
+-- +migrate Down
+
+DROP INDEX idx_user_id_created_at ON `send_logs`;
+
+DROP TABLE `send_rates`;
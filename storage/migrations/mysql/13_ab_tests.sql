@@ -0,0 +1,52 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `campaign_ab_tests` (
+    `campaign_id`       integer unsigned PRIMARY KEY,
+    `user_id`           integer unsigned NOT NULL,
+    `test_percentage`   integer unsigned NOT NULL,
+    `wait_minutes`      integer unsigned NOT NULL,
+    `winner_metric`     varchar(20)      NOT NULL,
+    `winner_variant_id` integer unsigned,
+    `status`            varchar(20)      NOT NULL,
+    `pick_winner_at`    datetime(6),
+    `params`            JSON,
+    `created_at`        datetime(6)      NOT NULL,
+    `updated_at`        datetime(6)      NOT NULL,
+    INDEX idx_user_id (`user_id`),
+    INDEX idx_status_pick_winner_at (`status`, `pick_winner_at`),
+    FOREIGN KEY (`campaign_id`) REFERENCES campaigns (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `campaign_variants` (
+    `id`           integer unsigned PRIMARY KEY AUTO_INCREMENT NOT NULL,
+    `user_id`      integer unsigned NOT NULL,
+    `campaign_id`  integer unsigned NOT NULL,
+    `name`         varchar(191)     NOT NULL,
+    `subject_part` varchar(191),
+    `template_id`  integer unsigned,
+    `created_at`   datetime(6)      NOT NULL,
+    `updated_at`   datetime(6)      NOT NULL,
+    INDEX idx_user_id (`user_id`),
+    FOREIGN KEY (`campaign_id`) REFERENCES campaigns (`id`),
+    FOREIGN KEY (`template_id`) REFERENCES templates (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+ALTER TABLE `sends` ADD COLUMN `variant_id` integer unsigned AFTER `campaign_id`;
+ALTER TABLE `deliveries` ADD COLUMN `variant_id` integer unsigned AFTER `campaign_id`;
+ALTER TABLE `opens` ADD COLUMN `variant_id` integer unsigned AFTER `campaign_id`;
+ALTER TABLE `clicks` ADD COLUMN `variant_id` integer unsigned AFTER `campaign_id`;
+ALTER TABLE `bounces` ADD COLUMN `variant_id` integer unsigned AFTER `campaign_id`;
+ALTER TABLE `complaints` ADD COLUMN `variant_id` integer unsigned AFTER `campaign_id`;
+
+-- +migrate Down
+
+ALTER TABLE `complaints` DROP COLUMN `variant_id`;
+ALTER TABLE `bounces` DROP COLUMN `variant_id`;
+ALTER TABLE `clicks` DROP COLUMN `variant_id`;
+ALTER TABLE `opens` DROP COLUMN `variant_id`;
+ALTER TABLE `deliveries` DROP COLUMN `variant_id`;
+ALTER TABLE `sends` DROP COLUMN `variant_id`;
+
+DROP TABLE `campaign_variants`;
+
+DROP TABLE `campaign_ab_tests`;
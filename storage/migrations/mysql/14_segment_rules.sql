@@ -0,0 +1,7 @@
+-- +migrate Up
+
+ALTER TABLE `segments` ADD COLUMN `rules` JSON;
+
+-- +migrate Down
+
+ALTER TABLE `segments` DROP COLUMN `rules`;
@@ -0,0 +1,37 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `signup_forms` (
+    `user_id`             integer unsigned PRIMARY KEY,
+    `enabled`             tinyint(1) NOT NULL DEFAULT 0,
+    `recaptcha`           tinyint(1) NOT NULL DEFAULT 0,
+    `confirm_template_id` integer unsigned,
+    `success_template_id` integer unsigned,
+    `segment_ids`         JSON,
+    `created_at`          datetime(6) NOT NULL,
+    `updated_at`          datetime(6) NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `subscriber_consents` (
+    `id`            integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`       integer unsigned NOT NULL,
+    `subscriber_id` integer unsigned NOT NULL,
+    `token_id`      integer unsigned NOT NULL,
+    `segment_ids`   JSON,
+    `signup_ip`     varchar(45)  NOT NULL,
+    `user_agent`    varchar(191) NOT NULL,
+    `confirm_ip`    varchar(45)  NOT NULL DEFAULT '',
+    `confirmed_at`  datetime(6),
+    `created_at`    datetime(6)  NOT NULL,
+    `updated_at`    datetime(6)  NOT NULL,
+    INDEX idx_subscriber_id (`subscriber_id`),
+    INDEX idx_token_id (`token_id`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    FOREIGN KEY (`subscriber_id`) REFERENCES subscribers (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `subscriber_consents`;
+
+DROP TABLE `signup_forms`;
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+ALTER TABLE `subscribers` ADD COLUMN `paused_until` datetime(6);
+
+CREATE TABLE IF NOT EXISTS `segment_opt_outs` (
+    `segment_id`    integer unsigned NOT NULL,
+    `subscriber_id` integer unsigned NOT NULL,
+    `created_at`    datetime(6)      NOT NULL,
+    PRIMARY KEY (`segment_id`, `subscriber_id`),
+    INDEX idx_subscriber_id (`subscriber_id`),
+    FOREIGN KEY (`segment_id`) REFERENCES segments (`id`),
+    FOREIGN KEY (`subscriber_id`) REFERENCES subscribers (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `segment_opt_outs`;
+
+ALTER TABLE `subscribers` DROP COLUMN `paused_until`;
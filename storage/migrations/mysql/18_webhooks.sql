@@ -0,0 +1,51 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `webhooks` (
+    `id`         integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`    integer unsigned NOT NULL,
+    `url`        varchar(2048) NOT NULL,
+    `secret`     varchar(191)  NOT NULL,
+    `events`     JSON,
+    `active`     tinyint(1)    NOT NULL DEFAULT 1,
+    `created_at` datetime(6)   NOT NULL,
+    `updated_at` datetime(6)   NOT NULL,
+    INDEX idx_user_id (`user_id`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+    `id`              integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`         integer unsigned NOT NULL,
+    `webhook_id`      integer unsigned NOT NULL,
+    `event`           varchar(191) NOT NULL,
+    `payload`         JSON,
+    `status`          varchar(191) NOT NULL,
+    `attempts`        integer unsigned NOT NULL DEFAULT 0,
+    `next_attempt_at` datetime(6),
+    `created_at`      datetime(6)  NOT NULL,
+    `updated_at`      datetime(6)  NOT NULL,
+    INDEX idx_webhook_id (`webhook_id`),
+    INDEX idx_status_next_attempt_at (`status`, `next_attempt_at`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    FOREIGN KEY (`webhook_id`) REFERENCES webhooks (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `webhook_delivery_attempts` (
+    `id`            integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `delivery_id`   integer unsigned NOT NULL,
+    `status_code`   integer unsigned NOT NULL DEFAULT 0,
+    `response_body` text,
+    `error`         text,
+    `duration_ms`   integer unsigned NOT NULL DEFAULT 0,
+    `created_at`    datetime(6) NOT NULL,
+    INDEX idx_delivery_id (`delivery_id`),
+    FOREIGN KEY (`delivery_id`) REFERENCES webhook_deliveries (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `webhook_delivery_attempts`;
+
+DROP TABLE `webhook_deliveries`;
+
+DROP TABLE `webhooks`;
@@ -0,0 +1,34 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `transactional_messages` (
+    `id`                  integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`             integer unsigned NOT NULL,
+    `message_id`          varchar(191) NOT NULL,
+    `idempotency_key`     varchar(191),
+    `template_id`         integer unsigned NOT NULL,
+    `recipient`           varchar(191) NOT NULL,
+    `status`              varchar(191) NOT NULL,
+    `provider_message_id` varchar(191),
+    `description`         text,
+    `created_at`          datetime(6)  NOT NULL,
+    `updated_at`          datetime(6)  NOT NULL,
+    UNIQUE INDEX idx_message_id (`message_id`),
+    UNIQUE INDEX idx_user_id_idempotency_key (`user_id`, `idempotency_key`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `transactional_events` (
+    `id`                       integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `transactional_message_id` integer unsigned NOT NULL,
+    `type`                     varchar(191) NOT NULL,
+    `description`              text,
+    `created_at`               datetime(6) NOT NULL,
+    INDEX idx_transactional_message_id (`transactional_message_id`),
+    FOREIGN KEY (`transactional_message_id`) REFERENCES transactional_messages (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `transactional_events`;
+
+DROP TABLE `transactional_messages`;
@@ -0,0 +1,80 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `automations` (
+    `id`                   integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`              integer unsigned NOT NULL,
+    `name`                 varchar(191)  NOT NULL,
+    `status`               varchar(191)  NOT NULL,
+    `trigger_type`         varchar(191)  NOT NULL,
+    `trigger_segment_id`   integer unsigned,
+    `trigger_campaign_id`  integer unsigned,
+    `trigger_link`         varchar(2048) NOT NULL DEFAULT '',
+    `trigger_metadata_key` varchar(191)  NOT NULL DEFAULT '',
+    `created_at`           datetime(6)   NOT NULL,
+    `updated_at`           datetime(6)   NOT NULL,
+    INDEX idx_user_id_status_trigger_type (`user_id`, `status`, `trigger_type`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `automation_steps` (
+    `id`                   integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `automation_id`        integer unsigned NOT NULL,
+    `position`             integer unsigned NOT NULL,
+    `type`                 varchar(191) NOT NULL,
+    `delay_minutes`        integer unsigned NOT NULL DEFAULT 0,
+    `condition_type`       varchar(191) NOT NULL DEFAULT '',
+    `condition_segment_id` integer unsigned,
+    `condition_key`        varchar(191) NOT NULL DEFAULT '',
+    `condition_value`      varchar(191) NOT NULL DEFAULT '',
+    `template_id`          integer unsigned,
+    `source`               varchar(191) NOT NULL DEFAULT '',
+    `from_name`            varchar(191) NOT NULL DEFAULT '',
+    `template_data`        JSON,
+    `created_at`           datetime(6)  NOT NULL,
+    `updated_at`           datetime(6)  NOT NULL,
+    INDEX idx_automation_id (`automation_id`),
+    FOREIGN KEY (`automation_id`) REFERENCES automations (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `automation_runs` (
+    `id`            integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`       integer unsigned NOT NULL,
+    `automation_id` integer unsigned NOT NULL,
+    `subscriber_id` integer unsigned NOT NULL,
+    `status`        varchar(191) NOT NULL,
+    `position`      integer unsigned NOT NULL DEFAULT 0,
+    `event_id`      varchar(27),
+    `next_run_at`   datetime(6),
+    `description`   varchar(191) NOT NULL DEFAULT '',
+    `created_at`    datetime(6)  NOT NULL,
+    `updated_at`    datetime(6)  NOT NULL,
+    UNIQUE INDEX idx_automation_id_subscriber_id (`automation_id`, `subscriber_id`),
+    INDEX idx_status_next_run_at (`status`, `next_run_at`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    FOREIGN KEY (`automation_id`) REFERENCES automations (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `automation_logs` (
+    `id`            integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`       integer unsigned NOT NULL,
+    `automation_id` integer unsigned NOT NULL,
+    `step_id`       integer unsigned NOT NULL,
+    `subscriber_id` integer unsigned NOT NULL,
+    `status`        varchar(191) NOT NULL,
+    `description`   varchar(191) NOT NULL DEFAULT '',
+    `message_id`    varchar(191),
+    `created_at`    datetime(6)  NOT NULL,
+    INDEX idx_automation_id (`automation_id`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    FOREIGN KEY (`automation_id`) REFERENCES automations (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `automation_logs`;
+
+DROP TABLE `automation_runs`;
+
+DROP TABLE `automation_steps`;
+
+DROP TABLE `automations`;
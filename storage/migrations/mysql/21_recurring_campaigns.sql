@@ -0,0 +1,21 @@
+-- +migrate Up
+
+ALTER TABLE `campaigns` ADD COLUMN `parent_id` integer unsigned;
+ALTER TABLE `campaigns` ADD INDEX `idx_campaigns_parent_id` (`parent_id`);
+
+ALTER TABLE `campaign_schedules` ADD COLUMN `recurrence` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `campaign_schedules` ADD COLUMN `timezone` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `campaign_schedules` ADD COLUMN `feed_url` varchar(2048) NOT NULL DEFAULT '';
+ALTER TABLE `campaign_schedules` ADD COLUMN `feed_last_item_at` datetime(6);
+ALTER TABLE `campaign_schedules` ADD COLUMN `last_run_at` datetime(6);
+
+-- +migrate Down
+
+ALTER TABLE `campaign_schedules` DROP COLUMN `last_run_at`;
+ALTER TABLE `campaign_schedules` DROP COLUMN `feed_last_item_at`;
+ALTER TABLE `campaign_schedules` DROP COLUMN `feed_url`;
+ALTER TABLE `campaign_schedules` DROP COLUMN `timezone`;
+ALTER TABLE `campaign_schedules` DROP COLUMN `recurrence`;
+
+ALTER TABLE `campaigns` DROP INDEX `idx_campaigns_parent_id`;
+ALTER TABLE `campaigns` DROP COLUMN `parent_id`;
@@ -0,0 +1,36 @@
+-- +migrate Up
+
+ALTER TABLE `subscribers` ADD COLUMN `timezone` varchar(191) NOT NULL DEFAULT '';
+
+ALTER TABLE `campaign_schedules` ADD COLUMN `delivery_mode` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `campaign_schedules` ADD COLUMN `local_time` varchar(191) NOT NULL DEFAULT '';
+
+ALTER TABLE `campaign_checkpoints` ADD COLUMN `batched` tinyint(1) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS `campaign_batches` (
+    `id`             integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`        integer unsigned NOT NULL,
+    `campaign_id`    integer unsigned NOT NULL,
+    `event_id`       varbinary(27)    NOT NULL,
+    `timezone`       varchar(191)     NOT NULL DEFAULT '',
+    `release_at`     datetime(6)      NOT NULL,
+    `status`         varchar(191)     NOT NULL,
+    `size`           integer unsigned NOT NULL DEFAULT 0,
+    `subscriber_ids` JSON,
+    `created_at`     datetime(6)      NOT NULL,
+    `updated_at`     datetime(6)      NOT NULL,
+    INDEX idx_status_release_at (`status`, `release_at`),
+    INDEX idx_event_id (`event_id`),
+    FOREIGN KEY (`campaign_id`) REFERENCES campaigns (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `campaign_batches`;
+
+ALTER TABLE `campaign_checkpoints` DROP COLUMN `batched`;
+
+ALTER TABLE `campaign_schedules` DROP COLUMN `local_time`;
+ALTER TABLE `campaign_schedules` DROP COLUMN `delivery_mode`;
+
+ALTER TABLE `subscribers` DROP COLUMN `timezone`;
@@ -0,0 +1,31 @@
+-- +migrate Up
+
+ALTER TABLE `templates` ADD COLUMN `version` integer unsigned NOT NULL DEFAULT 0;
+ALTER TABLE `campaigns` ADD COLUMN `template_version` integer unsigned NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS `template_versions` (
+    `id`           integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`      integer unsigned NOT NULL,
+    `template_id`  integer unsigned NOT NULL,
+    `version`      integer unsigned NOT NULL,
+    `subject_part` varchar(191)     NOT NULL,
+    `text_part`    text,
+    `html_key`     varchar(191)     NOT NULL,
+    `created_at`   datetime(6)      NOT NULL,
+    `updated_at`   datetime(6)      NOT NULL,
+    UNIQUE INDEX idx_template_id_version (`template_id`, `version`),
+    FOREIGN KEY (`template_id`) REFERENCES templates (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- the html part of the existing templates is kept under the key without a version
+INSERT INTO `template_versions` (`user_id`, `template_id`, `version`, `subject_part`, `text_part`, `html_key`, `created_at`, `updated_at`)
+SELECT `user_id`, `id`, 1, `subject_part`, `text_part`, CONCAT('templates/', `user_id`, '/', `id`), `updated_at`, `updated_at` FROM `templates`;
+
+UPDATE `templates` SET `version` = 1;
+
+-- +migrate Down
+
+DROP TABLE `template_versions`;
+
+ALTER TABLE `campaigns` DROP COLUMN `template_version`;
+ALTER TABLE `templates` DROP COLUMN `version`;
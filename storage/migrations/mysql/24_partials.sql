@@ -0,0 +1,35 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `partials` (
+    `id`         integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`    integer unsigned NOT NULL,
+    `name`       varchar(191)     NOT NULL,
+    `content`    mediumtext       NOT NULL,
+    `layout`     tinyint(1)       NOT NULL DEFAULT 0,
+    `created_at` datetime(6)      NOT NULL,
+    `updated_at` datetime(6)      NOT NULL,
+    UNIQUE INDEX idx_user_id_name (`user_id`, `name`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `template_partials` (
+    `user_id`     integer unsigned NOT NULL,
+    `template_id` integer unsigned NOT NULL,
+    `name`        varchar(191)     NOT NULL,
+    PRIMARY KEY (`template_id`, `name`),
+    INDEX idx_user_id_name (`user_id`, `name`),
+    FOREIGN KEY (`template_id`) REFERENCES templates (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+ALTER TABLE `templates` ADD COLUMN `layout_id` integer unsigned DEFAULT NULL;
+ALTER TABLE `templates` ADD CONSTRAINT `fk_templates_layout_id` FOREIGN KEY (`layout_id`) REFERENCES partials (`id`);
+ALTER TABLE `template_versions` ADD COLUMN `layout_id` integer unsigned DEFAULT NULL;
+
+-- +migrate Down
+
+ALTER TABLE `template_versions` DROP COLUMN `layout_id`;
+ALTER TABLE `templates` DROP FOREIGN KEY `fk_templates_layout_id`;
+ALTER TABLE `templates` DROP COLUMN `layout_id`;
+
+DROP TABLE `template_partials`;
+DROP TABLE `partials`;
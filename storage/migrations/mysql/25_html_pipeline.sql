@@ -0,0 +1,23 @@
+-- +migrate Up
+
+ALTER TABLE `templates` ADD COLUMN `inline_css` tinyint(1) NOT NULL DEFAULT 0;
+ALTER TABLE `templates` ADD COLUMN `minify_html` tinyint(1) NOT NULL DEFAULT 0;
+ALTER TABLE `templates` ADD COLUMN `sanitize_html` tinyint(1) NOT NULL DEFAULT 0;
+ALTER TABLE `templates` ADD COLUMN `generate_text` tinyint(1) NOT NULL DEFAULT 0;
+
+ALTER TABLE `template_versions` ADD COLUMN `inline_css` tinyint(1) NOT NULL DEFAULT 0;
+ALTER TABLE `template_versions` ADD COLUMN `minify_html` tinyint(1) NOT NULL DEFAULT 0;
+ALTER TABLE `template_versions` ADD COLUMN `sanitize_html` tinyint(1) NOT NULL DEFAULT 0;
+ALTER TABLE `template_versions` ADD COLUMN `generate_text` tinyint(1) NOT NULL DEFAULT 0;
+
+-- +migrate Down
+
+ALTER TABLE `template_versions` DROP COLUMN `generate_text`;
+ALTER TABLE `template_versions` DROP COLUMN `sanitize_html`;
+ALTER TABLE `template_versions` DROP COLUMN `minify_html`;
+ALTER TABLE `template_versions` DROP COLUMN `inline_css`;
+
+ALTER TABLE `templates` DROP COLUMN `generate_text`;
+ALTER TABLE `templates` DROP COLUMN `sanitize_html`;
+ALTER TABLE `templates` DROP COLUMN `minify_html`;
+ALTER TABLE `templates` DROP COLUMN `inline_css`;
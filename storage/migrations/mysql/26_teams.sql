@@ -0,0 +1,58 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `teams` (
+    `id`         integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`    integer unsigned NOT NULL,
+    `name`       varchar(191)     NOT NULL,
+    `created_at` datetime(6)      NOT NULL,
+    `updated_at` datetime(6)      NOT NULL,
+    UNIQUE INDEX idx_user_id (`user_id`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `team_roles` (
+    `id`          integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `team_id`     integer unsigned NOT NULL,
+    `name`        varchar(191)     NOT NULL,
+    `permissions` json             NOT NULL,
+    `created_at`  datetime(6)      NOT NULL,
+    `updated_at`  datetime(6)      NOT NULL,
+    UNIQUE INDEX idx_team_id_name (`team_id`, `name`),
+    FOREIGN KEY (`team_id`) REFERENCES teams (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `team_members` (
+    `id`         integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `team_id`    integer unsigned NOT NULL,
+    `user_id`    integer unsigned NOT NULL,
+    `role_id`    integer unsigned NOT NULL,
+    `created_at` datetime(6)      NOT NULL,
+    `updated_at` datetime(6)      NOT NULL,
+    UNIQUE INDEX idx_team_id_user_id (`team_id`, `user_id`),
+    INDEX idx_user_id (`user_id`),
+    FOREIGN KEY (`team_id`) REFERENCES teams (`id`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`),
+    FOREIGN KEY (`role_id`) REFERENCES team_roles (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `team_invitations` (
+    `id`         integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `team_id`    integer unsigned NOT NULL,
+    `email`      varchar(191)     NOT NULL,
+    `role_id`    integer unsigned NOT NULL,
+    `token`      varchar(191)     NOT NULL,
+    `expires_at` datetime(6)      NOT NULL,
+    `created_at` datetime(6)      NOT NULL,
+    `updated_at` datetime(6)      NOT NULL,
+    UNIQUE INDEX idx_token (`token`),
+    UNIQUE INDEX idx_team_id_email (`team_id`, `email`),
+    FOREIGN KEY (`team_id`) REFERENCES teams (`id`),
+    FOREIGN KEY (`role_id`) REFERENCES team_roles (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `team_invitations`;
+DROP TABLE `team_members`;
+DROP TABLE `team_roles`;
+DROP TABLE `teams`;
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+ALTER TABLE `api_keys` ADD COLUMN `name` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `api_keys` ADD COLUMN `prefix` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `api_keys` ADD COLUMN `scopes` json;
+ALTER TABLE `api_keys` ADD COLUMN `expires_at` datetime(6);
+ALTER TABLE `api_keys` ADD COLUMN `last_used_at` datetime(6);
+
+-- The existing keys keep the access to the whole api.
+UPDATE `api_keys` SET `scopes` = '["*"]';
+
+-- +migrate Down
+
+ALTER TABLE `api_keys` DROP COLUMN `last_used_at`;
+ALTER TABLE `api_keys` DROP COLUMN `expires_at`;
+ALTER TABLE `api_keys` DROP COLUMN `scopes`;
+ALTER TABLE `api_keys` DROP COLUMN `prefix`;
+ALTER TABLE `api_keys` DROP COLUMN `name`;
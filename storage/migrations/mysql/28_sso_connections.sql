@@ -0,0 +1,31 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `sso_connections` (
+    `id`                integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`           integer unsigned NOT NULL,
+    `protocol`          varchar(191)     NOT NULL,
+    `domain`            varchar(191)     NOT NULL,
+    `enforced`          tinyint(1)       NOT NULL DEFAULT 0,
+    `default_role_id`   integer unsigned NOT NULL,
+    `role_attribute`    varchar(191)     NOT NULL DEFAULT '',
+    `role_mappings`     json             NOT NULL,
+    `email_attribute`   varchar(191)     NOT NULL DEFAULT '',
+    `idp_entity_id`     varchar(191)     NOT NULL DEFAULT '',
+    `idp_sso_url`       text             NOT NULL,
+    `idp_certificate`   text             NOT NULL,
+    `issuer`            varchar(191)     NOT NULL DEFAULT '',
+    `authorization_url` text             NOT NULL,
+    `token_url`         text             NOT NULL,
+    `jwks_url`          text             NOT NULL,
+    `client_id`         varchar(191)     NOT NULL DEFAULT '',
+    `client_secret`     varchar(191)     NOT NULL DEFAULT '',
+    `created_at`        datetime(6)      NOT NULL,
+    `updated_at`        datetime(6)      NOT NULL,
+    UNIQUE INDEX idx_domain (`domain`),
+    INDEX idx_user_id (`user_id`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `sso_connections`;
@@ -0,0 +1,28 @@
+-- +migrate Up
+
+ALTER TABLE `users` ADD COLUMN `two_factor_enabled` tinyint(1) NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `two_factor_secret` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `users` ADD COLUMN `two_factor_counter` bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE `sessions` ADD COLUMN `two_factor_pending` tinyint(1) NOT NULL DEFAULT 0;
+ALTER TABLE `sessions` ADD COLUMN `two_factor_attempts` integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+    `id`         integer unsigned PRIMARY KEY AUTO_INCREMENT,
+    `user_id`    integer unsigned NOT NULL,
+    `code`       varchar(191)     NOT NULL,
+    `created_at` datetime(6)      NOT NULL,
+    INDEX idx_user_id_code (`user_id`, `code`),
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `recovery_codes`;
+
+ALTER TABLE `sessions` DROP COLUMN `two_factor_attempts`;
+ALTER TABLE `sessions` DROP COLUMN `two_factor_pending`;
+
+ALTER TABLE `users` DROP COLUMN `two_factor_counter`;
+ALTER TABLE `users` DROP COLUMN `two_factor_secret`;
+ALTER TABLE `users` DROP COLUMN `two_factor_enabled`;
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+ALTER TABLE `sessions` ADD COLUMN `ip` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `sessions` ADD COLUMN `user_agent` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `sessions` ADD COLUMN `last_activity_at` datetime(6);
+
+UPDATE `sessions` SET `last_activity_at` = `updated_at`;
+
+-- +migrate Down
+
+ALTER TABLE `sessions` DROP COLUMN `last_activity_at`;
+ALTER TABLE `sessions` DROP COLUMN `user_agent`;
+ALTER TABLE `sessions` DROP COLUMN `ip`;
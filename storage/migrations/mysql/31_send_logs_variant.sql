@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE `send_logs` ADD COLUMN `variant_id` integer unsigned;
+
+CREATE INDEX idx_campaign_id_variant_id ON `send_logs` (`campaign_id`, `variant_id`);
+
+-- +migrate Down
+
+DROP INDEX idx_campaign_id_variant_id ON `send_logs`;
+
+ALTER TABLE `send_logs` DROP COLUMN `variant_id`;
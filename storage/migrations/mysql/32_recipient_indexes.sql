@@ -0,0 +1,11 @@
+-- +migrate Up
+
+CREATE INDEX idx_user_id_recipient ON `bounces` (`user_id`, `recipient`);
+CREATE INDEX idx_user_id_recipient ON `complaints` (`user_id`, `recipient`);
+CREATE INDEX idx_user_id_recipient ON `transactional_messages` (`user_id`, `recipient`);
+
+-- +migrate Down
+
+DROP INDEX idx_user_id_recipient ON `bounces`;
+DROP INDEX idx_user_id_recipient ON `complaints`;
+DROP INDEX idx_user_id_recipient ON `transactional_messages`;
@@ -0,0 +1,17 @@
+-- +migrate Up
+
+ALTER TABLE `bounces` MODIFY `campaign_id` INTEGER UNSIGNED NULL DEFAULT NULL;
+ALTER TABLE `bounces` ADD COLUMN `automation_id` INTEGER UNSIGNED NULL DEFAULT NULL;
+
+ALTER TABLE `complaints` MODIFY `campaign_id` INTEGER UNSIGNED NULL DEFAULT NULL;
+ALTER TABLE `complaints` ADD COLUMN `automation_id` INTEGER UNSIGNED NULL DEFAULT NULL;
+
+-- +migrate Down
+
+ALTER TABLE `bounces` DROP COLUMN `automation_id`;
+DELETE FROM `bounces` WHERE `campaign_id` IS NULL;
+ALTER TABLE `bounces` MODIFY `campaign_id` INTEGER UNSIGNED NOT NULL;
+
+ALTER TABLE `complaints` DROP COLUMN `automation_id`;
+DELETE FROM `complaints` WHERE `campaign_id` IS NULL;
+ALTER TABLE `complaints` MODIFY `campaign_id` INTEGER UNSIGNED NOT NULL;
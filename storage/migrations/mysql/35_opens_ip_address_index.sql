@@ -0,0 +1,7 @@
+-- +migrate Up
+
+CREATE INDEX idx_opens_user_id_ip_address_created_at ON `opens` (`user_id`, `ip_address`, `created_at`);
+
+-- +migrate Down
+
+DROP INDEX idx_opens_user_id_ip_address_created_at ON `opens`;
@@ -0,0 +1,8 @@
+-- +migrate Up
+
+-- The keys which were created before the secrets were hashed are stored as is, without a prefix.
+UPDATE `api_keys` SET `secret_key` = SHA2(`secret_key`, 256), `prefix` = 'legacy' WHERE `prefix` = '';
+
+-- +migrate Down
+
+-- The hashed keys can't be restored.
@@ -0,0 +1,18 @@
+-- +migrate Up
+
+-- The domain is no longer unique, since a domain can be claimed by the pending connections of several
+-- accounts until one of them is verified. The existing connections must be verified as well.
+ALTER TABLE `sso_connections` ADD COLUMN `verification_token` varchar(191) NOT NULL DEFAULT '';
+ALTER TABLE `sso_connections` ADD COLUMN `verified_at` datetime(6) NULL DEFAULT NULL;
+
+UPDATE `sso_connections` SET `verification_token` = LEFT(SHA2(CONCAT(UUID(), RAND()), 256), 32);
+
+DROP INDEX idx_domain ON `sso_connections`;
+CREATE INDEX idx_domain ON `sso_connections` (`domain`);
+
+-- +migrate Down
+
+DROP INDEX idx_domain ON `sso_connections`;
+CREATE UNIQUE INDEX idx_domain ON `sso_connections` (`domain`);
+ALTER TABLE `sso_connections` DROP COLUMN `verified_at`;
+ALTER TABLE `sso_connections` DROP COLUMN `verification_token`;
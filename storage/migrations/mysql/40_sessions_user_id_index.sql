@@ -0,0 +1,7 @@
+-- +migrate Up
+
+CREATE INDEX idx_sessions_user_id ON `sessions` (`user_id`);
+
+-- +migrate Down
+
+DROP INDEX idx_sessions_user_id ON `sessions`;
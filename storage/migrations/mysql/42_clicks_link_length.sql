@@ -0,0 +1,8 @@
+-- +migrate Up
+
+ALTER TABLE `clicks` MODIFY `link` VARCHAR(2048) NOT NULL;
+
+-- +migrate Down
+
+UPDATE `clicks` SET `link` = LEFT(`link`, 191);
+ALTER TABLE `clicks` MODIFY `link` VARCHAR(191) NOT NULL;
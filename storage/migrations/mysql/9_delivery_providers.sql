@@ -0,0 +1,22 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS `delivery_providers` (
+    `id`             integer unsigned primary key auto_increment,
+    `user_id`        integer unsigned unique not null,
+    `type`           varchar(191) not null,
+    `host`           varchar(191),
+    `port`           integer unsigned,
+    `username`       varchar(191),
+    `password`       varchar(191),
+    `api_key`        varchar(191),
+    `domain`         varchar(191),
+    `region`         varchar(30),
+    `message_stream` varchar(191),
+    `created_at`     datetime(6) not null,
+    `updated_at`     datetime(6) not null,
+    FOREIGN KEY (`user_id`) REFERENCES users (`id`)
+) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+-- +migrate Down
+
+DROP TABLE `delivery_providers`;
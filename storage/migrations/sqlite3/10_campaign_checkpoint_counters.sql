@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE "campaign_checkpoints" ADD COLUMN "enqueued" integer not null default 0;
+ALTER TABLE "campaign_checkpoints" ADD COLUMN "failed" integer not null default 0;
+
+-- +migrate Down
+
+ALTER TABLE "campaign_checkpoints" DROP COLUMN "failed";
+ALTER TABLE "campaign_checkpoints" DROP COLUMN "enqueued";
@@ -0,0 +1,19 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "send_rates" (
+    "user_id"          integer primary key,
+    "max_send_rate"    numeric not null default 0,
+    "quota_send_rate"  numeric not null default 0,
+    "quota_updated_at" datetime,
+    "created_at"       datetime not null,
+    "updated_at"       datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_send_logs_user_created_at ON "send_logs" (user_id, created_at);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_send_logs_user_created_at;
+
+DROP TABLE "send_rates";
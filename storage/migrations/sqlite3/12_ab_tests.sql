@@ -0,0 +1,53 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "campaign_ab_tests" (
+    "campaign_id"       integer primary key,
+    "user_id"           integer not null,
+    "test_percentage"   integer not null,
+    "wait_minutes"      integer not null,
+    "winner_metric"     varchar(20) not null,
+    "winner_variant_id" integer,
+    "status"            varchar(20) not null,
+    "pick_winner_at"    datetime,
+    "params"            varchar,
+    "created_at"        datetime not null,
+    "updated_at"        datetime not null,
+    foreign key ("campaign_id") references campaigns("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_ab_tests_status_pick_winner_at ON "campaign_ab_tests" (status, pick_winner_at);
+
+CREATE TABLE IF NOT EXISTS "campaign_variants" (
+    "id"           integer primary key autoincrement,
+    "user_id"      integer not null,
+    "campaign_id"  integer not null,
+    "name"         varchar(191) not null,
+    "subject_part" varchar(191),
+    "template_id"  integer,
+    "created_at"   datetime not null,
+    "updated_at"   datetime not null,
+    foreign key ("campaign_id") references campaigns("id"),
+    foreign key ("template_id") references templates("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_variants_campaign_id ON "campaign_variants" (campaign_id);
+
+ALTER TABLE "sends" ADD COLUMN "variant_id" integer;
+ALTER TABLE "deliveries" ADD COLUMN "variant_id" integer;
+ALTER TABLE "opens" ADD COLUMN "variant_id" integer;
+ALTER TABLE "clicks" ADD COLUMN "variant_id" integer;
+ALTER TABLE "bounces" ADD COLUMN "variant_id" integer;
+ALTER TABLE "complaints" ADD COLUMN "variant_id" integer;
+
+-- +migrate Down
+
+ALTER TABLE "complaints" DROP COLUMN "variant_id";
+ALTER TABLE "bounces" DROP COLUMN "variant_id";
+ALTER TABLE "clicks" DROP COLUMN "variant_id";
+ALTER TABLE "opens" DROP COLUMN "variant_id";
+ALTER TABLE "deliveries" DROP COLUMN "variant_id";
+ALTER TABLE "sends" DROP COLUMN "variant_id";
+
+DROP TABLE "campaign_variants";
+
+DROP TABLE "campaign_ab_tests";
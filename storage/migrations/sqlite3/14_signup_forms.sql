@@ -0,0 +1,38 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "signup_forms" (
+    "user_id"             integer primary key,
+    "enabled"             integer not null default 0,
+    "recaptcha"           integer not null default 0,
+    "confirm_template_id" integer,
+    "success_template_id" integer,
+    "segment_ids"         json,
+    "created_at"          datetime not null,
+    "updated_at"          datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE TABLE IF NOT EXISTS "subscriber_consents" (
+    "id"            integer primary key autoincrement,
+    "user_id"       integer not null,
+    "subscriber_id" integer not null,
+    "token_id"      integer not null,
+    "segment_ids"   json,
+    "signup_ip"     varchar(45) not null,
+    "user_agent"    varchar(191) not null,
+    "confirm_ip"    varchar(45) not null default '',
+    "confirmed_at"  datetime,
+    "created_at"    datetime not null,
+    "updated_at"    datetime not null,
+    foreign key ("user_id") references users("id"),
+    foreign key ("subscriber_id") references subscribers("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriber_consents_subscriber_id ON "subscriber_consents" (subscriber_id);
+CREATE INDEX IF NOT EXISTS idx_subscriber_consents_token_id ON "subscriber_consents" (token_id);
+
+-- +migrate Down
+
+DROP TABLE "subscriber_consents";
+
+DROP TABLE "signup_forms";
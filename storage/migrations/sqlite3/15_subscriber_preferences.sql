@@ -0,0 +1,20 @@
+-- +migrate Up
+
+ALTER TABLE "subscribers" ADD COLUMN "paused_until" datetime;
+
+CREATE TABLE IF NOT EXISTS "segment_opt_outs" (
+    "segment_id"    integer not null,
+    "subscriber_id" integer not null,
+    "created_at"    datetime not null,
+    PRIMARY KEY ("segment_id", "subscriber_id"),
+    foreign key ("segment_id") references segments("id"),
+    foreign key ("subscriber_id") references subscribers("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_segment_opt_outs_subscriber_id ON "segment_opt_outs" (subscriber_id);
+
+-- +migrate Down
+
+DROP TABLE "segment_opt_outs";
+
+ALTER TABLE "subscribers" DROP COLUMN "paused_until";
@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE "campaigns" ADD COLUMN "track_opens" integer not null default 0;
+ALTER TABLE "campaigns" ADD COLUMN "track_clicks" integer not null default 0;
+
+-- +migrate Down
+
+ALTER TABLE "campaigns" DROP COLUMN "track_clicks";
+ALTER TABLE "campaigns" DROP COLUMN "track_opens";
@@ -0,0 +1,54 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "id"         integer primary key autoincrement,
+    "user_id"    integer not null,
+    "url"        varchar(2048) not null,
+    "secret"     varchar(191) not null,
+    "events"     json,
+    "active"     integer not null default 1,
+    "created_at" datetime not null,
+    "updated_at" datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON "webhooks" (user_id);
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id"              integer primary key autoincrement,
+    "user_id"         integer not null,
+    "webhook_id"      integer not null,
+    "event"           varchar(191) not null,
+    "payload"         json,
+    "status"          varchar(191) not null,
+    "attempts"        integer not null default 0,
+    "next_attempt_at" datetime,
+    "created_at"      datetime not null,
+    "updated_at"      datetime not null,
+    foreign key ("user_id") references users("id"),
+    foreign key ("webhook_id") references webhooks("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON "webhook_deliveries" (webhook_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_next_attempt_at ON "webhook_deliveries" (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS "webhook_delivery_attempts" (
+    "id"            integer primary key autoincrement,
+    "delivery_id"   integer not null,
+    "status_code"   integer not null default 0,
+    "response_body" text,
+    "error"         text,
+    "duration_ms"   integer not null default 0,
+    "created_at"    datetime not null,
+    foreign key ("delivery_id") references webhook_deliveries("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON "webhook_delivery_attempts" (delivery_id);
+
+-- +migrate Down
+
+DROP TABLE "webhook_delivery_attempts";
+
+DROP TABLE "webhook_deliveries";
+
+DROP TABLE "webhooks";
@@ -0,0 +1,35 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "transactional_messages" (
+    "id"                  integer primary key autoincrement,
+    "user_id"             integer not null,
+    "message_id"          varchar(191) not null unique,
+    "idempotency_key"     varchar(191),
+    "template_id"         integer not null,
+    "recipient"           varchar(191) not null,
+    "status"              varchar(191) not null,
+    "provider_message_id" varchar(191),
+    "description"         text,
+    "created_at"          datetime not null,
+    "updated_at"          datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactional_messages_user_id_idempotency_key ON "transactional_messages" (user_id, idempotency_key);
+
+CREATE TABLE IF NOT EXISTS "transactional_events" (
+    "id"                       integer primary key autoincrement,
+    "transactional_message_id" integer not null,
+    "type"                     varchar(191) not null,
+    "description"              text,
+    "created_at"               datetime not null,
+    foreign key ("transactional_message_id") references transactional_messages("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactional_events_transactional_message_id ON "transactional_events" (transactional_message_id);
+
+-- +migrate Down
+
+DROP TABLE "transactional_events";
+
+DROP TABLE "transactional_messages";
@@ -0,0 +1,84 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "automations" (
+    "id"                   integer primary key autoincrement,
+    "user_id"              integer not null,
+    "name"                 varchar(191) not null,
+    "status"               varchar(191) not null,
+    "trigger_type"         varchar(191) not null,
+    "trigger_segment_id"   integer,
+    "trigger_campaign_id"  integer,
+    "trigger_link"         varchar(2048) not null default '',
+    "trigger_metadata_key" varchar(191) not null default '',
+    "created_at"           datetime not null,
+    "updated_at"           datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_automations_user_id_status_trigger_type ON "automations" (user_id, status, trigger_type);
+
+CREATE TABLE IF NOT EXISTS "automation_steps" (
+    "id"                   integer primary key autoincrement,
+    "automation_id"        integer not null,
+    "position"             integer not null,
+    "type"                 varchar(191) not null,
+    "delay_minutes"        integer not null default 0,
+    "condition_type"       varchar(191) not null default '',
+    "condition_segment_id" integer,
+    "condition_key"        varchar(191) not null default '',
+    "condition_value"      varchar(191) not null default '',
+    "template_id"          integer,
+    "source"               varchar(191) not null default '',
+    "from_name"            varchar(191) not null default '',
+    "template_data"        json,
+    "created_at"           datetime not null,
+    "updated_at"           datetime not null,
+    foreign key ("automation_id") references automations("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_automation_steps_automation_id ON "automation_steps" (automation_id);
+
+CREATE TABLE IF NOT EXISTS "automation_runs" (
+    "id"            integer primary key autoincrement,
+    "user_id"       integer not null,
+    "automation_id" integer not null,
+    "subscriber_id" integer not null,
+    "status"        varchar(191) not null,
+    "position"      integer not null default 0,
+    "event_id"      varchar(27),
+    "next_run_at"   datetime,
+    "description"   varchar(191) not null default '',
+    "created_at"    datetime not null,
+    "updated_at"    datetime not null,
+    foreign key ("user_id") references users("id"),
+    foreign key ("automation_id") references automations("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_automation_runs_automation_id_subscriber_id ON "automation_runs" (automation_id, subscriber_id);
+CREATE INDEX IF NOT EXISTS idx_automation_runs_status_next_run_at ON "automation_runs" (status, next_run_at);
+
+CREATE TABLE IF NOT EXISTS "automation_logs" (
+    "id"            integer primary key autoincrement,
+    "user_id"       integer not null,
+    "automation_id" integer not null,
+    "step_id"       integer not null,
+    "subscriber_id" integer not null,
+    "status"        varchar(191) not null,
+    "description"   varchar(191) not null default '',
+    "message_id"    varchar(191),
+    "created_at"    datetime not null,
+    foreign key ("user_id") references users("id"),
+    foreign key ("automation_id") references automations("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_automation_logs_automation_id ON "automation_logs" (automation_id);
+
+-- +migrate Down
+
+DROP TABLE "automation_logs";
+
+DROP TABLE "automation_runs";
+
+DROP TABLE "automation_steps";
+
+DROP TABLE "automations";
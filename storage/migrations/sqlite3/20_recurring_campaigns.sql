@@ -0,0 +1,23 @@
+-- +migrate Up
+
+ALTER TABLE "campaigns" ADD COLUMN "parent_id" integer;
+
+ALTER TABLE "campaign_schedules" ADD COLUMN "recurrence" varchar(191) not null default '';
+ALTER TABLE "campaign_schedules" ADD COLUMN "timezone" varchar(191) not null default '';
+ALTER TABLE "campaign_schedules" ADD COLUMN "feed_url" varchar(2048) not null default '';
+ALTER TABLE "campaign_schedules" ADD COLUMN "feed_last_item_at" datetime;
+ALTER TABLE "campaign_schedules" ADD COLUMN "last_run_at" datetime;
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_parent_id ON "campaigns" (parent_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_campaigns_parent_id;
+
+ALTER TABLE "campaign_schedules" DROP COLUMN "last_run_at";
+ALTER TABLE "campaign_schedules" DROP COLUMN "feed_last_item_at";
+ALTER TABLE "campaign_schedules" DROP COLUMN "feed_url";
+ALTER TABLE "campaign_schedules" DROP COLUMN "timezone";
+ALTER TABLE "campaign_schedules" DROP COLUMN "recurrence";
+
+ALTER TABLE "campaigns" DROP COLUMN "parent_id";
@@ -0,0 +1,39 @@
+-- +migrate Up
+
+ALTER TABLE "subscribers" ADD COLUMN "timezone" varchar(191) not null default '';
+
+ALTER TABLE "campaign_schedules" ADD COLUMN "delivery_mode" varchar(191) not null default '';
+ALTER TABLE "campaign_schedules" ADD COLUMN "local_time" varchar(191) not null default '';
+
+ALTER TABLE "campaign_checkpoints" ADD COLUMN "batched" integer not null default 0;
+
+CREATE TABLE IF NOT EXISTS "campaign_batches" (
+    "id"             integer primary key autoincrement,
+    "user_id"        integer not null,
+    "campaign_id"    integer not null,
+    "event_id"       varchar(27) not null,
+    "timezone"       varchar(191) not null default '',
+    "release_at"     datetime not null,
+    "status"         varchar(191) not null,
+    "size"           integer not null default 0,
+    "subscriber_ids" json,
+    "created_at"     datetime not null,
+    "updated_at"     datetime not null,
+    foreign key ("campaign_id") references campaigns("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaign_batches_status_release_at ON "campaign_batches" (status, release_at);
+CREATE INDEX IF NOT EXISTS idx_campaign_batches_event_id ON "campaign_batches" (event_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_campaign_batches_event_id;
+DROP INDEX IF EXISTS idx_campaign_batches_status_release_at;
+DROP TABLE IF EXISTS "campaign_batches";
+
+ALTER TABLE "campaign_checkpoints" DROP COLUMN "batched";
+
+ALTER TABLE "campaign_schedules" DROP COLUMN "local_time";
+ALTER TABLE "campaign_schedules" DROP COLUMN "delivery_mode";
+
+ALTER TABLE "subscribers" DROP COLUMN "timezone";
@@ -0,0 +1,33 @@
+-- +migrate Up
+
+ALTER TABLE "templates" ADD COLUMN "version" integer not null default 0;
+ALTER TABLE "campaigns" ADD COLUMN "template_version" integer not null default 0;
+
+CREATE TABLE IF NOT EXISTS "template_versions" (
+    "id"           integer primary key autoincrement,
+    "user_id"      integer not null,
+    "template_id"  integer not null,
+    "version"      integer not null,
+    "subject_part" varchar(191) not null,
+    "text_part"    text,
+    "html_key"     varchar(191) not null,
+    "created_at"   datetime not null,
+    "updated_at"   datetime not null,
+    foreign key ("template_id") references templates("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_template_versions_template_id_version ON "template_versions" (template_id, version);
+
+-- the html part of the existing templates is kept under the key without a version
+INSERT INTO "template_versions" ("user_id", "template_id", "version", "subject_part", "text_part", "html_key", "created_at", "updated_at")
+SELECT "user_id", "id", 1, "subject_part", "text_part", 'templates/' || "user_id" || '/' || "id", "updated_at", "updated_at" FROM "templates";
+
+UPDATE "templates" SET "version" = 1;
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_template_versions_template_id_version;
+DROP TABLE IF EXISTS "template_versions";
+
+ALTER TABLE "campaigns" DROP COLUMN "template_version";
+ALTER TABLE "templates" DROP COLUMN "version";
@@ -0,0 +1,38 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "partials" (
+    "id"         integer primary key autoincrement,
+    "user_id"    integer not null,
+    "name"       varchar(191) not null,
+    "content"    text not null,
+    "layout"     integer not null default 0,
+    "created_at" datetime not null,
+    "updated_at" datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_partials_user_id_name ON "partials" (user_id, name);
+
+CREATE TABLE IF NOT EXISTS "template_partials" (
+    "user_id"     integer not null,
+    "template_id" integer not null,
+    "name"        varchar(191) not null,
+    primary key ("template_id", "name"),
+    foreign key ("template_id") references templates("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_partials_user_id_name ON "template_partials" (user_id, name);
+
+ALTER TABLE "templates" ADD COLUMN "layout_id" integer default null;
+ALTER TABLE "template_versions" ADD COLUMN "layout_id" integer default null;
+
+-- +migrate Down
+
+ALTER TABLE "template_versions" DROP COLUMN "layout_id";
+ALTER TABLE "templates" DROP COLUMN "layout_id";
+
+DROP INDEX IF EXISTS idx_template_partials_user_id_name;
+DROP TABLE IF EXISTS "template_partials";
+
+DROP INDEX IF EXISTS idx_partials_user_id_name;
+DROP TABLE IF EXISTS "partials";
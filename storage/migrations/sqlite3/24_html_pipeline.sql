@@ -0,0 +1,23 @@
+-- +migrate Up
+
+ALTER TABLE "templates" ADD COLUMN "inline_css" integer not null default 0;
+ALTER TABLE "templates" ADD COLUMN "minify_html" integer not null default 0;
+ALTER TABLE "templates" ADD COLUMN "sanitize_html" integer not null default 0;
+ALTER TABLE "templates" ADD COLUMN "generate_text" integer not null default 0;
+
+ALTER TABLE "template_versions" ADD COLUMN "inline_css" integer not null default 0;
+ALTER TABLE "template_versions" ADD COLUMN "minify_html" integer not null default 0;
+ALTER TABLE "template_versions" ADD COLUMN "sanitize_html" integer not null default 0;
+ALTER TABLE "template_versions" ADD COLUMN "generate_text" integer not null default 0;
+
+-- +migrate Down
+
+ALTER TABLE "template_versions" DROP COLUMN "generate_text";
+ALTER TABLE "template_versions" DROP COLUMN "sanitize_html";
+ALTER TABLE "template_versions" DROP COLUMN "minify_html";
+ALTER TABLE "template_versions" DROP COLUMN "inline_css";
+
+ALTER TABLE "templates" DROP COLUMN "generate_text";
+ALTER TABLE "templates" DROP COLUMN "sanitize_html";
+ALTER TABLE "templates" DROP COLUMN "minify_html";
+ALTER TABLE "templates" DROP COLUMN "inline_css";
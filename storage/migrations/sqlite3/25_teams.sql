@@ -0,0 +1,66 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "teams" (
+    "id"         integer primary key autoincrement,
+    "user_id"    integer not null unique,
+    "name"       varchar(191) not null,
+    "created_at" datetime not null,
+    "updated_at" datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE TABLE IF NOT EXISTS "team_roles" (
+    "id"          integer primary key autoincrement,
+    "team_id"     integer not null,
+    "name"        varchar(191) not null,
+    "permissions" text not null,
+    "created_at"  datetime not null,
+    "updated_at"  datetime not null,
+    foreign key ("team_id") references teams("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_team_roles_team_id_name ON "team_roles" (team_id, name);
+
+CREATE TABLE IF NOT EXISTS "team_members" (
+    "id"         integer primary key autoincrement,
+    "team_id"    integer not null,
+    "user_id"    integer not null,
+    "role_id"    integer not null,
+    "created_at" datetime not null,
+    "updated_at" datetime not null,
+    foreign key ("team_id") references teams("id"),
+    foreign key ("user_id") references users("id"),
+    foreign key ("role_id") references team_roles("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_team_members_team_id_user_id ON "team_members" (team_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_team_members_user_id ON "team_members" (user_id);
+
+CREATE TABLE IF NOT EXISTS "team_invitations" (
+    "id"         integer primary key autoincrement,
+    "team_id"    integer not null,
+    "email"      varchar(191) not null,
+    "role_id"    integer not null,
+    "token"      varchar(191) not null unique,
+    "expires_at" datetime not null,
+    "created_at" datetime not null,
+    "updated_at" datetime not null,
+    foreign key ("team_id") references teams("id"),
+    foreign key ("role_id") references team_roles("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_team_invitations_team_id_email ON "team_invitations" (team_id, email);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_team_invitations_team_id_email;
+DROP TABLE IF EXISTS "team_invitations";
+
+DROP INDEX IF EXISTS idx_team_members_user_id;
+DROP INDEX IF EXISTS idx_team_members_team_id_user_id;
+DROP TABLE IF EXISTS "team_members";
+
+DROP INDEX IF EXISTS idx_team_roles_team_id_name;
+DROP TABLE IF EXISTS "team_roles";
+
+DROP TABLE IF EXISTS "teams";
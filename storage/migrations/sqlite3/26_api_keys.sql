@@ -0,0 +1,15 @@
+-- +migrate Up
+
+ALTER TABLE "api_keys" ADD COLUMN "name" varchar(191) not null default '';
+ALTER TABLE "api_keys" ADD COLUMN "prefix" varchar(191) not null default '';
+ALTER TABLE "api_keys" ADD COLUMN "scopes" text not null default '["*"]';
+ALTER TABLE "api_keys" ADD COLUMN "expires_at" datetime;
+ALTER TABLE "api_keys" ADD COLUMN "last_used_at" datetime;
+
+-- +migrate Down
+
+ALTER TABLE "api_keys" DROP COLUMN "last_used_at";
+ALTER TABLE "api_keys" DROP COLUMN "expires_at";
+ALTER TABLE "api_keys" DROP COLUMN "scopes";
+ALTER TABLE "api_keys" DROP COLUMN "prefix";
+ALTER TABLE "api_keys" DROP COLUMN "name";
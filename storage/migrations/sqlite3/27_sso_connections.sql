@@ -0,0 +1,31 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "sso_connections" (
+    "id"                integer primary key autoincrement,
+    "user_id"           integer not null,
+    "protocol"          varchar(191) not null,
+    "domain"            varchar(191) not null unique,
+    "enforced"          integer not null default 0,
+    "default_role_id"   integer not null,
+    "role_attribute"    varchar(191) not null default '',
+    "role_mappings"     text not null,
+    "email_attribute"   varchar(191) not null default '',
+    "idp_entity_id"     varchar(191) not null default '',
+    "idp_sso_url"       text not null default '',
+    "idp_certificate"   text not null default '',
+    "issuer"            varchar(191) not null default '',
+    "authorization_url" text not null default '',
+    "token_url"         text not null default '',
+    "jwks_url"          text not null default '',
+    "client_id"         varchar(191) not null default '',
+    "client_secret"     varchar(191) not null default '',
+    "created_at"        datetime not null,
+    "updated_at"        datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_connections_user_id ON "sso_connections" (user_id);
+
+-- +migrate Down
+
+DROP TABLE "sso_connections";
@@ -0,0 +1,30 @@
+-- +migrate Up
+
+ALTER TABLE "users" ADD COLUMN "two_factor_enabled" integer not null default 0;
+ALTER TABLE "users" ADD COLUMN "two_factor_secret" varchar(191) not null default '';
+ALTER TABLE "users" ADD COLUMN "two_factor_counter" integer not null default 0;
+
+ALTER TABLE "sessions" ADD COLUMN "two_factor_pending" integer not null default 0;
+ALTER TABLE "sessions" ADD COLUMN "two_factor_attempts" integer not null default 0;
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id"         integer primary key autoincrement,
+    "user_id"    integer not null,
+    "code"       varchar(191) not null,
+    "created_at" datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id_code ON "recovery_codes" (user_id, code);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_recovery_codes_user_id_code;
+DROP TABLE IF EXISTS "recovery_codes";
+
+ALTER TABLE "sessions" DROP COLUMN "two_factor_attempts";
+ALTER TABLE "sessions" DROP COLUMN "two_factor_pending";
+
+ALTER TABLE "users" DROP COLUMN "two_factor_counter";
+ALTER TABLE "users" DROP COLUMN "two_factor_secret";
+ALTER TABLE "users" DROP COLUMN "two_factor_enabled";
@@ -0,0 +1,17 @@
+-- +migrate Up
+
+ALTER TABLE "sessions" ADD COLUMN "ip" varchar(191) not null default '';
+ALTER TABLE "sessions" ADD COLUMN "user_agent" varchar(191) not null default '';
+ALTER TABLE "sessions" ADD COLUMN "last_activity_at" datetime;
+
+UPDATE "sessions" SET "last_activity_at" = "updated_at";
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON "sessions" (user_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_sessions_user_id;
+
+ALTER TABLE "sessions" DROP COLUMN "last_activity_at";
+ALTER TABLE "sessions" DROP COLUMN "user_agent";
+ALTER TABLE "sessions" DROP COLUMN "ip";
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE "send_logs" ADD COLUMN "variant_id" integer;
+
+CREATE INDEX IF NOT EXISTS idx_send_logs_campaign_variant ON "send_logs" (campaign_id, variant_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_send_logs_campaign_variant;
+
+ALTER TABLE "send_logs" DROP COLUMN "variant_id";
@@ -0,0 +1,11 @@
+-- +migrate Up
+
+CREATE INDEX IF NOT EXISTS idx_bounces_user_id_recipient ON "bounces" (user_id, recipient);
+CREATE INDEX IF NOT EXISTS idx_complaints_user_id_recipient ON "complaints" (user_id, recipient);
+CREATE INDEX IF NOT EXISTS idx_transactional_messages_user_id_recipient ON "transactional_messages" (user_id, recipient);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_bounces_user_id_recipient;
+DROP INDEX IF EXISTS idx_complaints_user_id_recipient;
+DROP INDEX IF EXISTS idx_transactional_messages_user_id_recipient;
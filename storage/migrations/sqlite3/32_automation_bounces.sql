@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE "bounces" ADD COLUMN "automation_id" integer;
+ALTER TABLE "complaints" ADD COLUMN "automation_id" integer;
+
+-- +migrate Down
+
+ALTER TABLE "bounces" DROP COLUMN "automation_id";
+ALTER TABLE "complaints" DROP COLUMN "automation_id";
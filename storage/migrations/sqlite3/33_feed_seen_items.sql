@@ -0,0 +1,7 @@
+-- +migrate Up
+
+ALTER TABLE "campaign_schedules" ADD COLUMN "feed_seen_items" json;
+
+-- +migrate Down
+
+ALTER TABLE "campaign_schedules" DROP COLUMN "feed_seen_items";
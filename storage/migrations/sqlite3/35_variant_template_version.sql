@@ -0,0 +1,7 @@
+-- +migrate Up
+
+ALTER TABLE "campaign_variants" ADD COLUMN "template_version" integer not null default 0;
+
+-- +migrate Down
+
+ALTER TABLE "campaign_variants" DROP COLUMN "template_version";
@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE "template_versions" ADD COLUMN "processed_html_key" varchar(191) not null default '';
+ALTER TABLE "template_versions" ADD COLUMN "processed_text_part" text;
+
+-- +migrate Down
+
+ALTER TABLE "template_versions" DROP COLUMN "processed_text_part";
+ALTER TABLE "template_versions" DROP COLUMN "processed_html_key";
@@ -0,0 +1,52 @@
+-- +migrate Up
+
+-- The domain is no longer unique, since a domain can be claimed by the pending connections of several
+-- accounts until one of them is verified. The existing connections must be verified as well.
+CREATE TABLE IF NOT EXISTS "sso_connections_verification" (
+    "id"                 integer primary key autoincrement,
+    "user_id"            integer not null,
+    "protocol"           varchar(191) not null,
+    "domain"             varchar(191) not null,
+    "enforced"           integer not null default 0,
+    "default_role_id"    integer not null,
+    "role_attribute"     varchar(191) not null default '',
+    "role_mappings"      text not null,
+    "email_attribute"    varchar(191) not null default '',
+    "idp_entity_id"      varchar(191) not null default '',
+    "idp_sso_url"        text not null default '',
+    "idp_certificate"    text not null default '',
+    "issuer"             varchar(191) not null default '',
+    "authorization_url"  text not null default '',
+    "token_url"          text not null default '',
+    "jwks_url"           text not null default '',
+    "client_id"          varchar(191) not null default '',
+    "client_secret"      varchar(191) not null default '',
+    "verification_token" varchar(191) not null default '',
+    "verified_at"        datetime,
+    "created_at"         datetime not null,
+    "updated_at"         datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+INSERT INTO "sso_connections_verification" (
+    "id", "user_id", "protocol", "domain", "enforced", "default_role_id", "role_attribute", "role_mappings",
+    "email_attribute", "idp_entity_id", "idp_sso_url", "idp_certificate", "issuer", "authorization_url",
+    "token_url", "jwks_url", "client_id", "client_secret", "verification_token", "created_at", "updated_at"
+)
+SELECT
+    "id", "user_id", "protocol", "domain", "enforced", "default_role_id", "role_attribute", "role_mappings",
+    "email_attribute", "idp_entity_id", "idp_sso_url", "idp_certificate", "issuer", "authorization_url",
+    "token_url", "jwks_url", "client_id", "client_secret", lower(hex(randomblob(16))), "created_at", "updated_at"
+FROM "sso_connections";
+
+DROP TABLE "sso_connections";
+ALTER TABLE "sso_connections_verification" RENAME TO "sso_connections";
+
+CREATE INDEX IF NOT EXISTS idx_sso_connections_user_id ON "sso_connections" (user_id);
+CREATE INDEX IF NOT EXISTS idx_sso_connections_domain ON "sso_connections" (domain);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_sso_connections_domain;
+ALTER TABLE "sso_connections" DROP COLUMN "verified_at";
+ALTER TABLE "sso_connections" DROP COLUMN "verification_token";
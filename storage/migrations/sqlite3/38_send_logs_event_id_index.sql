@@ -0,0 +1,7 @@
+-- +migrate Up
+
+CREATE INDEX IF NOT EXISTS idx_send_logs_event_id ON "send_logs" (event_id);
+
+-- +migrate Down
+
+DROP INDEX IF EXISTS idx_send_logs_event_id;
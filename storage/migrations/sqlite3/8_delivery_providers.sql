@@ -0,0 +1,22 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "delivery_providers" (
+    "id"             integer primary key autoincrement,
+    "user_id"        integer unique not null,
+    "type"           varchar(191) not null,
+    "host"           varchar(191),
+    "port"           integer,
+    "username"       varchar(191),
+    "password"       varchar(191),
+    "api_key"        varchar(191),
+    "domain"         varchar(191),
+    "region"         varchar(30),
+    "message_stream" varchar(191),
+    "created_at"     datetime not null,
+    "updated_at"     datetime not null,
+    foreign key ("user_id") references users("id")
+);
+
+-- +migrate Down
+
+DROP TABLE "delivery_providers";
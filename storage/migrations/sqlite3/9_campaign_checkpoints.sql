@@ -0,0 +1,19 @@
+-- +migrate Up
+
+CREATE TABLE IF NOT EXISTS "campaign_checkpoints"
+(
+    "event_id"           varchar(27) primary key,
+    "user_id"            integer not null,
+    "campaign_id"        integer not null,
+    "last_subscriber_id" integer not null default 0,
+    "last_created_at"    datetime,
+    "paused"             integer not null default 0,
+    "params"             varchar,
+    "created_at"         datetime not null,
+    "updated_at"         datetime not null,
+    foreign key ("campaign_id") references campaigns("id")
+);
+
+-- +migrate Down
+
+DROP TABLE "campaign_checkpoints";
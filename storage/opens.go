@@ -1,7 +1,79 @@
 package storage
 
-import "github.com/mailbadger/app/entities"
+import (
+	"fmt"
+	"time"
 
+	"github.com/mailbadger/app/entities"
+)
+
+// timezoneInferenceWindow is how far back the opens from the same ip address are looked up
+// when inferring the time zone of a subscriber.
+const timezoneInferenceWindow = 30 * 24 * time.Hour
+
+// CreateOpen creates the open. When the time zone of the subscriber is not known, it is inferred from
+// the subscribers with a known time zone who recently opened the emails from the same ip address.
+// The opens made by image proxies are skipped, since their ip address is shared by many recipients.
 func (db *store) CreateOpen(o *entities.Open) error {
-	return db.Create(o).Error
+	err := db.Create(o).Error
+	if err != nil {
+		return err
+	}
+
+	if o.IPAddress == "" || o.IsProxied() {
+		return nil
+	}
+
+	var unknown int64
+	err = db.Model(&entities.Subscriber{}).
+		Where("user_id = ? AND email = ? AND timezone = ''", o.UserID, o.Recipient).
+		Count(&unknown).Error
+	if err != nil {
+		return fmt.Errorf("store: infer time zone: %w", err)
+	}
+	if unknown == 0 {
+		return nil
+	}
+
+	since := o.CreatedAt
+	if since.IsZero() {
+		since = time.Now().UTC()
+	}
+	since = since.Add(-timezoneInferenceWindow)
+
+	var timezones []string
+	err = db.Table("opens").
+		Joins("JOIN subscribers ON subscribers.user_id = opens.user_id AND subscribers.email = opens.recipient").
+		Where("opens.user_id = ? AND opens.ip_address = ? AND opens.created_at >= ? AND subscribers.timezone <> ''", o.UserID, o.IPAddress, since).
+		Group("subscribers.timezone").
+		Order("count(*) DESC").
+		Limit(1).
+		Pluck("subscribers.timezone", &timezones).Error
+	if err != nil {
+		return fmt.Errorf("store: infer time zone: %w", err)
+	}
+	if len(timezones) == 0 {
+		return nil
+	}
+
+	return db.Model(&entities.Subscriber{}).
+		Where("user_id = ? AND email = ? AND timezone = ''", o.UserID, o.Recipient).
+		Update("timezone", timezones[0]).Error
+}
+
+// GetOpenTimesByRecipients returns the times of the opens since the given time, grouped by the recipient.
+func (db *store) GetOpenTimesByRecipients(userID int64, recipients []string, since time.Time) (map[string][]time.Time, error) {
+	var opens []entities.Open
+	err := db.Select("recipient, created_at").
+		Where("user_id = ? AND recipient IN (?) AND created_at >= ?", userID, recipients, since).
+		Find(&opens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[string][]time.Time)
+	for _, o := range opens {
+		times[o.Recipient] = append(times[o.Recipient], o.CreatedAt)
+	}
+	return times, nil
 }
@@ -62,4 +62,38 @@ func TestOpens(t *testing.T) {
 	assert.NotNil(t, opensStats)
 	exp := &entities.OpensStats{Unique: 2, Total: 2}
 	assert.Equal(t, exp, opensStats)
+
+	times, err := store.GetOpenTimesByRecipients(1, []string{"jhon@email.com", "jane@doe.com"}, now.Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, times, 1)
+	assert.Len(t, times["jhon@email.com"], 2)
+
+	// the time zone is inferred from the subscribers who opened from the same ip address
+	known := &entities.Subscriber{UserID: 1, Email: "jhon@doe.com", Active: true, MetaJSON: entities.JSON(`{"timezone":"Europe/Berlin"}`)}
+	err = store.CreateSubscriber(known)
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/Berlin", known.Timezone)
+
+	unknown := &entities.Subscriber{UserID: 1, Email: "jane@doe.com", Active: true}
+	err = store.CreateSubscriber(unknown)
+	assert.Nil(t, err)
+
+	err = store.CreateOpen(&entities.Open{UserID: 1, CampaignID: 2, Recipient: "jane@doe.com", IPAddress: "1.1.1.1", CreatedAt: now})
+	assert.Nil(t, err)
+
+	sub, err := store.GetSubscriber(unknown.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/Berlin", sub.Timezone)
+
+	// the opens made by image proxies are not used
+	proxied := &entities.Subscriber{UserID: 1, Email: "bar@doe.com", Active: true}
+	err = store.CreateSubscriber(proxied)
+	assert.Nil(t, err)
+
+	err = store.CreateOpen(&entities.Open{UserID: 1, CampaignID: 2, Recipient: "bar@doe.com", IPAddress: "1.1.1.1", UserAgent: "Mozilla/5.0 (Windows NT 5.1; rv:11.0) Gecko Firefox/11.0 (via ggpht.com GoogleImageProxy)", CreatedAt: now})
+	assert.Nil(t, err)
+
+	sub, err = store.GetSubscriber(proxied.ID, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, sub.Timezone)
 }
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// CreatePartial creates a new partial in the database.
+func (db *store) CreatePartial(p *entities.Partial) error {
+	return db.Create(p).Error
+}
+
+// UpdatePartial edits an existing partial in the database.
+func (db *store) UpdatePartial(p *entities.Partial) error {
+	return db.Where("user_id = ? and id = ?", p.UserID, p.ID).Save(p).Error
+}
+
+// GetPartial returns the partial by the given id and user id.
+func (db *store) GetPartial(id, userID int64) (*entities.Partial, error) {
+	var p = new(entities.Partial)
+	err := db.Where("user_id = ? and id = ?", userID, id).First(p).Error
+	return p, err
+}
+
+// GetPartialByName returns the partial by the given name and user id.
+func (db *store) GetPartialByName(name string, userID int64) (*entities.Partial, error) {
+	var p = new(entities.Partial)
+	err := db.Where("user_id = ? and name = ?", userID, name).First(p).Error
+	return p, err
+}
+
+// GetPartials returns all partials and layouts of the user ordered by name.
+func (db *store) GetPartials(userID int64) ([]entities.Partial, error) {
+	var partials []entities.Partial
+	err := db.Where("user_id = ?", userID).Order("name").Find(&partials).Error
+	return partials, err
+}
+
+// DeletePartial deletes the partial with the given id and user id.
+func (db *store) DeletePartial(id, userID int64) error {
+	return db.Where("user_id = ? and id = ?", userID, id).Delete(&entities.Partial{}).Error
+}
+
+// SetTemplatePartials replaces the names of the partials which are included in the template.
+func (db *store) SetTemplatePartials(userID, templateID int64, names []string) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Where("user_id = ? and template_id = ?", userID, templateID).Delete(&entities.TemplatePartial{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete template partials: %w", err)
+	}
+
+	if len(names) > 0 {
+		tp := make([]entities.TemplatePartial, len(names))
+		for i, n := range names {
+			tp[i] = entities.TemplatePartial{UserID: userID, TemplateID: templateID, Name: n}
+		}
+		err = tx.Create(&tp).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: create template partials: %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetTemplatesByPartials returns the templates which include any of the partials with the given names
+// or which use any of the given layouts.
+func (db *store) GetTemplatesByPartials(userID int64, names []string, layoutIDs []int64) ([]entities.BaseTemplate, error) {
+	var templates []entities.BaseTemplate
+	if len(names) == 0 && len(layoutIDs) == 0 {
+		return templates, nil
+	}
+
+	cond := db.Where("id IN (?)", db.Model(&entities.TemplatePartial{}).
+		Select("template_id").
+		Where("user_id = ? and name IN (?)", userID, names))
+	if len(layoutIDs) > 0 {
+		cond = cond.Or("layout_id IN (?)", layoutIDs)
+	}
+
+	err := db.Where("user_id = ?", userID).
+		Where(cond).
+		Order("name").
+		Find(&templates).Error
+	return templates, err
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestPartials(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	footer := &entities.Partial{UserID: 1, Name: "footer", Content: "Unsubscribe"}
+	err := store.CreatePartial(footer)
+	assert.Nil(t, err)
+
+	layout := &entities.Partial{UserID: 1, Name: "main", Content: "{{> body}}", Layout: true}
+	err = store.CreatePartial(layout)
+	assert.Nil(t, err)
+
+	err = store.CreatePartial(&entities.Partial{UserID: 1, Name: "footer", Content: "duplicate"})
+	assert.NotNil(t, err)
+
+	footer.Content = "Unsubscribe here"
+	err = store.UpdatePartial(footer)
+	assert.Nil(t, err)
+
+	p, err := store.GetPartialByName("footer", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Unsubscribe here", p.Content)
+
+	_, err = store.GetPartial(footer.ID, 2)
+	assert.NotNil(t, err)
+
+	partials, err := store.GetPartials(1)
+	assert.Nil(t, err)
+	assert.Len(t, partials, 2)
+	assert.Equal(t, "footer", partials[0].Name)
+
+	withFooter := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{UserID: 1, Name: "with footer", SubjectPart: "foo"},
+	}
+	err = store.CreateTemplate(withFooter)
+	assert.Nil(t, err)
+	err = store.SetTemplatePartials(1, withFooter.ID, []string{"footer", "header"})
+	assert.Nil(t, err)
+	// the partials are replaced on each save
+	err = store.SetTemplatePartials(1, withFooter.ID, []string{"footer"})
+	assert.Nil(t, err)
+
+	withLayout := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{UserID: 1, Name: "with layout", SubjectPart: "foo"},
+		LayoutID:     &layout.ID,
+	}
+	err = store.CreateTemplate(withLayout)
+	assert.Nil(t, err)
+
+	templates, err := store.GetTemplatesByPartials(1, []string{"footer"}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, templates, 1)
+	assert.Equal(t, "with footer", templates[0].Name)
+
+	templates, err = store.GetTemplatesByPartials(1, nil, []int64{layout.ID})
+	assert.Nil(t, err)
+	assert.Len(t, templates, 1)
+	assert.Equal(t, "with layout", templates[0].Name)
+
+	templates, err = store.GetTemplatesByPartials(1, []string{"header"}, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, templates)
+
+	err = store.DeletePartial(footer.ID, 1)
+	assert.Nil(t, err)
+	_, err = store.GetPartial(footer.ID, 1)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetSubscriberSegments returns the segments which the subscriber is member of, the static
+// segments and the dynamic segments whose rules match the subscriber.
+func (db *store) GetSubscriberSegments(subscriberID, userID int64) ([]entities.Segment, error) {
+	var segments []entities.Segment
+	err := db.Where("user_id = ?", userID).Order("id").Find(&segments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var static []int64
+	err = db.Table("subscribers_segments").
+		Where("subscriber_id = ?", subscriberID).
+		Pluck("segment_id", &static).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var res []entities.Segment
+	for _, seg := range segments {
+		var member bool
+		for _, id := range static {
+			member = member || id == seg.ID
+		}
+
+		rules, err := seg.GetRules()
+		if err != nil {
+			return nil, err
+		}
+		if !member && rules != nil {
+			var count int64
+			err = db.Model(&entities.Subscriber{}).
+				Where("id = ? AND user_id = ?", subscriberID, userID).
+				Scopes(SegmentRulesScope(rules, userID)).
+				Count(&count).Error
+			if err != nil {
+				return nil, err
+			}
+			member = count > 0
+		}
+
+		if member {
+			res = append(res, seg)
+		}
+	}
+
+	return res, nil
+}
+
+// GetSegmentOptOuts returns the ids of the segments which the subscriber has opted out of.
+func (db *store) GetSegmentOptOuts(subscriberID int64) ([]int64, error) {
+	var ids []int64
+	err := db.Model(&entities.SegmentOptOut{}).
+		Where("subscriber_id = ?", subscriberID).
+		Pluck("segment_id", &ids).Error
+	return ids, err
+}
+
+// SaveSubscriberPreferences updates the name, metadata, time zone and the pause of the subscriber
+// and replaces the segment opt-outs.
+func (db *store) SaveSubscriberPreferences(s *entities.Subscriber, optOuts []int64) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := enrollOnMetadataChange(tx, s)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: enroll in automations (metadata changed): %w", err)
+	}
+
+	s.SetTimezoneFromMetadata()
+
+	err = tx.Model(&entities.Subscriber{}).
+		Where("id = ? AND user_id = ?", s.ID, s.UserID).
+		Updates(map[string]interface{}{
+			"name":         s.Name,
+			"metadata":     s.MetaJSON,
+			"paused_until": s.PausedUntil,
+			"timezone":     s.Timezone,
+		}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: update subscriber preferences: %w", err)
+	}
+
+	err = tx.Where("subscriber_id = ?", s.ID).Delete(&entities.SegmentOptOut{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete segment opt-outs: %w", err)
+	}
+
+	for _, id := range optOuts {
+		err = tx.Create(&entities.SegmentOptOut{SegmentID: id, SubscriberID: s.ID}).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: create segment opt-out: %w", err)
+		}
+	}
+
+	return tx.Commit().Error
+}
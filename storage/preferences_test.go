@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSubscriberPreferences(t *testing.T) {
+	db := openTestDb()
+
+	store := From(db)
+
+	jane := &entities.Subscriber{Name: "jane", Email: "jane@example.com", UserID: 1, MetaJSON: []byte(`{"plan":"pro"}`), Active: true}
+	john := &entities.Subscriber{Name: "john", Email: "john@example.com", UserID: 1, MetaJSON: []byte(`{}`), Active: true}
+	for _, s := range []*entities.Subscriber{jane, john} {
+		err := store.CreateSubscriber(s)
+		assert.Nil(t, err)
+	}
+
+	static := &entities.Segment{Name: "static", UserID: 1, Subscribers: []entities.Subscriber{*jane, *john}}
+	err := store.CreateSegment(static)
+	assert.Nil(t, err)
+
+	dynamic := &entities.Segment{Name: "dynamic", UserID: 1, Rules: entities.JSON(`{"match":"all","conditions":[{"field":"metadata.plan","op":"eq","value":"pro"}]}`)}
+	err = store.CreateSegment(dynamic)
+	assert.Nil(t, err)
+
+	segments, err := store.GetSubscriberSegments(jane.ID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, segments, 2)
+
+	segments, err = store.GetSubscriberSegments(john.ID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, segments, 1)
+
+	var timestamp time.Time
+	subs, err := store.GetDistinctSubscribersBySegmentIDs([]int64{static.ID}, 1, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 2)
+
+	// opt out of the static segment
+	err = store.SaveSubscriberPreferences(jane, []int64{static.ID})
+	assert.Nil(t, err)
+
+	optOuts, err := store.GetSegmentOptOuts(jane.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{static.ID}, optOuts)
+
+	subs, err = store.GetDistinctSubscribersBySegmentIDs([]int64{static.ID}, 1, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 1)
+
+	subs, err = store.GetDistinctSubscribersBySegmentIDs([]int64{static.ID, dynamic.ID}, 1, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, subs, 2)
+
+	ids, err := store.GetSegmentMemberIDs([]int64{static.ID}, 1, []int64{jane.ID, john.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{john.ID}, ids)
+
+	ids, err = store.GetSegmentMemberIDs([]int64{static.ID, dynamic.ID}, 1, []int64{jane.ID, john.ID})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []int64{jane.ID, john.ID}, ids)
+
+	// opt out of the dynamic segment and pause john
+	err = store.SaveSubscriberPreferences(jane, []int64{static.ID, dynamic.ID})
+	assert.Nil(t, err)
+
+	john.PausedUntil.SetValid(time.Now().UTC().Add(time.Hour))
+	err = store.SaveSubscriberPreferences(john, nil)
+	assert.Nil(t, err)
+
+	subs, err = store.GetDistinctSubscribersBySegmentIDs([]int64{static.ID, dynamic.ID}, 1, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, subs)
+
+	ids, err = store.GetSegmentMemberIDs([]int64{static.ID, dynamic.ID}, 1, []int64{jane.ID})
+	assert.Nil(t, err)
+	assert.Empty(t, ids)
+
+	john, err = store.GetSubscriber(john.ID, 1)
+	assert.Nil(t, err)
+	assert.True(t, john.IsPaused())
+}
@@ -0,0 +1,49 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// takeTokenScript implements a token bucket, the bucket is stored as a hash with the number
+// of tokens left and the time of the last update in milliseconds. The bucket is refilled with
+// the given rate per second up to the burst size. It returns 0 when a token is taken, otherwise
+// it returns the number of milliseconds to wait until a token is available.
+var takeTokenScript = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", key, "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call("HMSET", key, "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", key, math.ceil(burst * 1000 / rate) + 1000)
+
+return wait
+`)
+
+// TakeToken takes a token from the bucket with the given key. The bucket is shared between
+// all the clients, so it can be used for rate limiting across processes. When the bucket
+// is empty the duration until the next token is available is returned.
+func (rs *RedisStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (time.Duration, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	wait, err := takeTokenScript.Run(rs.client.WithContext(ctx), []string{key}, rate, burst, now).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
@@ -16,6 +16,7 @@ type Store interface {
 	Delete(ctx context.Context, key string) error
 	Exists(ctx context.Context, key string) (bool, error)
 	Expire(ctx context.Context, key string, seconds time.Duration) error
+	TakeToken(ctx context.Context, key string, rate float64, burst int64) (time.Duration, error)
 }
 
 func NewStoreFrom(conf config.Config) (*RedisStore, error) {
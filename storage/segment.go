@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"fmt"
+
 	"github.com/mailbadger/app/entities"
 )
 
@@ -72,6 +74,11 @@ func (db *store) DeleteSegment(id, userID int64) error {
 		return err
 	}
 
+	if err := db.Where("segment_id IN (SELECT id FROM segments WHERE id = ? AND user_id = ?)", id, userID).
+		Delete(&entities.SegmentOptOut{}).Error; err != nil {
+		return err
+	}
+
 	return db.Delete(&l).Error
 }
 
@@ -80,9 +87,41 @@ func (db *store) RemoveSubscribersFromSegment(s *entities.Segment) error {
 	return db.Model(s).Association("Subscribers").Clear()
 }
 
-// AppendSubscribers appends segscribers to the existing association.
+// AppendSubscribers appends segscribers to the existing association, and enrolls
+// the subscribers which joined the segment in the automations triggered by it.
 func (db *store) AppendSubscribers(s *entities.Segment) error {
-	return db.Model(s).Association("Subscribers").Append(s.Subscribers)
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var members []int64
+	err := tx.Table("subscribers_segments").Where("segment_id = ?", s.ID).Pluck("subscriber_id", &members).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: fetch segment members: %w", err)
+	}
+
+	err = tx.Model(s).Association("Subscribers").Append(s.Subscribers)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: append subscribers: %w", err)
+	}
+
+	for _, sub := range s.Subscribers {
+		if containsID(members, sub.ID) {
+			continue
+		}
+		err = enrollInSegmentAutomations(tx, s.UserID, sub.ID, []int64{s.ID})
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: enroll in automations (segment joined): %w", err)
+		}
+	}
+
+	return tx.Commit().Error
 }
 
 // DetachSubscribers deletes the subscribers association by the given subscribers list.
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// SegmentRulesScope returns a scope which filters the subscribers by the segment rules.
+func SegmentRulesScope(rules *entities.SegmentRules, userID int64) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		cond, args, err := compileSegmentRules(db.Dialector.Name(), rules, userID)
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		return db.Where(cond, args...)
+	}
+}
+
+// compileSegmentRules compiles the segment rules to an sql condition on the subscribers table.
+func compileSegmentRules(dialect string, rules *entities.SegmentRules, userID int64) (string, []interface{}, error) {
+	if err := rules.Validate(); err != nil {
+		return "", nil, err
+	}
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, c := range rules.Conditions {
+		cond, a := compileSegmentCondition(dialect, c, userID)
+		conds = append(conds, "("+cond+")")
+		args = append(args, a...)
+	}
+
+	sep := " AND "
+	if rules.Match == entities.RuleMatchAny {
+		sep = " OR "
+	}
+
+	return "(" + strings.Join(conds, sep) + ")", args, nil
+}
+
+// compileSegmentCondition compiles a single validated condition.
+func compileSegmentCondition(dialect string, c entities.SegmentCondition, userID int64) (string, []interface{}) {
+	switch {
+	case c.Field == entities.RuleFieldEmail || c.Field == entities.RuleFieldName:
+		return compileTextCondition("subscribers."+c.Field, c)
+	case strings.HasPrefix(c.Field, entities.RuleFieldMetadataPrefix):
+		// sqlite is not built with the json1 extension, so the value is extracted with a custom function.
+		expr := "CAST(metadata_value(subscribers.metadata, ?) AS TEXT)"
+		var path interface{} = c.MetadataKey()
+		if dialect == "mysql" {
+			expr = "JSON_UNQUOTE(JSON_EXTRACT(subscribers.metadata, ?))"
+			path = fmt.Sprintf(`$."%s"`, c.MetadataKey())
+		}
+
+		switch c.Op {
+		case entities.RuleOpExists:
+			return expr + " IS NOT NULL", []interface{}{path}
+		case entities.RuleOpNotExists:
+			return expr + " IS NULL", []interface{}{path}
+		case entities.RuleOpNotEquals:
+			return expr + " IS NULL OR " + expr + " <> ?", []interface{}{path, path, c.Value}
+		default:
+			cond, args := compileTextCondition(expr, c)
+			return cond, append([]interface{}{path}, args...)
+		}
+	case c.Field == entities.RuleFieldCreatedAt:
+		days, _ := c.IntValue()
+		t := time.Now().UTC().AddDate(0, 0, -days)
+		if c.Op == entities.RuleOpOlderThanDays {
+			return "subscribers.created_at < ?", []interface{}{t}
+		}
+		return "subscribers.created_at >= ?", []interface{}{t}
+	case c.Field == entities.RuleFieldOpened || c.Field == entities.RuleFieldClicked:
+		table := "opens"
+		if c.Field == entities.RuleFieldClicked {
+			table = "clicks"
+		}
+		n, _ := c.IntValue()
+		// the campaign ids are selected from a derived table because mysql doesn't support limit in IN subqueries.
+		sub := fmt.Sprintf(`SELECT recipient FROM %s WHERE user_id = ? AND campaign_id IN (
+			SELECT id FROM (
+				SELECT id FROM campaigns WHERE user_id = ? AND status = ? ORDER BY completed_at DESC, id DESC LIMIT %d
+			) AS last_campaigns
+		)`, table, n)
+		args := []interface{}{userID, userID, entities.StatusSent}
+		if c.Op == entities.RuleOpNoneOfLast {
+			return "subscribers.email NOT IN (" + sub + ")", args
+		}
+		return "subscribers.email IN (" + sub + ")", args
+	default:
+		// bounced and complained
+		table := "bounces"
+		if c.Field == entities.RuleFieldComplained {
+			table = "complaints"
+		}
+		sub := fmt.Sprintf("SELECT recipient FROM %s WHERE user_id = ?", table)
+		if is, _ := c.Value.(bool); !is {
+			return "subscribers.email NOT IN (" + sub + ")", []interface{}{userID}
+		}
+		return "subscribers.email IN (" + sub + ")", []interface{}{userID}
+	}
+}
+
+func compileTextCondition(expr string, c entities.SegmentCondition) (string, []interface{}) {
+	v, _ := c.Value.(string)
+	switch c.Op {
+	case entities.RuleOpNotEquals:
+		return expr + " <> ?", []interface{}{v}
+	case entities.RuleOpContains:
+		r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+		return expr + " LIKE ? ESCAPE '!'", []interface{}{"%" + r.Replace(v) + "%"}
+	default:
+		return expr + " = ?", []interface{}{v}
+	}
+}
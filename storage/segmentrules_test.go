@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSegmentRules(t *testing.T) {
+	db := openTestDb()
+
+	store := From(db)
+
+	subs := []*entities.Subscriber{
+		{Name: "john", Email: "john@example.com", UserID: 1, MetaJSON: []byte(`{"plan":"pro"}`), Active: true},
+		{Name: "jane", Email: "jane@example.com", UserID: 1, MetaJSON: []byte(`{"plan":"free"}`), Active: true},
+		{Name: "bob", Email: "bob@foo.com", UserID: 1, MetaJSON: []byte(`{}`), Active: true},
+		{Name: "inactive", Email: "inactive@example.com", UserID: 1, MetaJSON: []byte(`{"plan":"pro"}`), Active: false},
+	}
+	for _, s := range subs {
+		err := store.CreateSubscriber(s)
+		assert.Nil(t, err)
+	}
+
+	c := &entities.Campaign{Name: "foo", UserID: 1, Status: entities.StatusSent}
+	err := store.CreateCampaign(c)
+	assert.Nil(t, err)
+
+	err = store.CreateOpen(&entities.Open{UserID: 1, CampaignID: c.ID, Recipient: "john@example.com", CreatedAt: time.Now()})
+	assert.Nil(t, err)
+	err = store.CreateBounce(&entities.Bounce{UserID: 1, CampaignID: c.ID, Recipient: "jane@example.com", CreatedAt: time.Now()})
+	assert.Nil(t, err)
+
+	cases := []struct {
+		desc     string
+		rules    entities.SegmentRules
+		expected int64
+	}{
+		{
+			desc: "metadata equals",
+			rules: entities.SegmentRules{Match: entities.RuleMatchAll, Conditions: []entities.SegmentCondition{
+				{Field: "metadata.plan", Op: entities.RuleOpEquals, Value: "pro"},
+			}},
+			expected: 1,
+		},
+		{
+			desc: "metadata not equals",
+			rules: entities.SegmentRules{Match: entities.RuleMatchAll, Conditions: []entities.SegmentCondition{
+				{Field: "metadata.plan", Op: entities.RuleOpNotEquals, Value: "pro"},
+			}},
+			expected: 2,
+		},
+		{
+			desc: "metadata not exists",
+			rules: entities.SegmentRules{Match: entities.RuleMatchAll, Conditions: []entities.SegmentCondition{
+				{Field: "metadata.plan", Op: entities.RuleOpNotExists},
+			}},
+			expected: 1,
+		},
+		{
+			desc: "email contains",
+			rules: entities.SegmentRules{Match: entities.RuleMatchAll, Conditions: []entities.SegmentCondition{
+				{Field: entities.RuleFieldEmail, Op: entities.RuleOpContains, Value: "@example"},
+			}},
+			expected: 2,
+		},
+		{
+			desc: "created within days",
+			rules: entities.SegmentRules{Match: entities.RuleMatchAll, Conditions: []entities.SegmentCondition{
+				{Field: entities.RuleFieldCreatedAt, Op: entities.RuleOpWithinDays, Value: float64(1)},
+			}},
+			expected: 3,
+		},
+		{
+			desc: "opened any of last campaigns",
+			rules: entities.SegmentRules{Match: entities.RuleMatchAll, Conditions: []entities.SegmentCondition{
+				{Field: entities.RuleFieldOpened, Op: entities.RuleOpAnyOfLast, Value: float64(3)},
+			}},
+			expected: 1,
+		},
+		{
+			desc: "not bounced and not opened",
+			rules: entities.SegmentRules{Match: entities.RuleMatchAll, Conditions: []entities.SegmentCondition{
+				{Field: entities.RuleFieldBounced, Op: entities.RuleOpIs, Value: false},
+				{Field: entities.RuleFieldOpened, Op: entities.RuleOpNoneOfLast, Value: float64(3)},
+			}},
+			expected: 1,
+		},
+		{
+			desc: "match any",
+			rules: entities.SegmentRules{Match: entities.RuleMatchAny, Conditions: []entities.SegmentCondition{
+				{Field: entities.RuleFieldBounced, Op: entities.RuleOpIs, Value: true},
+				{Field: entities.RuleFieldName, Op: entities.RuleOpEquals, Value: "bob"},
+			}},
+			expected: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			total, err := store.CountSubscribersBySegmentRules(1, &tc.rules)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, total)
+		})
+	}
+
+	_, err = store.CountSubscribersBySegmentRules(1, &entities.SegmentRules{Match: "foo"})
+	assert.ErrorIs(t, err, entities.ErrInvalidSegmentRules)
+
+	// Test merging static and dynamic segments
+	static := &entities.Segment{Name: "static", UserID: 1, Subscribers: []entities.Subscriber{*subs[2]}}
+	err = store.CreateSegment(static)
+	assert.Nil(t, err)
+
+	dynamic := &entities.Segment{Name: "dynamic", UserID: 1, Rules: entities.JSON(`{"match":"all","conditions":[{"field":"metadata.plan","op":"exists"}]}`)}
+	err = store.CreateSegment(dynamic)
+	assert.Nil(t, err)
+
+	var timestamp time.Time
+	res, err := store.GetDistinctSubscribersBySegmentIDs([]int64{static.ID, dynamic.ID}, 1, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, res, 3)
+
+	res, err = store.GetDistinctSubscribersBySegmentIDs([]int64{dynamic.ID}, 1, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, res, 2)
+
+	res, err = store.GetDistinctSubscribersBySegmentIDs([]int64{dynamic.ID}, 2, false, true, timestamp, 0, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, res)
+}
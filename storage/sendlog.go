@@ -1,6 +1,10 @@
 package storage
 
 import (
+	"time"
+
+	"github.com/segmentio/ksuid"
+
 	"github.com/mailbadger/app/entities"
 )
 
@@ -26,3 +30,19 @@ func (db *store) GetSendLogByUUID(id string) (*entities.SendLog, error) {
 	err := db.Where("id = ?", id).First(log).Error
 	return log, err
 }
+
+// CountSendLogsSince returns the number of send logs of the user created after the given time.
+func (db *store) CountSendLogsSince(userID int64, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&entities.SendLog{}).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountSendLogsByEventID returns the number of send logs of the given campaign event.
+func (db *store) CountSendLogsByEventID(eventID ksuid.KSUID) (int64, error) {
+	var count int64
+	err := db.Model(&entities.SendLog{}).Where("event_id = ?", eventID).Count(&count).Error
+	return count, err
+}
@@ -60,4 +60,19 @@ func TestSendLogs(t *testing.T) {
 	n, err := store.CountLogsByStatus(entities.SendLogStatusFailed)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(2), n)
+	n, err = store.CountSendLogsSince(1, now.Add(-time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+
+	n, err = store.CountSendLogsSince(1, now.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+
+	n, err = store.CountSendLogsByEventID(sendLogs[0].EventID)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = store.CountSendLogsByEventID(ksuid.New())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
 }
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetSendRate returns the send rate limits by the given user id.
+func (db *store) GetSendRate(userID int64) (*entities.SendRate, error) {
+	var r = new(entities.SendRate)
+	err := db.Where("user_id = ?", userID).First(r).Error
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetMaxSendRate creates or updates the send rate cap configured by the user.
+func (db *store) SetMaxSendRate(userID int64, rate float64) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_send_rate", "updated_at"}),
+	}).Create(&entities.SendRate{
+		UserID:      userID,
+		MaxSendRate: rate,
+	}).Error
+}
+
+// SetQuotaSendRate creates or updates the send rate quota of the user's SES account.
+func (db *store) SetQuotaSendRate(userID int64, rate float64) error {
+	r := &entities.SendRate{
+		UserID:        userID,
+		QuotaSendRate: rate,
+	}
+	r.QuotaUpdatedAt.SetValid(time.Now().UTC())
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"quota_send_rate", "quota_updated_at", "updated_at"}),
+	}).Create(r).Error
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendRate(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	_, err := store.GetSendRate(1)
+	assert.NotNil(t, err)
+
+	err = store.SetMaxSendRate(1, 5)
+	assert.Nil(t, err)
+
+	err = store.SetQuotaSendRate(1, 14)
+	assert.Nil(t, err)
+
+	r, err := store.GetSendRate(1)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(5), r.MaxSendRate)
+	assert.Equal(t, float64(14), r.QuotaSendRate)
+	assert.True(t, r.QuotaUpdatedAt.Valid)
+
+	// updating the cap doesn't change the quota
+	err = store.SetMaxSendRate(1, 0)
+	assert.Nil(t, err)
+
+	r, err = store.GetSendRate(1)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), r.MaxSendRate)
+	assert.Equal(t, float64(14), r.QuotaSendRate)
+	assert.Equal(t, float64(14), r.Rate())
+}
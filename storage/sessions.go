@@ -1,6 +1,10 @@
 package storage
 
 import (
+	"time"
+
+	"gorm.io/gorm"
+
 	"github.com/mailbadger/app/entities"
 )
 
@@ -22,7 +26,61 @@ func (db *store) CreateSession(s *entities.Session) error {
 	return db.Create(s).Error
 }
 
+// GetUserSessions returns the active sessions of the given user, which were used after lastActivityAfter
+// and created after createdAfter, the zero times are ignored. The pending sessions of the two-factor
+// authentication are excluded.
+func (db *store) GetUserSessions(userID int64, lastActivityAfter, createdAfter time.Time) ([]entities.Session, error) {
+	var sessions []entities.Session
+	q := db.Where("user_id = ? AND two_factor_pending = ?", userID, false)
+	if !lastActivityAfter.IsZero() {
+		q = q.Where("last_activity_at > ?", lastActivityAfter)
+	}
+	if !createdAfter.IsZero() {
+		q = q.Where("created_at > ?", createdAfter)
+	}
+	err := q.Order("last_activity_at desc, id desc").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// GetUserSessionByID returns the active session by the given id and user id.
+func (db *store) GetUserSessionByID(id, userID int64) (*entities.Session, error) {
+	var s = new(entities.Session)
+	err := db.Where("id = ? AND user_id = ? AND two_factor_pending = ?", id, userID, false).First(s).Error
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdateSessionLastActivity sets the time of the last request made with the session.
+func (db *store) UpdateSessionLastActivity(sessionID string, t time.Time) error {
+	return db.Model(&entities.Session{}).
+		Where("session_id = ?", sessionID).
+		UpdateColumn("last_activity_at", t).Error
+}
+
+// IncrementTwoFactorAttempts increments the number of the codes which were entered in the session
+// and returns it.
+func (db *store) IncrementTwoFactorAttempts(sessionID string) (int, error) {
+	err := db.Model(&entities.Session{}).
+		Where("session_id = ?", sessionID).
+		UpdateColumn("two_factor_attempts", gorm.Expr("two_factor_attempts + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var s entities.Session
+	err = db.Select("two_factor_attempts").Where("session_id = ?", sessionID).First(&s).Error
+	return s.TwoFactorAttempts, err
+}
+
 // DeleteSession deletes a session by the given session id from the database.
 func (db *store) DeleteSession(sessionID string) error {
 	return db.Where("session_id = ?", sessionID).Delete(&entities.Session{}).Error
 }
+
+// DeleteOtherUserSessions deletes all sessions of the given user, except the one with the given session id.
+func (db *store) DeleteOtherUserSessions(userID int64, sessionID string) error {
+	return db.Where("user_id = ? AND session_id <> ?", userID, sessionID).Delete(&entities.Session{}).Error
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
@@ -34,6 +35,13 @@ func TestSessions(t *testing.T) {
 	assert.NotNil(t, sess.User.Boundaries)
 	assert.Equal(t, sess.User.Boundaries.Type, entities.BoundaryTypeNoLimit)
 
+	attempts, err := store.IncrementTwoFactorAttempts("foobar")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, attempts)
+	attempts, err = store.IncrementTwoFactorAttempts("foobar")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+
 	err = store.DeleteSession("foobar")
 	assert.Nil(t, err)
 
@@ -41,3 +49,62 @@ func TestSessions(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.True(t, errors.Is(gorm.ErrRecordNotFound, err))
 }
+
+func TestUserSessions(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	now := time.Now()
+	for _, s := range []*entities.Session{
+		{UserID: 1, SessionID: "foo", IP: "127.0.0.1", UserAgent: "curl", LastActivityAt: now.Add(-time.Hour)},
+		{UserID: 1, SessionID: "bar", LastActivityAt: now},
+		{UserID: 1, SessionID: "pending", TwoFactorPending: true, LastActivityAt: now},
+		{UserID: 1, SessionID: "idle", LastActivityAt: now.Add(-48 * time.Hour)},
+		{UserID: 1, SessionID: "old", LastActivityAt: now, CreatedAt: now.Add(-100 * time.Hour)},
+		{UserID: 2, SessionID: "baz", LastActivityAt: now},
+	} {
+		err := store.CreateSession(s)
+		assert.Nil(t, err)
+	}
+
+	// the expired sessions are included only without the thresholds
+	sessions, err := store.GetUserSessions(1, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 4)
+
+	sessions, err = store.GetUserSessions(1, now.Add(-24*time.Hour), now.Add(-72*time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, "bar", sessions[0].SessionID)
+	assert.Equal(t, "foo", sessions[1].SessionID)
+	assert.Equal(t, "127.0.0.1", sessions[1].IP)
+	assert.Equal(t, "curl", sessions[1].UserAgent)
+
+	foo, err := store.GetUserSessionByID(sessions[1].ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", foo.SessionID)
+
+	_, err = store.GetUserSessionByID(sessions[1].ID, 2)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	err = store.UpdateSessionLastActivity("foo", now.Add(time.Minute))
+	assert.Nil(t, err)
+
+	sessions, err = store.GetUserSessions(1, now.Add(-24*time.Hour), now.Add(-72*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", sessions[0].SessionID)
+
+	err = store.DeleteOtherUserSessions(1, "bar")
+	assert.Nil(t, err)
+
+	sessions, err = store.GetUserSessions(1, now.Add(-24*time.Hour), now.Add(-72*time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "bar", sessions[0].SessionID)
+
+	_, err = store.GetSession("pending")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	_, err = store.GetSession("baz")
+	assert.Nil(t, err)
+}
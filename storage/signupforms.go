@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetSignupForm returns the signup form settings by the given user id.
+func (db *store) GetSignupForm(userID int64) (*entities.SignupForm, error) {
+	var f = new(entities.SignupForm)
+	err := db.Where("user_id = ?", userID).First(f).Error
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SaveSignupForm creates or updates the signup form settings.
+func (db *store) SaveSignupForm(f *entities.SignupForm) error {
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"enabled",
+			"recaptcha",
+			"confirm_template_id",
+			"success_template_id",
+			"segment_ids",
+			"updated_at",
+		}),
+	}).Create(f).Error
+}
+
+// CreateSubscriberConsent creates a new subscriber consent.
+func (db *store) CreateSubscriberConsent(c *entities.SubscriberConsent) error {
+	return db.Create(c).Error
+}
+
+// GetSubscriberConsentByTokenID returns the subscriber consent which is confirmed by the given token.
+func (db *store) GetSubscriberConsentByTokenID(tokenID int64) (*entities.SubscriberConsent, error) {
+	var c = new(entities.SubscriberConsent)
+	err := db.Where("token_id = ?", tokenID).First(c).Error
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetSubscriberConsents returns the consents of the subscriber ordered by the latest first.
+func (db *store) GetSubscriberConsents(subscriberID, userID int64) ([]entities.SubscriberConsent, error) {
+	var consents []entities.SubscriberConsent
+	err := db.Where("subscriber_id = ? AND user_id = ?", subscriberID, userID).
+		Order("id DESC").
+		Find(&consents).Error
+	return consents, err
+}
+
+// ConfirmSubscription marks the consent as confirmed, activates the subscriber, adds it
+// to the given segments and deletes the confirmation token.
+func (db *store) ConfirmSubscription(c *entities.SubscriberConsent, segmentIDs []int64) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	c.ConfirmedAt.SetValid(time.Now().UTC())
+	err := tx.Model(c).Select("confirm_ip", "confirmed_at").Updates(c).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: confirm consent: %w", err)
+	}
+
+	err = tx.Model(&entities.Subscriber{}).
+		Where("id = ? AND user_id = ?", c.SubscriberID, c.UserID).
+		Update("active", true).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: activate subscriber: %w", err)
+	}
+
+	for _, id := range segmentIDs {
+		err = tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Table("subscribers_segments").
+			Create(map[string]interface{}{"segment_id": id, "subscriber_id": c.SubscriberID}).Error
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: add subscriber to segment: %w", err)
+		}
+	}
+
+	err = tx.Delete(&entities.Token{}, "id = ?", c.TokenID).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete confirm token: %w", err)
+	}
+
+	return tx.Commit().Error
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestSignupForm(t *testing.T) {
+	db := openTestDb()
+
+	store := From(db)
+
+	_, err := store.GetSignupForm(1)
+	assert.NotNil(t, err)
+
+	err = store.SaveSignupForm(&entities.SignupForm{UserID: 1, Enabled: true, SegmentIDs: entities.JSON(`[1]`)})
+	assert.Nil(t, err)
+
+	err = store.SaveSignupForm(&entities.SignupForm{UserID: 1, Enabled: true, Recaptcha: true, SegmentIDs: entities.JSON(`[1,2]`)})
+	assert.Nil(t, err)
+
+	f, err := store.GetSignupForm(1)
+	assert.Nil(t, err)
+	assert.True(t, f.Recaptcha)
+	ids, err := f.GetSegmentIDs()
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+}
+
+func TestConfirmSubscription(t *testing.T) {
+	db := openTestDb()
+
+	store := From(db)
+
+	seg := &entities.Segment{Name: "newsletter", UserID: 1}
+	err := store.CreateSegment(seg)
+	assert.Nil(t, err)
+
+	sub := &entities.Subscriber{Email: "jane@example.com", UserID: 1}
+	err = store.CreateSubscriber(sub)
+	assert.Nil(t, err)
+
+	token := &entities.Token{
+		UserID:    1,
+		Token:     "foo",
+		Type:      entities.ConfirmSubscriptionTokenType,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	err = store.CreateToken(token)
+	assert.Nil(t, err)
+
+	c := &entities.SubscriberConsent{
+		UserID:       1,
+		SubscriberID: sub.ID,
+		TokenID:      token.ID,
+		SignupIP:     "127.0.0.1",
+		UserAgent:    "test",
+	}
+	err = store.CreateSubscriberConsent(c)
+	assert.Nil(t, err)
+
+	c, err = store.GetSubscriberConsentByTokenID(token.ID)
+	assert.Nil(t, err)
+	assert.False(t, c.ConfirmedAt.Valid)
+
+	c.ConfirmIP = "127.0.0.2"
+	err = store.ConfirmSubscription(c, []int64{seg.ID})
+	assert.Nil(t, err)
+
+	sub, err = store.GetSubscriber(sub.ID, 1)
+	assert.Nil(t, err)
+	assert.True(t, sub.Active)
+
+	total, err := store.GetTotalSubscribersBySegment(seg.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+
+	_, err = store.GetToken("foo")
+	assert.NotNil(t, err)
+
+	consents, err := store.GetSubscriberConsents(sub.ID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, consents, 1)
+	assert.Equal(t, "127.0.0.2", consents[0].ConfirmIP)
+	assert.True(t, consents[0].ConfirmedAt.Valid)
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"github.com/mailbadger/app/entities"
+)
+
+// GetSSOConnections returns the single sign-on connections of the user.
+func (db *store) GetSSOConnections(userID int64) ([]entities.SSOConnection, error) {
+	var conns []entities.SSOConnection
+	err := db.Where("user_id = ?", userID).Order("id").Find(&conns).Error
+	return conns, err
+}
+
+// GetSSOConnection returns the connection by the given id and user id.
+func (db *store) GetSSOConnection(id, userID int64) (*entities.SSOConnection, error) {
+	var conn = new(entities.SSOConnection)
+	err := db.Where("id = ? and user_id = ?", id, userID).First(conn).Error
+	return conn, err
+}
+
+// GetSSOConnectionByID returns the connection by the given id, it is used by the sign in
+// which is not scoped to a user.
+func (db *store) GetSSOConnectionByID(id int64) (*entities.SSOConnection, error) {
+	var conn = new(entities.SSOConnection)
+	err := db.Where("id = ?", id).First(conn).Error
+	return conn, err
+}
+
+// GetSSOConnectionByDomain returns the verified connection of the email domain, the pending
+// connections of the domain are not returned.
+func (db *store) GetSSOConnectionByDomain(domain string) (*entities.SSOConnection, error) {
+	var conn = new(entities.SSOConnection)
+	err := db.Where("domain = ? and verified_at is not null", domain).First(conn).Error
+	return conn, err
+}
+
+// CreateSSOConnection creates a new single sign-on connection.
+func (db *store) CreateSSOConnection(conn *entities.SSOConnection) error {
+	return db.Create(conn).Error
+}
+
+// UpdateSSOConnection edits the connection of the user.
+func (db *store) UpdateSSOConnection(conn *entities.SSOConnection) error {
+	return db.Where("id = ? and user_id = ?", conn.ID, conn.UserID).Save(conn).Error
+}
+
+// DeleteSSOConnection deletes the connection with the given id and user id.
+func (db *store) DeleteSSOConnection(id, userID int64) error {
+	return db.Where("id = ? and user_id = ?", id, userID).Delete(&entities.SSOConnection{}).Error
+}
+
+// GetEnforcedSSOConnections returns the verified and enforced connections of the teams which the user is a member of.
+// The connections of the user's own account are not returned, so the owners can still sign in with
+// a password when the identity provider is down.
+func (db *store) GetEnforcedSSOConnections(userID int64) ([]entities.SSOConnection, error) {
+	var conns []entities.SSOConnection
+	err := db.Select("sso_connections.*").
+		Joins("join teams on teams.user_id = sso_connections.user_id").
+		Joins("join team_members on team_members.team_id = teams.id").
+		Where("team_members.user_id = ? and sso_connections.enforced = ?", userID, true).
+		Where("sso_connections.verified_at is not null").
+		Find(&conns).Error
+	return conns, err
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mailbadger/app/entities"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSSOConnections(t *testing.T) {
+	db := openTestDb()
+
+	store := From(db)
+
+	team := &entities.Team{UserID: 1, Name: "acme"}
+	err := store.CreateTeam(team)
+	assert.Nil(t, err)
+
+	roles, err := store.GetTeamRoles(team.ID)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, roles)
+
+	conns, err := store.GetSSOConnections(1)
+	assert.Nil(t, err)
+	assert.Empty(t, conns)
+
+	conn := &entities.SSOConnection{
+		UserID:        1,
+		Protocol:      entities.SSOProtocolOIDC,
+		Domain:        "acme.com",
+		DefaultRoleID: roles[0].ID,
+		RoleAttribute: "groups",
+		RoleMappings: entities.SSORoleMappings{
+			{Value: "admins", RoleID: roles[1].ID},
+		},
+		Issuer:   "https://idp.acme.com",
+		ClientID: "client",
+	}
+	err = store.CreateSSOConnection(conn)
+	assert.Nil(t, err)
+
+	// the domain can be claimed by several pending connections
+	err = store.CreateSSOConnection(&entities.SSOConnection{
+		UserID:        2,
+		Protocol:      entities.SSOProtocolSAML,
+		Domain:        "acme.com",
+		DefaultRoleID: roles[0].ID,
+	})
+	assert.Nil(t, err)
+
+	// only the verified connections are returned by the domain
+	_, err = store.GetSSOConnectionByDomain("acme.com")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	conn.VerifiedAt = entities.TimeFrom(time.Now())
+	err = store.UpdateSSOConnection(conn)
+	assert.Nil(t, err)
+
+	conn, err = store.GetSSOConnectionByDomain("acme.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://idp.acme.com", conn.Issuer)
+	assert.Equal(t, roles[1].ID, conn.RoleID([]string{"users", "Admins"}))
+	assert.Equal(t, roles[0].ID, conn.RoleID(nil))
+
+	_, err = store.GetSSOConnection(conn.ID, 2)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	u := &entities.User{
+		UUID:       "e3b0c442-98fc-4c14-9afb-f4c8996fb924",
+		Username:   "jane@acme.com",
+		Active:     true,
+		Verified:   true,
+		Source:     entities.SSOProtocolOIDC,
+		BoundaryID: 1,
+	}
+	err = store.CreateUser(u)
+	assert.Nil(t, err)
+
+	err = store.CreateTeamMember(&entities.TeamMember{TeamID: team.ID, UserID: u.ID, RoleID: roles[0].ID})
+	assert.Nil(t, err)
+
+	conns, err = store.GetEnforcedSSOConnections(u.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, conns)
+
+	conn.Enforced = true
+	err = store.UpdateSSOConnection(conn)
+	assert.Nil(t, err)
+
+	conns, err = store.GetEnforcedSSOConnections(u.ID)
+	assert.Nil(t, err)
+	assert.Len(t, conns, 1)
+	assert.Equal(t, conn.ID, conns[0].ID)
+	assert.Equal(t, entities.SSORoleMappings{{Value: "admins", RoleID: roles[1].ID}}, conns[0].RoleMappings)
+
+	// the owner is not restricted
+	conns, err = store.GetEnforcedSSOConnections(1)
+	assert.Nil(t, err)
+	assert.Empty(t, conns)
+
+	err = store.DeleteSSOConnection(conn.ID, 1)
+	assert.Nil(t, err)
+
+	_, err = store.GetSSOConnectionByID(conn.ID)
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+}
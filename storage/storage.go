@@ -3,6 +3,8 @@ package storage
 import (
 	"time"
 
+	"github.com/segmentio/ksuid"
+
 	"github.com/mailbadger/app/entities"
 )
 
@@ -21,14 +23,21 @@ type Storage interface {
 	GetRole(name string) (*entities.Role, error)
 
 	GetSession(sessionID string) (*entities.Session, error)
+	GetUserSessions(userID int64, lastActivityAfter, createdAfter time.Time) ([]entities.Session, error)
+	GetUserSessionByID(id, userID int64) (*entities.Session, error)
 	CreateSession(s *entities.Session) error
+	UpdateSessionLastActivity(sessionID string, t time.Time) error
+	IncrementTwoFactorAttempts(sessionID string) (int, error)
 	DeleteSession(sessionID string) error
+	DeleteOtherUserSessions(userID int64, sessionID string) error
 
 	GetCampaigns(int64, *PaginationCursor, map[string]string) error
 	GetCampaign(int64, int64) (*entities.Campaign, error)
+	GetCampaignInstances(parentID, userID int64, p *PaginationCursor) error
 	GetCampaignByName(name string, userID int64) (*entities.Campaign, error)
 	CreateCampaign(*entities.Campaign) error
 	UpdateCampaign(*entities.Campaign) error
+	UpdateCampaignStatus(id, userID int64, status string, from ...string) (bool, error)
 	DeleteCampaign(int64, int64) error
 	GetMonthlyTotalCampaigns(userID int64) (int64, error)
 	GetCampaignOpens(campaignID, userID int64, p *PaginationCursor) error
@@ -46,6 +55,29 @@ type Storage interface {
 	CreateCampaignSchedule(c *entities.CampaignSchedule) error
 	DeleteCampaignSchedule(campaignID int64) error
 	GetScheduledCampaigns(time time.Time) ([]entities.CampaignSchedule, error)
+	UpdateCampaignSchedule(c *entities.CampaignSchedule) error
+	CreateCampaignInstance(c *entities.Campaign, s *entities.CampaignSchedule) error
+
+	GetCampaignCheckpoint(eventID ksuid.KSUID) (*entities.CampaignCheckpoint, error)
+	SaveCampaignCheckpoint(cp *entities.CampaignCheckpoint) error
+	ResumeCampaignCheckpoint(eventID ksuid.KSUID) (bool, error)
+	PauseCampaignCheckpoint(eventID ksuid.KSUID) error
+	IncrementCampaignCheckpoint(eventID ksuid.KSUID, enqueued, failed int64) error
+
+	CreateCampaignBatches(batches []entities.CampaignBatch) error
+	GetCampaignBatch(id int64) (*entities.CampaignBatch, error)
+	GetCampaignBatches(eventID ksuid.KSUID, userID int64) ([]entities.CampaignBatch, error)
+	GetDueCampaignBatches(now time.Time, limit int) ([]entities.CampaignBatch, error)
+	UpdateCampaignBatchStatus(id int64, status, oldStatus string) (bool, error)
+	CountUnsentCampaignBatches(eventID ksuid.KSUID) (int64, error)
+
+	GetABTest(campaignID, userID int64) (*entities.ABTest, error)
+	GetABTestsToPickWinner(t time.Time) ([]entities.ABTest, error)
+	SaveABTest(t *entities.ABTest) error
+	UpdateABTest(t *entities.ABTest) error
+	UpdateVariantTemplateVersions(variants []entities.CampaignVariant) error
+	DeleteABTest(campaignID, userID int64) error
+	GetVariantStats(campaignID, userID, variantID int64) (*entities.CampaignStats, error)
 
 	GetSegments(int64, *PaginationCursor) error
 	GetSegmentsByIDs(userID int64, ids []int64) ([]entities.Segment, error)
@@ -62,6 +94,7 @@ type Storage interface {
 	GetSubscribersBySegmentID(int64, int64, *PaginationCursor) error
 	GetSubscriber(int64, int64) (*entities.Subscriber, error)
 	GetSubscribersByIDs([]int64, int64) ([]entities.Subscriber, error)
+	GetSegmentMemberIDs(segmentIDs []int64, userID int64, subscriberIDs []int64) ([]int64, error)
 	GetSubscriberByEmail(string, int64) (*entities.Subscriber, error)
 	GetDistinctSubscribersBySegmentIDs(
 		listIDs []int64,
@@ -70,6 +103,10 @@ type Storage interface {
 		timestamp time.Time,
 		nextID, limit int64,
 	) ([]entities.Subscriber, error)
+	CountSubscribersBySegmentRules(userID int64, rules *entities.SegmentRules) (int64, error)
+	GetSubscriberSegments(subscriberID, userID int64) ([]entities.Segment, error)
+	GetSegmentOptOuts(subscriberID int64) ([]int64, error)
+	SaveSubscriberPreferences(s *entities.Subscriber, optOuts []int64) error
 	CreateSubscriber(*entities.Subscriber) error
 	UpdateSubscriber(*entities.Subscriber) error
 	DeactivateSubscriber(userID int64, email string) error
@@ -80,15 +117,29 @@ type Storage interface {
 	SeekSubscribersByUserID(userID int64, nextID int64, limit int64) ([]entities.Subscriber, error)
 
 	GetAPIKeys(userID int64) ([]*entities.APIKey, error)
-	GetAPIKey(identifier string) (*entities.APIKey, error)
+	GetAPIKeyByID(id, userID int64) (*entities.APIKey, error)
+	GetAPIKey(key string) (*entities.APIKey, error)
 	CreateAPIKey(ak *entities.APIKey) error
 	UpdateAPIKey(ak *entities.APIKey) error
+	UpdateAPIKeyLastUsedAt(id int64, t time.Time) error
 	DeleteAPIKey(id, userID int64) error
 
 	GetSesKeys(userID int64) (*entities.SesKeys, error)
 	CreateSesKeys(s *entities.SesKeys) error
 	DeleteSesKeys(userID int64) error
 
+	GetDeliveryProvider(userID int64) (*entities.DeliveryProvider, error)
+	GetDeliveryProviderByID(id, userID int64) (*entities.DeliveryProvider, error)
+	SaveDeliveryProvider(p *entities.DeliveryProvider) error
+	DeleteDeliveryProvider(userID int64) error
+
+	GetSignupForm(userID int64) (*entities.SignupForm, error)
+	SaveSignupForm(f *entities.SignupForm) error
+	CreateSubscriberConsent(c *entities.SubscriberConsent) error
+	GetSubscriberConsentByTokenID(tokenID int64) (*entities.SubscriberConsent, error)
+	GetSubscriberConsents(subscriberID, userID int64) ([]entities.SubscriberConsent, error)
+	ConfirmSubscription(c *entities.SubscriberConsent, segmentIDs []int64) error
+
 	GetToken(token string) (*entities.Token, error)
 	CreateToken(s *entities.Token) error
 	DeleteToken(token string) error
@@ -97,14 +148,52 @@ type Storage interface {
 	CountLogsByUUID(id string) (int64, error)
 	CountLogsByStatus(status string) (int64, error)
 	GetSendLogByUUID(id string) (*entities.SendLog, error)
+	CountSendLogsSince(userID int64, since time.Time) (int64, error)
+	CountSendLogsByEventID(eventID ksuid.KSUID) (int64, error)
+
+	GetSendRate(userID int64) (*entities.SendRate, error)
+	SetMaxSendRate(userID int64, rate float64) error
+	SetQuotaSendRate(userID int64, rate float64) error
 
 	CreateBounce(b *entities.Bounce) error
 	CreateComplaint(c *entities.Complaint) error
 	CreateSend(s *entities.Send) error
 	CreateClick(c *entities.Click) error
 	CreateOpen(o *entities.Open) error
+	GetOpenTimesByRecipients(userID int64, recipients []string, since time.Time) (map[string][]time.Time, error)
 	CreateDelivery(d *entities.Delivery) error
 
+	GetWebhooks(userID int64) ([]entities.Webhook, error)
+	GetWebhook(id, userID int64) (*entities.Webhook, error)
+	CreateWebhook(w *entities.Webhook) error
+	UpdateWebhook(w *entities.Webhook) error
+	DeleteWebhook(id, userID int64) error
+	GetWebhookDeliveries(webhookID, userID int64, p *PaginationCursor) error
+	GetWebhookDelivery(id, webhookID, userID int64) (*entities.WebhookDelivery, error)
+	GetWebhookDeliveryAttempts(deliveryID int64) ([]entities.WebhookDeliveryAttempt, error)
+	CreateWebhookDelivery(d *entities.WebhookDelivery) error
+	GetDueWebhookDeliveries(t time.Time, limit int) ([]entities.WebhookDelivery, error)
+	ClaimWebhookDelivery(id int64, t time.Time, timeout time.Duration) (bool, error)
+	LogWebhookDeliveryAttempt(d *entities.WebhookDelivery, a *entities.WebhookDeliveryAttempt) error
+
+	CreateTransactionalMessage(m *entities.TransactionalMessage) error
+	GetTransactionalMessage(messageID string, userID int64) (*entities.TransactionalMessage, error)
+	GetTransactionalMessageByIdempotencyKey(key string, userID int64) (*entities.TransactionalMessage, error)
+	GetTransactionalEvents(transactionalMessageID int64) ([]entities.TransactionalEvent, error)
+	LogTransactionalEvent(m *entities.TransactionalMessage, e *entities.TransactionalEvent) error
+	IsRecipientSuppressed(userID int64, recipient string) (bool, error)
+
+	GetAutomations(userID int64) ([]entities.Automation, error)
+	GetAutomation(id, userID int64) (*entities.Automation, error)
+	CreateAutomation(a *entities.Automation) error
+	UpdateAutomation(a *entities.Automation) error
+	DeleteAutomation(id, userID int64) error
+	GetAutomationRuns(automationID, userID int64, p *PaginationCursor) error
+	GetAutomationLogs(automationID, userID int64, p *PaginationCursor) error
+	GetDueAutomationRuns(t time.Time, limit int) ([]entities.AutomationRun, error)
+	UpdateAutomationRun(r *entities.AutomationRun) error
+	CreateAutomationLog(l *entities.AutomationLog) error
+
 	CreateReport(r *entities.Report) error
 	UpdateReport(r *entities.Report) error
 	GetReportByFilename(filename string, userID int64) (*entities.Report, error)
@@ -117,4 +206,59 @@ type Storage interface {
 	GetTemplate(templateID int64, userID int64) (*entities.Template, error)
 	GetTemplates(userID int64, p *PaginationCursor, scopeMap map[string]string) error
 	DeleteTemplate(templateID int64, userID int64) error
+
+	CreateTemplateVersion(t *entities.Template, v *entities.TemplateVersion) error
+	GetTemplateVersion(templateID, version, userID int64) (*entities.TemplateVersion, error)
+	GetTemplateVersions(templateID, userID int64) ([]entities.TemplateVersion, error)
+	SetTemplatePartials(userID, templateID int64, names []string) error
+	GetTemplatesByPartials(userID int64, names []string, layoutIDs []int64) ([]entities.BaseTemplate, error)
+
+	CreatePartial(p *entities.Partial) error
+	UpdatePartial(p *entities.Partial) error
+	GetPartial(id, userID int64) (*entities.Partial, error)
+	GetPartialByName(name string, userID int64) (*entities.Partial, error)
+	GetPartials(userID int64) ([]entities.Partial, error)
+	DeletePartial(id, userID int64) error
+
+	CreateTeam(t *entities.Team) error
+	UpdateTeam(t *entities.Team) error
+	GetTeam(userID int64) (*entities.Team, error)
+	CreateTeamRole(r *entities.TeamRole) error
+	UpdateTeamRole(r *entities.TeamRole) error
+	GetTeamRole(id, teamID int64) (*entities.TeamRole, error)
+	GetTeamRoleByName(name string, teamID int64) (*entities.TeamRole, error)
+	GetTeamRoles(teamID int64) ([]entities.TeamRole, error)
+	DeleteTeamRole(id, teamID int64) error
+	GetTotalTeamRoleAssignments(roleID int64) (int64, error)
+	GetTeamMember(teamID, userID int64) (*entities.TeamMember, error)
+	GetTeamMemberByID(id, teamID int64) (*entities.TeamMember, error)
+	GetTeamMembers(teamID int64) ([]entities.TeamMember, error)
+	GetTeamMemberships(userID int64) ([]entities.TeamMember, error)
+	CreateTeamMember(m *entities.TeamMember) error
+	UpdateTeamMember(m *entities.TeamMember) error
+	DeleteTeamMember(id, teamID int64) error
+	GetTotalTeamMembers(teamID int64) (int64, error)
+	CreateTeamInvitation(i *entities.TeamInvitation) error
+	GetTeamInvitations(teamID int64) ([]entities.TeamInvitation, error)
+	GetTeamInvitationByEmail(email string, teamID int64) (*entities.TeamInvitation, error)
+	GetTeamInvitationByToken(token string) (*entities.TeamInvitation, error)
+	DeleteTeamInvitation(id, teamID int64) error
+	AcceptTeamInvitation(i *entities.TeamInvitation, userID int64) (*entities.TeamMember, error)
+
+	GetSSOConnections(userID int64) ([]entities.SSOConnection, error)
+	GetSSOConnection(id, userID int64) (*entities.SSOConnection, error)
+	GetSSOConnectionByID(id int64) (*entities.SSOConnection, error)
+	GetSSOConnectionByDomain(domain string) (*entities.SSOConnection, error)
+	CreateSSOConnection(conn *entities.SSOConnection) error
+	UpdateSSOConnection(conn *entities.SSOConnection) error
+	DeleteSSOConnection(id, userID int64) error
+	GetEnforcedSSOConnections(userID int64) ([]entities.SSOConnection, error)
+
+	SetTwoFactorSecret(userID int64, secret string) error
+	EnableTwoFactor(userID, counter int64, codes []string) error
+	DisableTwoFactor(userID int64) error
+	UseTwoFactorCounter(userID, counter int64) (bool, error)
+	ReplaceRecoveryCodes(userID int64, codes []string) error
+	UseRecoveryCode(userID int64, code string) error
+	GetTotalRecoveryCodes(userID int64) (int64, error)
 }
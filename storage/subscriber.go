@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -113,25 +114,38 @@ func (db *store) GetDistinctSubscribersBySegmentIDs(
 
 	var subs []entities.Subscriber
 
-	err := db.Table("subscribers").
-		Select("DISTINCT(id), name, email, created_at, metadata").
-		Joins("INNER JOIN subscribers_segments ON subscribers_segments.subscriber_id = subscribers.id").
+	segments, err := db.GetSegmentsByIDs(userID, listIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return subs, nil
+	}
+
+	cond, args, err := segmentMembersCond(db.Dialector.Name(), segments, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Table("subscribers").
+		Select("id, name, email, created_at, metadata, timezone").
 		Where(`
-			subscribers.user_id = ? 
-			AND subscribers_segments.segment_id IN (?)
-			AND subscribers.blacklisted = ? 
+			subscribers.user_id = ?
+			AND subscribers.blacklisted = ?
 			AND subscribers.active = ?
+			AND (subscribers.paused_until IS NULL OR subscribers.paused_until < ?)
 			AND (created_at > ? OR (created_at = ? AND id > ?))
 			AND created_at < ?`,
-			listIDs,
 			userID,
 			blacklisted,
 			active,
+			time.Now().UTC(),
 			timestamp.Format(time.RFC3339),
 			timestamp.Format(time.RFC3339),
 			nextID,
 			time.Now(),
 		).
+		Where(cond, args...).
 		Order("created_at, id").
 		Limit(int(limit)).
 		Find(&subs).Error
@@ -139,6 +153,82 @@ func (db *store) GetDistinctSubscribersBySegmentIDs(
 	return subs, err
 }
 
+// GetSegmentMemberIDs returns the ids of the given subscribers who are members of the segments and
+// didn't opt out of them, the same way the subscribers of a campaign are fetched when it's sent.
+func (db *store) GetSegmentMemberIDs(segmentIDs []int64, userID int64, subscriberIDs []int64) ([]int64, error) {
+	var ids []int64
+
+	segments, err := db.GetSegmentsByIDs(userID, segmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 || len(subscriberIDs) == 0 {
+		return ids, nil
+	}
+
+	cond, args, err := segmentMembersCond(db.Dialector.Name(), segments, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Table("subscribers").
+		Where("subscribers.user_id = ? AND subscribers.id IN (?)", userID, subscriberIDs).
+		Where(cond, args...).
+		Pluck("id", &ids).Error
+
+	return ids, err
+}
+
+// segmentMembersCond returns the condition matching the members of the segments, the static subscribers
+// of all segments are merged with the subscribers matching the segment rules, excluding the subscribers
+// who opted out of the segment from the preference center.
+func segmentMembersCond(dialect string, segments []entities.Segment, userID int64) (string, []interface{}, error) {
+	var (
+		ids   []int64
+		conds = []string{`subscribers.id IN (
+			SELECT ss.subscriber_id FROM subscribers_segments ss
+			WHERE ss.segment_id IN (?) AND NOT EXISTS (
+				SELECT 1 FROM segment_opt_outs o WHERE o.segment_id = ss.segment_id AND o.subscriber_id = ss.subscriber_id
+			)
+		)`}
+		args []interface{}
+	)
+	for _, seg := range segments {
+		ids = append(ids, seg.ID)
+	}
+	args = append(args, ids)
+
+	for _, seg := range segments {
+		rules, err := seg.GetRules()
+		if err != nil {
+			return "", nil, err
+		}
+		if rules == nil {
+			continue
+		}
+
+		cond, a, err := compileSegmentRules(dialect, rules, userID)
+		if err != nil {
+			return "", nil, err
+		}
+		conds = append(conds, "("+cond+" AND subscribers.id NOT IN (SELECT subscriber_id FROM segment_opt_outs WHERE segment_id = ?))")
+		args = append(args, a...)
+		args = append(args, seg.ID)
+	}
+
+	return "(" + strings.Join(conds, " OR ") + ")", args, nil
+}
+
+// CountSubscribersBySegmentRules returns the number of active subscribers matching the segment rules.
+func (db *store) CountSubscribersBySegmentRules(userID int64, rules *entities.SegmentRules) (int64, error) {
+	var count int64
+	err := db.Model(&entities.Subscriber{}).
+		Where("user_id = ? AND blacklisted = ? AND active = ?", userID, false, true).
+		Scopes(SegmentRulesScope(rules, userID)).
+		Count(&count).Error
+	return count, err
+}
+
 // CreateSubscriber creates a new subscriber and create subscribers event in the database.
 func (db *store) CreateSubscriber(s *entities.Subscriber) error {
 	tx := db.Begin()
@@ -149,6 +239,8 @@ func (db *store) CreateSubscriber(s *entities.Subscriber) error {
 		}
 	}()
 
+	s.SetTimezoneFromMetadata()
+
 	err := tx.Create(s).Error
 	if err != nil {
 		tx.Rollback()
@@ -178,6 +270,24 @@ func (db *store) CreateSubscriber(s *entities.Subscriber) error {
 		return fmt.Errorf("subscription store: add subscriber metric: %w", err)
 	}
 
+	err = createWebhookDeliveries(tx, s.UserID, entities.WebhookEventSubscriberCreated, s)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: add webhook deliveries (created): %w", err)
+	}
+
+	err = enrollInAutomations(tx, s.UserID, s.ID, entities.AutomationTriggerSubscriberCreated, nil)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: enroll in automations (created): %w", err)
+	}
+
+	err = enrollInSegmentAutomations(tx, s.UserID, s.ID, segmentIDs(s.Segments))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: enroll in automations (segment joined): %w", err)
+	}
+
 	return tx.Commit().Error
 }
 
@@ -190,16 +300,42 @@ func (db *store) UpdateSubscriber(s *entities.Subscriber) error {
 		}
 	}()
 
+	var current []int64
+	err := tx.Table("subscribers_segments").Where("subscriber_id = ?", s.ID).Pluck("segment_id", &current).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: fetch subscriber's segments: %w", err)
+	}
+
+	if err := enrollOnMetadataChange(tx, s); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: enroll in automations (metadata changed): %w", err)
+	}
+
 	if err := tx.Model(s).Association("Segments").Replace(s.Segments); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("subscription store: update subscriber's segment: %w", err)
 	}
 
+	s.SetTimezoneFromMetadata()
+
 	if err := tx.Where("id = ? and user_id = ?", s.ID, s.UserID).Save(s).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("subscription store: update subscriber: %w", err)
 	}
 
+	var joined []int64
+	for _, id := range segmentIDs(s.Segments) {
+		if !containsID(current, id) {
+			joined = append(joined, id)
+		}
+	}
+
+	if err := enrollInSegmentAutomations(tx, s.UserID, s.ID, joined); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: enroll in automations (segment joined): %w", err)
+	}
+
 	return tx.Commit().Error
 }
 
@@ -250,6 +386,13 @@ func (db *store) DeactivateSubscriber(userID int64, email string) error {
 		return fmt.Errorf("subscription store: add subscriber metric (unsubscribed): %w", err)
 	}
 
+	s.Active = false
+	err = createWebhookDeliveries(tx, userID, entities.WebhookEventSubscriberUnsubscribed, s)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: add webhook deliveries (unsubscribed): %w", err)
+	}
+
 	return tx.Commit().Error
 }
 
@@ -274,6 +417,18 @@ func (db *store) DeleteSubscriber(id, userID int64) error {
 		return fmt.Errorf("subscription store: delete subscriber's segment relation: %w", err)
 	}
 
+	err = tx.Where("subscriber_id = ?", s.ID).Delete(&entities.SegmentOptOut{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: delete subscriber's segment opt-outs: %w", err)
+	}
+
+	err = tx.Where("subscriber_id = ? AND user_id = ?", s.ID, userID).Delete(&entities.SubscriberConsent{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("subscription store: delete subscriber's consents: %w", err)
+	}
+
 	err = tx.Where("user_id = ?", userID).Delete(s).Error
 	if err != nil {
 		tx.Rollback()
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// CreateTeam creates the team along with its default roles.
+func (db *store) CreateTeam(t *entities.Team) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Create(t).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: create team: %w", err)
+	}
+
+	roles := entities.DefaultTeamRoles(t.ID)
+	err = tx.Create(&roles).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: create team roles: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// UpdateTeam edits the team of the user.
+func (db *store) UpdateTeam(t *entities.Team) error {
+	return db.Where("user_id = ? and id = ?", t.UserID, t.ID).Save(t).Error
+}
+
+// GetTeam returns the team which is owned by the user.
+func (db *store) GetTeam(userID int64) (*entities.Team, error) {
+	var t = new(entities.Team)
+	err := db.Where("user_id = ?", userID).First(t).Error
+	return t, err
+}
+
+// CreateTeamRole creates a new role of the team.
+func (db *store) CreateTeamRole(r *entities.TeamRole) error {
+	return db.Create(r).Error
+}
+
+// UpdateTeamRole edits the role of the team.
+func (db *store) UpdateTeamRole(r *entities.TeamRole) error {
+	return db.Where("team_id = ? and id = ?", r.TeamID, r.ID).Save(r).Error
+}
+
+// GetTeamRole returns the role by the given id and team id.
+func (db *store) GetTeamRole(id, teamID int64) (*entities.TeamRole, error) {
+	var r = new(entities.TeamRole)
+	err := db.Where("team_id = ? and id = ?", teamID, id).First(r).Error
+	return r, err
+}
+
+// GetTeamRoleByName returns the role by the given name and team id.
+func (db *store) GetTeamRoleByName(name string, teamID int64) (*entities.TeamRole, error) {
+	var r = new(entities.TeamRole)
+	err := db.Where("team_id = ? and name = ?", teamID, name).First(r).Error
+	return r, err
+}
+
+// GetTeamRoles returns the roles of the team ordered by name.
+func (db *store) GetTeamRoles(teamID int64) ([]entities.TeamRole, error) {
+	var roles []entities.TeamRole
+	err := db.Where("team_id = ?", teamID).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// DeleteTeamRole deletes the role with the given id and team id.
+func (db *store) DeleteTeamRole(id, teamID int64) error {
+	return db.Where("team_id = ? and id = ?", teamID, id).Delete(&entities.TeamRole{}).Error
+}
+
+// GetTotalTeamRoleAssignments returns the number of members and pending invitations with the role.
+func (db *store) GetTotalTeamRoleAssignments(roleID int64) (int64, error) {
+	var members, invitations int64
+	err := db.Model(&entities.TeamMember{}).Where("role_id = ?", roleID).Count(&members).Error
+	if err != nil {
+		return 0, err
+	}
+	err = db.Model(&entities.TeamInvitation{}).Where("role_id = ?", roleID).Count(&invitations).Error
+	return members + invitations, err
+}
+
+// GetTeamMember returns the membership of the user in the team, along with the team and the role.
+func (db *store) GetTeamMember(teamID, userID int64) (*entities.TeamMember, error) {
+	var m = new(entities.TeamMember)
+	err := db.Preload("Team").
+		Preload("Role").
+		Where("team_id = ? and user_id = ?", teamID, userID).
+		First(m).Error
+	return m, err
+}
+
+// GetTeamMemberByID returns the member by the given id and team id.
+func (db *store) GetTeamMemberByID(id, teamID int64) (*entities.TeamMember, error) {
+	var m = new(entities.TeamMember)
+	err := db.Preload("User").
+		Preload("Role").
+		Where("team_id = ? and id = ?", teamID, id).
+		First(m).Error
+	return m, err
+}
+
+// GetTeamMembers returns the members of the team along with their users and roles.
+func (db *store) GetTeamMembers(teamID int64) ([]entities.TeamMember, error) {
+	var members []entities.TeamMember
+	err := db.Preload("User").
+		Preload("Role").
+		Where("team_id = ?", teamID).
+		Order("id").
+		Find(&members).Error
+	return members, err
+}
+
+// GetTeamMemberships returns the memberships of the user in the teams of other users.
+func (db *store) GetTeamMemberships(userID int64) ([]entities.TeamMember, error) {
+	var members []entities.TeamMember
+	err := db.Preload("Team").
+		Preload("Role").
+		Where("user_id = ?", userID).
+		Order("id").
+		Find(&members).Error
+	return members, err
+}
+
+// CreateTeamMember adds the user to the team.
+func (db *store) CreateTeamMember(m *entities.TeamMember) error {
+	return db.Create(m).Error
+}
+
+// UpdateTeamMember edits the member of the team.
+func (db *store) UpdateTeamMember(m *entities.TeamMember) error {
+	return db.Model(&entities.TeamMember{}).
+		Where("team_id = ? and id = ?", m.TeamID, m.ID).
+		Update("role_id", m.RoleID).Error
+}
+
+// DeleteTeamMember removes the member with the given id from the team.
+func (db *store) DeleteTeamMember(id, teamID int64) error {
+	return db.Where("team_id = ? and id = ?", teamID, id).Delete(&entities.TeamMember{}).Error
+}
+
+// GetTotalTeamMembers returns the number of members of the team, including the pending invitations.
+func (db *store) GetTotalTeamMembers(teamID int64) (int64, error) {
+	var members, invitations int64
+	err := db.Model(&entities.TeamMember{}).Where("team_id = ?", teamID).Count(&members).Error
+	if err != nil {
+		return 0, err
+	}
+	err = db.Model(&entities.TeamInvitation{}).Where("team_id = ?", teamID).Count(&invitations).Error
+	return members + invitations, err
+}
+
+// CreateTeamInvitation creates a new invitation to the team.
+func (db *store) CreateTeamInvitation(i *entities.TeamInvitation) error {
+	return db.Create(i).Error
+}
+
+// GetTeamInvitations returns the pending invitations of the team.
+func (db *store) GetTeamInvitations(teamID int64) ([]entities.TeamInvitation, error) {
+	var invitations []entities.TeamInvitation
+	err := db.Where("team_id = ?", teamID).Order("id").Find(&invitations).Error
+	return invitations, err
+}
+
+// GetTeamInvitationByEmail returns the invitation of the email to the team.
+func (db *store) GetTeamInvitationByEmail(email string, teamID int64) (*entities.TeamInvitation, error) {
+	var i = new(entities.TeamInvitation)
+	err := db.Where("team_id = ? and email = ?", teamID, email).First(i).Error
+	return i, err
+}
+
+// GetTeamInvitationByToken returns the invitation with the given token along with its team.
+func (db *store) GetTeamInvitationByToken(token string) (*entities.TeamInvitation, error) {
+	var i = new(entities.TeamInvitation)
+	err := db.Preload("Team").Where("token = ?", token).First(i).Error
+	return i, err
+}
+
+// DeleteTeamInvitation deletes the invitation with the given id and team id.
+func (db *store) DeleteTeamInvitation(id, teamID int64) error {
+	return db.Where("team_id = ? and id = ?", teamID, id).Delete(&entities.TeamInvitation{}).Error
+}
+
+// AcceptTeamInvitation adds the user to the team with the role of the invitation, the invitation is
+// deleted in the same transaction so it can be accepted only once.
+func (db *store) AcceptTeamInvitation(i *entities.TeamInvitation, userID int64) (*entities.TeamMember, error) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	res := tx.Where("id = ?", i.ID).Delete(&entities.TeamInvitation{})
+	if res.Error != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("store: delete team invitation: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, fmt.Errorf("store: delete team invitation: %w", gorm.ErrRecordNotFound)
+	}
+
+	m := &entities.TeamMember{
+		TeamID: i.TeamID,
+		UserID: userID,
+		RoleID: i.RoleID,
+	}
+	err := tx.Create(m).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("store: create team member: %w", err)
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
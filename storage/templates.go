@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"fmt"
+
 	"github.com/mailbadger/app/entities"
 )
 
@@ -48,7 +50,32 @@ func (db *store) GetTemplates(userID int64, p *PaginationCursor, scopeMap map[st
 	return db.Paginate(p, userID)
 }
 
-// DeleteTemplate deletes the template with given template id and user id from db
+// DeleteTemplate deletes the template with its versions and partials with given template id and user id from db
 func (db *store) DeleteTemplate(templateID int64, userID int64) error {
-	return db.Where("user_id = ? and id = ?", userID, templateID).Delete(&entities.Template{}).Error
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Where("user_id = ? and template_id = ?", userID, templateID).Delete(&entities.TemplateVersion{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete template versions: %w", err)
+	}
+
+	err = tx.Where("user_id = ? and template_id = ?", userID, templateID).Delete(&entities.TemplatePartial{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete template partials: %w", err)
+	}
+
+	err = tx.Where("user_id = ? and id = ?", userID, templateID).Delete(&entities.Template{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete template: %w", err)
+	}
+
+	return tx.Commit().Error
 }
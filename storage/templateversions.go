@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// CreateTemplateVersion saves the template and creates a new version of it, the version number
+// is incremented in the same transaction so concurrent saves don't end up with the same version.
+func (db *store) CreateTemplateVersion(t *entities.Template, v *entities.TemplateVersion) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Model(&entities.Template{}).
+		Where("user_id = ? and id = ?", t.UserID, t.ID).
+		Updates(map[string]interface{}{
+			"name":          t.Name,
+			"subject_part":  t.SubjectPart,
+			"text_part":     t.TextPart,
+			"layout_id":     t.LayoutID,
+			"inline_css":    t.InlineCSS,
+			"minify_html":   t.MinifyHTML,
+			"sanitize_html": t.SanitizeHTML,
+			"generate_text": t.GenerateText,
+			"version":       gorm.Expr("version + 1"),
+			"updated_at":    time.Now().UTC(),
+		}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: update template: %w", err)
+	}
+
+	var version int64
+	err = tx.Model(&entities.Template{}).
+		Where("user_id = ? and id = ?", t.UserID, t.ID).
+		Pluck("version", &version).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: get template version: %w", err)
+	}
+
+	v.UserID = t.UserID
+	v.TemplateID = t.ID
+	v.Version = version
+	v.SubjectPart = t.SubjectPart
+	v.TextPart = t.TextPart
+	v.LayoutID = t.LayoutID
+	v.HTMLPipeline = t.HTMLPipeline
+	err = tx.Create(v).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: create template version: %w", err)
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		return err
+	}
+
+	t.Version = version
+	return nil
+}
+
+// GetTemplateVersion returns the version of the template by the given template id and user id.
+func (db *store) GetTemplateVersion(templateID, version, userID int64) (*entities.TemplateVersion, error) {
+	var v = new(entities.TemplateVersion)
+	err := db.Where("user_id = ? and template_id = ? and version = ?", userID, templateID, version).First(v).Error
+	return v, err
+}
+
+// GetTemplateVersions returns the versions of the template, the latest version first.
+func (db *store) GetTemplateVersions(templateID, userID int64) ([]entities.TemplateVersion, error) {
+	var versions []entities.TemplateVersion
+	err := db.Where("user_id = ? and template_id = ?", userID, templateID).
+		Order("version desc").
+		Find(&versions).Error
+	return versions, err
+}
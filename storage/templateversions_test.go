@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestTemplateVersions(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	tmpl := &entities.Template{
+		BaseTemplate: entities.BaseTemplate{
+			UserID:      1,
+			Name:        "versioned",
+			SubjectPart: "Hello",
+		},
+		TextPart: "Hello",
+	}
+	err := store.CreateTemplate(tmpl)
+	assert.Nil(t, err)
+
+	v1 := &entities.TemplateVersion{HTMLKey: "templates/1/v1"}
+	err = store.CreateTemplateVersion(tmpl, v1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), v1.Version)
+	assert.Equal(t, int64(1), tmpl.Version)
+
+	tmpl.Name = "renamed"
+	tmpl.SubjectPart = "Hello again"
+	v2 := &entities.TemplateVersion{HTMLKey: "templates/1/v2"}
+	err = store.CreateTemplateVersion(tmpl, v2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), v2.Version)
+
+	saved, err := store.GetTemplate(tmpl.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "renamed", saved.Name)
+	assert.Equal(t, "Hello again", saved.SubjectPart)
+	assert.Equal(t, int64(2), saved.Version)
+
+	v, err := store.GetTemplateVersion(tmpl.ID, 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", v.SubjectPart)
+	assert.Equal(t, "templates/1/v1", v.HTMLKey)
+
+	_, err = store.GetTemplateVersion(tmpl.ID, 1, 2)
+	assert.NotNil(t, err)
+
+	versions, err := store.GetTemplateVersions(tmpl.ID, 1)
+	assert.Nil(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, int64(2), versions[0].Version)
+
+	err = store.DeleteTemplate(tmpl.ID, 1)
+	assert.Nil(t, err)
+
+	versions, err = store.GetTemplateVersions(tmpl.ID, 1)
+	assert.Nil(t, err)
+	assert.Empty(t, versions)
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// CreateTransactionalMessage creates a new transactional message along with the event of it being queued.
+func (db *store) CreateTransactionalMessage(m *entities.TransactionalMessage) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Create(m).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: create transactional message: %w", err)
+	}
+
+	err = tx.Create(&entities.TransactionalEvent{
+		TransactionalMessageID: m.ID,
+		Type:                   entities.TransactionalEventQueued,
+		CreatedAt:              m.CreatedAt,
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: create transactional event: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// GetTransactionalMessage fetches a transactional message by the given message id and user id.
+func (db *store) GetTransactionalMessage(messageID string, userID int64) (*entities.TransactionalMessage, error) {
+	var m = new(entities.TransactionalMessage)
+	err := db.Where("message_id = ? AND user_id = ?", messageID, userID).First(m).Error
+	return m, err
+}
+
+// GetTransactionalMessageByIdempotencyKey fetches a transactional message by the given idempotency key and user id.
+func (db *store) GetTransactionalMessageByIdempotencyKey(key string, userID int64) (*entities.TransactionalMessage, error) {
+	var m = new(entities.TransactionalMessage)
+	err := db.Where("idempotency_key = ? AND user_id = ?", key, userID).First(m).Error
+	return m, err
+}
+
+// GetTransactionalEvents fetches the events of the transactional message in the order they occurred.
+func (db *store) GetTransactionalEvents(transactionalMessageID int64) ([]entities.TransactionalEvent, error) {
+	var events []entities.TransactionalEvent
+	err := db.Where("transactional_message_id = ?", transactionalMessageID).Order("created_at, id").Find(&events).Error
+	return events, err
+}
+
+// LogTransactionalEvent saves the status of the transactional message along with the event which changed it.
+func (db *store) LogTransactionalEvent(m *entities.TransactionalMessage, e *entities.TransactionalEvent) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	e.TransactionalMessageID = m.ID
+	err := tx.Create(e).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: create transactional event: %w", err)
+	}
+
+	m.UpdatedAt = time.Now().UTC()
+	err = tx.Model(m).Select("status", "provider_message_id", "description", "idempotency_key", "updated_at").Updates(m).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: update transactional message: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// IsRecipientSuppressed checks whether the emails to the recipient should not be sent, because the
+// subscriber is blacklisted, or the recipient complained or bounced permanently on an earlier email.
+func (db *store) IsRecipientSuppressed(userID int64, recipient string) (bool, error) {
+	var count int64
+	err := db.Model(&entities.Subscriber{}).
+		Where("user_id = ? AND email = ? AND blacklisted = ?", userID, recipient, true).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	err = db.Model(&entities.Complaint{}).
+		Where("user_id = ? AND recipient = ?", userID, recipient).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	err = db.Model(&entities.Bounce{}).
+		Where("user_id = ? AND recipient = ? AND type = ?", userID, recipient, entities.BounceTypePermanent).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	err = db.Model(&entities.TransactionalMessage{}).
+		Where("user_id = ? AND recipient = ? AND status = ?", userID, recipient, entities.TransactionalStatusComplained).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestTransactionalMessage(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	key := "order-1"
+	m := entities.NewTransactionalMessage(1, 2, "jane@example.com")
+	m.IdempotencyKey = &key
+
+	err := store.CreateTransactionalMessage(m)
+	assert.Nil(t, err)
+	assert.NotZero(t, m.ID)
+
+	// the idempotency key is unique per user
+	dup := entities.NewTransactionalMessage(1, 2, "jane@example.com")
+	dup.IdempotencyKey = &key
+	err = store.CreateTransactionalMessage(dup)
+	assert.NotNil(t, err)
+
+	other := entities.NewTransactionalMessage(2, 3, "jane@example.com")
+	other.IdempotencyKey = &key
+	err = store.CreateTransactionalMessage(other)
+	assert.Nil(t, err)
+
+	fetched, err := store.GetTransactionalMessage(m.MessageID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, m.ID, fetched.ID)
+	assert.Equal(t, entities.TransactionalStatusQueued, fetched.Status)
+
+	_, err = store.GetTransactionalMessage(m.MessageID, 2)
+	assert.NotNil(t, err)
+
+	fetched, err = store.GetTransactionalMessageByIdempotencyKey(key, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, m.ID, fetched.ID)
+
+	_, err = store.GetTransactionalMessageByIdempotencyKey("order-2", 1)
+	assert.NotNil(t, err)
+
+	providerID := "ses-message-id"
+	m.Status = entities.TransactionalStatusSent
+	m.ProviderMessageID = &providerID
+	err = store.LogTransactionalEvent(m, &entities.TransactionalEvent{
+		Type:        entities.TransactionalEventSend,
+		Description: entities.SendLogDescriptionOnSuccessful,
+	})
+	assert.Nil(t, err)
+
+	fetched, err = store.GetTransactionalMessage(m.MessageID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.TransactionalStatusSent, fetched.Status)
+	assert.Equal(t, &providerID, fetched.ProviderMessageID)
+
+	events, err := store.GetTransactionalEvents(m.ID)
+	assert.Nil(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, entities.TransactionalEventQueued, events[0].Type)
+	assert.Equal(t, entities.TransactionalEventSend, events[1].Type)
+}
+
+func TestIsRecipientSuppressed(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	suppressed, err := store.IsRecipientSuppressed(1, "jane@example.com")
+	assert.Nil(t, err)
+	assert.False(t, suppressed)
+
+	// the transient bounces don't suppress the recipient
+	err = store.CreateBounce(&entities.Bounce{UserID: 1, CampaignID: 1, Recipient: "jane@example.com", Type: "Transient"})
+	assert.Nil(t, err)
+	suppressed, err = store.IsRecipientSuppressed(1, "jane@example.com")
+	assert.Nil(t, err)
+	assert.False(t, suppressed)
+
+	err = store.CreateBounce(&entities.Bounce{UserID: 1, CampaignID: 1, Recipient: "jane@example.com", Type: entities.BounceTypePermanent})
+	assert.Nil(t, err)
+	suppressed, err = store.IsRecipientSuppressed(1, "jane@example.com")
+	assert.Nil(t, err)
+	assert.True(t, suppressed)
+
+	// the suppressions are per user
+	suppressed, err = store.IsRecipientSuppressed(2, "jane@example.com")
+	assert.Nil(t, err)
+	assert.False(t, suppressed)
+
+	err = store.CreateComplaint(&entities.Complaint{UserID: 1, CampaignID: 1, Recipient: "john@example.com"})
+	assert.Nil(t, err)
+	suppressed, err = store.IsRecipientSuppressed(1, "john@example.com")
+	assert.Nil(t, err)
+	assert.True(t, suppressed)
+
+	m := entities.NewTransactionalMessage(1, 2, "bob@example.com")
+	err = store.CreateTransactionalMessage(m)
+	assert.Nil(t, err)
+	m.Status = entities.TransactionalStatusComplained
+	err = store.LogTransactionalEvent(m, &entities.TransactionalEvent{Type: entities.TransactionalEventComplaint})
+	assert.Nil(t, err)
+	suppressed, err = store.IsRecipientSuppressed(1, "bob@example.com")
+	assert.Nil(t, err)
+	assert.True(t, suppressed)
+}
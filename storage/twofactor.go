@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// SetTwoFactorSecret sets the secret of the user who starts the enrollment, the two-factor
+// authentication remains disabled until it's confirmed.
+func (db *store) SetTwoFactorSecret(userID int64, secret string) error {
+	return db.Model(&entities.User{}).
+		Where("id = ? and two_factor_enabled = ?", userID, false).
+		Updates(map[string]interface{}{
+			"two_factor_secret":  secret,
+			"two_factor_counter": 0,
+		}).Error
+}
+
+// EnableTwoFactor enables the two-factor authentication of the user along with its recovery codes.
+func (db *store) EnableTwoFactor(userID, counter int64, codes []string) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Model(&entities.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"two_factor_enabled": true,
+			"two_factor_counter": counter,
+		}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: enable two factor: %w", err)
+	}
+
+	err = replaceRecoveryCodes(tx, userID, codes)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// DisableTwoFactor disables the two-factor authentication of the user and deletes its recovery codes.
+func (db *store) DisableTwoFactor(userID int64) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Model(&entities.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"two_factor_secret":  "",
+			"two_factor_counter": 0,
+		}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: disable two factor: %w", err)
+	}
+
+	err = tx.Where("user_id = ?", userID).Delete(&entities.RecoveryCode{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete recovery codes: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// UseTwoFactorCounter marks the time step of a code as used, it reports false when a code
+// of the same or a later time step was already used.
+func (db *store) UseTwoFactorCounter(userID, counter int64) (bool, error) {
+	res := db.Model(&entities.User{}).
+		Where("id = ? and two_factor_counter < ?", userID, counter).
+		UpdateColumn("two_factor_counter", counter)
+	return res.RowsAffected == 1, res.Error
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of the user with the given hashed codes.
+func (db *store) ReplaceRecoveryCodes(userID int64, codes []string) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := replaceRecoveryCodes(tx, userID, codes)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// UseRecoveryCode deletes the hashed recovery code of the user, so it can be used only once.
+func (db *store) UseRecoveryCode(userID int64, code string) error {
+	res := db.Where("user_id = ? and code = ?", userID, code).Delete(&entities.RecoveryCode{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetTotalRecoveryCodes returns the number of the unused recovery codes of the user.
+func (db *store) GetTotalRecoveryCodes(userID int64) (int64, error) {
+	var count int64
+	err := db.Model(&entities.RecoveryCode{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID int64, codes []string) error {
+	err := tx.Where("user_id = ?", userID).Delete(&entities.RecoveryCode{}).Error
+	if err != nil {
+		return fmt.Errorf("store: delete recovery codes: %w", err)
+	}
+
+	rc := make([]entities.RecoveryCode, len(codes))
+	for i, c := range codes {
+		rc[i] = entities.RecoveryCode{UserID: userID, Code: c}
+	}
+	err = tx.Create(&rc).Error
+	if err != nil {
+		return fmt.Errorf("store: create recovery codes: %w", err)
+	}
+	return nil
+}
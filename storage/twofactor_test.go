@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestTwoFactor(t *testing.T) {
+	db := openTestDb()
+	store := From(db)
+
+	err := store.SetTwoFactorSecret(1, "JBSWY3DPEHPK3PXP")
+	assert.Nil(t, err)
+
+	u, err := store.GetUser(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.TwoFactorSecret)
+	assert.False(t, u.TwoFactorEnabled)
+
+	err = store.EnableTwoFactor(1, 100, []string{
+		entities.HashRecoveryCode("aaaaa-bbbbb"),
+		entities.HashRecoveryCode("ccccc-ddddd"),
+	})
+	assert.Nil(t, err)
+
+	u, err = store.GetUser(1)
+	assert.Nil(t, err)
+	assert.True(t, u.TwoFactorEnabled)
+	assert.Equal(t, int64(100), u.TwoFactorCounter)
+
+	// the secret can't be changed once the two factor is enabled
+	err = store.SetTwoFactorSecret(1, "foobar")
+	assert.Nil(t, err)
+	u, err = store.GetUser(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.TwoFactorSecret)
+
+	ok, err := store.UseTwoFactorCounter(1, 100)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = store.UseTwoFactorCounter(1, 101)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	count, err := store.GetTotalRecoveryCodes(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	err = store.UseRecoveryCode(1, entities.HashRecoveryCode("AAAAABBBBB"))
+	assert.Nil(t, err)
+	err = store.UseRecoveryCode(1, entities.HashRecoveryCode("aaaaa-bbbbb"))
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	err = store.ReplaceRecoveryCodes(1, []string{entities.HashRecoveryCode("eeeee-fffff")})
+	assert.Nil(t, err)
+	err = store.UseRecoveryCode(1, entities.HashRecoveryCode("ccccc-ddddd"))
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	err = store.DisableTwoFactor(1)
+	assert.Nil(t, err)
+
+	u, err = store.GetUser(1)
+	assert.Nil(t, err)
+	assert.False(t, u.TwoFactorEnabled)
+	assert.Empty(t, u.TwoFactorSecret)
+
+	count, err = store.GetTotalRecoveryCodes(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
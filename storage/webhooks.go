@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/mailbadger/app/entities"
+)
+
+// GetWebhooks fetches the webhooks of the user.
+func (db *store) GetWebhooks(userID int64) ([]entities.Webhook, error) {
+	var webhooks []entities.Webhook
+	err := db.Where("user_id = ?", userID).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// GetWebhook fetches a webhook by the given id and user id.
+func (db *store) GetWebhook(id, userID int64) (*entities.Webhook, error) {
+	var w = new(entities.Webhook)
+	err := db.Where("id = ? AND user_id = ?", id, userID).First(w).Error
+	return w, err
+}
+
+// CreateWebhook creates a new webhook in the database.
+func (db *store) CreateWebhook(w *entities.Webhook) error {
+	return db.Create(w).Error
+}
+
+// UpdateWebhook edits an existing webhook in the database.
+func (db *store) UpdateWebhook(w *entities.Webhook) error {
+	return db.Where("id = ? AND user_id = ?", w.ID, w.UserID).Save(w).Error
+}
+
+// DeleteWebhook deletes the webhook along with its deliveries and their attempts.
+func (db *store) DeleteWebhook(id, userID int64) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Where("delivery_id IN (?)", tx.Table("webhook_deliveries").Select("id").Where("webhook_id = ? AND user_id = ?", id, userID)).
+		Delete(&entities.WebhookDeliveryAttempt{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete webhook delivery attempts: %w", err)
+	}
+
+	err = tx.Where("webhook_id = ? AND user_id = ?", id, userID).Delete(&entities.WebhookDelivery{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete webhook deliveries: %w", err)
+	}
+
+	err = tx.Where("id = ? AND user_id = ?", id, userID).Delete(&entities.Webhook{}).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: delete webhook: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// GetWebhookDeliveries fetches the deliveries of the webhook, and populates the pagination obj.
+func (db *store) GetWebhookDeliveries(webhookID, userID int64, p *PaginationCursor) error {
+	p.SetCollection(new([]entities.WebhookDelivery))
+	p.SetResource("webhook_deliveries")
+	p.AddScope(BelongsToWebhook(webhookID))
+
+	query := db.Table(p.Resource).
+		Where("user_id = ?", userID).
+		Order("created_at desc, id desc").
+		Limit(p.PerPage)
+
+	p.SetQuery(query)
+
+	return db.Paginate(p, userID)
+}
+
+// GetWebhookDelivery fetches a delivery of the webhook by the given id.
+func (db *store) GetWebhookDelivery(id, webhookID, userID int64) (*entities.WebhookDelivery, error) {
+	var d = new(entities.WebhookDelivery)
+	err := db.Where("id = ? AND webhook_id = ? AND user_id = ?", id, webhookID, userID).First(d).Error
+	return d, err
+}
+
+// GetWebhookDeliveryAttempts fetches the log of the attempts of the delivery.
+func (db *store) GetWebhookDeliveryAttempts(deliveryID int64) ([]entities.WebhookDeliveryAttempt, error) {
+	var attempts []entities.WebhookDeliveryAttempt
+	err := db.Where("delivery_id = ?", deliveryID).Order("id").Find(&attempts).Error
+	return attempts, err
+}
+
+// CreateWebhookDelivery creates a new webhook delivery in the database.
+func (db *store) CreateWebhookDelivery(d *entities.WebhookDelivery) error {
+	return db.Create(d).Error
+}
+
+// GetDueWebhookDeliveries fetches the pending deliveries which should be attempted before the given time.
+func (db *store) GetDueWebhookDeliveries(t time.Time, limit int) ([]entities.WebhookDelivery, error) {
+	var deliveries []entities.WebhookDelivery
+	err := db.Where("status = ? AND next_attempt_at <= ?", entities.WebhookDeliveryStatusPending, t).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimWebhookDelivery postpones the next attempt of the pending delivery by the given timeout, only if
+// the delivery is still due at the given time. It returns false if it was claimed or attempted in the meantime.
+func (db *store) ClaimWebhookDelivery(id int64, t time.Time, timeout time.Duration) (bool, error) {
+	res := db.Model(&entities.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, entities.WebhookDeliveryStatusPending, t).
+		Updates(map[string]interface{}{
+			"next_attempt_at": t.Add(timeout),
+			"updated_at":      time.Now().UTC(),
+		})
+
+	return res.RowsAffected > 0, res.Error
+}
+
+// LogWebhookDeliveryAttempt saves the outcome of the delivery along with the log of the attempt.
+func (db *store) LogWebhookDeliveryAttempt(d *entities.WebhookDelivery, a *entities.WebhookDeliveryAttempt) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Create(a).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: create webhook delivery attempt: %w", err)
+	}
+
+	err = tx.Model(d).Select("status", "attempts", "next_attempt_at", "updated_at").Updates(d).Error
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: update webhook delivery: %w", err)
+	}
+
+	return tx.Commit().Error
+}
+
+// BelongsToWebhook applies a scope for the webhook deliveries by the given webhook id.
+func BelongsToWebhook(webhookID int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("webhook_id = ?", webhookID)
+	}
+}
+
+// createWithWebhookEvent creates the record and queues the webhook deliveries of the event
+// in a single transaction, the record itself is the data of the event. The after funcs are
+// invoked in the same transaction.
+func (db *store) createWithWebhookEvent(value interface{}, userID int64, event string, after ...func(tx *gorm.DB) error) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err := tx.Create(value).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = createWebhookDeliveries(tx, userID, event, value)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("store: %s: %w", event, err)
+	}
+
+	for _, fn := range after {
+		err = fn(tx)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: %s: %w", event, err)
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// createWebhookDeliveries queues a delivery of the event for each active webhook of the user
+// that is subscribed to it. It is invoked in the same transaction in which the event is stored.
+func createWebhookDeliveries(tx *gorm.DB, userID int64, event string, data interface{}) error {
+	var webhooks []entities.Webhook
+	err := tx.Where("user_id = ? AND active = ?", userID, true).Find(&webhooks).Error
+	if err != nil {
+		return fmt.Errorf("fetch webhooks: %w", err)
+	}
+
+	var payload entities.JSON
+	for _, w := range webhooks {
+		if !w.HasEvent(event) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = entities.NewWebhookPayload(event, data)
+			if err != nil {
+				return fmt.Errorf("encode webhook payload: %w", err)
+			}
+		}
+
+		d := &entities.WebhookDelivery{
+			UserID:    userID,
+			WebhookID: w.ID,
+			Event:     event,
+			Payload:   payload,
+			Status:    entities.WebhookDeliveryStatusPending,
+		}
+		d.NextAttemptAt.SetValid(time.Now().UTC())
+
+		err = tx.Create(d).Error
+		if err != nil {
+			return fmt.Errorf("create webhook delivery: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mailbadger/app/entities"
+)
+
+func TestWebhooks(t *testing.T) {
+	db := openTestDb()
+
+	store := From(db)
+
+	w := &entities.Webhook{
+		UserID: 1,
+		URL:    "https://example.com/hook",
+		Secret: "secret",
+		Events: entities.JSON(`["subscriber.created","subscriber.unsubscribed"]`),
+		Active: true,
+	}
+	err := store.CreateWebhook(w)
+	assert.Nil(t, err)
+
+	inactive := &entities.Webhook{
+		UserID: 1,
+		URL:    "https://example.com/inactive",
+		Secret: "secret",
+		Events: entities.JSON(`["subscriber.created"]`),
+	}
+	err = store.CreateWebhook(inactive)
+	assert.Nil(t, err)
+
+	webhooks, err := store.GetWebhooks(1)
+	assert.Nil(t, err)
+	assert.Len(t, webhooks, 2)
+
+	_, err = store.GetWebhook(w.ID, 2)
+	assert.NotNil(t, err)
+
+	// creating and unsubscribing a subscriber queues the deliveries of the events.
+	sub := &entities.Subscriber{Email: "jane@example.com", UserID: 1, Active: true}
+	err = store.CreateSubscriber(sub)
+	assert.Nil(t, err)
+
+	err = store.DeactivateSubscriber(1, "jane@example.com")
+	assert.Nil(t, err)
+
+	// the webhook is not subscribed to the click events.
+	err = store.CreateClick(&entities.Click{UserID: 1, CampaignID: 1, Recipient: "jane@example.com", Link: "https://example.com"})
+	assert.Nil(t, err)
+
+	due, err := store.GetDueWebhookDeliveries(time.Now().UTC().Add(time.Second), 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 2)
+	assert.Equal(t, entities.WebhookEventSubscriberCreated, due[0].Event)
+	assert.Equal(t, entities.WebhookEventSubscriberUnsubscribed, due[1].Event)
+	assert.Equal(t, w.ID, due[0].WebhookID)
+
+	d := &due[0]
+	d.Attempts = 1
+	d.Status = entities.WebhookDeliveryStatusDelivered
+	d.NextAttemptAt.Valid = false
+	err = store.LogWebhookDeliveryAttempt(d, &entities.WebhookDeliveryAttempt{DeliveryID: d.ID, StatusCode: 200})
+	assert.Nil(t, err)
+
+	due, err = store.GetDueWebhookDeliveries(time.Now().UTC().Add(time.Second), 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 1)
+
+	// the delivery is claimed only once, and it is not due until the claim times out
+	now := time.Now().UTC().Add(time.Second)
+	ok, err := store.ClaimWebhookDelivery(due[0].ID, now, time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = store.ClaimWebhookDelivery(due[0].ID, now, time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	due, err = store.GetDueWebhookDeliveries(now, 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 0)
+
+	d, err = store.GetWebhookDelivery(d.ID, w.ID, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.WebhookDeliveryStatusDelivered, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+
+	attempts, err := store.GetWebhookDeliveryAttempts(d.ID)
+	assert.Nil(t, err)
+	assert.Len(t, attempts, 1)
+	assert.Equal(t, 200, attempts[0].StatusCode)
+
+	p := NewPaginationCursor("/api/webhooks/1/deliveries", 10)
+	err = store.GetWebhookDeliveries(w.ID, 1, p)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), p.Total)
+
+	err = store.DeleteWebhook(w.ID, 1)
+	assert.Nil(t, err)
+
+	_, err = store.GetWebhookDelivery(d.ID, w.ID, 1)
+	assert.NotNil(t, err)
+
+	webhooks, err = store.GetWebhooks(1)
+	assert.Nil(t, err)
+	assert.Len(t, webhooks, 1)
+}
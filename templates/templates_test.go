@@ -43,6 +43,44 @@ func TestInit(t *testing.T) {
 	_, err = html.Parse(resp.Body)
 	assert.Nil(t, err)
 
+	// render preferences.html
+	err = r.HTMLRender.Instance("preferences.html", gin.H{
+		"email": "foo@bar.com",
+		"t":     "foo",
+		"uuid":  "abcdefgh",
+		"name":  "foo",
+		"segments": []struct {
+			ID      int64
+			Name    string
+			Checked bool
+		}{{ID: 1, Name: "news", Checked: true}},
+		"metadata": []struct {
+			Key   string
+			Value string
+		}{{Key: "city", Value: "Skopje"}},
+		"paused_until": "January 2, 2006",
+		"saved":        "true",
+	}).Render(rec)
+
+	assert.Nil(t, err)
+	resp = rec.Result()
+	defer resp.Body.Close()
+
+	_, err = html.Parse(resp.Body)
+	assert.Nil(t, err)
+
+	// render subscribe-confirm.html
+	err = r.HTMLRender.Instance("subscribe-confirm.html", gin.H{
+		"token": "foo",
+	}).Render(rec)
+
+	assert.Nil(t, err)
+	resp = rec.Result()
+	defer resp.Body.Close()
+
+	_, err = html.Parse(resp.Body)
+	assert.Nil(t, err)
+
 	err = r.HTMLRender.Instance("non-existent-file.html", gin.H{}).Render(rec)
 	assert.NotNil(t, err)
 }
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCron is returned when the cron expression cannot be parsed.
+var ErrInvalidCron = errors.New("invalid cron expression")
+
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+var (
+	cronMonths = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	cronWeekdays = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// Cron is a parsed cron expression with the standard five fields: minute, hour,
+// day of month, month and day of week. Each field is stored as a bit set of the allowed values.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// when both the day of month and the day of week are restricted,
+	// a day matches if either of them matches.
+	domStar, dowStar bool
+}
+
+// ParseCron parses the cron expression, the descriptors @hourly, @daily,
+// @weekly, @monthly and @yearly are supported as well.
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidCron, len(fields))
+	}
+
+	var (
+		c   = new(Cron)
+		err error
+	)
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronWeekdays); err != nil {
+		return nil, err
+	}
+	// sunday can be written as both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*" || fields[2] == "?"
+	c.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return c, nil
+}
+
+// Next returns the first time after t which matches the expression, in the location of t.
+// The zero time is returned when there is no match in the next five years.
+func (c *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// forward returns next, unless the daylight saving change moved it before t, in which case
+// the search continues from the next hour.
+func forward(t, next time.Time) time.Time {
+	if !next.After(t) {
+		return t.Add(time.Hour)
+	}
+	return next
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case c.domStar && c.dowStar:
+		return true
+	case c.domStar:
+		return dow
+	case c.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps, e.g. "1,15", "1-5" or "*/10".
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("%w: invalid step in %q", ErrInvalidCron, field)
+			}
+			step = s
+			part = part[:i]
+		}
+
+		var lo, hi int
+		switch {
+		case part == "*" || part == "?":
+			lo, hi = min, max
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0], names); err != nil {
+				return 0, fmt.Errorf("%w: invalid value in %q", ErrInvalidCron, field)
+			}
+			if hi, err = parseCronValue(bounds[1], names); err != nil {
+				return 0, fmt.Errorf("%w: invalid value in %q", ErrInvalidCron, field)
+			}
+		default:
+			v, err := parseCronValue(part, names)
+			if err != nil {
+				return 0, fmt.Errorf("%w: invalid value in %q", ErrInvalidCron, field)
+			}
+			lo, hi = v, v
+			// a single value with a step, e.g. "5/15", runs until the end of the range
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%w: %q is out of range %d-%d", ErrInvalidCron, field, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when the request is made to an address which is not public.
+var ErrForbiddenAddress = errors.New("utils: forbidden address")
+
+// forbiddenNets are the ranges which are not covered by the net.IP helpers, but are still not
+// reachable from the internet, such as the shared address space used by some cloud metadata services.
+var forbiddenNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+// IsPublicIP checks whether the ip is a public unicast address. Loopback, private, link-local
+// (which includes the cloud metadata address 169.254.169.254) and reserved addresses are not public.
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() {
+		return false
+	}
+
+	for _, n := range forbiddenNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PublicDialControl is the control function of the dialers which connect only to public addresses.
+// It's called after the host is resolved, right before connecting, so it can't be bypassed with
+// DNS records that point to internal hosts.
+func PublicDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// NewPublicHTTPClient returns a http client for requests to the urls set by the users, it connects only
+// to public addresses. The address is checked after the host is resolved, right before connecting,
+// so it can't be bypassed with DNS records that point to internal hosts. Redirects are followed up to
+// maxRedirects times and each of them is checked in the same way, zero disables them.
+func NewPublicHTTPClient(timeout time.Duration, maxRedirects int) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: PublicDialControl,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		// the proxy from the environment is not used, since the address of the proxy would be checked instead.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return http.ErrUseLastResponse
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirect to %s", ErrForbiddenAddress, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPPeriod is the number of seconds in which a code is valid.
+	TOTPPeriod = 30
+	// TOTPDigits is the number of digits of a code.
+	TOTPDigits = 6
+	// totpSkew is the number of periods before and after the current one in which
+	// the codes are accepted, to allow for the clock drift of the devices.
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new base32 encoded secret for the time-based one-time passwords.
+func GenerateTOTPSecret() (string, error) {
+	b, err := GenerateRandomBytes(20)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPCounter returns the time step of the given time.
+func TOTPCounter(t time.Time) int64 {
+	return t.Unix() / TOTPPeriod
+}
+
+// TOTPCode returns the code of the secret for the given time step, as defined in RFC 6238.
+func TOTPCode(secret string, counter int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("totp: decode secret: %w", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	h := hmac.New(sha1.New, key)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", TOTPDigits, code%uint32(math.Pow10(TOTPDigits))), nil
+}
+
+// ValidateTOTP checks the code against the time steps around the given time and returns the matched
+// time step. The codes of the steps up to the last used one are rejected, so a code can be used only once.
+func ValidateTOTP(secret, code string, t time.Time, lastCounter int64) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	now := TOTPCounter(t)
+	for c := now - totpSkew; c <= now+totpSkew; c++ {
+		if c <= lastCounter {
+			continue
+		}
+		expected, err := TOTPCode(secret, c)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return c, true
+		}
+	}
+
+	return 0, false
+}
+
+// TOTPProvisioningURI returns the otpauth uri of the secret, which is shown as a QR code
+// to be scanned by the authenticator apps.
+func TOTPProvisioningURI(secret, issuer, account string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(TOTPDigits))
+	v.Set("period", fmt.Sprint(TOTPPeriod))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
@@ -2,7 +2,13 @@ package utils
 
 import (
 	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -38,3 +44,111 @@ func TestSignData(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "bd209680297c13ce4d5eaf0c8dea68691de725cfb7ae116b8e8845a9606b22d4", hash)
 }
+
+func TestParseCron(t *testing.T) {
+	invalid := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "foo * * * *"}
+	for _, expr := range invalid {
+		_, err := ParseCron(expr)
+		assert.ErrorIs(t, err, ErrInvalidCron, expr)
+	}
+
+	ny, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		expr string
+		from time.Time
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2021, 3, 1, 10, 7, 30, 0, time.UTC), time.Date(2021, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC), time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)},
+		// every monday and friday at 09:30
+		{"30 9 * * mon,5", time.Date(2021, 3, 2, 10, 0, 0, 0, time.UTC), time.Date(2021, 3, 5, 9, 30, 0, 0, time.UTC)},
+		// sunday written as 7
+		{"0 8 * * 7", time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC), time.Date(2021, 3, 7, 8, 0, 0, 0, time.UTC)},
+		// the 31st is skipped in the months which are shorter
+		{"0 0 31 * *", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC)},
+		// day of month or day of week
+		{"0 0 15 * sun", time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)},
+		// the time is kept in the location across the daylight saving change
+		{"0 9 * * *", time.Date(2021, 3, 13, 10, 0, 0, 0, ny), time.Date(2021, 3, 14, 9, 0, 0, 0, ny)},
+		{"0 0 29 2 *", time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		assert.Nil(t, err, tt.expr)
+		next := c.Next(tt.from)
+		assert.True(t, tt.next.Equal(next), "%s: expected %v, got %v", tt.expr, tt.next, next)
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// the secret and the codes of the SHA1 test vectors of RFC 6238
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		code, err := TOTPCode(secret, TOTPCounter(time.Unix(tt.unix, 0)))
+		assert.Nil(t, err)
+		assert.Equal(t, tt.code, code)
+	}
+
+	now := time.Unix(1234567890, 0)
+	counter, ok := ValidateTOTP(secret, "005924", now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPCounter(now), counter)
+
+	// the codes of the previous and the next period are accepted
+	_, ok = ValidateTOTP(secret, "005924", now.Add(TOTPPeriod*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(secret, "005924", now.Add(-TOTPPeriod*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(secret, "005924", now.Add(2*TOTPPeriod*time.Second), 0)
+	assert.False(t, ok)
+
+	// the code can't be used twice
+	_, ok = ValidateTOTP(secret, "005924", now, counter)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "123", now, 0)
+	assert.False(t, ok)
+
+	secret, err := GenerateTOTPSecret()
+	assert.Nil(t, err)
+	assert.Len(t, secret, 32)
+
+	assert.Equal(
+		t,
+		"otpauth://totp/Mailbadger:john@example.com?algorithm=SHA1&digits=6&issuer=Mailbadger&period=30&secret="+secret,
+		TOTPProvisioningURI(secret, "Mailbadger", "john@example.com"),
+	)
+}
+
+func TestPublicHTTPClient(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "10.0.0.1", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.100.100.200", "0.0.0.0", "::1", "fd00:ec2::254", "fe80::1"} {
+		assert.False(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"8.8.8.8", "93.184.216.34", "2606:4700:4700::1111"} {
+		assert.True(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// the host is resolved before the address is checked
+	u := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	_, err := NewPublicHTTPClient(time.Second, 0).Get(u)
+	assert.True(t, errors.Is(err, ErrForbiddenAddress))
+}
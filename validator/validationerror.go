@@ -40,22 +40,30 @@ func (q *ValidationError) FormatErrors() {
 		switch err.ActualTag() {
 		case "email":
 			q.Errors[err.Field()] = "Invalid email format"
-		case "required":
+		case "required", "required_if", "required_unless", "required_with", "required_without":
 			q.Errors[err.Field()] = "This field is required"
 		case "max":
 			q.Errors[err.Field()] = "Max length allowed is " + err.Param()
 		case "min":
 			q.Errors[err.Field()] = "Must be at least " + err.Param() + " character long"
+		case "gte":
+			q.Errors[err.Field()] = "Must be greater than or equal to " + err.Param()
+		case "lte":
+			q.Errors[err.Field()] = "Must be less than or equal to " + err.Param()
 		case "alphanum":
 			q.Errors[err.Field()] = "Only alphanumeric characters allowed"
 		case "oneof":
 			q.Errors[err.Field()] = "Must be one of: " + err.Param()
+		case "url":
+			q.Errors[err.Field()] = "Invalid url format"
 		case "html":
 			q.Errors[err.Field()] = "Content must be html"
 		case tagAlphanumericHyphen:
 			q.Errors[err.Field()] = "Must consist only of alphanumeric and hyphen characters"
 		case "datetime":
 			q.Errors[err.Field()] = "Must be of format: " + err.Param()
+		case "timezone":
+			q.Errors[err.Field()] = "Invalid time zone"
 		default:
 			q.Errors[err.Field()] = "Validation failed on condition: " + err.ActualTag()
 		}